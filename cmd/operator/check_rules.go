@@ -0,0 +1,107 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/rulecheck"
+)
+
+const checkRulesCommand = "check-rules"
+
+// checkRules evaluates the alerting rules from the assets directory against
+// a TSDB directory (e.g. a snapshot or a must-gather dump of the platform
+// Prometheus) and prints the alerts which would have fired.
+func checkRules(args []string) int {
+	flagset := flag.NewFlagSet(checkRulesCommand, flag.ContinueOnError)
+	flagset.Usage = func() {
+		fmt.Fprintf(flagset.Output(), "Usage: %s %s --tsdb-path <dir> [flags]\n\nEvaluate the shipped alerting rules against recorded TSDB data and report the alerts which would have fired.\n\n", os.Args[0], checkRulesCommand)
+		flagset.PrintDefaults()
+	}
+	tsdbPath := flagset.String("tsdb-path", "", "The path to the TSDB directory.")
+	assetsPath := flagset.String("assets", "/assets", "The path to the assets directory containing the PrometheusRule manifests.")
+	interval := flagset.Duration("interval", rulecheck.DefaultInterval, "The rule evaluation interval.")
+	start := flagset.String("start", "", "Start of the evaluation range (RFC3339). Defaults to the start of the oldest block.")
+	end := flagset.String("end", "", "End of the evaluation range (RFC3339). Defaults to the end of the newest block, set it to include samples from the WAL.")
+	if err := flagset.Parse(args); err != nil {
+		return 2
+	}
+
+	if *tsdbPath == "" {
+		fmt.Fprint(os.Stderr, "`--tsdb-path` flag is required, but not specified.\n")
+		return 2
+	}
+
+	rules, err := rulecheck.LoadRules(*assetsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	db, err := rulecheck.OpenDB(*tsdbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	mint, err := parseTime(*start, db.MinTime)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid `--start` value: %v\n", err)
+		return 2
+	}
+	maxt, err := parseTime(*end, db.MaxTime)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid `--end` value: %v\n", err)
+		return 2
+	}
+	if mint.IsZero() || maxt.IsZero() {
+		fmt.Fprint(os.Stderr, "No persisted block found, `--start` and `--end` flags are required.\n")
+		return 2
+	}
+
+	alerts, err := rulecheck.NewChecker(db, *interval).Check(context.Background(), rules, mint, maxt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("Evaluated %d alerting rules from %s to %s: %d alerts would have fired.\n\n", len(rules), mint.UTC().Format(time.RFC3339), maxt.UTC().Format(time.RFC3339), len(alerts))
+	if len(alerts) == 0 {
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALERT\tGROUP\tFIRED AT\tLAST FIRING AT\tLABELS")
+	for _, a := range alerts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.Name, a.Group, a.FiredAt.Format(time.RFC3339), a.ResolvedAt.Format(time.RFC3339), a.Labels)
+	}
+	w.Flush()
+
+	return 0
+}
+
+func parseTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -16,6 +16,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"net/http"
@@ -84,6 +85,15 @@ type telemetryConfig struct {
 }
 
 func Main() int {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case checkRulesCommand:
+			return checkRules(os.Args[2:])
+		case validateConfigCommand:
+			return validateConfig(os.Args[2:])
+		}
+	}
+
 	flagset := flag.CommandLine
 	klog.InitFlags(flagset)
 	namespace := flagset.String("namespace", "openshift-monitoring", "Namespace to deploy and manage cluster monitoring stack in.")
@@ -95,6 +105,9 @@ func Main() int {
 	telemetryConfigFile := flagset.String("telemetry-config", "/etc/cluster-monitoring-operator/telemetry/metrics.yaml", "Path to telemetry-config.")
 	remoteWrite := flagset.Bool("enabled-remote-write", false, "Whether to use legacy telemetry write protocol or Prometheus remote write.")
 	assetsPath := flagset.String("assets", "/assets", "The path to the assets directory.")
+	webhookListenAddress := flagset.String("webhook-listen-address", "", "The address serving the validating admission webhook of the configuration ConfigMaps. Disabled if empty.")
+	webhookCertFile := flagset.String("webhook-tls-cert-file", "/etc/tls/private/tls.crt", "The path to the TLS certificate of the validating admission webhook.")
+	webhookKeyFile := flagset.String("webhook-tls-private-key-file", "/etc/tls/private/tls.key", "The path to the TLS private key of the validating admission webhook.")
 	images := images{}
 	flag.Var(&images, "images", "Images to use for containers managed by the cluster-monitoring-operator.")
 	flag.Parse()
@@ -194,6 +207,7 @@ func Main() int {
 	o.RegisterMetrics(r)
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.HandlerFor(r, promhttp.HandlerOpts{}))
+	mux.Handle("/footprint", o.FootprintHandler())
 	mux.HandleFunc("/debug/pprof/", pprof.Index)
 	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
 	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
@@ -201,6 +215,32 @@ func Main() int {
 	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 	go http.ListenAndServe("127.0.0.1:8080", mux)
 
+	if *webhookListenAddress != "" {
+		webhookMux := http.NewServeMux()
+		webhookMux.Handle("/validate-configmaps", o.ConfigValidationHandler())
+		srv := &http.Server{
+			Addr:    *webhookListenAddress,
+			Handler: webhookMux,
+			TLSConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				// The serving certificate is rotated by the service CA
+				// operator hence it is read again for every handshake.
+				GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+					cert, err := tls.LoadX509KeyPair(*webhookCertFile, *webhookKeyFile)
+					if err != nil {
+						return nil, err
+					}
+					return &cert, nil
+				},
+			},
+		}
+		go func() {
+			if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				klog.Errorf("Failed to serve the validating admission webhook: %v", err)
+			}
+		}()
+	}
+
 	wg, ctx := errgroup.WithContext(ctx)
 
 	wg.Go(func() error { return o.Run(ctx) })
@@ -0,0 +1,249 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	cmo "github.com/openshift/cluster-monitoring-operator/pkg/operator"
+)
+
+const validateConfigCommand = "validate-config"
+
+// validateConfig checks a proposed configuration like the operator would on
+// the next reconciliation, with stricter checks catching typos, and prints
+// the changes of the rendered objects compared to a base configuration.
+func validateConfig(args []string) int {
+	flagset := flag.NewFlagSet(validateConfigCommand, flag.ContinueOnError)
+	flagset.Usage = func() {
+		fmt.Fprintf(flagset.Output(), "Usage: %s %s -f <config.yaml> [flags]\n\nValidate a proposed Cluster Monitoring configuration and print the changes of the rendered objects.\nThe files hold either the config.yaml content or the whole ConfigMap.\n\n", os.Args[0], validateConfigCommand)
+		flagset.PrintDefaults()
+	}
+	configFile := flagset.String("f", "", "The path to the proposed cluster monitoring configuration.")
+	userWorkloadConfigFile := flagset.String("user-workload-config", "", "The path to the proposed user workload monitoring configuration.")
+	baseConfigFile := flagset.String("base", "", "The path to the current cluster monitoring configuration to compare with. Defaults to the default configuration.")
+	baseUserWorkloadConfigFile := flagset.String("base-user-workload-config", "", "The path to the current user workload monitoring configuration to compare with. Defaults to the default configuration.")
+	assetsPath := flagset.String("assets", "/assets", "The path to the assets directory.")
+	namespace := flagset.String("namespace", "openshift-monitoring", "Namespace of the cluster monitoring stack.")
+	namespaceUserWorkload := flagset.String("namespace-user-workload", "openshift-user-workload-monitoring", "Namespace of the user workload monitoring stack.")
+	diff := flagset.Bool("diff", true, "Print the changes of the rendered objects.")
+	if err := flagset.Parse(args); err != nil {
+		return 2
+	}
+
+	if *configFile == "" {
+		fmt.Fprint(os.Stderr, "`-f` flag is required, but not specified.\n")
+		return 2
+	}
+
+	if _, err := os.Stat(*assetsPath); os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Could not find assets directory: %v\n", err)
+		return 2
+	}
+	assets := manifests.NewAssets(*assetsPath)
+
+	proposed, err := loadConfigFiles(*configFile, *userWorkloadConfigFile, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+
+	f := newValidationFactory(*namespace, *namespaceUserWorkload, proposed, assets)
+	if err := f.ValidateConfigStrict(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+
+	// Like the validating webhook, the user workload configuration is
+	// validated even when user workload monitoring isn't enabled.
+	if *userWorkloadConfigFile != "" && !*proposed.ClusterMonitoringConfiguration.UserWorkloadEnabled {
+		c, err := loadConfigFiles(*configFile, *userWorkloadConfigFile, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+			return 1
+		}
+		enabled := true
+		c.ClusterMonitoringConfiguration.UserWorkloadEnabled = &enabled
+
+		if err := newValidationFactory(*namespace, *namespaceUserWorkload, c, assets).ValidateConfigStrict(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Println("The configuration is valid.")
+	if !*diff {
+		return 0
+	}
+
+	base, err := loadConfigFiles(*baseConfigFile, *baseUserWorkloadConfigFile, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid base configuration: %v\n", err)
+		return 1
+	}
+
+	before, err := newValidationFactory(*namespace, *namespaceUserWorkload, base, assets).RenderConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid base configuration: %v\n", err)
+		return 1
+	}
+	after, err := f.RenderConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+
+	changes, err := diffObjects(before, after)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if changes == "" {
+		fmt.Println("No rendered object changes.")
+		return 0
+	}
+	fmt.Print("\n" + changes)
+
+	return 0
+}
+
+// loadConfigFiles parses the cluster and user workload configuration files.
+// Empty paths stand for the default configurations. When strict is set, the
+// fields unknown to the operator are rejected.
+func loadConfigFiles(configFile, userWorkloadConfigFile string, strict bool) (*manifests.Config, error) {
+	content, err := readConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if strict {
+		if err := manifests.CheckConfigFields(content); err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := manifests.NewConfigFromString(content)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err = readConfigFile(userWorkloadConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if strict {
+		if err := manifests.CheckUserConfigFields(content); err != nil {
+			return nil, err
+		}
+	}
+
+	uwc, err := manifests.NewUserConfigFromString(content)
+	if err != nil {
+		return nil, err
+	}
+	c.UserWorkloadConfiguration = uwc
+
+	return c, nil
+}
+
+// readConfigFile returns the configuration held by the file, either as is or
+// under the config.yaml key of a ConfigMap.
+func readConfigFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var cm struct {
+		Kind string            `json:"kind"`
+		Data map[string]string `json:"data"`
+	}
+	if err := yaml.Unmarshal(b, &cm); err == nil && cm.Kind == "ConfigMap" {
+		return cm.Data["config.yaml"], nil
+	}
+
+	return string(b), nil
+}
+
+// newValidationFactory returns a factory rendering the objects without
+// cluster: the infrastructure, proxy and API server settings are the
+// defaults.
+func newValidationFactory(namespace, namespaceUserWorkload string, c *manifests.Config, assets *manifests.Assets) *manifests.Factory {
+	return manifests.NewFactory(namespace, namespaceUserWorkload, c, cmo.NewDefaultInfrastructureConfig(), c, assets, &manifests.APIServerConfig{})
+}
+
+// diffObjects returns the unified diffs of the YAML representations of the
+// rendered objects, sorted by name.
+func diffObjects(before, after map[string]interface{}) (string, error) {
+	names := make([]string, 0, len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	for name := range before {
+		if _, found := after[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var out string
+	for _, name := range names {
+		a, err := marshalObject(before[name])
+		if err != nil {
+			return "", err
+		}
+		b, err := marshalObject(after[name])
+		if err != nil {
+			return "", err
+		}
+
+		d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(a),
+			B:        difflib.SplitLines(b),
+			FromFile: name + " (base)",
+			ToFile:   name + " (proposed)",
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+		out += d
+	}
+
+	return out, nil
+}
+
+func marshalObject(obj interface{}) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
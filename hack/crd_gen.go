@@ -0,0 +1,396 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build ignore
+// +build ignore
+
+// This program generates the ClusterMonitoring and UserWorkloadMonitoring
+// CustomResourceDefinitions. Their spec mirrors the config.yaml key of the
+// configuration ConfigMaps: the OpenAPI schema is derived from the Go types
+// parsed by the operator and the descriptions from their doc comments.
+//
+// Usage: go run hack/crd_gen.go <manifests directory>
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	manifestsPackage = "github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	clientPackage    = "github.com/openshift/cluster-monitoring-operator/pkg/client"
+)
+
+// overrides completes the generated schema of a field, identified by the Go
+// type name and the JSON field name, with the validations and defaults which
+// can't be derived from the Go types.
+var overrides = map[string]func(*apiextensionsv1.JSONSchemaProps){
+	"ClusterMonitoringConfiguration.enableUserWorkload": func(s *apiextensionsv1.JSONSchemaProps) {
+		s.Default = &apiextensionsv1.JSON{Raw: []byte("false")}
+	},
+	"PrometheusK8sConfig.collectionProfile": enum(
+		string(manifests.DefaultCollectionProfile),
+		string(manifests.MinimalCollectionProfile),
+		string(manifests.FullCollectionProfile),
+	),
+	"UserWorkloadConfiguration.defaultRuleEvaluationScope": enum(
+		manifests.RuleEvaluationScopeThanosRuler,
+		manifests.RuleEvaluationScopeLeafPrometheus,
+	),
+}
+
+func enum(values ...string) func(*apiextensionsv1.JSONSchemaProps) {
+	return func(s *apiextensionsv1.JSONSchemaProps) {
+		for _, v := range values {
+			s.Enum = append(s.Enum, apiextensionsv1.JSON{Raw: []byte(fmt.Sprintf("%q", v))})
+		}
+	}
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: crd_gen <manifests directory>")
+		os.Exit(1)
+	}
+
+	g := &generator{docs: map[string]map[string]string{}}
+	for pkg, dir := range map[string]string{
+		manifestsPackage: "pkg/manifests",
+		clientPackage:    "pkg/client",
+	} {
+		docs, err := parseDocs(dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		g.docs[pkg] = docs
+	}
+
+	for _, crd := range []struct {
+		file        string
+		gvr         schema.GroupVersionResource
+		kind        string
+		scope       apiextensionsv1.ResourceScope
+		description string
+		spec        reflect.Type
+		// status is the type of the status subresource, if any.
+		status  reflect.Type
+		columns []apiextensionsv1.CustomResourceColumnDefinition
+	}{
+		{
+			file:        "0000_50_cluster-monitoring-operator_00_1clustermonitoring-custom-resource-definition.yaml",
+			gvr:         client.ClusterMonitoringGVR,
+			kind:        "ClusterMonitoring",
+			scope:       apiextensionsv1.ClusterScoped,
+			description: fmt.Sprintf("ClusterMonitoring configures the platform monitoring stack. Only the resource named %q is read by the cluster monitoring operator. It takes precedence over the cluster-monitoring-config ConfigMap.", client.ClusterMonitoringName),
+			spec:        reflect.TypeOf(manifests.ClusterMonitoringConfiguration{}),
+			status:      reflect.TypeOf(client.ClusterMonitoringStatus{}),
+			columns: []apiextensionsv1.CustomResourceColumnDefinition{
+				{Name: "Prometheus", Type: "string", JSONPath: ".status.prometheus.available", Description: "Whether the platform Prometheus is available."},
+				{Name: "Alertmanager", Type: "string", JSONPath: ".status.alertmanager.available", Description: "Whether the platform Alertmanager is available."},
+				{Name: "ThanosQuerier", Type: "string", JSONPath: ".status.thanosQuerier.available", Description: "Whether Thanos Querier is available."},
+				{Name: "UserWorkload", Type: "string", JSONPath: ".status.userWorkload.available", Description: "Whether the user workload monitoring stack is available."},
+				{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+			},
+		},
+		{
+			file:        "0000_50_cluster-monitoring-operator_00_1userworkloadmonitoring-custom-resource-definition.yaml",
+			gvr:         client.UserWorkloadMonitoringGVR,
+			kind:        "UserWorkloadMonitoring",
+			scope:       apiextensionsv1.NamespaceScoped,
+			description: fmt.Sprintf("UserWorkloadMonitoring configures the user workload monitoring stack. Only the resource named %q in the openshift-user-workload-monitoring namespace is read by the cluster monitoring operator. It takes precedence over the user-workload-monitoring-config ConfigMap.", client.UserWorkloadMonitoringName),
+			spec:        reflect.TypeOf(manifests.UserWorkloadConfiguration{}),
+		},
+	} {
+		spec := g.schema(crd.spec, nil)
+		spec.Default = &apiextensionsv1.JSON{Raw: []byte("{}")}
+
+		properties := map[string]apiextensionsv1.JSONSchemaProps{
+			"apiVersion": {Type: "string"},
+			"kind":       {Type: "string"},
+			"metadata":   {Type: "object"},
+			"spec":       *spec,
+		}
+		var subresources *apiextensionsv1.CustomResourceSubresources
+		if crd.status != nil {
+			properties["status"] = *g.schema(crd.status, nil)
+			subresources = &apiextensionsv1.CustomResourceSubresources{Status: &apiextensionsv1.CustomResourceSubresourceStatus{}}
+		}
+
+		singular := strings.ToLower(crd.kind)
+		obj := apiextensionsv1.CustomResourceDefinition{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "apiextensions.k8s.io/v1",
+				Kind:       "CustomResourceDefinition",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: crd.gvr.Resource + "." + crd.gvr.Group,
+				Annotations: map[string]string{
+					"include.release.openshift.io/ibm-cloud-managed":              "true",
+					"include.release.openshift.io/self-managed-high-availability": "true",
+					"include.release.openshift.io/single-node-developer":          "true",
+				},
+			},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				Group: crd.gvr.Group,
+				Names: apiextensionsv1.CustomResourceDefinitionNames{
+					Kind:     crd.kind,
+					ListKind: crd.kind + "List",
+					Plural:   crd.gvr.Resource,
+					Singular: singular,
+				},
+				Scope: crd.scope,
+				Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+					{
+						Name:    crd.gvr.Version,
+						Served:  true,
+						Storage: true,
+						Schema: &apiextensionsv1.CustomResourceValidation{
+							OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+								Description: crd.description,
+								Type:        "object",
+								Properties:  properties,
+							},
+						},
+						Subresources:             subresources,
+						AdditionalPrinterColumns: crd.columns,
+					},
+				},
+			},
+			Status: apiextensionsv1.CustomResourceDefinitionStatus{
+				Conditions:     []apiextensionsv1.CustomResourceDefinitionCondition{},
+				StoredVersions: []string{},
+			},
+		}
+
+		b, err := yaml.Marshal(obj)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(os.Args[1], crd.file), b, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+type generator struct {
+	// docs maps the Go type names and the "Type.Field" names of the
+	// manifests and client packages to their doc comments, by package path.
+	docs map[string]map[string]string
+}
+
+var (
+	quantityType    = reflect.TypeOf(resource.Quantity{})
+	intOrStringType = reflect.TypeOf(intstr.IntOrString{})
+	timeType        = reflect.TypeOf(metav1.Time{})
+	durationType    = reflect.TypeOf(metav1.Duration{})
+)
+
+// schema returns the OpenAPI schema of the type as decoded by encoding/json.
+// The types being generated are tracked to cut recursive definitions.
+func (g *generator) schema(t reflect.Type, stack []reflect.Type) *apiextensionsv1.JSONSchemaProps {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case quantityType, intOrStringType:
+		return &apiextensionsv1.JSONSchemaProps{
+			AnyOf:        []apiextensionsv1.JSONSchemaProps{{Type: "integer"}, {Type: "string"}},
+			XIntOrString: true,
+		}
+	case timeType:
+		return &apiextensionsv1.JSONSchemaProps{Type: "string", Format: "date-time"}
+	case durationType:
+		return &apiextensionsv1.JSONSchemaProps{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &apiextensionsv1.JSONSchemaProps{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &apiextensionsv1.JSONSchemaProps{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &apiextensionsv1.JSONSchemaProps{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &apiextensionsv1.JSONSchemaProps{Type: "number"}
+	case reflect.String:
+		return &apiextensionsv1.JSONSchemaProps{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &apiextensionsv1.JSONSchemaProps{Type: "string", Format: "byte"}
+		}
+		return &apiextensionsv1.JSONSchemaProps{
+			Type:  "array",
+			Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: g.nullable(t.Elem(), stack)},
+		}
+	case reflect.Map:
+		return &apiextensionsv1.JSONSchemaProps{
+			Type:                 "object",
+			AdditionalProperties: &apiextensionsv1.JSONSchemaPropsOrBool{Allows: true, Schema: g.nullable(t.Elem(), stack)},
+		}
+	case reflect.Struct:
+		for _, s := range stack {
+			if s == t {
+				return &apiextensionsv1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: boolPtr(true)}
+			}
+		}
+		stack = append(stack, t)
+
+		s := &apiextensionsv1.JSONSchemaProps{Type: "object", Properties: map[string]apiextensionsv1.JSONSchemaProps{}}
+		s.Description = g.docs[t.PkgPath()][t.Name()]
+		g.properties(t, s, stack)
+		return s
+	default:
+		return &apiextensionsv1.JSONSchemaProps{XPreserveUnknownFields: boolPtr(true)}
+	}
+}
+
+// properties adds the fields of the struct to the schema following the
+// encoding/json rules.
+func (g *generator) properties(t reflect.Type, s *apiextensionsv1.JSONSchemaProps, stack []reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.properties(ft, s, stack)
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			// Unexported field.
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		p := g.nullable(f.Type, stack)
+		if doc := g.docs[t.PkgPath()][t.Name()+"."+f.Name]; doc != "" {
+			p.Description = doc
+		}
+		if t.PkgPath() == manifestsPackage {
+			if o, found := overrides[t.Name()+"."+name]; found {
+				o(p)
+			}
+		}
+		s.Properties[name] = *p
+	}
+}
+
+// nullable returns the schema of the type which accepts null when the Go
+// type does, as the YAML configuration does.
+func (g *generator) nullable(t reflect.Type, stack []reflect.Type) *apiextensionsv1.JSONSchemaProps {
+	s := g.schema(t, stack)
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		s.Nullable = true
+	}
+	return s
+}
+
+// parseDocs returns the doc comments of the types and struct fields declared
+// in the package directory.
+func parseDocs(dir string) (map[string]string, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := map[string]string{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.TYPE {
+					continue
+				}
+
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					doc := ts.Doc
+					if doc == nil && len(gd.Specs) == 1 {
+						doc = gd.Doc
+					}
+					if doc != nil {
+						docs[ts.Name.Name] = normalize(doc.Text())
+					}
+
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+					for _, field := range st.Fields.List {
+						doc := field.Doc
+						if doc == nil {
+							doc = field.Comment
+						}
+						if doc == nil {
+							continue
+						}
+						for _, n := range field.Names {
+							docs[ts.Name.Name+"."+n.Name] = normalize(doc.Text())
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return docs, nil
+}
+
+func normalize(doc string) string {
+	return strings.Join(strings.Fields(doc), " ")
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -167,6 +167,21 @@ function(params) {
         resources: ['apiservices'],
         verbs: ['create', 'get', 'list', 'watch', 'update', 'delete'],
       },
+      {
+        apiGroups: ['console.openshift.io'],
+        resources: ['consolenotifications'],
+        verbs: ['create', 'get', 'list', 'watch', 'update', 'delete'],
+      },
+      {
+        apiGroups: ['monitoring.openshift.io'],
+        resources: ['clustermonitorings', 'userworkloadmonitorings'],
+        verbs: ['get', 'list', 'watch'],
+      },
+      {
+        apiGroups: ['monitoring.openshift.io'],
+        resources: ['clustermonitorings/status'],
+        verbs: ['update'],
+      },
       {
         apiGroups: ['config.openshift.io'],
         resources: ['clusterversions'],
@@ -218,6 +233,14 @@ function(params) {
         resources: ['persistentvolumes'],
         verbs: ['get'],
       },
+      // The operator reports the ServiceMonitors and PodMonitors exceeding
+      // the target quota of their namespace with events in the user
+      // namespaces.
+      {
+        apiGroups: [''],
+        resources: ['events'],
+        verbs: ['create', 'patch'],
+      },
     ],
   },
 
@@ -355,11 +378,19 @@ function(params) {
       name: 'user-workload-monitoring-config-edit',
       namespace: cfg.namespaceUserWorkload,
     },
-    rules: [{
-      apiGroups: [''],
-      resourceNames: ['user-workload-monitoring-config'],
-      resources: ['configmaps'],
-      verbs: ['*'],
-    }],
+    rules: [
+      {
+        apiGroups: [''],
+        resourceNames: ['user-workload-monitoring-config'],
+        resources: ['configmaps'],
+        verbs: ['*'],
+      },
+      {
+        apiGroups: ['monitoring.openshift.io'],
+        resourceNames: ['user-workload'],
+        resources: ['userworkloadmonitorings'],
+        verbs: ['*'],
+      },
+    ],
   },
 }
@@ -46,6 +46,92 @@ function(params)
       },
     },
 
+    // The hosted variants scrape the etcd and kube-apiserver pods when the
+    // control plane runs in a management namespace instead of static pods.
+    // The namespace selector is set by the operator at runtime.
+    serviceMonitorEtcdHosted: {
+      apiVersion: 'monitoring.coreos.com/v1',
+      kind: 'ServiceMonitor',
+      metadata: {
+        name: 'etcd-hosted',
+        namespace: cfg.namespace,
+        labels: {
+          'app.kubernetes.io/name': 'etcd',
+          'k8s-app': 'etcd',
+        },
+      },
+      spec: {
+        endpoints: [
+          {
+            port: 'metrics',
+            interval: '30s',
+            scheme: 'https',
+            tlsConfig: {
+              serverName: 'etcd-client',
+              ca: { secret: { name: 'hosted-control-plane-metrics-client-certs', key: 'ca.crt' } },
+              cert: { secret: { name: 'hosted-control-plane-metrics-client-certs', key: 'tls.crt' } },
+              keySecret: { name: 'hosted-control-plane-metrics-client-certs', key: 'tls.key' },
+            },
+            relabelings: [
+              {
+                targetLabel: 'job',
+                replacement: 'etcd',
+              },
+            ],
+          },
+        ],
+        selector: {
+          matchLabels: {
+            app: 'etcd',
+          },
+        },
+        namespaceSelector: {
+          matchNames: [],
+        },
+      },
+    },
+
+    serviceMonitorKubeApiserverHosted: {
+      apiVersion: 'monitoring.coreos.com/v1',
+      kind: 'ServiceMonitor',
+      metadata: {
+        name: 'kube-apiserver-hosted',
+        namespace: cfg.namespace,
+        labels: {
+          'app.kubernetes.io/name': 'kube-apiserver',
+        },
+      },
+      spec: {
+        endpoints: [
+          {
+            port: 'client',
+            interval: '30s',
+            scheme: 'https',
+            tlsConfig: {
+              serverName: 'kube-apiserver',
+              ca: { secret: { name: 'hosted-control-plane-metrics-client-certs', key: 'ca.crt' } },
+              cert: { secret: { name: 'hosted-control-plane-metrics-client-certs', key: 'tls.crt' } },
+              keySecret: { name: 'hosted-control-plane-metrics-client-certs', key: 'tls.key' },
+            },
+            relabelings: [
+              {
+                targetLabel: 'job',
+                replacement: 'apiserver',
+              },
+            ],
+          },
+        ],
+        selector: {
+          matchLabels: {
+            app: 'kube-apiserver',
+          },
+        },
+        namespaceSelector: {
+          matchNames: [],
+        },
+      },
+    },
+
     // This changes the kubelet's certificates to be validated when
     // scraping.
     serviceMonitorKubelet+: {
@@ -0,0 +1,233 @@
+function(params) {
+  local cfg = params,
+  local labels = {
+    'app.kubernetes.io/component': 'metrics-server',
+    'app.kubernetes.io/name': 'metrics-server',
+    'app.kubernetes.io/version': cfg.version,
+  } + cfg.commonLabels,
+  local selectorLabels = {
+    [k]: labels[k]
+    for k in std.objectFields(labels)
+    if !std.setMember(k, ['app.kubernetes.io/version'])
+  },
+  local metadata = {
+    name: cfg.name,
+    namespace: cfg.namespace,
+    labels: labels,
+  },
+
+  serviceAccount: {
+    apiVersion: 'v1',
+    kind: 'ServiceAccount',
+    metadata: metadata,
+  },
+
+  clusterRole: {
+    apiVersion: 'rbac.authorization.k8s.io/v1',
+    kind: 'ClusterRole',
+    metadata: { name: 'system:' + cfg.name, labels: labels },
+    rules: [
+      {
+        apiGroups: [''],
+        resources: ['pods', 'nodes'],
+        verbs: ['get', 'list', 'watch'],
+      },
+      {
+        apiGroups: [''],
+        resources: ['nodes/metrics'],
+        verbs: ['get'],
+      },
+    ],
+  },
+
+  clusterRoleBinding: {
+    apiVersion: 'rbac.authorization.k8s.io/v1',
+    kind: 'ClusterRoleBinding',
+    metadata: { name: 'system:' + cfg.name, labels: labels },
+    roleRef: {
+      apiGroup: 'rbac.authorization.k8s.io',
+      kind: 'ClusterRole',
+      name: 'system:' + cfg.name,
+    },
+    subjects: [{
+      kind: 'ServiceAccount',
+      name: cfg.name,
+      namespace: cfg.namespace,
+    }],
+  },
+
+  clusterRoleBindingAuthDelegator: {
+    apiVersion: 'rbac.authorization.k8s.io/v1',
+    kind: 'ClusterRoleBinding',
+    metadata: { name: cfg.name + ':system:auth-delegator', labels: labels },
+    roleRef: {
+      apiGroup: 'rbac.authorization.k8s.io',
+      kind: 'ClusterRole',
+      name: 'system:auth-delegator',
+    },
+    subjects: [{
+      kind: 'ServiceAccount',
+      name: cfg.name,
+      namespace: cfg.namespace,
+    }],
+  },
+
+  roleBindingAuthReader: {
+    apiVersion: 'rbac.authorization.k8s.io/v1',
+    kind: 'RoleBinding',
+    metadata: {
+      name: cfg.name + '-auth-reader',
+      namespace: 'kube-system',
+      labels: labels,
+    },
+    roleRef: {
+      apiGroup: 'rbac.authorization.k8s.io',
+      kind: 'Role',
+      name: 'extension-apiserver-authentication-reader',
+    },
+    subjects: [{
+      kind: 'ServiceAccount',
+      name: cfg.name,
+      namespace: cfg.namespace,
+    }],
+  },
+
+  service: {
+    apiVersion: 'v1',
+    kind: 'Service',
+    metadata: metadata {
+      annotations: {
+        'service.beta.openshift.io/serving-cert-secret-name': cfg.name + '-tls',
+      },
+    },
+    spec: {
+      ports: [{ name: 'https', port: 443, targetPort: 'https' }],
+      selector: selectorLabels,
+      type: 'ClusterIP',
+    },
+  },
+
+  deployment: {
+    apiVersion: 'apps/v1',
+    kind: 'Deployment',
+    metadata: metadata,
+    spec: {
+      replicas: 2,
+      selector: { matchLabels: selectorLabels },
+      strategy: { rollingUpdate: { maxUnavailable: 1 } },
+      template: {
+        metadata: { labels: labels },
+        spec: {
+          affinity: {
+            podAntiAffinity: {
+              requiredDuringSchedulingIgnoredDuringExecution: [{
+                labelSelector: { matchLabels: selectorLabels },
+                namespaces: [cfg.namespace],
+                topologyKey: 'kubernetes.io/hostname',
+              }],
+            },
+          },
+          containers: [{
+            name: cfg.name,
+            image: cfg.image,
+            args: [
+              '--secure-port=10250',
+              '--cert-dir=/tmp',
+              '--tls-cert-file=/etc/tls/private/tls.crt',
+              '--tls-private-key-file=/etc/tls/private/tls.key',
+              '--tls-cipher-suites=' + cfg.tlsCipherSuites,
+              '--kubelet-certificate-authority=/etc/tls/kubelet-serving-ca-bundle/ca-bundle.crt',
+              '--kubelet-preferred-address-types=InternalIP',
+              '--kubelet-use-node-status-port',
+              '--metric-resolution=15s',
+            ],
+            ports: [{ containerPort: 10250, name: 'https' }],
+            readinessProbe: {
+              httpGet: { path: '/readyz', port: 'https', scheme: 'HTTPS' },
+              initialDelaySeconds: 20,
+              periodSeconds: 10,
+            },
+            livenessProbe: {
+              httpGet: { path: '/livez', port: 'https', scheme: 'HTTPS' },
+              periodSeconds: 10,
+            },
+            resources: { requests: { cpu: '1m', memory: '40Mi' } },
+            terminationMessagePolicy: 'FallbackToLogsOnError',
+            volumeMounts: [
+              { mountPath: '/tmp', name: 'tmpfs' },
+              { mountPath: '/etc/tls/private', name: 'secret-' + cfg.name + '-tls', readOnly: true },
+              { mountPath: '/etc/tls/kubelet-serving-ca-bundle', name: 'kubelet-serving-ca-bundle', readOnly: true },
+            ],
+          }],
+          nodeSelector: { 'kubernetes.io/os': 'linux' },
+          priorityClassName: 'system-cluster-critical',
+          serviceAccountName: cfg.name,
+          volumes: [
+            { name: 'tmpfs', emptyDir: {} },
+            { name: 'secret-' + cfg.name + '-tls', secret: { secretName: cfg.name + '-tls' } },
+            { name: 'kubelet-serving-ca-bundle', configMap: { name: 'kubelet-serving-ca-bundle' } },
+          ],
+        },
+      },
+    },
+  },
+
+  podDisruptionBudget: {
+    apiVersion: 'policy/v1',
+    kind: 'PodDisruptionBudget',
+    metadata: metadata,
+    spec: {
+      minAvailable: 1,
+      selector: { matchLabels: selectorLabels },
+    },
+  },
+
+  serviceMonitor: {
+    apiVersion: 'monitoring.coreos.com/v1',
+    kind: 'ServiceMonitor',
+    metadata: metadata,
+    spec: {
+      endpoints: [{
+        bearerTokenFile: '/var/run/secrets/kubernetes.io/serviceaccount/token',
+        interval: '30s',
+        metricRelabelings: [{
+          action: 'drop',
+          regex: '(apiserver_client_certificate_.*|apiserver_envelope_.*|apiserver_flowcontrol_.*|apiserver_storage_.*|apiserver_webhooks_.*|workqueue_.*)',
+          sourceLabels: ['__name__'],
+        }],
+        port: 'https',
+        scheme: 'https',
+        tlsConfig: {
+          caFile: '/etc/prometheus/configmaps/serving-certs-ca-bundle/service-ca.crt',
+          serverName: 'server-name-replaced-at-runtime',
+        },
+      }],
+      selector: { matchLabels: selectorLabels },
+    },
+  },
+
+  // Replaces the APIService of prometheus-adapter when metrics-server is
+  // enabled.
+  apiService: {
+    apiVersion: 'apiregistration.k8s.io/v1',
+    kind: 'APIService',
+    metadata: {
+      name: 'v1beta1.metrics.k8s.io',
+      labels: labels,
+      annotations: {
+        'service.alpha.openshift.io/inject-cabundle': 'true',
+      },
+    },
+    spec: {
+      group: 'metrics.k8s.io',
+      groupPriorityMinimum: 100,
+      insecureSkipTLSVerify: false,
+      service: {
+        name: cfg.name,
+        namespace: cfg.namespace,
+      },
+      version: 'v1beta1',
+      versionPriority: 100,
+    },
+  },
+}
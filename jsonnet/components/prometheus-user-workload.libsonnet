@@ -60,6 +60,11 @@ function(params)
             port: 10902,
             targetPort: 'thanos-proxy',
           },
+          {
+            name: 'federate',
+            port: 9092,
+            targetPort: 'federate',
+          },
         ],
         type: 'ClusterIP',
       },
@@ -177,6 +182,38 @@ function(params)
 
     kubeRbacProxySecret: generateSecret.staticAuthSecret(cfg.namespace, cfg.commonLabels, 'kube-rbac-proxy'),
 
+    // The /federate endpoint is scoped to the namespace given in the
+    // namespace query parameter. The request bearer token must be allowed to
+    // get pods metrics in this namespace and prom-label-proxy enforces the
+    // namespace label on the match[] selectors.
+    kubeRbacProxyFederateSecret: {
+      apiVersion: 'v1',
+      kind: 'Secret',
+      metadata: {
+        name: 'kube-rbac-proxy-federate',
+        namespace: cfg.namespace,
+        labels: cfg.commonLabels,
+      },
+      type: 'Opaque',
+      data: {},
+      stringData: {
+        'config.yaml': std.manifestYamlDoc({
+          authorization: {
+            rewrites: {
+              byQueryParameter: {
+                name: 'namespace',
+              },
+            },
+            resourceAttributes: {
+              apiVersion: 'metrics.k8s.io/v1beta1',
+              resource: 'pods',
+              namespace: '{{ .Value }}',
+            },
+          },
+        }),
+      },
+    },
+
     prometheus+: {
       spec+: {
         overrideHonorTimestamps: true,
@@ -226,6 +263,7 @@ function(params)
           'prometheus-user-workload-tls',
           'prometheus-user-workload-thanos-sidecar-tls',
           $.kubeRbacProxySecret.metadata.name,
+          $.kubeRbacProxyFederateSecret.metadata.name,
         ],
         configMaps: ['serving-certs-ca-bundle', 'metrics-client-ca'],
         probeNamespaceSelector: cfg.namespaceSelector,
@@ -329,6 +367,60 @@ function(params)
               },
             ],
           },
+          {
+            name: 'kube-rbac-proxy-federate',
+            image: cfg.kubeRbacProxyImage,
+            resources: {
+              requests: {
+                memory: '10Mi',
+                cpu: '1m',
+              },
+            },
+            ports: [
+              {
+                containerPort: 9092,
+                name: 'federate',
+              },
+            ],
+            args: [
+              '--secure-listen-address=0.0.0.0:9092',
+              '--upstream=http://127.0.0.1:9095',
+              '--allow-paths=/federate',
+              '--config-file=/etc/kube-rbac-proxy/config.yaml',
+              '--tls-cert-file=/etc/tls/private/tls.crt',
+              '--tls-private-key-file=/etc/tls/private/tls.key',
+              '--tls-cipher-suites=' + cfg.tlsCipherSuites,
+              '--logtostderr=true',
+            ],
+            terminationMessagePolicy: 'FallbackToLogsOnError',
+            volumeMounts: [
+              {
+                mountPath: '/etc/tls/private',
+                name: 'secret-prometheus-user-workload-tls',
+              },
+              {
+                mountPath: '/etc/kube-rbac-proxy',
+                name: 'secret-' + $.kubeRbacProxyFederateSecret.metadata.name,
+              },
+            ],
+          },
+          {
+            name: 'prom-label-proxy',
+            image: cfg.promLabelProxyImage,
+            args: [
+              '--insecure-listen-address=127.0.0.1:9095',
+              '--upstream=http://127.0.0.1:9090',
+              '--label=namespace',
+              '--error-on-replace',
+            ],
+            resources: {
+              requests: {
+                memory: '15Mi',
+                cpu: '1m',
+              },
+            },
+            terminationMessagePolicy: 'FallbackToLogsOnError',
+          },
           {
             name: 'thanos-sidecar',
             args: [
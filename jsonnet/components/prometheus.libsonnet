@@ -10,6 +10,74 @@ function(params)
   prometheus(cfg) + {
     trustedCaBundle: generateCertInjection.trustedCNOCaBundleCM(cfg.namespace, 'prometheus-trusted-ca-bundle'),
 
+    // Additional alerts for the limits enforced on user workloads, for
+    // remote write which retries samples from the WAL until they are
+    // truncated (every 2h) and for slow WAL replays delaying the restarts.
+    prometheusRule+: {
+      spec+: {
+        groups: std.map(
+          function(g)
+            if g.name == 'prometheus' then
+              g {
+                rules+: [
+                  {
+                    alert: 'PrometheusScrapeSampleLimitHit',
+                    expr: |||
+                      increase(prometheus_target_scrapes_exceeded_sample_limit_total{%(prometheusSelector)s}[5m]) > 0
+                    ||| % cfg.mixin._config,
+                    'for': '15m',
+                    labels: {
+                      severity: 'warning',
+                    },
+                    annotations: {
+                      description: 'Prometheus {{$labels.namespace}}/{{$labels.pod}} has failed {{ printf "%.0f" $value }} scrapes in the last 5m because some targets exceeded the configured sample_limit.',
+                      summary: 'Prometheus has failed scrapes that have exceeded the configured sample limit.',
+                    },
+                  },
+                  {
+                    alert: 'PrometheusRemoteWriteDataLossImminent',
+                    expr: |||
+                      (
+                        max_over_time(prometheus_remote_storage_highest_timestamp_in_seconds{%(prometheusSelector)s}[5m])
+                      - ignoring(remote_name, url) group_right
+                        max_over_time(prometheus_remote_storage_queue_highest_sent_timestamp_seconds{%(prometheusSelector)s}[5m])
+                      ) > 3600
+                      and on (namespace, pod, remote_name, url)
+                        rate(prometheus_remote_storage_samples_retried_total{%(prometheusSelector)s}[5m]) > 0
+                    ||| % cfg.mixin._config,
+                    'for': '15m',
+                    labels: {
+                      severity: 'warning',
+                    },
+                    annotations: {
+                      description: 'Prometheus {{$labels.namespace}}/{{$labels.pod}} has been retrying to send samples to {{ $labels.remote_name}}:{{ $labels.url }} for a long time and is {{ printf "%.0f" $value }}s behind. Samples will be lost when they are removed from the write-ahead log. Check the availability of the remote endpoint and the retryOnRateLimit, maxBackoff and maxShards queue settings.',
+                      summary: 'Prometheus remote write is close to dropping samples.',
+                    },
+                  },
+                  {
+                    alert: 'PrometheusSlowWALReplay',
+                    expr: |||
+                      prometheus_tsdb_data_replay_duration_seconds{%(prometheusSelector)s} > 600
+                      and on (namespace, pod)
+                        (time() - process_start_time_seconds{%(prometheusSelector)s}) < 3600
+                    ||| % cfg.mixin._config,
+                    labels: {
+                      severity: 'warning',
+                    },
+                    annotations: {
+                      description: 'Prometheus {{$labels.namespace}}/{{$labels.pod}} took {{ humanizeDuration $value }} to replay its write-ahead log when it started. Enabling the compression of the write-ahead log and the memory snapshot on shutdown or reducing the number of series shortens the restarts.',
+                      summary: 'Prometheus took a long time to replay its write-ahead log.',
+                    },
+                  },
+                ],
+              }
+            else
+              g,
+          super.groups,
+        ),
+      },
+    },
+
     grpcTlsSecret: {
       apiVersion: 'v1',
       kind: 'Secret',
@@ -209,6 +277,13 @@ function(params)
             action: 'replace',
             replacement: 'platform',
           },
+          // Dark launched alerts are evaluated but never sent to
+          // Alertmanager.
+          {
+            source_labels: ['openshift_io_alert_dark_launch'],
+            regex: 'true',
+            action: 'drop',
+          },
         ],),
       },
     },
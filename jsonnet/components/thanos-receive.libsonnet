@@ -0,0 +1,288 @@
+local generateSecret = import '../utils/generate-secret.libsonnet';
+
+function(params) {
+  local cfg = params,
+  local labels = {
+    'app.kubernetes.io/component': 'database-write-hashring',
+    'app.kubernetes.io/instance': cfg.name,
+    'app.kubernetes.io/name': 'thanos-receive',
+    'app.kubernetes.io/version': cfg.version,
+  } + cfg.commonLabels,
+  local selectorLabels = {
+    [k]: labels[k]
+    for k in std.objectFields(labels)
+    if !std.setMember(k, ['app.kubernetes.io/version'])
+  },
+  local metadata = {
+    name: cfg.name,
+    namespace: cfg.namespace,
+    labels: labels,
+  },
+  local kubeRbacProxy(name, port, portName, upstream, allowPaths, secretVolume, extraArgs, extraMounts) = {
+    name: name,
+    image: cfg.kubeRbacProxyImage,
+    args: [
+      '--secure-listen-address=0.0.0.0:' + port,
+      '--upstream=http://127.0.0.1:' + upstream,
+      '--config-file=/etc/kube-rbac-proxy/config.yaml',
+      '--tls-cert-file=/etc/tls/private/tls.crt',
+      '--tls-private-key-file=/etc/tls/private/tls.key',
+      '--tls-cipher-suites=' + std.join(',', cfg.tlsCipherSuites),
+    ] + extraArgs + [
+      '--logtostderr=true',
+      '--allow-paths=' + allowPaths,
+    ],
+    ports: [{ containerPort: port, name: portName }],
+    resources: { requests: { cpu: '1m', memory: '15Mi' } },
+    terminationMessagePolicy: 'FallbackToLogsOnError',
+    volumeMounts: [
+      { mountPath: '/etc/tls/private', name: 'secret-thanos-receive-tls' },
+      { mountPath: '/etc/kube-rbac-proxy', name: secretVolume },
+    ] + extraMounts,
+  },
+
+  serviceAccount: {
+    apiVersion: 'v1',
+    kind: 'ServiceAccount',
+    metadata: metadata,
+  },
+
+  clusterRole: {
+    apiVersion: 'rbac.authorization.k8s.io/v1',
+    kind: 'ClusterRole',
+    metadata: { name: cfg.name, labels: labels },
+    rules: [
+      {
+        apiGroups: ['authentication.k8s.io'],
+        resources: ['tokenreviews'],
+        verbs: ['create'],
+      },
+      {
+        apiGroups: ['authorization.k8s.io'],
+        resources: ['subjectaccessreviews'],
+        verbs: ['create'],
+      },
+    ],
+  },
+
+  clusterRoleBinding: {
+    apiVersion: 'rbac.authorization.k8s.io/v1',
+    kind: 'ClusterRoleBinding',
+    metadata: { name: cfg.name, labels: labels },
+    roleRef: {
+      apiGroup: 'rbac.authorization.k8s.io',
+      kind: 'ClusterRole',
+      name: cfg.name,
+    },
+    subjects: [{
+      kind: 'ServiceAccount',
+      name: cfg.name,
+      namespace: cfg.namespace,
+    }],
+  },
+
+  // Bound by the cluster administrators to the identities allowed to push
+  // metrics through the remote write endpoint.
+  remoteWriterClusterRole: {
+    apiVersion: 'rbac.authorization.k8s.io/v1',
+    kind: 'ClusterRole',
+    metadata: { name: cfg.name + '-remote-writer', labels: labels },
+    rules: [{
+      apiGroups: ['monitoring.openshift.io'],
+      resources: ['remotewrite'],
+      verbs: ['create'],
+    }],
+  },
+
+  kubeRbacProxySecret: {
+    apiVersion: 'v1',
+    kind: 'Secret',
+    metadata: metadata { name: cfg.name + '-kube-rbac-proxy' },
+    type: 'Opaque',
+    data: {},
+    stringData: {
+      'config.yaml': std.manifestYamlDoc({
+        authorization: {
+          resourceAttributes: {
+            apiGroup: 'monitoring.openshift.io',
+            namespace: cfg.namespace,
+            resource: 'remotewrite',
+          },
+        },
+      }),
+    },
+  },
+
+  kubeRbacProxyMetricsSecret: generateSecret.staticAuthSecret(cfg.namespace, labels, cfg.name + '-kube-rbac-proxy-metrics'),
+
+  grpcTlsSecret: {
+    apiVersion: 'v1',
+    kind: 'Secret',
+    metadata: metadata { name: cfg.name + '-grpc-tls' },
+    type: 'Opaque',
+    data: {},
+  },
+
+  // The hashrings are generated by the operator from the configuration.
+  hashringsConfig: {
+    apiVersion: 'v1',
+    kind: 'ConfigMap',
+    metadata: metadata { name: cfg.name + '-hashrings' },
+    data: { 'hashrings.json': '[]' },
+  },
+
+  service: {
+    apiVersion: 'v1',
+    kind: 'Service',
+    metadata: metadata {
+      annotations: {
+        'service.beta.openshift.io/serving-cert-secret-name': cfg.name + '-tls',
+      },
+    },
+    spec: {
+      clusterIP: 'None',
+      publishNotReadyAddresses: true,
+      ports: [
+        { name: 'grpc', port: 10901, targetPort: 'grpc' },
+        { name: 'remote-write', port: 19291, targetPort: 'remote-write' },
+        { name: 'metrics', port: 10903, targetPort: 'metrics' },
+      ],
+      selector: selectorLabels,
+    },
+  },
+
+  route: {
+    apiVersion: 'v1',
+    kind: 'Route',
+    metadata: metadata,
+    spec: {
+      path: '/api/v1/receive',
+      to: {
+        kind: 'Service',
+        name: cfg.name,
+      },
+      port: {
+        targetPort: 'remote-write',
+      },
+      tls: {
+        termination: 'Reencrypt',
+        insecureEdgeTerminationPolicy: 'Redirect',
+      },
+    },
+  },
+
+  serviceMonitor: {
+    apiVersion: 'monitoring.coreos.com/v1',
+    kind: 'ServiceMonitor',
+    metadata: metadata,
+    spec: {
+      selector: { matchLabels: selectorLabels },
+      endpoints: [{
+        port: 'metrics',
+        interval: '30s',
+        scheme: 'https',
+        tlsConfig: {
+          caFile: '/etc/prometheus/configmaps/serving-certs-ca-bundle/service-ca.crt',
+          serverName: 'server-name-replaced-at-runtime',
+          certFile: '/etc/prometheus/secrets/metrics-client-certs/tls.crt',
+          keyFile: '/etc/prometheus/secrets/metrics-client-certs/tls.key',
+        },
+      }],
+    },
+  },
+
+  statefulSet: {
+    apiVersion: 'apps/v1',
+    kind: 'StatefulSet',
+    metadata: metadata {
+      labels+: { 'app.kubernetes.io/managed-by': 'cluster-monitoring-operator' },
+    },
+    spec: {
+      replicas: 1,
+      serviceName: cfg.name,
+      podManagementPolicy: 'Parallel',
+      selector: { matchLabels: selectorLabels },
+      template: {
+        metadata: {
+          annotations: {
+            'target.workload.openshift.io/management': '{"effect": "PreferredDuringScheduling"}',
+          },
+          labels: labels { 'app.kubernetes.io/managed-by': 'cluster-monitoring-operator' },
+        },
+        spec: {
+          affinity: {
+            podAntiAffinity: {
+              preferredDuringSchedulingIgnoredDuringExecution: [{
+                weight: 100,
+                podAffinityTerm: {
+                  labelSelector: { matchLabels: selectorLabels },
+                  topologyKey: 'kubernetes.io/hostname',
+                },
+              }],
+            },
+          },
+          containers: [
+            {
+              name: 'thanos-receive',
+              image: cfg.image,
+              args: [
+                'receive',
+                '--grpc-address=0.0.0.0:10901',
+                '--http-address=127.0.0.1:10902',
+                '--remote-write.address=127.0.0.1:19291',
+                '--log.format=logfmt',
+                '--tsdb.path=/var/thanos/receive',
+                '--label=thanos_receive_replica="$(NAME)"',
+                '--receive.local-endpoint=$(NAME).%s.%s.svc.cluster.local:10901' % [cfg.name, cfg.namespace],
+                '--receive.hashrings-file=/etc/thanos/hashrings/hashrings.json',
+                '--receive.tenant-label-name=tenant_id',
+                '--grpc-server-tls-cert=/etc/tls/grpc/server.crt',
+                '--grpc-server-tls-key=/etc/tls/grpc/server.key',
+                '--grpc-server-tls-client-ca=/etc/tls/grpc/ca.crt',
+                '--remote-write.client-tls-cert=/etc/tls/grpc/client.crt',
+                '--remote-write.client-tls-key=/etc/tls/grpc/client.key',
+                '--remote-write.client-tls-ca=/etc/tls/grpc/ca.crt',
+                '--remote-write.client-server-name=prometheus-grpc',
+              ],
+              env: [{
+                name: 'NAME',
+                valueFrom: { fieldRef: { fieldPath: 'metadata.name' } },
+              }],
+              ports: [{ containerPort: 10901, name: 'grpc' }],
+              resources: { requests: { cpu: '10m', memory: '128Mi' } },
+              terminationMessagePolicy: 'FallbackToLogsOnError',
+              volumeMounts: [
+                { mountPath: '/var/thanos/receive', name: 'thanos-receive-data' },
+                { mountPath: '/etc/thanos/hashrings', name: 'thanos-receive-hashrings' },
+                { mountPath: '/etc/tls/grpc', name: 'secret-grpc-tls' },
+              ],
+            },
+            kubeRbacProxy('kube-rbac-proxy', 19292, 'remote-write', 19291, '/api/v1/receive', 'secret-thanos-receive-kube-rbac-proxy', [], []),
+            kubeRbacProxy(
+              'kube-rbac-proxy-metrics',
+              10903,
+              'metrics',
+              10902,
+              '/metrics',
+              'secret-thanos-receive-kube-rbac-proxy-metrics',
+              ['--client-ca-file=/etc/tls/client/client-ca.crt'],
+              [{ mountPath: '/etc/tls/client', name: 'metrics-client-ca', readOnly: true }],
+            ),
+          ],
+          nodeSelector: { 'kubernetes.io/os': 'linux' },
+          priorityClassName: 'system-cluster-critical',
+          serviceAccountName: cfg.name,
+          terminationGracePeriodSeconds: 120,
+          volumes: [
+            { name: 'thanos-receive-data', emptyDir: {} },
+            { name: 'thanos-receive-hashrings', configMap: { name: cfg.name + '-hashrings' } },
+            { name: 'secret-thanos-receive-tls', secret: { secretName: cfg.name + '-tls' } },
+            { name: 'secret-thanos-receive-kube-rbac-proxy', secret: { secretName: cfg.name + '-kube-rbac-proxy' } },
+            { name: 'secret-thanos-receive-kube-rbac-proxy-metrics', secret: { secretName: cfg.name + '-kube-rbac-proxy-metrics' } },
+            { name: 'metrics-client-ca', configMap: { name: 'metrics-client-ca' } },
+          ],
+        },
+      },
+    },
+  },
+}
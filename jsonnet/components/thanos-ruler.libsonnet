@@ -304,7 +304,7 @@ function(params)
       metadata: {
         name: cfg.crName,
         namespace: tr.config.namespace,
-        labels: {
+        labels: cfg.commonLabels {
           thanosRulerName: cfg.crName,
         },
       },
@@ -343,6 +343,45 @@ function(params) {
             severity: 'warning',
           },
         },
+        {
+          expr: 'max by (component) (cluster_monitoring_operator_storage_class_drift) > 0',
+          alert: 'ClusterMonitoringOperatorStorageClassDrift',
+          'for': '1h',
+          annotations: {
+            summary: 'Persistent volumes of the monitoring stack use an unexpected storage class.',
+            description: '{{ $value }} persistent volume claims of {{ $labels.component }} use another storage class than the configured or default one. Resizing or migrating these volumes may fail, inspect the StorageClassDrift condition of the monitoring ClusterOperator for details.',
+          },
+          labels: {
+            severity: 'info',
+            namespace: 'openshift-monitoring',
+          },
+        },
+        {
+          expr: 'min by (certificate) (cluster_monitoring_operator_grpc_tls_certificate_expiration_timestamp_seconds) - time() < 30 * 24 * 3600',
+          alert: 'ClusterMonitoringOperatorGRPCCertificateExpiring',
+          'for': '1h',
+          annotations: {
+            summary: 'A certificate used by the Thanos components to communicate over GRPC is about to expire.',
+            description: 'The {{ $labels.certificate }} certificate of the openshift-monitoring/grpc-tls secret expires in {{ $value | humanizeDuration }} although it should have been renewed. Once it expires, Thanos Querier can no longer query Prometheus and Thanos Ruler. Inspect the cluster-monitoring-operator log for rotation errors.',
+          },
+          labels: {
+            severity: 'warning',
+            namespace: 'openshift-monitoring',
+          },
+        },
+        {
+          expr: 'max by (phase) (cluster_monitoring_operator_grpc_tls_rotation_in_progress) == 1',
+          alert: 'ClusterMonitoringOperatorGRPCCARotationStuck',
+          'for': '24h',
+          annotations: {
+            summary: 'The replacement of the CA used by the Thanos components to communicate over GRPC is not progressing.',
+            description: 'The replacement of the GRPC TLS CA has been in the {{ $labels.phase }} phase for more than 24 hours. Inspect the cluster-monitoring-operator log for reconciliation errors.',
+          },
+          labels: {
+            severity: 'warning',
+            namespace: 'openshift-monitoring',
+          },
+        },
         {
           expr: 'cluster:alertmanager_integrations:max == 0',
           alert: 'AlertmanagerReceiversNotConfigured',
@@ -538,6 +577,69 @@ function(params) {
         },
       ],
     },
+    {
+      // The rule files of the user workload monitoring stack are named after
+      // the namespace, name and UID of the PrometheusRule. The UID maps the
+      // rule evaluation metrics to the namespace of the PrometheusRule since
+      // both the namespace and the name may contain dashes.
+      name: 'openshift-user-workload-rules.rules',
+      rules: [
+        {
+          expr: |||
+            sum by (namespace, job) (
+              label_replace(
+                label_replace(
+                  rate(prometheus_rule_evaluation_failures_total{namespace="openshift-user-workload-monitoring", job=~"prometheus-user-workload|thanos-ruler"}[5m]),
+                  "uid", "$1", "rule_group", ".*-([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\\.yaml;.*"
+                )
+                * on (uid) group_left (rule_namespace) max by (uid, rule_namespace) (cluster_monitoring_operator_prometheus_rule_info),
+                "namespace", "$1", "rule_namespace", "(.*)"
+              )
+            )
+          |||,
+          record: 'namespace_job:user_rule_evaluation_failures:rate5m',
+        },
+        {
+          expr: |||
+            sum by (namespace, job) (
+              label_replace(
+                label_replace(
+                  rate(prometheus_rule_group_iterations_missed_total{namespace="openshift-user-workload-monitoring", job=~"prometheus-user-workload|thanos-ruler"}[5m]),
+                  "uid", "$1", "rule_group", ".*-([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\\.yaml;.*"
+                )
+                * on (uid) group_left (rule_namespace) max by (uid, rule_namespace) (cluster_monitoring_operator_prometheus_rule_info),
+                "namespace", "$1", "rule_namespace", "(.*)"
+              )
+            )
+          |||,
+          record: 'namespace_job:user_rule_group_iterations_missed:rate5m',
+        },
+        {
+          expr: 'namespace_job:user_rule_evaluation_failures:rate5m > 0',
+          alert: 'UserRuleEvaluationFailing',
+          'for': '15m',
+          annotations: {
+            description: '{{ $labels.job }} fails to evaluate the rules of the {{ $labels.namespace }} namespace. Check the rules of the PrometheusRule objects of the namespace.',
+            summary: 'Rules of a user-defined project fail to evaluate.',
+          },
+          labels: {
+            severity: 'warning',
+          },
+        },
+        {
+          expr: 'namespace_job:user_rule_group_iterations_missed:rate5m > 0',
+          alert: 'UserRuleGroupIterationsMissed',
+          'for': '15m',
+          annotations: {
+            description: '{{ $labels.job }} misses evaluations of rule groups of the {{ $labels.namespace }} namespace because they take longer than their evaluation interval. Simplify the rules or increase the interval of the rule groups.',
+            summary: 'Rule groups of a user-defined project are too slow.',
+          },
+          labels: {
+            severity: 'warning',
+          },
+        },
+      ],
+    },
     {
       name: 'openshift-etcd-telemetry.rules',
       rules: [
@@ -581,5 +683,70 @@ function(params) {
         },
       ],
     },
+    {
+      // The skew between the clock of a node and the clock of Prometheus is
+      // the difference between the node time exposed by node_exporter and
+      // the scrape timestamp set by Prometheus. A skewed clock shifts the
+      // samples of the node which breaks the 'for' durations of the alerts
+      // and the deduplication of the samples by Thanos Querier.
+      name: 'openshift-clock-skew.rules',
+      rules: [
+        {
+          expr: 'node_time_seconds{job="node-exporter"} - timestamp(node_time_seconds{job="node-exporter"})',
+          record: 'instance:node_time_skew_seconds:offset',
+        },
+        {
+          expr: 'max(abs(instance:node_time_skew_seconds:offset))',
+          record: 'cluster:node_time_skew_seconds:max_abs',
+        },
+        {
+          expr: 'abs(instance:node_time_skew_seconds:offset) > 1',
+          alert: 'NodeClockSkewedFromPrometheus',
+          'for': '15m',
+          annotations: {
+            description: 'The clock of {{ $labels.instance }} is {{ $value | humanizeDuration }} apart from the clock of Prometheus. The samples of the node are shifted in time which delays or prevents the alerts with a "for" duration and breaks the deduplication of the samples. Check the time synchronization of the node.',
+            summary: 'The clock of a node is skewed from the clock of Prometheus.',
+          },
+          labels: {
+            namespace: 'openshift-monitoring',
+            severity: 'warning',
+          },
+        },
+      ],
+    },
+    {
+      // The query canary of the operator runs representative queries
+      // against Thanos Querier when enabled. These alerts catch the
+      // regressions of the query path before users notice them.
+      name: 'openshift-query-canary.rules',
+      rules: [
+        {
+          expr: 'sum by (query) (rate(cluster_monitoring_operator_query_canary_failures_total[10m])) / sum by (query) (rate(cluster_monitoring_operator_query_canary_queries_total[10m])) > 0.5',
+          alert: 'ThanosQueryCanaryFailing',
+          'for': '15m',
+          annotations: {
+            description: '{{ $value | humanizePercentage }} of the {{ $labels.query }} canary queries against Thanos Querier failed or returned no data in the last 10 minutes. Dashboards, alerts evaluated by Thanos Ruler and the web console may be affected. Inspect the logs of the Thanos Querier pods.',
+            summary: 'The queries of the query canary against Thanos Querier are failing.',
+          },
+          labels: {
+            namespace: 'openshift-monitoring',
+            severity: 'warning',
+          },
+        },
+        {
+          expr: 'histogram_quantile(0.9, sum by (query, le) (rate(cluster_monitoring_operator_query_canary_duration_seconds_bucket[10m]))) > 10',
+          alert: 'ThanosQueryCanarySlow',
+          'for': '15m',
+          annotations: {
+            description: 'The 90th percentile latency of the {{ $labels.query }} canary queries against Thanos Querier is {{ $value | humanizeDuration }}. Inspect the resource usage of the Thanos Querier and Prometheus pods.',
+            summary: 'The queries of the query canary against Thanos Querier are slow.',
+          },
+          labels: {
+            namespace: 'openshift-monitoring',
+            severity: 'warning',
+          },
+        },
+      ],
+    },
   ],
 }
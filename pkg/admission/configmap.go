@@ -0,0 +1,117 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package admission implements the validating admission webhook which
+// rejects invalid configuration ConfigMaps when they are written instead of
+// letting the operator go degraded on the next reconciliation.
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// maxRequestBytes bounds the size of the admission reviews. It is large
+// enough for any ConfigMap accepted by the API server.
+const maxRequestBytes = 3 << 20
+
+// ConfigMapValidator validates the ConfigMap with the given namespace and
+// name.
+type ConfigMapValidator struct {
+	Namespace string
+	Name      string
+	Validate  func(context.Context, *v1.ConfigMap) error
+}
+
+// Handler serves the admission reviews of ConfigMaps. The ConfigMaps which
+// don't match any validator are always allowed.
+type Handler struct {
+	validators []ConfigMapValidator
+}
+
+// NewHandler returns a handler validating the ConfigMaps with the given
+// validators.
+func NewHandler(validators ...ConfigMapValidator) *Handler {
+	return &Handler{validators: validators}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("invalid admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "invalid admission review: missing request", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = h.review(r.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&review); err != nil {
+		klog.Warningf("failed to write the admission review response: %v", err)
+	}
+}
+
+func (h *Handler) review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Kind.Group != "" || req.Kind.Kind != "ConfigMap" {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	for _, v := range h.validators {
+		if req.Namespace != v.Namespace || req.Name != v.Name {
+			continue
+		}
+
+		var cm v1.ConfigMap
+		if err := json.Unmarshal(req.Object.Raw, &cm); err != nil {
+			return denied(http.StatusBadRequest, metav1.StatusReasonBadRequest, fmt.Sprintf("failed to decode the ConfigMap: %v", err))
+		}
+
+		if err := v.Validate(ctx, &cm); err != nil {
+			klog.V(4).Infof("Rejecting the %s/%s ConfigMap: %v", req.Namespace, req.Name, err)
+			return denied(http.StatusUnprocessableEntity, metav1.StatusReasonInvalid, fmt.Sprintf("the %s/%s ConfigMap is invalid: %v", req.Namespace, req.Name, err))
+		}
+		break
+	}
+
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func denied(code int32, reason metav1.StatusReason, message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Reason:  reason,
+			Message: message,
+		},
+	}
+}
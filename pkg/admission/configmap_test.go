@@ -0,0 +1,158 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestHandler(t *testing.T) {
+	h := NewHandler(ConfigMapValidator{
+		Namespace: "openshift-monitoring",
+		Name:      "cluster-monitoring-config",
+		Validate: func(_ context.Context, cm *v1.ConfigMap) error {
+			if cm.Data["config.yaml"] != "valid" {
+				return errors.New("invalid config")
+			}
+			return nil
+		},
+	})
+
+	for _, tc := range []struct {
+		name      string
+		kind      metav1.GroupVersionKind
+		namespace string
+		configMap string
+		content   string
+
+		allowed bool
+	}{
+		{
+			name:      "valid",
+			kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			namespace: "openshift-monitoring",
+			configMap: "cluster-monitoring-config",
+			content:   "valid",
+			allowed:   true,
+		},
+		{
+			name:      "invalid",
+			kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			namespace: "openshift-monitoring",
+			configMap: "cluster-monitoring-config",
+			content:   "invalid",
+		},
+		{
+			name:      "other ConfigMap",
+			kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			namespace: "openshift-monitoring",
+			configMap: "other",
+			content:   "invalid",
+			allowed:   true,
+		},
+		{
+			name:      "other namespace",
+			kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			namespace: "default",
+			configMap: "cluster-monitoring-config",
+			content:   "invalid",
+			allowed:   true,
+		},
+		{
+			name:      "other kind",
+			kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+			namespace: "openshift-monitoring",
+			configMap: "cluster-monitoring-config",
+			content:   "invalid",
+			allowed:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			raw, err := json.Marshal(&v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: tc.configMap, Namespace: tc.namespace},
+				Data:       map[string]string{"config.yaml": tc.content},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := json.Marshal(&admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       types.UID("1234"),
+					Kind:      tc.kind,
+					Namespace: tc.namespace,
+					Name:      tc.configMap,
+					Operation: admissionv1.Update,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+
+			var review admissionv1.AdmissionReview
+			if err := json.Unmarshal(rec.Body.Bytes(), &review); err != nil {
+				t.Fatal(err)
+			}
+			if review.Response == nil {
+				t.Fatal("expected a response")
+			}
+			if review.Response.UID != "1234" {
+				t.Fatalf("expected UID %q, got %q", "1234", review.Response.UID)
+			}
+			if review.Response.Allowed != tc.allowed {
+				t.Fatalf("expected allowed to be %v, got %v", tc.allowed, review.Response.Allowed)
+			}
+			if !tc.allowed && (review.Response.Result == nil || review.Response.Result.Reason != metav1.StatusReasonInvalid) {
+				t.Fatalf("expected reason %q, got %v", metav1.StatusReasonInvalid, review.Response.Result)
+			}
+		})
+	}
+}
+
+func TestHandlerInvalidRequest(t *testing.T) {
+	h := NewHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader([]byte("{}"))))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/validate", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status code %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alertmanager inspects Alertmanager configurations for common
+// mistakes which can lead to alerts being silently dropped or merged.
+package alertmanager
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	severityLabel = "severity"
+	// groupByAll is the special group_by value aggregating by all labels.
+	groupByAll = "..."
+)
+
+// config is the subset of the Alertmanager configuration needed by the
+// analyzer.
+type config struct {
+	Route     *route     `yaml:"route"`
+	Receivers []receiver `yaml:"receivers"`
+}
+
+type receiver struct {
+	Name string `yaml:"name"`
+}
+
+type route struct {
+	Receiver string            `yaml:"receiver"`
+	GroupBy  []string          `yaml:"group_by"`
+	Match    map[string]string `yaml:"match"`
+	MatchRE  map[string]string `yaml:"match_re"`
+	Matchers []string          `yaml:"matchers"`
+	Continue bool              `yaml:"continue"`
+	Routes   []*route          `yaml:"routes"`
+}
+
+func (r *route) isCatchAll() bool {
+	return len(r.Match) == 0 && len(r.MatchRE) == 0 && len(r.Matchers) == 0
+}
+
+func groupsBySeverity(groupBy []string) bool {
+	for _, l := range groupBy {
+		if l == severityLabel || l == groupByAll {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze parses the given Alertmanager configuration and returns a sorted
+// list of warnings. It reports:
+//   - catch-all routes shadowing the sibling routes defined after them,
+//   - receivers which aren't referenced by any route,
+//   - routes overriding group_by without the severity label while their
+//     parent groups by severity.
+func Analyze(b []byte) ([]string, error) {
+	var c config
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, errors.Wrap(err, "parsing Alertmanager configuration failed")
+	}
+
+	if c.Route == nil {
+		return []string{"the configuration has no top-level route"}, nil
+	}
+
+	var (
+		warnings []string
+		used     = map[string]struct{}{}
+	)
+
+	var walk func(r *route, path string, parentGroupBy []string)
+	walk = func(r *route, path string, parentGroupBy []string) {
+		if r.Receiver != "" {
+			used[r.Receiver] = struct{}{}
+		}
+
+		groupBy := parentGroupBy
+		if r.GroupBy != nil {
+			groupBy = r.GroupBy
+			if groupsBySeverity(parentGroupBy) && !groupsBySeverity(groupBy) {
+				warnings = append(warnings, fmt.Sprintf("route %s drops the %q label from group_by: alerts of different severities will be grouped together", path, severityLabel))
+			}
+		}
+
+		for i, child := range r.Routes {
+			childPath := fmt.Sprintf("%s.routes[%d]", path, i)
+			if child.isCatchAll() && !child.Continue && i < len(r.Routes)-1 {
+				warnings = append(warnings, fmt.Sprintf("route %s matches all alerts without continue: the %d sibling route(s) defined after it are never evaluated", childPath, len(r.Routes)-1-i))
+			}
+			walk(child, childPath, groupBy)
+		}
+	}
+	walk(c.Route, "route", nil)
+
+	for _, rcv := range c.Receivers {
+		if _, ok := used[rcv.Name]; !ok {
+			warnings = append(warnings, fmt.Sprintf("receiver %q isn't referenced by any route", rcv.Name))
+		}
+	}
+
+	sort.Strings(warnings)
+	return warnings, nil
+}
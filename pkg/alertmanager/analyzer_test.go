@@ -0,0 +1,161 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected []string
+		err      bool
+	}{
+		{
+			name: "default configuration",
+			config: `
+receivers:
+- name: Default
+- name: Watchdog
+- name: Critical
+route:
+  group_by: [namespace]
+  receiver: Default
+  routes:
+  - matchers: ["alertname = Watchdog"]
+    receiver: Watchdog
+  - matchers: ["severity = critical"]
+    receiver: Critical
+`,
+		},
+		{
+			name: "catch-all route before specific routes",
+			config: `
+receivers:
+- name: Default
+- name: Critical
+route:
+  receiver: Default
+  routes:
+  - receiver: Default
+  - match:
+      severity: critical
+    receiver: Critical
+`,
+			expected: []string{
+				"route route.routes[0] matches all alerts without continue: the 1 sibling route(s) defined after it are never evaluated",
+			},
+		},
+		{
+			name: "catch-all route with continue",
+			config: `
+receivers:
+- name: Default
+- name: Critical
+route:
+  receiver: Default
+  routes:
+  - receiver: Default
+    continue: true
+  - match:
+      severity: critical
+    receiver: Critical
+`,
+		},
+		{
+			name: "unused receiver",
+			config: `
+receivers:
+- name: Default
+- name: Pager
+route:
+  receiver: Default
+`,
+			expected: []string{
+				`receiver "Pager" isn't referenced by any route`,
+			},
+		},
+		{
+			name: "group_by dropping severity",
+			config: `
+receivers:
+- name: Default
+- name: Team
+route:
+  receiver: Default
+  group_by: [namespace, severity]
+  routes:
+  - match_re:
+      namespace: team-.*
+    group_by: [namespace]
+    receiver: Team
+`,
+			expected: []string{
+				`route route.routes[0] drops the "severity" label from group_by: alerts of different severities will be grouped together`,
+			},
+		},
+		{
+			name: "group_by all labels",
+			config: `
+receivers:
+- name: Default
+- name: Team
+route:
+  receiver: Default
+  group_by: [severity]
+  routes:
+  - match_re:
+      namespace: team-.*
+    group_by: ["..."]
+    receiver: Team
+`,
+		},
+		{
+			name: "no route",
+			config: `
+receivers:
+- name: Default
+`,
+			expected: []string{
+				"the configuration has no top-level route",
+			},
+		},
+		{
+			name:   "invalid configuration",
+			config: `route: [`,
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Analyze([]byte(tc.config))
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
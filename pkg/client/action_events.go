@@ -0,0 +1,112 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ActionEventRecorder sets the recorder reporting the objects created,
+// updated and deleted by the client. Contrary to the object events, the
+// events are emitted in the operator namespace so that all the actions of the
+// operator can be followed from a single place.
+func ActionEventRecorder(r events.Recorder) Option {
+	return func(c *Client) {
+		c.actionRecorder = r
+	}
+}
+
+// SetActionEventRecorder sets the recorder reporting the objects created,
+// updated and deleted by the client. It is meant for recorders which can only
+// be built once the client exists.
+func (c *Client) SetActionEventRecorder(r events.Recorder) {
+	c.actionRecorder = r
+}
+
+// RecordFailure emits a warning event in the operator namespace.
+func (c *Client) RecordFailure(reason string, err error) {
+	if c.actionRecorder == nil {
+		return
+	}
+	c.actionRecorder.Warning(reason, err.Error())
+}
+
+// recordCreated reports an object created by the client. Nothing is reported
+// when the creation failed.
+func (c *Client) recordCreated(ctx context.Context, kind string, obj metav1.Object, err error) {
+	if err != nil {
+		return
+	}
+	c.recordAction(ctx, kind, "Created", obj, "because it was missing")
+}
+
+// recordUpdated reports an object updated by the client. Updates which
+// didn't modify the object (e.g. the API server didn't bump the resource
+// version) and failed updates aren't reported.
+func (c *Client) recordUpdated(ctx context.Context, kind string, existing, updated metav1.Object, err error) {
+	if err != nil || existing.GetResourceVersion() == updated.GetResourceVersion() {
+		return
+	}
+	c.recordAction(ctx, kind, "Updated", updated, "because it changed")
+}
+
+// recordDeleted reports an object deleted by the client. Nothing is reported
+// when the deletion failed, including when the object didn't exist.
+func (c *Client) recordDeleted(ctx context.Context, kind string, obj metav1.Object, err error) {
+	if err != nil {
+		return
+	}
+	c.recordAction(ctx, kind, "Deleted", obj, "because it isn't needed anymore")
+}
+
+// recordConflict reports the fields of an object which were modified by
+// another field manager and whether their ownership is taken back by the
+// client.
+func (c *Client) recordConflict(kind string, obj metav1.Object, forced bool, err error) {
+	if c.actionRecorder == nil {
+		return
+	}
+
+	ref := fmt.Sprintf("%s/%s", kind, obj.GetName())
+	if ns := obj.GetNamespace(); ns != "" {
+		ref = fmt.Sprintf("%s -n %s", ref, ns)
+	}
+	if !forced {
+		c.actionRecorder.Warningf(kind+"ApplyConflict", "Not applying %s, fields are owned by another manager: %v", ref, err)
+		return
+	}
+	c.actionRecorder.Warningf(kind+"ApplyConflict", "Forcing the ownership of the fields of %s modified by another manager: %v", ref, err)
+}
+
+func (c *Client) recordAction(ctx context.Context, kind, action string, obj metav1.Object, why string) {
+	if c.actionRecorder == nil {
+		return
+	}
+
+	ref := fmt.Sprintf("%s/%s", kind, obj.GetName())
+	if ns := obj.GetNamespace(); ns != "" {
+		ref = fmt.Sprintf("%s -n %s", ref, ns)
+	}
+
+	msg := fmt.Sprintf("%s %s %s", action, ref, why)
+	if hash := configHashFromContext(ctx); hash != "" {
+		msg = fmt.Sprintf("%s (config hash %s)", msg, hash)
+	}
+	c.actionRecorder.Event(kind+action, msg)
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func expectActionEvents(t *testing.T, recorder events.InMemoryRecorder, expected ...string) {
+	t.Helper()
+
+	var got []string
+	for _, e := range recorder.Events() {
+		got = append(got, e.Type+" "+e.Reason+" "+e.Message)
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("expected events %q, got %q", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected events %q, got %q", expected, got)
+		}
+	}
+}
+
+func TestCreateAndDeleteRecordActionEvents(t *testing.T) {
+	ctx := WithConfigHash(context.Background(), "abc")
+	recorder := events.NewInMemoryRecorder("test")
+	c := Client{
+		kclient:        fakeKube(),
+		actionRecorder: recorder,
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: ns,
+		},
+	}
+	if err := c.CreateOrUpdateConfigMap(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	// The fake clientset doesn't bump the resource version, the update is
+	// seen as a no-op.
+	if err := c.CreateOrUpdateConfigMap(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteConfigMap(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	// The object doesn't exist anymore.
+	if err := c.DeleteConfigMap(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+
+	expectActionEvents(t, recorder,
+		"Normal ConfigMapCreated Created ConfigMap/foo -n "+ns+" because it was missing (config hash abc)",
+		"Normal ConfigMapDeleted Deleted ConfigMap/foo -n "+ns+" because it isn't needed anymore (config hash abc)",
+	)
+}
+
+func TestRecordUpdated(t *testing.T) {
+	existing := &metav1.ObjectMeta{Name: "foo", ResourceVersion: "1"}
+
+	for _, tc := range []struct {
+		name    string
+		updated *metav1.ObjectMeta
+		err     error
+		events  []string
+	}{
+		{
+			name:    "changed",
+			updated: &metav1.ObjectMeta{Name: "foo", ResourceVersion: "2"},
+			events:  []string{"Normal ClusterRoleUpdated Updated ClusterRole/foo because it changed"},
+		},
+		{
+			name:    "unchanged",
+			updated: &metav1.ObjectMeta{Name: "foo", ResourceVersion: "1"},
+		},
+		{
+			name:    "failed",
+			updated: &metav1.ObjectMeta{},
+			err:     errors.New("conflict"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := events.NewInMemoryRecorder("test")
+			c := Client{actionRecorder: recorder}
+
+			c.recordUpdated(context.Background(), "ClusterRole", existing, tc.updated, tc.err)
+			expectActionEvents(t, recorder, tc.events...)
+		})
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	recorder := events.NewInMemoryRecorder("test")
+	c := Client{actionRecorder: recorder}
+
+	c.RecordFailure("UpdatingPrometheusK8SFailed", errors.New("timeout"))
+	expectActionEvents(t, recorder, "Warning UpdatingPrometheusK8SFailed timeout")
+
+	// The recorder is optional.
+	(&Client{}).RecordFailure("UpdatingPrometheusK8SFailed", errors.New("timeout"))
+}
@@ -0,0 +1,445 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// FieldManager is the name of the field manager used by the operator to
+// apply the objects it manages. The fields owned by other managers (e.g. the
+// CA bundles injected by the service CA operator) are left untouched.
+const FieldManager = "cluster-monitoring-operator"
+
+// object is implemented by all the objects applied by the client.
+type object interface {
+	metav1.Object
+	runtime.Object
+}
+
+// patchFunc sends a patch of the given type to the API server. It is
+// implemented by a closure over the Patch() method of the typed client which
+// keeps the returned object.
+type patchFunc func(pt types.PatchType, data []byte, opts metav1.PatchOptions) error
+
+// preservedFieldManager owns the labels and annotations set by other actors
+// which the operator carries over to the objects it recreates (e.g. a
+// ClusterRoleBinding whose role reference changed). A separate manager keeps
+// the next apply from pruning them.
+const preservedFieldManager = FieldManager + "-preserved"
+
+// legacyFieldManagers are the field managers which owned the fields of the
+// objects when the operator was updating them instead of applying them.
+var legacyFieldManagers = map[string]struct{}{
+	"operator":   {},
+	FieldManager: {},
+}
+
+// conflictManagerRe extracts the name of the manager from the conflicts
+// reported by the API server.
+var conflictManagerRe = regexp.MustCompile(`conflicts? with "([^"]*)"`)
+
+// apply applies the required object with server-side apply. When the object
+// exists, the fields owned by the legacy field managers are migrated to the
+// operator's apply manager so that the fields removed from the required
+// object get pruned. The labels and annotations with the operator's prefix
+// which aren't required anymore are removed too since they may have been set
+// before the operator switched to server-side apply.
+//
+// The managed fields migration is rejected when the object was modified since
+// it was read, the error is then reported as a conflict which the callers may
+// retry (see isResourceVersionConflict).
+//
+// A conflict means that another manager changed fields set by the operator.
+// The ownership is forced back only when the fields were modified by hand
+// (e.g. with kubectl) or by a previous version of the operator. Conflicts
+// with other controllers (e.g. an autoscaler changing the replicas) aren't
+// forced: they are reported with a warning event and an error.
+//
+// Existing objects with the unmanaged annotation aren't modified, their drift
+// from the required object is recorded instead and no request is sent. The
+// callers keep the existing object as the applied one.
+func (c *Client) apply(ctx context.Context, gvk schema.GroupVersionKind, required object, existing metav1.Object, patch patchFunc) error {
+	if existing != nil && isUnmanaged(existing) {
+		return c.audit(ctx, gvk, required, existing)
+	}
+
+	if existing != nil {
+		data, err := managedFieldsMigrationPatch(existing)
+		if err != nil {
+			return errors.Wrapf(err, "migrating the managed fields of %s object failed", gvk.Kind)
+		}
+		if data != nil {
+			if err := patch(types.JSONPatchType, data, metav1.PatchOptions{}); err != nil {
+				if apierrors.IsInvalid(err) && strings.Contains(err.Error(), "/metadata/resourceVersion") {
+					err = apierrors.NewConflict(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, existing.GetName(), err)
+				}
+				return errors.Wrapf(err, "migrating the managed fields of %s object failed", gvk.Kind)
+			}
+		}
+
+		if data := staleMetadataPatch(required, existing); data != nil {
+			if err := patch(types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager}); err != nil {
+				return errors.Wrapf(err, "removing stale metadata of %s object failed", gvk.Kind)
+			}
+		}
+	}
+
+	data, err := applyPatch(required, gvk)
+	if err != nil {
+		return errors.Wrapf(err, "serializing %s object failed", gvk.Kind)
+	}
+
+	opts := metav1.PatchOptions{FieldManager: FieldManager}
+	err = patch(types.ApplyPatchType, data, opts)
+	if !apierrors.IsConflict(err) {
+		return err
+	}
+
+	force := forceable(err)
+	klog.Warningf("Conflict applying %s %s (forced: %t): %v", gvk.Kind, objectRef(required), force, err)
+	c.recordConflict(gvk.Kind, required, force, err)
+	if !force {
+		return errors.Wrapf(err, "applying %s object failed", gvk.Kind)
+	}
+
+	opts.Force = &force
+	return patch(types.ApplyPatchType, data, opts)
+}
+
+// removeStaleData removes the stale keys from the data of the existing object
+// before it is applied, see staleDataPatch. Unmanaged objects are left
+// untouched.
+func (c *Client) removeStaleData(gvk schema.GroupVersionKind, existing metav1.Object, keys []string, patch patchFunc, stale ...string) error {
+	if isUnmanaged(existing) {
+		return nil
+	}
+
+	data := staleDataPatch(keys, stale...)
+	if data == nil {
+		return nil
+	}
+	return errors.Wrapf(patch(types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager}), "removing stale data of %s object failed", gvk.Kind)
+}
+
+// isResourceVersionConflict returns whether the error is a conflict caused by
+// a concurrent modification of the object rather than by fields owned by
+// another manager. Retrying the former with the fresh object is safe while
+// the latter needs an action of the other manager.
+func isResourceVersionConflict(err error) bool {
+	if !apierrors.IsConflict(err) {
+		return false
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Details != nil {
+		for _, cause := range status.Status().Details.Causes {
+			if cause.Type == metav1.CauseTypeFieldManagerConflict {
+				return false
+			}
+		}
+	}
+	return !conflictManagerRe.MatchString(err.Error())
+}
+
+// forceable returns whether the ownership of the conflicting fields can be
+// forced to the operator. This is the case when all the conflicting managers
+// are kubectl/oc commands or legacy managers of the operator.
+func forceable(err error) bool {
+	var messages []string
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Details != nil {
+		for _, cause := range status.Status().Details.Causes {
+			if cause.Type == metav1.CauseTypeFieldManagerConflict {
+				messages = append(messages, cause.Message)
+			}
+		}
+	}
+	if len(messages) == 0 {
+		messages = []string{err.Error()}
+	}
+
+	var managers int
+	for _, msg := range messages {
+		for _, m := range conflictManagerRe.FindAllStringSubmatch(msg, -1) {
+			managers++
+			if _, found := legacyFieldManagers[m[1]]; found {
+				continue
+			}
+			if isCLIManager(m[1]) {
+				continue
+			}
+			return false
+		}
+	}
+
+	return managers > 0
+}
+
+// isCLIManager returns whether the field manager is a kubectl or oc command.
+func isCLIManager(manager string) bool {
+	for _, cli := range []string{"kubectl", "oc"} {
+		if manager == cli || strings.HasPrefix(manager, cli+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// managedFieldsMigrationPatch returns the JSON patch moving the fields owned
+// by the legacy field managers with the Update operation to the operator's
+// apply manager. Without it, the fields removed from the required object would
+// never be pruned since they would still be owned by the legacy managers. It
+// returns nil when there's nothing to migrate.
+func managedFieldsMigrationPatch(existing metav1.Object) ([]byte, error) {
+	var (
+		entries []metav1.ManagedFieldsEntry
+		legacy  []metav1.ManagedFieldsEntry
+		applied = map[string]int{}
+	)
+	for _, e := range existing.GetManagedFields() {
+		if _, found := legacyFieldManagers[e.Manager]; found && e.Operation == metav1.ManagedFieldsOperationUpdate && e.Subresource == "" {
+			legacy = append(legacy, e)
+			continue
+		}
+		if e.Manager == FieldManager && e.Operation == metav1.ManagedFieldsOperationApply && e.Subresource == "" {
+			applied[e.APIVersion] = len(entries)
+		}
+		entries = append(entries, e)
+	}
+	if len(legacy) == 0 {
+		return nil, nil
+	}
+
+	for _, e := range legacy {
+		if e.FieldsV1 == nil {
+			continue
+		}
+
+		i, found := applied[e.APIVersion]
+		if !found {
+			i = len(entries)
+			applied[e.APIVersion] = i
+			entries = append(entries, metav1.ManagedFieldsEntry{
+				Manager:    FieldManager,
+				Operation:  metav1.ManagedFieldsOperationApply,
+				APIVersion: e.APIVersion,
+				Time:       e.Time,
+				FieldsType: e.FieldsType,
+			})
+		}
+
+		var fields, current map[string]interface{}
+		if err := json.Unmarshal(e.FieldsV1.Raw, &fields); err != nil {
+			return nil, err
+		}
+		if entries[i].FieldsV1 != nil {
+			if err := json.Unmarshal(entries[i].FieldsV1.Raw, &current); err != nil {
+				return nil, err
+			}
+		}
+		raw, err := json.Marshal(unionFields(current, fields))
+		if err != nil {
+			return nil, err
+		}
+		entries[i].FieldsV1 = &metav1.FieldsV1{Raw: raw}
+	}
+	if len(entries) == 0 {
+		// Replacing the managed fields with an empty list would reset them.
+		return nil, nil
+	}
+
+	return json.Marshal([]map[string]interface{}{
+		{"op": "test", "path": "/metadata/resourceVersion", "value": existing.GetResourceVersion()},
+		{"op": "replace", "path": "/metadata/managedFields", "value": entries},
+	})
+}
+
+// unionFields merges the field sets serialized in the FieldsV1 format.
+func unionFields(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+	for k, sv := range src {
+		sm, ok := sv.(map[string]interface{})
+		if !ok {
+			dst[k] = sv
+			continue
+		}
+		dm, _ := dst[k].(map[string]interface{})
+		dst[k] = unionFields(dm, sm)
+	}
+	return dst
+}
+
+// applyPatch returns the apply configuration of the object. The status and
+// the metadata fields managed by the API server are dropped so that the
+// operator doesn't take their ownership.
+func applyPatch(obj runtime.Object, gvk schema.GroupVersionKind) ([]byte, error) {
+	u, err := applyConfiguration(obj, gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(u)
+}
+
+func applyConfiguration(obj runtime.Object, gvk schema.GroupVersionKind) (map[string]interface{}, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(u, "status")
+	for _, f := range []string{"resourceVersion", "uid", "creationTimestamp", "generation", "managedFields", "selfLink"} {
+		unstructured.RemoveNestedField(u, "metadata", f)
+	}
+	u["apiVersion"], u["kind"] = gvk.ToAPIVersionAndKind()
+
+	return u, nil
+}
+
+// staleMetadataPatch returns the JSON merge patch removing the labels and
+// annotations of the existing object which have the operator's prefix and
+// aren't required anymore. It returns nil when there's nothing to remove.
+func staleMetadataPatch(required, existing metav1.Object) []byte {
+	stale := func(required, existing map[string]string) map[string]interface{} {
+		m := map[string]interface{}{}
+		for k := range existing {
+			if _, found := required[k]; !found && strings.HasPrefix(k, metadataPrefix) {
+				m[k] = nil
+			}
+		}
+		return m
+	}
+
+	metadata := map[string]interface{}{}
+	if labels := stale(required.GetLabels(), existing.GetLabels()); len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if annotations := stale(required.GetAnnotations(), existing.GetAnnotations()); len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// mergeMetadata merges labels and annotations from `existing` map into `required` one where `required` has precedence
+// over `existing` keys and values. Additionally function performs filtering of labels and annotations from `exiting` map
+// where keys starting from string defined in `metadataPrefix` are skipped. This prevents issues with preserving stale
+// metadata defined by the operator
+func mergeMetadata(required *metav1.ObjectMeta, existing metav1.ObjectMeta) {
+	merge := func(dst *map[string]string, src map[string]string) {
+		for k, v := range src {
+			if strings.HasPrefix(k, metadataPrefix) {
+				continue
+			}
+			if _, found := (*dst)[k]; found {
+				continue
+			}
+			if *dst == nil {
+				*dst = map[string]string{}
+			}
+			(*dst)[k] = v
+		}
+	}
+
+	merge(&required.Annotations, existing.Annotations)
+	merge(&required.Labels, existing.Labels)
+}
+
+// preservedMetadataPatch returns the JSON merge patch setting the labels and
+// annotations of the existing object which aren't required nor set by the
+// operator. It returns nil when there's nothing to preserve.
+func preservedMetadataPatch(required, existing metav1.Object) []byte {
+	var merged metav1.ObjectMeta
+	mergeMetadata(&merged, metav1.ObjectMeta{Labels: existing.GetLabels(), Annotations: existing.GetAnnotations()})
+
+	preserved := func(merged, required map[string]string) map[string]interface{} {
+		m := map[string]interface{}{}
+		for k, v := range merged {
+			if _, found := required[k]; !found {
+				m[k] = v
+			}
+		}
+		return m
+	}
+
+	metadata := map[string]interface{}{}
+	if labels := preserved(merged.Labels, required.GetLabels()); len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if annotations := preserved(merged.Annotations, required.GetAnnotations()); len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// staleDataPatch returns the JSON merge patch removing the stale keys from the
+// data of an object whose existing keys are given. These keys are owned by
+// another manager (e.g. the service CA operator) hence applying the object
+// doesn't remove them. It returns nil when none of the stale keys exist.
+func staleDataPatch(existing []string, stale ...string) []byte {
+	remove := map[string]interface{}{}
+	for _, k := range stale {
+		for _, e := range existing {
+			if e == k {
+				remove[k] = nil
+			}
+		}
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"data": remove})
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func objectRef(obj metav1.Object) string {
+	if ns := obj.GetNamespace(); ns != "" {
+		return fmt.Sprintf("%s/%s", ns, obj.GetName())
+	}
+	return obj.GetName()
+}
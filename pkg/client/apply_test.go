@@ -0,0 +1,569 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	ossfake "github.com/openshift/client-go/security/clientset/versioned/fake"
+	ossscheme "github.com/openshift/client-go/security/clientset/versioned/scheme"
+	"github.com/openshift/library-go/pkg/operator/events"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monfake "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned/fake"
+	monscheme "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned/scheme"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// applyEmulator emulates server-side apply for the fake clientsets which
+// don't support it. The applied configuration is merged into the existing
+// object: the fields set by others are kept, the fields applied previously
+// and missing from the new configuration are removed. Lists of named items
+// are merged by name, other lists are replaced.
+type applyEmulator struct {
+	tracker clienttesting.ObjectTracker
+	scheme  *runtime.Scheme
+	applied map[string]map[string]interface{}
+}
+
+func emulateApply(f *clienttesting.Fake, tracker clienttesting.ObjectTracker, scheme *runtime.Scheme) {
+	e := &applyEmulator{
+		tracker: tracker,
+		scheme:  scheme,
+		applied: map[string]map[string]interface{}{},
+	}
+	f.PrependReactor("patch", "*", e.react)
+}
+
+func fakeKube(objects ...runtime.Object) *fake.Clientset {
+	c := fake.NewSimpleClientset(objects...)
+	emulateApply(&c.Fake, c.Tracker(), kscheme.Scheme)
+	return c
+}
+
+func fakeMonitoring(objects ...runtime.Object) *monfake.Clientset {
+	c := monfake.NewSimpleClientset(objects...)
+	emulateApply(&c.Fake, c.Tracker(), monscheme.Scheme)
+	return c
+}
+
+func fakeSecurity(objects ...runtime.Object) *ossfake.Clientset {
+	c := ossfake.NewSimpleClientset(objects...)
+	emulateApply(&c.Fake, c.Tracker(), ossscheme.Scheme)
+	return c
+}
+
+func (e *applyEmulator) react(action clienttesting.Action) (bool, runtime.Object, error) {
+	pa, ok := action.(clienttesting.PatchAction)
+	if !ok || pa.GetPatchType() != types.ApplyPatchType {
+		return false, nil, nil
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(pa.GetPatch(), &patch); err != nil {
+		return true, nil, apierrors.NewBadRequest(err.Error())
+	}
+	gvk := (&unstructured.Unstructured{Object: patch}).GroupVersionKind()
+	gvr, ns, name := pa.GetResource(), pa.GetNamespace(), pa.GetName()
+	key := strings.Join([]string{gvr.String(), ns, name}, "/")
+
+	current := map[string]interface{}{}
+	existing, err := e.tracker.Get(gvr, ns, name)
+	switch {
+	case apierrors.IsNotFound(err):
+		existing = nil
+		delete(e.applied, key)
+	case err != nil:
+		return true, nil, err
+	default:
+		if err := roundTrip(existing, &current); err != nil {
+			return true, nil, err
+		}
+	}
+
+	removeFields(current, e.applied[key], patch)
+	mergeFields(current, patch)
+	e.applied[key] = patch
+
+	obj, err := e.scheme.New(gvk)
+	if err != nil {
+		return true, nil, err
+	}
+	if err := roundTrip(current, obj); err != nil {
+		return true, nil, err
+	}
+
+	if existing == nil {
+		err = e.tracker.Create(gvr, obj, ns)
+	} else {
+		err = e.tracker.Update(gvr, obj, ns)
+	}
+	return true, obj, err
+}
+
+func roundTrip(in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// removeFields removes from dst the fields of prev which aren't in next.
+func removeFields(dst, prev, next map[string]interface{}) {
+	for k, pv := range prev {
+		nv, found := next[k]
+		if !found {
+			delete(dst, k)
+			continue
+		}
+
+		pm, ok1 := pv.(map[string]interface{})
+		nm, ok2 := nv.(map[string]interface{})
+		dm, ok3 := dst[k].(map[string]interface{})
+		if ok1 && ok2 && ok3 {
+			removeFields(dm, pm, nm)
+		}
+	}
+}
+
+func mergeFields(dst, src map[string]interface{}) {
+	for k, sv := range src {
+		switch sv := sv.(type) {
+		case map[string]interface{}:
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				mergeFields(dm, sv)
+				continue
+			}
+		case []interface{}:
+			if dl, ok := dst[k].([]interface{}); ok {
+				dst[k] = mergeNamedItems(dl, sv)
+				continue
+			}
+		}
+		dst[k] = src[k]
+	}
+}
+
+func mergeNamedItems(dst, src []interface{}) []interface{} {
+	named := map[interface{}]map[string]interface{}{}
+	for _, item := range dst {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return src
+		}
+		named[m["name"]] = m
+	}
+
+	merged := make([]interface{}, 0, len(src))
+	for _, item := range src {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return src
+		}
+		if d, found := named[m["name"]]; found {
+			mergeFields(d, m)
+			m = d
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+func TestApplyPatch(t *testing.T) {
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "kube-state-metrics",
+			Namespace:         ns,
+			ResourceVersion:   "42",
+			UID:               "1234",
+			Generation:        3,
+			CreationTimestamp: metav1.Now(),
+		},
+		Status: appsv1.DeploymentStatus{Replicas: 1},
+	}
+
+	data, err := applyPatch(dep, appsv1.SchemeGroupVersion.WithKind("Deployment"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["apiVersion"] != "apps/v1" || got["kind"] != "Deployment" {
+		t.Fatalf("expected apps/v1 Deployment, got %v %v", got["apiVersion"], got["kind"])
+	}
+	if _, found := got["status"]; found {
+		t.Fatal("expected the status to be dropped")
+	}
+	metadata := got["metadata"].(map[string]interface{})
+	for _, f := range []string{"resourceVersion", "uid", "generation", "creationTimestamp"} {
+		if _, found := metadata[f]; found {
+			t.Fatalf("expected metadata.%s to be dropped, got %v", f, metadata[f])
+		}
+	}
+	if metadata["name"] != dep.Name || metadata["namespace"] != dep.Namespace {
+		t.Fatalf("expected the name and namespace to be kept, got %v", metadata)
+	}
+}
+
+func TestApplyKeepsFieldsOfOtherManagers(t *testing.T) {
+	ctx := context.Background()
+	c := Client{kclient: fakeKube()}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: ns,
+		},
+		Data: map[string]string{
+			"config.yaml": "foo",
+			"extra.yaml":  "foo",
+		},
+	}
+	if err := c.CreateOrUpdateConfigMap(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another controller adds data and labels.
+	current, err := c.kclient.CoreV1().ConfigMaps(ns).Get(ctx, cm.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current.Labels = map[string]string{"injected": "true"}
+	current.Data["injected"] = "bar"
+	if _, err := c.kclient.CoreV1().ConfigMaps(ns).Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	required := cm.DeepCopy()
+	required.Data = map[string]string{"config.yaml": "bar"}
+	if err := c.CreateOrUpdateConfigMap(ctx, required); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := c.kclient.CoreV1().ConfigMaps(ns).Get(ctx, cm.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"config.yaml": "bar",
+		"injected":    "bar",
+	}
+	if len(after.Data) != len(expected) {
+		t.Fatalf("expected data %q, got %q", expected, after.Data)
+	}
+	for k, v := range expected {
+		if after.Data[k] != v {
+			t.Fatalf("expected data %q, got %q", expected, after.Data)
+		}
+	}
+	if after.Labels["injected"] != "true" {
+		t.Fatalf("expected the injected label to be kept, got %q", after.Labels)
+	}
+}
+
+func TestApplyForcesConflicts(t *testing.T) {
+	ctx := context.Background()
+	prometheus := &monv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8s",
+			Namespace: ns,
+		},
+	}
+
+	mclient := fakeMonitoring(prometheus.DeepCopy())
+	// Simulate a field owned by the operator and modified by another
+	// manager.
+	var applies int
+	mclient.PrependReactor("patch", "prometheuses", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.(clienttesting.PatchAction).GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		applies++
+		if applies > 1 {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewConflict(monv1.Resource(monv1.PrometheusName), prometheus.Name, errors.New(`conflict with "kubectl-edit": .spec.retention`))
+	})
+
+	recorder := events.NewInMemoryRecorder("test")
+	c := Client{mclient: mclient, actionRecorder: recorder}
+	prometheus.Spec.Retention = "1d"
+	if err := c.CreateOrUpdatePrometheus(ctx, prometheus); err != nil {
+		t.Fatal(err)
+	}
+
+	if applies != 2 {
+		t.Fatalf("expected the object to be applied twice, got %d", applies)
+	}
+
+	after, err := mclient.MonitoringV1().Prometheuses(ns).Get(ctx, prometheus.GetName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Spec.Retention != "1d" {
+		t.Fatalf("expected retention %q, got %q", "1d", after.Spec.Retention)
+	}
+
+	var conflicts []string
+	for _, e := range recorder.Events() {
+		if e.Reason == "PrometheusApplyConflict" {
+			conflicts = append(conflicts, e.Message)
+		}
+	}
+	if len(conflicts) != 1 || !strings.Contains(conflicts[0], "Prometheus/k8s -n "+ns) || !strings.Contains(conflicts[0], "kubectl-edit") {
+		t.Fatalf("expected a conflict event for Prometheus/k8s, got %q", conflicts)
+	}
+}
+
+func TestApplyDoesntForceConflictsWithControllers(t *testing.T) {
+	ctx := context.Background()
+	prometheus := &monv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8s",
+			Namespace: ns,
+		},
+	}
+
+	mclient := fakeMonitoring(prometheus.DeepCopy())
+	var applies int
+	mclient.PrependReactor("patch", "prometheuses", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.(clienttesting.PatchAction).GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		applies++
+		err := apierrors.NewConflict(monv1.Resource(monv1.PrometheusName), prometheus.Name, errors.New(`Apply failed with 2 conflicts: conflict with "kubectl-edit" using monitoring.coreos.com/v1: .spec.retention, conflict with "prometheus-autoscaler" using monitoring.coreos.com/v1: .spec.replicas`))
+		err.ErrStatus.Details.Causes = []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldManagerConflict,
+			Message: `conflict with "kubectl-edit" using monitoring.coreos.com/v1`,
+			Field:   ".spec.retention",
+		}, {
+			Type:    metav1.CauseTypeFieldManagerConflict,
+			Message: `conflict with "prometheus-autoscaler" using monitoring.coreos.com/v1`,
+			Field:   ".spec.replicas",
+		}}
+		return true, nil, err
+	})
+
+	recorder := events.NewInMemoryRecorder("test")
+	c := Client{mclient: mclient, actionRecorder: recorder}
+	prometheus.Spec.Retention = "1d"
+	err := c.CreateOrUpdatePrometheus(ctx, prometheus)
+	if err == nil || !strings.Contains(err.Error(), "prometheus-autoscaler") {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+	if applies != 1 {
+		t.Fatalf("expected the object to be applied once, got %d", applies)
+	}
+
+	var conflicts []string
+	for _, e := range recorder.Events() {
+		if e.Reason == "PrometheusApplyConflict" {
+			conflicts = append(conflicts, e.Message)
+		}
+	}
+	if len(conflicts) != 1 || !strings.HasPrefix(conflicts[0], "Not applying") || !strings.Contains(conflicts[0], "prometheus-autoscaler") {
+		t.Fatalf("expected a conflict event without forcing, got %q", conflicts)
+	}
+}
+
+func TestIsResourceVersionConflict(t *testing.T) {
+	gr := monv1.Resource(monv1.PrometheusName)
+	fieldConflict := apierrors.NewConflict(gr, "k8s", errors.New(`Apply failed with 1 conflict: conflict with "prometheus-autoscaler" using monitoring.coreos.com/v1: .spec.replicas`))
+	fieldConflict.ErrStatus.Details.Causes = []metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldManagerConflict,
+		Message: `conflict with "prometheus-autoscaler" using monitoring.coreos.com/v1`,
+		Field:   ".spec.replicas",
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "object modified",
+			err:      apierrors.NewConflict(gr, "k8s", errors.New("the object has been modified")),
+			expected: true,
+		},
+		{
+			name:     "wrapped object modified",
+			err:      fmt.Errorf("updating Prometheus object failed: %w", apierrors.NewConflict(gr, "k8s", errors.New("the object has been modified"))),
+			expected: true,
+		},
+		{
+			name: "field manager conflict",
+			err:  fieldConflict,
+		},
+		{
+			name: "field manager conflict without causes",
+			err:  apierrors.NewConflict(gr, "k8s", errors.New(`conflict with "prometheus-autoscaler": .spec.replicas`)),
+		},
+		{
+			name: "not a conflict",
+			err:  apierrors.NewBadRequest("invalid"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isResourceVersionConflict(tc.err); got != tc.expected {
+				t.Fatalf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestManagedFieldsMigrationPatch(t *testing.T) {
+	entry := func(manager string, op metav1.ManagedFieldsOperationType, fields string) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{
+			Manager:    manager,
+			Operation:  op,
+			APIVersion: "v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		fields   []metav1.ManagedFieldsEntry
+		expected []metav1.ManagedFieldsEntry
+	}{
+		{
+			name: "no legacy manager",
+			fields: []metav1.ManagedFieldsEntry{
+				entry(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:data":{"f:a":{}}}`),
+				entry("service-ca", metav1.ManagedFieldsOperationUpdate, `{"f:data":{"f:b":{}}}`),
+			},
+		},
+		{
+			name: "legacy manager only",
+			fields: []metav1.ManagedFieldsEntry{
+				entry("operator", metav1.ManagedFieldsOperationUpdate, `{"f:data":{"f:a":{}}}`),
+				entry("service-ca", metav1.ManagedFieldsOperationUpdate, `{"f:data":{"f:b":{}}}`),
+			},
+			expected: []metav1.ManagedFieldsEntry{
+				entry("service-ca", metav1.ManagedFieldsOperationUpdate, `{"f:data":{"f:b":{}}}`),
+				entry(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:data":{"f:a":{}}}`),
+			},
+		},
+		{
+			name: "legacy managers merged with the apply manager",
+			fields: []metav1.ManagedFieldsEntry{
+				entry(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:data":{"f:a":{}}}`),
+				entry("operator", metav1.ManagedFieldsOperationUpdate, `{"f:data":{"f:c":{}},"f:metadata":{"f:labels":{"f:foo":{}}}}`),
+				entry(FieldManager, metav1.ManagedFieldsOperationUpdate, `{"f:metadata":{"f:labels":{"f:bar":{}}}}`),
+			},
+			expected: []metav1.ManagedFieldsEntry{
+				entry(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:data":{"f:a":{},"f:c":{}},"f:metadata":{"f:labels":{"f:bar":{},"f:foo":{}}}}`),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "42", ManagedFields: tc.fields}}
+			data, err := managedFieldsMigrationPatch(cm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.expected == nil {
+				if data != nil {
+					t.Fatalf("expected no patch, got %s", data)
+				}
+				return
+			}
+
+			var ops []struct {
+				Op    string          `json:"op"`
+				Path  string          `json:"path"`
+				Value json.RawMessage `json:"value"`
+			}
+			if err := json.Unmarshal(data, &ops); err != nil {
+				t.Fatal(err)
+			}
+			if len(ops) != 2 || ops[0].Op != "test" || string(ops[0].Value) != `"42"` || ops[1].Op != "replace" || ops[1].Path != "/metadata/managedFields" {
+				t.Fatalf("unexpected patch %s", data)
+			}
+
+			var got []metav1.ManagedFieldsEntry
+			if err := json.Unmarshal(ops[1].Value, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %d entries, got %s", len(tc.expected), ops[1].Value)
+			}
+			for i := range got {
+				e := tc.expected[i]
+				if got[i].Manager != e.Manager || got[i].Operation != e.Operation || string(got[i].FieldsV1.Raw) != string(e.FieldsV1.Raw) {
+					t.Fatalf("expected entry %d to be %s/%s %s, got %s/%s %s", i, e.Manager, e.Operation, e.FieldsV1.Raw, got[i].Manager, got[i].Operation, got[i].FieldsV1.Raw)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRemovesStaleOperatorMetadata(t *testing.T) {
+	ctx := context.Background()
+	sa := &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prometheus-k8s",
+			Namespace: ns,
+			Annotations: map[string]string{
+				"monitoring.openshift.io/stale": "",
+				"other":                         "value",
+			},
+		},
+	}
+
+	c := Client{kclient: fakeKube(sa.DeepCopy())}
+	required := sa.DeepCopy()
+	required.Annotations = map[string]string{"monitoring.openshift.io/foo": "bar"}
+	if err := c.CreateOrUpdateServiceAccount(ctx, required); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := c.kclient.CoreV1().ServiceAccounts(ns).Get(ctx, sa.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"monitoring.openshift.io/foo": "bar",
+		"other":                       "value",
+	}
+	if len(after.Annotations) != len(expected) {
+		t.Fatalf("expected annotations %q, got %q", expected, after.Annotations)
+	}
+	for k, v := range expected {
+		if after.Annotations[k] != v {
+			t.Fatalf("expected annotations %q, got %q", expected, after.Annotations)
+		}
+	}
+}
@@ -15,14 +15,14 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"net/url"
 	"reflect"
-	"strings"
 	"time"
 
-	"github.com/imdario/mergo"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
 
 	configv1 "github.com/openshift/api/config/v1"
 	routev1 "github.com/openshift/api/route/v1"
@@ -30,6 +30,7 @@ import (
 	openshiftconfigclientset "github.com/openshift/client-go/config/clientset/versioned"
 	openshiftrouteclientset "github.com/openshift/client-go/route/clientset/versioned"
 	openshiftsecurityclientset "github.com/openshift/client-go/security/clientset/versioned"
+	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/prometheus-operator/prometheus-operator/pkg/alertmanager"
 	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	monitoring "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned"
@@ -38,20 +39,29 @@ import (
 	thanosoperator "github.com/prometheus-operator/prometheus-operator/pkg/thanos"
 	admissionv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	v1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	extensionsobj "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 	aggregatorclient "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset"
@@ -60,6 +70,48 @@ import (
 const (
 	deploymentCreateTimeout = 5 * time.Minute
 	metadataPrefix          = "monitoring.openshift.io/"
+
+	// RotateSecretAnnotation is set by administrators on a Secret managed
+	// by the operator to generate new random values (session secrets,
+	// salts, password hashes) on the next reconciliation. The annotation
+	// is removed by the update.
+	RotateSecretAnnotation = metadataPrefix + "rotate-secret"
+
+	// ManagedObjectsSelector selects the objects deployed by the operator.
+	ManagedObjectsSelector = "app.kubernetes.io/part-of=openshift-monitoring"
+)
+
+var consoleNotificationGVR = schema.GroupVersionResource{
+	Group:    "console.openshift.io",
+	Version:  "v1",
+	Resource: "consolenotifications",
+}
+
+var (
+	// ClusterMonitoringGVR identifies the cluster-scoped resources holding
+	// the configuration of the platform monitoring stack.
+	ClusterMonitoringGVR = schema.GroupVersionResource{
+		Group:    "monitoring.openshift.io",
+		Version:  "v1alpha1",
+		Resource: "clustermonitorings",
+	}
+	// UserWorkloadMonitoringGVR identifies the resources holding the
+	// configuration of the user workload monitoring stack.
+	UserWorkloadMonitoringGVR = schema.GroupVersionResource{
+		Group:    "monitoring.openshift.io",
+		Version:  "v1alpha1",
+		Resource: "userworkloadmonitorings",
+	}
+)
+
+const (
+	// ClusterMonitoringName is the name of the only ClusterMonitoring
+	// resource read by the operator.
+	ClusterMonitoringName = "cluster"
+	// UserWorkloadMonitoringName is the name of the only
+	// UserWorkloadMonitoring resource read by the operator in the user
+	// workload monitoring namespace.
+	UserWorkloadMonitoringName = "user-workload"
 )
 
 type Client struct {
@@ -73,6 +125,11 @@ type Client struct {
 	mclient               monitoring.Interface
 	eclient               apiextensionsclient.Interface
 	aggclient             aggregatorclient.Interface
+	dclient               dynamic.Interface
+	objectRecorder        record.EventRecorder
+	actionRecorder        events.Recorder
+	generations           *generationTracker
+	unmanaged             *unmanagedTracker
 }
 
 func NewForConfig(cfg *rest.Config, version string, namespace, userWorkloadNamespace string) (*Client, error) {
@@ -116,6 +173,11 @@ func NewForConfig(cfg *rest.Config, version string, namespace, userWorkloadNames
 		return nil, errors.Wrap(err, "creating kubernetes aggregator")
 	}
 
+	dclient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating dynamic client")
+	}
+
 	return New(
 		version,
 		namespace,
@@ -127,6 +189,8 @@ func NewForConfig(cfg *rest.Config, version string, namespace, userWorkloadNames
 		MonitoringClient(mclient),
 		ApiExtensionsClient(eclient),
 		AggregatorClient(aggclient),
+		DynamicClient(dclient),
+		ObjectEventRecorder(NewObjectEventRecorder(kclient)),
 	), nil
 }
 
@@ -174,11 +238,19 @@ func AggregatorClient(aggclient aggregatorclient.Interface) Option {
 	}
 }
 
+func DynamicClient(dclient dynamic.Interface) Option {
+	return func(c *Client) {
+		c.dclient = dclient
+	}
+}
+
 func New(version string, namespace, userWorkloadNamespace string, options ...Option) *Client {
 	c := &Client{
 		version:               version,
 		namespace:             namespace,
 		userWorkloadNamespace: userWorkloadNamespace,
+		generations:           newGenerationTracker(),
+		unmanaged:             newUnmanagedTracker(),
 	}
 
 	for _, opt := range options {
@@ -204,6 +276,14 @@ func (c *Client) ConfigMapListWatchForNamespace(ns string) *cache.ListWatch {
 	return cache.NewListWatchFromClient(c.kclient.CoreV1().RESTClient(), "configmaps", ns, fields.Everything())
 }
 
+// ConfigMapListWatchForSelector lists and watches the ConfigMaps of the
+// namespace (all namespaces when empty) matching the label selector.
+func (c *Client) ConfigMapListWatchForSelector(ns, selector string) *cache.ListWatch {
+	return cache.NewFilteredListWatchFromClient(c.kclient.CoreV1().RESTClient(), "configmaps", ns, func(options *metav1.ListOptions) {
+		options.LabelSelector = selector
+	})
+}
+
 func (c *Client) SecretListWatchForNamespace(ns string) *cache.ListWatch {
 	return cache.NewListWatchFromClient(c.kclient.CoreV1().RESTClient(), "secrets", ns, fields.Everything())
 }
@@ -235,6 +315,91 @@ func (c *Client) InfrastructureListWatchForResource(ctx context.Context, resourc
 	}
 }
 
+// ClusterMonitoringListWatch lists and watches the ClusterMonitoring
+// resource read by the operator.
+func (c *Client) ClusterMonitoringListWatch(ctx context.Context) *cache.ListWatch {
+	return c.configResourceListWatch(ctx, c.dclient.Resource(ClusterMonitoringGVR), ClusterMonitoringName)
+}
+
+// UserWorkloadMonitoringListWatch lists and watches the
+// UserWorkloadMonitoring resource read by the operator.
+func (c *Client) UserWorkloadMonitoringListWatch(ctx context.Context) *cache.ListWatch {
+	return c.configResourceListWatch(ctx, c.dclient.Resource(UserWorkloadMonitoringGVR).Namespace(c.userWorkloadNamespace), UserWorkloadMonitoringName)
+}
+
+func (c *Client) configResourceListWatch(ctx context.Context, ri dynamic.ResourceInterface, name string) *cache.ListWatch {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return ri.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return ri.Watch(ctx, options)
+		},
+	}
+}
+
+// GetUserWorkloadMonitoring returns the UserWorkloadMonitoring resource read
+// by the operator.
+func (c *Client) GetUserWorkloadMonitoring(ctx context.Context) (*unstructured.Unstructured, error) {
+	return c.dclient.Resource(UserWorkloadMonitoringGVR).Namespace(c.userWorkloadNamespace).Get(ctx, UserWorkloadMonitoringName, metav1.GetOptions{})
+}
+
+// PrometheusRuleMetadataListWatch lists and watches the PrometheusRules of
+// all namespaces. The rule groups are dropped to save memory since only the
+// metadata is needed.
+func (c *Client) PrometheusRuleMetadataListWatch(ctx context.Context) *cache.ListWatch {
+	rules := c.mclient.MonitoringV1().PrometheusRules(metav1.NamespaceAll)
+
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			l, err := rules.List(ctx, options)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, r := range l.Items {
+				r.Spec = monv1.PrometheusRuleSpec{}
+			}
+
+			return l, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := rules.Watch(ctx, options)
+			if err != nil {
+				return nil, err
+			}
+
+			return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+				if r, ok := e.Object.(*monv1.PrometheusRule); ok {
+					r.Spec = monv1.PrometheusRuleSpec{}
+				}
+				return e, true
+			}), nil
+		},
+	}
+}
+
+// ManagedWorkloadListWatchForNamespace lists and watches the workloads of the
+// given resource (e.g. "deployments" or "prometheuses") deployed by the
+// operator in the namespace.
+func (c *Client) ManagedWorkloadListWatchForNamespace(resource, ns string) *cache.ListWatch {
+	var getter cache.Getter
+	switch resource {
+	case "deployments", "statefulsets", "daemonsets":
+		getter = c.kclient.AppsV1().RESTClient()
+	default:
+		getter = c.mclient.MonitoringV1().RESTClient()
+	}
+
+	return cache.NewFilteredListWatchFromClient(getter, resource, ns, func(options *metav1.ListOptions) {
+		options.LabelSelector = ManagedObjectsSelector
+	})
+}
+
 func (c *Client) ApiServersListWatchForResource(ctx context.Context, resource string) *cache.ListWatch {
 	apiServerInterface := c.oscclient.ConfigV1().APIServers()
 
@@ -285,53 +450,222 @@ func (c *Client) AssurePrometheusOperatorCRsExist(ctx context.Context) error {
 func (c *Client) CreateOrUpdateValidatingWebhookConfiguration(ctx context.Context, w *admissionv1.ValidatingWebhookConfiguration) error {
 	admclient := c.kclient.AdmissionregistrationV1().ValidatingWebhookConfigurations()
 	existing, err := admclient.Get(ctx, w.GetName(), metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
-		_, err := admclient.Create(ctx, w, metav1.CreateOptions{})
-		return errors.Wrap(err, "creating ValidatingWebhookConfiguration object failed")
-	}
-	if err != nil {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "retrieving ValidatingWebhookConfiguration object failed")
 	}
 
 	required := w.DeepCopy()
-	required.ResourceVersion = existing.ResourceVersion
-	// retain the CABundle that service-ca-operator created if the proper annotation is found
+	// leave the CABundle to service-ca-operator if the proper annotation is found
 	if val, ok := required.Annotations["service.beta.openshift.io/inject-cabundle"]; ok && val == "true" {
 		for i := range required.Webhooks {
-			if len(existing.Webhooks[i].ClientConfig.CABundle) > 0 {
-				required.Webhooks[i].ClientConfig.CABundle = existing.Webhooks[i].ClientConfig.CABundle
-			}
+			required.Webhooks[i].ClientConfig.CABundle = nil
 		}
 	}
-	_, err = admclient.Update(ctx, required, metav1.UpdateOptions{})
+
+	applied := existing
+	gvk := admissionv1.SchemeGroupVersion.WithKind("ValidatingWebhookConfiguration")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = admclient.Patch(ctx, required.GetName(), pt, data, opts)
+		return err
+	}
+
+	if apierrors.IsNotFound(err) {
+		err = c.apply(ctx, gvk, required, nil, patch)
+		c.recordCreated(ctx, "ValidatingWebhookConfiguration", applied, err)
+		return errors.Wrap(err, "creating ValidatingWebhookConfiguration object failed")
+	}
+
+	err = c.apply(ctx, gvk, required, existing, patch)
+	c.recordUpdated(ctx, "ValidatingWebhookConfiguration", existing, applied, err)
 	return errors.Wrap(err, "updating ValidatingWebhookConfiguration object failed")
 }
 
 func (c *Client) CreateOrUpdateSecurityContextConstraints(ctx context.Context, s *secv1.SecurityContextConstraints) error {
 	sccclient := c.ossclient.SecurityV1().SecurityContextConstraints()
 	existing, err := sccclient.Get(ctx, s.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving SecurityContextConstraints object failed")
+	}
+
+	applied := existing
+	gvk := secv1.GroupVersion.WithKind("SecurityContextConstraints")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = sccclient.Patch(ctx, s.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := sccclient.Create(ctx, s, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, s, nil, patch)
+		c.recordCreated(ctx, "SecurityContextConstraints", applied, err)
 		return errors.Wrap(err, "creating SecurityContextConstraints object failed")
 	}
+
+	err = c.apply(ctx, gvk, s, existing, patch)
+	c.recordUpdated(ctx, "SecurityContextConstraints", existing, applied, err)
+	return errors.Wrap(err, "updating SecurityContextConstraints object failed")
+}
+
+// HasRouteCapability returns true when the route.openshift.io API is served
+// by the cluster.
+func (c *Client) HasRouteCapability() (bool, error) {
+	_, err := c.kclient.Discovery().ServerResourcesForGroupVersion(routev1.GroupVersion.String())
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
 	if err != nil {
-		return errors.Wrap(err, "retrieving SecurityContextConstraints object failed")
+		return false, errors.Wrap(err, "discovering Route API failed")
+	}
+	return true, nil
+}
+
+// MissingAPIResources returns the resources which aren't served by the
+// cluster for the given group version.
+func (c *Client) MissingAPIResources(groupVersion string, resources ...string) ([]string, error) {
+	l, err := c.kclient.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if apierrors.IsNotFound(err) {
+		return resources, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "discovering %s API failed", groupVersion)
 	}
 
-	// the CRD version of SCC appears to require this.  We can try to chase why later.
-	required := s.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-	required.ResourceVersion = existing.ResourceVersion
+	served := make(map[string]struct{}, len(l.APIResources))
+	for _, r := range l.APIResources {
+		served[r.Name] = struct{}{}
+	}
 
-	_, err = sccclient.Update(ctx, required, metav1.UpdateOptions{})
-	return errors.Wrap(err, "updating SecurityContextConstraints object failed")
+	var missing []string
+	for _, r := range resources {
+		if _, found := served[r]; !found {
+			missing = append(missing, r)
+		}
+	}
+	return missing, nil
+}
+
+// GetNamespace returns the namespace with its deletion state.
+func (c *Client) GetNamespace(ctx context.Context, name string) (*v1.Namespace, error) {
+	return c.kclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+}
+
+// HasNamespace returns true when the namespace exists.
+func (c *Client) HasNamespace(ctx context.Context, name string) (bool, error) {
+	_, err := c.kclient.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "retrieving namespace %q failed", name)
+	}
+	return true, nil
+}
+
+// CanI returns true when the operator's service account is allowed to
+// perform the action described by the resource attributes.
+func (c *Client) CanI(ctx context.Context, ra authorizationv1.ResourceAttributes) (bool, error) {
+	sar, err := c.kclient.AuthorizationV1().SelfSubjectAccessReviews().Create(
+		ctx,
+		&authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &ra,
+			},
+		},
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "creating SelfSubjectAccessReview object failed")
+	}
+	return sar.Status.Allowed, nil
+}
+
+// ValidatingWebhookReady returns an error if the services backing the given
+// validating webhook configuration have no ready endpoint. It returns nil if
+// the configuration doesn't exist.
+func (c *Client) ValidatingWebhookReady(ctx context.Context, name string) error {
+	w, err := c.kclient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "retrieving ValidatingWebhookConfiguration object failed")
+	}
+
+	for _, wh := range w.Webhooks {
+		svc := wh.ClientConfig.Service
+		if svc == nil {
+			continue
+		}
+
+		ep, err := c.kclient.CoreV1().Endpoints(svc.Namespace).Get(ctx, svc.Name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "retrieving endpoints of service %s/%s for webhook %q failed", svc.Namespace, svc.Name, wh.Name)
+		}
+
+		var ready bool
+		for _, s := range ep.Subsets {
+			if len(s.Addresses) > 0 {
+				ready = true
+				break
+			}
+		}
+		if !ready {
+			return errors.Errorf("service %s/%s for webhook %q has no ready endpoint", svc.Namespace, svc.Name, wh.Name)
+		}
+	}
+
+	return nil
+}
+
+// ListConsoleNotifications returns the ConsoleNotification objects matching
+// the label selector. The ConsoleNotification API is only available when the
+// console is installed so callers should check for NotFound errors.
+func (c *Client) ListConsoleNotifications(ctx context.Context, selector string) ([]unstructured.Unstructured, error) {
+	l, err := c.dclient.Resource(consoleNotificationGVR).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, nil
+}
+
+func (c *Client) CreateOrUpdateConsoleNotification(ctx context.Context, n *unstructured.Unstructured) error {
+	nclient := c.dclient.Resource(consoleNotificationGVR)
+	existing, err := nclient.Get(ctx, n.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		created, err := nclient.Create(ctx, n, metav1.CreateOptions{})
+		c.recordCreated(ctx, "ConsoleNotification", created, err)
+		return errors.Wrap(err, "creating ConsoleNotification object failed")
+	}
+	if err != nil {
+		return errors.Wrap(err, "retrieving ConsoleNotification object failed")
+	}
+
+	if reflect.DeepEqual(existing.Object["spec"], n.Object["spec"]) && reflect.DeepEqual(existing.GetLabels(), n.GetLabels()) {
+		return nil
+	}
+
+	required := n.DeepCopy()
+	required.SetResourceVersion(existing.GetResourceVersion())
+	updated, err := nclient.Update(ctx, required, metav1.UpdateOptions{})
+	c.recordUpdated(ctx, "ConsoleNotification", existing, updated, err)
+	return errors.Wrap(err, "updating ConsoleNotification object failed")
+}
+
+func (c *Client) DeleteConsoleNotification(ctx context.Context, name string) error {
+	err := c.dclient.Resource(consoleNotificationGVR).Delete(ctx, name, metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "ConsoleNotification", &metav1.ObjectMeta{Name: name}, err)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
 }
 
 func (c *Client) CreateRouteIfNotExists(ctx context.Context, r *routev1.Route) error {
 	rclient := c.osrclient.RouteV1().Routes(r.GetNamespace())
 	_, err := rclient.Get(ctx, r.GetName(), metav1.GetOptions{})
 	if apierrors.IsNotFound(err) {
-		_, err := rclient.Create(ctx, r, metav1.CreateOptions{})
+		created, err := rclient.Create(ctx, r, metav1.CreateOptions{})
+		c.recordCreated(ctx, "Route", created, err)
 		return errors.Wrap(err, "creating Route object failed")
 	}
 	return nil
@@ -368,6 +702,10 @@ func (c *Client) GetInfrastructure(ctx context.Context, name string) (*configv1.
 	return c.oscclient.ConfigV1().Infrastructures().Get(ctx, name, metav1.GetOptions{})
 }
 
+func (c *Client) GetDNS(ctx context.Context, name string) (*configv1.DNS, error) {
+	return c.oscclient.ConfigV1().DNSes().Get(ctx, name, metav1.GetOptions{})
+}
+
 func (c *Client) GetAPIServerConfig(ctx context.Context, name string) (*configv1.APIServer, error) {
 	return c.oscclient.ConfigV1().APIServers().Get(ctx, name, metav1.GetOptions{})
 }
@@ -376,72 +714,204 @@ func (c *Client) GetConfigmap(ctx context.Context, namespace, name string) (*v1.
 	return c.kclient.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
+// ListConfigMaps returns the configmaps in the given namespace matching the
+// label selector.
+func (c *Client) ListConfigMaps(ctx context.Context, namespace, labelSelector string) ([]v1.ConfigMap, error) {
+	cml, err := c.kclient.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing configmaps in namespace %s with label selector %s", namespace, labelSelector)
+	}
+
+	return cml.Items, nil
+}
+
+// ListPersistentVolumeClaims returns the persistent volume claims in the given
+// namespace matching the label selector.
+func (c *Client) ListPersistentVolumeClaims(ctx context.Context, namespace, labelSelector string) ([]v1.PersistentVolumeClaim, error) {
+	pvcl, err := c.kclient.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing persistent volume claims in namespace %s with label selector %s", namespace, labelSelector)
+	}
+
+	return pvcl.Items, nil
+}
+
+// ListServices returns the services in the given namespace matching the
+// label selector.
+func (c *Client) ListServices(ctx context.Context, namespace, labelSelector string) ([]v1.Service, error) {
+	sl, err := c.kclient.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing services in namespace %s with label selector %s", namespace, labelSelector)
+	}
+
+	return sl.Items, nil
+}
+
+// ListEndpoints returns the endpoints in the given namespace.
+func (c *Client) ListEndpoints(ctx context.Context, namespace string) ([]v1.Endpoints, error) {
+	el, err := c.kclient.CoreV1().Endpoints(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing endpoints in namespace %s", namespace)
+	}
+
+	return el.Items, nil
+}
+
+// ListPods returns the pods in the given namespace matching the label
+// selector.
+func (c *Client) ListPods(ctx context.Context, namespace, labelSelector string) ([]v1.Pod, error) {
+	pl, err := c.kclient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing pods in namespace %s with label selector %s", namespace, labelSelector)
+	}
+
+	return pl.Items, nil
+}
+
+// GetStorageClass returns the storage class with the given name or the
+// default storage class if name is empty. It returns nil if no storage class
+// matches.
+func (c *Client) GetStorageClass(ctx context.Context, name string) (*storagev1.StorageClass, error) {
+	// The operator is only allowed to list storage classes.
+	scs, err := c.kclient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing StorageClass objects failed")
+	}
+
+	for i := range scs.Items {
+		sc := &scs.Items[i]
+		if name != "" && sc.Name == name {
+			return sc, nil
+		}
+		if name == "" && sc.Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+			return sc, nil
+		}
+	}
+
+	return nil, nil
+}
+
 func (c *Client) GetSecret(ctx context.Context, namespace, name string) (*v1.Secret, error) {
 	return c.kclient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
+// CreateOrUpdatePrometheus creates or updates the Prometheus object.
+// CreateOrUpdatePrometheus creates or updates the Prometheus object. The update is
+// retried when the object is modified concurrently.
 func (c *Client) CreateOrUpdatePrometheus(ctx context.Context, p *monv1.Prometheus) error {
+	return retry.OnError(retry.DefaultRetry, isResourceVersionConflict, func() error {
+		return c.createOrUpdatePrometheus(ctx, p)
+	})
+}
+
+func (c *Client) createOrUpdatePrometheus(ctx context.Context, p *monv1.Prometheus) error {
 	pclient := c.mclient.MonitoringV1().Prometheuses(p.GetNamespace())
 	existing, err := pclient.Get(ctx, p.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving Prometheus object failed")
+	}
+
+	applied := existing
+	gvk := monv1.SchemeGroupVersion.WithKind(monv1.PrometheusesKind)
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = pclient.Patch(ctx, p.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := pclient.Create(ctx, p, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, p, nil, patch)
+		c.recordCreated(ctx, "Prometheus", applied, err)
 		return errors.Wrap(err, "creating Prometheus object failed")
 	}
+
+	err = c.apply(ctx, gvk, p, existing, patch)
+	c.recordUpdated(ctx, "Prometheus", existing, applied, err)
 	if err != nil {
-		return errors.Wrap(err, "retrieving Prometheus object failed")
+		return errors.Wrap(err, "updating Prometheus object failed")
 	}
 
-	required := p.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-
-	required.ResourceVersion = existing.ResourceVersion
-	_, err = pclient.Update(ctx, required, metav1.UpdateOptions{})
-	return errors.Wrap(err, "updating Prometheus object failed")
+	c.trackGeneration(ctx, "Prometheus", existing, applied)
+	c.recordUpdate(ctx, applied, &existing.Spec, &applied.Spec)
+	return nil
 }
 
 func (c *Client) CreateOrUpdatePrometheusRule(ctx context.Context, p *monv1.PrometheusRule) error {
 	pclient := c.mclient.MonitoringV1().PrometheusRules(p.GetNamespace())
 	existing, err := pclient.Get(ctx, p.GetName(), metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
-		_, err := pclient.Create(ctx, p, metav1.CreateOptions{})
-		return errors.Wrap(err, "creating PrometheusRule object failed")
-	}
-	if err != nil {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "retrieving PrometheusRule object failed")
 	}
 
-	required := p.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
+	applied := existing
+	gvk := monv1.SchemeGroupVersion.WithKind(monv1.PrometheusRuleKind)
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = pclient.Patch(ctx, p.GetName(), pt, data, opts)
+		return err
+	}
 
-	required.ResourceVersion = existing.ResourceVersion
+	if apierrors.IsNotFound(err) {
+		err = c.apply(ctx, gvk, p, nil, patch)
+		c.recordCreated(ctx, "PrometheusRule", applied, err)
+		return errors.Wrap(err, "creating PrometheusRule object failed")
+	}
 
-	_, err = pclient.Update(ctx, required, metav1.UpdateOptions{})
+	err = c.apply(ctx, gvk, p, existing, patch)
+	c.recordUpdated(ctx, "PrometheusRule", existing, applied, err)
 	return errors.Wrap(err, "updating PrometheusRule object failed")
 }
 
+// CreateOrUpdateAlertmanager creates or updates the Alertmanager object.
+// CreateOrUpdateAlertmanager creates or updates the Alertmanager object. The update is
+// retried when the object is modified concurrently.
 func (c *Client) CreateOrUpdateAlertmanager(ctx context.Context, a *monv1.Alertmanager) error {
+	return retry.OnError(retry.DefaultRetry, isResourceVersionConflict, func() error {
+		return c.createOrUpdateAlertmanager(ctx, a)
+	})
+}
+
+func (c *Client) createOrUpdateAlertmanager(ctx context.Context, a *monv1.Alertmanager) error {
 	aclient := c.mclient.MonitoringV1().Alertmanagers(a.GetNamespace())
 	existing, err := aclient.Get(ctx, a.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving Alertmanager object failed")
+	}
+
+	applied := existing
+	gvk := monv1.SchemeGroupVersion.WithKind(monv1.AlertmanagersKind)
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = aclient.Patch(ctx, a.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := aclient.Create(ctx, a, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, a, nil, patch)
+		c.recordCreated(ctx, "Alertmanager", applied, err)
 		return errors.Wrap(err, "creating Alertmanager object failed")
 	}
+
+	err = c.apply(ctx, gvk, a, existing, patch)
+	c.recordUpdated(ctx, "Alertmanager", existing, applied, err)
 	if err != nil {
-		return errors.Wrap(err, "retrieving Alertmanager object failed")
+		return errors.Wrap(err, "updating Alertmanager object failed")
 	}
 
-	required := a.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-
-	required.ResourceVersion = existing.ResourceVersion
-
-	_, err = aclient.Update(ctx, required, metav1.UpdateOptions{})
-	return errors.Wrap(err, "updating Alertmanager object failed")
+	c.trackGeneration(ctx, "Alertmanager", existing, applied)
+	c.recordUpdate(ctx, applied, &existing.Spec, &applied.Spec)
+	return nil
 }
 
 func (c *Client) DeleteAlertmanager(ctx context.Context, a *monv1.Alertmanager) error {
 	aclient := c.mclient.MonitoringV1().Alertmanagers(a.GetNamespace())
 	err := aclient.Delete(ctx, a.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "Alertmanager", a, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -449,27 +919,49 @@ func (c *Client) DeleteAlertmanager(ctx context.Context, a *monv1.Alertmanager)
 	return err
 }
 
+// CreateOrUpdateThanosRuler creates or updates the ThanosRuler object.
+// CreateOrUpdateThanosRuler creates or updates the ThanosRuler object. The update is
+// retried when the object is modified concurrently.
 func (c *Client) CreateOrUpdateThanosRuler(ctx context.Context, t *monv1.ThanosRuler) error {
+	return retry.OnError(retry.DefaultRetry, isResourceVersionConflict, func() error {
+		return c.createOrUpdateThanosRuler(ctx, t)
+	})
+}
+
+func (c *Client) createOrUpdateThanosRuler(ctx context.Context, t *monv1.ThanosRuler) error {
 	trclient := c.mclient.MonitoringV1().ThanosRulers(t.GetNamespace())
 	existing, err := trclient.Get(ctx, t.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving Thanos Ruler object failed")
+	}
+
+	applied := existing
+	gvk := monv1.SchemeGroupVersion.WithKind(monv1.ThanosRulerKind)
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = trclient.Patch(ctx, t.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := trclient.Create(ctx, t, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, t, nil, patch)
+		c.recordCreated(ctx, "ThanosRuler", applied, err)
 		return errors.Wrap(err, "creating Thanos Ruler object failed")
 	}
+
+	err = c.apply(ctx, gvk, t, existing, patch)
+	c.recordUpdated(ctx, "ThanosRuler", existing, applied, err)
 	if err != nil {
-		return errors.Wrap(err, "retrieving Thanos Ruler object failed")
+		return errors.Wrap(err, "updating Thanos Ruler object failed")
 	}
 
-	required := t.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-	required.ResourceVersion = existing.ResourceVersion
-
-	_, err = trclient.Update(ctx, required, metav1.UpdateOptions{})
-	return errors.Wrap(err, "updating Thanos Ruler object failed")
+	c.trackGeneration(ctx, "ThanosRuler", existing, applied)
+	c.recordUpdate(ctx, applied, &existing.Spec, &applied.Spec)
+	return nil
 }
 
 func (c *Client) DeleteConfigMap(ctx context.Context, cm *v1.ConfigMap) error {
 	err := c.kclient.CoreV1().ConfigMaps(cm.GetNamespace()).Delete(ctx, cm.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "ConfigMap", cm, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -490,6 +982,7 @@ func (c *Client) DeleteHashedConfigMap(ctx context.Context, namespace, prefix, n
 
 	for _, cm := range configMaps.Items {
 		err := c.KubernetesInterface().CoreV1().ConfigMaps(namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+		c.recordDeleted(ctx, "ConfigMap", &cm, err)
 		if err != nil {
 			return errors.Wrapf(err, "error deleting configmap: %s/%s", namespace, cm.Name)
 		}
@@ -511,6 +1004,7 @@ func (c *Client) DeleteHashedSecret(ctx context.Context, namespace, prefix, newH
 
 	for _, s := range secrets.Items {
 		err := c.KubernetesInterface().CoreV1().Secrets(namespace).Delete(ctx, s.Name, metav1.DeleteOptions{})
+		c.recordDeleted(ctx, "Secret", &s, err)
 		if err != nil {
 			return errors.Wrapf(err, "error deleting secret: %s/%s", namespace, s.Name)
 		}
@@ -521,6 +1015,41 @@ func (c *Client) DeleteHashedSecret(ctx context.Context, namespace, prefix, newH
 
 func (c *Client) DeleteValidatingWebhook(ctx context.Context, w *admissionv1.ValidatingWebhookConfiguration) error {
 	err := c.kclient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Delete(ctx, w.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "ValidatingWebhookConfiguration", w, err)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (c *Client) DeleteDeployment(ctx context.Context, d *appsv1.Deployment) error {
+	p := metav1.DeletePropagationForeground
+	err := c.kclient.AppsV1().Deployments(d.GetNamespace()).Delete(ctx, d.GetName(), metav1.DeleteOptions{PropagationPolicy: &p})
+	c.recordDeleted(ctx, "Deployment", d, err)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// DeletePersistentVolumeClaim deletes the persistent volume claim. The claim
+// is only removed once no pod uses it anymore.
+func (c *Client) DeletePersistentVolumeClaim(ctx context.Context, pvc *v1.PersistentVolumeClaim) error {
+	err := c.kclient.CoreV1().PersistentVolumeClaims(pvc.GetNamespace()).Delete(ctx, pvc.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "PersistentVolumeClaim", pvc, err)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (c *Client) DeleteStatefulSet(ctx context.Context, sts *appsv1.StatefulSet) error {
+	p := metav1.DeletePropagationForeground
+	err := c.kclient.AppsV1().StatefulSets(sts.GetNamespace()).Delete(ctx, sts.GetName(), metav1.DeleteOptions{PropagationPolicy: &p})
+	c.recordDeleted(ctx, "StatefulSet", sts, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -528,19 +1057,37 @@ func (c *Client) DeleteValidatingWebhook(ctx context.Context, w *admissionv1.Val
 	return err
 }
 
-func (c *Client) DeleteDeployment(ctx context.Context, d *appsv1.Deployment) error {
-	p := metav1.DeletePropagationForeground
-	err := c.kclient.AppsV1().Deployments(d.GetNamespace()).Delete(ctx, d.GetName(), metav1.DeleteOptions{PropagationPolicy: &p})
-	if apierrors.IsNotFound(err) {
-		return nil
+// DeleteStaleDeployments deletes the deployments of the namespace matching
+// the label selector, except the ones listed in keep.
+func (c *Client) DeleteStaleDeployments(ctx context.Context, namespace, selector string, keep []*appsv1.Deployment) error {
+	deployments, err := c.kclient.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return errors.Wrap(err, "listing deployments failed")
+	}
+
+	names := make(map[string]struct{}, len(keep))
+	for _, d := range keep {
+		names[d.GetName()] = struct{}{}
+	}
+
+	for i := range deployments.Items {
+		if _, found := names[deployments.Items[i].Name]; found {
+			continue
+		}
+
+		err = c.DeleteDeployment(ctx, &deployments.Items[i])
+		if err != nil {
+			return errors.Wrapf(err, "deleting deployment %s/%s failed", namespace, deployments.Items[i].Name)
+		}
 	}
 
-	return err
+	return nil
 }
 
 func (c *Client) DeletePodDisruptionBudget(ctx context.Context, pdb *policyv1.PodDisruptionBudget) error {
 	p := metav1.DeletePropagationForeground
 	err := c.kclient.PolicyV1().PodDisruptionBudgets(pdb.GetNamespace()).Delete(ctx, pdb.GetName(), metav1.DeleteOptions{PropagationPolicy: &p})
+	c.recordDeleted(ctx, "PodDisruptionBudget", pdb, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -552,6 +1099,7 @@ func (c *Client) DeletePrometheus(ctx context.Context, p *monv1.Prometheus) erro
 	pclient := c.mclient.MonitoringV1().Prometheuses(p.GetNamespace())
 
 	err := pclient.Delete(ctx, p.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "Prometheus", p, err)
 	if err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "deleting Prometheus object failed")
 	}
@@ -584,6 +1132,7 @@ func (c *Client) DeleteThanosRuler(ctx context.Context, tr *monv1.ThanosRuler) e
 	trclient := c.mclient.MonitoringV1().ThanosRulers(tr.GetNamespace())
 
 	err := trclient.Delete(ctx, tr.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "ThanosRuler", tr, err)
 	if err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "deleting Thanos Ruler object failed")
 	}
@@ -615,6 +1164,7 @@ func (c *Client) DeleteThanosRuler(ctx context.Context, tr *monv1.ThanosRuler) e
 func (c *Client) DeleteDaemonSet(ctx context.Context, d *appsv1.DaemonSet) error {
 	orphanDependents := false
 	err := c.kclient.AppsV1().DaemonSets(d.GetNamespace()).Delete(ctx, d.GetName(), metav1.DeleteOptions{OrphanDependents: &orphanDependents})
+	c.recordDeleted(ctx, "DaemonSet", d, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -630,6 +1180,7 @@ func (c *Client) DeleteServiceMonitorByNamespaceAndName(ctx context.Context, nam
 	sclient := c.mclient.MonitoringV1().ServiceMonitors(namespace)
 
 	err := sclient.Delete(ctx, name, metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "ServiceMonitor", &metav1.ObjectMeta{Namespace: namespace, Name: name}, err)
 	// if the object does not exist then everything is good here
 	if err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "deleting ServiceMonitor object failed")
@@ -640,6 +1191,7 @@ func (c *Client) DeleteServiceMonitorByNamespaceAndName(ctx context.Context, nam
 
 func (c *Client) DeleteServiceAccount(ctx context.Context, sa *v1.ServiceAccount) error {
 	err := c.kclient.CoreV1().ServiceAccounts(sa.Namespace).Delete(ctx, sa.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "ServiceAccount", sa, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -649,6 +1201,7 @@ func (c *Client) DeleteServiceAccount(ctx context.Context, sa *v1.ServiceAccount
 
 func (c *Client) DeleteClusterRole(ctx context.Context, cr *rbacv1.ClusterRole) error {
 	err := c.kclient.RbacV1().ClusterRoles().Delete(ctx, cr.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "ClusterRole", cr, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -658,6 +1211,7 @@ func (c *Client) DeleteClusterRole(ctx context.Context, cr *rbacv1.ClusterRole)
 
 func (c *Client) DeleteClusterRoleBinding(ctx context.Context, crb *rbacv1.ClusterRoleBinding) error {
 	err := c.kclient.RbacV1().ClusterRoleBindings().Delete(ctx, crb.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "ClusterRoleBinding", crb, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -667,6 +1221,7 @@ func (c *Client) DeleteClusterRoleBinding(ctx context.Context, crb *rbacv1.Clust
 
 func (c *Client) DeleteService(ctx context.Context, svc *v1.Service) error {
 	err := c.kclient.CoreV1().Services(svc.Namespace).Delete(ctx, svc.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "Service", svc, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -676,6 +1231,7 @@ func (c *Client) DeleteService(ctx context.Context, svc *v1.Service) error {
 
 func (c *Client) DeleteRoute(ctx context.Context, r *routev1.Route) error {
 	err := c.osrclient.RouteV1().Routes(r.GetNamespace()).Delete(ctx, r.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "Route", r, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -690,6 +1246,7 @@ func (c *Client) DeletePrometheusRuleByNamespaceAndName(ctx context.Context, nam
 	sclient := c.mclient.MonitoringV1().PrometheusRules(namespace)
 
 	err := sclient.Delete(ctx, name, metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "PrometheusRule", &metav1.ObjectMeta{Namespace: namespace, Name: name}, err)
 	// if the object does not exist then everything is good here
 	if err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "deleting PrometheusRule object failed")
@@ -700,6 +1257,7 @@ func (c *Client) DeletePrometheusRuleByNamespaceAndName(ctx context.Context, nam
 
 func (c *Client) DeleteSecret(ctx context.Context, s *v1.Secret) error {
 	err := c.kclient.CoreV1().Secrets(s.Namespace).Delete(ctx, s.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "Secret", s, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -833,10 +1391,7 @@ func (c *Client) CreateOrUpdateDeployment(ctx context.Context, dep *appsv1.Deplo
 		return nil
 	}
 
-	required := dep.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-
-	err = c.UpdateDeployment(ctx, required)
+	err = c.UpdateDeployment(ctx, existing, dep)
 	if err != nil {
 		uErr, ok := err.(*apierrors.StatusError)
 		if ok && uErr.ErrStatus.Code == 422 && uErr.ErrStatus.Reason == metav1.StatusReasonInvalid {
@@ -845,7 +1400,7 @@ func (c *Client) CreateOrUpdateDeployment(ctx context.Context, dep *appsv1.Deplo
 			if err != nil {
 				return errors.Wrap(err, "deleting Deployment object failed")
 			}
-			err = c.CreateDeployment(ctx, required)
+			err = c.CreateDeployment(ctx, dep)
 			if err != nil {
 				return errors.Wrap(err, "creating Deployment object failed after update failed")
 			}
@@ -856,7 +1411,8 @@ func (c *Client) CreateOrUpdateDeployment(ctx context.Context, dep *appsv1.Deplo
 }
 
 func (c *Client) CreateDeployment(ctx context.Context, dep *appsv1.Deployment) error {
-	d, err := c.kclient.AppsV1().Deployments(dep.GetNamespace()).Create(ctx, dep, metav1.CreateOptions{})
+	d, err := c.applyDeployment(ctx, dep, nil)
+	c.recordCreated(ctx, "Deployment", d, err)
 	if err != nil {
 		return err
 	}
@@ -864,15 +1420,90 @@ func (c *Client) CreateDeployment(ctx context.Context, dep *appsv1.Deployment) e
 	return c.WaitForDeploymentRollout(ctx, d)
 }
 
-func (c *Client) UpdateDeployment(ctx context.Context, dep *appsv1.Deployment) error {
-	updated, err := c.kclient.AppsV1().Deployments(dep.GetNamespace()).Update(ctx, dep, metav1.UpdateOptions{})
+// UpdateDeployment updates the existing deployment and waits for the
+// rollout to complete.
+func (c *Client) UpdateDeployment(ctx context.Context, existing, dep *appsv1.Deployment) error {
+	updated, err := c.applyDeployment(ctx, dep, existing)
+	c.recordUpdated(ctx, "Deployment", existing, updated, err)
 	if err != nil {
 		return err
 	}
 
+	c.trackGeneration(ctx, "Deployment", existing, updated)
+	c.recordUpdate(ctx, updated, &existing.Spec, &updated.Spec)
+
 	return c.WaitForDeploymentRollout(ctx, updated)
 }
 
+func (c *Client) applyDeployment(ctx context.Context, dep *appsv1.Deployment, existing metav1.Object) (*appsv1.Deployment, error) {
+	dclient := c.kclient.AppsV1().Deployments(dep.GetNamespace())
+	applied, _ := existing.(*appsv1.Deployment)
+	err := c.apply(ctx, appsv1.SchemeGroupVersion.WithKind("Deployment"), dep, existing, func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = dclient.Patch(ctx, dep.GetName(), pt, data, opts)
+		return err
+	})
+	return applied, err
+}
+
+// CreateOrUpdateStatefulSet creates or updates the statefulset and waits for
+// the rollout to complete. The statefulset is recreated when the update is
+// rejected because it modifies immutable fields such as the volume claim
+// templates.
+func (c *Client) CreateOrUpdateStatefulSet(ctx context.Context, sts *appsv1.StatefulSet) error {
+	existing, err := c.kclient.AppsV1().StatefulSets(sts.GetNamespace()).Get(ctx, sts.GetName(), metav1.GetOptions{})
+
+	if apierrors.IsNotFound(err) {
+		err = c.CreateStatefulSet(ctx, sts)
+		return errors.Wrap(err, "creating StatefulSet object failed")
+	}
+	if err != nil {
+		return errors.Wrap(err, "retrieving StatefulSet object failed")
+	}
+
+	updated, err := c.applyStatefulSet(ctx, sts, existing)
+	c.recordUpdated(ctx, "StatefulSet", existing, updated, err)
+	if err != nil {
+		uErr, ok := err.(*apierrors.StatusError)
+		if ok && uErr.ErrStatus.Code == 422 && uErr.ErrStatus.Reason == metav1.StatusReasonInvalid {
+			err = c.DeleteStatefulSet(ctx, existing)
+			if err != nil {
+				return errors.Wrap(err, "deleting StatefulSet object failed")
+			}
+			err = c.CreateStatefulSet(ctx, sts)
+			if err != nil {
+				return errors.Wrap(err, "creating StatefulSet object failed after update failed")
+			}
+			return nil
+		}
+		return errors.Wrap(err, "updating StatefulSet object failed")
+	}
+
+	c.trackGeneration(ctx, "StatefulSet", existing, updated)
+	c.recordUpdate(ctx, updated, &existing.Spec, &updated.Spec)
+
+	return c.WaitForStatefulsetRollout(ctx, updated)
+}
+
+func (c *Client) CreateStatefulSet(ctx context.Context, sts *appsv1.StatefulSet) error {
+	s, err := c.applyStatefulSet(ctx, sts, nil)
+	c.recordCreated(ctx, "StatefulSet", s, err)
+	if err != nil {
+		return err
+	}
+
+	return c.WaitForStatefulsetRollout(ctx, s)
+}
+
+func (c *Client) applyStatefulSet(ctx context.Context, sts *appsv1.StatefulSet, existing metav1.Object) (*appsv1.StatefulSet, error) {
+	sclient := c.kclient.AppsV1().StatefulSets(sts.GetNamespace())
+	applied, _ := existing.(*appsv1.StatefulSet)
+	err := c.apply(ctx, appsv1.SchemeGroupVersion.WithKind("StatefulSet"), sts, existing, func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = sclient.Patch(ctx, sts.GetName(), pt, data, opts)
+		return err
+	})
+	return applied, err
+}
+
 func (c *Client) WaitForDeploymentRollout(ctx context.Context, dep *appsv1.Deployment) error {
 	var lastErr error
 	if err := wait.Poll(time.Second, deploymentCreateTimeout, func() (bool, error) {
@@ -1019,10 +1650,7 @@ func (c *Client) CreateOrUpdateDaemonSet(ctx context.Context, ds *appsv1.DaemonS
 		return errors.Wrap(err, "retrieving DaemonSet object failed")
 	}
 
-	required := ds.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-
-	err = c.UpdateDaemonSet(ctx, required)
+	err = c.UpdateDaemonSet(ctx, existing, ds)
 	if err != nil {
 		uErr, ok := err.(*apierrors.StatusError)
 		if ok && uErr.ErrStatus.Code == 422 && uErr.ErrStatus.Reason == metav1.StatusReasonInvalid {
@@ -1032,7 +1660,7 @@ func (c *Client) CreateOrUpdateDaemonSet(ctx context.Context, ds *appsv1.DaemonS
 				return errors.Wrap(err, "deleting DaemonSet object failed")
 			}
 
-			err = c.CreateDaemonSet(ctx, required)
+			err = c.CreateDaemonSet(ctx, ds)
 			if err != nil {
 				return errors.Wrap(err, "creating DaemonSet object failed after update failed")
 			}
@@ -1043,7 +1671,8 @@ func (c *Client) CreateOrUpdateDaemonSet(ctx context.Context, ds *appsv1.DaemonS
 }
 
 func (c *Client) CreateDaemonSet(ctx context.Context, ds *appsv1.DaemonSet) error {
-	d, err := c.kclient.AppsV1().DaemonSets(ds.GetNamespace()).Create(ctx, ds, metav1.CreateOptions{})
+	d, err := c.applyDaemonSet(ctx, ds, nil)
+	c.recordCreated(ctx, "DaemonSet", d, err)
 	if err != nil {
 		return err
 	}
@@ -1051,15 +1680,31 @@ func (c *Client) CreateDaemonSet(ctx context.Context, ds *appsv1.DaemonSet) erro
 	return c.WaitForDaemonSetRollout(ctx, d)
 }
 
-func (c *Client) UpdateDaemonSet(ctx context.Context, ds *appsv1.DaemonSet) error {
-	updated, err := c.kclient.AppsV1().DaemonSets(ds.GetNamespace()).Update(ctx, ds, metav1.UpdateOptions{})
+// UpdateDaemonSet updates the existing daemonset and waits for the rollout
+// to complete.
+func (c *Client) UpdateDaemonSet(ctx context.Context, existing, ds *appsv1.DaemonSet) error {
+	updated, err := c.applyDaemonSet(ctx, ds, existing)
+	c.recordUpdated(ctx, "DaemonSet", existing, updated, err)
 	if err != nil {
 		return err
 	}
 
+	c.trackGeneration(ctx, "DaemonSet", existing, updated)
+	c.recordUpdate(ctx, updated, &existing.Spec, &updated.Spec)
+
 	return c.WaitForDaemonSetRollout(ctx, updated)
 }
 
+func (c *Client) applyDaemonSet(ctx context.Context, ds *appsv1.DaemonSet, existing metav1.Object) (*appsv1.DaemonSet, error) {
+	dclient := c.kclient.AppsV1().DaemonSets(ds.GetNamespace())
+	applied, _ := existing.(*appsv1.DaemonSet)
+	err := c.apply(ctx, appsv1.SchemeGroupVersion.WithKind("DaemonSet"), ds, existing, func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = dclient.Patch(ctx, ds.GetName(), pt, data, opts)
+		return err
+	})
+	return applied, err
+}
+
 func (c *Client) WaitForDaemonSetRollout(ctx context.Context, ds *appsv1.DaemonSet) error {
 	var lastErr error
 	if err := wait.Poll(time.Second, deploymentCreateTimeout, func() (bool, error) {
@@ -1109,37 +1754,158 @@ func (c *Client) WaitForDaemonSetRollout(ctx context.Context, ds *appsv1.DaemonS
 }
 
 func (c *Client) CreateOrUpdateSecret(ctx context.Context, s *v1.Secret) error {
+	return c.createOrUpdateSecret(ctx, s, nil)
+}
+
+// CreateOrUpdateGeneratedSecret creates or updates the Secret like
+// CreateOrUpdateSecret but keeps the existing values of the given keys which
+// hold randomly generated data (e.g. session secrets and salts). Generating
+// new values on every reconciliation would restart the pods mounting the
+// Secret for no reason. The values are generated again when the existing
+// Secret has the RotateSecretAnnotation annotation.
+func (c *Client) CreateOrUpdateGeneratedSecret(ctx context.Context, s *v1.Secret, keys ...string) error {
+	generated := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		generated[k] = struct{}{}
+	}
+
+	return c.createOrUpdateSecret(ctx, s, func(key string, _ []byte) bool {
+		_, found := generated[key]
+		return found
+	})
+}
+
+// CreateOrUpdateHtpasswdSecret creates or updates the Secret holding the
+// bcrypt hash of the password under the "auth" key. The hash is salted
+// randomly hence the existing one is kept as long as it matches the password.
+func (c *Client) CreateOrUpdateHtpasswdSecret(ctx context.Context, s *v1.Secret, password string) error {
+	return c.createOrUpdateSecret(ctx, s, func(key string, value []byte) bool {
+		if key != "auth" {
+			return false
+		}
+
+		i := bytes.IndexByte(value, ':')
+		if i < 0 {
+			return false
+		}
+		return bcrypt.CompareHashAndPassword(value[i+1:], []byte(password)) == nil
+	})
+}
+
+// createOrUpdateSecret creates or updates the Secret. When keep is not nil,
+// it tells which existing values replace the required ones unless the
+// rotation of the Secret is requested. The Secret isn't updated when nothing
+// changed.
+func (c *Client) createOrUpdateSecret(ctx context.Context, s *v1.Secret, keep func(key string, value []byte) bool) error {
 	sClient := c.kclient.CoreV1().Secrets(s.GetNamespace())
 	existing, err := sClient.Get(ctx, s.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving Secret object failed")
+	}
+
+	applied := existing
+	gvk := v1.SchemeGroupVersion.WithKind("Secret")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = sClient.Patch(ctx, s.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := sClient.Create(ctx, s, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, s, nil, patch)
+		c.recordCreated(ctx, "Secret", applied, err)
 		return errors.Wrap(err, "creating Secret object failed")
 	}
-	if err != nil {
-		return errors.Wrap(err, "retrieving Secret object failed")
-	}
 
 	required := s.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
+	current := existing.DeepCopy()
+
+	if _, rotate := existing.Annotations[RotateSecretAnnotation]; rotate {
+		klog.V(2).Infof("Rotating the generated data of Secret %s/%s", existing.Namespace, existing.Name)
+	} else if keep != nil {
+		for k := range required.Data {
+			if v, ok := existing.Data[k]; ok && len(v) > 0 && keep(k, v) {
+				required.Data[k] = v
+			}
+		}
+	}
+
 	// Check if the Secret has an owner reference to a Service, that carries
 	// the annotation with key
 	// service.beta.openshift.io/serving-cert-secret-name and the Secrets
 	// name as the value.
 	// This means that service-ca-operator controls and populates the two
-	// data fields tls.crt and tls.key. We want to retain those on updates
-	// if they exist and are not empty.
-	if c.maybeHasServiceCAData(ctx, required) {
-		if v, ok := existing.Data["tls.crt"]; ok && len(v) > 0 {
-			required.Data["tls.crt"] = v
+	// data fields tls.crt and tls.key. They are left out of the applied
+	// object so that the operator doesn't take their ownership.
+	serviceCA := c.maybeHasServiceCAData(ctx, required)
+	if serviceCA {
+		if required.Data == nil {
+			required.Data = map[string][]byte{}
+		}
+		for _, k := range []string{"tls.crt", "tls.key"} {
+			if v, ok := existing.Data[k]; ok && len(v) > 0 {
+				required.Data[k] = v
+			}
 		}
-		if v, ok := existing.Data["tls.key"]; ok && len(v) > 0 {
-			required.Data["tls.key"] = v
+	}
+
+	if secretUnchanged(required, current) {
+		return nil
+	}
+
+	// Drop the tls data when it's empty or not owned by service-ca-operator.
+	keys := make([]string, 0, len(existing.Data))
+	for k := range existing.Data {
+		keys = append(keys, k)
+	}
+	var stale []string
+	for _, k := range []string{"tls.crt", "tls.key"} {
+		if v, found := existing.Data[k]; found {
+			if _, required := required.Data[k]; (serviceCA && len(v) == 0) || (!serviceCA && !required) {
+				stale = append(stale, k)
+			}
 		}
 	}
-	_, err = sClient.Update(ctx, required, metav1.UpdateOptions{})
+	if serviceCA {
+		delete(required.Data, "tls.crt")
+		delete(required.Data, "tls.key")
+	}
+
+	err = c.removeStaleData(gvk, existing, keys, patch, stale...)
+	if err == nil {
+		err = c.apply(ctx, gvk, required, applied, patch)
+	}
+	c.recordUpdated(ctx, "Secret", existing, applied, err)
 	return errors.Wrap(err, "updating Secret object failed")
 }
 
+// secretUnchanged returns whether updating the existing Secret with the
+// required one would be a no-op. The string data is merged into the data
+// like the API server does.
+func secretUnchanged(required, existing *v1.Secret) bool {
+	data := make(map[string][]byte, len(required.Data)+len(required.StringData))
+	for k, v := range required.Data {
+		data[k] = v
+	}
+	for k, v := range required.StringData {
+		data[k] = []byte(v)
+	}
+
+	typ := required.Type
+	if typ == "" {
+		typ = v1.SecretTypeOpaque
+	}
+	existingType := existing.Type
+	if existingType == "" {
+		existingType = v1.SecretTypeOpaque
+	}
+
+	return typ == existingType &&
+		equality.Semantic.DeepEqual(data, existing.Data) &&
+		equality.Semantic.DeepEqual(required.Labels, existing.Labels) &&
+		equality.Semantic.DeepEqual(required.Annotations, existing.Annotations) &&
+		equality.Semantic.DeepEqual(required.OwnerReferences, existing.OwnerReferences)
+}
+
 // maybeHasServiceCAData checks if the passed Secret s has at least one owner reference that
 // points to a Service with the annotation service.beta.openshift.io/serving-cert-secret-name: s.name
 func (c *Client) maybeHasServiceCAData(ctx context.Context, s *v1.Secret) bool {
@@ -1163,7 +1929,8 @@ func (c *Client) CreateIfNotExistSecret(ctx context.Context, s *v1.Secret) error
 	sClient := c.kclient.CoreV1().Secrets(s.GetNamespace())
 	_, err := sClient.Get(ctx, s.GetName(), metav1.GetOptions{})
 	if apierrors.IsNotFound(err) {
-		_, err := sClient.Create(ctx, s, metav1.CreateOptions{})
+		created, err := sClient.Create(ctx, s, metav1.CreateOptions{})
+		c.recordCreated(ctx, "Secret", created, err)
 		return errors.Wrap(err, "creating Secret object failed")
 	}
 
@@ -1193,24 +1960,47 @@ func (c *Client) DeleteConfigMapList(ctx context.Context, cml *v1.ConfigMapList)
 func (c *Client) CreateOrUpdateConfigMap(ctx context.Context, cm *v1.ConfigMap) error {
 	cmClient := c.kclient.CoreV1().ConfigMaps(cm.GetNamespace())
 	existing, err := cmClient.Get(ctx, cm.GetName(), metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
-		_, err := cmClient.Create(ctx, cm, metav1.CreateOptions{})
-		return errors.Wrap(err, "creating ConfigMap object failed")
-	}
-	if err != nil {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "retrieving ConfigMap object failed")
 	}
 
 	required := cm.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
+	injectCABundle := false
 	if val, ok := required.Annotations["service.beta.openshift.io/inject-cabundle"]; ok && val == "true" {
-		// retain any service-ca data that service-ca-operator has created
-		if v, ok := existing.Data["service-ca.crt"]; ok && len(v) > 0 {
-			required.Data["service-ca.crt"] = v
-		}
+		// leave the service-ca data to service-ca-operator
+		delete(required.Data, "service-ca.crt")
+		injectCABundle = true
+	}
+
+	applied := existing
+	gvk := v1.SchemeGroupVersion.WithKind("ConfigMap")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = cmClient.Patch(ctx, required.GetName(), pt, data, opts)
+		return err
+	}
+
+	if apierrors.IsNotFound(err) {
+		err = c.apply(ctx, gvk, required, nil, patch)
+		c.recordCreated(ctx, "ConfigMap", applied, err)
+		return errors.Wrap(err, "creating ConfigMap object failed")
 	}
 
-	_, err = cmClient.Update(ctx, required, metav1.UpdateOptions{})
+	// Drop the service-ca data when it's empty or not injected anymore.
+	keys := make([]string, 0, len(existing.Data))
+	for k := range existing.Data {
+		keys = append(keys, k)
+	}
+	var stale []string
+	if v, found := existing.Data["service-ca.crt"]; found {
+		if _, required := required.Data["service-ca.crt"]; (injectCABundle && v == "") || (!injectCABundle && !required) {
+			stale = append(stale, "service-ca.crt")
+		}
+	}
+	err = c.removeStaleData(gvk, existing, keys, patch, stale...)
+	if err == nil {
+		err = c.apply(ctx, gvk, required, applied, patch)
+	}
+	c.recordUpdated(ctx, "ConfigMap", existing, applied, err)
 	return errors.Wrap(err, "updating ConfigMap object failed")
 }
 
@@ -1226,6 +2016,7 @@ func (c *Client) DeleteIfExists(ctx context.Context, nsName string) error {
 	}
 
 	err = nClient.Delete(ctx, nsName, metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "Namespace", &metav1.ObjectMeta{Name: nsName}, err)
 	return errors.Wrap(err, "deleting ConfigMap object failed")
 }
 
@@ -1234,6 +2025,7 @@ func (c *Client) CreateIfNotExistConfigMap(ctx context.Context, cm *v1.ConfigMap
 	res, err := cClient.Get(ctx, cm.GetName(), metav1.GetOptions{})
 	if apierrors.IsNotFound(err) {
 		res, err := cClient.Create(ctx, cm, metav1.CreateOptions{})
+		c.recordCreated(ctx, "ConfigMap", res, err)
 		if err != nil {
 			return nil, errors.Wrap(err, "creating ConfigMap object failed")
 		}
@@ -1248,13 +2040,22 @@ func (c *Client) CreateIfNotExistConfigMap(ctx context.Context, cm *v1.ConfigMap
 func (c *Client) CreateOrUpdatePodDisruptionBudget(ctx context.Context, pdb *policyv1.PodDisruptionBudget) error {
 	pdbClient := c.kclient.PolicyV1().PodDisruptionBudgets(pdb.Namespace)
 	existing, err := pdbClient.Get(ctx, pdb.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving PodDisruptionBudget object failed")
+	}
+
+	applied := existing
+	gvk := policyv1.SchemeGroupVersion.WithKind("PodDisruptionBudget")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = pdbClient.Patch(ctx, pdb.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := pdbClient.Create(ctx, pdb, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, pdb, nil, patch)
+		c.recordCreated(ctx, "PodDisruptionBudget", applied, err)
 		return errors.Wrap(err, "creating PodDisruptionBudget object failed")
 	}
-	if err != nil {
-		return errors.Wrap(err, "retrieving PodDisruptionBudget object failed")
-	}
 
 	required := pdb.DeepCopy()
 	required.ResourceVersion = existing.ResourceVersion
@@ -1263,25 +2064,32 @@ func (c *Client) CreateOrUpdatePodDisruptionBudget(ctx context.Context, pdb *pol
 		return nil
 	}
 
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-
-	_, err = pdbClient.Update(ctx, required, metav1.UpdateOptions{})
+	err = c.apply(ctx, gvk, pdb, existing, patch)
+	c.recordUpdated(ctx, "PodDisruptionBudget", existing, applied, err)
 	return errors.Wrap(err, "updating PodDisruptionBudget object failed")
 }
 
 func (c *Client) CreateOrUpdateService(ctx context.Context, svc *v1.Service) error {
 	sclient := c.kclient.CoreV1().Services(svc.GetNamespace())
 	existing, err := sclient.Get(ctx, svc.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving Service object failed")
+	}
+
+	applied := existing
+	gvk := v1.SchemeGroupVersion.WithKind("Service")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = sclient.Patch(ctx, svc.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err = sclient.Create(ctx, svc, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, svc, nil, patch)
+		c.recordCreated(ctx, "Service", applied, err)
 		return errors.Wrap(err, "creating Service object failed")
 	}
-	if err != nil {
-		return errors.Wrap(err, "retrieving Service object failed")
-	}
 
 	required := svc.DeepCopy()
-	required.ResourceVersion = existing.ResourceVersion
 	if required.Spec.Type == v1.ServiceTypeClusterIP {
 		required.Spec.ClusterIP = existing.Spec.ClusterIP
 	}
@@ -1290,166 +2098,293 @@ func (c *Client) CreateOrUpdateService(ctx context.Context, svc *v1.Service) err
 		return nil
 	}
 
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-
-	_, err = sclient.Update(ctx, required, metav1.UpdateOptions{})
+	err = c.apply(ctx, gvk, svc, existing, patch)
+	c.recordUpdated(ctx, "Service", existing, applied, err)
 	return errors.Wrap(err, "updating Service object failed")
 }
 
 func (c *Client) CreateOrUpdateRoleBinding(ctx context.Context, rb *rbacv1.RoleBinding) error {
 	rbClient := c.kclient.RbacV1().RoleBindings(rb.GetNamespace())
 	existing, err := rbClient.Get(ctx, rb.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving RoleBinding object failed")
+	}
+
+	applied := existing
+	gvk := rbacv1.SchemeGroupVersion.WithKind("RoleBinding")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = rbClient.Patch(ctx, rb.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := rbClient.Create(ctx, rb, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, rb, nil, patch)
+		c.recordCreated(ctx, "RoleBinding", applied, err)
 		return errors.Wrap(err, "creating RoleBinding object failed")
 	}
-	if err != nil {
-		return errors.Wrap(err, "retrieving RoleBinding object failed")
-	}
 
 	if reflect.DeepEqual(rb.RoleRef, existing.RoleRef) &&
 		reflect.DeepEqual(rb.Subjects, existing.Subjects) {
 		return nil
 	}
 
-	required := rb.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-
-	_, err = rbClient.Update(ctx, required, metav1.UpdateOptions{})
+	err = c.apply(ctx, gvk, rb, existing, patch)
+	c.recordUpdated(ctx, "RoleBinding", existing, applied, err)
 	return errors.Wrap(err, "updating RoleBinding object failed")
 }
 
 func (c *Client) CreateOrUpdateRole(ctx context.Context, r *rbacv1.Role) error {
 	rClient := c.kclient.RbacV1().Roles(r.GetNamespace())
 	existing, err := rClient.Get(ctx, r.GetName(), metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
-		_, err := rClient.Create(ctx, r, metav1.CreateOptions{})
-		return errors.Wrap(err, "creating Role object failed")
-	}
-	if err != nil {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "retrieving Role object failed")
 	}
 
-	required := r.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
+	applied := existing
+	gvk := rbacv1.SchemeGroupVersion.WithKind("Role")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = rClient.Patch(ctx, r.GetName(), pt, data, opts)
+		return err
+	}
+
+	if apierrors.IsNotFound(err) {
+		err = c.apply(ctx, gvk, r, nil, patch)
+		c.recordCreated(ctx, "Role", applied, err)
+		return errors.Wrap(err, "creating Role object failed")
+	}
 
-	_, err = rClient.Update(ctx, required, metav1.UpdateOptions{})
+	err = c.apply(ctx, gvk, r, existing, patch)
+	c.recordUpdated(ctx, "Role", existing, applied, err)
 	return errors.Wrap(err, "updating Role object failed")
 }
 
 func (c *Client) CreateOrUpdateClusterRole(ctx context.Context, cr *rbacv1.ClusterRole) error {
 	crClient := c.kclient.RbacV1().ClusterRoles()
 	existing, err := crClient.Get(ctx, cr.GetName(), metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
-		_, err := crClient.Create(ctx, cr, metav1.CreateOptions{})
-		return errors.Wrap(err, "creating ClusterRole object failed")
-	}
-	if err != nil {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "retrieving ClusterRole object failed")
 	}
 
-	required := cr.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
+	applied := existing
+	gvk := rbacv1.SchemeGroupVersion.WithKind("ClusterRole")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = crClient.Patch(ctx, cr.GetName(), pt, data, opts)
+		return err
+	}
+
+	if apierrors.IsNotFound(err) {
+		err = c.apply(ctx, gvk, cr, nil, patch)
+		c.recordCreated(ctx, "ClusterRole", applied, err)
+		return errors.Wrap(err, "creating ClusterRole object failed")
+	}
 
-	_, err = crClient.Update(ctx, required, metav1.UpdateOptions{})
+	err = c.apply(ctx, gvk, cr, existing, patch)
+	c.recordUpdated(ctx, "ClusterRole", existing, applied, err)
 	return errors.Wrap(err, "updating ClusterRole object failed")
 }
 
+// CreateOrUpdateClusterRoleBinding creates or updates the
+// ClusterRoleBinding. The role reference being immutable, the binding is
+// recreated when it changes unless it is unmanaged.
 func (c *Client) CreateOrUpdateClusterRoleBinding(ctx context.Context, crb *rbacv1.ClusterRoleBinding) error {
 	crbClient := c.kclient.RbacV1().ClusterRoleBindings()
 	existing, err := crbClient.Get(ctx, crb.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving ClusterRoleBinding object failed")
+	}
+
+	applied := existing
+	gvk := rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = crbClient.Patch(ctx, crb.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := crbClient.Create(ctx, crb, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, crb, nil, patch)
+		c.recordCreated(ctx, "ClusterRoleBinding", applied, err)
 		return errors.Wrap(err, "creating ClusterRoleBinding object failed")
 	}
-	if err != nil {
-		return errors.Wrap(err, "retrieving ClusterRoleBinding object failed")
-	}
 
 	if reflect.DeepEqual(crb.RoleRef, existing.RoleRef) &&
 		reflect.DeepEqual(crb.Subjects, existing.Subjects) {
 		return nil
 	}
 
-	required := crb.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
+	if !reflect.DeepEqual(crb.RoleRef, existing.RoleRef) && !isUnmanaged(existing) {
+		err = crbClient.Delete(ctx, crb.Name, metav1.DeleteOptions{})
+		if err != nil {
+			return errors.Wrap(err, "deleting ClusterRoleBinding object failed")
+		}
 
-	err = crbClient.Delete(ctx, crb.Name, metav1.DeleteOptions{})
-	if err != nil {
-		return errors.Wrap(err, "deleting ClusterRoleBinding object failed")
+		err = c.apply(ctx, gvk, crb, nil, patch)
+		if data := preservedMetadataPatch(crb, existing); err == nil && data != nil {
+			err = patch(types.MergePatchType, data, metav1.PatchOptions{FieldManager: preservedFieldManager})
+		}
+		c.recordUpdated(ctx, "ClusterRoleBinding", existing, applied, err)
+		return errors.Wrap(err, "updating ClusterRoleBinding object failed")
 	}
 
-	_, err = crbClient.Create(ctx, required, metav1.CreateOptions{})
+	err = c.apply(ctx, gvk, crb, existing, patch)
+	c.recordUpdated(ctx, "ClusterRoleBinding", existing, applied, err)
 	return errors.Wrap(err, "updating ClusterRoleBinding object failed")
 }
 
 func (c *Client) CreateOrUpdateServiceAccount(ctx context.Context, sa *v1.ServiceAccount) error {
 	sClient := c.kclient.CoreV1().ServiceAccounts(sa.GetNamespace())
-	_, err := sClient.Get(ctx, sa.GetName(), metav1.GetOptions{})
+	existing, err := sClient.Get(ctx, sa.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving ServiceAccount object failed")
+	}
+
+	// Contrary to updates, applying the ServiceAccount doesn't generate a
+	// new token secret when nothing has changed. The secrets and image pull
+	// secrets added by the token controllers aren't owned by the operator
+	// and are left untouched.
+	applied := existing
+	gvk := v1.SchemeGroupVersion.WithKind("ServiceAccount")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = sClient.Patch(ctx, sa.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := sClient.Create(ctx, sa, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, sa, nil, patch)
+		c.recordCreated(ctx, "ServiceAccount", applied, err)
 		return errors.Wrap(err, "creating ServiceAccount object failed")
 	}
-	return errors.Wrap(err, "retrieving ServiceAccount object failed")
-
-	// TODO(brancz): Use Patch instead of Update
-	//
-	// ServiceAccounts get a new secret generated whenever they are updated, even
-	// if nothing has changed. This is likely due to "Update" performing a PUT
-	// call signifying, that this may be a new ServiceAccount, therefore a new
-	// token is needed. The expectation is that Patch does not cause this,
-	// however, currently there has been no need to update ServiceAccounts,
-	// therefore we are skipping this effort for now until we actually need to
-	// change the ServiceAccount.
-	//
-	//if err != nil {
-	//	return errors.Wrap(err, "retrieving ServiceAccount object failed")
-	//}
-	//
-	//_, err = sClient.Update(sa)
-	//return errors.Wrap(err, "updating ServiceAccount object failed")
+
+	err = c.apply(ctx, gvk, sa, existing, patch)
+	c.recordUpdated(ctx, "ServiceAccount", existing, applied, err)
+	return errors.Wrap(err, "updating ServiceAccount object failed")
 }
 
 func (c *Client) CreateOrUpdateServiceMonitor(ctx context.Context, sm *monv1.ServiceMonitor) error {
 	smClient := c.mclient.MonitoringV1().ServiceMonitors(sm.GetNamespace())
 	existing, err := smClient.Get(ctx, sm.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving ServiceMonitor object failed")
+	}
+
+	applied := existing
+	gvk := monv1.SchemeGroupVersion.WithKind(monv1.ServiceMonitorsKind)
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = smClient.Patch(ctx, sm.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err := smClient.Create(ctx, sm, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, sm, nil, patch)
+		c.recordCreated(ctx, "ServiceMonitor", applied, err)
 		return errors.Wrap(err, "creating ServiceMonitor object failed")
 	}
+
+	err = c.apply(ctx, gvk, sm, existing, patch)
+	c.recordUpdated(ctx, "ServiceMonitor", existing, applied, err)
+	return errors.Wrap(err, "updating ServiceMonitor object failed")
+}
+
+func (c *Client) ListServiceMonitors(ctx context.Context, namespace, labelSelector string) ([]*monv1.ServiceMonitor, error) {
+	l, err := c.mclient.MonitoringV1().ServiceMonitors(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
 	if err != nil {
-		return errors.Wrap(err, "retrieving ServiceMonitor object failed")
+		return nil, errors.Wrap(err, "listing ServiceMonitor objects failed")
 	}
+	return l.Items, nil
+}
 
-	required := sm.DeepCopy()
-	mergeMetadata(&required.ObjectMeta, existing.ObjectMeta)
-
-	required.ResourceVersion = existing.ResourceVersion
-	_, err = smClient.Update(ctx, required, metav1.UpdateOptions{})
+func (c *Client) UpdateServiceMonitor(ctx context.Context, sm *monv1.ServiceMonitor) error {
+	updated, err := c.mclient.MonitoringV1().ServiceMonitors(sm.GetNamespace()).Update(ctx, sm, metav1.UpdateOptions{})
+	c.recordUpdated(ctx, "ServiceMonitor", sm, updated, err)
 	return errors.Wrap(err, "updating ServiceMonitor object failed")
 }
 
+func (c *Client) ListPodMonitors(ctx context.Context, namespace, labelSelector string) ([]*monv1.PodMonitor, error) {
+	l, err := c.mclient.MonitoringV1().PodMonitors(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing PodMonitor objects failed")
+	}
+	return l.Items, nil
+}
+
+func (c *Client) UpdatePodMonitor(ctx context.Context, pm *monv1.PodMonitor) error {
+	updated, err := c.mclient.MonitoringV1().PodMonitors(pm.GetNamespace()).Update(ctx, pm, metav1.UpdateOptions{})
+	c.recordUpdated(ctx, "PodMonitor", pm, updated, err)
+	return errors.Wrap(err, "updating PodMonitor object failed")
+}
+
+func (c *Client) ListPrometheusRules(ctx context.Context, namespace, labelSelector string) ([]*monv1.PrometheusRule, error) {
+	l, err := c.mclient.MonitoringV1().PrometheusRules(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing PrometheusRule objects failed")
+	}
+	return l.Items, nil
+}
+
+func (c *Client) UpdatePrometheusRule(ctx context.Context, rule *monv1.PrometheusRule) error {
+	updated, err := c.mclient.MonitoringV1().PrometheusRules(rule.GetNamespace()).Update(ctx, rule, metav1.UpdateOptions{})
+	c.recordUpdated(ctx, "PrometheusRule", rule, updated, err)
+	return errors.Wrap(err, "updating PrometheusRule object failed")
+}
+
 func (c *Client) CreateOrUpdateAPIService(ctx context.Context, apiService *apiregistrationv1.APIService) error {
 	apsc := c.aggclient.ApiregistrationV1().APIServices()
 	existing, err := apsc.Get(ctx, apiService.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving APIService object failed")
+	}
+
+	required := apiService.DeepCopy()
+	// leave the CA bundle to service-ca-operator
+	for _, a := range []string{"service.beta.openshift.io/inject-cabundle", "service.alpha.openshift.io/inject-cabundle"} {
+		if val, ok := required.Annotations[a]; ok && val == "true" {
+			required.Spec.CABundle = nil
+		}
+	}
+
+	applied := existing
+	gvk := apiregistrationv1.SchemeGroupVersion.WithKind("APIService")
+	patch := func(pt types.PatchType, data []byte, opts metav1.PatchOptions) (err error) {
+		applied, err = apsc.Patch(ctx, required.GetName(), pt, data, opts)
+		return err
+	}
+
 	if apierrors.IsNotFound(err) {
-		_, err = apsc.Create(ctx, apiService, metav1.CreateOptions{})
+		err = c.apply(ctx, gvk, required, nil, patch)
+		c.recordCreated(ctx, "APIService", applied, err)
 		return errors.Wrap(err, "creating APIService object failed")
 	}
+
+	err = c.apply(ctx, gvk, required, existing, patch)
+	c.recordUpdated(ctx, "APIService", existing, applied, err)
+	return errors.Wrap(err, "updating APIService object failed")
+}
+
+// DeleteAPIService deletes the APIService if it is served by the same
+// Service. The APIServices registered by other components for the same API
+// group are left untouched.
+func (c *Client) DeleteAPIService(ctx context.Context, apiService *apiregistrationv1.APIService) error {
+	apsc := c.aggclient.ApiregistrationV1().APIServices()
+	existing, err := apsc.Get(ctx, apiService.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
 	if err != nil {
 		return errors.Wrap(err, "retrieving APIService object failed")
 	}
 
-	required := apiService.DeepCopy()
-	required.ResourceVersion = existing.ResourceVersion
-	if val, ok := required.Annotations["service.beta.openshift.io/inject-cabundle"]; ok && val == "true" {
-		if len(existing.Spec.CABundle) > 0 {
-			required.Spec.CABundle = existing.Spec.CABundle
-		}
+	if existing.Spec.Service == nil || apiService.Spec.Service == nil ||
+		existing.Spec.Service.Namespace != apiService.Spec.Service.Namespace ||
+		existing.Spec.Service.Name != apiService.Spec.Service.Name {
+		return nil
+	}
+
+	err = apsc.Delete(ctx, apiService.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "APIService", apiService, err)
+	if apierrors.IsNotFound(err) {
+		return nil
 	}
-	_, err = apsc.Update(ctx, required, metav1.UpdateOptions{})
-	return errors.Wrap(err, "updating APIService object failed")
 
+	return errors.Wrap(err, "deleting APIService object failed")
 }
 
 func (c *Client) WaitForCRDReady(ctx context.Context, crd *extensionsobj.CustomResourceDefinition) error {
@@ -1486,6 +2421,7 @@ func (c *Client) StatusReporter() *StatusReporter {
 
 func (c *Client) DeleteRoleBinding(ctx context.Context, binding *rbacv1.RoleBinding) error {
 	err := c.kclient.RbacV1().RoleBindings(binding.Namespace).Delete(ctx, binding.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "RoleBinding", binding, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
@@ -1495,30 +2431,10 @@ func (c *Client) DeleteRoleBinding(ctx context.Context, binding *rbacv1.RoleBind
 
 func (c *Client) DeleteRole(ctx context.Context, role *rbacv1.Role) error {
 	err := c.kclient.RbacV1().Roles(role.Namespace).Delete(ctx, role.GetName(), metav1.DeleteOptions{})
+	c.recordDeleted(ctx, "Role", role, err)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
 
 	return err
 }
-
-// mergeMetadata merges labels and annotations from `existing` map into `required` one where `required` has precedence
-// over `existing` keys and values. Additionally function performs filtering of labels and annotations from `exiting` map
-// where keys starting from string defined in `metadataPrefix` are deleted. This prevents issues with preserving stale
-// metadata defined by the operator
-func mergeMetadata(required *metav1.ObjectMeta, existing metav1.ObjectMeta) {
-	for k := range existing.Annotations {
-		if strings.HasPrefix(k, metadataPrefix) {
-			delete(existing.Annotations, k)
-		}
-	}
-
-	for k := range existing.Labels {
-		if strings.HasPrefix(k, metadataPrefix) {
-			delete(existing.Labels, k)
-		}
-	}
-
-	mergo.Merge(&required.Annotations, existing.Annotations)
-	mergo.Merge(&required.Labels, existing.Labels)
-}
@@ -17,6 +17,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -25,14 +26,15 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"golang.org/x/crypto/bcrypt"
 	"k8s.io/apimachinery/pkg/util/intstr"
-	"k8s.io/client-go/kubernetes/fake"
-
-	ossfake "github.com/openshift/client-go/security/clientset/versioned/fake"
-	monfake "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned/fake"
+	clienttesting "k8s.io/client-go/testing"
 )
 
 const (
@@ -194,7 +196,7 @@ func TestCreateOrUpdateDeployment(t *testing.T) {
 			}
 
 			c := Client{
-				kclient: fake.NewSimpleClientset(dep.DeepCopy()),
+				kclient: fakeKube(dep.DeepCopy()),
 			}
 
 			if _, err := c.kclient.AppsV1().Deployments(ns).Get(ctx, dep.Name, metav1.GetOptions{}); err != nil {
@@ -224,6 +226,49 @@ func TestCreateOrUpdateDeployment(t *testing.T) {
 	}
 }
 
+func TestDeleteStaleDeployments(t *testing.T) {
+	ctx := context.Background()
+	newDeployment := func(name string, labels map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns,
+				Labels:    labels,
+			},
+		}
+	}
+	selected := map[string]string{"app.kubernetes.io/name": "foo"}
+
+	c := Client{
+		kclient: fakeKube(
+			newDeployment("foo", selected),
+			newDeployment("foo-shard-0", selected),
+			newDeployment("foo-shard-1", selected),
+			newDeployment("bar", map[string]string{"app.kubernetes.io/name": "bar"}),
+		),
+	}
+
+	keep := []*appsv1.Deployment{newDeployment("foo-shard-0", selected)}
+	if err := c.DeleteStaleDeployments(ctx, ns, "app.kubernetes.io/name=foo", keep); err != nil {
+		t.Fatal(err)
+	}
+
+	deployments, err := c.kclient.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range deployments.Items {
+		got = append(got, d.Name)
+	}
+
+	expected := []string{"bar", "foo-shard-0"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected deployments %v, got %v", expected, got)
+	}
+}
+
 func TestCreateOrUpdateDaemonSet(t *testing.T) {
 	ctx := context.Background()
 	testCases := []struct {
@@ -290,7 +335,7 @@ func TestCreateOrUpdateDaemonSet(t *testing.T) {
 			}
 
 			c := Client{
-				kclient: fake.NewSimpleClientset(ds.DeepCopy()),
+				kclient: fakeKube(ds.DeepCopy()),
 			}
 			if _, err := c.kclient.AppsV1().DaemonSets(ns).Get(ctx, ds.Name, metav1.GetOptions{}); err != nil {
 				t.Fatal(err)
@@ -386,7 +431,7 @@ func TestCreateOrUpdateSecret(t *testing.T) {
 			}
 
 			c := Client{
-				kclient: fake.NewSimpleClientset(s.DeepCopy()),
+				kclient: fakeKube(s.DeepCopy()),
 			}
 
 			if _, err := c.kclient.CoreV1().Secrets(ns).Get(ctx, s.Name, metav1.GetOptions{}); err != nil {
@@ -523,7 +568,7 @@ func TestCreateOrUpdateSecretWithSCOData(t *testing.T) {
 			}
 
 			c := Client{
-				kclient: fake.NewSimpleClientset(s.DeepCopy(), svc),
+				kclient: fakeKube(s.DeepCopy(), svc),
 			}
 
 			if _, err := c.kclient.CoreV1().Secrets(ns).Get(ctx, s.Name, metav1.GetOptions{}); err != nil {
@@ -546,6 +591,188 @@ func TestCreateOrUpdateSecretWithSCOData(t *testing.T) {
 	}
 }
 
+func TestCreateOrUpdateSecretNoop(t *testing.T) {
+	ctx := context.Background()
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: ns,
+			Labels: map[string]string{
+				"app.kubernetes.io/name": "app",
+			},
+		},
+		Data: map[string][]byte{
+			"config.yaml": []byte("foo"),
+		},
+	}
+
+	kclient := fakeKube(s.DeepCopy())
+	c := Client{
+		kclient: kclient,
+	}
+
+	required := s.DeepCopy()
+	required.Data = nil
+	required.StringData = map[string]string{
+		"config.yaml": "foo",
+	}
+	if err := c.CreateOrUpdateSecret(ctx, required); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range kclient.Actions() {
+		if a.GetVerb() == "update" || a.GetVerb() == "patch" {
+			t.Fatalf("expected no update of the unchanged Secret, got %v", a)
+		}
+	}
+}
+
+func TestCreateOrUpdateGeneratedSecret(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name               string
+		initialAnnotations map[string]string
+		initialData        map[string][]byte
+		updatedData        map[string][]byte
+		expectedData       map[string][]byte
+	}{
+		{
+			name: "keep existing generated data",
+			initialData: map[string][]byte{
+				"session_secret": []byte("old"),
+				"token":          []byte("old"),
+			},
+			updatedData: map[string][]byte{
+				"session_secret": []byte("new"),
+				"token":          []byte("new"),
+			},
+			expectedData: map[string][]byte{
+				"session_secret": []byte("old"),
+				"token":          []byte("new"),
+			},
+		},
+		{
+			name: "generate missing data",
+			initialData: map[string][]byte{
+				"session_secret": []byte(""),
+			},
+			updatedData: map[string][]byte{
+				"session_secret": []byte("new"),
+			},
+			expectedData: map[string][]byte{
+				"session_secret": []byte("new"),
+			},
+		},
+		{
+			name: "rotate generated data",
+			initialAnnotations: map[string]string{
+				RotateSecretAnnotation: "true",
+			},
+			initialData: map[string][]byte{
+				"session_secret": []byte("old"),
+			},
+			updatedData: map[string][]byte{
+				"session_secret": []byte("new"),
+			},
+			expectedData: map[string][]byte{
+				"session_secret": []byte("new"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "secret",
+					Namespace:   ns,
+					Annotations: tc.initialAnnotations,
+				},
+				Data: tc.initialData,
+			}
+
+			c := Client{
+				kclient: fakeKube(s.DeepCopy()),
+			}
+
+			s.Annotations = nil
+			s.Data = tc.updatedData
+			if err := c.CreateOrUpdateGeneratedSecret(ctx, s, "session_secret"); err != nil {
+				t.Fatal(err)
+			}
+			after, err := c.kclient.CoreV1().Secrets(ns).Get(ctx, s.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.expectedData, after.Data) {
+				t.Errorf("expected data %q, got %q", tc.expectedData, after.Data)
+			}
+			if _, found := after.Annotations[RotateSecretAnnotation]; found {
+				t.Errorf("expected the %q annotation to be removed", RotateSecretAnnotation)
+			}
+		})
+	}
+}
+
+func TestCreateOrUpdateHtpasswdSecret(t *testing.T) {
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := []byte("internal:" + string(hash))
+
+	for _, tc := range []struct {
+		name     string
+		password string
+		keep     bool
+	}{
+		{
+			name:     "same password",
+			password: "password",
+			keep:     true,
+		},
+		{
+			name:     "new password",
+			password: "other",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "secret",
+					Namespace: ns,
+				},
+				Data: map[string][]byte{
+					"auth": existing,
+				},
+			}
+
+			c := Client{
+				kclient: fakeKube(s.DeepCopy()),
+			}
+
+			hash, err := bcrypt.GenerateFromPassword([]byte(tc.password), bcrypt.MinCost)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Data["auth"] = []byte("internal:" + string(hash))
+			if err := c.CreateOrUpdateHtpasswdSecret(ctx, s, tc.password); err != nil {
+				t.Fatal(err)
+			}
+			after, err := c.kclient.CoreV1().Secrets(ns).Get(ctx, s.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if kept := bytes.Equal(after.Data["auth"], existing); kept != tc.keep {
+				t.Errorf("expected existing hash kept to be %v, got %v", tc.keep, kept)
+			}
+		})
+	}
+}
+
 func TestCreateOrUpdateConfigMap(t *testing.T) {
 	ctx := context.Background()
 	testCases := []struct {
@@ -658,7 +885,7 @@ func TestCreateOrUpdateConfigMap(t *testing.T) {
 			}
 
 			c := Client{
-				kclient: fake.NewSimpleClientset(cm),
+				kclient: fakeKube(cm),
 			}
 
 			if _, err := c.kclient.CoreV1().ConfigMaps(ns).Get(ctx, cm.Name, metav1.GetOptions{}); err != nil {
@@ -683,7 +910,9 @@ func TestCreateOrUpdateConfigMap(t *testing.T) {
 			if !reflect.DeepEqual(tc.expectedLabels, after.Labels) {
 				t.Errorf("expected labels %q, got %q", tc.expectedLabels, after.Labels)
 			}
-			if !reflect.DeepEqual(tc.expectedData, after.Data) {
+			// The API server doesn't tell empty data apart from missing
+			// data.
+			if !equality.Semantic.DeepEqual(tc.expectedData, after.Data) {
 				t.Errorf("%q: expected data %q, got %q", tc.name, tc.expectedData, after.Data)
 			}
 		})
@@ -804,7 +1033,7 @@ func TestCreateOrUpdateService(t *testing.T) {
 			}
 
 			c := Client{
-				kclient: fake.NewSimpleClientset(svc.DeepCopy()),
+				kclient: fakeKube(svc.DeepCopy()),
 			}
 
 			before, err := c.kclient.CoreV1().Services(ns).Get(ctx, svc.Name, metav1.GetOptions{})
@@ -903,7 +1132,7 @@ func TestCreateOrUpdateRole(t *testing.T) {
 				},
 			}
 			c := Client{
-				kclient: fake.NewSimpleClientset(role.DeepCopy()),
+				kclient: fakeKube(role.DeepCopy()),
 			}
 			if _, err := c.kclient.RbacV1().Roles(ns).Get(ctx, role.Name, metav1.GetOptions{}); err != nil {
 				t.Fatal(err)
@@ -1040,7 +1269,7 @@ func TestCreateOrUpdateRoleBinding(t *testing.T) {
 				Subjects: tc.initialSubjects,
 			}
 			c := Client{
-				kclient: fake.NewSimpleClientset(roleBinding.DeepCopy()),
+				kclient: fakeKube(roleBinding.DeepCopy()),
 			}
 			before, err := c.kclient.RbacV1().RoleBindings(ns).Get(ctx, roleBinding.Name, metav1.GetOptions{})
 			if err != nil {
@@ -1138,7 +1367,7 @@ func TestCreateOrUpdateClusterRole(t *testing.T) {
 				},
 			}
 			c := Client{
-				kclient: fake.NewSimpleClientset(clusterRole.DeepCopy()),
+				kclient: fakeKube(clusterRole.DeepCopy()),
 			}
 			if _, err := c.kclient.RbacV1().ClusterRoles().Get(ctx, clusterRole.Name, metav1.GetOptions{}); err != nil {
 				t.Fatal(err)
@@ -1275,7 +1504,7 @@ func TestCreateOrUpdateClusterRoleBinding(t *testing.T) {
 			}
 
 			c := Client{
-				kclient: fake.NewSimpleClientset(clusterRoleBinding.DeepCopy()),
+				kclient: fakeKube(clusterRoleBinding.DeepCopy()),
 			}
 			before, err := c.kclient.RbacV1().ClusterRoleBindings().Get(ctx, clusterRoleBinding.Name, metav1.GetOptions{})
 			if err != nil {
@@ -1373,7 +1602,7 @@ func TestCreateOrUpdateSecurityContextConstraints(t *testing.T) {
 			}
 
 			c := Client{
-				ossclient: ossfake.NewSimpleClientset(),
+				ossclient: fakeSecurity(),
 			}
 			c.ossclient.SecurityV1().SecurityContextConstraints().Create(ctx, scc.DeepCopy(), metav1.CreateOptions{})
 
@@ -1468,7 +1697,7 @@ func TestCreateOrUpdateServiceMonitor(t *testing.T) {
 			}
 
 			c := Client{
-				mclient: monfake.NewSimpleClientset(serviceMonitor.DeepCopy()),
+				mclient: fakeMonitoring(serviceMonitor.DeepCopy()),
 			}
 			if _, err := c.mclient.MonitoringV1().ServiceMonitors(ns).Get(ctx, serviceMonitor.GetName(), metav1.GetOptions{}); err != nil {
 				t.Fatal(err)
@@ -1560,7 +1789,7 @@ func TestCreateOrUpdatePrometheusRule(t *testing.T) {
 			}
 
 			c := Client{
-				mclient: monfake.NewSimpleClientset(rule.DeepCopy()),
+				mclient: fakeMonitoring(rule.DeepCopy()),
 			}
 			if _, err := c.mclient.MonitoringV1().PrometheusRules(ns).Get(ctx, rule.GetName(), metav1.GetOptions{}); err != nil {
 				t.Fatal(err)
@@ -1652,7 +1881,7 @@ func TestCreateOrUpdatePrometheus(t *testing.T) {
 			}
 
 			c := Client{
-				mclient: monfake.NewSimpleClientset(prometheus.DeepCopy()),
+				mclient: fakeMonitoring(prometheus.DeepCopy()),
 			}
 			if _, err := c.mclient.MonitoringV1().Prometheuses(ns).Get(ctx, prometheus.GetName(), metav1.GetOptions{}); err != nil {
 				t.Fatal(err)
@@ -1679,6 +1908,42 @@ func TestCreateOrUpdatePrometheus(t *testing.T) {
 	}
 }
 
+func TestCreateOrUpdatePrometheusConflict(t *testing.T) {
+	ctx := context.Background()
+	prometheus := &monv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8s",
+			Namespace: ns,
+		},
+	}
+
+	mclient := fakeMonitoring(prometheus.DeepCopy())
+	// Simulate a concurrent modification of the object between the get and
+	// apply calls.
+	var conflicts int
+	mclient.PrependReactor("patch", "prometheuses", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			return false, nil, nil
+		}
+		conflicts++
+		return true, nil, apierrors.NewConflict(monv1.Resource(monv1.PrometheusName), prometheus.Name, errors.New("object modified"))
+	})
+
+	c := Client{mclient: mclient}
+	prometheus.Spec.Retention = "1d"
+	if err := c.CreateOrUpdatePrometheus(ctx, prometheus); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := mclient.MonitoringV1().Prometheuses(ns).Get(ctx, prometheus.GetName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Spec.Retention != "1d" {
+		t.Fatalf("expected retention %q, got %q", "1d", after.Spec.Retention)
+	}
+}
+
 func TestCreateOrUpdateAlertmanager(t *testing.T) {
 	ctx := context.Background()
 	testCases := []struct {
@@ -1745,7 +2010,7 @@ func TestCreateOrUpdateAlertmanager(t *testing.T) {
 			}
 
 			c := Client{
-				mclient: monfake.NewSimpleClientset(alertmanager.DeepCopy()),
+				mclient: fakeMonitoring(alertmanager.DeepCopy()),
 			}
 			if _, err := c.mclient.MonitoringV1().Alertmanagers(ns).Get(ctx, alertmanager.GetName(), metav1.GetOptions{}); err != nil {
 				t.Fatal(err)
@@ -1789,10 +2054,10 @@ func TestCreateOrUpdateValidatingWebhookConfiguration(t *testing.T) {
 			expectedCABundle: []byte("fooCA"),
 		},
 		{
-			name:               "drop CA bundle of annotation is missing",
+			name:               "keep CA bundle not applied by the operator if annotation is missing",
 			initialAnnotations: map[string]string{},
 			initialCABundle:    []byte("fooCA"),
-			expectedCABundle:   []byte(""),
+			expectedCABundle:   []byte("fooCA"),
 		},
 	}
 
@@ -1813,7 +2078,7 @@ func TestCreateOrUpdateValidatingWebhookConfiguration(t *testing.T) {
 			}
 
 			c := Client{
-				kclient: fake.NewSimpleClientset(webhook.DeepCopy()),
+				kclient: fakeKube(webhook.DeepCopy()),
 			}
 
 			if _, err := c.kclient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, webhook.Name, metav1.GetOptions{}); err != nil {
@@ -0,0 +1,114 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ClusterMonitoringStatus reports the availability of the main components of
+// the monitoring stack. It is shown by `oc get clustermonitoring`.
+type ClusterMonitoringStatus struct {
+	// Prometheus is the availability of the platform Prometheus.
+	Prometheus ComponentAvailability `json:"prometheus"`
+	// Alertmanager is the availability of the platform Alertmanager.
+	Alertmanager ComponentAvailability `json:"alertmanager"`
+	// ThanosQuerier is the availability of Thanos Querier.
+	ThanosQuerier ComponentAvailability `json:"thanosQuerier"`
+	// UserWorkload is the availability of the user workload monitoring
+	// stack: its Prometheus Operator, Prometheus and Thanos Ruler.
+	UserWorkload ComponentAvailability `json:"userWorkload"`
+}
+
+// ComponentAvailability is the outcome of the last reconciliation of a
+// component.
+type ComponentAvailability struct {
+	// Available is "True" when the last reconciliation of the component
+	// succeeded and "False" when it failed.
+	Available metav1.ConditionStatus `json:"available,omitempty"`
+	// Message explains why the last reconciliation of the component failed.
+	Message string `json:"message,omitempty"`
+}
+
+// statusComponents maps the components reconciled by the operator to the
+// field of the ClusterMonitoring status reporting them.
+var statusComponents = map[string]func(*ClusterMonitoringStatus) *ComponentAvailability{
+	"Prometheus-k8s":                    func(s *ClusterMonitoringStatus) *ComponentAvailability { return &s.Prometheus },
+	"Alertmanager":                      func(s *ClusterMonitoringStatus) *ComponentAvailability { return &s.Alertmanager },
+	"Thanos Querier":                    func(s *ClusterMonitoringStatus) *ComponentAvailability { return &s.ThanosQuerier },
+	"user workload Prometheus Operator": func(s *ClusterMonitoringStatus) *ComponentAvailability { return &s.UserWorkload },
+	"Prometheus-user-workload":          func(s *ClusterMonitoringStatus) *ComponentAvailability { return &s.UserWorkload },
+	"User Workload Thanos Ruler":        func(s *ClusterMonitoringStatus) *ComponentAvailability { return &s.UserWorkload },
+}
+
+// Update sets the availability of the components from the results of their
+// reconciliation. The components without result (e.g. skipped because their
+// configuration didn't change) keep their availability.
+func (s *ClusterMonitoringStatus) Update(results []ComponentResult) {
+	updated := map[*ComponentAvailability]struct{}{}
+	for _, res := range results {
+		field, found := statusComponents[res.Component]
+		if !found {
+			continue
+		}
+
+		a := field(s)
+		if _, found := updated[a]; !found {
+			*a = ComponentAvailability{Available: metav1.ConditionTrue}
+			updated[a] = struct{}{}
+		}
+		if res.Err == nil {
+			continue
+		}
+
+		msg := componentFailure(res.Component, res.Err)
+		if a.Available == metav1.ConditionFalse {
+			msg = a.Message + "; " + msg
+		}
+		*a = ComponentAvailability{Available: metav1.ConditionFalse, Message: msg}
+	}
+}
+
+// UpdateClusterMonitoringStatus updates the status of the ClusterMonitoring
+// resource with the results of the reconciliation of the components. The
+// status isn't written when it doesn't change.
+func (c *Client) UpdateClusterMonitoringStatus(ctx context.Context, cr *unstructured.Unstructured, results []ComponentResult) error {
+	var current ClusterMonitoringStatus
+	if status, found, _ := unstructured.NestedMap(cr.Object, "status"); found {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(status, &current); err != nil {
+			return err
+		}
+	}
+
+	updated := current
+	updated.Update(results)
+	if reflect.DeepEqual(updated, current) {
+		return nil
+	}
+
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&updated)
+	if err != nil {
+		return err
+	}
+	obj := cr.DeepCopy()
+	obj.Object["status"] = status
+	_, err = c.dclient.Resource(ClusterMonitoringGVR).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func TestClusterMonitoringStatusUpdate(t *testing.T) {
+	status := ClusterMonitoringStatus{
+		ThanosQuerier: ComponentAvailability{Available: metav1.ConditionFalse, Message: "failed"},
+	}
+	status.Update([]ComponentResult{
+		{Component: "Prometheus-k8s"},
+		{Component: "Alertmanager", Err: errors.New("timeout")},
+		{Component: "Prometheus-user-workload"},
+		{Component: "User Workload Thanos Ruler", Err: errors.New("timeout")},
+		{Component: "node-exporter", Err: errors.New("timeout")},
+	})
+
+	expected := ClusterMonitoringStatus{
+		Prometheus:   ComponentAvailability{Available: metav1.ConditionTrue},
+		Alertmanager: ComponentAvailability{Available: metav1.ConditionFalse, Message: "Alertmanager: timeout"},
+		// Thanos Querier wasn't reconciled.
+		ThanosQuerier: ComponentAvailability{Available: metav1.ConditionFalse, Message: "failed"},
+		UserWorkload:  ComponentAvailability{Available: metav1.ConditionFalse, Message: "User Workload Thanos Ruler: timeout"},
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, status)
+	}
+}
+
+func TestUpdateClusterMonitoringStatus(t *testing.T) {
+	ctx := context.Background()
+	cr := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "monitoring.openshift.io/v1alpha1",
+			"kind":       "ClusterMonitoring",
+			"metadata": map[string]interface{}{
+				"name": ClusterMonitoringName,
+			},
+		},
+	}
+	dclient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), cr.DeepCopy())
+	c := Client{dclient: dclient}
+
+	results := []ComponentResult{{Component: "Prometheus-k8s"}, {Component: "Thanos Querier", Err: errors.New("timeout")}}
+	if err := c.UpdateClusterMonitoringStatus(ctx, cr, results); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := dclient.Resource(ClusterMonitoringGVR).Get(ctx, ClusterMonitoringName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{
+		"prometheus.available":    "True",
+		"thanosQuerier.available": "False",
+		"thanosQuerier.message":   "Thanos Querier: timeout",
+	} {
+		got, _, _ := unstructured.NestedString(updated.Object, append([]string{"status"}, strings.Split(path, ".")...)...)
+		if got != expected {
+			t.Errorf("expected status.%s to be %q, got %q", path, expected, got)
+		}
+	}
+
+	// The status isn't written again when it doesn't change.
+	dclient.ClearActions()
+	if err := c.UpdateClusterMonitoringStatus(ctx, updated, results); err != nil {
+		t.Fatal(err)
+	}
+	if actions := dclient.Actions(); len(actions) != 0 {
+		t.Fatalf("expected no request, got %v", actions)
+	}
+}
+
+func TestClusterMonitoringCRDStatus(t *testing.T) {
+	b, err := ioutil.ReadFile("../../manifests/0000_50_cluster-monitoring-operator_00_1clustermonitoring-custom-resource-definition.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := yaml.Unmarshal(b, &crd); err != nil {
+		t.Fatal(err)
+	}
+	if len(crd.Spec.Versions) != 1 {
+		t.Fatalf("expected 1 version, got %d", len(crd.Spec.Versions))
+	}
+	version := crd.Spec.Versions[0]
+
+	if version.Subresources == nil || version.Subresources.Status == nil {
+		t.Fatal("expected the status subresource to be enabled")
+	}
+	schema, found := version.Schema.OpenAPIV3Schema.Properties["status"]
+	if !found {
+		t.Fatal("expected a status schema")
+	}
+
+	// Every printer column of the status must point to a field written by
+	// the operator and described by the schema.
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&ClusterMonitoringStatus{
+		Prometheus:    ComponentAvailability{Available: metav1.ConditionTrue},
+		Alertmanager:  ComponentAvailability{Available: metav1.ConditionTrue},
+		ThanosQuerier: ComponentAvailability{Available: metav1.ConditionTrue},
+		UserWorkload:  ComponentAvailability{Available: metav1.ConditionTrue},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var columns int
+	for _, col := range version.AdditionalPrinterColumns {
+		if !strings.HasPrefix(col.JSONPath, ".status.") {
+			continue
+		}
+		columns++
+
+		fields := strings.Split(strings.TrimPrefix(col.JSONPath, ".status."), ".")
+		if _, found, _ := unstructured.NestedString(status, fields...); !found {
+			t.Errorf("column %s: %s isn't written by the operator", col.Name, col.JSONPath)
+		}
+		s := schema
+		for _, f := range fields {
+			if s, found = s.Properties[f]; !found {
+				t.Errorf("column %s: %s isn't in the status schema", col.Name, col.JSONPath)
+				break
+			}
+		}
+	}
+	if columns != 4 {
+		t.Fatalf("expected 4 status columns, got %d", columns)
+	}
+}
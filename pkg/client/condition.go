@@ -102,6 +102,12 @@ func (cs *conditions) setCondition(condition v1.ClusterStatusConditionType, stat
 	cs.entryMap = entries
 }
 
+func (cs *conditions) setConditions(conditions []Condition, time metav1.Time) {
+	for _, c := range conditions {
+		cs.setCondition(c.Type, c.Status, c.Message, c.Reason, time)
+	}
+}
+
 func (cs *conditions) entries() []v1.ClusterOperatorStatusCondition {
 	var res []v1.ClusterOperatorStatusCondition
 	for _, v := range cs.entryMap {
@@ -0,0 +1,118 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// generationTracker remembers the generation of the workloads updated by the
+// client. The API server increments the generation of an object on every
+// change of its spec: when the generation found before an update differs
+// from the one returned by the previous update, another actor (a user, a
+// GitOps tool, ...) modified the spec in between and the operator is
+// reverting the modification. The modified objects are remembered per task.
+type generationTracker struct {
+	mtx         sync.Mutex
+	generations map[string]int64
+	modified    map[string]map[string]struct{}
+}
+
+func newGenerationTracker() *generationTracker {
+	return &generationTracker{
+		generations: map[string]int64{},
+		modified:    map[string]map[string]struct{}{},
+	}
+}
+
+func (g *generationTracker) track(task, kind string, existing, updated metav1.Object) {
+	if g == nil {
+		return
+	}
+
+	key := fmt.Sprintf("%s %s/%s", kind, updated.GetNamespace(), updated.GetName())
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	// The modifications of unmanaged objects are expected and aren't
+	// reverted by the operator.
+	if last, found := g.generations[key]; found && last != existing.GetGeneration() && !isUnmanaged(updated) {
+		if g.modified[task] == nil {
+			g.modified[task] = map[string]struct{}{}
+		}
+		g.modified[task][key] = struct{}{}
+	}
+	g.generations[key] = updated.GetGeneration()
+}
+
+func (g *generationTracker) take() map[string][]string {
+	if g == nil {
+		return nil
+	}
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	ret := make(map[string][]string, len(g.modified))
+	for task, modified := range g.modified {
+		for key := range modified {
+			ret[task] = append(ret[task], key)
+		}
+		sort.Strings(ret[task])
+	}
+	g.modified = map[string]map[string]struct{}{}
+
+	return ret
+}
+
+// TakeModifiedObjects returns the workloads (e.g. "Prometheus
+// openshift-monitoring/k8s") whose spec was modified outside of the operator
+// since the operator last updated them, grouped by the task which updated
+// them (see WithTask), and forgets them. The objects updated only once
+// since the operator started are never reported.
+func (c *Client) TakeModifiedObjects() map[string][]string {
+	return c.generations.take()
+}
+
+func (c *Client) trackGeneration(ctx context.Context, kind string, existing, updated metav1.Object) {
+	c.generations.track(taskFromContext(ctx), kind, existing, updated)
+}
+
+// AppliedGeneration returns true when the generation of the workload is the
+// one returned by the last update of the client. It tells the changes
+// applied by the operator apart from the changes made by other actors.
+func (c *Client) AppliedGeneration(kind string, obj metav1.Object) bool {
+	return c.generations.applied(kind, obj)
+}
+
+func (g *generationTracker) applied(kind string, obj metav1.Object) bool {
+	if g == nil {
+		return false
+	}
+
+	key := fmt.Sprintf("%s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	last, found := g.generations[key]
+	return found && last == obj.GetGeneration()
+}
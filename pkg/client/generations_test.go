@@ -0,0 +1,83 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGenerationTracker(t *testing.T) {
+	obj := func(name string, generation int64) *metav1.ObjectMeta {
+		return &metav1.ObjectMeta{Namespace: "openshift-monitoring", Name: name, Generation: generation}
+	}
+
+	g := newGenerationTracker()
+
+	// The first update can't tell whether the object was modified.
+	g.track("Updating Prometheus-k8s", "Prometheus", obj("k8s", 3), obj("k8s", 4))
+	g.track("Updating Alertmanager", "Alertmanager", obj("main", 1), obj("main", 1))
+	if got := g.take(); len(got) != 0 {
+		t.Fatalf("expected no modified object, got %v", got)
+	}
+
+	// The generation is unchanged since the previous update.
+	g.track("Updating Prometheus-k8s", "Prometheus", obj("k8s", 4), obj("k8s", 5))
+	// The spec was modified by another actor since the previous update.
+	g.track("Updating Alertmanager", "Alertmanager", obj("main", 2), obj("main", 3))
+	expected := map[string][]string{"Updating Alertmanager": {"Alertmanager openshift-monitoring/main"}}
+	if got := g.take(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	if got := g.take(); len(got) != 0 {
+		t.Fatalf("expected the modified objects to be reset, got %v", got)
+	}
+
+	var nilTracker *generationTracker
+	nilTracker.track("", "Prometheus", obj("k8s", 1), obj("k8s", 2))
+	if got := nilTracker.take(); got != nil {
+		t.Fatalf("expected nil, got %v", got)
+	}
+}
+
+func TestGenerationTrackerApplied(t *testing.T) {
+	obj := func(generation int64) *metav1.ObjectMeta {
+		return &metav1.ObjectMeta{Namespace: "openshift-monitoring", Name: "k8s", Generation: generation}
+	}
+
+	g := newGenerationTracker()
+	if g.applied("Prometheus", obj(1)) {
+		t.Fatal("expected an untracked object not to be applied")
+	}
+
+	g.track("", "Prometheus", obj(1), obj(2))
+	if !g.applied("Prometheus", obj(2)) {
+		t.Fatal("expected the generation returned by the update to be applied")
+	}
+	if g.applied("Prometheus", obj(3)) {
+		t.Fatal("expected a newer generation not to be applied")
+	}
+	if g.applied("Alertmanager", obj(2)) {
+		t.Fatal("expected another kind not to be applied")
+	}
+
+	var nilTracker *generationTracker
+	if nilTracker.applied("Prometheus", obj(2)) {
+		t.Fatal("expected false for a nil tracker")
+	}
+}
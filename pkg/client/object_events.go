@@ -0,0 +1,128 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+type configHashKey struct{}
+
+// WithConfigHash returns a context carrying the hash of the configuration
+// being reconciled. The hash is reported in the events attached to the
+// updated objects.
+func WithConfigHash(ctx context.Context, hash string) context.Context {
+	return context.WithValue(ctx, configHashKey{}, hash)
+}
+
+func configHashFromContext(ctx context.Context) string {
+	hash, _ := ctx.Value(configHashKey{}).(string)
+	return hash
+}
+
+// ObjectEventRecorder sets the recorder used to attach events to the
+// objects updated by the client.
+func ObjectEventRecorder(r record.EventRecorder) Option {
+	return func(c *Client) {
+		c.objectRecorder = r
+	}
+}
+
+// NewObjectEventRecorder returns a recorder emitting the events in the
+// namespace of the involved objects.
+func NewObjectEventRecorder(kclient kubernetes.Interface) record.EventRecorder {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(monv1.AddToScheme(scheme))
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kclient.CoreV1().Events("")})
+
+	return broadcaster.NewRecorder(scheme, v1.EventSource{Component: "cluster-monitoring-operator"})
+}
+
+// RecordObjectWarning attaches a warning event to the given object, in its
+// namespace.
+func (c *Client) RecordObjectWarning(obj runtime.Object, reason, messageFmt string, args ...interface{}) {
+	if c.objectRecorder == nil {
+		return
+	}
+	c.objectRecorder.Eventf(obj, v1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// recordUpdate attaches an event to the updated object listing the spec
+// fields modified by the update.
+func (c *Client) recordUpdate(ctx context.Context, updated runtime.Object, oldSpec, newSpec interface{}) {
+	if c.objectRecorder == nil {
+		return
+	}
+
+	fields, err := changedFields(oldSpec, newSpec)
+	if err != nil {
+		klog.V(4).Infof("failed to compute the changed fields: %v", err)
+		return
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	msg := fmt.Sprintf("Updated spec fields: %s", strings.Join(fields, ", "))
+	if hash := configHashFromContext(ctx); hash != "" {
+		msg = fmt.Sprintf("%s (config hash %s)", msg, hash)
+	}
+	c.objectRecorder.Event(updated, v1.EventTypeNormal, "SpecUpdated", msg)
+}
+
+// changedFields returns the sorted list of top-level fields which differ
+// between the two specs. The specs must be pointers to structs.
+func changedFields(oldSpec, newSpec interface{}) ([]string, error) {
+	o, err := runtime.DefaultUnstructuredConverter.ToUnstructured(oldSpec)
+	if err != nil {
+		return nil, err
+	}
+	n, err := runtime.DefaultUnstructuredConverter.ToUnstructured(newSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for k, v := range n {
+		if !reflect.DeepEqual(o[k], v) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range o {
+		if _, found := n[k]; !found {
+			fields = append(fields, k)
+		}
+	}
+
+	sort.Strings(fields)
+	return fields, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestUpdateRecordsObjectEvent(t *testing.T) {
+	replicas := int32(2)
+	prometheus := &monv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8s",
+			Namespace: ns,
+		},
+		Spec: monv1.PrometheusSpec{
+			Replicas:  &replicas,
+			Retention: "15d",
+		},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		ctx    context.Context
+		update func(*monv1.Prometheus)
+		event  string
+	}{
+		{
+			name:   "no change",
+			ctx:    context.Background(),
+			update: func(*monv1.Prometheus) {},
+		},
+		{
+			name: "changed fields",
+			ctx:  context.Background(),
+			update: func(p *monv1.Prometheus) {
+				p.Spec.Retention = "1d"
+				p.Spec.LogLevel = "debug"
+			},
+			event: "Normal SpecUpdated Updated spec fields: logLevel, retention",
+		},
+		{
+			name: "changed fields with config hash",
+			ctx:  WithConfigHash(context.Background(), "abc"),
+			update: func(p *monv1.Prometheus) {
+				p.Spec.Replicas = nil
+			},
+			event: "Normal SpecUpdated Updated spec fields: replicas (config hash abc)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			c := Client{
+				mclient:        fakeMonitoring(),
+				objectRecorder: recorder,
+			}
+			if err := c.CreateOrUpdatePrometheus(tc.ctx, prometheus.DeepCopy()); err != nil {
+				t.Fatal(err)
+			}
+
+			p := prometheus.DeepCopy()
+			tc.update(p)
+			if err := c.CreateOrUpdatePrometheus(tc.ctx, p); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case e := <-recorder.Events:
+				if e != tc.event {
+					t.Fatalf("expected event %q, got %q", tc.event, e)
+				}
+			default:
+				if tc.event != "" {
+					t.Fatalf("expected event %q, got none", tc.event)
+				}
+			}
+		})
+	}
+}
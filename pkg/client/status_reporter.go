@@ -16,14 +16,21 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	cmostr "github.com/openshift/cluster-monitoring-operator/pkg/strings"
 
 	v1 "github.com/openshift/api/config/v1"
 	clientv1 "github.com/openshift/client-go/config/clientset/versioned/typed/config/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
 )
 
 const (
@@ -31,14 +38,64 @@ const (
 	asExpectedReason            string = "AsExpected"
 	StorageNotConfiguredMessage        = "Prometheus is running without persistent storage which can lead to data loss during upgrades and cluster disruptions. Please refer to the official documentation to see how to configure storage for Prometheus: https://docs.openshift.com/container-platform/4.8/monitoring/configuring-the-monitoring-stack.html"
 	StorageNotConfiguredReason         = "PrometheusDataPersistenceNotConfigured"
+
+	// statusUpdateInterval is the minimum time between two status updates
+	// which only change the messages or reasons of the conditions.
+	statusUpdateInterval = time.Minute
+
+	// NotAvailableFeatures is an informational condition listing the
+	// optional features which the operator skipped.
+	NotAvailableFeatures v1.ClusterStatusConditionType = "NotAvailableFeatures"
+
+	// DisabledComponents is an informational condition listing the
+	// optional components disabled by the configuration.
+	DisabledComponents v1.ClusterStatusConditionType = "DisabledComponents"
+
+	// NamespacesOverQuota is an informational condition listing the user
+	// namespaces with monitors requesting more than their scrape budget.
+	NamespacesOverQuota v1.ClusterStatusConditionType = "NamespacesOverQuota"
+
+	// StorageClassDrift is an informational condition listing the
+	// persistent volume claims provisioned with another storage class than
+	// the configured or default one.
+	StorageClassDrift v1.ClusterStatusConditionType = "StorageClassDrift"
+
+	// ExcludedRules is an informational condition listing the platform
+	// alerting rules and rule groups excluded by the configuration.
+	ExcludedRules v1.ClusterStatusConditionType = "ExcludedRules"
+
+	// UnknownFields is an informational condition listing the fields of
+	// the configuration ConfigMaps unknown to the operator.
+	UnknownFields v1.ClusterStatusConditionType = "UnknownFields"
+
+	// ClockSkew is an informational condition listing the nodes whose clock
+	// is skewed from the clock of Prometheus.
+	ClockSkew v1.ClusterStatusConditionType = "ClockSkew"
+
+	// UnmanagedDrift is an informational condition listing the unmanaged
+	// objects which drifted from their desired state.
+	UnmanagedDrift v1.ClusterStatusConditionType = "UnmanagedDrift"
+
+	// TeardownProgressing is an informational condition listing the
+	// removed components whose objects are still being deleted.
+	TeardownProgressing v1.ClusterStatusConditionType = "TeardownProgressing"
 )
 
+// StatusReporter updates the status of the ClusterOperator. Updates which
+// don't change anything are skipped and updates which only change the
+// messages or reasons of the conditions are applied at most once per
+// interval to avoid thrashing the object when the reconciliation flaps.
 type StatusReporter struct {
 	client                clientv1.ClusterOperatorInterface
 	clusterOperatorName   string
 	namespace             string
 	userWorkloadNamespace string
 	version               string
+
+	mtx            sync.Mutex
+	updateInterval time.Duration
+	lastUpdate     time.Time
+	now            func() time.Time
 }
 
 func NewStatusReporter(client clientv1.ClusterOperatorInterface, name, namespace, userWorkloadNamespace, version string) *StatusReporter {
@@ -48,6 +105,8 @@ func NewStatusReporter(client clientv1.ClusterOperatorInterface, name, namespace
 		namespace:             namespace,
 		userWorkloadNamespace: userWorkloadNamespace,
 		version:               version,
+		updateInterval:        statusUpdateInterval,
+		now:                   time.Now,
 	}
 }
 
@@ -107,14 +166,93 @@ func (r *StatusReporter) newClusterOperator() *v1.ClusterOperator {
 }
 
 func (r *StatusReporter) setConditions(ctx context.Context, co *v1.ClusterOperator, conditions *conditions) error {
-	co.Status.Conditions = conditions.entries()
-	co.Status.RelatedObjects = r.relatedObjects()
+	return r.updateStatus(ctx, co, conditions, co.Status.Versions)
+}
+
+func (r *StatusReporter) updateStatus(ctx context.Context, co *v1.ClusterOperator, conditions *conditions, versions []v1.OperandVersion) error {
+	status := *co.Status.DeepCopy()
+	status.Conditions = conditions.entries()
+	status.RelatedObjects = r.relatedObjects()
+	status.Versions = versions
+	sort.Sort(byType(status.Conditions))
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
 
-	_, err := r.client.UpdateStatus(ctx, co, metav1.UpdateOptions{})
-	return err
+	now := r.now()
+	if !r.needsUpdate(co.Status, status, now) {
+		return nil
+	}
+
+	co.Status = status
+	if _, err := r.client.UpdateStatus(ctx, co, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+
+	r.lastUpdate = now
+	return nil
 }
 
-func (r *StatusReporter) SetRollOutDone(ctx context.Context, degradedConditionMessage string, degradedConditionReason string) error {
+// needsUpdate returns whether the current status should be replaced by the
+// desired one.
+func (r *StatusReporter) needsUpdate(current, desired v1.ClusterOperatorStatus, now time.Time) bool {
+	current = *current.DeepCopy()
+	sort.Sort(byType(current.Conditions))
+
+	if equality.Semantic.DeepEqual(current, desired) {
+		klog.V(4).Info("Skipping the ClusterOperator status update: no change")
+		return false
+	}
+
+	if conditionStatusChanged(current.Conditions, desired.Conditions) ||
+		!equality.Semantic.DeepEqual(current.Versions, desired.Versions) ||
+		!equality.Semantic.DeepEqual(current.RelatedObjects, desired.RelatedObjects) {
+		return true
+	}
+
+	if now.Sub(r.lastUpdate) < r.updateInterval {
+		klog.V(4).Infof("Skipping the ClusterOperator status update: only the condition messages changed since the last update at %s", r.lastUpdate.Format(time.RFC3339))
+		return false
+	}
+
+	return true
+}
+
+// conditionStatusChanged returns true if a condition was added, removed or
+// has a different status.
+func conditionStatusChanged(current, desired []v1.ClusterOperatorStatusCondition) bool {
+	if len(current) != len(desired) {
+		return true
+	}
+
+	statuses := make(map[v1.ClusterStatusConditionType]v1.ConditionStatus, len(current))
+	for _, c := range current {
+		statuses[c.Type] = c.Status
+	}
+
+	for _, c := range desired {
+		if s, found := statuses[c.Type]; !found || s != c.Status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetRollOutDone reports the stack as rolled out and available. The other
+// given conditions are set in the same status update.
+func (r *StatusReporter) SetRollOutDone(ctx context.Context, degradedConditionMessage string, degradedConditionReason string, others ...Condition) error {
+	return r.setRollOutDone(ctx, v1.ConditionFalse, degradedConditionMessage, degradedConditionReason, others)
+}
+
+// SetRollOutDoneDegraded reports the stack as rolled out and available while
+// a part of it is degraded by a state which the operator can't fix on its
+// own, e.g. the user workload monitoring namespace being deleted.
+func (r *StatusReporter) SetRollOutDoneDegraded(ctx context.Context, degradedConditionMessage string, degradedConditionReason string, others ...Condition) error {
+	return r.setRollOutDone(ctx, v1.ConditionTrue, degradedConditionMessage, degradedConditionReason, others)
+}
+
+func (r *StatusReporter) setRollOutDone(ctx context.Context, degraded v1.ConditionStatus, degradedConditionMessage string, degradedConditionReason string, others []Condition) error {
 	co, err := r.getOrCreateClusterOperator(ctx)
 	if err != nil {
 		return err
@@ -122,25 +260,25 @@ func (r *StatusReporter) SetRollOutDone(ctx context.Context, degradedConditionMe
 
 	time := metav1.Now()
 	conditions := newConditions(co.Status, r.version, time)
+	conditions.setConditions(others, time)
 	conditions.setCondition(v1.OperatorAvailable, v1.ConditionTrue, "Successfully rolled out the stack.", "RollOutDone", time)
 	conditions.setCondition(v1.OperatorProgressing, v1.ConditionFalse, "", "", time)
-	conditions.setCondition(v1.OperatorDegraded, v1.ConditionFalse, degradedConditionMessage, degradedConditionReason, time)
+	conditions.setCondition(v1.OperatorDegraded, degraded, degradedConditionMessage, degradedConditionReason, time)
 
 	// If we have reached "level" for the operator, report that we are at the version
 	// injected into us during update. We require that all components be rolled out
 	// and available at the new version before reporting this value.
+	var versions []v1.OperandVersion
 	if len(r.version) > 0 {
-		co.Status.Versions = []v1.OperandVersion{
+		versions = []v1.OperandVersion{
 			{
 				Name:    "operator",
 				Version: r.version,
 			},
 		}
-	} else {
-		co.Status.Versions = nil
 	}
 
-	return r.setConditions(ctx, co, conditions)
+	return r.updateStatus(ctx, co, conditions, versions)
 }
 
 // SetRollOutInProgress sets the OperatorProgressing condition to true, either:
@@ -163,7 +301,9 @@ func (r *StatusReporter) SetRollOutInProgress(ctx context.Context) error {
 	return r.setConditions(ctx, co, conditions)
 }
 
-func (r *StatusReporter) SetFailed(ctx context.Context, statusErr error, reason string) error {
+// SetFailed reports the stack as unavailable and degraded because of the
+// given error. The other given conditions are set in the same status update.
+func (r *StatusReporter) SetFailed(ctx context.Context, statusErr error, reason string, others ...Condition) error {
 	co, err := r.getOrCreateClusterOperator(ctx)
 	if err != nil {
 		return err
@@ -174,6 +314,7 @@ func (r *StatusReporter) SetFailed(ctx context.Context, statusErr error, reason
 	reason = cmostr.ToPascalCase(reason)
 
 	conditions := newConditions(co.Status, r.version, time)
+	conditions.setConditions(others, time)
 	conditions.setCondition(v1.OperatorAvailable, v1.ConditionFalse, unavailableMessage, reason, time)
 	conditions.setCondition(v1.OperatorProgressing, v1.ConditionFalse, unavailableMessage, reason, time)
 	conditions.setCondition(v1.OperatorDegraded, v1.ConditionTrue, fmt.Sprintf("Failed to rollout the stack. Error: %v", statusErr), reason, time)
@@ -181,6 +322,164 @@ func (r *StatusReporter) SetFailed(ctx context.Context, statusErr error, reason
 	return r.setConditions(ctx, co, conditions)
 }
 
+// ComponentResult is the outcome of the last reconciliation of a component.
+type ComponentResult struct {
+	// Component is the name of the component (e.g. "Prometheus-k8s").
+	Component string
+	Err       error
+}
+
+// ComponentConditionType returns the type of the condition reporting
+// whether the reconciliation of the component failed (e.g.
+// "ThanosQuerierDegraded" for "Thanos Querier").
+func ComponentConditionType(component string) v1.ClusterStatusConditionType {
+	return v1.ClusterStatusConditionType(cmostr.ToPascalCase(component) + "Degraded")
+}
+
+// ComponentErrors aggregates the failures of the components. The message
+// has one line per failed component with the object involved in the failure
+// when the API server reported it.
+type ComponentErrors []ComponentResult
+
+func (e ComponentErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, r := range e {
+		if r.Err == nil {
+			continue
+		}
+		lines = append(lines, componentFailure(r.Component, r.Err))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// componentFailure describes the failure of a component: the component, the
+// object when known and the error.
+func componentFailure(component string, err error) string {
+	var statusErr *apierrors.StatusError
+	if errors.As(err, &statusErr) {
+		if d := statusErr.ErrStatus.Details; d != nil && d.Name != "" {
+			kind := d.Kind
+			if kind == "" {
+				kind = "object"
+			}
+			return fmt.Sprintf("%s: %s %q: %v", component, kind, d.Name, err)
+		}
+	}
+	return fmt.Sprintf("%s: %v", component, err)
+}
+
+// ComponentConditions returns the conditions reporting the outcome of the
+// reconciliation of every component, named after the component (see
+// ComponentConditionType). The conditions of the components missing from
+// the results, e.g. because an earlier task group failed, are kept as is.
+// The conditions are informational, the Degraded condition aggregates the
+// failures.
+func ComponentConditions(results []ComponentResult) []Condition {
+	conditions := make([]Condition, 0, len(results))
+	for _, res := range results {
+		if res.Err == nil {
+			conditions = append(conditions, Condition{Type: ComponentConditionType(res.Component), Status: v1.ConditionFalse, Reason: asExpectedReason})
+			continue
+		}
+
+		conditions = append(conditions, Condition{
+			Type:    ComponentConditionType(res.Component),
+			Status:  v1.ConditionTrue,
+			Message: componentFailure(res.Component, res.Err),
+			Reason:  "ReconciliationFailed",
+		})
+	}
+
+	return conditions
+}
+
+// Condition is a condition of the ClusterOperator which is set along with the
+// other conditions of the reconciliation in a single status update.
+type Condition struct {
+	Type    v1.ClusterStatusConditionType
+	Status  v1.ConditionStatus
+	Message string
+	Reason  string
+}
+
+// listConditions are the reasons and the messages of the informational
+// conditions which list the items they report.
+var listConditions = map[v1.ClusterStatusConditionType]struct{ reason, message string }{
+	NotAvailableFeatures: {"APIsNotAvailable", "The following features are not available because the cluster doesn't serve the required APIs"},
+	DisabledComponents:   {"DisabledByConfiguration", "The following components are disabled by the configuration"},
+	NamespacesOverQuota:  {"ScrapeLimitsOverQuota", "The scrape limits of monitors have been lowered to the namespace quota in"},
+	StorageClassDrift:    {"StorageClassMismatch", "The following volumes don't use the expected storage class, resizing or migrating them may fail"},
+	ExcludedRules:        {"PlatformRulesExcluded", "The following platform rules are excluded by the configuration"},
+	UnknownFields:        {"UnknownConfigFields", "The following configuration fields are unknown and ignored"},
+	ClockSkew:            {"ClockSkewDetected", `The clock of the following nodes is skewed from the clock of Prometheus, alerts with a "for" duration may be delayed and the deduplication of samples may fail`},
+	UnmanagedDrift:       {"UnmanagedObjectsDrifted", "The following unmanaged objects drifted from their desired state and aren't updated by the operator"},
+}
+
+// ListCondition returns the informational condition of the given type
+// listing the items, e.g. the DisabledComponents condition listing the
+// disabled components. The condition is True when there are items and False
+// otherwise. It doesn't affect the Available or Degraded conditions.
+func ListCondition(t v1.ClusterStatusConditionType, items []string) Condition {
+	l := listConditions[t]
+	return listCondition(t, l.reason, asExpectedReason, l.message, items)
+}
+
+func listCondition(t v1.ClusterStatusConditionType, reasonTrue, reasonFalse, message string, items []string) Condition {
+	if len(items) == 0 {
+		return Condition{Type: t, Status: v1.ConditionFalse, Reason: reasonFalse}
+	}
+
+	return Condition{
+		Type:    t,
+		Status:  v1.ConditionTrue,
+		Message: fmt.Sprintf("%s: %s", message, strings.Join(items, "; ")),
+		Reason:  reasonTrue,
+	}
+}
+
+// TeardownCondition returns the condition reporting the removed components
+// whose teardown is in progress (e.g. pods still terminating) and the
+// persistent volume claims retained after their teardown. When the removal
+// of the operator is requested, it also reports whether the operator can be
+// removed.
+func TeardownCondition(uninstalling bool, pending, retained []string) Condition {
+	switch {
+	case len(pending) > 0:
+		return listCondition(TeardownProgressing, "TeardownInProgress", asExpectedReason, "The following removed components are being torn down", pending)
+	case uninstalling:
+		return Condition{
+			Type:    TeardownProgressing,
+			Status:  v1.ConditionFalse,
+			Message: "The optional components are torn down, the operator can be removed.",
+			Reason:  "UninstallReady",
+		}
+	case len(retained) > 0:
+		return Condition{
+			Type:    TeardownProgressing,
+			Status:  v1.ConditionFalse,
+			Message: fmt.Sprintf("The persistent volume claims of the following removed components are retained, set teardown.deletePersistentVolumeClaims to true to delete them: %s", strings.Join(retained, "; ")),
+			Reason:  "PersistentVolumeClaimsRetained",
+		}
+	}
+
+	return Condition{Type: TeardownProgressing, Status: v1.ConditionFalse, Reason: asExpectedReason}
+}
+
+// SetConditions sets the given conditions in a single status update.
+func (r *StatusReporter) SetConditions(ctx context.Context, others ...Condition) error {
+	co, err := r.getOrCreateClusterOperator(ctx)
+	if err != nil {
+		return err
+	}
+
+	time := metav1.Now()
+	conditions := newConditions(co.Status, r.version, time)
+	conditions.setConditions(others, time)
+
+	return r.setConditions(ctx, co, conditions)
+}
+
 func (r *StatusReporter) SetUpgradeable(ctx context.Context, cond v1.ConditionStatus, message, reason string) error {
 	co, err := r.getOrCreateClusterOperator(ctx)
 	if err != nil {
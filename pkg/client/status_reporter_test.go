@@ -21,8 +21,11 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
+	pkgerrors "github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	v1 "github.com/openshift/api/config/v1"
 	clientv1 "github.com/openshift/client-go/config/clientset/versioned/typed/config/v1"
@@ -123,6 +126,31 @@ func TestStatusReporterSetRolloutDone(t *testing.T) {
 	}
 }
 
+func TestStatusReporterSetRollOutDoneDegraded(t *testing.T) {
+	mock := &clusterOperatorMock{}
+	sr := NewStatusReporter(mock, "foo", "bar", "fred", "1.0")
+
+	getReturnsClusterOperator(&v1.ClusterOperator{})(mock)
+	updateStatusReturnsError(nil)(mock)
+
+	got := sr.SetRollOutDoneDegraded(context.Background(), "The fred namespace has been terminating", "UserWorkloadNamespaceTerminating")
+
+	for _, check := range []checkFunc{
+		hasUpdatedStatus(true),
+		hasUpdatedStatusVersions("1.0"),
+		hasUpdatedStatusConditions(
+			"Available", "True",
+			"Degraded", "True",
+			"Progressing", "False",
+			"Upgradeable", "Unknown",
+		),
+	} {
+		if err := check(mock, got); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestStatusReporterSetInProgress(t *testing.T) {
 	ctx := context.Background()
 	for _, tc := range []struct {
@@ -312,6 +340,249 @@ func TestStatusReporterSetFailed(t *testing.T) {
 	}
 }
 
+func TestInformationalConditions(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		got  Condition
+
+		status  v1.ConditionStatus
+		reason  string
+		message string
+	}{
+		{
+			name:   "no items",
+			got:    ListCondition(DisabledComponents, nil),
+			status: v1.ConditionFalse,
+			reason: asExpectedReason,
+		},
+		{
+			name:    "not available features",
+			got:     ListCondition(NotAvailableFeatures, []string{"Routes (route.openshift.io/v1)"}),
+			status:  v1.ConditionTrue,
+			reason:  "APIsNotAvailable",
+			message: "The following features are not available because the cluster doesn't serve the required APIs: Routes (route.openshift.io/v1)",
+		},
+		{
+			name:    "disabled components",
+			got:     ListCondition(DisabledComponents, []string{"alertmanagerMain (managementState: Removed)", "openshift-state-metrics"}),
+			status:  v1.ConditionTrue,
+			reason:  "DisabledByConfiguration",
+			message: "The following components are disabled by the configuration: alertmanagerMain (managementState: Removed); openshift-state-metrics",
+		},
+		{
+			name:    "namespaces over quota",
+			got:     ListCondition(NamespacesOverQuota, []string{"ns1"}),
+			status:  v1.ConditionTrue,
+			reason:  "ScrapeLimitsOverQuota",
+			message: "The scrape limits of monitors have been lowered to the namespace quota in: ns1",
+		},
+		{
+			name:    "storage class drift",
+			got:     ListCondition(StorageClassDrift, []string{"prometheus-k8s: prometheus-k8s-db-prometheus-k8s-0"}),
+			status:  v1.ConditionTrue,
+			reason:  "StorageClassMismatch",
+			message: "The following volumes don't use the expected storage class, resizing or migrating them may fail: prometheus-k8s: prometheus-k8s-db-prometheus-k8s-0",
+		},
+		{
+			name:    "excluded rules",
+			got:     ListCondition(ExcludedRules, []string{"group=kubernetes-apps alert=KubePodCrashLooping (1 rules)"}),
+			status:  v1.ConditionTrue,
+			reason:  "PlatformRulesExcluded",
+			message: "The following platform rules are excluded by the configuration: group=kubernetes-apps alert=KubePodCrashLooping (1 rules)",
+		},
+		{
+			name:    "unknown fields",
+			got:     ListCondition(UnknownFields, []string{"openshift-monitoring/cluster-monitoring-config: prometheusK8s.retentio"}),
+			status:  v1.ConditionTrue,
+			reason:  "UnknownConfigFields",
+			message: "The following configuration fields are unknown and ignored: openshift-monitoring/cluster-monitoring-config: prometheusK8s.retentio",
+		},
+		{
+			name:    "clock skew",
+			got:     ListCondition(ClockSkew, []string{"node-1 (5s)"}),
+			status:  v1.ConditionTrue,
+			reason:  "ClockSkewDetected",
+			message: `The clock of the following nodes is skewed from the clock of Prometheus, alerts with a "for" duration may be delayed and the deduplication of samples may fail: node-1 (5s)`,
+		},
+		{
+			name:    "unmanaged drift",
+			got:     ListCondition(UnmanagedDrift, []string{"ConfigMap openshift-monitoring/foo (data.bar)"}),
+			status:  v1.ConditionTrue,
+			reason:  "UnmanagedObjectsDrifted",
+			message: "The following unmanaged objects drifted from their desired state and aren't updated by the operator: ConfigMap openshift-monitoring/foo (data.bar)",
+		},
+		{
+			name:   "nothing to tear down",
+			got:    TeardownCondition(false, nil, nil),
+			status: v1.ConditionFalse,
+			reason: asExpectedReason,
+		},
+		{
+			name:    "pods terminating",
+			got:     TeardownCondition(true, []string{"alertmanager-main: 2 pods terminating"}, nil),
+			status:  v1.ConditionTrue,
+			reason:  "TeardownInProgress",
+			message: "The following removed components are being torn down: alertmanager-main: 2 pods terminating",
+		},
+		{
+			name:    "uninstall ready",
+			got:     TeardownCondition(true, nil, []string{"alertmanager-main: openshift-monitoring/alertmanager-main-db-alertmanager-main-0"}),
+			status:  v1.ConditionFalse,
+			reason:  "UninstallReady",
+			message: "The optional components are torn down, the operator can be removed.",
+		},
+		{
+			name:    "claims retained",
+			got:     TeardownCondition(false, nil, []string{"alertmanager-main: openshift-monitoring/alertmanager-main-db-alertmanager-main-0"}),
+			status:  v1.ConditionFalse,
+			reason:  "PersistentVolumeClaimsRetained",
+			message: "The persistent volume claims of the following removed components are retained, set teardown.deletePersistentVolumeClaims to true to delete them: alertmanager-main: openshift-monitoring/alertmanager-main-db-alertmanager-main-0",
+		},
+		{
+			name:   "component reconciled",
+			got:    ComponentConditions([]ComponentResult{{Component: "Thanos Querier"}})[0],
+			status: v1.ConditionFalse,
+			reason: asExpectedReason,
+		},
+		{
+			name:    "component failed",
+			got:     ComponentConditions([]ComponentResult{{Component: "Alertmanager", Err: errors.New("reconciling Alertmanager object failed")}})[0],
+			status:  v1.ConditionTrue,
+			reason:  "ReconciliationFailed",
+			message: "Alertmanager: reconciling Alertmanager object failed",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.got.Status != tc.status || tc.got.Reason != tc.reason || tc.got.Message != tc.message {
+				t.Fatalf("expected status %q, reason %q and message %q, got %+v", tc.status, tc.reason, tc.message, tc.got)
+			}
+		})
+	}
+}
+
+func TestStatusReporterSetRollOutDoneWithConditions(t *testing.T) {
+	mock := &clusterOperatorMock{}
+	sr := NewStatusReporter(mock, "foo", "bar", "fred", "1.0")
+
+	getReturnsClusterOperator(&v1.ClusterOperator{})(mock)
+	updateStatusReturnsError(nil)(mock)
+
+	conditions := ComponentConditions([]ComponentResult{
+		{Component: "Thanos Querier"},
+		{Component: "Alertmanager", Err: errors.New("reconciling Alertmanager object failed")},
+	})
+	conditions = append(conditions,
+		ListCondition(DisabledComponents, []string{"openshift-state-metrics"}),
+		ListCondition(ClockSkew, nil),
+		Condition{Type: v1.OperatorUpgradeable, Status: v1.ConditionTrue},
+	)
+	got := sr.SetRollOutDone(context.Background(), "", "", conditions...)
+
+	for _, check := range []checkFunc{
+		hasUpdatedStatus(true),
+		hasUpdatedStatusVersions("1.0"),
+		hasUpdatedStatusConditions(
+			"AlertmanagerDegraded", "True",
+			"Available", "True",
+			"ClockSkew", "False",
+			"Degraded", "False",
+			"DisabledComponents", "True",
+			"Progressing", "False",
+			"ThanosQuerierDegraded", "False",
+			"Upgradeable", "True",
+		),
+	} {
+		if err := check(mock, got); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if mock.gets != 1 || mock.statusUpdates != 1 {
+		t.Fatalf("expected a single get and status update, got %d gets and %d status updates", mock.gets, mock.statusUpdates)
+	}
+}
+
+func TestComponentErrors(t *testing.T) {
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "prometheus-k8s-tls")
+	errs := ComponentErrors{
+		{Component: "Thanos Querier"},
+		{Component: "Prometheus-k8s", Err: pkgerrors.Wrap(notFound, "waiting for Prometheus object changes failed")},
+		{Component: "Alertmanager", Err: errors.New("reconciling Alertmanager object failed")},
+	}
+
+	expected := "Alertmanager: reconciling Alertmanager object failed\n" +
+		`Prometheus-k8s: secrets "prometheus-k8s-tls": waiting for Prometheus object changes failed: secrets "prometheus-k8s-tls" not found`
+	if got := errs.Error(); got != expected {
+		t.Fatalf("expected message %q, got %q", expected, got)
+	}
+}
+
+func TestStatusReporterThrottling(t *testing.T) {
+	ctx := context.Background()
+	mock := &clusterOperatorMock{}
+
+	now := time.Now()
+	sr := NewStatusReporter(mock, "foo", "bar", "fred", "1.0")
+	sr.now = func() time.Time { return now }
+
+	// The mock returns the object passed to the last status update.
+	getReturnsClusterOperator(&v1.ClusterOperator{})(mock)
+	updateStatusReturnsError(nil)(mock)
+
+	for _, tc := range []struct {
+		name    string
+		elapsed time.Duration
+		set     func() error
+		updated bool
+	}{
+		{
+			name: "initial update",
+			set: func() error {
+				return sr.SetConditions(ctx, ListCondition(StorageClassDrift, []string{"prometheus-k8s-db-prometheus-k8s-0"}))
+			},
+			updated: true,
+		},
+		{
+			name: "no change",
+			set: func() error {
+				return sr.SetConditions(ctx, ListCondition(StorageClassDrift, []string{"prometheus-k8s-db-prometheus-k8s-0"}))
+			},
+		},
+		{
+			name:    "message change within the interval",
+			elapsed: time.Second,
+			set: func() error {
+				return sr.SetConditions(ctx, ListCondition(StorageClassDrift, []string{"prometheus-k8s-db-prometheus-k8s-1"}))
+			},
+		},
+		{
+			name:    "message change after the interval",
+			elapsed: statusUpdateInterval,
+			set: func() error {
+				return sr.SetConditions(ctx, ListCondition(StorageClassDrift, []string{"prometheus-k8s-db-prometheus-k8s-1"}))
+			},
+			updated: true,
+		},
+		{
+			name:    "status change within the interval",
+			elapsed: time.Second,
+			set:     func() error { return sr.SetConditions(ctx, ListCondition(StorageClassDrift, nil)) },
+			updated: true,
+		},
+	} {
+		now = now.Add(tc.elapsed)
+		mock.statusUpdated = nil
+
+		if err := tc.set(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		if err := hasUpdatedStatus(tc.updated)(mock, nil); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+	}
+}
+
 type givenStatusReporter struct {
 	operatorName, namespace, userWorkloadNamespace, version string
 	err                                                     error
@@ -416,6 +687,7 @@ type clusterOperatorMock struct {
 	getFunc                                  func(string, metav1.GetOptions) (*v1.ClusterOperator, error)
 
 	created, updated, statusUpdated *v1.ClusterOperator
+	gets, statusUpdates             int
 }
 
 // ensure the mock satisfies the ClusterOperatorInterface interface.
@@ -433,6 +705,7 @@ func (com *clusterOperatorMock) Update(ctx context.Context, co *v1.ClusterOperat
 
 func (com *clusterOperatorMock) UpdateStatus(ctx context.Context, co *v1.ClusterOperator, opts metav1.UpdateOptions) (*v1.ClusterOperator, error) {
 	com.statusUpdated = co
+	com.statusUpdates++
 	return com.updateStatusFunc(co)
 }
 
@@ -445,6 +718,7 @@ func (com *clusterOperatorMock) DeleteCollection(ctx context.Context, options me
 }
 
 func (com *clusterOperatorMock) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.ClusterOperator, error) {
+	com.gets++
 	return com.getFunc(name, opts)
 }
 
@@ -0,0 +1,32 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import "context"
+
+type taskKey struct{}
+
+// WithTask returns a context carrying the name of the task reconciling the
+// objects. The unmanaged and modified objects found by the client are
+// reported per task so that the operator can keep the results of the tasks
+// which didn't run during a reconciliation.
+func WithTask(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, taskKey{}, name)
+}
+
+func taskFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(taskKey{}).(string)
+	return name
+}
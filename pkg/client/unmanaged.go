@@ -0,0 +1,211 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// UnmanagedAnnotation is set to "true" by administrators on an object
+// managed by the operator to stop the operator from updating it. The
+// operator keeps comparing the object with the desired one and reports the
+// drifted fields instead of reverting them, e.g. while debugging a component
+// in production.
+const UnmanagedAnnotation = metadataPrefix + "unmanaged"
+
+// UnmanagedObject is an object with the unmanaged annotation and the fields
+// which differ from the desired object.
+type UnmanagedObject struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Drift     []string
+}
+
+func (o UnmanagedObject) String() string {
+	if o.Namespace == "" {
+		return fmt.Sprintf("%s %s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+func isUnmanaged(obj metav1.Object) bool {
+	return obj.GetAnnotations()[UnmanagedAnnotation] == "true"
+}
+
+// unmanagedTracker remembers the unmanaged objects found by each task since
+// they were last taken.
+type unmanagedTracker struct {
+	mtx     sync.Mutex
+	objects map[string]map[string]UnmanagedObject
+}
+
+func newUnmanagedTracker() *unmanagedTracker {
+	return &unmanagedTracker{
+		objects: map[string]map[string]UnmanagedObject{},
+	}
+}
+
+func (u *unmanagedTracker) record(task string, o UnmanagedObject) {
+	if u == nil {
+		return
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	if u.objects[task] == nil {
+		u.objects[task] = map[string]UnmanagedObject{}
+	}
+	u.objects[task][o.String()] = o
+}
+
+func (u *unmanagedTracker) take() map[string][]UnmanagedObject {
+	if u == nil {
+		return nil
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	ret := make(map[string][]UnmanagedObject, len(u.objects))
+	for task, objects := range u.objects {
+		for _, o := range objects {
+			ret[task] = append(ret[task], o)
+		}
+		SortUnmanagedObjects(ret[task])
+	}
+	u.objects = map[string]map[string]UnmanagedObject{}
+
+	return ret
+}
+
+// SortUnmanagedObjects sorts the objects by kind, namespace and name.
+func SortUnmanagedObjects(objects []UnmanagedObject) {
+	sort.Slice(objects, func(i, j int) bool { return objects[i].String() < objects[j].String() })
+}
+
+// TakeUnmanagedObjects returns the objects with the unmanaged annotation
+// which the client skipped since the last call, sorted by kind, namespace
+// and name and grouped by the task which found them (see WithTask).
+func (c *Client) TakeUnmanagedObjects() map[string][]UnmanagedObject {
+	return c.unmanaged.take()
+}
+
+// audit records the fields of the unmanaged object which differ from the
+// required object without modifying it.
+func (c *Client) audit(ctx context.Context, gvk schema.GroupVersionKind, required object, existing metav1.Object) error {
+	drift, err := driftedFields(required, existing, gvk)
+	if err != nil {
+		return err
+	}
+
+	o := UnmanagedObject{
+		Kind:      gvk.Kind,
+		Namespace: existing.GetNamespace(),
+		Name:      existing.GetName(),
+		Drift:     drift,
+	}
+	c.unmanaged.record(taskFromContext(ctx), o)
+	if len(drift) > 0 {
+		klog.Warningf("Not updating the unmanaged %s which drifted from the desired state: %s", o, strings.Join(drift, ", "))
+	} else {
+		klog.V(4).Infof("Not updating the unmanaged %s", o)
+	}
+
+	return nil
+}
+
+// driftedFields returns the paths of the fields set in the required object
+// whose value differs in the existing object. Fields which are only set in
+// the existing object (defaults, fields owned by other managers) aren't
+// reported.
+func driftedFields(required object, existing metav1.Object, gvk schema.GroupVersionKind) ([]string, error) {
+	want, err := applyConfiguration(required, gvk)
+	if err != nil {
+		return nil, err
+	}
+	delete(want, "apiVersion")
+	delete(want, "kind")
+
+	ro, ok := existing.(runtime.Object)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %T object", existing)
+	}
+	got, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ro)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []string
+	diffFields("", want, got, &drift)
+	sort.Strings(drift)
+
+	return drift, nil
+}
+
+func diffFields(path string, want, got interface{}, drift *[]string) {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			*drift = append(*drift, path)
+			return
+		}
+		for k, v := range w {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			diffFields(p, v, g[k], drift)
+		}
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(w) != len(g) {
+			*drift = append(*drift, path)
+			return
+		}
+		for i := range w {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if name, found := listItemName(w[i]); found {
+				p = fmt.Sprintf("%s[name=%s]", path, name)
+			}
+			diffFields(p, w[i], g[i], drift)
+		}
+	default:
+		if !reflect.DeepEqual(want, got) {
+			*drift = append(*drift, path)
+		}
+	}
+}
+
+func listItemName(item interface{}) (string, bool) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	name, ok := m["name"].(string)
+	return name, ok
+}
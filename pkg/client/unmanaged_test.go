@@ -0,0 +1,166 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDriftedFields(t *testing.T) {
+	required := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kube-state-metrics",
+			Namespace: ns,
+			Labels:    map[string]string{"app": "kube-state-metrics"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: "kube-state-metrics", Image: "ksm:v2", Args: []string{"--port=8081"}},
+						{Name: "kube-rbac-proxy", Image: "proxy:v1"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		existing func(*appsv1.Deployment)
+		expected []string
+	}{
+		{
+			name:     "in sync",
+			existing: func(*appsv1.Deployment) {},
+		},
+		{
+			name: "fields set by others",
+			existing: func(d *appsv1.Deployment) {
+				d.Annotations = map[string]string{UnmanagedAnnotation: "true"}
+				d.Labels["other"] = "value"
+				d.Spec.Template.Spec.Containers[0].TerminationMessagePath = "/dev/termination-log"
+			},
+		},
+		{
+			name: "modified fields",
+			existing: func(d *appsv1.Deployment) {
+				d.Labels["app"] = "foo"
+				d.Spec.Template.Spec.Containers[0].Image = "ksm:debug"
+				d.Spec.Template.Spec.Containers[0].Args = append(d.Spec.Template.Spec.Containers[0].Args, "--v=10")
+			},
+			expected: []string{
+				"metadata.labels.app",
+				"spec.template.spec.containers[name=kube-state-metrics].args",
+				"spec.template.spec.containers[name=kube-state-metrics].image",
+			},
+		},
+		{
+			name: "removed container",
+			existing: func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.Containers = d.Spec.Template.Spec.Containers[:1]
+			},
+			expected: []string{"spec.template.spec.containers"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			existing := required.DeepCopy()
+			tc.existing(existing)
+
+			drift, err := driftedFields(required, existing, appsv1.SchemeGroupVersion.WithKind("Deployment"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(drift) == 0 && len(tc.expected) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(drift, tc.expected) {
+				t.Fatalf("expected drift %q, got %q", tc.expected, drift)
+			}
+		})
+	}
+}
+
+func TestUnmanagedObjectIsNotUpdated(t *testing.T) {
+	ctx := WithTask(context.Background(), "Updating Prometheus-k8s")
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "prometheus-k8s-rulefiles",
+			Namespace:   ns,
+			Annotations: map[string]string{UnmanagedAnnotation: "true"},
+		},
+		Data: map[string]string{"rules.yaml": "debug"},
+	}
+
+	kclient := fakeKube(cm.DeepCopy())
+	c := Client{kclient: kclient, unmanaged: newUnmanagedTracker()}
+	required := cm.DeepCopy()
+	required.Annotations = nil
+	required.Data = map[string]string{"rules.yaml": "groups: []"}
+	if err := c.CreateOrUpdateConfigMap(ctx, required); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range kclient.Actions() {
+		if a.GetVerb() != "get" {
+			t.Fatalf("expected no write request for the unmanaged object, got %s", a.GetVerb())
+		}
+	}
+
+	after, err := c.kclient.CoreV1().ConfigMaps(ns).Get(ctx, cm.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after.Data, cm.Data) || after.Annotations[UnmanagedAnnotation] != "true" {
+		t.Fatalf("expected the unmanaged object to be left untouched, got %v", after)
+	}
+
+	expected := map[string][]UnmanagedObject{
+		"Updating Prometheus-k8s": {{
+			Kind:      "ConfigMap",
+			Namespace: ns,
+			Name:      cm.Name,
+			Drift:     []string{"data.rules.yaml"},
+		}},
+	}
+	if got := c.TakeUnmanagedObjects(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := c.TakeUnmanagedObjects(); len(got) != 0 {
+		t.Fatalf("expected no unmanaged objects after take, got %v", got)
+	}
+
+	// Removing the annotation reverts the drift.
+	after.Annotations = nil
+	if _, err := c.kclient.CoreV1().ConfigMaps(ns).Update(ctx, after, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateOrUpdateConfigMap(ctx, required); err != nil {
+		t.Fatal(err)
+	}
+	after, err = c.kclient.CoreV1().ConfigMaps(ns).Get(ctx, cm.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after.Data, required.Data) {
+		t.Fatalf("expected data %v, got %v", required.Data, after.Data)
+	}
+}
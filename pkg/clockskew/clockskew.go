@@ -0,0 +1,88 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clockskew detects the nodes whose clock is skewed from the clock of
+// Prometheus.
+package clockskew
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/promclient"
+	"github.com/pkg/errors"
+)
+
+const (
+	// skewQuery relies on the instance:node_time_skew_seconds:offset
+	// recording rule which compares the time exposed by node_exporter with
+	// the scrape timestamp set by Prometheus.
+	skewQuery = `abs(instance:node_time_skew_seconds:offset) > %s`
+
+	// DefaultThreshold is the skew above which a node is reported. It
+	// matches the threshold of the NodeClockSkewedFromPrometheus alert.
+	DefaultThreshold = time.Second
+)
+
+// Skew is the clock skew of a node.
+type Skew struct {
+	Instance string
+	Offset   time.Duration
+}
+
+func (s Skew) String() string {
+	return fmt.Sprintf("%s (%s)", s.Instance, s.Offset)
+}
+
+// Checker reports the nodes whose clock is skewed from the clock of
+// Prometheus by more than the threshold.
+type Checker struct {
+	querier   promclient.LabelQuerier
+	threshold time.Duration
+}
+
+func New(querier promclient.LabelQuerier, threshold time.Duration) *Checker {
+	return &Checker{
+		querier:   querier,
+		threshold: threshold,
+	}
+}
+
+// Check returns the skewed nodes sorted by instance. A nil Checker reports
+// nothing.
+func (c *Checker) Check(ctx context.Context) ([]Skew, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	threshold := strconv.FormatFloat(c.threshold.Seconds(), 'f', -1, 64)
+	offsets, err := c.querier.QueryByLabel(ctx, fmt.Sprintf(skewQuery, threshold), "instance")
+	if err != nil {
+		return nil, errors.Wrap(err, "querying the clock skew of the nodes failed")
+	}
+
+	skews := make([]Skew, 0, len(offsets))
+	for instance, offset := range offsets {
+		skews = append(skews, Skew{
+			Instance: instance,
+			Offset:   time.Duration(offset * float64(time.Second)).Round(time.Millisecond),
+		})
+	}
+	sort.Slice(skews, func(i, j int) bool { return skews[i].Instance < skews[j].Instance })
+
+	return skews, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clockskew
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type querierFunc func(query, label string) (map[string]float64, error)
+
+func (f querierFunc) QueryByLabel(_ context.Context, query, label string) (map[string]float64, error) {
+	return f(query, label)
+}
+
+func TestCheck(t *testing.T) {
+	var gotQuery, gotLabel string
+	c := New(querierFunc(func(query, label string) (map[string]float64, error) {
+		gotQuery, gotLabel = query, label
+		return map[string]float64{
+			"worker-1": 2.5,
+			"master-0": 1.2345,
+		}, nil
+	}), 1500*time.Millisecond)
+
+	skews, err := c.Check(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotQuery != "abs(instance:node_time_skew_seconds:offset) > 1.5" {
+		t.Fatalf("unexpected query %q", gotQuery)
+	}
+	if gotLabel != "instance" {
+		t.Fatalf("unexpected label %q", gotLabel)
+	}
+
+	expected := []Skew{
+		{Instance: "master-0", Offset: 1235 * time.Millisecond},
+		{Instance: "worker-1", Offset: 2500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(skews, expected) {
+		t.Fatalf("expected %v, got %v", expected, skews)
+	}
+	if s := skews[0].String(); s != "master-0 (1.235s)" {
+		t.Fatalf("unexpected string %q", s)
+	}
+}
+
+func TestCheckError(t *testing.T) {
+	c := New(querierFunc(func(string, string) (map[string]float64, error) {
+		return nil, errors.New("connection refused")
+	}), DefaultThreshold)
+
+	if _, err := c.Check(context.Background()); err == nil {
+		t.Fatal("expected an error, got none")
+	}
+}
+
+func TestCheckNil(t *testing.T) {
+	var c *Checker
+	skews, err := c.Check(context.Background())
+	if err != nil || skews != nil {
+		t.Fatalf("expected nothing, got %v, %v", skews, err)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configreload verifies that the monitoring components run the
+// configuration generated for them.
+package configreload
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/promclient"
+	"github.com/pkg/errors"
+)
+
+const (
+	alertmanagerStaleQuery   = `count(alertmanager_config_hash{namespace=%q,job=%q} != %s) or vector(0)`
+	alertmanagerRunningQuery = `max(alertmanager_config_hash{namespace=%q,job=%q} != %s)`
+	prometheusFailedQuery    = `count(prometheus_config_last_reload_successful{namespace=%q,job=%q} == 0) or vector(0)`
+
+	// DefaultGracePeriod is the time given to the components to reload their
+	// configuration before a mismatch is reported. It accounts for the
+	// propagation of the secret to the pods, the reload and the scrape
+	// interval.
+	DefaultGracePeriod = 5 * time.Minute
+)
+
+// ErrStaleConfig is returned when a component doesn't run the expected
+// configuration.
+var ErrStaleConfig = errors.New("stale configuration")
+
+// ConfigHash returns the hash of the configuration as exposed by the
+// alertmanager_config_hash metric.
+func ConfigHash(data []byte) float64 {
+	sum := md5.Sum(data)
+	// Alertmanager only keeps 48 bits since a float64 has a 53 bit mantissa.
+	b := make([]byte, 8)
+	copy(b, sum[0:6])
+	return float64(binary.LittleEndian.Uint64(b))
+}
+
+func formatHash(h float64) string {
+	return strconv.FormatFloat(h, 'f', -1, 64)
+}
+
+// Checker compares the configuration reported by the components with the
+// expected one. A mismatch is only reported once it outlasted the grace
+// period, hence the same Checker must be used across reconciliations.
+type Checker struct {
+	querier     promclient.Querier
+	gracePeriod time.Duration
+	now         func() time.Time
+
+	mtx        sync.Mutex
+	mismatches map[string]mismatch
+}
+
+type mismatch struct {
+	expected string
+	since    time.Time
+}
+
+func New(querier promclient.Querier, gracePeriod time.Duration) *Checker {
+	return &Checker{
+		querier:     querier,
+		gracePeriod: gracePeriod,
+		now:         time.Now,
+		mismatches:  map[string]mismatch{},
+	}
+}
+
+// CheckAlertmanager verifies that all the pods of the Alertmanager job run
+// the given configuration. The returned error wraps ErrStaleConfig when they
+// don't.
+func (c *Checker) CheckAlertmanager(ctx context.Context, namespace, job string, config []byte) error {
+	expected := formatHash(ConfigHash(config))
+
+	stale, err := c.querier.Query(ctx, fmt.Sprintf(alertmanagerStaleQuery, namespace, job, expected))
+	if err != nil {
+		return errors.Wrapf(err, "querying the configuration hash of %s/%s failed", namespace, job)
+	}
+
+	key := fmt.Sprintf("alertmanager/%s/%s", namespace, job)
+	if !c.outlasted(key, expected, stale > 0) {
+		return nil
+	}
+
+	running, err := c.querier.Query(ctx, fmt.Sprintf(alertmanagerRunningQuery, namespace, job, expected))
+	if err != nil {
+		return errors.Wrapf(err, "querying the configuration hash of %s/%s failed", namespace, job)
+	}
+
+	return errors.Wrapf(ErrStaleConfig, "%s/%s: %d pod(s) didn't reload the configuration (running hash %s, expected hash %s)", namespace, job, int(stale), formatHash(running), expected)
+}
+
+// CheckPrometheus verifies that all the pods of the Prometheus job reloaded
+// their configuration successfully. Prometheus doesn't expose the hash of
+// its configuration, the status of the last reload is used instead.
+func (c *Checker) CheckPrometheus(ctx context.Context, namespace, job string) error {
+	failed, err := c.querier.Query(ctx, fmt.Sprintf(prometheusFailedQuery, namespace, job))
+	if err != nil {
+		return errors.Wrapf(err, "querying the configuration reload status of %s/%s failed", namespace, job)
+	}
+
+	key := fmt.Sprintf("prometheus/%s/%s", namespace, job)
+	if !c.outlasted(key, "", failed > 0) {
+		return nil
+	}
+
+	return errors.Wrapf(ErrStaleConfig, "%s/%s: %d pod(s) failed to reload the configuration", namespace, job, int(failed))
+}
+
+// outlasted records whether the component identified by key mismatches
+// the expected configuration and returns true if it has been the case for
+// longer than the grace period. A new expected configuration restarts the
+// grace period.
+func (c *Checker) outlasted(key, expected string, mismatched bool) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if !mismatched {
+		delete(c.mismatches, key)
+		return false
+	}
+
+	m, found := c.mismatches[key]
+	if !found || m.expected != expected {
+		c.mismatches[key] = mismatch{expected: expected, since: c.now()}
+		return false
+	}
+
+	return c.now().Sub(m.since) >= c.gracePeriod
+}
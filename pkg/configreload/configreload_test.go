@@ -0,0 +1,113 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configreload
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeQuerier map[string]float64
+
+func (f fakeQuerier) Query(_ context.Context, query string) (float64, error) {
+	for k, v := range f {
+		if strings.HasPrefix(query, k) {
+			return v, nil
+		}
+	}
+	return 0, errors.New("empty result")
+}
+
+func TestConfigHash(t *testing.T) {
+	// md5("") = d41d8cd98f00b204e9800998ecf8427e, the first 6 bytes are
+	// read as a little-endian integer.
+	const expected = float64(0x008fd98c1dd4)
+	if got := ConfigHash([]byte("")); got != expected {
+		t.Fatalf("expected %s, got %s", formatHash(expected), formatHash(got))
+	}
+}
+
+func TestCheckAlertmanager(t *testing.T) {
+	now := time.Now()
+	stale := 1.0
+	c := New(fakeQuerier{}, time.Minute)
+	c.now = func() time.Time { return now }
+	c.querier = querierFunc(func(query string) (float64, error) {
+		if strings.HasPrefix(query, "count(") {
+			return stale, nil
+		}
+		return 42, nil
+	})
+
+	check := func() error {
+		return c.CheckAlertmanager(context.Background(), "openshift-monitoring", "alertmanager-main", []byte("route: {}"))
+	}
+
+	// The first mismatch starts the grace period.
+	if err := check(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	err := check()
+	if !errors.Is(err, ErrStaleConfig) {
+		t.Fatalf("expected stale configuration error, got %v", err)
+	}
+	for _, s := range []string{"alertmanager-main", "running hash 42", "expected hash " + formatHash(ConfigHash([]byte("route: {}")))} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("expected %q in error %q", s, err)
+		}
+	}
+
+	// A reload resets the grace period.
+	stale = 0
+	if err := check(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	stale = 1
+	if err := check(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestCheckPrometheus(t *testing.T) {
+	now := time.Now()
+	c := New(fakeQuerier{"count(prometheus_config_last_reload_successful": 2}, time.Minute)
+	c.now = func() time.Time { return now }
+
+	if err := c.CheckPrometheus(context.Background(), "openshift-monitoring", "prometheus-k8s"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	err := c.CheckPrometheus(context.Background(), "openshift-monitoring", "prometheus-k8s")
+	if err == nil || !strings.Contains(err.Error(), "2 pod(s) failed to reload") {
+		t.Fatalf("expected reload failure, got %v", err)
+	}
+
+	err = New(fakeQuerier{}, time.Minute).CheckPrometheus(context.Background(), "openshift-monitoring", "prometheus-k8s")
+	if err == nil || errors.Is(err, ErrStaleConfig) {
+		t.Fatalf("expected query error, got %v", err)
+	}
+}
+
+type querierFunc func(string) (float64, error)
+
+func (f querierFunc) Query(_ context.Context, query string) (float64, error) {
+	return f(query)
+}
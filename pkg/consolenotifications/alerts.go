@@ -0,0 +1,127 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consolenotifications
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Alert is an alert returned by the Prometheus alerts API.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    *time.Time        `json:"activeAt"`
+}
+
+// AlertsGetter returns the alerts currently firing.
+type AlertsGetter interface {
+	FiringAlerts(ctx context.Context) ([]Alert, error)
+}
+
+// HTTPAlertsGetter retrieves the alerts from the Prometheus HTTP API with a
+// bearer token.
+type HTTPAlertsGetter struct {
+	url       string
+	tokenFile string
+	client    *http.Client
+}
+
+// NewHTTPAlertsGetter returns an AlertsGetter for the Prometheus API served at
+// the given URL. The bearer token is read from tokenFile on every request and
+// the server certificate is verified against the CA bundle in caFile.
+func NewHTTPAlertsGetter(u, tokenFile, caFile string) (*HTTPAlertsGetter, error) {
+	ca, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading CA bundle failed")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.Errorf("no certificate found in %s", caFile)
+	}
+
+	return &HTTPAlertsGetter{
+		url:       u,
+		tokenFile: tokenFile,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+func (g *HTTPAlertsGetter) FiringAlerts(ctx context.Context) ([]Alert, error) {
+	token, err := ioutil.ReadFile(g.tokenFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading bearer token failed")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url+"/api/v1/alerts", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request failed")
+	}
+	req.Header.Set("Authorization", "Bearer "+string(token))
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving alerts failed")
+	}
+	defer resp.Body.Close()
+
+	return parseAlertsResponse(resp.StatusCode, resp.Body)
+}
+
+type alertsResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		Alerts []Alert `json:"alerts"`
+	} `json:"data"`
+}
+
+func parseAlertsResponse(statusCode int, body io.Reader) ([]Alert, error) {
+	var r alertsResponse
+	if err := json.NewDecoder(body).Decode(&r); err != nil {
+		return nil, errors.Wrapf(err, "decoding response failed (status code: %d)", statusCode)
+	}
+
+	if r.Status != "success" {
+		return nil, errors.Errorf("retrieving alerts failed: %s: %s", r.ErrorType, r.Error)
+	}
+
+	var alerts []Alert
+	for _, a := range r.Data.Alerts {
+		if a.State != "firing" {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+
+	return alerts, nil
+}
@@ -0,0 +1,253 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consolenotifications turns firing platform alerts into notification
+// banners of the OpenShift web console.
+package consolenotifications
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+)
+
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "cluster-monitoring-operator"
+	componentLabel = "app.kubernetes.io/component"
+	componentValue = "alert-notification"
+
+	namePrefix   = "cluster-monitoring-alert-"
+	syncInterval = time.Minute
+
+	// maxNotifications caps the number of banners to avoid flooding the
+	// console when many alerts are firing.
+	maxNotifications = 5
+	maxTextLength    = 256
+)
+
+// Controller reconciles the ConsoleNotification objects with the firing
+// alerts.
+type Controller struct {
+	client *client.Client
+	alerts AlertsGetter
+
+	mtx        sync.Mutex
+	configured bool
+	enabled    bool
+	selector   labels.Selector
+
+	trigger chan struct{}
+}
+
+func NewController(c *client.Client, alerts AlertsGetter) *Controller {
+	return &Controller{
+		client:  c,
+		alerts:  alerts,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// SetConfig updates the configuration of the controller and triggers a
+// reconciliation. The controller doesn't do anything until it's been
+// configured once.
+func (c *Controller) SetConfig(enabled bool, selector labels.Selector) {
+	c.mtx.Lock()
+	c.configured, c.enabled, c.selector = true, enabled, selector
+	c.mtx.Unlock()
+
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run reconciles the console notifications periodically until the context
+// is canceled.
+func (c *Controller) Run(ctx context.Context, _ int) {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.trigger:
+		}
+
+		if err := c.sync(ctx); err != nil {
+			klog.Warningf("Failed to reconcile console notifications: %v", err)
+		}
+	}
+}
+
+func (c *Controller) sync(ctx context.Context) error {
+	c.mtx.Lock()
+	configured, enabled, selector := c.configured, c.enabled, c.selector
+	c.mtx.Unlock()
+
+	if !configured {
+		return nil
+	}
+
+	existing, err := c.client.ListConsoleNotifications(ctx, managedByLabel+"="+managedByValue+","+componentLabel+"="+componentValue)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Info("ConsoleNotification API not available, skipping console notifications")
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "listing ConsoleNotification objects failed")
+	}
+
+	var desired []*unstructured.Unstructured
+	if enabled {
+		alerts, err := c.alerts.FiringAlerts(ctx)
+		if err != nil {
+			// Keep the current banners since we don't know which alerts
+			// are still firing.
+			return err
+		}
+		desired = notifications(alerts, selector)
+	}
+
+	keep := make(map[string]struct{}, len(desired))
+	for _, n := range desired {
+		if err := c.client.CreateOrUpdateConsoleNotification(ctx, n); err != nil {
+			return errors.Wrapf(err, "reconciling ConsoleNotification %q failed", n.GetName())
+		}
+		keep[n.GetName()] = struct{}{}
+	}
+
+	for _, n := range existing {
+		if _, found := keep[n.GetName()]; found {
+			continue
+		}
+		if err := c.client.DeleteConsoleNotification(ctx, n.GetName()); err != nil {
+			return errors.Wrapf(err, "deleting ConsoleNotification %q failed", n.GetName())
+		}
+	}
+
+	return nil
+}
+
+// notifications returns one notification per name of the alerts matching the
+// selector, ordered by alert name.
+func notifications(alerts []Alert, selector labels.Selector) []*unstructured.Unstructured {
+	byName := map[string][]Alert{}
+	for _, a := range alerts {
+		if !selector.Matches(labels.Set(a.Labels)) {
+			continue
+		}
+		byName[a.Labels["alertname"]] = append(byName[a.Labels["alertname"]], a)
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > maxNotifications {
+		names = names[:maxNotifications]
+	}
+
+	res := make([]*unstructured.Unstructured, 0, len(names))
+	for _, name := range names {
+		res = append(res, notification(name, byName[name]))
+	}
+
+	return res
+}
+
+func notification(name string, alerts []Alert) *unstructured.Unstructured {
+	// All alerts share the same name hence the same rule; their annotations
+	// only differ by the templated values.
+	a := alerts[0]
+
+	text := fmt.Sprintf("Alert %s is firing", name)
+	if len(alerts) > 1 {
+		text += fmt.Sprintf(" (%d instances)", len(alerts))
+	}
+	for _, k := range []string{"summary", "description", "message"} {
+		if v := a.Annotations[k]; v != "" {
+			text += ": " + v
+			break
+		}
+	}
+	if len(text) > maxTextLength {
+		// Cut on a rune boundary to keep the text valid UTF-8.
+		i := maxTextLength - 3
+		for i > 0 && !utf8.RuneStart(text[i]) {
+			i--
+		}
+		text = text[:i] + "..."
+	}
+
+	color, backgroundColor := "#151515", "#f0ab00"
+	if a.Labels["severity"] == "critical" {
+		color, backgroundColor = "#fff", "#c9190b"
+	}
+
+	spec := map[string]interface{}{
+		"text":            text,
+		"location":        "BannerTop",
+		"color":           color,
+		"backgroundColor": backgroundColor,
+	}
+	if runbook := a.Annotations["runbook_url"]; runbook != "" {
+		spec["link"] = map[string]interface{}{
+			"href": runbook,
+			"text": "Runbook",
+		}
+	}
+
+	n := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "console.openshift.io/v1",
+		"kind":       "ConsoleNotification",
+		"spec":       spec,
+	}}
+	n.SetName(namePrefix + objectName(name))
+	n.SetLabels(map[string]string{
+		managedByLabel: managedByValue,
+		componentLabel: componentValue,
+	})
+
+	return n
+}
+
+// objectName converts an alert name into a valid object name.
+func objectName(alertName string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, alertName)
+}
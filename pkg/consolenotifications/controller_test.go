@@ -0,0 +1,187 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consolenotifications
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestNotifications(t *testing.T) {
+	alerts := []Alert{
+		{
+			Labels:      map[string]string{"alertname": "KubeAPIDown", "severity": "critical"},
+			Annotations: map[string]string{"summary": "Target disappeared from Prometheus target discovery.", "runbook_url": "https://example.com/KubeAPIDown.md"},
+		},
+		{
+			Labels:      map[string]string{"alertname": "NodeFilesystemAlmostOutOfSpace", "severity": "critical", "instance": "a"},
+			Annotations: map[string]string{"description": "Filesystem has less than 3% space left."},
+		},
+		{
+			Labels:      map[string]string{"alertname": "NodeFilesystemAlmostOutOfSpace", "severity": "critical", "instance": "b"},
+			Annotations: map[string]string{"description": "Filesystem has less than 3% space left."},
+		},
+		{
+			Labels: map[string]string{"alertname": "Watchdog", "severity": "none"},
+		},
+		{
+			Labels: map[string]string{"alertname": "KubePodCrashLooping", "severity": "warning"},
+		},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		selector labels.Selector
+		expected map[string]string
+	}{
+		{
+			name:     "critical alerts",
+			selector: labels.SelectorFromSet(labels.Set{"severity": "critical"}),
+			expected: map[string]string{
+				"cluster-monitoring-alert-kubeapidown":                    "Alert KubeAPIDown is firing: Target disappeared from Prometheus target discovery.",
+				"cluster-monitoring-alert-nodefilesystemalmostoutofspace": "Alert NodeFilesystemAlmostOutOfSpace is firing (2 instances): Filesystem has less than 3% space left.",
+			},
+		},
+		{
+			name:     "warning alerts",
+			selector: labels.SelectorFromSet(labels.Set{"severity": "warning"}),
+			expected: map[string]string{
+				"cluster-monitoring-alert-kubepodcrashlooping": "Alert KubePodCrashLooping is firing",
+			},
+		},
+		{
+			name:     "no match",
+			selector: labels.SelectorFromSet(labels.Set{"severity": "info"}),
+			expected: map[string]string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := notifications(alerts, tc.selector)
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %d notifications, got %d", len(tc.expected), len(got))
+			}
+
+			for _, n := range got {
+				text, ok := tc.expected[n.GetName()]
+				if !ok {
+					t.Fatalf("unexpected notification %q", n.GetName())
+				}
+				if got, _, _ := unstructured.NestedString(n.Object, "spec", "text"); got != text {
+					t.Fatalf("expected text %q, got %q", text, got)
+				}
+				if n.GetLabels()[managedByLabel] != managedByValue || n.GetLabels()[componentLabel] != componentValue {
+					t.Fatalf("unexpected labels %v", n.GetLabels())
+				}
+			}
+		})
+	}
+
+	n := notifications(alerts[:1], labels.Everything())[0]
+	if href, _, _ := unstructured.NestedString(n.Object, "spec", "link", "href"); href != "https://example.com/KubeAPIDown.md" {
+		t.Fatalf("expected runbook link, got %q", href)
+	}
+	if bg, _, _ := unstructured.NestedString(n.Object, "spec", "backgroundColor"); bg != "#c9190b" {
+		t.Fatalf("expected critical background color, got %q", bg)
+	}
+}
+
+func TestNotificationsLimits(t *testing.T) {
+	var alerts []Alert
+	for i := 0; i < maxNotifications+2; i++ {
+		alerts = append(alerts, Alert{
+			Labels:      map[string]string{"alertname": fmt.Sprintf("Alert_%d:foo", i)},
+			Annotations: map[string]string{"summary": strings.Repeat("x", 2*maxTextLength)},
+		})
+	}
+
+	got := notifications(alerts, labels.Everything())
+	if len(got) != maxNotifications {
+		t.Fatalf("expected %d notifications, got %d", maxNotifications, len(got))
+	}
+	if got[0].GetName() != "cluster-monitoring-alert-alert-0-foo" {
+		t.Fatalf("unexpected name %q", got[0].GetName())
+	}
+	if text, _, _ := unstructured.NestedString(got[0].Object, "spec", "text"); len(text) != maxTextLength {
+		t.Fatalf("expected text to be truncated to %d characters, got %d", maxTextLength, len(text))
+	}
+}
+
+func TestNotificationTruncatesOnRuneBoundary(t *testing.T) {
+	got := notification("KubeAPIDown", []Alert{{
+		Labels:      map[string]string{"alertname": "KubeAPIDown"},
+		Annotations: map[string]string{"summary": "x" + strings.Repeat("é", maxTextLength)},
+	}})
+
+	text, _, _ := unstructured.NestedString(got.Object, "spec", "text")
+	if !utf8.ValidString(text) {
+		t.Fatalf("expected valid UTF-8 text, got %q", text)
+	}
+	if len(text) > maxTextLength {
+		t.Fatalf("expected text to be at most %d bytes, got %d", maxTextLength, len(text))
+	}
+	if !strings.HasSuffix(text, "...") {
+		t.Fatalf("expected truncated text to end with an ellipsis, got %q", text)
+	}
+}
+
+func TestParseAlertsResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		expected int
+		err      bool
+	}{
+		{
+			name:     "firing and pending alerts",
+			body:     `{"status":"success","data":{"alerts":[{"labels":{"alertname":"A"},"state":"firing"},{"labels":{"alertname":"B"},"state":"pending"}]}}`,
+			expected: 1,
+		},
+		{
+			name: "no alert",
+			body: `{"status":"success","data":{"alerts":[]}}`,
+		},
+		{
+			name: "error",
+			body: `{"status":"error","errorType":"internal","error":"boom"}`,
+			err:  true,
+		},
+		{
+			name: "invalid JSON",
+			body: `<html>`,
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			alerts, err := parseAlertsResponse(200, strings.NewReader(tc.body))
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(alerts) != tc.expected {
+				t.Fatalf("expected %d alerts, got %d", tc.expected, len(alerts))
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package footprint reports the resources consumed by the monitoring stack
+// itself so that administrators can plan the capacity of their clusters.
+package footprint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/promclient"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const queryTimeout = 30 * time.Second
+
+// Footprint is the resource footprint of the monitoring components of a
+// namespace.
+type Footprint struct {
+	Namespace string `json:"namespace,omitempty"`
+	// Pods is the number of running and pending pods.
+	Pods int `json:"pods"`
+	// CPURequests and MemoryRequests are the sums of the effective requests
+	// of the pods.
+	CPURequests    resource.Quantity `json:"cpuRequests"`
+	MemoryRequests resource.Quantity `json:"memoryRequests"`
+	// PersistentVolumeClaims is the number of PVCs and StorageRequests the
+	// sum of their storage requests.
+	PersistentVolumeClaims int               `json:"persistentVolumeClaims"`
+	StorageRequests        resource.Quantity `json:"storageRequests"`
+	// StorageUsedBytes is the space used on the persistent volumes as
+	// reported by the kubelets. It is omitted when Prometheus can't be
+	// queried.
+	StorageUsedBytes *float64 `json:"storageUsedBytes,omitempty"`
+	// HeadSeries is the number of series in the heads of the Prometheus and
+	// Thanos Ruler instances, the replicas being counted once. It is omitted
+	// when Prometheus can't be queried.
+	HeadSeries *float64 `json:"headSeries,omitempty"`
+}
+
+// Report is the resource footprint of the monitoring stack.
+type Report struct {
+	Namespaces []Footprint `json:"namespaces"`
+	Total      Footprint   `json:"total"`
+}
+
+// Reporter computes the resource footprint of the monitoring stack from the
+// Kubernetes API and the metrics of the stack.
+type Reporter struct {
+	kclient    kubernetes.Interface
+	querier    promclient.Querier
+	namespaces []string
+}
+
+// New returns a reporter for the given namespaces. The querier may be nil in
+// which case the storage usage and series counts are omitted.
+func New(kclient kubernetes.Interface, querier promclient.Querier, namespaces ...string) *Reporter {
+	return &Reporter{
+		kclient:    kclient,
+		querier:    querier,
+		namespaces: namespaces,
+	}
+}
+
+// Report returns the resource footprint of the monitoring stack.
+func (r *Reporter) Report(ctx context.Context) (*Report, error) {
+	report := &Report{
+		Namespaces: make([]Footprint, 0, len(r.namespaces)),
+		Total: Footprint{
+			CPURequests:     *resource.NewQuantity(0, resource.DecimalSI),
+			MemoryRequests:  *resource.NewQuantity(0, resource.BinarySI),
+			StorageRequests: *resource.NewQuantity(0, resource.BinarySI),
+		},
+	}
+
+	for _, ns := range r.namespaces {
+		fp, err := r.namespaceFootprint(ctx, ns)
+		if err != nil {
+			return nil, err
+		}
+		report.Namespaces = append(report.Namespaces, *fp)
+
+		report.Total.Pods += fp.Pods
+		report.Total.CPURequests.Add(fp.CPURequests)
+		report.Total.MemoryRequests.Add(fp.MemoryRequests)
+		report.Total.PersistentVolumeClaims += fp.PersistentVolumeClaims
+		report.Total.StorageRequests.Add(fp.StorageRequests)
+		report.Total.StorageUsedBytes = addOptional(report.Total.StorageUsedBytes, fp.StorageUsedBytes)
+		report.Total.HeadSeries = addOptional(report.Total.HeadSeries, fp.HeadSeries)
+	}
+
+	return report, nil
+}
+
+func (r *Reporter) namespaceFootprint(ctx context.Context, ns string) (*Footprint, error) {
+	fp := &Footprint{
+		Namespace:       ns,
+		CPURequests:     *resource.NewQuantity(0, resource.DecimalSI),
+		MemoryRequests:  *resource.NewQuantity(0, resource.BinarySI),
+		StorageRequests: *resource.NewQuantity(0, resource.BinarySI),
+	}
+
+	pods, err := r.kclient.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing pods in namespace %s failed", ns)
+	}
+
+	for _, p := range pods.Items {
+		if p.Status.Phase == v1.PodSucceeded || p.Status.Phase == v1.PodFailed {
+			continue
+		}
+
+		fp.Pods++
+		requests := podRequests(&p)
+		fp.CPURequests.Add(requests[v1.ResourceCPU])
+		fp.MemoryRequests.Add(requests[v1.ResourceMemory])
+	}
+
+	pvcs, err := r.kclient.CoreV1().PersistentVolumeClaims(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing PVCs in namespace %s failed", ns)
+	}
+
+	for _, pvc := range pvcs.Items {
+		fp.PersistentVolumeClaims++
+		fp.StorageRequests.Add(pvc.Spec.Resources.Requests[v1.ResourceStorage])
+	}
+
+	if r.querier == nil {
+		return fp, nil
+	}
+
+	fp.StorageUsedBytes = r.query(ctx, fmt.Sprintf(`sum(kubelet_volume_stats_used_bytes{namespace=%q})`, ns))
+	fp.HeadSeries = r.query(ctx, fmt.Sprintf(`sum(max by (job) (prometheus_tsdb_head_series{namespace=%q}))`, ns))
+
+	return fp, nil
+}
+
+// query returns the result of the query or nil if it fails, the missing
+// metrics shouldn't prevent reporting the rest of the footprint.
+func (r *Reporter) query(ctx context.Context, query string) *float64 {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	v, err := r.querier.Query(ctx, query)
+	if err != nil {
+		klog.V(4).Infof("footprint query %q failed: %v", query, err)
+		return nil
+	}
+
+	return &v
+}
+
+// podRequests returns the effective requests of the pod: the sum of the
+// requests of the containers or the highest request of the init containers,
+// whichever is greater.
+func podRequests(p *v1.Pod) v1.ResourceList {
+	requests := v1.ResourceList{}
+	for _, c := range p.Spec.Containers {
+		for name, q := range c.Resources.Requests {
+			sum := requests[name]
+			sum.Add(q)
+			requests[name] = sum
+		}
+	}
+
+	for _, c := range p.Spec.InitContainers {
+		for name, q := range c.Resources.Requests {
+			if current, found := requests[name]; !found || q.Cmp(current) > 0 {
+				requests[name] = q.DeepCopy()
+			}
+		}
+	}
+
+	return requests
+}
+
+func addOptional(a, b *float64) *float64 {
+	if b == nil {
+		return a
+	}
+
+	sum := *b
+	if a != nil {
+		sum += *a
+	}
+
+	return &sum
+}
+
+// Handler serves the report as JSON.
+func (r *Reporter) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report, err := r.Report(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			klog.Warningf("failed to write the footprint report: %v", err)
+		}
+	})
+}
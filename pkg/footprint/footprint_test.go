@@ -0,0 +1,150 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package footprint
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeQuerier map[string]float64
+
+func (f fakeQuerier) Query(_ context.Context, query string) (float64, error) {
+	for k, v := range f {
+		if strings.Contains(query, k) {
+			return v, nil
+		}
+	}
+	return 0, errors.New("empty result")
+}
+
+func pod(ns, name string, phase v1.PodPhase, cpu, mem string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "c",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse(cpu),
+						v1.ResourceMemory: resource.MustParse(mem),
+					},
+				},
+			}},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestReport(t *testing.T) {
+	initPod := pod("openshift-user-workload-monitoring", "init", v1.PodRunning, "10m", "10Mi")
+	initPod.Spec.InitContainers = []v1.Container{{
+		Name: "init",
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse("100Mi")},
+		},
+	}}
+
+	kclient := fake.NewSimpleClientset(
+		pod("openshift-monitoring", "prometheus-k8s-0", v1.PodRunning, "70m", "1Gi"),
+		pod("openshift-monitoring", "prometheus-k8s-1", v1.PodPending, "70m", "1Gi"),
+		pod("openshift-monitoring", "completed", v1.PodSucceeded, "1", "1Gi"),
+		initPod,
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-monitoring", Name: "prometheus-k8s-db-prometheus-k8s-0"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("40Gi")},
+				},
+			},
+		},
+	)
+
+	for _, tc := range []struct {
+		name    string
+		querier fakeQuerier
+	}{
+		{
+			name: "without querier",
+		},
+		{
+			name: "with querier",
+			querier: fakeQuerier{
+				"kubelet_volume_stats_used_bytes": 1 << 30,
+				"prometheus_tsdb_head_series":     1000,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := New(kclient, nil, "openshift-monitoring", "openshift-user-workload-monitoring")
+			if tc.querier != nil {
+				r = New(kclient, tc.querier, "openshift-monitoring", "openshift-user-workload-monitoring")
+			}
+
+			report, err := r.Report(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(report.Namespaces) != 2 {
+				t.Fatalf("expected 2 namespaces, got %d", len(report.Namespaces))
+			}
+
+			platform := report.Namespaces[0]
+			if platform.Pods != 2 {
+				t.Fatalf("expected 2 pods, got %d", platform.Pods)
+			}
+			if platform.CPURequests.Cmp(resource.MustParse("140m")) != 0 {
+				t.Fatalf("expected 140m CPU requests, got %s", platform.CPURequests.String())
+			}
+			if platform.StorageRequests.Cmp(resource.MustParse("40Gi")) != 0 {
+				t.Fatalf("expected 40Gi storage requests, got %s", platform.StorageRequests.String())
+			}
+
+			uwm := report.Namespaces[1]
+			if uwm.MemoryRequests.Cmp(resource.MustParse("100Mi")) != 0 {
+				t.Fatalf("expected the init container request to prevail, got %s", uwm.MemoryRequests.String())
+			}
+
+			if report.Total.Pods != 3 {
+				t.Fatalf("expected 3 pods in total, got %d", report.Total.Pods)
+			}
+			if report.Total.MemoryRequests.Cmp(resource.MustParse("2148Mi")) != 0 {
+				t.Fatalf("expected 2148Mi memory requests in total, got %s", report.Total.MemoryRequests.String())
+			}
+
+			if tc.querier == nil {
+				if report.Total.HeadSeries != nil || report.Total.StorageUsedBytes != nil {
+					t.Fatal("expected no metrics without querier")
+				}
+				return
+			}
+
+			if report.Total.HeadSeries == nil || *report.Total.HeadSeries != 2000 {
+				t.Fatalf("expected 2000 head series in total, got %v", report.Total.HeadSeries)
+			}
+			if report.Total.StorageUsedBytes == nil || *report.Total.StorageUsedBytes != 2*(1<<30) {
+				t.Fatalf("expected 2GiB used in total, got %v", report.Total.StorageUsedBytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+)
+
+type CollectionProfile string
+
+const (
+	// DefaultCollectionProfile scrapes the platform components with the
+	// relabelings shipped in the assets.
+	DefaultCollectionProfile CollectionProfile = "default"
+	// MinimalCollectionProfile only keeps the metrics required by the
+	// platform rules, dashboards and prometheus-adapter.
+	MinimalCollectionProfile CollectionProfile = "minimal"
+	// FullCollectionProfile removes the drop relabelings shipped in the
+	// assets and ingests everything exposed by the platform components.
+	FullCollectionProfile CollectionProfile = "full"
+)
+
+const (
+	kubeletComponent          = "kubelet"
+	nodeExporterComponent     = "node-exporter"
+	kubeStateMetricsComponent = "kube-state-metrics"
+)
+
+// minimalProfileMetrics lists per component the metrics referenced by the
+// alerting/recording rules and dashboards from the assets as well as by the
+// prometheus-adapter queries. It needs to be updated when new rules or
+// dashboards depend on other metrics.
+var minimalProfileMetrics = map[string][]string{
+	kubeletComponent: {
+		"container_cpu_cfs_periods_total",
+		"container_cpu_cfs_throttled_periods_total",
+		"container_cpu_usage_seconds_total",
+		"container_fs_reads_bytes_total",
+		"container_fs_reads_total",
+		"container_fs_usage_bytes",
+		"container_fs_writes_bytes_total",
+		"container_fs_writes_total",
+		"container_memory_cache",
+		"container_memory_rss",
+		"container_memory_swap",
+		"container_memory_usage_bytes",
+		"container_memory_working_set_bytes",
+		"container_network_receive_bytes_total",
+		"container_network_receive_packets_dropped_total",
+		"container_network_receive_packets_total",
+		"container_network_transmit_bytes_total",
+		"container_network_transmit_packets_dropped_total",
+		"container_network_transmit_packets_total",
+		"container_spec_cpu_shares",
+		"kubelet_certificate_manager_client_expiration_renew_errors",
+		"kubelet_containers_per_pod_count_sum",
+		"kubelet_node_name",
+		"kubelet_pleg_relist_duration_seconds_bucket",
+		"kubelet_pod_worker_duration_seconds_bucket",
+		"kubelet_server_expiration_renew_errors",
+		"kubelet_volume_stats_available_bytes",
+		"kubelet_volume_stats_capacity_bytes",
+		"kubelet_volume_stats_used_bytes",
+		"machine_cpu_cores",
+		"machine_memory_bytes",
+	},
+	nodeExporterComponent: {
+		"node_cpu_info",
+		"node_cpu_seconds_total",
+		"node_disk_io_time_seconds_total",
+		"node_disk_io_time_weighted_seconds_total",
+		"node_filefd_allocated",
+		"node_filefd_maximum",
+		"node_filesystem_avail_bytes",
+		"node_filesystem_files",
+		"node_filesystem_files_free",
+		"node_filesystem_readonly",
+		"node_filesystem_size_bytes",
+		"node_load1",
+		"node_md_disks",
+		"node_md_disks_required",
+		"node_memory_Buffers_bytes",
+		"node_memory_Cached_bytes",
+		"node_memory_MemAvailable_bytes",
+		"node_memory_MemFree_bytes",
+		"node_memory_MemTotal_bytes",
+		"node_memory_Slab_bytes",
+		"node_netstat_TcpExt_TCPSynRetrans",
+		"node_netstat_Tcp_OutSegs",
+		"node_netstat_Tcp_RetransSegs",
+		"node_network_receive_bytes_total",
+		"node_network_receive_drop_total",
+		"node_network_receive_errs_total",
+		"node_network_receive_packets_total",
+		"node_network_transmit_bytes_total",
+		"node_network_transmit_drop_total",
+		"node_network_transmit_errs_total",
+		"node_network_transmit_packets_total",
+		"node_network_up",
+		"node_nf_conntrack_entries",
+		"node_nf_conntrack_entries_limit",
+		"node_textfile_scrape_error",
+		"node_time_seconds",
+		"node_timex_maxerror_seconds",
+		"node_timex_offset_seconds",
+		"node_timex_sync_status",
+		"node_vmstat_pgmajfault",
+	},
+	kubeStateMetricsComponent: {
+		"kube_daemonset_status_current_number_scheduled",
+		"kube_daemonset_status_desired_number_scheduled",
+		"kube_daemonset_status_number_available",
+		"kube_daemonset_status_number_misscheduled",
+		"kube_daemonset_updated_number_scheduled",
+		"kube_deployment_metadata_generation",
+		"kube_deployment_spec_replicas",
+		"kube_deployment_status_observed_generation",
+		"kube_deployment_status_replicas_available",
+		"kube_deployment_status_replicas_updated",
+		"kube_horizontalpodautoscaler_spec_max_replicas",
+		"kube_horizontalpodautoscaler_spec_min_replicas",
+		"kube_horizontalpodautoscaler_status_current_replicas",
+		"kube_horizontalpodautoscaler_status_desired_replicas",
+		"kube_job_failed",
+		"kube_job_spec_completions",
+		"kube_job_status_succeeded",
+		"kube_node_labels",
+		"kube_node_role",
+		"kube_node_spec_taint",
+		"kube_node_spec_unschedulable",
+		"kube_node_status_allocatable",
+		"kube_node_status_capacity",
+		"kube_node_status_condition",
+		"kube_persistentvolume_status_phase",
+		"kube_persistentvolumeclaim_access_mode",
+		"kube_persistentvolumeclaim_info",
+		"kube_persistentvolumeclaim_labels",
+		"kube_persistentvolumeclaim_resource_requests_storage_bytes",
+		"kube_pod_container_resource_limits",
+		"kube_pod_container_resource_requests",
+		"kube_pod_container_status_last_terminated_reason",
+		"kube_pod_container_status_restarts_total",
+		"kube_pod_container_status_waiting_reason",
+		"kube_pod_info",
+		"kube_pod_owner",
+		"kube_pod_restart_policy",
+		"kube_pod_status_phase",
+		"kube_pod_status_ready",
+		"kube_replicaset_owner",
+		"kube_replicationcontroller_owner",
+		"kube_resourcequota",
+		"kube_running_pod_ready",
+		"kube_state_metrics_list_total",
+		"kube_state_metrics_watch_total",
+		"kube_statefulset_metadata_generation",
+		"kube_statefulset_replicas",
+		"kube_statefulset_status_current_revision",
+		"kube_statefulset_status_observed_generation",
+		"kube_statefulset_status_replicas",
+		"kube_statefulset_status_replicas_ready",
+		"kube_statefulset_status_replicas_updated",
+		"kube_statefulset_status_update_revision",
+		"kube_storageclass_info",
+	},
+}
+
+func (f *Factory) collectionProfile() (CollectionProfile, error) {
+	switch p := CollectionProfile(f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.CollectionProfile); p {
+	case "":
+		return DefaultCollectionProfile, nil
+	case DefaultCollectionProfile, MinimalCollectionProfile, FullCollectionProfile:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%w - collection profile: %q", ErrConfigValidation, p)
+	}
+}
+
+// applyCollectionProfile updates the metric relabelings of all the
+// ServiceMonitor endpoints according to the configured collection profile.
+func (f *Factory) applyCollectionProfile(sm *monv1.ServiceMonitor, component string) error {
+	profile, err := f.collectionProfile()
+	if err != nil {
+		return err
+	}
+
+	switch profile {
+	case MinimalCollectionProfile:
+		names := make([]string, 0, len(minimalProfileMetrics[component]))
+		for _, n := range minimalProfileMetrics[component] {
+			names = append(names, regexp.QuoteMeta(n))
+		}
+
+		for i := range sm.Spec.Endpoints {
+			sm.Spec.Endpoints[i].MetricRelabelConfigs = append(sm.Spec.Endpoints[i].MetricRelabelConfigs, &monv1.RelabelConfig{
+				Action:       "keep",
+				SourceLabels: []string{"__name__"},
+				Regex:        fmt.Sprintf("(%s)", strings.Join(names, "|")),
+			})
+		}
+	case FullCollectionProfile:
+		for i := range sm.Spec.Endpoints {
+			var relabelings []*monv1.RelabelConfig
+			for _, r := range sm.Spec.Endpoints[i].MetricRelabelConfigs {
+				if r.Action == "drop" {
+					continue
+				}
+				relabelings = append(relabelings, r)
+			}
+			sm.Spec.Endpoints[i].MetricRelabelConfigs = relabelings
+		}
+	}
+
+	return nil
+}
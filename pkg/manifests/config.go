@@ -18,11 +18,24 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
 	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	promlabels "github.com/prometheus/prometheus/model/labels"
+	promql "github.com/prometheus/prometheus/promql/parser"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
 	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
 )
@@ -34,6 +47,23 @@ const (
 type Config struct {
 	Images      *Images `json:"-"`
 	RemoteWrite bool    `json:"-"`
+	// RouteAPIUnavailable is true when the cluster doesn't serve the
+	// route.openshift.io API. Routes aren't reconciled in this case.
+	RouteAPIUnavailable bool `json:"-"`
+	// UserWorkloadNamespaceTerminating is true when the user workload
+	// monitoring namespace is being deleted. The objects of the namespace
+	// aren't reconciled in this case.
+	UserWorkloadNamespaceTerminating bool `json:"-"`
+	// Uninstalling is true when the removal of the operator is requested
+	// with teardown.uninstall. The optional components are torn down in this
+	// case (see DisableOptionalComponents).
+	Uninstalling bool `json:"-"`
+	// OperatorVersion is the version of the operator, it labels the
+	// PrometheusRules and the dashboards shipped by the operator.
+	OperatorVersion string `json:"-"`
+	// UnknownFields lists the fields unknown to the operator by key of the
+	// configuration ConfigMap holding them.
+	UnknownFields map[string][]string `json:"-"`
 
 	ClusterMonitoringConfiguration *ClusterMonitoringConfiguration `json:"-"`
 	UserWorkloadConfiguration      *UserWorkloadConfiguration      `json:"-"`
@@ -96,6 +126,7 @@ type ClusterMonitoringConfiguration struct {
 	PrometheusOperatorConfig *PrometheusOperatorConfig    `json:"prometheusOperator"`
 	PrometheusK8sConfig      *PrometheusK8sConfig         `json:"prometheusK8s"`
 	AlertmanagerMainConfig   *AlertmanagerMainConfig      `json:"alertmanagerMain"`
+	NodeExporterConfig       *NodeExporterConfig          `json:"nodeExporter"`
 	KubeStateMetricsConfig   *KubeStateMetricsConfig      `json:"kubeStateMetrics"`
 	OpenShiftMetricsConfig   *OpenShiftStateMetricsConfig `json:"openshiftStateMetrics"`
 	GrafanaConfig            *GrafanaConfig               `json:"grafana"`
@@ -103,8 +134,55 @@ type ClusterMonitoringConfiguration struct {
 	HTTPConfig               *HTTPConfig                  `json:"http"`
 	TelemeterClientConfig    *TelemeterClientConfig       `json:"telemeterClient"`
 	K8sPrometheusAdapter     *K8sPrometheusAdapter        `json:"k8sPrometheusAdapter"`
+	MetricsServerConfig      *MetricsServerConfig         `json:"metricsServer"`
 	ThanosQuerierConfig      *ThanosQuerierConfig         `json:"thanosQuerier"`
+	ThanosReceiveConfig      *ThanosReceiveConfig         `json:"thanosReceive"`
 	UserWorkloadEnabled      *bool                        `json:"enableUserWorkload"`
+	ConsoleNotifications     *ConsoleNotificationsConfig  `json:"consoleNotifications"`
+	ConsoleDashboards        *ConsoleDashboardsConfig     `json:"consoleDashboards"`
+	HostedControlPlane       *HostedControlPlaneConfig    `json:"hostedControlPlane"`
+	// UserWorkloadTenancy configures the isolation of the user-defined
+	// alerts.
+	UserWorkloadTenancy *UserWorkloadTenancyConfig `json:"userWorkloadTenancy"`
+	// QueryCanary runs representative queries against Thanos Querier to
+	// detect the regressions of the query path.
+	QueryCanary *QueryCanaryConfig `json:"queryCanary"`
+	// Teardown configures the removal of the disabled components.
+	Teardown *TeardownConfig `json:"teardown"`
+	// Overrides sets the management state of individual components.
+	Overrides []ComponentOverride `json:"overrides"`
+	// ExcludedRules removes shipped alerting rules or rule groups from the
+	// platform PrometheusRule objects.
+	ExcludedRules []ExcludedRule `json:"excludedRules"`
+	// Strict fails the reconciliation when the configuration ConfigMaps
+	// hold fields unknown to the operator instead of only reporting them.
+	Strict bool `json:"strict"`
+}
+
+// ExcludedRule selects the platform rules to exclude. When only the group is
+// set, the whole rule group is removed. When only the alert is set, the
+// alerting rule is removed from all the groups.
+type ExcludedRule struct {
+	Group string `json:"group"`
+	Alert string `json:"alert"`
+}
+
+func (e ExcludedRule) String() string {
+	switch {
+	case e.Alert == "":
+		return fmt.Sprintf("group %s", e.Group)
+	case e.Group == "":
+		return fmt.Sprintf("alert %s", e.Alert)
+	default:
+		return fmt.Sprintf("alert %s in group %s", e.Alert, e.Group)
+	}
+}
+
+func (e ExcludedRule) matches(group, alert string) bool {
+	if e.Group != "" && e.Group != group {
+		return false
+	}
+	return e.Alert == "" || e.Alert == alert
 }
 
 type Images struct {
@@ -122,8 +200,14 @@ type Images struct {
 	KubeRbacProxy            string
 	TelemeterClient          string
 	Thanos                   string
+	MetricsServer            string
 }
 
+// HTTPConfig is the proxy configuration used when the cluster-wide proxy
+// configuration can't be read.
+//
+// Deprecated: configure the cluster-wide proxy instead, and
+// telemeterClient.proxy to override it for the telemetry traffic.
 type HTTPConfig struct {
 	HTTPProxy  string `json:"httpProxy"`
 	HTTPSProxy string `json:"httpsProxy"`
@@ -131,9 +215,10 @@ type HTTPConfig struct {
 }
 
 type PrometheusOperatorConfig struct {
-	LogLevel     string            `json:"logLevel"`
-	NodeSelector map[string]string `json:"nodeSelector"`
-	Tolerations  []v1.Toleration   `json:"tolerations"`
+	LogLevel          string            `json:"logLevel"`
+	NodeSelector      map[string]string `json:"nodeSelector"`
+	Tolerations       []v1.Toleration   `json:"tolerations"`
+	PriorityClassName string            `json:"priorityClassName"`
 }
 
 // RemoteWriteSpec is almost a 1to1 copy of monv1.RemoteWriteSpec but with the
@@ -150,6 +235,10 @@ type RemoteWriteSpec struct {
 	// Custom HTTP headers to be sent along with each remote write request.
 	// Be aware that headers that are set by Prometheus itself can't be overwritten.
 	// Only valid in Prometheus versions 2.25.0 and newer.
+	// The values are Go templates which can reference the cluster metadata
+	// ({{ .ClusterID }}, {{ .PlatformType }}, {{ .Region }} and
+	// {{ .BaseDomain }}), e.g. to set the X-Scope-OrgID header of
+	// multi-tenant backends.
 	Headers map[string]string `json:"headers,omitempty"`
 	// The list of remote write relabel configurations.
 	WriteRelabelConfigs []monv1.RelabelConfig `json:"writeRelabelConfigs,omitempty"`
@@ -179,6 +268,91 @@ type PrometheusK8sConfig struct {
 	TelemetryMatches    []string                             `json:"-"`
 	AlertmanagerConfigs []AdditionalAlertmanagerConfig       `json:"additionalAlertmanagerConfigs"`
 	QueryLogFile        string                               `json:"queryLogFile"`
+	ExternalURL         string                               `json:"externalURL"`
+	// CollectionProfile defines the metrics collected from the platform
+	// components: minimal, default or full.
+	CollectionProfile string `json:"collectionProfile"`
+	// ResourceRecommendation enables the automatic adjustment of the
+	// memory request from the observed usage.
+	ResourceRecommendation *ResourceRecommendationConfig `json:"resourceRecommendation"`
+	// MinReadySeconds is the minimum number of seconds for which a new pod
+	// should be ready before it is considered available.
+	MinReadySeconds *uint32 `json:"minReadySeconds"`
+	// ReadinessProbe and StartupProbe override the timings of the probes of
+	// the prometheus container.
+	ReadinessProbe *ProbeConfig `json:"readinessProbe"`
+	StartupProbe   *ProbeConfig `json:"startupProbe"`
+	// DarkLaunchAlerts overrides the dark launch mode of the platform alerts
+	// by alert name. Dark launched alerts are evaluated but not sent to
+	// Alertmanager.
+	DarkLaunchAlerts map[string]bool `json:"darkLaunchAlerts"`
+	// EnableFeatures lists the Prometheus feature flags to enable. Only the
+	// features of the allow-list are accepted.
+	EnableFeatures []string `json:"enableFeatures"`
+	// QueryTimeout is the maximum time to process a query.
+	QueryTimeout string `json:"queryTimeout"`
+	// QueryMaxSamples is the maximum number of samples a single query can
+	// load into memory.
+	QueryMaxSamples *uint32 `json:"queryMaxSamples"`
+	// QueryMaxConcurrency is the maximum number of queries executed
+	// concurrently.
+	QueryMaxConcurrency *uint32 `json:"queryMaxConcurrency"`
+	// WAL tunes the write-ahead log to shorten the restarts.
+	WAL *PrometheusWALConfig `json:"wal"`
+	// ExternalLabelNames renames the external labels identifying the
+	// Prometheus instances and their replicas, for both the platform and the
+	// user workload Prometheus.
+	ExternalLabelNames *ExternalLabelNamesConfig `json:"externalLabelNames"`
+	// Secrets and ConfigMaps list the objects of the operator namespace
+	// mounted into the Prometheus pods, e.g. to hold the credentials used by
+	// the scrape configurations.
+	Secrets    []string `json:"secrets"`
+	ConfigMaps []string `json:"configMaps"`
+}
+
+// ExternalLabelNamesConfig defines the names of the external labels added by
+// the Prometheus operator. Empty names keep the defaults.
+type ExternalLabelNamesConfig struct {
+	// Prometheus is the name of the label identifying the Prometheus
+	// instance. Defaults to "prometheus".
+	Prometheus string `json:"prometheus"`
+	// Replica is the name of the label identifying the Prometheus replica,
+	// which is removed when deduplicating the series and the alerts.
+	// Defaults to "prometheus_replica".
+	Replica string `json:"replica"`
+}
+
+// PrometheusWALConfig tunes the write-ahead log of Prometheus. Restarting
+// Prometheus replays the write-ahead log to rebuild the in-memory series
+// which can take a long time on big TSDBs.
+type PrometheusWALConfig struct {
+	// Compression compresses the write-ahead log records, reducing the
+	// volume read during the replay at the cost of some CPU.
+	Compression *bool `json:"compression"`
+	// MemorySnapshotOnShutdown writes the in-memory series to disk on
+	// shutdown so that the next start only replays the records written after
+	// the snapshot. It enables the memory-snapshot-on-shutdown feature flag.
+	MemorySnapshotOnShutdown bool `json:"memorySnapshotOnShutdown"`
+}
+
+// ProbeConfig overrides the timings of a container probe. Zero values keep
+// the defaults.
+type ProbeConfig struct {
+	TimeoutSeconds   int32 `json:"timeoutSeconds"`
+	PeriodSeconds    int32 `json:"periodSeconds"`
+	FailureThreshold int32 `json:"failureThreshold"`
+}
+
+type ResourceRecommendationConfig struct {
+	Enabled bool `json:"enabled"`
+	// The memory request is never set below this value.
+	MinMemoryRequest *resource.Quantity `json:"minMemoryRequest,omitempty"`
+	// The memory request is never set above this value.
+	MaxMemoryRequest *resource.Quantity `json:"maxMemoryRequest,omitempty"`
+}
+
+func (r *ResourceRecommendationConfig) IsEnabled() bool {
+	return r != nil && r.Enabled
 }
 
 type AdditionalAlertmanagerConfig struct {
@@ -219,12 +393,106 @@ type AlertmanagerMainConfig struct {
 	Tolerations         []v1.Toleration                      `json:"tolerations"`
 	Resources           *v1.ResourceRequirements             `json:"resources"`
 	VolumeClaimTemplate *monv1.EmbeddedPersistentVolumeClaim `json:"volumeClaimTemplate"`
+	ExternalURL         string                               `json:"externalURL"`
+	Retention           string                               `json:"retention"`
+	Secrets             []string                             `json:"secrets"`
+	ConfigMaps          []string                             `json:"configMaps"`
+	UseClusterProxy     *bool                                `json:"useClusterProxy"`
+	// RouteDefaults are merged into the root route of the Alertmanager
+	// configuration.
+	RouteDefaults *AlertmanagerRouteDefaults `json:"routeDefaults"`
+	// UserAlertsThrottling aggregates the user-defined alerts per namespace
+	// so that they can't delay the notifications of the platform alerts.
+	UserAlertsThrottling *AlertmanagerUserAlertsThrottling `json:"userAlertsThrottling"`
+	// ReceiversTLS overrides the TLS settings of the HTTP clients of the
+	// receivers of the Alertmanager configuration.
+	ReceiversTLS []AlertmanagerReceiverTLSConfig `json:"receiversTLS"`
+}
+
+// AlertmanagerReceiverTLSConfig defines the TLS settings used by all the
+// notification integrations of a receiver. The referenced Secrets are copied
+// into the Alertmanager namespace by the operator.
+type AlertmanagerReceiverTLSConfig struct {
+	// Receiver is the name of the receiver in the Alertmanager
+	// configuration.
+	Receiver string `json:"receiver"`
+	// Namespace of the referenced Secrets. Defaults to the Alertmanager
+	// namespace.
+	Namespace string    `json:"namespace"`
+	TLSConfig TLSConfig `json:"tlsConfig"`
+}
+
+// AlertmanagerUserAlertsThrottling defines the notification timings of the
+// user-defined alerts. The alerts of a namespace are grouped together and
+// notified at most once per group interval, the routing of the user
+// configuration still selects the receivers.
+type AlertmanagerUserAlertsThrottling struct {
+	GroupWait      string `json:"groupWait"`
+	GroupInterval  string `json:"groupInterval"`
+	RepeatInterval string `json:"repeatInterval"`
+}
+
+// AlertmanagerRouteDefaults defines the grouping and the notification timings
+// of the root route of the Alertmanager configuration. They only apply when
+// the root route doesn't define them and they are inherited by the child
+// routes.
+type AlertmanagerRouteDefaults struct {
+	GroupBy        []string `json:"groupBy"`
+	GroupWait      string   `json:"groupWait"`
+	GroupInterval  string   `json:"groupInterval"`
+	RepeatInterval string   `json:"repeatInterval"`
 }
 
 func (a AlertmanagerMainConfig) IsEnabled() bool {
 	return a.Enabled == nil || *a.Enabled
 }
 
+// HasRenderedConfig returns whether Alertmanager runs a configuration
+// rendered from the user-provided one.
+func (a AlertmanagerMainConfig) HasRenderedConfig() bool {
+	return a.RouteDefaults != nil || a.UserAlertsThrottling != nil || len(a.ReceiversTLS) > 0
+}
+
+// UsesClusterProxy returns whether the cluster-wide proxy settings should be
+// injected into the Alertmanager container so that receivers can reach
+// external endpoints. It is disabled unless explicitly requested.
+func (a AlertmanagerMainConfig) UsesClusterProxy() bool {
+	return a.UseClusterProxy != nil && *a.UseClusterProxy
+}
+
+// ConsoleNotificationsConfig controls the conversion of firing platform alerts
+// into notification banners of the web console.
+type ConsoleNotificationsConfig struct {
+	Enabled bool `json:"enabled"`
+	// AlertSelector selects the alerts to display by their labels. Defaults to
+	// critical alerts.
+	AlertSelector *metav1.LabelSelector `json:"alertSelector"`
+}
+
+// ConsoleDashboardsConfig selects the dashboards deployed for the web console.
+type ConsoleDashboardsConfig struct {
+	// Excluded lists the names of the shipped dashboard ConfigMaps (e.g.
+	// "grafana-dashboard-etcd") removed from the console.
+	Excluded []string `json:"excluded"`
+	// AdditionalDashboardsNamespace is the namespace whose dashboard
+	// ConfigMaps are copied to the console.
+	AdditionalDashboardsNamespace string `json:"additionalDashboardsNamespace"`
+}
+
+// Selector returns the label selector matching the alerts to display.
+func (c *ConsoleNotificationsConfig) Selector() (labels.Selector, error) {
+	if c.AlertSelector == nil {
+		return labels.SelectorFromSet(labels.Set{"severity": "critical"}), nil
+	}
+
+	sel, err := metav1.LabelSelectorAsSelector(c.AlertSelector)
+	if err != nil {
+		return nil, fmt.Errorf("%w - consoleNotifications alertSelector: %v", ErrConfigValidation, err)
+	}
+
+	return sel, nil
+}
+
 type ThanosRulerConfig struct {
 	LogLevel             string                               `json:"logLevel"`
 	NodeSelector         map[string]string                    `json:"nodeSelector"`
@@ -232,6 +500,13 @@ type ThanosRulerConfig struct {
 	Resources            *v1.ResourceRequirements             `json:"resources"`
 	VolumeClaimTemplate  *monv1.EmbeddedPersistentVolumeClaim `json:"volumeClaimTemplate"`
 	AlertmanagersConfigs []AdditionalAlertmanagerConfig       `json:"additionalAlertmanagerConfigs"`
+	PriorityClassName    string                               `json:"priorityClassName"`
+	// EvaluationInterval is the interval between consecutive rule
+	// evaluations. Defaults to 15s.
+	EvaluationInterval string `json:"evaluationInterval"`
+	// Replicas is the number of Thanos Ruler replicas. Defaults to 2 on
+	// highly available infrastructures and 1 otherwise.
+	Replicas *int32 `json:"replicas"`
 }
 
 type ThanosQuerierConfig struct {
@@ -239,12 +514,91 @@ type ThanosQuerierConfig struct {
 	NodeSelector map[string]string        `json:"nodeSelector"`
 	Tolerations  []v1.Toleration          `json:"tolerations"`
 	Resources    *v1.ResourceRequirements `json:"resources"`
+	// MaxConcurrentQueries is the maximum number of queries processed
+	// concurrently.
+	MaxConcurrentQueries *uint32 `json:"maxConcurrentQueries"`
+	// QueryTimeout is the maximum time to process a query.
+	QueryTimeout string `json:"queryTimeout"`
+	// LookbackDelta is the maximum lookback duration for retrieving metrics
+	// during expression evaluations.
+	LookbackDelta string `json:"lookbackDelta"`
+	// AdditionalStores are external StoreAPI endpoints (e.g. Thanos Store or
+	// Thanos Receive) queried in addition to the in-cluster stores.
+	AdditionalStores []ThanosQuerierStoreConfig `json:"additionalStores"`
+}
+
+// ThanosQuerierStoreConfig defines an external StoreAPI endpoint and the TLS
+// settings of its gRPC connection. The referenced Secrets are copied into the
+// Thanos Querier namespace by the operator.
+type ThanosQuerierStoreConfig struct {
+	// Address is the gRPC address of the store as host:port.
+	Address string `json:"address"`
+	// Namespace of the referenced Secrets. Defaults to the Thanos Querier
+	// namespace.
+	Namespace string `json:"namespace"`
+	// TLSConfig enables TLS for the gRPC connection. The connection is in
+	// plain text when omitted.
+	TLSConfig *TLSConfig `json:"tlsConfig"`
+}
+
+// ThanosReceiveConfig configures the optional Thanos Receive component which
+// accepts metrics pushed by other clusters through remote write.
+type ThanosReceiveConfig struct {
+	Enabled bool `json:"enabled"`
+	// Replicas is the number of Thanos Receive pods in the hashring.
+	// Defaults to 1.
+	Replicas *int32 `json:"replicas"`
+	// ReplicationFactor is the number of replicas each sample is written
+	// to. It must not exceed the number of replicas. Defaults to 1.
+	ReplicationFactor *int32 `json:"replicationFactor"`
+	// TenantHeader is the HTTP header identifying the tenant of a remote
+	// write request. Defaults to THANOS-TENANT.
+	TenantHeader string `json:"tenantHeader"`
+	// Tenants restricts the tenants allowed to write. Requests for other
+	// tenants, including requests without the tenant header, are rejected.
+	// All tenants are accepted when empty.
+	Tenants []string `json:"tenants"`
+	// Retention is the duration for which the received metrics are kept.
+	// Defaults to 15d.
+	Retention           string                               `json:"retention"`
+	LogLevel            string                               `json:"logLevel"`
+	NodeSelector        map[string]string                    `json:"nodeSelector"`
+	Tolerations         []v1.Toleration                      `json:"tolerations"`
+	Resources           *v1.ResourceRequirements             `json:"resources"`
+	VolumeClaimTemplate *monv1.EmbeddedPersistentVolumeClaim `json:"volumeClaimTemplate"`
+}
+
+// IsEnabled returns whether Thanos Receive should be deployed. It is disabled
+// by default.
+func (t *ThanosReceiveConfig) IsEnabled() bool {
+	return t != nil && t.Enabled
+}
+
+// MetricsServerConfig configures metrics-server which serves the resource
+// metrics API (metrics.k8s.io) instead of prometheus-adapter when enabled.
+// metrics-server collects the resource usage directly from the kubelets
+// which removes the queries of the resource metrics API from Prometheus.
+type MetricsServerConfig struct {
+	Enabled      bool                     `json:"enabled"`
+	NodeSelector map[string]string        `json:"nodeSelector"`
+	Tolerations  []v1.Toleration          `json:"tolerations"`
+	Resources    *v1.ResourceRequirements `json:"resources"`
+}
+
+// IsEnabled returns whether metrics-server should be deployed. It is disabled
+// by default.
+func (m *MetricsServerConfig) IsEnabled() bool {
+	return m != nil && m.Enabled
 }
 
 type GrafanaConfig struct {
 	Enabled      *bool             `json:"enabled"`
 	NodeSelector map[string]string `json:"nodeSelector"`
 	Tolerations  []v1.Toleration   `json:"tolerations"`
+	// AdditionalDatasources references the secret keys holding the
+	// definitions of extra datasources provisioned in Grafana. The secrets
+	// must be in the openshift-monitoring namespace.
+	AdditionalDatasources []v1.SecretKeySelector `json:"additionalDatasources"`
 }
 
 // IsEnabled returns the underlying value of the `Enabled` boolean pointer.  It
@@ -260,11 +614,119 @@ func (g *GrafanaConfig) IsEnabled() bool {
 type KubeStateMetricsConfig struct {
 	NodeSelector map[string]string `json:"nodeSelector"`
 	Tolerations  []v1.Toleration   `json:"tolerations"`
+	// CustomResourceStateConfig references the ConfigMap key holding the
+	// custom resource state configuration of kube-state-metrics, which
+	// generates metrics from the fields of custom resources. The ConfigMap
+	// must be in the openshift-monitoring namespace.
+	CustomResourceStateConfig *v1.ConfigMapKeySelector `json:"customResourceStateConfig"`
+	// Shards is the number of kube-state-metrics instances among which the
+	// Kubernetes objects are split on very large clusters. Defaults to 1.
+	Shards *int32 `json:"shards"`
+}
+
+// ShardCount returns the number of kube-state-metrics shards.
+func (k *KubeStateMetricsConfig) ShardCount() (int32, error) {
+	if k.Shards == nil {
+		return 1, nil
+	}
+
+	if *k.Shards < 1 {
+		return 0, fmt.Errorf("%w - kubeStateMetrics shards must be at least 1: %d", ErrConfigValidation, *k.Shards)
+	}
+
+	return *k.Shards, nil
+}
+
+// NodeExporterConfig bounds the resources used by node-exporter which runs
+// on every node.
+type NodeExporterConfig struct {
+	Resources *v1.ResourceRequirements `json:"resources"`
+	// MaxProcs is the maximum number of CPUs used by node-exporter
+	// (GOMAXPROCS). Defaults to the node-exporter default (1).
+	MaxProcs *uint32 `json:"maxProcs"`
+	// Textfile adds custom sources to the textfile collector.
+	Textfile *NodeExporterTextfileConfig `json:"textfile"`
+}
+
+// NodeExporterTextfileConfig defines where the textfile collector of
+// node-exporter finds the custom *.prom files in addition to the files
+// generated at startup.
+type NodeExporterTextfileConfig struct {
+	// HostPath is a directory of the nodes holding the *.prom files, e.g.
+	// written by firmware or hardware RAID tools. It replaces the ephemeral
+	// textfile directory of the pods.
+	HostPath string `json:"hostPath"`
+	// ConfigMap is the name of a ConfigMap in the openshift-monitoring
+	// namespace whose keys ending with .prom are exposed on every node.
+	ConfigMap string `json:"configMap"`
 }
 
 type OpenShiftStateMetricsConfig struct {
+	Enabled      *bool             `json:"enabled"`
 	NodeSelector map[string]string `json:"nodeSelector"`
 	Tolerations  []v1.Toleration   `json:"tolerations"`
+	// DisabledCollectors lists the collectors of openshift-state-metrics
+	// to disable, e.g. builds and deploymentconfigs on clusters which don't
+	// use them.
+	DisabledCollectors []string `json:"disabledCollectors"`
+	// ResyncPeriod is the interval at which the informers of
+	// openshift-state-metrics relist the resources.
+	ResyncPeriod string `json:"resyncPeriod"`
+}
+
+// openShiftStateMetricsCollectors is the list of the collectors enabled by
+// default in openshift-state-metrics.
+var openShiftStateMetricsCollectors = []string{
+	"buildconfigs",
+	"builds",
+	"clusterresourcequotas",
+	"deploymentconfigs",
+	"groups",
+	"routes",
+}
+
+// Collectors returns the openshift-state-metrics collectors to enable or nil
+// when none is disabled.
+func (o *OpenShiftStateMetricsConfig) Collectors() ([]string, error) {
+	if len(o.DisabledCollectors) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]struct{}, len(openShiftStateMetricsCollectors))
+	for _, c := range openShiftStateMetricsCollectors {
+		known[c] = struct{}{}
+	}
+
+	disabled := make(map[string]struct{}, len(o.DisabledCollectors))
+	for _, c := range o.DisabledCollectors {
+		if _, found := known[c]; !found {
+			return nil, fmt.Errorf("%w - openshiftStateMetrics disabledCollectors: unknown collector %q, must be one of %s", ErrConfigValidation, c, strings.Join(openShiftStateMetricsCollectors, ", "))
+		}
+		disabled[c] = struct{}{}
+	}
+
+	var collectors []string
+	for _, c := range openShiftStateMetricsCollectors {
+		if _, found := disabled[c]; !found {
+			collectors = append(collectors, c)
+		}
+	}
+
+	if len(collectors) == 0 {
+		return nil, fmt.Errorf("%w - openshiftStateMetrics disabledCollectors: at least one collector must be enabled, disable openshift-state-metrics instead", ErrConfigValidation)
+	}
+
+	return collectors, nil
+}
+
+// IsEnabled returns the underlying value of the `Enabled` boolean pointer. It
+// defaults to TRUE if the pointer is nil because openshift-state-metrics
+// should be enabled by default.
+func (o *OpenShiftStateMetricsConfig) IsEnabled() bool {
+	if o.Enabled == nil {
+		return true
+	}
+	return *o.Enabled
 }
 
 // Prometheus Adapater related configurations
@@ -274,6 +736,83 @@ type K8sPrometheusAdapter struct {
 
 	// Prometheus Adapter audit logging related configuration
 	Audit *Audit `json:"audit"`
+
+	// QueryBackend selects the API queried by prometheus-adapter:
+	// thanos-querier (default) or prometheus-k8s. Querying prometheus-k8s
+	// directly avoids the latency added by Thanos Querier to every HPA query
+	// at the cost of not deduplicating the data of the Prometheus replicas.
+	QueryBackend string `json:"queryBackend"`
+
+	// CustomMetricsRules are the prometheus-adapter rules serving the
+	// custom metrics API (custom.metrics.k8s.io). The API is registered
+	// only when at least one rule is defined.
+	CustomMetricsRules []PrometheusAdapterRule `json:"customMetricsRules"`
+	// ExternalMetricsRules are the prometheus-adapter rules serving the
+	// external metrics API (external.metrics.k8s.io). The API is registered
+	// only when at least one rule is defined.
+	ExternalMetricsRules []PrometheusAdapterRule `json:"externalMetricsRules"`
+}
+
+// IsEnabled returns whether prometheus-adapter should be deployed. It is
+// only needed for the custom and external metrics APIs when the resource
+// metrics API is served by metrics-server.
+func (a *K8sPrometheusAdapter) IsEnabled(metricsServer *MetricsServerConfig) bool {
+	if !metricsServer.IsEnabled() {
+		return true
+	}
+
+	return len(a.CustomMetricsRules) > 0 || len(a.ExternalMetricsRules) > 0
+}
+
+const (
+	PrometheusAdapterQueryBackendThanosQuerier = "thanos-querier"
+	PrometheusAdapterQueryBackendPrometheusK8s = "prometheus-k8s"
+)
+
+// PrometheusAdapterRule mirrors a discovery rule of the prometheus-adapter
+// configuration.
+//
+// see: https://github.com/kubernetes-sigs/prometheus-adapter/blob/master/docs/config.md
+type PrometheusAdapterRule struct {
+	// SeriesQuery is the Prometheus series query used to discover the
+	// metrics.
+	SeriesQuery string `json:"seriesQuery"`
+	// SeriesFilters filters the discovered series by name.
+	SeriesFilters []PrometheusAdapterSeriesFilter `json:"seriesFilters,omitempty"`
+	// Resources associates the labels of the series with Kubernetes
+	// resources.
+	Resources PrometheusAdapterResourceMapping `json:"resources,omitempty"`
+	// Name maps the name of the series to the name of the metric exposed by
+	// the API.
+	Name *PrometheusAdapterNameMapping `json:"name,omitempty"`
+	// MetricsQuery is the Go template of the Prometheus query returning the
+	// values of the metric.
+	MetricsQuery string `json:"metricsQuery"`
+}
+
+type PrometheusAdapterSeriesFilter struct {
+	Is    string `json:"is,omitempty"`
+	IsNot string `json:"isNot,omitempty"`
+}
+
+type PrometheusAdapterResourceMapping struct {
+	// Template is the Go template mapping a resource to a label name.
+	Template string `json:"template,omitempty"`
+	// Overrides maps label names to resources.
+	Overrides map[string]PrometheusAdapterGroupResource `json:"overrides,omitempty"`
+	// Namespaced tells whether external metrics are namespaced. Defaults to
+	// true.
+	Namespaced *bool `json:"namespaced,omitempty"`
+}
+
+type PrometheusAdapterGroupResource struct {
+	Group    string `json:"group,omitempty"`
+	Resource string `json:"resource"`
+}
+
+type PrometheusAdapterNameMapping struct {
+	Matches string `json:"matches,omitempty"`
+	As      string `json:"as,omitempty"`
 }
 
 // Audit profile configurations
@@ -288,6 +827,191 @@ type Audit struct {
 	Profile auditv1.Level `json:"profile"`
 }
 
+// HostedControlPlaneConfig configures the monitoring of a control plane which
+// runs as pods in a management namespace. It is only used when the
+// infrastructure reports an external control-plane topology.
+type HostedControlPlaneConfig struct {
+	// Namespace is the namespace where the etcd and kube-apiserver pods of
+	// the control plane are running.
+	Namespace string `json:"namespace"`
+}
+
+// UserWorkloadTenancyConfig configures the tenant label set on the alerts of
+// the user workload Prometheus and Thanos Ruler.
+type UserWorkloadTenancyConfig struct {
+	// TenantLabel is the name of the label holding the namespace of the
+	// rule on the user-defined alerts. Defaults to "namespace". Any other
+	// value is set in addition to the namespace label and overrides the
+	// value set by the rules.
+	TenantLabel string `json:"tenantLabel"`
+}
+
+// DefaultTenantLabel is the label set by prometheus-operator on the series
+// and alerts of the user workload Prometheus and Thanos Ruler.
+const DefaultTenantLabel = "namespace"
+
+// Label returns the name of the tenant label.
+func (c *UserWorkloadTenancyConfig) Label() (string, error) {
+	if c == nil || c.TenantLabel == "" {
+		return DefaultTenantLabel, nil
+	}
+
+	l := c.TenantLabel
+	if !model.LabelName(l).IsValid() || strings.HasPrefix(l, model.ReservedLabelPrefix) {
+		return "", fmt.Errorf("%w - userWorkloadTenancy tenantLabel: invalid label name %q", ErrConfigValidation, l)
+	}
+	switch l {
+	case model.AlertNameLabel, AlertSourceLabel:
+		return "", fmt.Errorf("%w - userWorkloadTenancy tenantLabel: label %q is reserved", ErrConfigValidation, l)
+	}
+
+	return l, nil
+}
+
+// ComponentOverride sets the management state of a component of the platform
+// monitoring stack. Unmanaged components aren't updated by the operator,
+// removed components are deleted.
+type ComponentOverride struct {
+	// Component is the key of the component in the configuration (e.g.
+	// "grafana" or "telemeterClient").
+	Component string `json:"component"`
+	// ManagementState is Managed (default), Unmanaged or Removed.
+	ManagementState operatorv1.ManagementState `json:"managementState"`
+}
+
+// overridableComponents are the components whose management state can be
+// overridden. The components which can be removed have a function disabling
+// them.
+var overridableComponents = map[string]func(*ClusterMonitoringConfiguration){
+	"prometheusOperator": nil,
+	"prometheusK8s":      nil,
+	"alertmanagerMain": func(cmc *ClusterMonitoringConfiguration) {
+		disabled := false
+		cmc.AlertmanagerMainConfig.Enabled = &disabled
+	},
+	"nodeExporter":     nil,
+	"kubeStateMetrics": nil,
+	"openshiftStateMetrics": func(cmc *ClusterMonitoringConfiguration) {
+		disabled := false
+		cmc.OpenShiftMetricsConfig.Enabled = &disabled
+	},
+	"grafana": func(cmc *ClusterMonitoringConfiguration) {
+		disabled := false
+		cmc.GrafanaConfig.Enabled = &disabled
+	},
+	"telemeterClient": func(cmc *ClusterMonitoringConfiguration) {
+		disabled := false
+		cmc.TelemeterClientConfig.Enabled = &disabled
+	},
+	"k8sPrometheusAdapter": nil,
+	"metricsServer":        nil,
+	"thanosQuerier":        nil,
+	"thanosReceive": func(cmc *ClusterMonitoringConfiguration) {
+		cmc.ThanosReceiveConfig.Enabled = false
+	},
+}
+
+// ApplyOverrides validates the component overrides and disables the removed
+// components so that their tasks delete them.
+func (c *Config) ApplyOverrides() error {
+	cmc := c.ClusterMonitoringConfiguration
+	seen := make(map[string]struct{}, len(cmc.Overrides))
+	for _, o := range cmc.Overrides {
+		disable, found := overridableComponents[o.Component]
+		if !found {
+			return fmt.Errorf("%w - overrides: unknown component %q, must be one of %s", ErrConfigValidation, o.Component, strings.Join(overridableComponentNames(false), ", "))
+		}
+		if _, found := seen[o.Component]; found {
+			return fmt.Errorf("%w - overrides: component %q is overridden more than once", ErrConfigValidation, o.Component)
+		}
+		seen[o.Component] = struct{}{}
+
+		switch o.ManagementState {
+		case "", operatorv1.Managed, operatorv1.Unmanaged:
+		case operatorv1.Removed:
+			if disable == nil {
+				return fmt.Errorf("%w - overrides: component %q can't be removed, only %s can", ErrConfigValidation, o.Component, strings.Join(overridableComponentNames(true), ", "))
+			}
+			disable(cmc)
+		default:
+			return fmt.Errorf("%w - overrides: invalid managementState %q for component %q, must be one of Managed, Unmanaged, Removed", ErrConfigValidation, o.ManagementState, o.Component)
+		}
+	}
+
+	return nil
+}
+
+// ComponentsInState returns the sorted components overridden with the given
+// management state.
+func (c *Config) ComponentsInState(state operatorv1.ManagementState) []string {
+	var components []string
+	for _, o := range c.ClusterMonitoringConfiguration.Overrides {
+		if o.ManagementState == state {
+			components = append(components, o.Component)
+		}
+	}
+	sort.Strings(components)
+
+	return components
+}
+
+func overridableComponentNames(removable bool) []string {
+	names := make([]string, 0, len(overridableComponents))
+	for name, disable := range overridableComponents {
+		if removable && disable == nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// QueryCanaryConfig configures the canary which periodically runs
+// representative queries against Thanos Querier and records their latency
+// and success as metrics.
+type QueryCanaryConfig struct {
+	Enabled bool `json:"enabled"`
+	// Interval is the interval between two runs of the queries. Defaults to
+	// 1m, the minimum is 30s.
+	Interval string `json:"interval"`
+}
+
+const (
+	defaultQueryCanaryInterval = time.Minute
+	minQueryCanaryInterval     = 30 * time.Second
+)
+
+// IntervalDuration returns the interval between two runs of the queries.
+func (c *QueryCanaryConfig) IntervalDuration() (time.Duration, error) {
+	if c.Interval == "" {
+		return defaultQueryCanaryInterval, nil
+	}
+
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("%w - queryCanary interval: %v", ErrConfigValidation, err)
+	}
+	if d < minQueryCanaryInterval {
+		return 0, fmt.Errorf("%w - queryCanary interval must be at least %s: %q", ErrConfigValidation, minQueryCanaryInterval, c.Interval)
+	}
+
+	return d, nil
+}
+
+// TeardownConfig configures the removal of the components which are
+// disabled or removed with the operator.
+type TeardownConfig struct {
+	// DeletePersistentVolumeClaims deletes the persistent volume claims of
+	// the removed components once their pods are gone. The claims, and
+	// possibly the data of their volumes, are retained by default.
+	DeletePersistentVolumeClaims bool `json:"deletePersistentVolumeClaims"`
+	// Uninstall tears down all the optional components so that the
+	// operator can be removed afterwards.
+	Uninstall bool `json:"uninstall"`
+}
+
 type EtcdConfig struct {
 	Enabled *bool `json:"-"`
 }
@@ -308,6 +1032,89 @@ type TelemeterClientConfig struct {
 	Token              string            `json:"token"`
 	NodeSelector       map[string]string `json:"nodeSelector"`
 	Tolerations        []v1.Toleration   `json:"tolerations"`
+	// TelemetryMatches extends or shrinks the set of series forwarded to
+	// Telemeter.
+	TelemetryMatches *TelemetryMatchesConfig `json:"telemetryMatches"`
+	// RemoteWrite sends the telemetry through the remote write of the
+	// platform Prometheus instead of deploying telemeter-client. It
+	// overrides the default mode set on the command line.
+	RemoteWrite *bool `json:"remoteWrite"`
+	// Proxy overrides the cluster-wide proxy settings for the telemetry
+	// traffic.
+	Proxy *TelemeterClientProxyConfig `json:"proxy"`
+	// AdditionalCABundle references a ConfigMap or Secret key in the
+	// openshift-monitoring namespace holding PEM-encoded CA certificates
+	// trusted for the telemetry traffic, e.g. the CA of a TLS-intercepting
+	// proxy.
+	AdditionalCABundle *monv1.SecretOrConfigMap `json:"additionalCABundle"`
+}
+
+// TelemeterClientProxyConfig overrides the proxy settings of the cluster-wide
+// proxy object. Empty fields keep the cluster-wide values.
+type TelemeterClientProxyConfig struct {
+	HTTPProxy  string `json:"httpProxy"`
+	HTTPSProxy string `json:"httpsProxy"`
+	NoProxy    string `json:"noProxy"`
+}
+
+// Validate returns an error if a proxy URL is invalid.
+func (p *TelemeterClientProxyConfig) Validate() error {
+	if p == nil {
+		return nil
+	}
+
+	for _, v := range []struct{ name, value string }{
+		{name: "httpProxy", value: p.HTTPProxy},
+		{name: "httpsProxy", value: p.HTTPSProxy},
+	} {
+		if v.value == "" {
+			continue
+		}
+
+		u, err := url.Parse(v.value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%w - telemeterClient proxy: invalid %s URL %q", ErrConfigValidation, v.name, v.value)
+		}
+	}
+
+	return nil
+}
+
+// ValidateAdditionalCABundle returns an error unless the additional CA
+// bundle references exactly one ConfigMap or Secret key.
+func (cfg *TelemeterClientConfig) ValidateAdditionalCABundle() error {
+	ref := cfg.AdditionalCABundle
+	if ref == nil {
+		return nil
+	}
+
+	switch {
+	case ref.ConfigMap != nil && ref.Secret != nil:
+		return fmt.Errorf("%w - telemeterClient additionalCABundle: configMap and secret are mutually exclusive", ErrConfigValidation)
+	case ref.ConfigMap == nil && ref.Secret == nil:
+		return fmt.Errorf("%w - telemeterClient additionalCABundle: either configMap or secret is required", ErrConfigValidation)
+	case ref.ConfigMap != nil && (ref.ConfigMap.Name == "" || ref.ConfigMap.Key == ""):
+		return fmt.Errorf("%w - telemeterClient additionalCABundle: configMap name and key are required", ErrConfigValidation)
+	case ref.Secret != nil && (ref.Secret.Name == "" || ref.Secret.Key == ""):
+		return fmt.Errorf("%w - telemeterClient additionalCABundle: secret name and key are required", ErrConfigValidation)
+	}
+
+	return nil
+}
+
+// TelemetryMatchesConfig overrides the series selectors forwarded to
+// Telemeter. Changing them affects the data available to Red Hat support,
+// hence the overrides are rejected unless AcknowledgeUnsupported is set.
+type TelemetryMatchesConfig struct {
+	// AcknowledgeUnsupported acknowledges that the cluster forwards a
+	// different set of series than the one shipped with the release.
+	AcknowledgeUnsupported bool `json:"acknowledgeUnsupported"`
+	// Additional are series selectors forwarded in addition to the
+	// default ones.
+	Additional []string `json:"additional"`
+	// Excluded are default series selectors which aren't forwarded. They
+	// must match a default selector exactly.
+	Excluded []string `json:"excluded"`
 }
 
 func (cfg *TelemeterClientConfig) IsEnabled() bool {
@@ -365,9 +1172,18 @@ func (c *Config) applyDefaults() {
 	if c.ClusterMonitoringConfiguration.ThanosQuerierConfig == nil {
 		c.ClusterMonitoringConfiguration.ThanosQuerierConfig = &ThanosQuerierConfig{}
 	}
+	if c.ClusterMonitoringConfiguration.ThanosReceiveConfig == nil {
+		c.ClusterMonitoringConfiguration.ThanosReceiveConfig = &ThanosReceiveConfig{}
+	}
+	if c.ClusterMonitoringConfiguration.MetricsServerConfig == nil {
+		c.ClusterMonitoringConfiguration.MetricsServerConfig = &MetricsServerConfig{}
+	}
 	if c.ClusterMonitoringConfiguration.GrafanaConfig == nil {
 		c.ClusterMonitoringConfiguration.GrafanaConfig = &GrafanaConfig{}
 	}
+	if c.ClusterMonitoringConfiguration.NodeExporterConfig == nil {
+		c.ClusterMonitoringConfiguration.NodeExporterConfig = &NodeExporterConfig{}
+	}
 	if c.ClusterMonitoringConfiguration.KubeStateMetricsConfig == nil {
 		c.ClusterMonitoringConfiguration.KubeStateMetricsConfig = &KubeStateMetricsConfig{}
 	}
@@ -378,9 +1194,10 @@ func (c *Config) applyDefaults() {
 		c.ClusterMonitoringConfiguration.HTTPConfig = &HTTPConfig{}
 	}
 	if c.ClusterMonitoringConfiguration.TelemeterClientConfig == nil {
-		c.ClusterMonitoringConfiguration.TelemeterClientConfig = &TelemeterClientConfig{
-			TelemeterServerURL: "https://infogw.api.openshift.com/",
-		}
+		c.ClusterMonitoringConfiguration.TelemeterClientConfig = &TelemeterClientConfig{}
+	}
+	if c.ClusterMonitoringConfiguration.TelemeterClientConfig.TelemeterServerURL == "" {
+		c.ClusterMonitoringConfiguration.TelemeterClientConfig.TelemeterServerURL = "https://infogw.api.openshift.com/"
 	}
 
 	if c.ClusterMonitoringConfiguration.K8sPrometheusAdapter == nil {
@@ -396,6 +1213,30 @@ func (c *Config) applyDefaults() {
 	if c.ClusterMonitoringConfiguration.EtcdConfig == nil {
 		c.ClusterMonitoringConfiguration.EtcdConfig = &EtcdConfig{}
 	}
+
+	if c.ClusterMonitoringConfiguration.ConsoleNotifications == nil {
+		c.ClusterMonitoringConfiguration.ConsoleNotifications = &ConsoleNotificationsConfig{}
+	}
+
+	if c.ClusterMonitoringConfiguration.ConsoleDashboards == nil {
+		c.ClusterMonitoringConfiguration.ConsoleDashboards = &ConsoleDashboardsConfig{}
+	}
+
+	if c.ClusterMonitoringConfiguration.HostedControlPlane == nil {
+		c.ClusterMonitoringConfiguration.HostedControlPlane = &HostedControlPlaneConfig{}
+	}
+
+	if c.ClusterMonitoringConfiguration.UserWorkloadTenancy == nil {
+		c.ClusterMonitoringConfiguration.UserWorkloadTenancy = &UserWorkloadTenancyConfig{}
+	}
+
+	if c.ClusterMonitoringConfiguration.QueryCanary == nil {
+		c.ClusterMonitoringConfiguration.QueryCanary = &QueryCanaryConfig{}
+	}
+
+	if c.ClusterMonitoringConfiguration.Teardown == nil {
+		c.ClusterMonitoringConfiguration.Teardown = &TeardownConfig{}
+	}
 }
 
 func (c *Config) SetImages(images map[string]string) {
@@ -413,19 +1254,113 @@ func (c *Config) SetImages(images map[string]string) {
 	c.Images.K8sPrometheusAdapter = images["k8s-prometheus-adapter"]
 	c.Images.OpenShiftStateMetrics = images["openshift-state-metrics"]
 	c.Images.Thanos = images["thanos"]
+	c.Images.MetricsServer = images["metrics-server"]
+}
+
+// SetTelemetryMatches sets the series selectors forwarded to Telemeter from
+// the default matches and the overrides of the configuration.
+func (c *Config) SetTelemetryMatches(matches []string) error {
+	overrides := c.ClusterMonitoringConfiguration.TelemeterClientConfig.TelemetryMatches
+	if overrides == nil || (len(overrides.Additional) == 0 && len(overrides.Excluded) == 0) {
+		c.ClusterMonitoringConfiguration.PrometheusK8sConfig.TelemetryMatches = matches
+		return nil
+	}
+
+	if !overrides.AcknowledgeUnsupported {
+		return fmt.Errorf("%w - telemeterClient telemetryMatches: overriding the telemetry matches requires acknowledgeUnsupported to be true", ErrConfigValidation)
+	}
+
+	excluded := make(map[string]struct{}, len(overrides.Excluded))
+	for _, m := range overrides.Excluded {
+		excluded[m] = struct{}{}
+	}
+
+	effective := make([]string, 0, len(matches)+len(overrides.Additional))
+	for _, m := range matches {
+		if _, found := excluded[m]; found {
+			delete(excluded, m)
+			continue
+		}
+		effective = append(effective, m)
+	}
+
+	for _, m := range overrides.Excluded {
+		if _, found := excluded[m]; !found {
+			continue
+		}
+		return fmt.Errorf("%w - telemeterClient telemetryMatches: excluded selector %q isn't a default telemetry match", ErrConfigValidation, m)
+	}
+
+	for _, m := range overrides.Additional {
+		if err := validateTelemetryMatch(m); err != nil {
+			return fmt.Errorf("%w - telemeterClient telemetryMatches: invalid additional selector %q: %v", ErrConfigValidation, m, err)
+		}
+		effective = append(effective, m)
+	}
+
+	c.ClusterMonitoringConfiguration.PrometheusK8sConfig.TelemetryMatches = effective
+	return nil
+}
+
+// validateTelemetryMatch checks that the selector can be applied by both
+// telemeter-client and the write relabeling of the remote write mode which
+// can't express negative matchers.
+func validateTelemetryMatch(m string) error {
+	matchers, err := promql.ParseMetricSelector(m)
+	if err != nil {
+		return err
+	}
+
+	for _, lm := range matchers {
+		if lm.Type == promlabels.MatchNotEqual || lm.Type == promlabels.MatchNotRegexp {
+			return fmt.Errorf("negative matcher on label %q isn't supported", lm.Name)
+		}
+	}
+
+	return nil
 }
 
-func (c *Config) SetTelemetryMatches(matches []string) {
-	c.ClusterMonitoringConfiguration.PrometheusK8sConfig.TelemetryMatches = matches
+// DisableOptionalComponents disables the components which can be turned off
+// (user workload monitoring, Alertmanager, Grafana, Thanos Receive,
+// openshift-state-metrics and the Telemeter client) so that their tasks
+// delete them. It is used when the operator is being removed.
+func (c *Config) DisableOptionalComponents() {
+	disabled := false
+	cmc := c.ClusterMonitoringConfiguration
+	cmc.UserWorkloadEnabled = &disabled
+	cmc.AlertmanagerMainConfig.Enabled = &disabled
+	cmc.GrafanaConfig.Enabled = &disabled
+	cmc.OpenShiftMetricsConfig.Enabled = &disabled
+	cmc.TelemeterClientConfig.Enabled = &disabled
+	cmc.ThanosReceiveConfig.Enabled = false
 }
 
+// SetRemoteWrite sets the default telemetry mode, the mode set in the
+// telemeter client configuration takes precedence.
 func (c *Config) SetRemoteWrite(rw bool) {
 	c.RemoteWrite = rw
+	if mode := c.ClusterMonitoringConfiguration.TelemeterClientConfig.RemoteWrite; mode != nil {
+		c.RemoteWrite = *mode
+	}
 	if c.RemoteWrite && c.ClusterMonitoringConfiguration.TelemeterClientConfig.TelemeterServerURL == "https://infogw.api.openshift.com/" {
 		c.ClusterMonitoringConfiguration.TelemeterClientConfig.TelemeterServerURL = "https://infogw.api.openshift.com/metrics/v1/receive"
 	}
 }
 
+// Hash returns a short hash identifying the cluster and user workload
+// configurations.
+func (c *Config) Hash() (string, error) {
+	h := fnv.New64a()
+	for _, v := range []interface{}{c.ClusterMonitoringConfiguration, c.UserWorkloadConfiguration} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		h.Write(b)
+	}
+	return strconv.FormatUint(h.Sum64(), 32), nil
+}
+
 func (c *Config) LoadClusterID(load func() (*configv1.ClusterVersion, error)) error {
 	if c.ClusterMonitoringConfiguration.TelemeterClientConfig.ClusterID != "" {
 		return nil
@@ -485,6 +1420,18 @@ func (c *Config) NoProxy() string {
 	return c.ClusterMonitoringConfiguration.HTTPConfig.NoProxy
 }
 
+// DeprecatedFields describes the deprecated fields set in the cluster
+// monitoring configuration and how to replace them, one entry per field.
+func (c *Config) DeprecatedFields() []string {
+	var fields []string
+
+	if h := c.ClusterMonitoringConfiguration.HTTPConfig; h != nil && *h != (HTTPConfig{}) {
+		fields = append(fields, "http: configure the cluster-wide proxy (proxies.config.openshift.io/cluster) instead, and telemeterClient.proxy to override it for the telemetry traffic")
+	}
+
+	return fields
+}
+
 func NewConfigFromString(content string) (*Config, error) {
 	if content == "" {
 		return NewDefaultConfig(), nil
@@ -493,6 +1440,21 @@ func NewConfigFromString(content string) (*Config, error) {
 	return NewConfig(bytes.NewBuffer([]byte(content)))
 }
 
+// NewConfigFromSpec parses the spec of a ClusterMonitoring resource which
+// mirrors the config.yaml key of the Cluster Monitoring ConfigMap.
+func NewConfigFromSpec(spec map[string]interface{}) (*Config, error) {
+	if spec == nil {
+		return NewDefaultConfig(), nil
+	}
+
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConfig(bytes.NewReader(b))
+}
+
 func NewDefaultConfig() *Config {
 	c := &Config{}
 	cmc := ClusterMonitoringConfiguration{}
@@ -506,6 +1468,134 @@ type UserWorkloadConfiguration struct {
 	PrometheusOperator *PrometheusOperatorConfig   `json:"prometheusOperator"`
 	Prometheus         *PrometheusRestrictedConfig `json:"prometheus"`
 	ThanosRuler        *ThanosRulerConfig          `json:"thanosRuler"`
+	// NamespaceQuotas caps the scrape limits of the ServiceMonitors and
+	// PodMonitors and the number of rules of the given namespaces.
+	NamespaceQuotas []NamespaceQuota `json:"namespaceQuotas"`
+	// ExcludedRuleNamespaces lists the namespaces whose PrometheusRules are
+	// ignored by the user workload Prometheus and Thanos Ruler. The
+	// namespaces are still scraped.
+	ExcludedRuleNamespaces []string `json:"excludedRuleNamespaces"`
+	// DefaultRuleEvaluationScope defines where the PrometheusRules without
+	// the openshift.io/prometheus-rule-evaluation-scope label are evaluated:
+	// thanos-ruler (default) or leaf-prometheus.
+	DefaultRuleEvaluationScope string `json:"defaultRuleEvaluationScope"`
+}
+
+const (
+	// RuleEvaluationScopeLabel selects where a user-defined PrometheusRule is
+	// evaluated.
+	RuleEvaluationScopeLabel = "openshift.io/prometheus-rule-evaluation-scope"
+
+	// RuleEvaluationScopeThanosRuler evaluates the rules in Thanos Ruler
+	// which can query both platform and user-defined metrics.
+	RuleEvaluationScopeThanosRuler = "thanos-ruler"
+	// RuleEvaluationScopeLeafPrometheus evaluates the rules in the user
+	// workload Prometheus which only has the user-defined metrics.
+	RuleEvaluationScopeLeafPrometheus = "leaf-prometheus"
+
+	// RuleQuotaExceededLabel is set by the operator on the user-defined
+	// PrometheusRules which exceed the rule budget of their namespace. These
+	// rules are ignored by the user workload Prometheus and Thanos Ruler.
+	RuleQuotaExceededLabel = "openshift.io/prometheus-rule-quota-exceeded"
+
+	// TargetQuotaExceededLabel is set by the operator on the user-defined
+	// ServiceMonitors and PodMonitors whose targets don't fit in the target
+	// quota of their namespace. These monitors are ignored by the user
+	// workload Prometheus.
+	TargetQuotaExceededLabel = "openshift.io/target-quota-exceeded"
+)
+
+// NamespaceQuota defines the sample and target budgets of a namespace. The
+// operator lowers the sampleLimit and targetLimit of every ServiceMonitor and
+// PodMonitor in the namespace to the budget when they are unset or higher.
+// The monitors whose targets don't fit in the target budget of the namespace
+// and the PrometheusRules which don't fit in the rule budget are ignored. A
+// zero value means no budget.
+type NamespaceQuota struct {
+	Namespace   string `json:"namespace"`
+	SampleLimit uint64 `json:"sampleLimit"`
+	TargetLimit uint64 `json:"targetLimit"`
+	// PrometheusRuleLimit caps the number of PrometheusRule objects and
+	// AlertingRuleLimit the number of alerting rules of the namespace.
+	PrometheusRuleLimit uint64 `json:"prometheusRuleLimit"`
+	AlertingRuleLimit   uint64 `json:"alertingRuleLimit"`
+}
+
+// HasRuleBudget returns whether the quota limits the rules of the namespace.
+func (q NamespaceQuota) HasRuleBudget() bool {
+	return q.PrometheusRuleLimit != 0 || q.AlertingRuleLimit != 0
+}
+
+// AllowsRules returns whether the given number of PrometheusRules and
+// alerting rules fit in the rule budget.
+func (q NamespaceQuota) AllowsRules(prometheusRules, alertingRules uint64) bool {
+	if q.PrometheusRuleLimit != 0 && prometheusRules > q.PrometheusRuleLimit {
+		return false
+	}
+	return q.AlertingRuleLimit == 0 || alertingRules <= q.AlertingRuleLimit
+}
+
+const (
+	requestedSampleLimitAnnotation = "monitoring.openshift.io/requested-sample-limit"
+	requestedTargetLimitAnnotation = "monitoring.openshift.io/requested-target-limit"
+	enforcedSampleLimitAnnotation  = "monitoring.openshift.io/enforced-sample-limit"
+	enforcedTargetLimitAnnotation  = "monitoring.openshift.io/enforced-target-limit"
+)
+
+// Apply lowers the sample and target limits of a monitor to the budget. The
+// limits which were explicitly set above the budget are kept in annotations
+// of the monitor, along with the lowered limits, so that the namespace is
+// still reported as over quota after the limits have been lowered and that
+// the requested limits are restored when the budget is removed or raised. It
+// returns whether the monitor has been modified and whether it exceeds the
+// budget.
+func (q NamespaceQuota) Apply(meta *metav1.ObjectMeta, sampleLimit, targetLimit *uint64) (bool, bool) {
+	sampleChanged, sampleExceeded := applyLimit(meta, requestedSampleLimitAnnotation, enforcedSampleLimitAnnotation, q.SampleLimit, sampleLimit)
+	targetChanged, targetExceeded := applyLimit(meta, requestedTargetLimitAnnotation, enforcedTargetLimitAnnotation, q.TargetLimit, targetLimit)
+
+	return sampleChanged || targetChanged, sampleExceeded || targetExceeded
+}
+
+func applyLimit(meta *metav1.ObjectMeta, requestedAnnotation, enforcedAnnotation string, budget uint64, limit *uint64) (bool, bool) {
+	requested, found := meta.Annotations[requestedAnnotation]
+	enforced, hasEnforced := meta.Annotations[enforcedAnnotation]
+
+	// A limit still equal to the one enforced by the operator is replaced by
+	// the requested limit, which is then lowered again if it exceeds the
+	// current budget. The monitors lowered before the enforced limit was
+	// recorded were lowered to the budget.
+	want := *limit
+	if v, err := strconv.ParseUint(requested, 10, 64); found && err == nil {
+		if enforced == strconv.FormatUint(want, 10) || !hasEnforced && want == budget {
+			want = v
+		}
+	}
+
+	var exceeded bool
+	switch {
+	case budget == 0 || want != 0 && want <= budget:
+		// The limit is within the budget, the previous request is obsolete.
+		delete(meta.Annotations, requestedAnnotation)
+		delete(meta.Annotations, enforcedAnnotation)
+	case want == 0:
+		delete(meta.Annotations, requestedAnnotation)
+		delete(meta.Annotations, enforcedAnnotation)
+		want = budget
+	default:
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+		meta.Annotations[requestedAnnotation] = strconv.FormatUint(want, 10)
+		meta.Annotations[enforcedAnnotation] = strconv.FormatUint(budget, 10)
+		want = budget
+		exceeded = true
+	}
+
+	changed := want != *limit ||
+		meta.Annotations[requestedAnnotation] != requested ||
+		meta.Annotations[enforcedAnnotation] != enforced
+	*limit = want
+	return changed, exceeded
 }
 
 type PrometheusRestrictedConfig struct {
@@ -519,8 +1609,21 @@ type PrometheusRestrictedConfig struct {
 	RemoteWrite         []RemoteWriteSpec                    `json:"remoteWrite"`
 	EnforcedSampleLimit *uint64                              `json:"enforcedSampleLimit"`
 	EnforcedTargetLimit *uint64                              `json:"enforcedTargetLimit"`
+	EnforcedLabelLimit  *uint64                              `json:"enforcedLabelLimit"`
 	AlertmanagerConfigs []AdditionalAlertmanagerConfig       `json:"additionalAlertmanagerConfigs"`
 	QueryLogFile        string                               `json:"queryLogFile"`
+	PriorityClassName   string                               `json:"priorityClassName"`
+	MinReadySeconds     *uint32                              `json:"minReadySeconds"`
+	ReadinessProbe      *ProbeConfig                         `json:"readinessProbe"`
+	StartupProbe        *ProbeConfig                         `json:"startupProbe"`
+	EnableFeatures      []string                             `json:"enableFeatures"`
+	// QueryTimeout, QueryMaxSamples and QueryMaxConcurrency limit the
+	// queries processed by Prometheus.
+	QueryTimeout        string  `json:"queryTimeout"`
+	QueryMaxSamples     *uint32 `json:"queryMaxSamples"`
+	QueryMaxConcurrency *uint32 `json:"queryMaxConcurrency"`
+	// WAL tunes the write-ahead log to shorten the restarts.
+	WAL *PrometheusWALConfig `json:"wal"`
 }
 
 func (u *UserWorkloadConfiguration) applyDefaults() {
@@ -535,6 +1638,22 @@ func (u *UserWorkloadConfiguration) applyDefaults() {
 	}
 }
 
+// NewUserConfigFromSpec parses the spec of a UserWorkloadMonitoring resource
+// which mirrors the config.yaml key of the User Workload Monitoring
+// ConfigMap.
+func NewUserConfigFromSpec(spec map[string]interface{}) (*UserWorkloadConfiguration, error) {
+	if spec == nil {
+		return NewDefaultUserWorkloadMonitoringConfig(), nil
+	}
+
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUserConfigFromString(string(b))
+}
+
 func NewUserConfigFromString(content string) (*UserWorkloadConfiguration, error) {
 	if content == "" {
 		return NewDefaultUserWorkloadMonitoringConfig(), nil
@@ -547,9 +1666,54 @@ func NewUserConfigFromString(content string) (*UserWorkloadConfiguration, error)
 
 	u.applyDefaults()
 
+	if err := u.validateNamespaceQuotas(); err != nil {
+		return nil, err
+	}
+
+	// The actual cluster metadata isn't known yet, only the templates are
+	// validated.
+	md := clusterMetadata{clusterID: "validation", platformType: "validation", region: "validation", baseDomain: "validation"}
+	if _, err := renderTemplates(u.Prometheus.ExternalLabels, md); err != nil {
+		return nil, fmt.Errorf("%w - prometheus externalLabels: %v", ErrConfigValidation, err)
+	}
+	if _, err := renderRemoteWriteHeaders(u.Prometheus.RemoteWrite, md); err != nil {
+		return nil, fmt.Errorf("%w - prometheus %v", ErrConfigValidation, err)
+	}
+
+	switch u.DefaultRuleEvaluationScope {
+	case "", RuleEvaluationScopeThanosRuler, RuleEvaluationScopeLeafPrometheus:
+	default:
+		return nil, fmt.Errorf("%w - defaultRuleEvaluationScope must be %q or %q: %q", ErrConfigValidation, RuleEvaluationScopeThanosRuler, RuleEvaluationScopeLeafPrometheus, u.DefaultRuleEvaluationScope)
+	}
+
 	return u, nil
 }
 
+// HasRuleQuotas returns whether any namespace quota limits the rules.
+func (u *UserWorkloadConfiguration) HasRuleQuotas() bool {
+	for _, q := range u.NamespaceQuotas {
+		if q.HasRuleBudget() {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *UserWorkloadConfiguration) validateNamespaceQuotas() error {
+	seen := make(map[string]struct{}, len(u.NamespaceQuotas))
+	for _, q := range u.NamespaceQuotas {
+		if q.Namespace == "" {
+			return fmt.Errorf("%w - namespaceQuotas: namespace is required", ErrConfigValidation)
+		}
+		if _, found := seen[q.Namespace]; found {
+			return fmt.Errorf("%w - namespaceQuotas: duplicate namespace %q", ErrConfigValidation, q.Namespace)
+		}
+		seen[q.Namespace] = struct{}{}
+	}
+
+	return nil
+}
+
 func NewDefaultUserWorkloadMonitoringConfig() *UserWorkloadConfiguration {
 	u := &UserWorkloadConfiguration{}
 	u.applyDefaults()
@@ -16,9 +16,17 @@ package manifests
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
 )
 
 func TestConfigParsing(t *testing.T) {
@@ -235,6 +243,41 @@ func TestHttpProxyConfig(t *testing.T) {
 	}
 }
 
+func TestDeprecatedFields(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected int
+	}{
+		{
+			name: "empty configuration",
+		},
+		{
+			name: "empty http configuration",
+			config: `http: {}
+`,
+		},
+		{
+			name: "http proxy",
+			config: `http:
+  httpProxy: http://test.com
+`,
+			expected: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := c.DeprecatedFields(); len(got) != tc.expected {
+				t.Fatalf("expected %d deprecated fields, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
 func TestGrafanaDefaultsToEnabled(t *testing.T) {
 	for _, tt := range []struct {
 		name          string
@@ -277,3 +320,691 @@ func TestGrafanaDefaultsToEnabled(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenShiftStateMetricsDefaultsToEnabled(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		config        string
+		expectEnabled bool
+	}{
+		{
+			name:          "empty config",
+			config:        "",
+			expectEnabled: true,
+		},
+		{
+			name:          "empty openshift-state-metrics config",
+			config:        `{"openshiftStateMetrics":{}}`,
+			expectEnabled: true,
+		},
+		{
+			name:          "openshift-state-metrics disabled",
+			config:        `{"openshiftStateMetrics":{"enabled": false}}`,
+			expectEnabled: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			enabled := c.ClusterMonitoringConfiguration.OpenShiftMetricsConfig.IsEnabled()
+
+			if enabled != tt.expectEnabled {
+				t.Fatalf("OpenShiftStateMetricsConfig.IsEnabled() returned %t, expected %t",
+					enabled, tt.expectEnabled)
+			}
+		})
+	}
+}
+
+func TestConsoleNotificationsSelector(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		config  string
+		alert   map[string]string
+		enabled bool
+		matches bool
+		invalid bool
+	}{
+		{
+			name:    "default",
+			config:  "",
+			alert:   map[string]string{"alertname": "KubeAPIDown", "severity": "critical"},
+			matches: true,
+		},
+		{
+			name:    "default skips warnings",
+			config:  "",
+			alert:   map[string]string{"alertname": "KubePodCrashLooping", "severity": "warning"},
+			matches: false,
+		},
+		{
+			name: "custom selector",
+			config: `consoleNotifications:
+  enabled: true
+  alertSelector:
+    matchExpressions:
+    - key: severity
+      operator: In
+      values: ["critical", "warning"]
+`,
+			alert:   map[string]string{"alertname": "KubePodCrashLooping", "severity": "warning"},
+			enabled: true,
+			matches: true,
+		},
+		{
+			name: "invalid selector",
+			config: `consoleNotifications:
+  enabled: true
+  alertSelector:
+    matchExpressions:
+    - key: severity
+      operator: Foo
+`,
+			enabled: true,
+			invalid: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := c.ClusterMonitoringConfiguration.ConsoleNotifications
+			if cfg.Enabled != tt.enabled {
+				t.Fatalf("expected enabled to be %t, got %t", tt.enabled, cfg.Enabled)
+			}
+
+			sel, err := cfg.Selector()
+			if tt.invalid {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sel.Matches(labels.Set(tt.alert)) != tt.matches {
+				t.Fatalf("expected selector %q to match %v: %t", sel, tt.alert, tt.matches)
+			}
+		})
+	}
+}
+
+func TestNamespaceQuotasValidation(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		config  string
+		invalid bool
+	}{
+		{
+			name: "valid quotas",
+			config: `namespaceQuotas:
+- namespace: team-a
+  sampleLimit: 1000
+- namespace: team-b
+  targetLimit: 10
+`,
+		},
+		{
+			name: "missing namespace",
+			config: `namespaceQuotas:
+- sampleLimit: 1000
+`,
+			invalid: true,
+		},
+		{
+			name: "duplicate namespace",
+			config: `namespaceQuotas:
+- namespace: team-a
+  sampleLimit: 1000
+- namespace: team-a
+  targetLimit: 10
+`,
+			invalid: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewUserConfigFromString(tt.config)
+			if tt.invalid {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNamespaceQuotaAllowsRules(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		quota           NamespaceQuota
+		prometheusRules uint64
+		alertingRules   uint64
+		allowed         bool
+	}{
+		{
+			name:            "no rule budget",
+			quota:           NamespaceQuota{SampleLimit: 1000},
+			prometheusRules: 100,
+			alertingRules:   1000,
+			allowed:         true,
+		},
+		{
+			name:            "within budget",
+			quota:           NamespaceQuota{PrometheusRuleLimit: 5, AlertingRuleLimit: 50},
+			prometheusRules: 5,
+			alertingRules:   50,
+			allowed:         true,
+		},
+		{
+			name:            "too many PrometheusRules",
+			quota:           NamespaceQuota{PrometheusRuleLimit: 5, AlertingRuleLimit: 50},
+			prometheusRules: 6,
+			alertingRules:   10,
+		},
+		{
+			name:            "too many alerting rules",
+			quota:           NamespaceQuota{AlertingRuleLimit: 50},
+			prometheusRules: 1,
+			alertingRules:   51,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if allowed := tt.quota.AllowsRules(tt.prometheusRules, tt.alertingRules); allowed != tt.allowed {
+				t.Fatalf("expected allowed to be %t, got %t", tt.allowed, allowed)
+			}
+		})
+	}
+}
+
+func TestNamespaceQuotaApply(t *testing.T) {
+	q := NamespaceQuota{Namespace: "team-a", SampleLimit: 1000, TargetLimit: 10}
+
+	for _, tt := range []struct {
+		name        string
+		annotations map[string]string
+		sampleLimit uint64
+		targetLimit uint64
+
+		expectedSampleLimit uint64
+		expectedTargetLimit uint64
+		expectedAnnotations map[string]string
+		changed             bool
+		exceeded            bool
+	}{
+		{
+			name:                "unset limits",
+			expectedSampleLimit: 1000,
+			expectedTargetLimit: 10,
+			changed:             true,
+		},
+		{
+			name:                "limits within budget",
+			sampleLimit:         500,
+			targetLimit:         5,
+			expectedSampleLimit: 500,
+			expectedTargetLimit: 5,
+		},
+		{
+			name:                "limits above budget",
+			sampleLimit:         5000,
+			targetLimit:         5,
+			expectedSampleLimit: 1000,
+			expectedTargetLimit: 5,
+			expectedAnnotations: map[string]string{requestedSampleLimitAnnotation: "5000", enforcedSampleLimitAnnotation: "1000"},
+			changed:             true,
+			exceeded:            true,
+		},
+		{
+			name:                "limits already lowered",
+			annotations:         map[string]string{requestedSampleLimitAnnotation: "5000", enforcedSampleLimitAnnotation: "1000"},
+			sampleLimit:         1000,
+			targetLimit:         10,
+			expectedSampleLimit: 1000,
+			expectedTargetLimit: 10,
+			expectedAnnotations: map[string]string{requestedSampleLimitAnnotation: "5000", enforcedSampleLimitAnnotation: "1000"},
+			exceeded:            true,
+		},
+		{
+			name:                "limits lowered before the enforced limit was recorded",
+			annotations:         map[string]string{requestedSampleLimitAnnotation: "5000"},
+			sampleLimit:         1000,
+			targetLimit:         10,
+			expectedSampleLimit: 1000,
+			expectedTargetLimit: 10,
+			expectedAnnotations: map[string]string{requestedSampleLimitAnnotation: "5000", enforcedSampleLimitAnnotation: "1000"},
+			changed:             true,
+			exceeded:            true,
+		},
+		{
+			name:                "budget raised",
+			annotations:         map[string]string{requestedSampleLimitAnnotation: "5000", enforcedSampleLimitAnnotation: "500"},
+			sampleLimit:         500,
+			targetLimit:         10,
+			expectedSampleLimit: 1000,
+			expectedTargetLimit: 10,
+			expectedAnnotations: map[string]string{requestedSampleLimitAnnotation: "5000", enforcedSampleLimitAnnotation: "1000"},
+			changed:             true,
+			exceeded:            true,
+		},
+		{
+			name:                "budget raised above the requested limit",
+			annotations:         map[string]string{requestedSampleLimitAnnotation: "800", enforcedSampleLimitAnnotation: "500"},
+			sampleLimit:         500,
+			targetLimit:         10,
+			expectedSampleLimit: 800,
+			expectedTargetLimit: 10,
+			expectedAnnotations: map[string]string{},
+			changed:             true,
+		},
+		{
+			name:                "limits lowered by the user",
+			annotations:         map[string]string{requestedSampleLimitAnnotation: "5000", enforcedSampleLimitAnnotation: "1000"},
+			sampleLimit:         100,
+			targetLimit:         10,
+			expectedSampleLimit: 100,
+			expectedTargetLimit: 10,
+			expectedAnnotations: map[string]string{},
+			changed:             true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := metav1.ObjectMeta{Annotations: tt.annotations}
+			sampleLimit, targetLimit := tt.sampleLimit, tt.targetLimit
+
+			changed, exceeded := q.Apply(&meta, &sampleLimit, &targetLimit)
+			if changed != tt.changed {
+				t.Fatalf("expected changed to be %t, got %t", tt.changed, changed)
+			}
+			if exceeded != tt.exceeded {
+				t.Fatalf("expected exceeded to be %t, got %t", tt.exceeded, exceeded)
+			}
+			if sampleLimit != tt.expectedSampleLimit || targetLimit != tt.expectedTargetLimit {
+				t.Fatalf("expected limits %d/%d, got %d/%d", tt.expectedSampleLimit, tt.expectedTargetLimit, sampleLimit, targetLimit)
+			}
+			if !reflect.DeepEqual(meta.Annotations, tt.expectedAnnotations) {
+				t.Fatalf("expected annotations %v, got %v", tt.expectedAnnotations, meta.Annotations)
+			}
+		})
+	}
+}
+
+func TestNamespaceQuotaApplyWithoutBudget(t *testing.T) {
+	meta := metav1.ObjectMeta{Annotations: map[string]string{
+		requestedSampleLimitAnnotation: "5000",
+		enforcedSampleLimitAnnotation:  "1000",
+		requestedTargetLimitAnnotation: "50",
+		enforcedTargetLimitAnnotation:  "10",
+	}}
+	sampleLimit, targetLimit := uint64(1000), uint64(10)
+
+	// The sample budget is removed while the target budget is raised.
+	changed, exceeded := NamespaceQuota{Namespace: "team-a", TargetLimit: 20}.Apply(&meta, &sampleLimit, &targetLimit)
+	if !changed || !exceeded {
+		t.Fatalf("expected the monitor to be changed and over quota, got changed=%t exceeded=%t", changed, exceeded)
+	}
+	if sampleLimit != 5000 || targetLimit != 20 {
+		t.Fatalf("expected limits 5000/20, got %d/%d", sampleLimit, targetLimit)
+	}
+	expected := map[string]string{
+		requestedTargetLimitAnnotation: "50",
+		enforcedTargetLimitAnnotation:  "20",
+	}
+	if !reflect.DeepEqual(meta.Annotations, expected) {
+		t.Fatalf("expected annotations %v, got %v", expected, meta.Annotations)
+	}
+}
+
+func TestTelemetryMatchesOverrides(t *testing.T) {
+	defaults := []string{
+		`{__name__="up"}`,
+		`{__name__="cluster_version"}`,
+	}
+
+	for _, tt := range []struct {
+		name     string
+		config   string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "no overrides",
+			expected: defaults,
+		},
+		{
+			name: "overrides without acknowledgement",
+			config: `telemeterClient:
+  telemetryMatches:
+    additional:
+    - '{__name__="foo"}'
+`,
+			err: true,
+		},
+		{
+			name: "additional and excluded selectors",
+			config: `telemeterClient:
+  telemetryMatches:
+    acknowledgeUnsupported: true
+    additional:
+    - '{__name__=~"foo|bar"}'
+    excluded:
+    - '{__name__="up"}'
+`,
+			expected: []string{`{__name__="cluster_version"}`, `{__name__=~"foo|bar"}`},
+		},
+		{
+			name: "unknown excluded selector",
+			config: `telemeterClient:
+  telemetryMatches:
+    acknowledgeUnsupported: true
+    excluded:
+    - '{__name__="down"}'
+`,
+			err: true,
+		},
+		{
+			name: "invalid additional selector",
+			config: `telemeterClient:
+  telemetryMatches:
+    acknowledgeUnsupported: true
+    additional:
+    - '{__name__="foo"'
+`,
+			err: true,
+		},
+		{
+			name: "negative matcher",
+			config: `telemeterClient:
+  telemetryMatches:
+    acknowledgeUnsupported: true
+    additional:
+    - '{__name__="foo",job!="bar"}'
+`,
+			err: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.SetTelemetryMatches(defaults)
+			if tt.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected a config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := c.ClusterMonitoringConfiguration.PrometheusK8sConfig.TelemetryMatches; !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected matches %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewConfigFromSpec(t *testing.T) {
+	b, err := ioutil.ReadFile("../../examples/config/clustermonitoring.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr struct {
+		Spec map[string]interface{} `json:"spec"`
+	}
+	if err := yaml.Unmarshal(b, &cr); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewConfigFromSpec(cr.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.ClusterMonitoringConfiguration.AlertmanagerMainConfig.VolumeClaimTemplate == nil {
+		t.Fatal("config parsing failed: AlertmanagerMainConfig VolumeClaimTemplate was not parsed correctly")
+	}
+	if c.ClusterMonitoringConfiguration.PrometheusK8sConfig.Retention != "24h" {
+		t.Fatalf("config parsing failed: expected retention %q, got %q", "24h", c.ClusterMonitoringConfiguration.PrometheusK8sConfig.Retention)
+	}
+}
+
+func TestNewConfigFromNilSpec(t *testing.T) {
+	c, err := NewConfigFromSpec(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, NewDefaultConfig()) {
+		t.Fatal("expected the default configuration for a nil spec")
+	}
+
+	uwc, err := NewUserConfigFromSpec(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(uwc, NewDefaultUserWorkloadMonitoringConfig()) {
+		t.Fatal("expected the default user workload configuration for a nil spec")
+	}
+}
+
+func TestNewUserConfigFromSpec(t *testing.T) {
+	b, err := ioutil.ReadFile("../../examples/user-workload/userworkloadmonitoring.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cr struct {
+		Spec map[string]interface{} `json:"spec"`
+	}
+	if err := yaml.Unmarshal(b, &cr); err != nil {
+		t.Fatal(err)
+	}
+
+	uwc, err := NewUserConfigFromSpec(cr.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uwc.Prometheus == nil || uwc.Prometheus.Retention != "24h" {
+		t.Fatal("config parsing failed: Prometheus was not parsed correctly")
+	}
+	if uwc.ThanosRuler == nil {
+		t.Fatal("config parsing failed: Thanos was not parsed correctly")
+	}
+}
+
+func TestDisableOptionalComponents(t *testing.T) {
+	c, err := NewConfigFromString(`enableUserWorkload: true
+teardown:
+  deletePersistentVolumeClaims: true
+thanosReceive:
+  enabled: true
+telemeterClient:
+  clusterID: "123"
+  token: secret`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.ClusterMonitoringConfiguration.Teardown.DeletePersistentVolumeClaims {
+		t.Fatal("expected the persistent volume claims deletion to be enabled")
+	}
+
+	c.DisableOptionalComponents()
+
+	cmc := c.ClusterMonitoringConfiguration
+	for name, enabled := range map[string]bool{
+		"user workload monitoring": *cmc.UserWorkloadEnabled,
+		"alertmanager":             cmc.AlertmanagerMainConfig.IsEnabled(),
+		"grafana":                  cmc.GrafanaConfig.IsEnabled(),
+		"openshift-state-metrics":  cmc.OpenShiftMetricsConfig.IsEnabled(),
+		"telemeter client":         cmc.TelemeterClientConfig.IsEnabled(),
+		"thanos receive":           cmc.ThanosReceiveConfig.IsEnabled(),
+	} {
+		if enabled {
+			t.Errorf("expected %s to be disabled", name)
+		}
+	}
+}
+
+func TestQueryCanaryInterval(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected time.Duration
+		err      bool
+	}{
+		{
+			name:     "default",
+			expected: time.Minute,
+		},
+		{
+			name: "custom",
+			config: `queryCanary:
+  enabled: true
+  interval: 5m`,
+			expected: 5 * time.Minute,
+		},
+		{
+			name: "too short",
+			config: `queryCanary:
+  interval: 10s`,
+			err: true,
+		},
+		{
+			name: "invalid",
+			config: `queryCanary:
+  interval: often`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := c.ClusterMonitoringConfiguration.QueryCanary.IntervalDuration()
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected a validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, d)
+			}
+		})
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		config    string
+		unmanaged []string
+		removed   []string
+		err       bool
+	}{
+		{
+			name: "no override",
+		},
+		{
+			name: "unmanaged and removed components",
+			config: `overrides:
+- component: thanosQuerier
+  managementState: Unmanaged
+- component: grafana
+  managementState: Removed
+- component: alertmanagerMain
+  managementState: Removed
+- component: prometheusK8s
+  managementState: Managed`,
+			unmanaged: []string{"thanosQuerier"},
+			removed:   []string{"alertmanagerMain", "grafana"},
+		},
+		{
+			name: "unknown component",
+			config: `overrides:
+- component: prometheus
+  managementState: Unmanaged`,
+			err: true,
+		},
+		{
+			name: "component not removable",
+			config: `overrides:
+- component: prometheusK8s
+  managementState: Removed`,
+			err: true,
+		},
+		{
+			name: "invalid state",
+			config: `overrides:
+- component: grafana
+  managementState: Force`,
+			err: true,
+		},
+		{
+			name: "duplicate component",
+			config: `overrides:
+- component: grafana
+  managementState: Unmanaged
+- component: grafana
+  managementState: Removed`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.ApplyOverrides()
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected a validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := c.ComponentsInState(operatorv1.Unmanaged); !reflect.DeepEqual(got, tc.unmanaged) {
+				t.Fatalf("expected unmanaged components %v, got %v", tc.unmanaged, got)
+			}
+			if got := c.ComponentsInState(operatorv1.Removed); !reflect.DeepEqual(got, tc.removed) {
+				t.Fatalf("expected removed components %v, got %v", tc.removed, got)
+			}
+
+			cmc := c.ClusterMonitoringConfiguration
+			for name, enabled := range map[string]bool{
+				"alertmanagerMain": cmc.AlertmanagerMainConfig.IsEnabled(),
+				"grafana":          cmc.GrafanaConfig.IsEnabled(),
+			} {
+				removed := false
+				for _, r := range tc.removed {
+					removed = removed || r == name
+				}
+				if enabled == removed {
+					t.Errorf("expected %s to be enabled: %v, got %v", name, !removed, enabled)
+				}
+			}
+		})
+	}
+}
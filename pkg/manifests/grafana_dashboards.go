@@ -0,0 +1,234 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// ConsoleDashboardsNamespace is the namespace holding the dashboard
+	// ConfigMaps. The console reads them directly, the operator aggregates
+	// them for Grafana.
+	ConsoleDashboardsNamespace = configManagedNamespace
+	// ConsoleDashboardsSelector selects the dashboard ConfigMaps.
+	ConsoleDashboardsSelector = "console.openshift.io/dashboard=true"
+	// AdditionalDashboardsSelector selects the copies of the dashboards
+	// found in the additional dashboards namespace.
+	AdditionalDashboardsSelector = additionalDashboardSourceLabel
+
+	additionalDashboardSourceLabel = "monitoring.openshift.io/dashboard-source-namespace"
+	// additionalDashboardPrefix is reserved to the copies of the additional
+	// dashboards so that they can't collide with the shipped dashboards.
+	additionalDashboardPrefix = "monitoring-additional-"
+
+	grafanaCustomDashboards     = "grafana-dashboards-custom"
+	grafanaCustomDashboardsPath = "/grafana-dashboard-definitions/1"
+)
+
+// GrafanaCustomDashboards aggregates the dashboards found in the given
+// console ConfigMaps into a single ConfigMap mounted by Grafana. The
+// dashboards shipped by the operator are skipped since Grafana already
+// provisions them. Each JSON key is stored as "<configmap>-<key>" to avoid
+// collisions between ConfigMaps.
+func (f *Factory) GrafanaCustomDashboards(sources []v1.ConfigMap) (*v1.ConfigMap, error) {
+	builtin, err := f.NewConfigMapList(f.assets.MustNewAssetReader(GrafanaDashboardDefinitions))
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]struct{}, len(builtin.Items))
+	for _, c := range builtin.Items {
+		skip[c.GetName()] = struct{}{}
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      grafanaCustomDashboards,
+			Namespace: f.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/component": "grafana",
+				"app.kubernetes.io/name":      "grafana",
+				"app.kubernetes.io/part-of":   "openshift-monitoring",
+			},
+		},
+		Data: map[string]string{},
+	}
+
+	for _, src := range sources {
+		if _, found := skip[src.GetName()]; found {
+			continue
+		}
+
+		for k, v := range src.Data {
+			if !strings.HasSuffix(k, ".json") {
+				continue
+			}
+			cm.Data[src.GetName()+"-"+k] = v
+		}
+	}
+
+	return cm, nil
+}
+
+// addGrafanaCustomDashboardsProvider registers the file provider loading
+// the custom dashboards into a dedicated folder.
+func addGrafanaCustomDashboardsProvider(cm *v1.ConfigMap) error {
+	var sources map[string]interface{}
+	if err := json.Unmarshal([]byte(cm.Data["dashboards.yaml"]), &sources); err != nil {
+		return fmt.Errorf("failed to parse the Grafana dashboard sources: %w", err)
+	}
+
+	providers, _ := sources["providers"].([]interface{})
+	sources["providers"] = append(providers, map[string]interface{}{
+		"folder":    "Custom",
+		"folderUid": "",
+		"name":      "1",
+		"options": map[string]interface{}{
+			"path": grafanaCustomDashboardsPath,
+		},
+		"orgId": 1,
+		"type":  "file",
+		// Dashboards owned by other teams can't be edited from Grafana.
+		"allowUiUpdates": false,
+	})
+
+	b, err := json.MarshalIndent(sources, "", "    ")
+	if err != nil {
+		return err
+	}
+	cm.Data["dashboards.yaml"] = string(b)
+
+	return nil
+}
+
+// grafanaCustomDashboardsVolume returns the volume and the mount of the
+// custom dashboards. The volume is optional so that Grafana starts before
+// the ConfigMap is created.
+func grafanaCustomDashboardsVolume() (v1.Volume, v1.VolumeMount) {
+	optional := true
+	return v1.Volume{
+		Name: grafanaCustomDashboards,
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: grafanaCustomDashboards,
+				},
+				Optional: &optional,
+			},
+		},
+	}, v1.VolumeMount{
+		Name:      grafanaCustomDashboards,
+		MountPath: grafanaCustomDashboardsPath,
+		ReadOnly:  true,
+	}
+}
+
+// ConsoleDashboards returns the shipped console dashboards to deploy and the
+// ones excluded by the configuration, which must be removed.
+func (f *Factory) ConsoleDashboards() (*v1.ConfigMapList, *v1.ConfigMapList, error) {
+	cl, err := f.NewConfigMapList(f.assets.MustNewAssetReader(ConsoleDashboardDefinitions))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	excluded := make(map[string]struct{}, len(f.config.ClusterMonitoringConfiguration.ConsoleDashboards.Excluded))
+	for _, name := range f.config.ClusterMonitoringConfiguration.ConsoleDashboards.Excluded {
+		excluded[name] = struct{}{}
+	}
+
+	kept, removed := &v1.ConfigMapList{}, &v1.ConfigMapList{}
+	for _, c := range cl.Items {
+		c.Namespace = ConsoleDashboardsNamespace
+		f.setOrigin(&c, "cluster-monitoring-operator")
+		if _, found := excluded[c.GetName()]; found {
+			removed.Items = append(removed.Items, c)
+			delete(excluded, c.GetName())
+			continue
+		}
+		kept.Items = append(kept.Items, c)
+	}
+
+	if len(excluded) > 0 {
+		unknown := make([]string, 0, len(excluded))
+		for name := range excluded {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		return nil, nil, fmt.Errorf("%w - consoleDashboards excluded: unknown dashboards %s", ErrConfigValidation, strings.Join(unknown, ", "))
+	}
+
+	return kept, removed, nil
+}
+
+// AdditionalDashboardsNamespace returns the namespace whose dashboards are
+// copied to the console, or an empty string when it isn't configured.
+func (f *Factory) AdditionalDashboardsNamespace() (string, error) {
+	ns := f.config.ClusterMonitoringConfiguration.ConsoleDashboards.AdditionalDashboardsNamespace
+	if ns == "" {
+		return "", nil
+	}
+
+	if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+		return "", fmt.Errorf("%w - consoleDashboards additionalDashboardsNamespace: invalid name %q: %s", ErrConfigValidation, ns, strings.Join(errs, ", "))
+	}
+	if ns == ConsoleDashboardsNamespace {
+		return "", fmt.Errorf("%w - consoleDashboards additionalDashboardsNamespace: the dashboards of %s are already read by the console", ErrConfigValidation, ns)
+	}
+
+	return ns, nil
+}
+
+// ConsoleAdditionalDashboards copies the given dashboard ConfigMaps into the
+// console namespace. The copies are named
+// "monitoring-additional-<namespace>-<configmap>" and labeled with the namespace of their source so that the ones whose source
+// is gone can be found and removed.
+func (f *Factory) ConsoleAdditionalDashboards(sources []v1.ConfigMap) *v1.ConfigMapList {
+	cl := &v1.ConfigMapList{}
+	for _, src := range sources {
+		labels := map[string]string{
+			"app.kubernetes.io/part-of":    "openshift-monitoring",
+			additionalDashboardSourceLabel: src.GetNamespace(),
+		}
+		for k, v := range src.GetLabels() {
+			if strings.HasPrefix(k, "console.openshift.io/") {
+				labels[k] = v
+			}
+		}
+
+		data := make(map[string]string, len(src.Data))
+		for k, v := range src.Data {
+			data[k] = v
+		}
+
+		cl.Items = append(cl.Items, v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      additionalDashboardPrefix + src.GetNamespace() + "-" + src.GetName(),
+				Namespace: ConsoleDashboardsNamespace,
+				Labels:    labels,
+			},
+			Data: data,
+		})
+	}
+
+	return cl
+}
@@ -21,10 +21,17 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"math"
 	"net"
 	"net/url"
+	"path"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/openshift/library-go/pkg/crypto"
 
@@ -33,6 +40,7 @@ import (
 	"github.com/openshift/cluster-monitoring-operator/pkg/promqlgen"
 	"github.com/pkg/errors"
 	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
 	"golang.org/x/crypto/bcrypt"
 	yaml2 "gopkg.in/yaml.v2"
 	admissionv1 "k8s.io/api/admissionregistration/v1"
@@ -40,8 +48,10 @@ import (
 	v1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
 	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
@@ -51,6 +61,42 @@ const (
 	configManagedNamespace = "openshift-config-managed"
 	sharedConfigMap        = "monitoring-shared-config"
 
+	alertmanagerConfigKey = "alertmanager.yaml"
+
+	prometheusK8sResourceRecommendationConfigMap = "prometheus-k8s-resource-recommendation"
+
+	zoneTopologyKey = "topology.kubernetes.io/zone"
+
+	grafanaAdditionalDatasourcesKey            = "additional.yaml"
+	grafanaAdditionalDatasourcesHashAnnotation = "monitoring.openshift.io/additional-datasources-hash"
+
+	kubeStateMetricsCustomResourceStateVolume         = "custom-resource-state-config"
+	kubeStateMetricsCustomResourceStateDir            = "/etc/kube-state-metrics/custom-resource-state"
+	kubeStateMetricsCustomResourceStateFile           = "config.yaml"
+	kubeStateMetricsCustomResourceStateHashAnnotation = "monitoring.openshift.io/custom-resource-state-config-hash"
+	kubeStateMetricsShardLabel                        = "monitoring.openshift.io/kube-state-metrics-shard"
+
+	prometheusAdapterConfigKey            = "config.yaml"
+	prometheusAdapterRulesHashAnnotation  = "monitoring.openshift.io/adapter-rules-hash"
+	prometheusAdapterCustomMetricsGroup   = "custom.metrics.k8s.io"
+	prometheusAdapterExternalMetricsGroup = "external.metrics.k8s.io"
+
+	nodeExporterTextfileDir             = "/var/node_exporter/textfile"
+	nodeExporterTextfileVolume          = "node-exporter-textfile"
+	nodeExporterTextfileConfigMapVolume = "node-exporter-textfile-configmap"
+	nodeExporterTextfileHashAnnotation  = "monitoring.openshift.io/textfile-hash"
+
+	telemeterAdditionalCABundleVolume         = "telemeter-additional-ca-bundle"
+	telemeterAdditionalCABundleDir            = "/etc/telemeter-additional-ca-bundle"
+	telemeterAdditionalCABundleFile           = "ca-bundle.crt"
+	telemeterAdditionalCABundleHashAnnotation = "monitoring.openshift.io/additional-ca-bundle-hash"
+
+	// HostedControlPlaneNamespaceAnnotation records on the hosted
+	// control-plane metrics client Secret the namespace of the control plane
+	// so that the objects of a previous namespace can be cleaned up.
+	HostedControlPlaneNamespaceAnnotation = "monitoring.openshift.io/hosted-control-plane-namespace"
+	hostedControlPlaneMetricsClientSecret = "hosted-control-plane-metrics-client-certs"
+
 	htpasswdArg = "-htpasswd-file=/etc/proxy/htpasswd/auth"
 	clientCAArg = "--client-ca-file=/etc/tls/client/client-ca.crt"
 )
@@ -114,6 +160,7 @@ var (
 	PrometheusK8sProxySecret                          = "prometheus-k8s/proxy-secret.yaml"
 	PrometheusRBACProxySecret                         = "prometheus-k8s/kube-rbac-proxy-secret.yaml"
 	PrometheusUserWorkloadRBACProxySecret             = "prometheus-user-workload/kube-rbac-proxy-secret.yaml"
+	PrometheusUserWorkloadRBACProxyFederateSecret     = "prometheus-user-workload/kube-rbac-proxy-federate-secret.yaml"
 	PrometheusK8sRoute                                = "prometheus-k8s/route.yaml"
 	PrometheusK8sHtpasswd                             = "prometheus-k8s/htpasswd-secret.yaml"
 	PrometheusK8sServingCertsCABundle                 = "prometheus-k8s/serving-certs-ca-bundle.yaml"
@@ -159,6 +206,17 @@ var (
 	PrometheusAdapterServiceMonitor                     = "prometheus-adapter/service-monitor.yaml"
 	PrometheusAdapterServiceAccount                     = "prometheus-adapter/service-account.yaml"
 
+	MetricsServerAPIService                      = "metrics-server/api-service.yaml"
+	MetricsServerClusterRole                     = "metrics-server/cluster-role.yaml"
+	MetricsServerClusterRoleBinding              = "metrics-server/cluster-role-binding.yaml"
+	MetricsServerClusterRoleBindingAuthDelegator = "metrics-server/cluster-role-binding-auth-delegator.yaml"
+	MetricsServerDeployment                      = "metrics-server/deployment.yaml"
+	MetricsServerPodDisruptionBudget             = "metrics-server/pod-disruption-budget.yaml"
+	MetricsServerRoleBindingAuthReader           = "metrics-server/role-binding-auth-reader.yaml"
+	MetricsServerService                         = "metrics-server/service.yaml"
+	MetricsServerServiceAccount                  = "metrics-server/service-account.yaml"
+	MetricsServerServiceMonitor                  = "metrics-server/service-monitor.yaml"
+
 	PrometheusOperatorClusterRoleBinding    = "prometheus-operator/cluster-role-binding.yaml"
 	PrometheusOperatorClusterRole           = "prometheus-operator/cluster-role.yaml"
 	PrometheusOperatorServiceAccount        = "prometheus-operator/service-account.yaml"
@@ -184,6 +242,7 @@ var (
 	GrafanaDatasourcesSecret     = "grafana/dashboard-datasources.yaml"
 	GrafanaDashboardDefinitions  = "grafana/dashboard-definitions.yaml"
 	GrafanaDashboardSources      = "grafana/dashboard-sources.yaml"
+	ConsoleDashboardDefinitions  = "grafana/console-dashboard-definitions.yaml"
 	GrafanaDeployment            = "grafana/deployment.yaml"
 	GrafanaRBACProxyMetricSecret = "grafana/kube-rbac-proxy-metric-secret.yaml"
 	GrafanaProxySecret           = "grafana/proxy-secret.yaml"
@@ -234,6 +293,19 @@ var (
 	ThanosQuerierGrpcTLSSecret          = "thanos-querier/grpc-tls-secret.yaml"
 	ThanosQuerierTrustedCABundle        = "thanos-querier/trusted-ca-bundle.yaml"
 
+	ThanosReceiveStatefulSet             = "thanos-receive/stateful-set.yaml"
+	ThanosReceiveService                 = "thanos-receive/service.yaml"
+	ThanosReceiveServiceMonitor          = "thanos-receive/service-monitor.yaml"
+	ThanosReceiveRoute                   = "thanos-receive/route.yaml"
+	ThanosReceiveServiceAccount          = "thanos-receive/service-account.yaml"
+	ThanosReceiveClusterRole             = "thanos-receive/cluster-role.yaml"
+	ThanosReceiveClusterRoleBinding      = "thanos-receive/cluster-role-binding.yaml"
+	ThanosReceiveRemoteWriterClusterRole = "thanos-receive/remote-writer-cluster-role.yaml"
+	ThanosReceiveRBACProxySecret         = "thanos-receive/kube-rbac-proxy-secret.yaml"
+	ThanosReceiveRBACProxyMetricsSecret  = "thanos-receive/kube-rbac-proxy-metrics-secret.yaml"
+	ThanosReceiveGrpcTLSSecret           = "thanos-receive/grpc-tls-secret.yaml"
+	ThanosReceiveHashringsConfigMap      = "thanos-receive/hashrings-config.yaml"
+
 	ThanosRulerCustomResource               = "thanos-ruler/thanos-ruler.yaml"
 	ThanosRulerService                      = "thanos-ruler/service.yaml"
 	ThanosRulerRoute                        = "thanos-ruler/route.yaml"
@@ -257,6 +329,9 @@ var (
 	ControlPlanePrometheusRule        = "control-plane/prometheus-rule.yaml"
 	ControlPlaneKubeletServiceMonitor = "control-plane/service-monitor-kubelet.yaml"
 	ControlPlaneEtcdServiceMonitor    = "control-plane/service-monitor-etcd.yaml"
+
+	ControlPlaneEtcdHostedServiceMonitor          = "control-plane/service-monitor-etcd-hosted.yaml"
+	ControlPlaneKubeAPIServerHostedServiceMonitor = "control-plane/service-monitor-kube-apiserver-hosted.yaml"
 )
 
 var (
@@ -276,14 +351,42 @@ var (
 
 	TrustedCABundleKey = "ca-bundle.crt"
 
+	// DarkLaunchAnnotation marks a shipped alerting rule as dark launched.
+	DarkLaunchAnnotation = "openshift.io/dark-launch"
+	// DarkLaunchLabel is added to the dark launched alerts which are then
+	// dropped by the alert relabeling of prometheus-k8s.
+	DarkLaunchLabel = "openshift_io_alert_dark_launch"
+	// AlertSourceLabel is set to "platform" on the platform alerts by the
+	// alert relabeling of prometheus-k8s.
+	AlertSourceLabel = "openshift_io_alert_source"
+
+	// AlertmanagerRenderedConfigSecret holds the user-provided Alertmanager
+	// configuration merged with the route defaults. The
+	// alertmanager-main-generated name is already used by the Prometheus
+	// operator.
+	AlertmanagerRenderedConfigSecret = "alertmanager-main-rendered"
+	// AlertmanagerReceiversTLSSecret holds the copies of the certificates and
+	// keys referenced by the TLS settings of the receivers.
+	AlertmanagerReceiversTLSSecret = "alertmanager-main-receivers-tls"
+
 	AlertmanagerLegacyServiceMonitorName                = "alertmanager"
 	AdditionalAlertmanagerConfigSecretKey               = "alertmanager-configs.yaml"
 	PrometheusK8sAdditionalAlertmanagerConfigSecretName = "prometheus-k8s-additional-alertmanager-configs"
 	PrometheusUWAdditionalAlertmanagerConfigSecretName  = "prometheus-user-workload-additional-alertmanager-configs"
+
+	// The alert relabel configs of the user workload Prometheus and Thanos
+	// Ruler set the tenant label of the user-defined alerts.
+	AlertRelabelConfigSecretKey              = "alert-relabel-configs.yaml"
+	PrometheusUWAlertRelabelConfigSecretName = "prometheus-user-workload-alert-relabel-configs"
+	ThanosRulerAlertRelabelConfigSecretName  = "thanos-ruler-alert-relabel-configs"
 )
 
 var (
 	ErrConfigValidation = fmt.Errorf("invalid value for config")
+
+	// alertmanagerRetentionRegexp matches the durations accepted by the
+	// Alertmanager CRD for the data retention.
+	alertmanagerRetentionRegexp = regexp.MustCompile(`^[0-9]+(ms|s|m|h)$`)
 )
 
 type Factory struct {
@@ -294,12 +397,21 @@ type Factory struct {
 	proxy                 ProxyReader
 	assets                *Assets
 	APIServerConfig       *APIServerConfig
+
+	// excludedRules counts the rules removed by each configured exclusion
+	// from each PrometheusRule object (keyed by namespace/name). The
+	// PrometheusRule objects are rendered by concurrent tasks.
+	excludedRulesMtx sync.Mutex
+	excludedRules    map[string]map[ExcludedRule]int
 }
 
 // InfrastructureReader has methods to describe the cluster infrastructure.
 type InfrastructureReader interface {
 	HighlyAvailableInfrastructure() bool
 	HostedControlPlane() bool
+	PlatformType() string
+	Region() string
+	BaseDomain() string
 }
 
 // ProxyReader has methods to describe the proxy configuration.
@@ -337,6 +449,223 @@ func (f *Factory) AlertmanagerExternalURL(host string) *url.URL {
 	}
 }
 
+// parseExternalURL validates a user-provided external URL. Only absolute
+// URLs are accepted since they are used to build links in alert
+// notifications and in the console.
+func parseExternalURL(component, s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%w - %s external URL must be an absolute URL: %q", ErrConfigValidation, component, s)
+	}
+	return u, nil
+}
+
+// setPrometheusProbes applies the minReadySeconds and probe settings to the
+// Prometheus resource. The probes are patched into the prometheus container
+// generated by prometheus-operator.
+func setPrometheusProbes(p *monv1.Prometheus, component string, minReadySeconds *uint32, readiness, startup *ProbeConfig) error {
+	if minReadySeconds != nil {
+		p.Spec.MinReadySeconds = minReadySeconds
+	}
+
+	if readiness == nil && startup == nil {
+		return nil
+	}
+
+	readinessProbe, err := probeFromConfig(component+" readinessProbe", readiness)
+	if err != nil {
+		return err
+	}
+	startupProbe, err := probeFromConfig(component+" startupProbe", startup)
+	if err != nil {
+		return err
+	}
+
+	for i := range p.Spec.Containers {
+		if p.Spec.Containers[i].Name == "prometheus" {
+			p.Spec.Containers[i].ReadinessProbe = readinessProbe
+			p.Spec.Containers[i].StartupProbe = startupProbe
+			return nil
+		}
+	}
+
+	p.Spec.Containers = append(p.Spec.Containers, v1.Container{
+		Name:           "prometheus",
+		ReadinessProbe: readinessProbe,
+		StartupProbe:   startupProbe,
+	})
+	return nil
+}
+
+func probeFromConfig(field string, pc *ProbeConfig) (*v1.Probe, error) {
+	if pc == nil {
+		return nil, nil
+	}
+
+	if pc.TimeoutSeconds < 0 || pc.PeriodSeconds < 0 || pc.FailureThreshold < 0 {
+		return nil, fmt.Errorf("%w - %s: values must not be negative", ErrConfigValidation, field)
+	}
+
+	return &v1.Probe{
+		TimeoutSeconds:   pc.TimeoutSeconds,
+		PeriodSeconds:    pc.PeriodSeconds,
+		FailureThreshold: pc.FailureThreshold,
+	}, nil
+}
+
+// allowedPrometheusFeatures lists the Prometheus feature flags which can be
+// enabled from the configuration. Features affecting the security or the
+// tenancy model (e.g. remote-write-receiver) aren't allowed.
+var allowedPrometheusFeatures = map[string]struct{}{
+	"exemplar-storage":            {},
+	"extra-scrape-metrics":        {},
+	"memory-snapshot-on-shutdown": {},
+	"promql-at-modifier":          {},
+	"promql-negative-offset":      {},
+}
+
+// setPrometheusFeatures enables the Prometheus feature flags after checking
+// them against the allow-list.
+func setPrometheusFeatures(p *monv1.Prometheus, component string, features []string) error {
+	enabled := make(map[string]struct{}, len(p.Spec.EnableFeatures))
+	for _, feature := range p.Spec.EnableFeatures {
+		enabled[feature] = struct{}{}
+	}
+
+	for _, feature := range features {
+		if _, found := allowedPrometheusFeatures[feature]; !found {
+			return fmt.Errorf("%w - %s enableFeatures: feature %q isn't supported", ErrConfigValidation, component, feature)
+		}
+		if _, found := enabled[feature]; found {
+			continue
+		}
+		enabled[feature] = struct{}{}
+		p.Spec.EnableFeatures = append(p.Spec.EnableFeatures, feature)
+	}
+
+	return nil
+}
+
+// setPrometheusWAL applies the write-ahead log settings to the Prometheus
+// resource.
+func setPrometheusWAL(p *monv1.Prometheus, component string, wal *PrometheusWALConfig) error {
+	if wal == nil {
+		return nil
+	}
+
+	if wal.Compression != nil {
+		p.Spec.WALCompression = wal.Compression
+	}
+
+	if wal.MemorySnapshotOnShutdown {
+		return setPrometheusFeatures(p, component, []string{"memory-snapshot-on-shutdown"})
+	}
+
+	return nil
+}
+
+const (
+	defaultPrometheusExternalLabelName = "prometheus"
+	defaultReplicaExternalLabelName    = "prometheus_replica"
+)
+
+// externalLabelNames returns the names of the external labels identifying
+// the Prometheus instances and their replicas. Renamed labels are rejected
+// when the change would break the deduplication of the series and of the
+// alerts or the telemetry, which expects the default names.
+func (f *Factory) externalLabelNames() (string, string, error) {
+	prometheusLabel, replicaLabel := defaultPrometheusExternalLabelName, defaultReplicaExternalLabelName
+
+	cmc := f.config.ClusterMonitoringConfiguration
+	names := cmc.PrometheusK8sConfig.ExternalLabelNames
+	if names == nil {
+		return prometheusLabel, replicaLabel, nil
+	}
+	if names.Prometheus != "" {
+		prometheusLabel = names.Prometheus
+	}
+	if names.Replica != "" {
+		replicaLabel = names.Replica
+	}
+	if prometheusLabel == defaultPrometheusExternalLabelName && replicaLabel == defaultReplicaExternalLabelName {
+		return prometheusLabel, replicaLabel, nil
+	}
+
+	for _, l := range []string{prometheusLabel, replicaLabel} {
+		if !model.LabelName(l).IsValid() || strings.HasPrefix(l, model.ReservedLabelPrefix) {
+			return "", "", fmt.Errorf("%w - prometheusK8s externalLabelNames: invalid label name %q", ErrConfigValidation, l)
+		}
+		// The replica labels of Thanos Ruler and Thanos Receive are also
+		// removed by Thanos Querier.
+		if l == "thanos_ruler_replica" || l == "thanos_receive_replica" {
+			return "", "", fmt.Errorf("%w - prometheusK8s externalLabelNames: the %q label is reserved by Thanos", ErrConfigValidation, l)
+		}
+	}
+
+	if prometheusLabel == replicaLabel {
+		return "", "", fmt.Errorf("%w - prometheusK8s externalLabelNames: the prometheus and replica labels must differ", ErrConfigValidation)
+	}
+
+	externalLabels := map[string]map[string]string{"prometheusK8s": cmc.PrometheusK8sConfig.ExternalLabels}
+	if f.config.UserWorkloadConfiguration != nil && f.config.UserWorkloadConfiguration.Prometheus != nil {
+		externalLabels["prometheus"] = f.config.UserWorkloadConfiguration.Prometheus.ExternalLabels
+	}
+	for field, labels := range externalLabels {
+		for _, l := range []string{prometheusLabel, replicaLabel} {
+			if _, found := labels[l]; found {
+				return "", "", fmt.Errorf("%w - %s externalLabels: the %q label is already set by externalLabelNames", ErrConfigValidation, field, l)
+			}
+		}
+	}
+
+	if cmc.TelemeterClientConfig == nil || cmc.TelemeterClientConfig.Enabled == nil || *cmc.TelemeterClientConfig.Enabled {
+		return "", "", fmt.Errorf("%w - prometheusK8s externalLabelNames: the labels can't be renamed while telemetry is enabled", ErrConfigValidation)
+	}
+
+	return prometheusLabel, replicaLabel, nil
+}
+
+// setPrometheusExternalLabelNames applies the names of the external labels
+// to the Prometheus resource. The Prometheus operator also removes the
+// replica label from the alerts so that Alertmanager deduplicates them.
+func (f *Factory) setPrometheusExternalLabelNames(p *monv1.Prometheus) error {
+	prometheusLabel, replicaLabel, err := f.externalLabelNames()
+	if err != nil {
+		return err
+	}
+
+	if prometheusLabel != defaultPrometheusExternalLabelName {
+		p.Spec.PrometheusExternalLabelName = &prometheusLabel
+	}
+	if replicaLabel != defaultReplicaExternalLabelName {
+		p.Spec.ReplicaExternalLabelName = &replicaLabel
+	}
+
+	return nil
+}
+
+// appendVolumeSources appends the user-defined Secret or ConfigMap names to
+// the given list, skipping duplicates.
+func appendVolumeSources(names []string, additional []string, field string) ([]string, error) {
+	seen := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		seen[n] = struct{}{}
+	}
+
+	for _, n := range additional {
+		if errs := validation.IsDNS1123Subdomain(n); len(errs) > 0 {
+			return nil, fmt.Errorf("%w - %s: invalid name %q: %s", ErrConfigValidation, field, n, strings.Join(errs, ", "))
+		}
+		if _, found := seen[n]; found {
+			continue
+		}
+		seen[n] = struct{}{}
+		names = append(names, n)
+	}
+
+	return names, nil
+}
+
 func (f *Factory) AlertmanagerConfig() (*v1.Secret, error) {
 	s, err := f.NewSecret(f.assets.MustNewAssetReader(AlertmanagerConfig))
 	if err != nil {
@@ -348,6 +677,457 @@ func (f *Factory) AlertmanagerConfig() (*v1.Secret, error) {
 	return s, nil
 }
 
+// AlertmanagerRenderedConfig returns the Alertmanager configuration Secret
+// used by Alertmanager when route defaults, the throttling of the user alerts
+// or receiver TLS settings are configured. It is a copy of the given
+// user-provided Secret whose root route is completed with the defaults and
+// the throttling route and whose receivers use the TLS settings. It returns
+// nil when none is configured.
+func (f *Factory) AlertmanagerRenderedConfig(userConfig *v1.Secret) (*v1.Secret, error) {
+	cfg := f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig
+	if !cfg.HasRenderedConfig() {
+		return nil, nil
+	}
+
+	b := userConfig.Data[alertmanagerConfigKey]
+	var err error
+	if cfg.RouteDefaults != nil {
+		b, err = applyAlertmanagerRouteDefaults(b, cfg.RouteDefaults)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.UserAlertsThrottling != nil {
+		b, err = applyAlertmanagerUserAlertsThrottling(b, cfg.UserAlertsThrottling)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.ReceiversTLS) > 0 {
+		b, err = applyAlertmanagerReceiversTLS(b, cfg.ReceiversTLS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AlertmanagerRenderedConfigSecret,
+			Namespace: f.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "cluster-monitoring-operator",
+				"app.kubernetes.io/part-of":    "openshift-monitoring",
+			},
+		},
+		Type: userConfig.Type,
+		Data: make(map[string][]byte, len(userConfig.Data)),
+	}
+	// The other keys (e.g. notification templates) are kept as is.
+	for k, v := range userConfig.Data {
+		s.Data[k] = v
+	}
+	s.Data[alertmanagerConfigKey] = b
+
+	return s, nil
+}
+
+// applyAlertmanagerRouteDefaults sets the fields of the root route of the
+// Alertmanager configuration which aren't defined yet.
+func applyAlertmanagerRouteDefaults(b []byte, d *AlertmanagerRouteDefaults) ([]byte, error) {
+	var defaults yaml2.MapSlice
+	if len(d.GroupBy) > 0 {
+		defaults = append(defaults, yaml2.MapItem{Key: "group_by", Value: d.GroupBy})
+	}
+	for _, t := range []struct {
+		field, key, value string
+	}{
+		{field: "groupWait", key: "group_wait", value: d.GroupWait},
+		{field: "groupInterval", key: "group_interval", value: d.GroupInterval},
+		{field: "repeatInterval", key: "repeat_interval", value: d.RepeatInterval},
+	} {
+		if t.value == "" {
+			continue
+		}
+		if v, err := model.ParseDuration(t.value); err != nil || v == 0 {
+			return nil, fmt.Errorf("%w - alertmanagerMain routeDefaults %s must be a positive duration: %q", ErrConfigValidation, t.field, t.value)
+		}
+		defaults = append(defaults, yaml2.MapItem{Key: t.key, Value: t.value})
+	}
+
+	cfg, idx, route, err := parseAlertmanagerRootRoute(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range defaults {
+		var found bool
+		for _, r := range route {
+			if r.Key == item.Key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			route = append(route, item)
+		}
+	}
+	cfg[idx].Value = route
+
+	return yaml2.Marshal(cfg)
+}
+
+// applyAlertmanagerUserAlertsThrottling prepends a route catching the alerts
+// which don't come from the platform to the child routes of the root route.
+// The route groups the alerts by namespace with the throttled timings and
+// its child routes are copies of the user-defined ones so that the alerts
+// still reach the same receivers.
+func applyAlertmanagerUserAlertsThrottling(b []byte, t *AlertmanagerUserAlertsThrottling) ([]byte, error) {
+	throttled := yaml2.MapSlice{
+		{Key: "matchers", Value: []string{fmt.Sprintf("%s!=%q", AlertSourceLabel, "platform")}},
+		{Key: "group_by", Value: []string{"namespace"}},
+	}
+	for _, d := range []struct {
+		field, key, value string
+	}{
+		{field: "groupWait", key: "group_wait", value: t.GroupWait},
+		{field: "groupInterval", key: "group_interval", value: t.GroupInterval},
+		{field: "repeatInterval", key: "repeat_interval", value: t.RepeatInterval},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if v, err := model.ParseDuration(d.value); err != nil || v == 0 {
+			return nil, fmt.Errorf("%w - alertmanagerMain userAlertsThrottling %s must be a positive duration: %q", ErrConfigValidation, d.field, d.value)
+		}
+		throttled = append(throttled, yaml2.MapItem{Key: d.key, Value: d.value})
+	}
+
+	cfg, idx, route, err := parseAlertmanagerRootRoute(b)
+	if err != nil {
+		return nil, err
+	}
+
+	routesIdx := -1
+	for i := range route {
+		if route[i].Key == "routes" {
+			routesIdx = i
+			break
+		}
+	}
+
+	routes := []interface{}{}
+	if routesIdx >= 0 {
+		if route[routesIdx].Value != nil {
+			var ok bool
+			routes, ok = route[routesIdx].Value.([]interface{})
+			if !ok {
+				return nil, errors.New("the Alertmanager configuration routes aren't a list")
+			}
+		}
+	} else {
+		route = append(route, yaml2.MapItem{Key: "routes"})
+		routesIdx = len(route) - 1
+	}
+
+	if len(routes) > 0 {
+		throttled = append(throttled, yaml2.MapItem{Key: "routes", Value: throttleAlertmanagerRoutes(routes)})
+	}
+	route[routesIdx].Value = append([]interface{}{throttled}, routes...)
+	cfg[idx].Value = route
+
+	return yaml2.Marshal(cfg)
+}
+
+// throttleAlertmanagerRoutes returns a copy of the routes which inherit the
+// grouping and the timings of the throttling route.
+func throttleAlertmanagerRoutes(routes []interface{}) []interface{} {
+	throttled := make([]interface{}, 0, len(routes))
+	for _, r := range routes {
+		route, ok := r.(yaml2.MapSlice)
+		if !ok {
+			throttled = append(throttled, r)
+			continue
+		}
+
+		var copied yaml2.MapSlice
+		for _, item := range route {
+			switch item.Key {
+			case "group_by", "group_wait", "group_interval", "repeat_interval":
+				continue
+			case "routes":
+				if children, ok := item.Value.([]interface{}); ok {
+					item.Value = throttleAlertmanagerRoutes(children)
+				}
+			}
+			copied = append(copied, item)
+		}
+		throttled = append(throttled, copied)
+	}
+
+	return throttled
+}
+
+// AlertmanagerReceiversTLSSecret returns the Secret holding the certificates
+// and keys referenced by the TLS settings of the receivers, read from their
+// Secrets with the given function. It returns nil when no receiver TLS
+// settings are configured.
+func (f *Factory) AlertmanagerReceiversTLSSecret(getSecret func(namespace, name string) (*v1.Secret, error)) (*v1.Secret, error) {
+	receivers := f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.ReceiversTLS
+	if len(receivers) == 0 {
+		return nil, nil
+	}
+
+	if err := validateAlertmanagerReceiversTLS(receivers); err != nil {
+		return nil, err
+	}
+
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AlertmanagerReceiversTLSSecret,
+			Namespace: f.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "cluster-monitoring-operator",
+				"app.kubernetes.io/part-of":    "openshift-monitoring",
+			},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{},
+	}
+
+	for i, r := range receivers {
+		namespace := r.Namespace
+		if namespace == "" {
+			namespace = f.namespace
+		}
+
+		for _, ref := range alertmanagerReceiverTLSFiles(i, r.TLSConfig) {
+			src, err := getSecret(namespace, ref.selector.Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading Secret %s/%s of receiver %q failed", namespace, ref.selector.Name, r.Receiver)
+			}
+
+			v, found := src.Data[ref.selector.Key]
+			if !found {
+				return nil, fmt.Errorf("key %q not found in Secret %s/%s of receiver %q", ref.selector.Key, namespace, ref.selector.Name, r.Receiver)
+			}
+			s.Data[ref.key] = v
+		}
+	}
+
+	return s, nil
+}
+
+// tlsConfigFile maps a key of a user Secret to its key in the Secret holding
+// the copies made by the operator.
+type tlsConfigFile struct {
+	field    string
+	selector *v1.SecretKeySelector
+	key      string
+}
+
+// tlsConfigFiles returns the files referenced by the TLS settings, their
+// keys in the copied Secret are prefixed with the given prefix.
+func tlsConfigFiles(prefix string, t TLSConfig) []tlsConfigFile {
+	var files []tlsConfigFile
+	for _, f := range []tlsConfigFile{
+		{field: "ca", selector: t.CA, key: prefix + "-ca.crt"},
+		{field: "cert", selector: t.Cert, key: prefix + "-tls.crt"},
+		{field: "key", selector: t.Key, key: prefix + "-tls.key"},
+	} {
+		if f.selector != nil {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// alertmanagerReceiverTLSFiles returns the files referenced by the TLS
+// settings of the i-th receiver. The keys are derived from the position of
+// the receiver because the receiver names aren't valid Secret keys.
+func alertmanagerReceiverTLSFiles(i int, t TLSConfig) []tlsConfigFile {
+	return tlsConfigFiles(fmt.Sprintf("receiver-%d", i), t)
+}
+
+func validateAlertmanagerReceiversTLS(receivers []AlertmanagerReceiverTLSConfig) error {
+	seen := make(map[string]struct{}, len(receivers))
+	for i, r := range receivers {
+		if r.Receiver == "" {
+			return fmt.Errorf("%w - alertmanagerMain receiversTLS[%d] receiver must be set", ErrConfigValidation, i)
+		}
+		if _, found := seen[r.Receiver]; found {
+			return fmt.Errorf("%w - alertmanagerMain receiversTLS: duplicate receiver %q", ErrConfigValidation, r.Receiver)
+		}
+		seen[r.Receiver] = struct{}{}
+
+		if r.Namespace != "" {
+			if errs := validation.IsDNS1123Label(r.Namespace); len(errs) > 0 {
+				return fmt.Errorf("%w - alertmanagerMain receiversTLS receiver %q: invalid namespace %q: %s", ErrConfigValidation, r.Receiver, r.Namespace, strings.Join(errs, ", "))
+			}
+		}
+
+		if (r.TLSConfig.Cert == nil) != (r.TLSConfig.Key == nil) {
+			return fmt.Errorf("%w - alertmanagerMain receiversTLS receiver %q: cert and key must be set together", ErrConfigValidation, r.Receiver)
+		}
+
+		for _, f := range alertmanagerReceiverTLSFiles(i, r.TLSConfig) {
+			if f.selector.Name == "" || f.selector.Key == "" {
+				return fmt.Errorf("%w - alertmanagerMain receiversTLS receiver %q: %s must reference a Secret name and key", ErrConfigValidation, r.Receiver, f.field)
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyAlertmanagerReceiversTLS sets the TLS settings of all the
+// notification integrations of the configured receivers. The settings
+// defined in the Alertmanager configuration are replaced. The receivers which
+// aren't defined in the Alertmanager configuration are ignored.
+func applyAlertmanagerReceiversTLS(b []byte, receivers []AlertmanagerReceiverTLSConfig) ([]byte, error) {
+	if err := validateAlertmanagerReceiversTLS(receivers); err != nil {
+		return nil, err
+	}
+
+	tlsConfigs := make(map[string]yaml2.MapSlice, len(receivers))
+	for i, r := range receivers {
+		tlsConfigs[r.Receiver] = alertmanagerReceiverTLSConfig(i, r.TLSConfig)
+	}
+
+	var cfg yaml2.MapSlice
+	if err := yaml2.Unmarshal(b, &cfg); err != nil {
+		return nil, errors.Wrap(err, "parsing Alertmanager configuration failed")
+	}
+
+	for i := range cfg {
+		if cfg[i].Key != "receivers" {
+			continue
+		}
+
+		list, ok := cfg[i].Value.([]interface{})
+		if !ok {
+			return nil, errors.New("the Alertmanager configuration receivers aren't a list")
+		}
+
+		for j := range list {
+			receiver, ok := list[j].(yaml2.MapSlice)
+			if !ok {
+				continue
+			}
+
+			tlsConfig, found := tlsConfigs[mapSliceString(receiver, "name")]
+			if !found {
+				continue
+			}
+
+			for k := range receiver {
+				key, ok := receiver[k].Key.(string)
+				if !ok || !strings.HasSuffix(key, "_configs") {
+					continue
+				}
+
+				integrations, ok := receiver[k].Value.([]interface{})
+				if !ok {
+					continue
+				}
+
+				for l := range integrations {
+					integration, ok := integrations[l].(yaml2.MapSlice)
+					if !ok {
+						continue
+					}
+
+					// The email integration doesn't use an HTTP client.
+					if key == "email_configs" {
+						integrations[l] = setMapSliceItem(integration, "tls_config", tlsConfig)
+						continue
+					}
+
+					httpConfig, _ := mapSliceValue(integration, "http_config").(yaml2.MapSlice)
+					integrations[l] = setMapSliceItem(integration, "http_config", setMapSliceItem(httpConfig, "tls_config", tlsConfig))
+				}
+			}
+		}
+	}
+
+	return yaml2.Marshal(cfg)
+}
+
+// alertmanagerReceiverTLSConfig returns the Alertmanager TLS configuration
+// of the i-th receiver, referencing the files of the mounted
+// AlertmanagerReceiversTLSSecret Secret.
+func alertmanagerReceiverTLSConfig(i int, t TLSConfig) yaml2.MapSlice {
+	var tlsConfig yaml2.MapSlice
+	for _, f := range alertmanagerReceiverTLSFiles(i, t) {
+		tlsConfig = append(tlsConfig, yaml2.MapItem{
+			Key:   f.field + "_file",
+			Value: fmt.Sprintf("/etc/alertmanager/secrets/%s/%s", AlertmanagerReceiversTLSSecret, f.key),
+		})
+	}
+	if t.ServerName != "" {
+		tlsConfig = append(tlsConfig, yaml2.MapItem{Key: "server_name", Value: t.ServerName})
+	}
+	tlsConfig = append(tlsConfig, yaml2.MapItem{Key: "insecure_skip_verify", Value: t.InsecureSkipVerify})
+
+	return tlsConfig
+}
+
+func mapSliceValue(m yaml2.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func mapSliceString(m yaml2.MapSlice, key string) string {
+	s, _ := mapSliceValue(m, key).(string)
+	return s
+}
+
+// setMapSliceItem sets the value of the key, keeping its position when it
+// already exists.
+func setMapSliceItem(m yaml2.MapSlice, key string, value interface{}) yaml2.MapSlice {
+	for i := range m {
+		if m[i].Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml2.MapItem{Key: key, Value: value})
+}
+
+// parseAlertmanagerRootRoute parses the Alertmanager configuration and
+// returns it with the index and the value of its root route. A MapSlice keeps
+// the order of the keys and the fields unknown to the operator.
+func parseAlertmanagerRootRoute(b []byte) (yaml2.MapSlice, int, yaml2.MapSlice, error) {
+	var cfg yaml2.MapSlice
+	if err := yaml2.Unmarshal(b, &cfg); err != nil {
+		return nil, 0, nil, errors.Wrap(err, "parsing Alertmanager configuration failed")
+	}
+
+	idx := -1
+	for i := range cfg {
+		if cfg[i].Key == "route" {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		cfg = append(cfg, yaml2.MapItem{Key: "route", Value: yaml2.MapSlice{}})
+		idx = len(cfg) - 1
+	}
+
+	route, ok := cfg[idx].Value.(yaml2.MapSlice)
+	if !ok && cfg[idx].Value != nil {
+		return nil, 0, nil, errors.New("the Alertmanager configuration route isn't a map")
+	}
+
+	return cfg, idx, route, nil
+}
+
 func (f *Factory) AlertmanagerProxySecret() (*v1.Secret, error) {
 	s, err := f.NewSecret(f.assets.MustNewAssetReader(AlertmanagerProxySecret))
 	if err != nil {
@@ -431,6 +1211,17 @@ func setContainerEnvironmentVariable(container *v1.Container, name, value string
 	}
 }
 
+// containerIndex returns the index of the named container or -1 if it
+// doesn't exist.
+func containerIndex(containers []v1.Container, name string) int {
+	for i := range containers {
+		if containers[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func (f *Factory) injectProxyVariables(container *v1.Container) {
 	if f.proxy.HTTPProxy() != "" {
 		setContainerEnvironmentVariable(container, "HTTP_PROXY", f.proxy.HTTPProxy())
@@ -451,12 +1242,49 @@ func (f *Factory) AlertmanagerMain(host string, trustedCABundleCM *v1.ConfigMap)
 
 	a.Spec.Image = &f.config.Images.Alertmanager
 
-	a.Spec.ExternalURL = f.AlertmanagerExternalURL(host).String()
+	if f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.ExternalURL != "" {
+		u, err := parseExternalURL("alertmanagerMain", f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.ExternalURL)
+		if err != nil {
+			return nil, err
+		}
+		a.Spec.ExternalURL = u.String()
+	} else if host != "" {
+		a.Spec.ExternalURL = f.AlertmanagerExternalURL(host).String()
+	}
 
 	if f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.LogLevel != "" {
 		a.Spec.LogLevel = f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.LogLevel
 	}
 
+	if f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.HasRenderedConfig() {
+		a.Spec.ConfigSecret = AlertmanagerRenderedConfigSecret
+	}
+
+	if retention := f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.Retention; retention != "" {
+		if !alertmanagerRetentionRegexp.MatchString(retention) {
+			return nil, fmt.Errorf("%w - alertmanagerMain retention must match %s: %q", ErrConfigValidation, alertmanagerRetentionRegexp, retention)
+		}
+		a.Spec.Retention = retention
+	}
+
+	// The Secrets and ConfigMaps are mounted by the Prometheus operator under
+	// /etc/alertmanager/secrets/<name> and /etc/alertmanager/configmaps/<name>
+	// so that receivers can reference credentials and CA certificates.
+	secrets, err := appendVolumeSources(a.Spec.Secrets, f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.Secrets, "alertmanagerMain secrets")
+	if err != nil {
+		return nil, err
+	}
+	a.Spec.Secrets = secrets
+	if len(f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.ReceiversTLS) > 0 {
+		a.Spec.Secrets, _ = appendVolumeSources(a.Spec.Secrets, []string{AlertmanagerReceiversTLSSecret}, "")
+	}
+
+	configMaps, err := appendVolumeSources(a.Spec.ConfigMaps, f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.ConfigMaps, "alertmanagerMain configMaps")
+	if err != nil {
+		return nil, err
+	}
+	a.Spec.ConfigMaps = configMaps
+
 	if f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.Resources != nil {
 		a.Spec.Resources = *f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.Resources
 	}
@@ -465,6 +1293,23 @@ func (f *Factory) AlertmanagerMain(host string, trustedCABundleCM *v1.ConfigMap)
 		a.Spec.Storage = &monv1.StorageSpec{
 			VolumeClaimTemplate: *f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.VolumeClaimTemplate,
 		}
+
+		// Spread the replicas across zones so that a zone outage doesn't
+		// strand all the volumes. The constraint is best-effort to keep
+		// single-zone clusters schedulable.
+		a.Spec.TopologySpreadConstraints = append(a.Spec.TopologySpreadConstraints, v1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       zoneTopologyKey,
+			WhenUnsatisfiable: v1.ScheduleAnyway,
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app.kubernetes.io/component": "alert-router",
+					"app.kubernetes.io/instance":  "main",
+					"app.kubernetes.io/name":      "alertmanager",
+					"app.kubernetes.io/part-of":   "openshift-monitoring",
+				},
+			},
+		})
 	} else if f.infrastructure.HighlyAvailableInfrastructure() {
 		// When no persistent storage is configured, add a startup probe to
 		// ensure that the Alertmanager container has time to replicate data
@@ -532,6 +1377,39 @@ func (f *Factory) AlertmanagerMain(host string, trustedCABundleCM *v1.ConfigMap)
 		}
 	}
 
+	// Receivers such as webhooks or PagerDuty may only be reachable through
+	// the cluster-wide proxy. The Prometheus operator merges this container
+	// with the alertmanager container it generates. The trusted CA bundle is
+	// already mounted into the alertmanager container via spec.volumeMounts.
+	if f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.UsesClusterProxy() {
+		container := v1.Container{Name: "alertmanager"}
+		i := containerIndex(a.Spec.Containers, container.Name)
+		if i >= 0 {
+			container = a.Spec.Containers[i]
+		}
+
+		// injectProxyVariables only sets variables which are already declared.
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+			container.Env = append(container.Env, v1.EnvVar{Name: name})
+		}
+		f.injectProxyVariables(&container)
+
+		env := container.Env[:0]
+		for _, e := range container.Env {
+			if e.Value != "" || e.ValueFrom != nil {
+				env = append(env, e)
+			}
+		}
+		container.Env = env
+
+		switch {
+		case i >= 0:
+			a.Spec.Containers[i] = container
+		case len(container.Env) > 0:
+			a.Spec.Containers = append(a.Spec.Containers, container)
+		}
+	}
+
 	a.Namespace = f.namespace
 
 	return a, nil
@@ -571,7 +1449,7 @@ func (f *Factory) AlertmanagerRoute() (*routev1.Route, error) {
 }
 
 func (f *Factory) AlertmanagerPrometheusRule() (*monv1.PrometheusRule, error) {
-	return f.NewPrometheusRule(f.assets.MustNewAssetReader(AlertmanagerPrometheusRule))
+	return f.newShippedPrometheusRule(AlertmanagerPrometheusRule)
 }
 
 func (f *Factory) KubeStateMetricsClusterRoleBinding() (*rbacv1.ClusterRoleBinding, error) {
@@ -603,14 +1481,25 @@ func (f *Factory) KubeStateMetricsServiceMonitor() (*monv1.ServiceMonitor, error
 	sm.Spec.Endpoints[1].TLSConfig.ServerName = fmt.Sprintf("kube-state-metrics.%s.svc", f.namespace)
 	sm.Namespace = f.namespace
 
+	if err := f.applyCollectionProfile(sm, kubeStateMetricsComponent); err != nil {
+		return nil, err
+	}
+
 	return sm, nil
 }
 
-func (f *Factory) KubeStateMetricsDeployment() (*appsv1.Deployment, error) {
+// KubeStateMetricsDeployment returns the kube-state-metrics Deployment. The
+// customResourceState ConfigMap is the one referenced by the configuration,
+// if any; its content is hashed into the pod template so that
+// kube-state-metrics restarts when the custom resource state configuration
+// changes.
+func (f *Factory) KubeStateMetricsDeployment(customResourceState *v1.ConfigMap) (*appsv1.Deployment, error) {
 	d, err := f.NewDeployment(f.assets.MustNewAssetReader(KubeStateMetricsDeployment))
 	if err != nil {
 		return nil, err
 	}
+
+	crs := f.config.ClusterMonitoringConfiguration.KubeStateMetricsConfig.CustomResourceStateConfig
 	for i, container := range d.Spec.Template.Spec.Containers {
 		switch container.Name {
 		case "kube-rbac-proxy-self", "kube-rbac-proxy-main":
@@ -618,6 +1507,48 @@ func (f *Factory) KubeStateMetricsDeployment() (*appsv1.Deployment, error) {
 			d.Spec.Template.Spec.Containers[i].Args = f.setTLSSecurityConfiguration(container.Args, KubeRbacProxyTLSCipherSuitesFlag, KubeRbacProxyMinTLSVersionFlag)
 		case "kube-state-metrics":
 			d.Spec.Template.Spec.Containers[i].Image = f.config.Images.KubeStateMetrics
+
+			if crs == nil {
+				continue
+			}
+
+			d.Spec.Template.Spec.Containers[i].Args = append(
+				d.Spec.Template.Spec.Containers[i].Args,
+				fmt.Sprintf("--custom-resource-state-config-file=%s/%s", kubeStateMetricsCustomResourceStateDir, kubeStateMetricsCustomResourceStateFile),
+			)
+			d.Spec.Template.Spec.Containers[i].VolumeMounts = append(
+				d.Spec.Template.Spec.Containers[i].VolumeMounts,
+				v1.VolumeMount{
+					Name:      kubeStateMetricsCustomResourceStateVolume,
+					MountPath: kubeStateMetricsCustomResourceStateDir,
+					ReadOnly:  true,
+				},
+			)
+		}
+	}
+
+	if crs != nil {
+		d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, v1.Volume{
+			Name: kubeStateMetricsCustomResourceStateVolume,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: crs.LocalObjectReference,
+					Items: []v1.KeyToPath{{
+						Key:  crs.Key,
+						Path: kubeStateMetricsCustomResourceStateFile,
+					}},
+					Optional: crs.Optional,
+				},
+			},
+		})
+
+		if customResourceState != nil {
+			h := fnv.New64()
+			h.Write([]byte(customResourceState.Data[crs.Key]))
+			if d.Spec.Template.Annotations == nil {
+				d.Spec.Template.Annotations = map[string]string{}
+			}
+			d.Spec.Template.Annotations[kubeStateMetricsCustomResourceStateHashAnnotation] = strconv.FormatUint(h.Sum64(), 32)
 		}
 	}
 
@@ -633,6 +1564,53 @@ func (f *Factory) KubeStateMetricsDeployment() (*appsv1.Deployment, error) {
 	return d, nil
 }
 
+// KubeStateMetricsDeployments returns the kube-state-metrics Deployments.
+// Without sharding, it is the single kube-state-metrics Deployment. Otherwise
+// there is one Deployment per shard with the shard number in its name and its
+// pod labels. The Service selects the pods of all shards, so the
+// ServiceMonitor scrapes all of them.
+func (f *Factory) KubeStateMetricsDeployments(customResourceState *v1.ConfigMap) ([]*appsv1.Deployment, error) {
+	shards, err := f.config.ClusterMonitoringConfiguration.KubeStateMetricsConfig.ShardCount()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := f.KubeStateMetricsDeployment(customResourceState)
+	if err != nil {
+		return nil, err
+	}
+
+	if shards == 1 {
+		return []*appsv1.Deployment{d}, nil
+	}
+
+	deployments := make([]*appsv1.Deployment, 0, shards)
+	for i := int32(0); i < shards; i++ {
+		shard := d.DeepCopy()
+		shard.Name = fmt.Sprintf("%s-shard-%d", d.Name, i)
+
+		label := strconv.Itoa(int(i))
+		shard.Spec.Selector.MatchLabels[kubeStateMetricsShardLabel] = label
+		shard.Spec.Template.Labels[kubeStateMetricsShardLabel] = label
+
+		for j, c := range shard.Spec.Template.Spec.Containers {
+			if c.Name != "kube-state-metrics" {
+				continue
+			}
+
+			shard.Spec.Template.Spec.Containers[j].Args = append(
+				shard.Spec.Template.Spec.Containers[j].Args,
+				fmt.Sprintf("--shard=%d", i),
+				fmt.Sprintf("--total-shards=%d", shards),
+			)
+		}
+
+		deployments = append(deployments, shard)
+	}
+
+	return deployments, nil
+}
+
 func (f *Factory) KubeStateMetricsServiceAccount() (*v1.ServiceAccount, error) {
 	s, err := f.NewServiceAccount(f.assets.MustNewAssetReader(KubeStateMetricsServiceAccount))
 	if err != nil {
@@ -667,7 +1645,7 @@ func (f *Factory) KubeStateMetricsRBACProxySecret() (*v1.Secret, error) {
 }
 
 func (f *Factory) KubeStateMetricsPrometheusRule() (*monv1.PrometheusRule, error) {
-	return f.NewPrometheusRule(f.assets.MustNewAssetReader(KubeStateMetricsPrometheusRule))
+	return f.newShippedPrometheusRule(KubeStateMetricsPrometheusRule)
 }
 
 func (f *Factory) OpenShiftStateMetricsClusterRoleBinding() (*rbacv1.ClusterRoleBinding, error) {
@@ -711,6 +1689,22 @@ func (f *Factory) OpenShiftStateMetricsDeployment() (*appsv1.Deployment, error)
 			d.Spec.Template.Spec.Containers[i].Args = f.setTLSSecurityConfiguration(container.Args, KubeRbacProxyTLSCipherSuitesFlag, KubeRbacProxyMinTLSVersionFlag)
 		case "openshift-state-metrics":
 			d.Spec.Template.Spec.Containers[i].Image = f.config.Images.OpenShiftStateMetrics
+
+			cfg := f.config.ClusterMonitoringConfiguration.OpenShiftMetricsConfig
+			collectors, err := cfg.Collectors()
+			if err != nil {
+				return nil, err
+			}
+			if len(collectors) > 0 {
+				d.Spec.Template.Spec.Containers[i].Args = append(d.Spec.Template.Spec.Containers[i].Args, "--collectors="+strings.Join(collectors, ","))
+			}
+
+			if cfg.ResyncPeriod != "" {
+				if v, err := time.ParseDuration(cfg.ResyncPeriod); err != nil || v <= 0 {
+					return nil, fmt.Errorf("%w - openshiftStateMetrics resyncPeriod must be a positive duration: %q", ErrConfigValidation, cfg.ResyncPeriod)
+				}
+				d.Spec.Template.Spec.Containers[i].Args = append(d.Spec.Template.Spec.Containers[i].Args, "--resync-period="+cfg.ResyncPeriod)
+			}
 		}
 	}
 
@@ -768,19 +1762,40 @@ func (f *Factory) NodeExporterServiceMonitor() (*monv1.ServiceMonitor, error) {
 	sm.Spec.Endpoints[0].TLSConfig.ServerName = fmt.Sprintf("node-exporter.%s.svc", f.namespace)
 	sm.Namespace = f.namespace
 
+	if err := f.applyCollectionProfile(sm, nodeExporterComponent); err != nil {
+		return nil, err
+	}
+
 	return sm, nil
 }
 
-func (f *Factory) NodeExporterDaemonSet() (*appsv1.DaemonSet, error) {
+// NodeExporterDaemonSet returns the node-exporter DaemonSet. The *.prom keys
+// of the given ConfigMap, if any, are mounted into the textfile directory.
+func (f *Factory) NodeExporterDaemonSet(textfile *v1.ConfigMap) (*appsv1.DaemonSet, error) {
 	ds, err := f.NewDaemonSet(f.assets.MustNewAssetReader(NodeExporterDaemonSet))
 	if err != nil {
 		return nil, err
 	}
 
+	cfg := f.config.ClusterMonitoringConfiguration.NodeExporterConfig
+	if cfg.MaxProcs != nil && *cfg.MaxProcs == 0 {
+		return nil, fmt.Errorf("%w - nodeExporter maxProcs must be at least 1", ErrConfigValidation)
+	}
+
+	if err := f.setNodeExporterTextfile(ds, textfile); err != nil {
+		return nil, err
+	}
+
 	for i, container := range ds.Spec.Template.Spec.Containers {
 		switch container.Name {
 		case "node-exporter":
 			ds.Spec.Template.Spec.Containers[i].Image = f.config.Images.NodeExporter
+			if cfg.MaxProcs != nil {
+				ds.Spec.Template.Spec.Containers[i].Args = append(ds.Spec.Template.Spec.Containers[i].Args, fmt.Sprintf("--runtime.gomaxprocs=%d", *cfg.MaxProcs))
+			}
+			if cfg.Resources != nil {
+				ds.Spec.Template.Spec.Containers[i].Resources = *cfg.Resources
+			}
 		case "kube-rbac-proxy":
 			ds.Spec.Template.Spec.Containers[i].Image = f.config.Images.KubeRbacProxy
 			ds.Spec.Template.Spec.Containers[i].Args = f.setTLSSecurityConfiguration(container.Args, KubeRbacProxyTLSCipherSuitesFlag, KubeRbacProxyMinTLSVersionFlag)
@@ -799,6 +1814,83 @@ func (f *Factory) NodeExporterDaemonSet() (*appsv1.DaemonSet, error) {
 	return ds, nil
 }
 
+func (f *Factory) setNodeExporterTextfile(ds *appsv1.DaemonSet, textfile *v1.ConfigMap) error {
+	cfg := f.config.ClusterMonitoringConfiguration.NodeExporterConfig.Textfile
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.HostPath != "" {
+		if !path.IsAbs(cfg.HostPath) || path.Clean(cfg.HostPath) == "/" {
+			return fmt.Errorf("%w - nodeExporter textfile hostPath must be an absolute path other than /: %q", ErrConfigValidation, cfg.HostPath)
+		}
+
+		hostPathType := v1.HostPathDirectoryOrCreate
+		for i, vol := range ds.Spec.Template.Spec.Volumes {
+			if vol.Name == nodeExporterTextfileVolume {
+				ds.Spec.Template.Spec.Volumes[i].VolumeSource = v1.VolumeSource{
+					HostPath: &v1.HostPathVolumeSource{
+						Path: path.Clean(cfg.HostPath),
+						Type: &hostPathType,
+					},
+				}
+			}
+		}
+	}
+
+	if textfile == nil {
+		return nil
+	}
+
+	// The textfile collector doesn't read the sub-directories hence each
+	// key is mounted as a file of the textfile directory. Files mounted with
+	// subPath aren't updated, the pods are rolled out on changes instead.
+	keys := make([]string, 0, len(textfile.Data))
+	for k := range textfile.Data {
+		if strings.HasSuffix(k, ".prom") {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64()
+	var mounts []v1.VolumeMount
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte(textfile.Data[k]))
+		mounts = append(mounts, v1.VolumeMount{
+			Name:      nodeExporterTextfileConfigMapVolume,
+			MountPath: path.Join(nodeExporterTextfileDir, k),
+			SubPath:   k,
+			ReadOnly:  true,
+		})
+	}
+
+	ds.Spec.Template.Spec.Volumes = append(ds.Spec.Template.Spec.Volumes, v1.Volume{
+		Name: nodeExporterTextfileConfigMapVolume,
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: textfile.Name},
+			},
+		},
+	})
+	for i, c := range ds.Spec.Template.Spec.Containers {
+		if c.Name == "node-exporter" {
+			ds.Spec.Template.Spec.Containers[i].VolumeMounts = append(ds.Spec.Template.Spec.Containers[i].VolumeMounts, mounts...)
+		}
+	}
+
+	if ds.Spec.Template.Annotations == nil {
+		ds.Spec.Template.Annotations = map[string]string{}
+	}
+	ds.Spec.Template.Annotations[nodeExporterTextfileHashAnnotation] = strconv.FormatUint(h.Sum64(), 32)
+
+	return nil
+}
+
 func (f *Factory) NodeExporterService() (*v1.Service, error) {
 	s, err := f.NewService(f.assets.MustNewAssetReader(NodeExporterService))
 	if err != nil {
@@ -846,7 +1938,7 @@ func (f *Factory) NodeExporterClusterRole() (*rbacv1.ClusterRole, error) {
 }
 
 func (f *Factory) NodeExporterPrometheusRule() (*monv1.PrometheusRule, error) {
-	return f.NewPrometheusRule(f.assets.MustNewAssetReader(NodeExporterPrometheusRule))
+	return f.newShippedPrometheusRule(NodeExporterPrometheusRule)
 }
 
 func (f *Factory) NodeExporterRBACProxySecret() (*v1.Secret, error) {
@@ -941,6 +2033,17 @@ func (f *Factory) PrometheusK8sRoleBindingList() (*rbacv1.RoleBindingList, error
 		rb.Subjects[0].Namespace = f.namespace
 	}
 
+	ns, err := f.HostedControlPlaneNamespace()
+	if err != nil {
+		return nil, err
+	}
+	if ns != "" && len(rbl.Items) > 0 {
+		// Allow Prometheus to discover the hosted control-plane targets.
+		rb := *rbl.Items[0].DeepCopy()
+		rb.Namespace = ns
+		rbl.Items = append(rbl.Items, rb)
+	}
+
 	return rbl, nil
 }
 
@@ -989,6 +2092,16 @@ func (f *Factory) PrometheusK8sRoleList() (*rbacv1.RoleList, error) {
 		r.Namespace = f.namespace
 	}
 
+	ns, err := f.HostedControlPlaneNamespace()
+	if err != nil {
+		return nil, err
+	}
+	if ns != "" && len(rl.Items) > 0 {
+		r := *rl.Items[0].DeepCopy()
+		r.Namespace = ns
+		rl.Items = append(rl.Items, r)
+	}
+
 	return rl, nil
 }
 
@@ -1006,7 +2119,7 @@ func (f *Factory) PrometheusUserWorkloadRoleList() (*rbacv1.RoleList, error) {
 }
 
 func (f *Factory) PrometheusK8sPrometheusRule() (*monv1.PrometheusRule, error) {
-	return f.NewPrometheusRule(f.assets.MustNewAssetReader(PrometheusK8sPrometheusRule))
+	return f.newShippedPrometheusRule(PrometheusK8sPrometheusRule)
 }
 
 func (f *Factory) PrometheusK8sServiceAccount() (*v1.ServiceAccount, error) {
@@ -1070,7 +2183,7 @@ func (f *Factory) PrometheusK8sGrpcTLSSecret() (*v1.Secret, error) {
 }
 
 func (f *Factory) PrometheusK8sThanosSidecarPrometheusRule() (*monv1.PrometheusRule, error) {
-	return f.NewPrometheusRule(f.assets.MustNewAssetReader(PrometheusK8sThanosSidecarPrometheusRule))
+	return f.newShippedPrometheusRule(PrometheusK8sThanosSidecarPrometheusRule)
 }
 
 func (f *Factory) PrometheusUserWorkloadGrpcTLSSecret() (*v1.Secret, error) {
@@ -1224,6 +2337,17 @@ func (f *Factory) PrometheusUserWorkloadRBACProxySecret() (*v1.Secret, error) {
 	return s, nil
 }
 
+func (f *Factory) PrometheusUserWorkloadRBACProxyFederateSecret() (*v1.Secret, error) {
+	s, err := f.NewSecret(f.assets.MustNewAssetReader(PrometheusUserWorkloadRBACProxyFederateSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespaceUserWorkload
+
+	return s, nil
+}
+
 func (f *Factory) ThanosQuerierRBACProxySecret() (*v1.Secret, error) {
 	s, err := f.NewSecret(f.assets.MustNewAssetReader(ThanosQuerierRBACProxySecret))
 	if err != nil {
@@ -1338,6 +2462,18 @@ func (f *Factory) ThanosQuerierRoute() (*routev1.Route, error) {
 func (f *Factory) SharingConfig(promHost, amHost, grafanaHost, thanosHost *url.URL) *v1.ConfigMap {
 	data := map[string]string{}
 
+	// The external URLs from the configuration take precedence over the
+	// Route hosts.
+	if u, err := url.Parse(f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ExternalURL); err == nil && u.Host != "" {
+		promHost = u
+	}
+
+	if f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.IsEnabled() {
+		if u, err := url.Parse(f.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.ExternalURL); err == nil && u.Host != "" {
+			amHost = u
+		}
+	}
+
 	// Configmap keys need to include "public" to indicate that they are public values.
 	// See https://bugzilla.redhat.com/show_bug.cgi?id=1807100.
 	if promHost != nil {
@@ -1365,6 +2501,27 @@ func (f *Factory) SharingConfig(promHost, amHost, grafanaHost, thanosHost *url.U
 	}
 }
 
+// PrometheusK8sResourceRecommendation returns the ConfigMap publishing the
+// memory request chosen for the platform Prometheus along with the observed
+// values it has been computed from.
+func (f *Factory) PrometheusK8sResourceRecommendation(memoryRequest resource.Quantity, workingSetBytes, headSeries float64) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prometheusK8sResourceRecommendationConfigMap,
+			Namespace: f.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "cluster-monitoring-operator",
+				"app.kubernetes.io/part-of":    "openshift-monitoring",
+			},
+		},
+		Data: map[string]string{
+			"memoryRequest":           memoryRequest.String(),
+			"observedWorkingSetBytes": strconv.FormatFloat(workingSetBytes, 'f', 0, 64),
+			"observedHeadSeries":      strconv.FormatFloat(headSeries, 'f', 0, 64),
+		},
+	}
+}
+
 func (f *Factory) PrometheusK8sTrustedCABundle() (*v1.ConfigMap, error) {
 	cm, err := f.NewConfigMap(f.assets.MustNewAssetReader(PrometheusK8sTrustedCABundle))
 	if err != nil {
@@ -1389,12 +2546,43 @@ func (f *Factory) PrometheusK8s(host string, grpcTLS *v1.Secret, trustedCABundle
 	}
 
 	p.Spec.Image = &f.config.Images.Prometheus
-	p.Spec.ExternalURL = f.PrometheusExternalURL(host).String()
+	if f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ExternalURL != "" {
+		u, err := parseExternalURL("prometheusK8s", f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ExternalURL)
+		if err != nil {
+			return nil, err
+		}
+		p.Spec.ExternalURL = u.String()
+	} else if host != "" {
+		p.Spec.ExternalURL = f.PrometheusExternalURL(host).String()
+	}
 
 	if f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.Resources != nil {
 		p.Spec.Resources = *f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.Resources
 	}
 
+	pc := f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig
+	if err := setPrometheusProbes(p, "prometheusK8s", pc.MinReadySeconds, pc.ReadinessProbe, pc.StartupProbe); err != nil {
+		return nil, err
+	}
+
+	if err := setPrometheusFeatures(p, "prometheusK8s", pc.EnableFeatures); err != nil {
+		return nil, err
+	}
+
+	if err := setPrometheusQueryLimits(p, "prometheusK8s", pc.QueryTimeout, pc.QueryMaxSamples, pc.QueryMaxConcurrency); err != nil {
+		return nil, err
+	}
+
+	if err := setPrometheusWAL(p, "prometheusK8s", pc.WAL); err != nil {
+		return nil, err
+	}
+
+	if rc := f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ResourceRecommendation; rc.IsEnabled() {
+		if rc.MinMemoryRequest != nil && rc.MaxMemoryRequest != nil && rc.MinMemoryRequest.Cmp(*rc.MaxMemoryRequest) > 0 {
+			return nil, fmt.Errorf("%w - prometheusK8s resource recommendation: minMemoryRequest (%s) is greater than maxMemoryRequest (%s)", ErrConfigValidation, rc.MinMemoryRequest.String(), rc.MaxMemoryRequest.String())
+		}
+	}
+
 	if f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.NodeSelector != nil {
 		p.Spec.NodeSelector = f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.NodeSelector
 	}
@@ -1404,7 +2592,14 @@ func (f *Factory) PrometheusK8s(host string, grpcTLS *v1.Secret, trustedCABundle
 	}
 
 	if f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ExternalLabels != nil {
-		p.Spec.ExternalLabels = f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ExternalLabels
+		p.Spec.ExternalLabels, err = renderTemplates(f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ExternalLabels, f.clusterMetadata())
+		if err != nil {
+			return nil, fmt.Errorf("%w - prometheusK8s externalLabels: %v", ErrConfigValidation, err)
+		}
+	}
+
+	if err := f.setPrometheusExternalLabelNames(p); err != nil {
+		return nil, err
 	}
 
 	if f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.VolumeClaimTemplate != nil {
@@ -1471,6 +2666,28 @@ func (f *Factory) PrometheusK8s(host string, grpcTLS *v1.Secret, trustedCABundle
 			},
 		}
 
+		if err := f.config.ClusterMonitoringConfiguration.TelemeterClientConfig.Proxy.Validate(); err != nil {
+			return nil, err
+		}
+		if proxy := f.config.ClusterMonitoringConfiguration.TelemeterClientConfig.Proxy; proxy != nil {
+			// Telemeter is served over HTTPS.
+			spec.ProxyURL = proxy.HTTPSProxy
+			if spec.ProxyURL == "" {
+				spec.ProxyURL = proxy.HTTPProxy
+			}
+		}
+
+		if err := f.config.ClusterMonitoringConfiguration.TelemeterClientConfig.ValidateAdditionalCABundle(); err != nil {
+			return nil, err
+		}
+		if ca := f.config.ClusterMonitoringConfiguration.TelemeterClientConfig.AdditionalCABundle; ca != nil {
+			// Prometheus only trusts the given CA bundle for the
+			// remote write endpoint.
+			spec.TLSConfig = &monv1.TLSConfig{
+				SafeTLSConfig: monv1.SafeTLSConfig{CA: *ca},
+			}
+		}
+
 		p.Spec.RemoteWrite = []monv1.RemoteWriteSpec{spec}
 
 	}
@@ -1479,7 +2696,11 @@ func (f *Factory) PrometheusK8s(host string, grpcTLS *v1.Secret, trustedCABundle
 	}
 
 	if len(f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.RemoteWrite) > 0 {
-		p.Spec.RemoteWrite = addRemoteWriteConfigs(p.Spec.RemoteWrite, f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.RemoteWrite...)
+		rws, err := renderRemoteWriteHeaders(f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.RemoteWrite, f.clusterMetadata())
+		if err != nil {
+			return nil, fmt.Errorf("%w - prometheusK8s %v", ErrConfigValidation, err)
+		}
+		p.Spec.RemoteWrite = addRemoteWriteConfigs(p.Spec.RemoteWrite, rws...)
 	}
 
 	for _, rw := range p.Spec.RemoteWrite {
@@ -1616,6 +2837,22 @@ func (f *Factory) PrometheusK8s(host string, grpcTLS *v1.Secret, trustedCABundle
 		p.Spec.Secrets = append(p.Spec.Secrets, getAdditionalAlertmanagerSecrets(f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.AlertmanagerConfigs)...)
 	}
 
+	// The Secrets and ConfigMaps are mounted by the Prometheus operator under
+	// /etc/prometheus/secrets/<name> and /etc/prometheus/configmaps/<name>
+	// so that scrape configurations can reference credentials and CA
+	// certificates.
+	secrets, err := appendVolumeSources(p.Spec.Secrets, f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.Secrets, "prometheusK8s secrets")
+	if err != nil {
+		return nil, err
+	}
+	p.Spec.Secrets = secrets
+
+	configMaps, err := appendVolumeSources(p.Spec.ConfigMaps, f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ConfigMaps, "prometheusK8s configMaps")
+	if err != nil {
+		return nil, err
+	}
+	p.Spec.ConfigMaps = configMaps
+
 	return p, nil
 }
 
@@ -1658,6 +2895,77 @@ func (f *Factory) PrometheusUserWorkloadAdditionalAlertManagerConfigsSecret() (*
 	}, nil
 }
 
+// userWorkloadAlertRelabelConfigs returns the relabel configs copying the
+// namespace enforced by prometheus-operator into the tenant label of the
+// user-defined alerts, or an empty list with the default tenant label. The
+// value set by the rules is always overridden and the label is removed from
+// the alerts without namespace.
+func (f *Factory) userWorkloadAlertRelabelConfigs() (string, error) {
+	label, err := f.config.ClusterMonitoringConfiguration.UserWorkloadTenancy.Label()
+	if err != nil {
+		return "", err
+	}
+
+	if label == DefaultTenantLabel {
+		return "[]", nil
+	}
+
+	relabelConfigs, err := yaml2.Marshal([]yaml2.MapSlice{
+		{
+			{Key: "action", Value: "replace"},
+			{Key: "source_labels", Value: []string{DefaultTenantLabel}},
+			{Key: "regex", Value: "(.*)"},
+			{Key: "replacement", Value: "$1"},
+			{Key: "target_label", Value: label},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(relabelConfigs), nil
+}
+
+// hasUserWorkloadTenantLabel returns true when the tenant label of the
+// user-defined alerts isn't the namespace label.
+func (f *Factory) hasUserWorkloadTenantLabel() (bool, error) {
+	label, err := f.config.ClusterMonitoringConfiguration.UserWorkloadTenancy.Label()
+	if err != nil {
+		return false, err
+	}
+
+	return label != DefaultTenantLabel, nil
+}
+
+func (f *Factory) userWorkloadAlertRelabelConfigsSecret(name string) (*v1.Secret, error) {
+	relabelConfigs, err := f.userWorkloadAlertRelabelConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: f.namespaceUserWorkload,
+		},
+		Data: map[string][]byte{
+			AlertRelabelConfigSecretKey: []byte(relabelConfigs),
+		},
+	}, nil
+}
+
+// PrometheusUserWorkloadAlertRelabelConfigsSecret returns the Secret holding
+// the alert relabel configs of the user workload Prometheus.
+func (f *Factory) PrometheusUserWorkloadAlertRelabelConfigsSecret() (*v1.Secret, error) {
+	return f.userWorkloadAlertRelabelConfigsSecret(PrometheusUWAlertRelabelConfigSecretName)
+}
+
+// ThanosRulerAlertRelabelConfigsSecret returns the Secret holding the alert
+// relabel configs of the user workload Thanos Ruler.
+func (f *Factory) ThanosRulerAlertRelabelConfigsSecret() (*v1.Secret, error) {
+	return f.userWorkloadAlertRelabelConfigsSecret(ThanosRulerAlertRelabelConfigSecretName)
+}
+
 func (f *Factory) PrometheusUserWorkload(grpcTLS *v1.Secret) (*monv1.Prometheus, error) {
 	p, err := f.NewPrometheus(f.assets.MustNewAssetReader(PrometheusUserWorkload))
 	if err != nil {
@@ -1685,8 +2993,19 @@ func (f *Factory) PrometheusUserWorkload(grpcTLS *v1.Secret) (*monv1.Prometheus,
 		p.Spec.Tolerations = f.config.UserWorkloadConfiguration.Prometheus.Tolerations
 	}
 
+	if f.config.UserWorkloadConfiguration.Prometheus.PriorityClassName != "" {
+		p.Spec.PriorityClassName = f.config.UserWorkloadConfiguration.Prometheus.PriorityClassName
+	}
+
 	if f.config.UserWorkloadConfiguration.Prometheus.ExternalLabels != nil {
-		p.Spec.ExternalLabels = f.config.UserWorkloadConfiguration.Prometheus.ExternalLabels
+		p.Spec.ExternalLabels, err = renderTemplates(f.config.UserWorkloadConfiguration.Prometheus.ExternalLabels, f.clusterMetadata())
+		if err != nil {
+			return nil, fmt.Errorf("%w - prometheus externalLabels: %v", ErrConfigValidation, err)
+		}
+	}
+
+	if err := f.setPrometheusExternalLabelNames(p); err != nil {
+		return nil, err
 	}
 
 	if f.config.UserWorkloadConfiguration.Prometheus.VolumeClaimTemplate != nil {
@@ -1696,7 +3015,11 @@ func (f *Factory) PrometheusUserWorkload(grpcTLS *v1.Secret) (*monv1.Prometheus,
 	}
 
 	if len(f.config.UserWorkloadConfiguration.Prometheus.RemoteWrite) > 0 {
-		p.Spec.RemoteWrite = addRemoteWriteConfigs(p.Spec.RemoteWrite, f.config.UserWorkloadConfiguration.Prometheus.RemoteWrite...)
+		rws, err := renderRemoteWriteHeaders(f.config.UserWorkloadConfiguration.Prometheus.RemoteWrite, f.clusterMetadata())
+		if err != nil {
+			return nil, fmt.Errorf("%w - prometheus %v", ErrConfigValidation, err)
+		}
+		p.Spec.RemoteWrite = addRemoteWriteConfigs(p.Spec.RemoteWrite, rws...)
 	}
 
 	if f.config.UserWorkloadConfiguration.Prometheus.EnforcedSampleLimit != nil {
@@ -1707,6 +3030,50 @@ func (f *Factory) PrometheusUserWorkload(grpcTLS *v1.Secret) (*monv1.Prometheus,
 		p.Spec.EnforcedTargetLimit = f.config.UserWorkloadConfiguration.Prometheus.EnforcedTargetLimit
 	}
 
+	if f.config.UserWorkloadConfiguration.Prometheus.EnforcedLabelLimit != nil {
+		p.Spec.EnforcedLabelLimit = f.config.UserWorkloadConfiguration.Prometheus.EnforcedLabelLimit
+	}
+
+	excludeRuleNamespaces(p.Spec.RuleNamespaceSelector, f.config.UserWorkloadConfiguration.ExcludedRuleNamespaces)
+
+	if f.config.UserWorkloadConfiguration.DefaultRuleEvaluationScope == RuleEvaluationScopeLeafPrometheus {
+		p.Spec.RuleSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{
+					Key:      RuleEvaluationScopeLabel,
+					Operator: metav1.LabelSelectorOpNotIn,
+					Values:   []string{RuleEvaluationScopeThanosRuler},
+				},
+			},
+		}
+	}
+
+	if f.config.UserWorkloadConfiguration.HasRuleQuotas() {
+		excludeRulesOverQuota(p.Spec.RuleSelector)
+	}
+
+	// The target quotas can be set by annotating the namespaces hence the
+	// monitors over quota are always excluded.
+	excludeMonitorsOverTargetQuota(p.Spec.ServiceMonitorSelector)
+	excludeMonitorsOverTargetQuota(p.Spec.PodMonitorSelector)
+
+	pc := f.config.UserWorkloadConfiguration.Prometheus
+	if err := setPrometheusProbes(p, "prometheus", pc.MinReadySeconds, pc.ReadinessProbe, pc.StartupProbe); err != nil {
+		return nil, err
+	}
+
+	if err := setPrometheusFeatures(p, "prometheus", pc.EnableFeatures); err != nil {
+		return nil, err
+	}
+
+	if err := setPrometheusQueryLimits(p, "prometheus", pc.QueryTimeout, pc.QueryMaxSamples, pc.QueryMaxConcurrency); err != nil {
+		return nil, err
+	}
+
+	if err := setPrometheusWAL(p, "prometheus", pc.WAL); err != nil {
+		return nil, err
+	}
+
 	if f.config.Images.Thanos != "" {
 		p.Spec.Thanos.Image = &f.config.Images.Thanos
 	}
@@ -1716,9 +3083,12 @@ func (f *Factory) PrometheusUserWorkload(grpcTLS *v1.Secret) (*monv1.Prometheus,
 	}
 
 	for i, container := range p.Spec.Containers {
-		if container.Name == "kube-rbac-proxy" || container.Name == "kube-rbac-proxy-thanos" {
+		switch container.Name {
+		case "kube-rbac-proxy", "kube-rbac-proxy-thanos", "kube-rbac-proxy-federate":
 			p.Spec.Containers[i].Image = f.config.Images.KubeRbacProxy
 			p.Spec.Containers[i].Args = f.setTLSSecurityConfiguration(container.Args, KubeRbacProxyTLSCipherSuitesFlag, KubeRbacProxyMinTLSVersionFlag)
+		case "prom-label-proxy":
+			p.Spec.Containers[i].Image = f.config.Images.PromLabelProxy
 		}
 	}
 	p.Spec.Alerting.Alertmanagers[0].Namespace = f.namespace
@@ -1745,6 +3115,19 @@ func (f *Factory) PrometheusUserWorkload(grpcTLS *v1.Secret) (*monv1.Prometheus,
 		p.Spec.Secrets = append(p.Spec.Secrets, getAdditionalAlertmanagerSecrets(alertManagerConfigs)...)
 	}
 
+	tenantLabel, err := f.hasUserWorkloadTenantLabel()
+	if err != nil {
+		return nil, err
+	}
+	if tenantLabel {
+		p.Spec.AdditionalAlertRelabelConfigs = &v1.SecretKeySelector{
+			Key: AlertRelabelConfigSecretKey,
+			LocalObjectReference: v1.LocalObjectReference{
+				Name: PrometheusUWAlertRelabelConfigSecretName,
+			},
+		}
+	}
+
 	return p, nil
 }
 
@@ -1839,6 +3222,8 @@ func (f *Factory) PrometheusAdapterServiceAccount() (*v1.ServiceAccount, error)
 	return sa, nil
 }
 
+// PrometheusAdapterConfigMap returns the prometheus-adapter configuration
+// extended with the custom and external metrics rules, if any.
 func (f *Factory) PrometheusAdapterConfigMap() (*v1.ConfigMap, error) {
 	cm, err := f.NewConfigMap(f.assets.MustNewAssetReader(PrometheusAdapterConfigMap))
 	if err != nil {
@@ -1847,9 +3232,87 @@ func (f *Factory) PrometheusAdapterConfigMap() (*v1.ConfigMap, error) {
 
 	cm.Namespace = f.namespace
 
+	config := f.config.ClusterMonitoringConfiguration.K8sPrometheusAdapter
+	if len(config.CustomMetricsRules) == 0 && len(config.ExternalMetricsRules) == 0 {
+		return cm, nil
+	}
+
+	var adapterConfig yaml2.MapSlice
+	if err := yaml2.Unmarshal([]byte(cm.Data[prometheusAdapterConfigKey]), &adapterConfig); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the prometheus-adapter configuration")
+	}
+
+	for _, r := range []struct {
+		key   string
+		field string
+		rules []PrometheusAdapterRule
+	}{
+		{key: "rules", field: "customMetricsRules", rules: config.CustomMetricsRules},
+		{key: "externalRules", field: "externalMetricsRules", rules: config.ExternalMetricsRules},
+	} {
+		if len(r.rules) == 0 {
+			continue
+		}
+
+		rules, err := renderPrometheusAdapterRules(r.field, r.rules)
+		if err != nil {
+			return nil, err
+		}
+		adapterConfig = append(adapterConfig, yaml2.MapItem{Key: r.key, Value: rules})
+	}
+
+	b, err := yaml2.Marshal(adapterConfig)
+	if err != nil {
+		return nil, err
+	}
+	cm.Data[prometheusAdapterConfigKey] = string(b)
+
 	return cm, nil
 }
 
+// renderPrometheusAdapterRules validates the rules and returns their generic
+// YAML representation.
+func renderPrometheusAdapterRules(field string, rules []PrometheusAdapterRule) (interface{}, error) {
+	for i, r := range rules {
+		if r.SeriesQuery == "" || r.MetricsQuery == "" {
+			return nil, fmt.Errorf("%w - k8sPrometheusAdapter %s[%d]: seriesQuery and metricsQuery are required", ErrConfigValidation, field, i)
+		}
+
+		patterns := make([]string, 0, len(r.SeriesFilters)*2+1)
+		for _, sf := range r.SeriesFilters {
+			patterns = append(patterns, sf.Is, sf.IsNot)
+		}
+		if r.Name != nil {
+			patterns = append(patterns, r.Name.Matches)
+		}
+		for _, p := range patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				return nil, fmt.Errorf("%w - k8sPrometheusAdapter %s[%d]: invalid regular expression %q: %v", ErrConfigValidation, field, i, p, err)
+			}
+		}
+
+		for label, gr := range r.Resources.Overrides {
+			if gr.Resource == "" {
+				return nil, fmt.Errorf("%w - k8sPrometheusAdapter %s[%d]: missing resource for label %q", ErrConfigValidation, field, i, label)
+			}
+		}
+	}
+
+	// The rules are converted through JSON to honor the field names of the
+	// adapter configuration.
+	b, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := yaml2.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
 func (f *Factory) PrometheusAdapterConfigMapAuditPolicy() (*v1.ConfigMap, error) {
 	cm, err := f.NewConfigMap(f.assets.MustNewAssetReader(PrometheusAdapterConfigMapAuditPolicy))
 	if err != nil {
@@ -1908,6 +3371,22 @@ func (f *Factory) PrometheusAdapterDeployment(apiAuthSecretName string, requesth
 	}
 	dep.Namespace = f.namespace
 
+	// Both backends are served on the same port behind the same
+	// authorization rules, the CA bundle and token are unchanged.
+	backend := config.QueryBackend
+	switch backend {
+	case "":
+		backend = PrometheusAdapterQueryBackendThanosQuerier
+	case PrometheusAdapterQueryBackendThanosQuerier, PrometheusAdapterQueryBackendPrometheusK8s:
+	default:
+		return nil, fmt.Errorf("%w - k8sPrometheusAdapter queryBackend must be %q or %q: %q", ErrConfigValidation, PrometheusAdapterQueryBackendThanosQuerier, PrometheusAdapterQueryBackendPrometheusK8s, backend)
+	}
+	for i, arg := range spec.Containers[0].Args {
+		if strings.HasPrefix(arg, "--prometheus-url=") {
+			spec.Containers[0].Args[i] = fmt.Sprintf("--prometheus-url=https://%s.%s.svc:9091", backend, f.namespace)
+		}
+	}
+
 	r := newErrMapReader(requestheader)
 
 	var (
@@ -1964,6 +3443,21 @@ func (f *Factory) PrometheusAdapterDeployment(apiAuthSecretName string, requesth
 		},
 	)
 
+	// prometheus-adapter doesn't reload its configuration.
+	if len(config.CustomMetricsRules) > 0 || len(config.ExternalMetricsRules) > 0 {
+		b, err := json.Marshal([][]PrometheusAdapterRule{config.CustomMetricsRules, config.ExternalMetricsRules})
+		if err != nil {
+			return nil, err
+		}
+
+		h := fnv.New64()
+		h.Write(b)
+		if dep.Spec.Template.Annotations == nil {
+			dep.Spec.Template.Annotations = map[string]string{}
+		}
+		dep.Spec.Template.Annotations[prometheusAdapterRulesHashAnnotation] = strconv.FormatUint(h.Sum64(), 32)
+	}
+
 	spec.Containers[0].Args = f.setTLSSecurityConfiguration(spec.Containers[0].Args,
 		PrometheusAdapterTLSCipherSuitesFlag, PrometheusAdapterTLSMinTLSVersionFlag)
 
@@ -2058,6 +3552,30 @@ func (f *Factory) PrometheusAdapterAPIService() (*apiregistrationv1.APIService,
 	return f.NewAPIService(f.assets.MustNewAssetReader(PrometheusAdapterAPIService))
 }
 
+// PrometheusAdapterCustomMetricsAPIService returns the APIService of the
+// custom metrics API served by prometheus-adapter.
+func (f *Factory) PrometheusAdapterCustomMetricsAPIService() (*apiregistrationv1.APIService, error) {
+	return f.prometheusAdapterMetricsAPIService(prometheusAdapterCustomMetricsGroup)
+}
+
+// PrometheusAdapterExternalMetricsAPIService returns the APIService of the
+// external metrics API served by prometheus-adapter.
+func (f *Factory) PrometheusAdapterExternalMetricsAPIService() (*apiregistrationv1.APIService, error) {
+	return f.prometheusAdapterMetricsAPIService(prometheusAdapterExternalMetricsGroup)
+}
+
+func (f *Factory) prometheusAdapterMetricsAPIService(group string) (*apiregistrationv1.APIService, error) {
+	api, err := f.PrometheusAdapterAPIService()
+	if err != nil {
+		return nil, err
+	}
+
+	api.Name = fmt.Sprintf("%s.%s", api.Spec.Version, group)
+	api.Spec.Group = group
+
+	return api, nil
+}
+
 func (f *Factory) PrometheusOperatorServiceMonitor() (*monv1.ServiceMonitor, error) {
 	sm, err := f.NewServiceMonitor(f.assets.MustNewAssetReader(PrometheusOperatorServiceMonitor))
 	if err != nil {
@@ -2071,7 +3589,7 @@ func (f *Factory) PrometheusOperatorServiceMonitor() (*monv1.ServiceMonitor, err
 }
 
 func (f *Factory) PrometheusOperatorPrometheusRule() (*monv1.PrometheusRule, error) {
-	return f.NewPrometheusRule(f.assets.MustNewAssetReader(PrometheusOperatorPrometheusRule))
+	return f.newShippedPrometheusRule(PrometheusOperatorPrometheusRule)
 }
 
 func (f *Factory) PrometheusOperatorUserWorkloadServiceMonitor() (*monv1.ServiceMonitor, error) {
@@ -2191,6 +3709,10 @@ func (f *Factory) PrometheusOperatorDeployment() (*appsv1.Deployment, error) {
 		d.Spec.Template.Spec.Tolerations = f.config.ClusterMonitoringConfiguration.PrometheusOperatorConfig.Tolerations
 	}
 
+	if f.config.ClusterMonitoringConfiguration.PrometheusOperatorConfig.PriorityClassName != "" {
+		d.Spec.Template.Spec.PriorityClassName = f.config.ClusterMonitoringConfiguration.PrometheusOperatorConfig.PriorityClassName
+	}
+
 	for i, container := range d.Spec.Template.Spec.Containers {
 		switch container.Name {
 		case "kube-rbac-proxy":
@@ -2240,6 +3762,10 @@ func (f *Factory) PrometheusOperatorUserWorkloadDeployment() (*appsv1.Deployment
 		d.Spec.Template.Spec.Tolerations = f.config.UserWorkloadConfiguration.PrometheusOperator.Tolerations
 	}
 
+	if f.config.UserWorkloadConfiguration.PrometheusOperator.PriorityClassName != "" {
+		d.Spec.Template.Spec.PriorityClassName = f.config.UserWorkloadConfiguration.PrometheusOperator.PriorityClassName
+	}
+
 	for i, container := range d.Spec.Template.Spec.Containers {
 		switch container.Name {
 		case "kube-rbac-proxy":
@@ -2476,6 +4002,61 @@ func (f *Factory) GrafanaDatasources() (*v1.Secret, error) {
 	return s, nil
 }
 
+// GrafanaDatasourcesWithAdditional returns a copy of the Grafana datasources
+// secret with the additional datasources merged under a dedicated key. The
+// sources are the contents of the secret keys referenced by the
+// configuration, in the same order. The generated Prometheus datasource is
+// left untouched because its password is shared with the Prometheus htpasswd
+// secret.
+func (f *Factory) GrafanaDatasourcesWithAdditional(s *v1.Secret, sources [][]byte) (*v1.Secret, error) {
+	s = s.DeepCopy()
+	if len(sources) == 0 {
+		delete(s.Data, grafanaAdditionalDatasourcesKey)
+		return s, nil
+	}
+
+	names := map[string]struct{}{"prometheus": {}}
+	datasources := make([]map[string]interface{}, 0, len(sources))
+	for i, src := range sources {
+		var ds map[string]interface{}
+		if err := yaml.Unmarshal(src, &ds); err != nil {
+			return nil, fmt.Errorf("%w - grafana additionalDatasources[%d]: %v", ErrConfigValidation, i, err)
+		}
+
+		name, _ := ds["name"].(string)
+		typ, _ := ds["type"].(string)
+		if name == "" || typ == "" {
+			return nil, fmt.Errorf("%w - grafana additionalDatasources[%d]: name and type are required", ErrConfigValidation, i)
+		}
+		if _, found := names[name]; found {
+			return nil, fmt.Errorf("%w - grafana additionalDatasources[%d]: duplicate datasource name %q", ErrConfigValidation, i, name)
+		}
+		names[name] = struct{}{}
+
+		// Grafana is read-only hence the datasources can't be edited.
+		ds["editable"] = false
+		if _, found := ds["orgId"]; !found {
+			ds["orgId"] = 1
+		}
+		datasources = append(datasources, ds)
+	}
+
+	b, err := json.MarshalIndent(map[string]interface{}{
+		"apiVersion":  1,
+		"datasources": datasources,
+	}, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Data == nil {
+		s.Data = map[string][]byte{}
+	}
+	s.Data[grafanaAdditionalDatasourcesKey] = b
+
+	return s, nil
+}
+
 func (f *Factory) GrafanaDashboardDefinitions() (*v1.ConfigMapList, error) {
 	cl, err := f.NewConfigMapList(f.assets.MustNewAssetReader(GrafanaDashboardDefinitions))
 	if err != nil {
@@ -2485,6 +4066,7 @@ func (f *Factory) GrafanaDashboardDefinitions() (*v1.ConfigMapList, error) {
 	configmaps := []v1.ConfigMap{}
 	for _, c := range cl.Items {
 		c.Namespace = f.namespace
+		f.setOrigin(&c, "grafana")
 		if !f.config.ClusterMonitoringConfiguration.EtcdConfig.IsEnabled() {
 			if c.GetName() != "grafana-dashboard-etcd" {
 				configmaps = append(configmaps, c)
@@ -2506,6 +4088,10 @@ func (f *Factory) GrafanaDashboardSources() (*v1.ConfigMap, error) {
 
 	c.Namespace = f.namespace
 
+	if err := addGrafanaCustomDashboardsProvider(c); err != nil {
+		return nil, err
+	}
+
 	return c, nil
 }
 
@@ -2521,12 +4107,26 @@ func (f *Factory) GrafanaTrustedCABundle() (*v1.ConfigMap, error) {
 // GrafanaDeployment generates a new Deployment for Grafana.
 // If the passed ConfigMap is not empty it mounts the Trusted CA Bundle as a VolumeMount to
 // /etc/pki/ca-trust/extracted/pem/ location.
-func (f *Factory) GrafanaDeployment(proxyCABundleCM *v1.ConfigMap) (*appsv1.Deployment, error) {
+// If the passed datasources Secret holds additional datasources, their hash
+// is added to the pod template so that Grafana is restarted to provision
+// them.
+func (f *Factory) GrafanaDeployment(proxyCABundleCM *v1.ConfigMap, datasources *v1.Secret) (*appsv1.Deployment, error) {
 	d, err := f.NewDeployment(f.assets.MustNewAssetReader(GrafanaDeployment))
 	if err != nil {
 		return nil, err
 	}
 
+	if datasources != nil {
+		if b, found := datasources.Data[grafanaAdditionalDatasourcesKey]; found {
+			h := fnv.New64()
+			h.Write(b)
+			if d.Spec.Template.Annotations == nil {
+				d.Spec.Template.Annotations = map[string]string{}
+			}
+			d.Spec.Template.Annotations[grafanaAdditionalDatasourcesHashAnnotation] = strconv.FormatUint(h.Sum64(), 32)
+		}
+	}
+
 	for i, container := range d.Spec.Template.Spec.Containers {
 		switch container.Name {
 		case "grafana":
@@ -2550,6 +4150,10 @@ func (f *Factory) GrafanaDeployment(proxyCABundleCM *v1.ConfigMap) (*appsv1.Depl
 				d.Spec.Template.Spec.Containers[i].VolumeMounts = volMounts
 			}
 
+			vol, volMount := grafanaCustomDashboardsVolume()
+			d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, vol)
+			d.Spec.Template.Spec.Containers[i].VolumeMounts = append(d.Spec.Template.Spec.Containers[i].VolumeMounts, volMount)
+
 		case "grafana-proxy":
 			d.Spec.Template.Spec.Containers[i].Image = f.config.Images.OauthProxy
 
@@ -2734,7 +4338,7 @@ func (f *Factory) ClusterMonitoringOperatorServiceMonitor() (*monv1.ServiceMonit
 }
 
 func (f *Factory) ClusterMonitoringOperatorPrometheusRule() (*monv1.PrometheusRule, error) {
-	r, err := f.NewPrometheusRule(f.assets.MustNewAssetReader(ClusterMonitoringOperatorPrometheusRule))
+	r, err := f.newShippedPrometheusRule(ClusterMonitoringOperatorPrometheusRule)
 	if err != nil {
 		return nil, err
 	}
@@ -2745,7 +4349,7 @@ func (f *Factory) ClusterMonitoringOperatorPrometheusRule() (*monv1.PrometheusRu
 }
 
 func (f *Factory) ControlPlanePrometheusRule() (*monv1.PrometheusRule, error) {
-	r, err := f.NewPrometheusRule(f.assets.MustNewAssetReader(ControlPlanePrometheusRule))
+	r, err := f.newShippedPrometheusRule(ControlPlanePrometheusRule)
 	if err != nil {
 		return nil, err
 	}
@@ -2753,11 +4357,21 @@ func (f *Factory) ControlPlanePrometheusRule() (*monv1.PrometheusRule, error) {
 	r.Namespace = f.namespace
 
 	if f.infrastructure.HostedControlPlane() {
+		ns, err := f.HostedControlPlaneNamespace()
+		if err != nil {
+			return nil, err
+		}
+
 		groups := []monv1.RuleGroup{}
 		for _, g := range r.Spec.Groups {
 			switch g.Name {
-			case "kubernetes-system-apiserver",
-				"kubernetes-system-controller-manager",
+			case "kubernetes-system-apiserver":
+				// The API server is only scraped when the namespace of
+				// the hosted control plane is known.
+				if ns != "" {
+					groups = append(groups, g)
+				}
+			case "kubernetes-system-controller-manager",
 				"kubernetes-system-scheduler":
 				// skip
 			default:
@@ -2767,7 +4381,126 @@ func (f *Factory) ControlPlanePrometheusRule() (*monv1.PrometheusRule, error) {
 		r.Spec.Groups = groups
 	}
 
-	return r, nil
+	return r, nil
+}
+
+// HostedControlPlaneNamespace returns the namespace where the control-plane
+// pods are running. It returns an empty string when the control plane isn't
+// hosted or when the namespace isn't configured.
+func (f *Factory) HostedControlPlaneNamespace() (string, error) {
+	if !f.infrastructure.HostedControlPlane() {
+		return "", nil
+	}
+
+	ns := f.config.ClusterMonitoringConfiguration.HostedControlPlane.Namespace
+	if ns == "" {
+		return "", nil
+	}
+
+	if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+		return "", fmt.Errorf("%w - hostedControlPlane namespace: invalid name %q: %s", ErrConfigValidation, ns, strings.Join(errs, ", "))
+	}
+
+	return ns, nil
+}
+
+// ControlPlaneHostedMetricsClientSecret returns the secret holding the client
+// certificate and CA used to scrape the hosted control-plane pods.
+func (f *Factory) ControlPlaneHostedMetricsClientSecret(tlsClient *v1.Secret) (*v1.Secret, error) {
+	data := make(map[string]string)
+
+	for k, v := range tlsClient.Data {
+		data[k] = string(v)
+	}
+
+	r := newErrMapReader(data)
+
+	var (
+		clientCA   = r.value("ca.crt")
+		clientCert = r.value("tls.crt")
+		clientKey  = r.value("tls.key")
+	)
+
+	if r.Error() != nil {
+		return nil, errors.Wrap(r.err, "couldn't find hosted control-plane metrics client certificate data")
+	}
+
+	ns, err := f.HostedControlPlaneNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	s := f.ControlPlaneHostedMetricsClientSecretMeta()
+	s.Annotations = map[string]string{HostedControlPlaneNamespaceAnnotation: ns}
+	s.StringData = map[string]string{
+		"ca.crt":  clientCA,
+		"tls.crt": clientCert,
+		"tls.key": clientKey,
+	}
+	return s, nil
+}
+
+// ControlPlaneHostedMetricsClientSecretMeta returns the hosted control-plane
+// metrics client secret without data, to be retrieved or deleted.
+func (f *Factory) ControlPlaneHostedMetricsClientSecretMeta() *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: f.namespace,
+			Name:      hostedControlPlaneMetricsClientSecret,
+		},
+	}
+}
+
+// PrometheusK8sHostedControlPlaneRBAC returns the Role and RoleBinding
+// allowing Prometheus to discover the hosted control-plane targets in the
+// given namespace.
+func (f *Factory) PrometheusK8sHostedControlPlaneRBAC(namespace string) (*rbacv1.Role, *rbacv1.RoleBinding, error) {
+	rl, err := f.NewRoleList(f.assets.MustNewAssetReader(PrometheusK8sRoleList))
+	if err != nil {
+		return nil, nil, err
+	}
+	rbl, err := f.NewRoleBindingList(f.assets.MustNewAssetReader(PrometheusK8sRoleBindingList))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rl.Items) == 0 || len(rbl.Items) == 0 {
+		return nil, nil, errors.New("no Prometheus Role or RoleBinding to copy")
+	}
+
+	r := rl.Items[0].DeepCopy()
+	r.Namespace = namespace
+	rb := rbl.Items[0].DeepCopy()
+	rb.Namespace = namespace
+	rb.Subjects[0].Namespace = f.namespace
+
+	return r, rb, nil
+}
+
+func (f *Factory) ControlPlaneEtcdHostedServiceMonitor() (*monv1.ServiceMonitor, error) {
+	return f.controlPlaneHostedServiceMonitor(ControlPlaneEtcdHostedServiceMonitor)
+}
+
+func (f *Factory) ControlPlaneKubeAPIServerHostedServiceMonitor() (*monv1.ServiceMonitor, error) {
+	return f.controlPlaneHostedServiceMonitor(ControlPlaneKubeAPIServerHostedServiceMonitor)
+}
+
+func (f *Factory) controlPlaneHostedServiceMonitor(asset string) (*monv1.ServiceMonitor, error) {
+	s, err := f.NewServiceMonitor(f.assets.MustNewAssetReader(asset))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespace
+
+	ns, err := f.HostedControlPlaneNamespace()
+	if err != nil {
+		return nil, err
+	}
+	if ns != "" {
+		s.Spec.NamespaceSelector.MatchNames = []string{ns}
+	}
+
+	return s, nil
 }
 
 func (f *Factory) ControlPlaneEtcdSecret(tlsClient *v1.Secret, ca *v1.ConfigMap) (*v1.Secret, error) {
@@ -2825,6 +4558,10 @@ func (f *Factory) ControlPlaneKubeletServiceMonitor() (*monv1.ServiceMonitor, er
 
 	s.Namespace = f.namespace
 
+	if err := f.applyCollectionProfile(s, kubeletComponent); err != nil {
+		return nil, err
+	}
+
 	return s, nil
 }
 
@@ -3058,9 +4795,139 @@ func (f *Factory) NewPrometheusRule(manifest io.Reader) (*monv1.PrometheusRule,
 		p.SetNamespace(f.namespace)
 	}
 
+	if err := f.excludeRules(p); err != nil {
+		return nil, err
+	}
+	f.markDarkLaunchedAlerts(p)
+
 	return p, nil
 }
 
+// excludeRules removes the alerting rules and the rule groups excluded by
+// the configuration. Groups left without rules are removed as well since
+// Prometheus rejects empty groups.
+func (f *Factory) excludeRules(p *monv1.PrometheusRule) error {
+	exclusions := f.config.ClusterMonitoringConfiguration.ExcludedRules
+	if len(exclusions) == 0 {
+		return nil
+	}
+
+	for _, e := range exclusions {
+		if e.Group == "" && e.Alert == "" {
+			return fmt.Errorf("%w - excludedRules: either group or alert is required", ErrConfigValidation)
+		}
+	}
+
+	removed := map[ExcludedRule]int{}
+	groups := p.Spec.Groups[:0]
+	for _, g := range p.Spec.Groups {
+		rules := g.Rules[:0]
+		for _, r := range g.Rules {
+			excluded := false
+			for _, e := range exclusions {
+				// Recording rules are only removed with their group since
+				// other rules and dashboards may depend on them.
+				if r.Alert == "" && e.Alert != "" {
+					continue
+				}
+				if e.matches(g.Name, r.Alert) {
+					removed[e]++
+					excluded = true
+					break
+				}
+			}
+			if !excluded {
+				rules = append(rules, r)
+			}
+		}
+
+		if len(rules) == 0 {
+			continue
+		}
+		g.Rules = rules
+		groups = append(groups, g)
+	}
+	p.Spec.Groups = groups
+
+	// The objects without excluded rules are recorded too so that they
+	// reset the counts of a previous reconciliation.
+	f.excludedRulesMtx.Lock()
+	defer f.excludedRulesMtx.Unlock()
+	if f.excludedRules == nil {
+		f.excludedRules = map[string]map[ExcludedRule]int{}
+	}
+	f.excludedRules[p.Namespace+"/"+p.Name] = removed
+
+	return nil
+}
+
+// ExcludedRules returns the number of rules removed by each configured
+// exclusion from the PrometheusRule objects rendered so far. Exclusions
+// which didn't match any rule are reported with a count of zero.
+func (f *Factory) ExcludedRules() map[ExcludedRule]int {
+	return CountExcludedRules(f.config.ClusterMonitoringConfiguration.ExcludedRules, f.ExcludedRulesByObject())
+}
+
+// ExcludedRulesByObject returns the number of rules removed by each
+// exclusion from each PrometheusRule object rendered so far, keyed by the
+// namespace/name of the object.
+func (f *Factory) ExcludedRulesByObject() map[string]map[ExcludedRule]int {
+	f.excludedRulesMtx.Lock()
+	defer f.excludedRulesMtx.Unlock()
+
+	ret := make(map[string]map[ExcludedRule]int, len(f.excludedRules))
+	for k, v := range f.excludedRules {
+		ret[k] = v
+	}
+
+	return ret
+}
+
+// CountExcludedRules sums the number of rules removed by each of the
+// exclusions over the given PrometheusRule objects. Exclusions which didn't
+// match any rule are reported with a count of zero.
+func CountExcludedRules(exclusions []ExcludedRule, byObject map[string]map[ExcludedRule]int) map[ExcludedRule]int {
+	excluded := make(map[ExcludedRule]int, len(exclusions))
+	for _, e := range exclusions {
+		excluded[e] = 0
+		for _, removed := range byObject {
+			excluded[e] += removed[e]
+		}
+	}
+
+	return excluded
+}
+
+// markDarkLaunchedAlerts adds the dark launch label to the alerting rules
+// which are shipped with the dark launch annotation or configured as such.
+// The alerts are still evaluated and exposed by the ALERTS metric but they
+// are dropped by the alert relabeling of prometheus-k8s before reaching
+// Alertmanager.
+func (f *Factory) markDarkLaunchedAlerts(p *monv1.PrometheusRule) {
+	overrides := f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.DarkLaunchAlerts
+
+	for i := range p.Spec.Groups {
+		for j, r := range p.Spec.Groups[i].Rules {
+			if r.Alert == "" {
+				continue
+			}
+
+			darkLaunch := r.Annotations[DarkLaunchAnnotation] == "true"
+			if v, found := overrides[r.Alert]; found {
+				darkLaunch = v
+			}
+			if !darkLaunch {
+				continue
+			}
+
+			if r.Labels == nil {
+				p.Spec.Groups[i].Rules[j].Labels = map[string]string{}
+			}
+			p.Spec.Groups[i].Rules[j].Labels[DarkLaunchLabel] = "true"
+		}
+	}
+}
+
 func (f *Factory) NewTelemeterPrometheusRecRuleFromString(expr string) (*monv1.PrometheusRule, error) {
 	p := &monv1.PrometheusRule{
 		ObjectMeta: metav1.ObjectMeta{
@@ -3084,6 +4951,7 @@ func (f *Factory) NewTelemeterPrometheusRecRuleFromString(expr string) (*monv1.P
 	if p.GetNamespace() == "" {
 		p.SetNamespace(f.namespace)
 	}
+	f.setOrigin(p, "telemeter-client")
 
 	return p, nil
 }
@@ -3188,6 +5056,203 @@ func (f *Factory) ThanosQuerierPodDisruptionBudget() (*policyv1.PodDisruptionBud
 	return pdb, nil
 }
 
+// setPrometheusQueryLimits bounds the time and the memory used by a single
+// query so that a runaway query fails with an explicit error instead of
+// destabilizing Prometheus.
+func setPrometheusQueryLimits(p *monv1.Prometheus, component string, timeout string, maxSamples, maxConcurrency *uint32) error {
+	if timeout == "" && maxSamples == nil && maxConcurrency == nil {
+		return nil
+	}
+
+	if p.Spec.Query == nil {
+		p.Spec.Query = &monv1.QuerySpec{}
+	}
+
+	if timeout != "" {
+		if v, err := model.ParseDuration(timeout); err != nil || v == 0 {
+			return fmt.Errorf("%w - %s queryTimeout must be a positive duration: %q", ErrConfigValidation, component, timeout)
+		}
+		p.Spec.Query.Timeout = &timeout
+	}
+
+	if maxSamples != nil {
+		if *maxSamples == 0 || *maxSamples > math.MaxInt32 {
+			return fmt.Errorf("%w - %s queryMaxSamples must be between 1 and %d: %d", ErrConfigValidation, component, math.MaxInt32, *maxSamples)
+		}
+		v := int32(*maxSamples)
+		p.Spec.Query.MaxSamples = &v
+	}
+
+	if maxConcurrency != nil {
+		if *maxConcurrency == 0 || *maxConcurrency > math.MaxInt32 {
+			return fmt.Errorf("%w - %s queryMaxConcurrency must be between 1 and %d: %d", ErrConfigValidation, component, math.MaxInt32, *maxConcurrency)
+		}
+		v := int32(*maxConcurrency)
+		p.Spec.Query.MaxConcurrency = &v
+	}
+
+	return nil
+}
+
+// thanosQuerierQueryArgs returns the flags limiting the queries processed by
+// Thanos Querier.
+func thanosQuerierQueryArgs(c *ThanosQuerierConfig) ([]string, error) {
+	var args []string
+
+	if c.MaxConcurrentQueries != nil {
+		if *c.MaxConcurrentQueries == 0 {
+			return nil, fmt.Errorf("%w - thanosQuerier maxConcurrentQueries must be greater than 0", ErrConfigValidation)
+		}
+		args = append(args, fmt.Sprintf("--query.max-concurrent=%d", *c.MaxConcurrentQueries))
+	}
+
+	for _, d := range []struct {
+		field, flag, value string
+	}{
+		{field: "queryTimeout", flag: "--query.timeout", value: c.QueryTimeout},
+		{field: "lookbackDelta", flag: "--query.lookback-delta", value: c.LookbackDelta},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if v, err := model.ParseDuration(d.value); err != nil || v == 0 {
+			return nil, fmt.Errorf("%w - thanosQuerier %s must be a positive duration: %q", ErrConfigValidation, d.field, d.value)
+		}
+		args = append(args, fmt.Sprintf("%s=%s", d.flag, d.value))
+	}
+
+	return args, nil
+}
+
+const (
+	// thanosQuerierStoreGRPCPort and thanosQuerierStoreHTTPPort are the
+	// first local ports of the queriers proxying the additional stores.
+	thanosQuerierStoreGRPCPort = 10911
+	thanosQuerierStoreHTTPPort = 11911
+)
+
+// thanosQuerierStoreTLSFiles returns the files referenced by the TLS
+// settings of the i-th additional store.
+func thanosQuerierStoreTLSFiles(i int, t TLSConfig) []tlsConfigFile {
+	return tlsConfigFiles(fmt.Sprintf("store-%d", i), t)
+}
+
+func validateThanosQuerierAdditionalStores(stores []ThanosQuerierStoreConfig) error {
+	seen := make(map[string]struct{}, len(stores))
+	for i, s := range stores {
+		if _, _, err := net.SplitHostPort(s.Address); err != nil {
+			return fmt.Errorf("%w - thanosQuerier additionalStores[%d]: invalid address %q: %v", ErrConfigValidation, i, s.Address, err)
+		}
+		if _, found := seen[s.Address]; found {
+			return fmt.Errorf("%w - thanosQuerier additionalStores: duplicate address %q", ErrConfigValidation, s.Address)
+		}
+		seen[s.Address] = struct{}{}
+
+		if s.Namespace != "" {
+			if errs := validation.IsDNS1123Label(s.Namespace); len(errs) > 0 {
+				return fmt.Errorf("%w - thanosQuerier additionalStores store %q: invalid namespace %q: %s", ErrConfigValidation, s.Address, s.Namespace, strings.Join(errs, ", "))
+			}
+		}
+
+		if s.TLSConfig == nil {
+			continue
+		}
+
+		if (s.TLSConfig.Cert == nil) != (s.TLSConfig.Key == nil) {
+			return fmt.Errorf("%w - thanosQuerier additionalStores store %q: cert and key must be set together", ErrConfigValidation, s.Address)
+		}
+
+		for _, f := range thanosQuerierStoreTLSFiles(i, *s.TLSConfig) {
+			if f.selector.Name == "" || f.selector.Key == "" {
+				return fmt.Errorf("%w - thanosQuerier additionalStores store %q: %s must reference a Secret name and key", ErrConfigValidation, s.Address, f.field)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ThanosQuerierAdditionalStoresTLS returns the certificates and keys
+// referenced by the TLS settings of the additional stores, read from their
+// Secrets with the given function, as key/value pairs to be added to the
+// Thanos Querier GRPC TLS Secret. The pairs are ordered by store.
+func (f *Factory) ThanosQuerierAdditionalStoresTLS(getSecret func(namespace, name string) (*v1.Secret, error)) ([]string, error) {
+	stores := f.config.ClusterMonitoringConfiguration.ThanosQuerierConfig.AdditionalStores
+	if err := validateThanosQuerierAdditionalStores(stores); err != nil {
+		return nil, err
+	}
+
+	var data []string
+	for i, s := range stores {
+		if s.TLSConfig == nil {
+			continue
+		}
+
+		namespace := s.Namespace
+		if namespace == "" {
+			namespace = f.namespace
+		}
+
+		for _, ref := range thanosQuerierStoreTLSFiles(i, *s.TLSConfig) {
+			src, err := getSecret(namespace, ref.selector.Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading Secret %s/%s of store %q failed", namespace, ref.selector.Name, s.Address)
+			}
+
+			v, found := src.Data[ref.selector.Key]
+			if !found {
+				return nil, fmt.Errorf("key %q not found in Secret %s/%s of store %q", ref.selector.Key, namespace, ref.selector.Name, s.Address)
+			}
+			data = append(data, ref.key, string(v))
+		}
+	}
+
+	return data, nil
+}
+
+// thanosQuerierStoreContainer returns the container proxying the i-th
+// additional store. Thanos Querier applies the same gRPC client TLS settings
+// to all its stores, hence each additional store is queried by a dedicated
+// querier using the TLS settings of the store and serving the store API on
+// a local port with the in-cluster certificates.
+func thanosQuerierStoreContainer(query v1.Container, i int, s ThanosQuerierStoreConfig, logLevel string) v1.Container {
+	args := []string{
+		"query",
+		fmt.Sprintf("--grpc-address=127.0.0.1:%d", thanosQuerierStoreGRPCPort+i),
+		fmt.Sprintf("--http-address=127.0.0.1:%d", thanosQuerierStoreHTTPPort+i),
+		"--log.format=logfmt",
+		"--store=" + s.Address,
+		"--grpc-server-tls-cert=/etc/tls/grpc/server.crt",
+		"--grpc-server-tls-key=/etc/tls/grpc/server.key",
+		"--grpc-server-tls-client-ca=/etc/tls/grpc/ca.crt",
+	}
+	if logLevel != "" {
+		args = append(args, "--log.level="+logLevel)
+	}
+
+	if s.TLSConfig != nil {
+		args = append(args, "--grpc-client-tls-secure")
+		for _, f := range thanosQuerierStoreTLSFiles(i, *s.TLSConfig) {
+			args = append(args, fmt.Sprintf("--grpc-client-tls-%s=/etc/tls/grpc/%s", f.field, f.key))
+		}
+		if s.TLSConfig.ServerName != "" {
+			args = append(args, "--grpc-client-server-name="+s.TLSConfig.ServerName)
+		}
+		if s.TLSConfig.InsecureSkipVerify {
+			args = append(args, "--grpc-client-tls-skip-verify")
+		}
+	}
+
+	return v1.Container{
+		Name:                     fmt.Sprintf("thanos-query-store-%d", i),
+		Image:                    query.Image,
+		Args:                     args,
+		Resources:                *query.Resources.DeepCopy(),
+		TerminationMessagePolicy: query.TerminationMessagePolicy,
+		VolumeMounts:             append([]v1.VolumeMount(nil), query.VolumeMounts...),
+	}
+}
+
 func (f *Factory) ThanosQuerierDeployment(grpcTLS *v1.Secret, enableUserWorkloadMonitoring bool, trustedCA *v1.ConfigMap) (*appsv1.Deployment, error) {
 	d, err := f.NewDeployment(f.assets.MustNewAssetReader(ThanosQuerierDeployment))
 	if err != nil {
@@ -3253,6 +5318,14 @@ func (f *Factory) ThanosQuerierDeployment(grpcTLS *v1.Secret, enableUserWorkload
 				)
 			}
 
+			if f.config.ClusterMonitoringConfiguration.ThanosReceiveConfig.IsEnabled() {
+				d.Spec.Template.Spec.Containers[i].Args = append(
+					d.Spec.Template.Spec.Containers[i].Args,
+					"--query.replica-label=thanos_receive_replica",
+					fmt.Sprintf("--store=dnssrv+_grpc._tcp.thanos-receive.%s.svc.cluster.local", f.namespace),
+				)
+			}
+
 			if f.config.ClusterMonitoringConfiguration.ThanosQuerierConfig.Resources != nil {
 				d.Spec.Template.Spec.Containers[i].Resources = *f.config.ClusterMonitoringConfiguration.ThanosQuerierConfig.Resources
 			}
@@ -3260,6 +5333,27 @@ func (f *Factory) ThanosQuerierDeployment(grpcTLS *v1.Secret, enableUserWorkload
 				d.Spec.Template.Spec.Containers[i].Args = append(d.Spec.Template.Spec.Containers[i].Args, fmt.Sprintf("--log.level=%s", f.config.ClusterMonitoringConfiguration.ThanosQuerierConfig.LogLevel))
 			}
 
+			args, err := thanosQuerierQueryArgs(f.config.ClusterMonitoringConfiguration.ThanosQuerierConfig)
+			if err != nil {
+				return nil, err
+			}
+			d.Spec.Template.Spec.Containers[i].Args = append(d.Spec.Template.Spec.Containers[i].Args, args...)
+
+			// The default replica label is kept so that the series of the
+			// replicas which aren't restarted yet are still deduplicated
+			// when the label is renamed.
+			_, replicaLabel, err := f.externalLabelNames()
+			if err != nil {
+				return nil, err
+			}
+			if replicaLabel != defaultReplicaExternalLabelName {
+				d.Spec.Template.Spec.Containers[i].Args = append(d.Spec.Template.Spec.Containers[i].Args, "--query.replica-label="+replicaLabel)
+			}
+
+			for j := range f.config.ClusterMonitoringConfiguration.ThanosQuerierConfig.AdditionalStores {
+				d.Spec.Template.Spec.Containers[i].Args = append(d.Spec.Template.Spec.Containers[i].Args, fmt.Sprintf("--store=127.0.0.1:%d", thanosQuerierStoreGRPCPort+j))
+			}
+
 		case "prom-label-proxy":
 			d.Spec.Template.Spec.Containers[i].Image = f.config.Images.PromLabelProxy
 
@@ -3269,6 +5363,25 @@ func (f *Factory) ThanosQuerierDeployment(grpcTLS *v1.Secret, enableUserWorkload
 		}
 	}
 
+	stores := f.config.ClusterMonitoringConfiguration.ThanosQuerierConfig.AdditionalStores
+	if err := validateThanosQuerierAdditionalStores(stores); err != nil {
+		return nil, err
+	}
+	if len(stores) > 0 {
+		var query v1.Container
+		for _, c := range d.Spec.Template.Spec.Containers {
+			if c.Name == "thanos-query" {
+				query = c
+			}
+		}
+		for i, s := range stores {
+			d.Spec.Template.Spec.Containers = append(
+				d.Spec.Template.Spec.Containers,
+				thanosQuerierStoreContainer(query, i, s, f.config.ClusterMonitoringConfiguration.ThanosQuerierConfig.LogLevel),
+			)
+		}
+	}
+
 	d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, v1.Volume{
 		Name: "secret-grpc-tls",
 		VolumeSource: v1.VolumeSource{
@@ -3310,7 +5423,7 @@ func (f *Factory) ThanosQuerierService() (*v1.Service, error) {
 }
 
 func (f *Factory) ThanosQuerierPrometheusRule() (*monv1.PrometheusRule, error) {
-	return f.NewPrometheusRule(f.assets.MustNewAssetReader(ThanosQuerierPrometheusRule))
+	return f.newShippedPrometheusRule(ThanosQuerierPrometheusRule)
 }
 
 func (f *Factory) ThanosQuerierServiceMonitor() (*monv1.ServiceMonitor, error) {
@@ -3414,12 +5527,24 @@ func (f *Factory) TelemeterClientKubeRbacProxySecret() (*v1.Secret, error) {
 // TelemeterClientDeployment generates a new Deployment for Telemeter client.
 // If the passed ConfigMap is not empty it mounts the Trusted CA Bundle as a VolumeMount to
 // /etc/pki/ca-trust/extracted/pem/ location.
-func (f *Factory) TelemeterClientDeployment(proxyCABundleCM *v1.ConfigMap) (*appsv1.Deployment, error) {
+// TelemeterClientDeployment returns the telemeter-client Deployment. The
+// additionalCABundle is the content of the additional CA bundle referenced by
+// the configuration, if any; it is hashed into the pod template so that
+// telemeter-client restarts when the CA bundle changes.
+func (f *Factory) TelemeterClientDeployment(proxyCABundleCM *v1.ConfigMap, additionalCABundle []byte) (*appsv1.Deployment, error) {
 	d, err := f.NewDeployment(f.assets.MustNewAssetReader(TelemeterClientDeployment))
 	if err != nil {
 		return nil, err
 	}
 
+	cfg := f.config.ClusterMonitoringConfiguration.TelemeterClientConfig
+	if err := cfg.Proxy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.ValidateAdditionalCABundle(); err != nil {
+		return nil, err
+	}
+
 	for i, container := range d.Spec.Template.Spec.Containers {
 		switch container.Name {
 		case "telemeter-client":
@@ -3433,6 +5558,31 @@ func (f *Factory) TelemeterClientDeployment(proxyCABundleCM *v1.ConfigMap) (*app
 			}
 
 			f.injectProxyVariables(&d.Spec.Template.Spec.Containers[i])
+			if p := cfg.Proxy; p != nil {
+				if p.HTTPProxy != "" {
+					setContainerEnvironmentVariable(&d.Spec.Template.Spec.Containers[i], "HTTP_PROXY", p.HTTPProxy)
+				}
+				if p.HTTPSProxy != "" {
+					setContainerEnvironmentVariable(&d.Spec.Template.Spec.Containers[i], "HTTPS_PROXY", p.HTTPSProxy)
+				}
+				if p.NoProxy != "" {
+					setContainerEnvironmentVariable(&d.Spec.Template.Spec.Containers[i], "NO_PROXY", p.NoProxy)
+				}
+			}
+
+			// The certificates found in SSL_CERT_DIR are trusted in
+			// addition to the trusted CA bundle.
+			if cfg.AdditionalCABundle != nil {
+				d.Spec.Template.Spec.Containers[i].Env = append(d.Spec.Template.Spec.Containers[i].Env, v1.EnvVar{
+					Name:  "SSL_CERT_DIR",
+					Value: telemeterAdditionalCABundleDir,
+				})
+				d.Spec.Template.Spec.Containers[i].VolumeMounts = append(d.Spec.Template.Spec.Containers[i].VolumeMounts, v1.VolumeMount{
+					Name:      telemeterAdditionalCABundleVolume,
+					MountPath: telemeterAdditionalCABundleDir,
+					ReadOnly:  true,
+				})
+			}
 
 			cmd := []string{}
 			// Note: matchers are read only during CMO bootstrap. This mechanism was chosen as CMO image will be reloaded during upgrades
@@ -3469,6 +5619,32 @@ func (f *Factory) TelemeterClientDeployment(proxyCABundleCM *v1.ConfigMap) (*app
 		}
 	}
 
+	if ref := cfg.AdditionalCABundle; ref != nil {
+		items := []v1.KeyToPath{{Path: telemeterAdditionalCABundleFile}}
+		volume := v1.Volume{Name: telemeterAdditionalCABundleVolume}
+		if ref.ConfigMap != nil {
+			items[0].Key = ref.ConfigMap.Key
+			volume.VolumeSource.ConfigMap = &v1.ConfigMapVolumeSource{
+				LocalObjectReference: ref.ConfigMap.LocalObjectReference,
+				Items:                items,
+			}
+		} else {
+			items[0].Key = ref.Secret.Key
+			volume.VolumeSource.Secret = &v1.SecretVolumeSource{
+				SecretName: ref.Secret.Name,
+				Items:      items,
+			}
+		}
+		d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, volume)
+
+		h := fnv.New64()
+		h.Write(additionalCABundle)
+		if d.Spec.Template.Annotations == nil {
+			d.Spec.Template.Annotations = map[string]string{}
+		}
+		d.Spec.Template.Annotations[telemeterAdditionalCABundleHashAnnotation] = strconv.FormatUint(h.Sum64(), 32)
+	}
+
 	if len(f.config.ClusterMonitoringConfiguration.TelemeterClientConfig.NodeSelector) > 0 {
 		d.Spec.Template.Spec.NodeSelector = f.config.ClusterMonitoringConfiguration.TelemeterClientConfig.NodeSelector
 	}
@@ -3574,7 +5750,7 @@ func (f *Factory) ThanosRulerClusterRole() (*rbacv1.ClusterRole, error) {
 }
 
 func (f *Factory) ThanosRulerPrometheusRule() (*monv1.PrometheusRule, error) {
-	return f.NewPrometheusRule(f.assets.MustNewAssetReader(ThanosRulerPrometheusRule))
+	return f.newShippedPrometheusRule(ThanosRulerPrometheusRule)
 }
 
 func (f *Factory) ThanosRulerAlertManagerRoleBinding() (*rbacv1.RoleBinding, error) {
@@ -3653,6 +5829,13 @@ func (f *Factory) ThanosRulerCustomResource(
 
 	t.Spec.Image = f.config.Images.Thanos
 
+	if replicas := f.config.UserWorkloadConfiguration.ThanosRuler.Replicas; replicas != nil {
+		if *replicas < 1 {
+			return nil, fmt.Errorf("%w - thanosRuler replicas must be at least 1: %d", ErrConfigValidation, *replicas)
+		}
+		t.Spec.Replicas = replicas
+	}
+
 	if f.config.UserWorkloadConfiguration.ThanosRuler.LogLevel != "" {
 		t.Spec.LogLevel = f.config.UserWorkloadConfiguration.ThanosRuler.LogLevel
 	}
@@ -3675,6 +5858,45 @@ func (f *Factory) ThanosRulerCustomResource(
 		t.Spec.Tolerations = f.config.UserWorkloadConfiguration.ThanosRuler.Tolerations
 	}
 
+	if f.config.UserWorkloadConfiguration.ThanosRuler.PriorityClassName != "" {
+		t.Spec.PriorityClassName = f.config.UserWorkloadConfiguration.ThanosRuler.PriorityClassName
+	}
+
+	excludeRuleNamespaces(t.Spec.RuleNamespaceSelector, f.config.UserWorkloadConfiguration.ExcludedRuleNamespaces)
+
+	tenantLabel, err := f.hasUserWorkloadTenantLabel()
+	if err != nil {
+		return nil, err
+	}
+	if tenantLabel {
+		t.Spec.AlertRelabelConfigs = &v1.SecretKeySelector{
+			Key: AlertRelabelConfigSecretKey,
+			LocalObjectReference: v1.LocalObjectReference{
+				Name: ThanosRulerAlertRelabelConfigSecretName,
+			},
+		}
+	}
+
+	if f.config.UserWorkloadConfiguration.DefaultRuleEvaluationScope == RuleEvaluationScopeLeafPrometheus {
+		t.Spec.RuleSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				RuleEvaluationScopeLabel: RuleEvaluationScopeThanosRuler,
+			},
+		}
+	}
+
+	if f.config.UserWorkloadConfiguration.HasRuleQuotas() {
+		excludeRulesOverQuota(t.Spec.RuleSelector)
+	}
+
+	if interval := f.config.UserWorkloadConfiguration.ThanosRuler.EvaluationInterval; interval != "" {
+		d, err := model.ParseDuration(interval)
+		if err != nil || d == 0 {
+			return nil, fmt.Errorf("%w - thanosRuler evaluationInterval must be a positive duration: %q", ErrConfigValidation, interval)
+		}
+		t.Spec.EvaluationInterval = interval
+	}
+
 	for i, container := range t.Spec.Containers {
 		switch container.Name {
 		case "thanos-ruler-proxy":
@@ -3726,6 +5948,43 @@ func (f *Factory) ThanosRulerCustomResource(
 	return t, nil
 }
 
+// excludeRuleNamespaces adds the given namespaces to the rule namespace
+// selector as a deny-list.
+func excludeRuleNamespaces(sel *metav1.LabelSelector, namespaces []string) {
+	if sel == nil || len(namespaces) == 0 {
+		return
+	}
+
+	sel.MatchExpressions = append(sel.MatchExpressions, metav1.LabelSelectorRequirement{
+		Key:      "kubernetes.io/metadata.name",
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   namespaces,
+	})
+}
+
+// excludeRulesOverQuota removes from the selector the PrometheusRules which
+// exceed the rule budget of their namespace.
+func excludeRulesOverQuota(sel *metav1.LabelSelector) {
+	excludeLabeled(sel, RuleQuotaExceededLabel)
+}
+
+// excludeMonitorsOverTargetQuota removes from the selector the ServiceMonitors
+// or PodMonitors which exceed the target quota of their namespace.
+func excludeMonitorsOverTargetQuota(sel *metav1.LabelSelector) {
+	excludeLabeled(sel, TargetQuotaExceededLabel)
+}
+
+func excludeLabeled(sel *metav1.LabelSelector, label string) {
+	if sel == nil {
+		return
+	}
+
+	sel.MatchExpressions = append(sel.MatchExpressions, metav1.LabelSelectorRequirement{
+		Key:      label,
+		Operator: metav1.LabelSelectorOpDoesNotExist,
+	})
+}
+
 func (f *Factory) mountThanosRulerAlertmanagerSecrets(t *monv1.ThanosRuler) {
 	amAuthSecrets := getAdditionalAlertmanagerSecrets(f.config.GetThanosRulerAlertmanagerConfigs())
 	if len(amAuthSecrets) == 0 {
@@ -3990,6 +6249,16 @@ func NewDeployment(manifest io.Reader) (*appsv1.Deployment, error) {
 	return &d, nil
 }
 
+func NewStatefulSet(manifest io.Reader) (*appsv1.StatefulSet, error) {
+	s := appsv1.StatefulSet{}
+	err := yaml.NewYAMLOrJSONDecoder(manifest, 100).Decode(&s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
 func NewAPIService(manifest io.Reader) (*apiregistrationv1.APIService, error) {
 	s := apiregistrationv1.APIService{}
 	err := yaml.NewYAMLOrJSONDecoder(manifest, 100).Decode(&s)
@@ -4123,6 +6392,88 @@ func addRemoteWriteConfigs(rw []monv1.RemoteWriteSpec, rwTargets ...RemoteWriteS
 	return rw
 }
 
+// clusterMetadata holds the values available to the templates of the
+// external labels and remote write headers, e.g. {{ .Region }}. Referencing
+// an unknown value fails the templating.
+type clusterMetadata struct {
+	clusterID    string
+	platformType string
+	region       string
+	baseDomain   string
+}
+
+func (f *Factory) clusterMetadata() clusterMetadata {
+	return clusterMetadata{
+		clusterID:    f.config.ClusterMonitoringConfiguration.TelemeterClientConfig.ClusterID,
+		platformType: f.infrastructure.PlatformType(),
+		region:       f.infrastructure.Region(),
+		baseDomain:   f.infrastructure.BaseDomain(),
+	}
+}
+
+func (md clusterMetadata) ClusterID() (string, error) {
+	return md.value("cluster ID", md.clusterID)
+}
+
+func (md clusterMetadata) PlatformType() (string, error) {
+	return md.value("platform type", md.platformType)
+}
+
+func (md clusterMetadata) Region() (string, error) {
+	return md.value("region", md.region)
+}
+
+func (md clusterMetadata) BaseDomain() (string, error) {
+	return md.value("base domain", md.baseDomain)
+}
+
+func (md clusterMetadata) value(name, v string) (string, error) {
+	if v == "" {
+		return "", fmt.Errorf("the %s is unknown", name)
+	}
+	return v, nil
+}
+
+// renderTemplates returns a copy of the map with the values templated from
+// the cluster metadata.
+func renderTemplates(m map[string]string, md clusterMetadata) (map[string]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	rendered := make(map[string]string, len(m))
+	for k, v := range m {
+		tmpl, err := template.New(k).Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for %q: %w", k, err)
+		}
+
+		var b strings.Builder
+		if err := tmpl.Execute(&b, md); err != nil {
+			return nil, fmt.Errorf("failed to template %q: %w", k, err)
+		}
+		rendered[k] = b.String()
+	}
+
+	return rendered, nil
+}
+
+// renderRemoteWriteHeaders returns a copy of the remote write configurations
+// with the header values templated.
+func renderRemoteWriteHeaders(rws []RemoteWriteSpec, md clusterMetadata) ([]RemoteWriteSpec, error) {
+	rendered := make([]RemoteWriteSpec, 0, len(rws))
+	for _, rw := range rws {
+		headers, err := renderTemplates(rw.Headers, md)
+		if err != nil {
+			return nil, fmt.Errorf("remote write headers: %w", err)
+		}
+		rw.Headers = headers
+		rendered = append(rendered, rw)
+	}
+
+	return rendered, nil
+}
+
 func htpasswdVolumeMount(name string) v1.VolumeMount {
 	return v1.VolumeMount{
 		Name:      name,
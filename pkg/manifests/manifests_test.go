@@ -15,25 +15,33 @@
 package manifests
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"testing"
 
 	"github.com/openshift/library-go/pkg/crypto"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 
 	v1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
 )
 
 type fakeInfrastructureReader struct {
 	highlyAvailableInfrastructure bool
 	hostedControlPlane            bool
+	platformType                  string
+	region                        string
+	baseDomain                    string
 }
 
 func (f *fakeInfrastructureReader) HighlyAvailableInfrastructure() bool {
@@ -44,6 +52,18 @@ func (f *fakeInfrastructureReader) HostedControlPlane() bool {
 	return f.hostedControlPlane
 }
 
+func (f *fakeInfrastructureReader) PlatformType() string {
+	return f.platformType
+}
+
+func (f *fakeInfrastructureReader) Region() string {
+	return f.region
+}
+
+func (f *fakeInfrastructureReader) BaseDomain() string {
+	return f.baseDomain
+}
+
 func defaultInfrastructureReader() InfrastructureReader {
 	return &fakeInfrastructureReader{highlyAvailableInfrastructure: true, hostedControlPlane: false}
 }
@@ -234,6 +254,66 @@ func TestUnconfiguredManifests(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	_, err = f.ThanosReceiveServiceAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveClusterRole()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveClusterRoleBinding()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveRemoteWriterClusterRole()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveRBACProxySecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveRBACProxyMetricsSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveGrpcTLSSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveHashringsConfigMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveRoute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveServiceMonitor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.ThanosReceiveStatefulSet(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	_, err = f.AlertmanagerService()
 	if err != nil {
 		t.Fatal(err)
@@ -264,7 +344,7 @@ func TestUnconfiguredManifests(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = f.KubeStateMetricsDeployment()
+	_, err = f.KubeStateMetricsDeployment(nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -314,7 +394,7 @@ func TestUnconfiguredManifests(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = f.NodeExporterDaemonSet()
+	_, err = f.NodeExporterDaemonSet(nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -618,7 +698,7 @@ func TestUnconfiguredManifests(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = f.GrafanaDeployment(nil)
+	_, err = f.GrafanaDeployment(nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -693,7 +773,7 @@ func TestUnconfiguredManifests(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = f.TelemeterClientDeployment(nil)
+	_, err = f.TelemeterClientDeployment(nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -747,6 +827,263 @@ func TestSharingConfig(t *testing.T) {
 	}
 }
 
+func TestExternalURL(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		config             string
+		expectedProm       string
+		expectedAM         string
+		expectedSharedAM   string
+		expectedSharedProm string
+		err                error
+	}{
+		{
+			name:               "route hosts",
+			config:             ``,
+			expectedProm:       "https://prometheus.example.com/",
+			expectedAM:         "https://alertmanager.example.com/",
+			expectedSharedProm: "https://prometheus.example.com/",
+			expectedSharedAM:   "https://alertmanager.example.com/",
+		},
+		{
+			name: "custom external URLs",
+			config: `prometheusK8s:
+  externalURL: https://monitoring.example.org/prometheus
+alertmanagerMain:
+  externalURL: https://monitoring.example.org/alertmanager
+`,
+			expectedProm:       "https://monitoring.example.org/prometheus",
+			expectedAM:         "https://monitoring.example.org/alertmanager",
+			expectedSharedProm: "https://monitoring.example.org/prometheus",
+			expectedSharedAM:   "https://monitoring.example.org/alertmanager",
+		},
+		{
+			name: "relative external URL",
+			config: `prometheusK8s:
+  externalURL: /prometheus
+`,
+			err: ErrConfigValidation,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.SetImages(map[string]string{})
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			p, err := f.PrometheusK8s("prometheus.example.com", &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected error %v, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := f.AlertmanagerMain("alertmanager.example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Spec.ExternalURL != tc.expectedProm {
+				t.Fatalf("expected Prometheus external URL %q, got %q", tc.expectedProm, p.Spec.ExternalURL)
+			}
+
+			if a.Spec.ExternalURL != tc.expectedAM {
+				t.Fatalf("expected Alertmanager external URL %q, got %q", tc.expectedAM, a.Spec.ExternalURL)
+			}
+
+			cm := f.SharingConfig(f.PrometheusExternalURL("prometheus.example.com"), f.AlertmanagerExternalURL("alertmanager.example.com"), nil, nil)
+			if cm.Data["prometheusPublicURL"] != tc.expectedSharedProm {
+				t.Fatalf("expected shared Prometheus URL %q, got %q", tc.expectedSharedProm, cm.Data["prometheusPublicURL"])
+			}
+			if cm.Data["alertmanagerPublicURL"] != tc.expectedSharedAM {
+				t.Fatalf("expected shared Alertmanager URL %q, got %q", tc.expectedSharedAM, cm.Data["alertmanagerPublicURL"])
+			}
+		})
+	}
+}
+
+func TestPrometheusK8sResourceRecommendation(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		err    error
+	}{
+		{
+			name: "valid bounds",
+			config: `prometheusK8s:
+  resourceRecommendation:
+    enabled: true
+    minMemoryRequest: 1Gi
+    maxMemoryRequest: 4Gi
+`,
+		},
+		{
+			name: "inverted bounds",
+			config: `prometheusK8s:
+  resourceRecommendation:
+    enabled: true
+    minMemoryRequest: 4Gi
+    maxMemoryRequest: 1Gi
+`,
+			err: ErrConfigValidation,
+		},
+		{
+			name: "inverted bounds but disabled",
+			config: `prometheusK8s:
+  resourceRecommendation:
+    minMemoryRequest: 4Gi
+    maxMemoryRequest: 1Gi
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.SetImages(map[string]string{})
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			_, err = f.PrometheusK8s("prometheus.example.com", &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	cm := f.PrometheusK8sResourceRecommendation(resource.MustParse("2Gi"), 1.5*(1<<30), 1e6)
+	if cm.Namespace != "openshift-monitoring" {
+		t.Fatalf("expected namespace %q, got %q", "openshift-monitoring", cm.Namespace)
+	}
+	for k, v := range map[string]string{
+		"memoryRequest":           "2Gi",
+		"observedWorkingSetBytes": "1610612736",
+		"observedHeadSeries":      "1000000",
+	} {
+		if cm.Data[k] != v {
+			t.Fatalf("expected %q for key %q, got %q", v, k, cm.Data[k])
+		}
+	}
+}
+
+func TestCollectionProfile(t *testing.T) {
+	countActions := func(sm *monv1.ServiceMonitor, action string) int {
+		var n int
+		for _, e := range sm.Spec.Endpoints {
+			for _, r := range e.MetricRelabelConfigs {
+				if r.Action == action {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	for _, tc := range []struct {
+		name   string
+		config string
+		check  func(t *testing.T, kubelet, nodeExporter, ksm *monv1.ServiceMonitor)
+		err    error
+	}{
+		{
+			name:   "default profile",
+			config: ``,
+			check: func(t *testing.T, kubelet, nodeExporter, ksm *monv1.ServiceMonitor) {
+				if countActions(kubelet, "drop") == 0 {
+					t.Fatal("expected drop relabelings for kubelet")
+				}
+				for _, sm := range []*monv1.ServiceMonitor{kubelet, nodeExporter, ksm} {
+					if n := countActions(sm, "keep"); n != 0 {
+						t.Fatalf("expected no keep relabeling for %s, got %d", sm.Name, n)
+					}
+				}
+			},
+		},
+		{
+			name: "minimal profile",
+			config: `prometheusK8s:
+  collectionProfile: minimal
+`,
+			check: func(t *testing.T, kubelet, nodeExporter, ksm *monv1.ServiceMonitor) {
+				for _, sm := range []*monv1.ServiceMonitor{kubelet, nodeExporter, ksm} {
+					if n := countActions(sm, "keep"); n != len(sm.Spec.Endpoints) {
+						t.Fatalf("expected %d keep relabelings for %s, got %d", len(sm.Spec.Endpoints), sm.Name, n)
+					}
+				}
+
+				e := nodeExporter.Spec.Endpoints[0]
+				keep := e.MetricRelabelConfigs[len(e.MetricRelabelConfigs)-1]
+				re := regexp.MustCompile("^(?:" + keep.Regex + ")$")
+				for _, metric := range []string{"node_cpu_seconds_total", "node_time_seconds"} {
+					if !re.MatchString(metric) {
+						t.Fatalf("expected %s to be kept, regex: %s", metric, keep.Regex)
+					}
+				}
+				if re.MatchString("node_scrape_collector_duration_seconds") {
+					t.Fatalf("expected node_scrape_collector_duration_seconds to be dropped, regex: %s", keep.Regex)
+				}
+			},
+		},
+		{
+			name: "full profile",
+			config: `prometheusK8s:
+  collectionProfile: full
+`,
+			check: func(t *testing.T, kubelet, nodeExporter, ksm *monv1.ServiceMonitor) {
+				if n := countActions(kubelet, "drop"); n != 0 {
+					t.Fatalf("expected no drop relabeling for kubelet, got %d", n)
+				}
+			},
+		},
+		{
+			name: "invalid profile",
+			config: `prometheusK8s:
+  collectionProfile: tiny
+`,
+			err: ErrConfigValidation,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+			kubelet, err := f.ControlPlaneKubeletServiceMonitor()
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected error %v, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nodeExporter, err := f.NodeExporterServiceMonitor()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ksm, err := f.KubeStateMetricsServiceMonitor()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tc.check(t, kubelet, nodeExporter, ksm)
+		})
+	}
+}
+
 func TestPrometheusOperatorConfiguration(t *testing.T) {
 	c, err := NewConfigFromString(`prometheusOperator:
   nodeSelector:
@@ -866,6 +1203,7 @@ func TestPrometheusK8sRemoteWrite(t *testing.T) {
 		name                    string
 		config                  func() *Config
 		expectedRemoteWriteURLs []string
+		check                   func(*testing.T, []monv1.RemoteWriteSpec)
 	}{
 		{
 			name: "default config",
@@ -938,6 +1276,83 @@ func TestPrometheusK8sRemoteWrite(t *testing.T) {
 				"https://infogw.api.openshift.com/metrics/v1/receive",
 			},
 		},
+		{
+			name: "remote write telemetry enabled by the configuration",
+
+			config: func() *Config {
+				c, err := NewConfigFromString(`telemeterClient:
+  remoteWrite: true
+  clusterID: "123"
+  token: secret
+`)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				c.SetRemoteWrite(false)
+
+				return c
+			},
+
+			expectedRemoteWriteURLs: []string{
+				"https://infogw.api.openshift.com/metrics/v1/receive",
+			},
+		},
+		{
+			name: "remote write telemetry disabled by the configuration",
+
+			config: func() *Config {
+				c, err := NewConfigFromString(`telemeterClient:
+  remoteWrite: false
+  clusterID: "123"
+  token: secret
+`)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				c.SetRemoteWrite(true)
+
+				return c
+			},
+
+			expectedRemoteWriteURLs: nil,
+		},
+		{
+			name: "remote write telemetry with proxy and additional CA bundle",
+
+			config: func() *Config {
+				c, err := NewConfigFromString(`telemeterClient:
+  proxy:
+    httpsProxy: https://proxy.example.com:3128
+  additionalCABundle:
+    configMap:
+      name: corporate-ca
+      key: ca.crt
+`)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				c.SetRemoteWrite(true)
+				c.ClusterMonitoringConfiguration.TelemeterClientConfig.ClusterID = "123"
+				c.ClusterMonitoringConfiguration.TelemeterClientConfig.Token = "secret"
+
+				return c
+			},
+
+			expectedRemoteWriteURLs: []string{
+				"https://infogw.api.openshift.com/metrics/v1/receive",
+			},
+			check: func(t *testing.T, rws []monv1.RemoteWriteSpec) {
+				if rws[0].ProxyURL != "https://proxy.example.com:3128" {
+					t.Errorf("expected proxy URL %q, got %q", "https://proxy.example.com:3128", rws[0].ProxyURL)
+				}
+				if rws[0].TLSConfig == nil || rws[0].TLSConfig.CA.ConfigMap == nil || rws[0].TLSConfig.CA.ConfigMap.Name != "corporate-ca" {
+					t.Errorf("expected the corporate-ca ConfigMap as CA, got %v", rws[0].TLSConfig)
+				}
+			},
+		},
 		{
 			name: "remote write telemetry and custom remote write",
 
@@ -1007,6 +1422,10 @@ func TestPrometheusK8sRemoteWrite(t *testing.T) {
 			if !reflect.DeepEqual(got, tc.expectedRemoteWriteURLs) {
 				t.Errorf("want remote write URLs %v, got %v", tc.expectedRemoteWriteURLs, got)
 			}
+
+			if tc.check != nil {
+				tc.check(t, p.Spec.RemoteWrite)
+			}
 		})
 	}
 }
@@ -1175,14 +1594,64 @@ ingress:
 	}
 }
 
-func TestPrometheusK8sAdditionalAlertManagerConfigsSecret(t *testing.T) {
-	testCases := []struct {
-		name           string
-		config         string
-		expected       string
-		mountedSecrets []string
-	}{
-		{
+func TestPrometheusK8sVolumeSources(t *testing.T) {
+	render := func(config string) (*monv1.Prometheus, error) {
+		c, err := NewConfigFromString(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+		return f.PrometheusK8s("prometheus-k8s.openshift-monitoring.svc", &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil)
+	}
+
+	defaults, err := render("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defaults.Spec.Secrets) == 0 {
+		t.Fatal("expected default secrets")
+	}
+
+	p, err := render(fmt.Sprintf(`prometheusK8s:
+  secrets:
+  - scrape-credentials
+  - %s
+  configMaps:
+  - scrape-ca
+`, defaults.Spec.Secrets[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Secrets already mounted by the operator aren't duplicated.
+	expectedSecrets := append(append([]string{}, defaults.Spec.Secrets...), "scrape-credentials")
+	if !reflect.DeepEqual(p.Spec.Secrets, expectedSecrets) {
+		t.Fatalf("expected secrets %v, got %v", expectedSecrets, p.Spec.Secrets)
+	}
+	expectedConfigMaps := append(append([]string{}, defaults.Spec.ConfigMaps...), "scrape-ca")
+	if !reflect.DeepEqual(p.Spec.ConfigMaps, expectedConfigMaps) {
+		t.Fatalf("expected configmaps %v, got %v", expectedConfigMaps, p.Spec.ConfigMaps)
+	}
+
+	for _, tc := range []string{
+		"prometheusK8s:\n  secrets:\n  - Invalid_Name\n",
+		"prometheusK8s:\n  configMaps:\n  - foo/bar\n",
+	} {
+		if _, err := render(tc); !errors.Is(err, ErrConfigValidation) {
+			t.Fatalf("expected config validation error for %q, got %v", tc, err)
+		}
+	}
+}
+
+func TestPrometheusK8sAdditionalAlertManagerConfigsSecret(t *testing.T) {
+	testCases := []struct {
+		name           string
+		config         string
+		expected       string
+		mountedSecrets []string
+	}{
+		{
 			name:           "empty config",
 			config:         "",
 			expected:       "[]\n",
@@ -1837,6 +2306,175 @@ k8sPrometheusAdapter:
 	}
 }
 
+func TestK8sPrometheusAdapterQueryBackend(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+
+		expectedURL string
+		err         bool
+	}{
+		{
+			name:        "default",
+			expectedURL: "https://thanos-querier.openshift-monitoring.svc:9091",
+		},
+		{
+			name:        "prometheus-k8s",
+			config:      "k8sPrometheusAdapter:\n  queryBackend: prometheus-k8s\n",
+			expectedURL: "https://prometheus-k8s.openshift-monitoring.svc:9091",
+		},
+		{
+			name:   "invalid",
+			config: "k8sPrometheusAdapter:\n  queryBackend: grafana\n",
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			d, err := f.PrometheusAdapterDeployment("foo", map[string]string{
+				"requestheader-allowed-names":        "",
+				"requestheader-extra-headers-prefix": "",
+				"requestheader-group-headers":        "",
+				"requestheader-username-headers":     "",
+			})
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			url := getContainerArgValue(d.Spec.Template.Spec.Containers, "--prometheus-url=", d.Spec.Template.Spec.Containers[0].Name)
+			if url != "--prometheus-url="+tc.expectedURL {
+				t.Fatalf("expected %q, got %q", "--prometheus-url="+tc.expectedURL, url)
+			}
+		})
+	}
+}
+
+func TestK8sPrometheusAdapterMetricsRules(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+
+		expectedKeys []string
+		err          bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "custom and external rules",
+			config: `k8sPrometheusAdapter:
+  customMetricsRules:
+  - seriesQuery: 'http_requests_total{namespace!="",pod!=""}'
+    resources:
+      overrides:
+        namespace: {resource: namespace}
+        pod: {resource: pod}
+    name:
+      matches: "^(.*)_total$"
+      as: "${1}_per_second"
+    metricsQuery: 'sum(rate(<<.Series>>{<<.LabelMatchers>>}[2m])) by (<<.GroupBy>>)'
+  externalMetricsRules:
+  - seriesQuery: 'queue_depth'
+    resources:
+      namespaced: false
+    metricsQuery: 'max(<<.Series>>{<<.LabelMatchers>>})'
+`,
+			expectedKeys: []string{"rules", "externalRules"},
+		},
+		{
+			name: "missing metrics query",
+			config: `k8sPrometheusAdapter:
+  customMetricsRules:
+  - seriesQuery: 'http_requests_total'
+`,
+			err: true,
+		},
+		{
+			name: "invalid name regexp",
+			config: `k8sPrometheusAdapter:
+  externalMetricsRules:
+  - seriesQuery: 'queue_depth'
+    name:
+      matches: "(.*"
+    metricsQuery: 'max(<<.Series>>{<<.LabelMatchers>>})'
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			cm, err := f.PrometheusAdapterConfigMap()
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var adapterConfig map[string]interface{}
+			if err := yaml.Unmarshal([]byte(cm.Data["config.yaml"]), &adapterConfig); err != nil {
+				t.Fatal(err)
+			}
+			if _, found := adapterConfig["resourceRules"]; !found {
+				t.Fatal("expected resourceRules to be preserved")
+			}
+			for _, k := range []string{"rules", "externalRules"} {
+				_, found := adapterConfig[k]
+				expected := false
+				for _, ek := range tc.expectedKeys {
+					expected = expected || ek == k
+				}
+				if found != expected {
+					t.Fatalf("expected %q to be present: %v, got %v", k, expected, found)
+				}
+			}
+
+			d, err := f.PrometheusAdapterDeployment("foo", map[string]string{
+				"requestheader-allowed-names":        "",
+				"requestheader-extra-headers-prefix": "",
+				"requestheader-group-headers":        "",
+				"requestheader-username-headers":     "",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, found := d.Spec.Template.Annotations[prometheusAdapterRulesHashAnnotation]
+			if found != (len(tc.expectedKeys) > 0) {
+				t.Fatalf("expected rules hash annotation: %v, got %v", len(tc.expectedKeys) > 0, found)
+			}
+		})
+	}
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	api, err := f.PrometheusAdapterExternalMetricsAPIService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.Name != "v1beta1.external.metrics.k8s.io" || api.Spec.Group != "external.metrics.k8s.io" {
+		t.Fatalf("unexpected APIService %q for group %q", api.Name, api.Spec.Group)
+	}
+}
+
 func TestAlertmanagerMainStartupProbe(t *testing.T) {
 	for _, tc := range []struct {
 		name                string
@@ -1910,6 +2548,12 @@ func TestAlertmanagerMainStartupProbe(t *testing.T) {
 func TestAlertmanagerMainConfiguration(t *testing.T) {
 	c, err := NewConfigFromString(`alertmanagerMain:
   logLevel: debug
+  retention: 240h
+  secrets:
+  - pagerduty-token
+  - alertmanager-main-tls
+  configMaps:
+  - webhook-ca
   baseImage: quay.io/test/alertmanager
   nodeSelector:
     type: worker
@@ -1951,6 +2595,25 @@ ingress:
 		t.Fatalf("Alertmanager logLevel is not configured correctly, want: 'debug', got: '%s'", a.Spec.LogLevel)
 	}
 
+	if a.Spec.Retention != "240h" {
+		t.Fatalf("Alertmanager retention is not configured correctly, want: '240h', got: '%s'", a.Spec.Retention)
+	}
+
+	expectedSecrets := []string{
+		"alertmanager-main-tls",
+		"alertmanager-main-proxy",
+		"alertmanager-kube-rbac-proxy",
+		"alertmanager-kube-rbac-proxy-metric",
+		"pagerduty-token",
+	}
+	if !reflect.DeepEqual(a.Spec.Secrets, expectedSecrets) {
+		t.Fatalf("Alertmanager secrets are not configured correctly, want: %v, got: %v", expectedSecrets, a.Spec.Secrets)
+	}
+
+	if !reflect.DeepEqual(a.Spec.ConfigMaps, []string{"webhook-ca"}) {
+		t.Fatalf("Alertmanager configMaps are not configured correctly, want: [webhook-ca], got: %v", a.Spec.ConfigMaps)
+	}
+
 	if *a.Spec.Image != "docker.io/openshift/origin-prometheus-alertmanager:latest" {
 		t.Fatal("Alertmanager image is not configured correctly")
 	}
@@ -1993,6 +2656,13 @@ ingress:
 		t.Fatal("Alertmanager volumeClaimTemplate not configured correctly, expected 10Gi storage request, but found", storageRequestPtr.String())
 	}
 
+	if len(a.Spec.TopologySpreadConstraints) != 1 {
+		t.Fatalf("expected 1 topology spread constraint, got %d", len(a.Spec.TopologySpreadConstraints))
+	}
+	if tsc := a.Spec.TopologySpreadConstraints[0]; tsc.TopologyKey != "topology.kubernetes.io/zone" || tsc.WhenUnsatisfiable != v1.ScheduleAnyway {
+		t.Fatalf("Alertmanager zone topology spread constraint not configured correctly: %v", tsc)
+	}
+
 	kubeRbacProxyTLSCipherSuitesArg := ""
 	kubeRbacProxyMinTLSVersionArg := ""
 	for _, container := range a.Spec.Containers {
@@ -2026,156 +2696,662 @@ ingress:
 	}
 }
 
-func TestNodeExporter(t *testing.T) {
-	c, err := NewConfigFromString(``)
-	if err != nil {
-		t.Fatal(err)
-	}
-	c.SetImages(map[string]string{
-		"node-exporter":   "docker.io/openshift/origin-prometheus-node-exporter:latest",
-		"kube-rbac-proxy": "docker.io/openshift/origin-kube-rbac-proxy:latest",
-	})
+func TestAlertmanagerMainRetention(t *testing.T) {
+	for _, tc := range []struct {
+		retention string
+		err       bool
+	}{
+		{retention: ""},
+		{retention: "120h"},
+		{retention: "30m"},
+		{retention: "10d", err: true},
+		{retention: "1h30m", err: true},
+		{retention: "foo", err: true},
+	} {
+		t.Run(tc.retention, func(t *testing.T) {
+			c, err := NewConfigFromString(fmt.Sprintf("alertmanagerMain:\n  retention: %q\n", tc.retention))
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			a, err := f.AlertmanagerMain("", &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	ds, err := f.NodeExporterDaemonSet()
-	if err != nil {
-		t.Fatal(err)
+			if a.Spec.Retention != tc.retention {
+				t.Fatalf("expected retention %q, got %q", tc.retention, a.Spec.Retention)
+			}
+		})
 	}
+}
 
-	kubeRbacProxyTLSCipherSuitesArg := ""
-	kubeRbacProxyMinTLSVersionArg := ""
+func TestAlertmanagerRouteDefaults(t *testing.T) {
+	userConfig := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "alertmanager-main", Namespace: "openshift-monitoring"},
+		Data: map[string][]byte{
+			"alertmanager.yaml": []byte(`global:
+  resolve_timeout: 5m
+route:
+  receiver: default
+  group_wait: 10s
+  routes:
+  - receiver: watchdog
+    match:
+      alertname: Watchdog
+receivers:
+- name: default
+- name: watchdog
+`),
+			"custom.tmpl": []byte("{{ define \"foo\" }}bar{{ end }}"),
+		},
+	}
 
-	for _, container := range ds.Spec.Template.Spec.Containers {
-		switch container.Name {
-		case "node-exporter":
-			if container.Image != "docker.io/openshift/origin-prometheus-node-exporter:latest" {
-				t.Fatalf("image for node-exporter daemonset is wrong: %s", container.Name)
+	for _, tc := range []struct {
+		name   string
+		config string
+
+		expectedRoute map[string]interface{}
+		err           bool
+	}{
+		{
+			name: "no defaults",
+		},
+		{
+			name: "defaults",
+			config: `alertmanagerMain:
+  routeDefaults:
+    groupBy: [namespace, alertname]
+    groupWait: 1m
+    groupInterval: 10m
+    repeatInterval: 24h
+`,
+			expectedRoute: map[string]interface{}{
+				"receiver":        "default",
+				"group_by":        []interface{}{"namespace", "alertname"},
+				"group_wait":      "10s",
+				"group_interval":  "10m",
+				"repeat_interval": "24h",
+			},
+		},
+		{
+			name: "invalid duration",
+			config: `alertmanagerMain:
+  routeDefaults:
+    repeatInterval: 1 day
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
 			}
-		case "kube-rbac-proxy":
-			if container.Image != "docker.io/openshift/origin-kube-rbac-proxy:latest" {
-				t.Fatalf("image for kube-rbac-proxy in node-exporter daemonset is wrong: %s", container.Name)
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			s, err := f.AlertmanagerRenderedConfig(userConfig)
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
 			}
-			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(ds.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
-			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(ds.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
-		}
-	}
 
-	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyTLSCipherSuitesFlag,
-		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
+			a, err := f.AlertmanagerMain("", &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
-		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
-	}
+			if tc.expectedRoute == nil {
+				if s != nil {
+					t.Fatalf("expected no rendered Secret, got %v", s)
+				}
+				if a.Spec.ConfigSecret != "" {
+					t.Fatalf("expected no config secret, got %q", a.Spec.ConfigSecret)
+				}
+				return
+			}
 
-	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
-	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
-		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
-	}
+			if a.Spec.ConfigSecret != s.Name {
+				t.Fatalf("expected config secret %q, got %q", s.Name, a.Spec.ConfigSecret)
+			}
+			if string(s.Data["custom.tmpl"]) != string(userConfig.Data["custom.tmpl"]) {
+				t.Fatalf("expected template to be kept, got %q", s.Data["custom.tmpl"])
+			}
 
-	ds2, err := f.NodeExporterDaemonSet()
-	if err != nil {
-		t.Fatal(err)
-	}
+			var rendered struct {
+				Global    map[string]interface{}   `json:"global"`
+				Route     map[string]interface{}   `json:"route"`
+				Receivers []map[string]interface{} `json:"receivers"`
+			}
+			if err := yaml.Unmarshal(s.Data["alertmanager.yaml"], &rendered); err != nil {
+				t.Fatal(err)
+			}
 
-	if !reflect.DeepEqual(ds, ds2) {
-		t.Fatal("expected NodeExporterDaemonSet to be an idempotent function")
+			if rendered.Global["resolve_timeout"] != "5m" || len(rendered.Receivers) != 2 {
+				t.Fatalf("expected the rest of the configuration to be kept, got %v", rendered)
+			}
+			delete(rendered.Route, "routes")
+			if !reflect.DeepEqual(rendered.Route, tc.expectedRoute) {
+				t.Fatalf("expected route %v, got %v", tc.expectedRoute, rendered.Route)
+			}
+		})
 	}
 }
 
-func TestKubeStateMetrics(t *testing.T) {
-	c, err := NewConfigFromString(``)
-	if err != nil {
-		t.Fatal(err)
+func TestAlertmanagerUserAlertsThrottling(t *testing.T) {
+	userConfig := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "alertmanager-main", Namespace: "openshift-monitoring"},
+		Data: map[string][]byte{
+			"alertmanager.yaml": []byte(`route:
+  receiver: default
+  group_by: [alertname]
+  routes:
+  - receiver: watchdog
+    match:
+      alertname: Watchdog
+  - receiver: team-a
+    group_by: [alertname, job]
+    group_interval: 1m
+    matchers:
+    - namespace="team-a"
+    routes:
+    - receiver: team-a-critical
+      repeat_interval: 5m
+      matchers:
+      - severity="critical"
+receivers:
+- name: default
+- name: watchdog
+- name: team-a
+- name: team-a-critical
+`),
+		},
 	}
-	c.SetImages(map[string]string{
-		"kube-state-metrics": "docker.io/openshift/origin-kube-state-metrics:latest",
-		"kube-rbac-proxy":    "docker.io/openshift/origin-kube-rbac-proxy:latest",
-	})
 
-	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-
-	d, err := f.KubeStateMetricsDeployment()
-	if err != nil {
-		t.Fatal(err)
-	}
+	for _, tc := range []struct {
+		name   string
+		config string
 
-	kubeRbacProxyTLSCipherSuitesArg := ""
-	kubeRbacProxyMinTLSVersionArg := ""
-	for _, container := range d.Spec.Template.Spec.Containers {
-		switch container.Name {
-		case "kube-state-metrics":
-			if container.Image != "docker.io/openshift/origin-kube-state-metrics:latest" {
-				t.Fatal("kube-state-metrics image incorrectly configured")
+		expectedRoutes string
+		err            bool
+	}{
+		{
+			name: "throttling",
+			config: `alertmanagerMain:
+  userAlertsThrottling:
+    groupInterval: 15m
+    repeatInterval: 12h
+`,
+			expectedRoutes: `- matchers:
+  - openshift_io_alert_source!="platform"
+  group_by: [namespace]
+  group_interval: 15m
+  repeat_interval: 12h
+  routes:
+  - receiver: watchdog
+    match:
+      alertname: Watchdog
+  - receiver: team-a
+    matchers:
+    - namespace="team-a"
+    routes:
+    - receiver: team-a-critical
+      matchers:
+      - severity="critical"
+- receiver: watchdog
+  match:
+    alertname: Watchdog
+- receiver: team-a
+  group_by: [alertname, job]
+  group_interval: 1m
+  matchers:
+  - namespace="team-a"
+  routes:
+  - receiver: team-a-critical
+    repeat_interval: 5m
+    matchers:
+    - severity="critical"
+`,
+		},
+		{
+			name: "invalid duration",
+			config: `alertmanagerMain:
+  userAlertsThrottling:
+    groupWait: soon
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
 			}
-		case "kube-rbac-proxy-self", "kube-rbac-proxy-main":
-			if container.Image != "docker.io/openshift/origin-kube-rbac-proxy:latest" {
-				t.Fatalf("%s image incorrectly configured", container.Name)
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			s, err := f.AlertmanagerRenderedConfig(userConfig)
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
 			}
 
-			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
-			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
-		}
-	}
+			a, err := f.AlertmanagerMain("", &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Spec.ConfigSecret != s.Name {
+				t.Fatalf("expected config secret %q, got %q", s.Name, a.Spec.ConfigSecret)
+			}
 
-	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyTLSCipherSuitesFlag,
-		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
+			var rendered struct {
+				Route struct {
+					Receiver string        `json:"receiver"`
+					GroupBy  []string      `json:"group_by"`
+					Routes   []interface{} `json:"routes"`
+				} `json:"route"`
+			}
+			if err := yaml.Unmarshal(s.Data["alertmanager.yaml"], &rendered); err != nil {
+				t.Fatal(err)
+			}
 
-	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
-		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
+			var expected []interface{}
+			if err := yaml.Unmarshal([]byte(tc.expectedRoutes), &expected); err != nil {
+				t.Fatal(err)
+			}
+
+			if rendered.Route.Receiver != "default" || !reflect.DeepEqual(rendered.Route.GroupBy, []string{"alertname"}) {
+				t.Fatalf("expected the root route to be kept, got %v", rendered.Route)
+			}
+			if !reflect.DeepEqual(rendered.Route.Routes, expected) {
+				t.Fatalf("expected routes %v, got %v", expected, rendered.Route.Routes)
+			}
+		})
 	}
+}
 
-	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
-	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
-		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
+func TestAlertmanagerReceiversTLS(t *testing.T) {
+	userConfig := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "alertmanager-main", Namespace: "openshift-monitoring"},
+		Data: map[string][]byte{
+			"alertmanager.yaml": []byte(`route:
+  receiver: default
+receivers:
+- name: default
+- name: team-a
+  webhook_configs:
+  - url: https://team-a.example.com
+    http_config:
+      bearer_token: secret
+      tls_config:
+        insecure_skip_verify: true
+  email_configs:
+  - to: team-a@example.com
+- name: team-b
+  slack_configs:
+  - api_url: https://slack.example.com
+`),
+		},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		config string
+
+		expectedReceivers string
+		expectedData      map[string]string
+		err               bool
+	}{
+		{
+			name: "receiver TLS",
+			config: `alertmanagerMain:
+  receiversTLS:
+  - receiver: team-a
+    namespace: team-a
+    tlsConfig:
+      ca: {name: team-a-tls, key: ca.crt}
+      cert: {name: team-a-tls, key: tls.crt}
+      key: {name: team-a-tls, key: tls.key}
+      serverName: team-a.example.com
+  - receiver: undefined
+    tlsConfig:
+      insecureSkipVerify: true
+`,
+			expectedReceivers: `- name: default
+- name: team-a
+  webhook_configs:
+  - url: https://team-a.example.com
+    http_config:
+      bearer_token: secret
+      tls_config:
+        ca_file: /etc/alertmanager/secrets/alertmanager-main-receivers-tls/receiver-0-ca.crt
+        cert_file: /etc/alertmanager/secrets/alertmanager-main-receivers-tls/receiver-0-tls.crt
+        key_file: /etc/alertmanager/secrets/alertmanager-main-receivers-tls/receiver-0-tls.key
+        server_name: team-a.example.com
+        insecure_skip_verify: false
+  email_configs:
+  - to: team-a@example.com
+    tls_config:
+      ca_file: /etc/alertmanager/secrets/alertmanager-main-receivers-tls/receiver-0-ca.crt
+      cert_file: /etc/alertmanager/secrets/alertmanager-main-receivers-tls/receiver-0-tls.crt
+      key_file: /etc/alertmanager/secrets/alertmanager-main-receivers-tls/receiver-0-tls.key
+      server_name: team-a.example.com
+      insecure_skip_verify: false
+- name: team-b
+  slack_configs:
+  - api_url: https://slack.example.com
+`,
+			expectedData: map[string]string{
+				"receiver-0-ca.crt":  "team-a/team-a-tls/ca.crt",
+				"receiver-0-tls.crt": "team-a/team-a-tls/tls.crt",
+				"receiver-0-tls.key": "team-a/team-a-tls/tls.key",
+			},
+		},
+		{
+			name: "default namespace",
+			config: `alertmanagerMain:
+  receiversTLS:
+  - receiver: team-b
+    tlsConfig:
+      ca: {name: custom-ca, key: ca.crt}
+`,
+			expectedReceivers: `- name: default
+- name: team-a
+  webhook_configs:
+  - url: https://team-a.example.com
+    http_config:
+      bearer_token: secret
+      tls_config:
+        insecure_skip_verify: true
+  email_configs:
+  - to: team-a@example.com
+- name: team-b
+  slack_configs:
+  - api_url: https://slack.example.com
+    http_config:
+      tls_config:
+        ca_file: /etc/alertmanager/secrets/alertmanager-main-receivers-tls/receiver-0-ca.crt
+        insecure_skip_verify: false
+`,
+			expectedData: map[string]string{
+				"receiver-0-ca.crt": "openshift-monitoring/custom-ca/ca.crt",
+			},
+		},
+		{
+			name: "missing receiver name",
+			config: `alertmanagerMain:
+  receiversTLS:
+  - tlsConfig:
+      insecureSkipVerify: true
+`,
+			err: true,
+		},
+		{
+			name: "duplicate receiver",
+			config: `alertmanagerMain:
+  receiversTLS:
+  - receiver: team-a
+  - receiver: team-a
+`,
+			err: true,
+		},
+		{
+			name: "cert without key",
+			config: `alertmanagerMain:
+  receiversTLS:
+  - receiver: team-a
+    tlsConfig:
+      cert: {name: team-a-tls, key: tls.crt}
+`,
+			err: true,
+		},
+		{
+			name: "invalid namespace",
+			config: `alertmanagerMain:
+  receiversTLS:
+  - receiver: team-a
+    namespace: Team_A
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			s, err := f.AlertmanagerRenderedConfig(userConfig)
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rendered struct {
+				Receivers []interface{} `json:"receivers"`
+			}
+			if err := yaml.Unmarshal(s.Data["alertmanager.yaml"], &rendered); err != nil {
+				t.Fatal(err)
+			}
+
+			var expected []interface{}
+			if err := yaml.Unmarshal([]byte(tc.expectedReceivers), &expected); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(rendered.Receivers, expected) {
+				t.Fatalf("expected receivers %v, got %v", expected, rendered.Receivers)
+			}
+
+			tlsSecret, err := f.AlertmanagerReceiversTLSSecret(func(namespace, name string) (*v1.Secret, error) {
+				return &v1.Secret{
+					Data: map[string][]byte{
+						"ca.crt":  []byte(namespace + "/" + name + "/ca.crt"),
+						"tls.crt": []byte(namespace + "/" + name + "/tls.crt"),
+						"tls.key": []byte(namespace + "/" + name + "/tls.key"),
+					},
+				}, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tlsSecret.Namespace != "openshift-monitoring" || tlsSecret.Name != AlertmanagerReceiversTLSSecret {
+				t.Fatalf("expected Secret openshift-monitoring/%s, got %s/%s", AlertmanagerReceiversTLSSecret, tlsSecret.Namespace, tlsSecret.Name)
+			}
+
+			data := make(map[string]string, len(tlsSecret.Data))
+			for k, v := range tlsSecret.Data {
+				data[k] = string(v)
+			}
+			if !reflect.DeepEqual(data, tc.expectedData) {
+				t.Fatalf("expected data %v, got %v", tc.expectedData, data)
+			}
+
+			a, err := f.AlertmanagerMain("", &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var found bool
+			for _, name := range a.Spec.Secrets {
+				if name == AlertmanagerReceiversTLSSecret {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("expected %q in the Alertmanager secrets, got %v", AlertmanagerReceiversTLSSecret, a.Spec.Secrets)
+			}
+		})
 	}
+}
 
-	d2, err := f.KubeStateMetricsDeployment()
+func TestAlertmanagerReceiversTLSMissingKey(t *testing.T) {
+	c, err := NewConfigFromString(`alertmanagerMain:
+  receiversTLS:
+  - receiver: team-a
+    tlsConfig:
+      ca: {name: team-a-tls, key: ca.crt}
+`)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if !reflect.DeepEqual(d, d2) {
-		t.Fatal("expected KubeStateMetricsDeployment to be an idempotent function")
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	_, err = f.AlertmanagerReceiversTLSSecret(func(namespace, name string) (*v1.Secret, error) {
+		return &v1.Secret{Data: map[string][]byte{}}, nil
+	})
+	if err == nil {
+		t.Fatal("expected error, got none")
 	}
 }
 
-func TestOpenShiftStateMetrics(t *testing.T) {
+func TestAlertmanagerMainProxy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected map[string]string
+	}{
+		{
+			name:   "default",
+			config: "",
+		},
+		{
+			name: "not opted in",
+			config: `http:
+  httpProxy: http://proxy:3128
+`,
+		},
+		{
+			name: "no proxy",
+			config: `alertmanagerMain:
+  useClusterProxy: true
+`,
+		},
+		{
+			name: "cluster proxy",
+			config: `http:
+  httpProxy: http://proxy:3128
+  httpsProxy: https://proxy:3128
+  noProxy: .svc,.cluster.local
+alertmanagerMain:
+  useClusterProxy: true
+`,
+			expected: map[string]string{
+				"HTTP_PROXY":  "http://proxy:3128",
+				"HTTPS_PROXY": "https://proxy:3128",
+				"NO_PROXY":    ".svc,.cluster.local",
+			},
+		},
+		{
+			name: "opt-out",
+			config: `http:
+  httpProxy: http://proxy:3128
+alertmanagerMain:
+  useClusterProxy: false
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), c, NewAssets(assetsPath), &APIServerConfig{})
+			a, err := f.AlertmanagerMain("", &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			env := map[string]string{}
+			for _, container := range a.Spec.Containers {
+				if container.Name != "alertmanager" {
+					continue
+				}
+				for _, e := range container.Env {
+					env[e.Name] = e.Value
+				}
+			}
+
+			if len(env) != len(tc.expected) || (len(env) > 0 && !reflect.DeepEqual(env, tc.expected)) {
+				t.Fatalf("expected alertmanager container environment %v, got %v", tc.expected, env)
+			}
+		})
+	}
+}
+
+func TestAlertmanagerMainInvalidVolumeSources(t *testing.T) {
+	for _, tc := range []string{
+		"alertmanagerMain:\n  secrets:\n  - Invalid_Name\n",
+		"alertmanagerMain:\n  configMaps:\n  - foo/bar\n",
+	} {
+		c, err := NewConfigFromString(tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+		_, err = f.AlertmanagerMain("", &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+		if !errors.Is(err, ErrConfigValidation) {
+			t.Fatalf("expected config validation error for %q, got %v", tc, err)
+		}
+	}
+}
+
+func TestNodeExporter(t *testing.T) {
 	c, err := NewConfigFromString(``)
 	if err != nil {
 		t.Fatal(err)
 	}
 	c.SetImages(map[string]string{
-		"openshift-state-metrics": "docker.io/openshift/origin-openshift-state-metrics:latest",
-		"kube-rbac-proxy":         "docker.io/openshift/origin-kube-rbac-proxy:latest",
+		"node-exporter":   "docker.io/openshift/origin-prometheus-node-exporter:latest",
+		"kube-rbac-proxy": "docker.io/openshift/origin-kube-rbac-proxy:latest",
 	})
 
 	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
 
-	d, err := f.OpenShiftStateMetricsDeployment()
+	ds, err := f.NodeExporterDaemonSet(nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	kubeRbacProxyTLSCipherSuitesArg := ""
 	kubeRbacProxyMinTLSVersionArg := ""
-	for _, container := range d.Spec.Template.Spec.Containers {
+
+	for _, container := range ds.Spec.Template.Spec.Containers {
 		switch container.Name {
-		case "openshift-state-metrics":
-			if container.Image != "docker.io/openshift/origin-openshift-state-metrics:latest" {
-				t.Fatal("openshift-state-metrics image incorrectly configured")
+		case "node-exporter":
+			if container.Image != "docker.io/openshift/origin-prometheus-node-exporter:latest" {
+				t.Fatalf("image for node-exporter daemonset is wrong: %s", container.Name)
 			}
-
-		case "kube-rbac-proxy-self", "kube-rbac-proxy-main":
+		case "kube-rbac-proxy":
 			if container.Image != "docker.io/openshift/origin-kube-rbac-proxy:latest" {
-				t.Fatal("kube-rbac-proxy image incorrectly configured")
+				t.Fatalf("image for kube-rbac-proxy in node-exporter daemonset is wrong: %s", container.Name)
 			}
-			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
-			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
+			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(ds.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
+			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(ds.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
 		}
 	}
 
@@ -2193,230 +3369,185 @@ func TestOpenShiftStateMetrics(t *testing.T) {
 		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
 	}
 
-	d2, err := f.OpenShiftStateMetricsDeployment()
+	ds2, err := f.NodeExporterDaemonSet(nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if !reflect.DeepEqual(d, d2) {
-		t.Fatal("expected OpenShiftStateMetricsDeployment to be an idempotent function")
+	if !reflect.DeepEqual(ds, ds2) {
+		t.Fatal("expected NodeExporterDaemonSet to be an idempotent function")
 	}
-
 }
 
-func TestPrometheusK8sControlPlaneRulesFiltered(t *testing.T) {
-	tests := []struct {
-		name           string
-		infrastructure InfrastructureReader
-		verify         func(bool)
+func TestNodeExporterMaxProcsAndResources(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+
+		expectedArg    string
+		expectedMemory string
+		err            bool
 	}{
 		{
-			name:           "default config",
-			infrastructure: defaultInfrastructureReader(),
-			verify: func(api bool) {
-				if !api {
-					t.Fatal("did not get all expected kubernetes control plane rules")
-				}
-			},
+			name:           "default",
+			expectedMemory: "32Mi",
 		},
 		{
-			name:           "hosted control plane",
-			infrastructure: &fakeInfrastructureReader{highlyAvailableInfrastructure: true, hostedControlPlane: true},
-			verify: func(api bool) {
-				if api {
-					t.Fatalf("kubernetes control plane rules found, none expected")
-				}
-			},
+			name: "custom",
+			config: `nodeExporter:
+  maxProcs: 2
+  resources:
+    requests:
+      memory: 64Mi
+    limits:
+      memory: 128Mi
+`,
+			expectedArg:    "--runtime.gomaxprocs=2",
+			expectedMemory: "64Mi",
 		},
-	}
+		{
+			name:   "invalid maxProcs",
+			config: "nodeExporter:\n  maxProcs: 0\n",
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	for _, tc := range tests {
-		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), tc.infrastructure, &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-		r, err := f.ControlPlanePrometheusRule()
-		if err != nil {
-			t.Fatal(err)
-		}
-		apiServerRulesFound := false
-		for _, g := range r.Spec.Groups {
-			switch g.Name {
-			case "kubernetes-system-apiserver":
-				apiServerRulesFound = true
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			ds, err := f.NodeExporterDaemonSet(nil)
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
 			}
-		}
-		tc.verify(apiServerRulesFound)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if arg := getContainerArgValue(ds.Spec.Template.Spec.Containers, "--runtime.gomaxprocs=", "node-exporter"); arg != tc.expectedArg {
+				t.Fatalf("expected GOMAXPROCS argument %q, got %q", tc.expectedArg, arg)
+			}
+
+			for _, container := range ds.Spec.Template.Spec.Containers {
+				if container.Name != "node-exporter" {
+					continue
+				}
+				if mem := container.Resources.Requests.Memory().String(); mem != tc.expectedMemory {
+					t.Fatalf("expected memory request %q, got %q", tc.expectedMemory, mem)
+				}
+			}
+		})
 	}
 }
 
-func TestEtcdGrafanaDashboardFiltered(t *testing.T) {
-	enabled := false
-	c := NewDefaultConfig()
-	c.ClusterMonitoringConfiguration.EtcdConfig.Enabled = &enabled
-	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-
-	cms, err := f.GrafanaDashboardDefinitions()
+func TestNodeExporterTextfile(t *testing.T) {
+	c, err := NewConfigFromString(`nodeExporter:
+  textfile:
+    hostPath: /var/lib/node_exporter/textfile/
+    configMap: custom-textfile
+`)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	for _, cm := range cms.Items {
-		if cm.Name == "grafana-dashboard-etcd" {
-			t.Fatal("etcd dashboard found, even if etcd is disabled")
-		}
+	textfile := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-textfile"},
+		Data: map[string]string{
+			"raid.prom":  "raid_degraded 0\n",
+			"README.txt": "ignored",
+		},
 	}
-}
 
-func TestEtcdGrafanaDashboard(t *testing.T) {
-	enabled := true
-	c := NewDefaultConfig()
-	c.ClusterMonitoringConfiguration.EtcdConfig.Enabled = &enabled
 	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-
-	cms, err := f.GrafanaDashboardDefinitions()
+	ds, err := f.NodeExporterDaemonSet(textfile)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	found := false
-	for _, cm := range cms.Items {
-		if cm.Name == "grafana-dashboard-etcd" {
-			found = true
+	var hostPath, configMap string
+	for _, vol := range ds.Spec.Template.Spec.Volumes {
+		switch vol.Name {
+		case "node-exporter-textfile":
+			if vol.HostPath == nil {
+				t.Fatalf("expected hostPath textfile volume, got %v", vol.VolumeSource)
+			}
+			hostPath = vol.HostPath.Path
+		case "node-exporter-textfile-configmap":
+			configMap = vol.ConfigMap.Name
 		}
 	}
-	if !found {
-		t.Fatal("etcd dashboard not found, even if etcd is enabled")
+	if hostPath != "/var/lib/node_exporter/textfile" {
+		t.Fatalf("expected hostPath %q, got %q", "/var/lib/node_exporter/textfile", hostPath)
+	}
+	if configMap != "custom-textfile" {
+		t.Fatalf("expected ConfigMap volume %q, got %q", "custom-textfile", configMap)
 	}
-}
 
-func TestThanosQuerierConfiguration(t *testing.T) {
-	c, err := NewConfigFromString(`thanosQuerier:
-  nodeSelector:
-    type: foo
-  tolerations:
-  - effect: PreferNoSchedule
-    operator: Exists
-  resources:
-    limits:
-      cpu: 1m
-      memory: 2Mi
-    requests:
-      cpu: 3m
-      memory: 4Mi
-`)
+	var mounts []string
+	for _, container := range ds.Spec.Template.Spec.Containers {
+		if container.Name != "node-exporter" {
+			continue
+		}
+		for _, vm := range container.VolumeMounts {
+			if vm.Name == "node-exporter-textfile-configmap" {
+				mounts = append(mounts, vm.MountPath)
+			}
+		}
+	}
+	if !reflect.DeepEqual(mounts, []string{"/var/node_exporter/textfile/raid.prom"}) {
+		t.Fatalf("unexpected textfile mounts: %v", mounts)
+	}
 
-	if err != nil {
-		t.Fatal(err)
+	hash := ds.Spec.Template.Annotations["monitoring.openshift.io/textfile-hash"]
+	if hash == "" {
+		t.Fatal("expected textfile hash annotation")
 	}
 
-	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-	d, err := f.ThanosQuerierDeployment(
-		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-		false,
-		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-	)
+	textfile.Data["raid.prom"] = "raid_degraded 1\n"
+	ds, err = f.NodeExporterDaemonSet(textfile)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	for _, tc := range []struct {
-		name      string
-		want, got interface{}
-	}{
-		{
-			name: "node selector",
-			want: map[string]string{"type": "foo"},
-			got:  d.Spec.Template.Spec.NodeSelector,
-		},
-		{
-			name: "tolerations",
-			want: []v1.Toleration{
-				{
-					Effect:   "PreferNoSchedule",
-					Operator: "Exists",
-				},
-			},
-			got: d.Spec.Template.Spec.Tolerations,
-		},
-	} {
-		t.Run(tc.name, func(t *testing.T) {
-			if !reflect.DeepEqual(tc.got, tc.want) {
-				t.Errorf("want %+v, got %+v", tc.want, tc.got)
-			}
-		})
+	if ds.Spec.Template.Annotations["monitoring.openshift.io/textfile-hash"] == hash {
+		t.Fatal("expected textfile hash annotation to change")
 	}
+}
 
-	kubeRbacProxyTLSCipherSuitesArg := ""
-	kubeRbacProxyMinTLSVersionArg := ""
-	for _, c := range d.Spec.Template.Spec.Containers {
-		switch c.Name {
-		case "thanos-query":
-			for _, tc := range []struct {
-				name, want string
-				resource   func() *resource.Quantity
-			}{
-				{
-					name:     "limits/cpu",
-					want:     "1m",
-					resource: c.Resources.Limits.Cpu,
-				},
-				{
-					name:     "limits/memory",
-					want:     "2Mi",
-					resource: c.Resources.Limits.Memory,
-				},
-				{
-					name:     "requests/cpu",
-					want:     "3m",
-					resource: c.Resources.Requests.Cpu,
-				},
-				{
-					name:     "requests/memory",
-					want:     "4Mi",
-					resource: c.Resources.Requests.Memory,
-				},
-			} {
-				t.Run(tc.name, func(t *testing.T) {
-					if got := tc.resource(); got.Cmp(resource.MustParse(tc.want)) != 0 {
-						t.Errorf("want %v, got %v", tc.want, got)
-					}
-				})
+func TestNodeExporterInvalidTextfileHostPath(t *testing.T) {
+	for _, p := range []string{"relative/path", "/", "//"} {
+		t.Run(p, func(t *testing.T) {
+			c, err := NewConfigFromString(fmt.Sprintf("nodeExporter:\n  textfile:\n    hostPath: %q\n", p))
+			if err != nil {
+				t.Fatal(err)
 			}
 
-		case "oauth-proxy":
-			volumeName := "thanos-querier-trusted-ca-bundle"
-			if !trustedCABundleVolumeConfigured(d.Spec.Template.Spec.Volumes, volumeName) {
-				t.Fatalf("trusted CA bundle volume for %s is not configured correctly", c.Name)
-			}
-			if !trustedCABundleVolumeMountsConfigured(c.VolumeMounts, volumeName) {
-				t.Fatalf("trusted CA bundle volume mount for %s is not configured correctly", c.Name)
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			_, err = f.NodeExporterDaemonSet(nil)
+			if !errors.Is(err, ErrConfigValidation) {
+				t.Fatalf("expected config validation error, got %v", err)
 			}
-
-		case "kube-rbac-proxy", "kube-rbac-proxy-rules", "kube-rbac-proxy-metrics":
-			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, c.Name)
-			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, c.Name)
-		}
-	}
-	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyTLSCipherSuitesFlag,
-		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
-
-	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
-		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
-	}
-
-	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
-	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
-		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
+		})
 	}
 }
 
-func TestGrafanaConfiguration(t *testing.T) {
+func TestKubeStateMetrics(t *testing.T) {
 	c, err := NewConfigFromString(``)
 	if err != nil {
 		t.Fatal(err)
 	}
+	c.SetImages(map[string]string{
+		"kube-state-metrics": "docker.io/openshift/origin-kube-state-metrics:latest",
+		"kube-rbac-proxy":    "docker.io/openshift/origin-kube-rbac-proxy:latest",
+	})
+
 	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-	d, err := f.GrafanaDeployment(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+
+	d, err := f.KubeStateMetricsDeployment(nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -2425,19 +3556,20 @@ func TestGrafanaConfiguration(t *testing.T) {
 	kubeRbacProxyMinTLSVersionArg := ""
 	for _, container := range d.Spec.Template.Spec.Containers {
 		switch container.Name {
-		case "grafana-proxy":
-			volumeName := "grafana-trusted-ca-bundle"
-			if !trustedCABundleVolumeConfigured(d.Spec.Template.Spec.Volumes, volumeName) {
-				t.Fatalf("trusted CA bundle volume for %s is not configured correctly", container.Name)
+		case "kube-state-metrics":
+			if container.Image != "docker.io/openshift/origin-kube-state-metrics:latest" {
+				t.Fatal("kube-state-metrics image incorrectly configured")
 			}
-			if !trustedCABundleVolumeMountsConfigured(container.VolumeMounts, volumeName) {
-				t.Fatalf("trusted CA bundle volume mount for %s is not configured correctly", container.Name)
+		case "kube-rbac-proxy-self", "kube-rbac-proxy-main":
+			if container.Image != "docker.io/openshift/origin-kube-rbac-proxy:latest" {
+				t.Fatalf("%s image incorrectly configured", container.Name)
 			}
-		case "kube-rbac-proxy-metrics":
+
 			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
 			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
 		}
 	}
+
 	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
 		KubeRbacProxyTLSCipherSuitesFlag,
 		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
@@ -2451,407 +3583,3268 @@ func TestGrafanaConfiguration(t *testing.T) {
 	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
 		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
 	}
-}
 
-func TestTelemeterConfiguration(t *testing.T) {
-	c, err := NewConfigFromString(``)
+	d2, err := f.KubeStateMetricsDeployment(nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-	d, err := f.TelemeterClientDeployment(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+
+	if !reflect.DeepEqual(d, d2) {
+		t.Fatal("expected KubeStateMetricsDeployment to be an idempotent function")
+	}
+}
+
+func TestKubeStateMetricsCustomResourceState(t *testing.T) {
+	c, err := NewConfigFromString(`kubeStateMetrics:
+  customResourceStateConfig:
+    name: ksm-crs
+    key: crs.yaml
+`)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	kubeRbacProxyTLSCipherSuitesArg := ""
-	kubeRbacProxyMinTLSVersionArg := ""
-	for _, container := range d.Spec.Template.Spec.Containers {
-		switch container.Name {
-		case "telemeter-client":
-			volumeName := "telemeter-trusted-ca-bundle"
-			if !trustedCABundleVolumeConfigured(d.Spec.Template.Spec.Volumes, volumeName) {
-				t.Fatalf("trusted CA bundle volume for %s is not configured correctly", container.Name)
-			}
-			if !trustedCABundleVolumeMountsConfigured(container.VolumeMounts, volumeName) {
-				t.Fatalf("trusted CA bundle volume mount for %s is not configured correctly", container.Name)
-			}
-		case "kube-rbac-proxy":
-			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
-			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	newConfigMap := func(content string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "ksm-crs", Namespace: "openshift-monitoring"},
+			Data:       map[string]string{"crs.yaml": content},
 		}
 	}
 
-	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyTLSCipherSuitesFlag,
-		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
-
-	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
-		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
+	d, err := f.KubeStateMetricsDeployment(newConfigMap("spec: {}"))
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
-	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
-		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
+	arg := "--custom-resource-state-config-file=/etc/kube-state-metrics/custom-resource-state/config.yaml"
+	if got := getContainerArgValue(d.Spec.Template.Spec.Containers, arg, "kube-state-metrics"); got != arg {
+		t.Fatalf("expected argument %q, got %q", arg, got)
 	}
-}
 
-func TestThanosRulerConfiguration(t *testing.T) {
+	var found bool
+	for _, v := range d.Spec.Template.Spec.Volumes {
+		if v.Name != kubeStateMetricsCustomResourceStateVolume {
+			continue
+		}
+		found = true
+
+		expected := []v1.KeyToPath{{Key: "crs.yaml", Path: "config.yaml"}}
+		if v.ConfigMap == nil || v.ConfigMap.Name != "ksm-crs" || !reflect.DeepEqual(v.ConfigMap.Items, expected) {
+			t.Fatalf("unexpected volume source %+v", v.VolumeSource)
+		}
+	}
+	if !found {
+		t.Fatalf("expected volume %q", kubeStateMetricsCustomResourceStateVolume)
+	}
+
+	hash := d.Spec.Template.Annotations[kubeStateMetricsCustomResourceStateHashAnnotation]
+	if hash == "" {
+		t.Fatal("expected the custom resource state configuration hash annotation")
+	}
+
+	d, err = f.KubeStateMetricsDeployment(newConfigMap("spec: {resources: []}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Spec.Template.Annotations[kubeStateMetricsCustomResourceStateHashAnnotation] == hash {
+		t.Fatal("expected the hash annotation to change with the configuration")
+	}
+}
+
+func TestKubeStateMetricsShards(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected []string
+		err      error
+	}{
+		{
+			name:     "default",
+			expected: []string{"kube-state-metrics"},
+		},
+		{
+			name:     "single shard",
+			config:   "kubeStateMetrics:\n  shards: 1\n",
+			expected: []string{"kube-state-metrics"},
+		},
+		{
+			name:     "3 shards",
+			config:   "kubeStateMetrics:\n  shards: 3\n",
+			expected: []string{"kube-state-metrics-shard-0", "kube-state-metrics-shard-1", "kube-state-metrics-shard-2"},
+		},
+		{
+			name:   "invalid shards",
+			config: "kubeStateMetrics:\n  shards: 0\n",
+			err:    ErrConfigValidation,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			deployments, err := f.KubeStateMetricsDeployments(nil)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected error %v, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc, err := f.KubeStateMetricsService()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for i, d := range deployments {
+				names = append(names, d.Name)
+
+				selector := labels.SelectorFromSet(d.Spec.Selector.MatchLabels)
+				if !selector.Matches(labels.Set(d.Spec.Template.Labels)) {
+					t.Errorf("%s: the selector doesn't match the pod labels", d.Name)
+				}
+
+				if !labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(d.Spec.Template.Labels)) {
+					t.Errorf("%s: the Service doesn't select the pods", d.Name)
+				}
+
+				shardArg := getContainerArgValue(d.Spec.Template.Spec.Containers, "--shard=", "kube-state-metrics")
+				totalArg := getContainerArgValue(d.Spec.Template.Spec.Containers, "--total-shards=", "kube-state-metrics")
+				if len(deployments) == 1 {
+					if shardArg != "" || totalArg != "" {
+						t.Errorf("%s: unexpected sharding arguments %q %q", d.Name, shardArg, totalArg)
+					}
+					continue
+				}
+
+				if shardArg != fmt.Sprintf("--shard=%d", i) || totalArg != fmt.Sprintf("--total-shards=%d", len(deployments)) {
+					t.Errorf("%s: unexpected sharding arguments %q %q", d.Name, shardArg, totalArg)
+				}
+
+				for j := range deployments[:i] {
+					other := labels.SelectorFromSet(deployments[j].Spec.Selector.MatchLabels)
+					if other.Matches(labels.Set(d.Spec.Template.Labels)) {
+						t.Errorf("%s: the pods are selected by %s", d.Name, deployments[j].Name)
+					}
+				}
+			}
+
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Fatalf("expected deployments %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestOpenShiftStateMetrics(t *testing.T) {
 	c, err := NewConfigFromString(``)
 	if err != nil {
 		t.Fatal(err)
 	}
+	c.SetImages(map[string]string{
+		"openshift-state-metrics": "docker.io/openshift/origin-openshift-state-metrics:latest",
+		"kube-rbac-proxy":         "docker.io/openshift/origin-kube-rbac-proxy:latest",
+	})
+
 	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-	tr, err := f.ThanosRulerCustomResource(
-		"",
-		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-		nil,
-	)
+
+	d, err := f.OpenShiftStateMetricsDeployment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kubeRbacProxyTLSCipherSuitesArg := ""
+	kubeRbacProxyMinTLSVersionArg := ""
+	for _, container := range d.Spec.Template.Spec.Containers {
+		switch container.Name {
+		case "openshift-state-metrics":
+			if container.Image != "docker.io/openshift/origin-openshift-state-metrics:latest" {
+				t.Fatal("openshift-state-metrics image incorrectly configured")
+			}
+
+		case "kube-rbac-proxy-self", "kube-rbac-proxy-main":
+			if container.Image != "docker.io/openshift/origin-kube-rbac-proxy:latest" {
+				t.Fatal("kube-rbac-proxy image incorrectly configured")
+			}
+			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
+			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
+		}
+	}
+
+	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyTLSCipherSuitesFlag,
+		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
+
+	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
+		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
+	}
+
+	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
+	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
+		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
+	}
+
+	d2, err := f.OpenShiftStateMetricsDeployment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d, d2) {
+		t.Fatal("expected OpenShiftStateMetricsDeployment to be an idempotent function")
+	}
+
+}
+
+func TestOpenShiftStateMetricsCollectorsAndResync(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "default",
+			expected: []string{"--host=127.0.0.1", "--port=8081", "--telemetry-host=127.0.0.1", "--telemetry-port=8082"},
+		},
+		{
+			name: "disabled collectors and resync period",
+			config: `openshiftStateMetrics:
+  disabledCollectors: [builds, deploymentconfigs]
+  resyncPeriod: 10m
+`,
+			expected: []string{
+				"--host=127.0.0.1", "--port=8081", "--telemetry-host=127.0.0.1", "--telemetry-port=8082",
+				"--collectors=buildconfigs,clusterresourcequotas,groups,routes",
+				"--resync-period=10m",
+			},
+		},
+		{
+			name: "unknown collector",
+			config: `openshiftStateMetrics:
+  disabledCollectors: [pods]
+`,
+			err: true,
+		},
+		{
+			name: "all collectors disabled",
+			config: `openshiftStateMetrics:
+  disabledCollectors: [buildconfigs, builds, clusterresourcequotas, deploymentconfigs, groups, routes]
+`,
+			err: true,
+		},
+		{
+			name: "invalid resync period",
+			config: `openshiftStateMetrics:
+  resyncPeriod: 0s
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			d, err := f.OpenShiftStateMetricsDeployment()
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, container := range d.Spec.Template.Spec.Containers {
+				if container.Name != "openshift-state-metrics" {
+					continue
+				}
+				if !reflect.DeepEqual(container.Args, tc.expected) {
+					t.Fatalf("expected args %v, got %v", tc.expected, container.Args)
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusK8sControlPlaneRulesFiltered(t *testing.T) {
+	tests := []struct {
+		name           string
+		infrastructure InfrastructureReader
+		verify         func(bool)
+	}{
+		{
+			name:           "default config",
+			infrastructure: defaultInfrastructureReader(),
+			verify: func(api bool) {
+				if !api {
+					t.Fatal("did not get all expected kubernetes control plane rules")
+				}
+			},
+		},
+		{
+			name:           "hosted control plane",
+			infrastructure: &fakeInfrastructureReader{highlyAvailableInfrastructure: true, hostedControlPlane: true},
+			verify: func(api bool) {
+				if api {
+					t.Fatalf("kubernetes control plane rules found, none expected")
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), tc.infrastructure, &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+		r, err := f.ControlPlanePrometheusRule()
+		if err != nil {
+			t.Fatal(err)
+		}
+		apiServerRulesFound := false
+		for _, g := range r.Spec.Groups {
+			switch g.Name {
+			case "kubernetes-system-apiserver":
+				apiServerRulesFound = true
+			}
+		}
+		tc.verify(apiServerRulesFound)
+	}
+}
+
+func TestEtcdGrafanaDashboardFiltered(t *testing.T) {
+	enabled := false
+	c := NewDefaultConfig()
+	c.ClusterMonitoringConfiguration.EtcdConfig.Enabled = &enabled
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	cms, err := f.GrafanaDashboardDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cm := range cms.Items {
+		if cm.Name == "grafana-dashboard-etcd" {
+			t.Fatal("etcd dashboard found, even if etcd is disabled")
+		}
+	}
+}
+
+func TestEtcdGrafanaDashboard(t *testing.T) {
+	enabled := true
+	c := NewDefaultConfig()
+	c.ClusterMonitoringConfiguration.EtcdConfig.Enabled = &enabled
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	cms, err := f.GrafanaDashboardDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, cm := range cms.Items {
+		if cm.Name == "grafana-dashboard-etcd" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("etcd dashboard not found, even if etcd is enabled")
+	}
+}
+
+func TestThanosQuerierConfiguration(t *testing.T) {
+	c, err := NewConfigFromString(`thanosQuerier:
+  nodeSelector:
+    type: foo
+  tolerations:
+  - effect: PreferNoSchedule
+    operator: Exists
+  resources:
+    limits:
+      cpu: 1m
+      memory: 2Mi
+    requests:
+      cpu: 3m
+      memory: 4Mi
+  maxConcurrentQueries: 10
+  queryTimeout: 2m
+  lookbackDelta: 10m
+`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	d, err := f.ThanosQuerierDeployment(
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		false,
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		want, got interface{}
+	}{
+		{
+			name: "node selector",
+			want: map[string]string{"type": "foo"},
+			got:  d.Spec.Template.Spec.NodeSelector,
+		},
+		{
+			name: "tolerations",
+			want: []v1.Toleration{
+				{
+					Effect:   "PreferNoSchedule",
+					Operator: "Exists",
+				},
+			},
+			got: d.Spec.Template.Spec.Tolerations,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tc.got, tc.want) {
+				t.Errorf("want %+v, got %+v", tc.want, tc.got)
+			}
+		})
+	}
+
+	kubeRbacProxyTLSCipherSuitesArg := ""
+	kubeRbacProxyMinTLSVersionArg := ""
+	for _, c := range d.Spec.Template.Spec.Containers {
+		switch c.Name {
+		case "thanos-query":
+			for _, arg := range []string{"--query.max-concurrent=10", "--query.timeout=2m", "--query.lookback-delta=10m"} {
+				if got := getContainerArgValue(d.Spec.Template.Spec.Containers, strings.SplitN(arg, "=", 2)[0]+"=", c.Name); got != arg {
+					t.Errorf("want argument %q, got %q", arg, got)
+				}
+			}
+
+			for _, tc := range []struct {
+				name, want string
+				resource   func() *resource.Quantity
+			}{
+				{
+					name:     "limits/cpu",
+					want:     "1m",
+					resource: c.Resources.Limits.Cpu,
+				},
+				{
+					name:     "limits/memory",
+					want:     "2Mi",
+					resource: c.Resources.Limits.Memory,
+				},
+				{
+					name:     "requests/cpu",
+					want:     "3m",
+					resource: c.Resources.Requests.Cpu,
+				},
+				{
+					name:     "requests/memory",
+					want:     "4Mi",
+					resource: c.Resources.Requests.Memory,
+				},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					if got := tc.resource(); got.Cmp(resource.MustParse(tc.want)) != 0 {
+						t.Errorf("want %v, got %v", tc.want, got)
+					}
+				})
+			}
+
+		case "oauth-proxy":
+			volumeName := "thanos-querier-trusted-ca-bundle"
+			if !trustedCABundleVolumeConfigured(d.Spec.Template.Spec.Volumes, volumeName) {
+				t.Fatalf("trusted CA bundle volume for %s is not configured correctly", c.Name)
+			}
+			if !trustedCABundleVolumeMountsConfigured(c.VolumeMounts, volumeName) {
+				t.Fatalf("trusted CA bundle volume mount for %s is not configured correctly", c.Name)
+			}
+
+		case "kube-rbac-proxy", "kube-rbac-proxy-rules", "kube-rbac-proxy-metrics":
+			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, c.Name)
+			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, c.Name)
+		}
+	}
+	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyTLSCipherSuitesFlag,
+		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
+
+	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
+		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
+	}
+
+	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
+	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
+		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
+	}
+}
+
+func TestGrafanaConfiguration(t *testing.T) {
+	c, err := NewConfigFromString(``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	d, err := f.GrafanaDeployment(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kubeRbacProxyTLSCipherSuitesArg := ""
+	kubeRbacProxyMinTLSVersionArg := ""
+	for _, container := range d.Spec.Template.Spec.Containers {
+		switch container.Name {
+		case "grafana-proxy":
+			volumeName := "grafana-trusted-ca-bundle"
+			if !trustedCABundleVolumeConfigured(d.Spec.Template.Spec.Volumes, volumeName) {
+				t.Fatalf("trusted CA bundle volume for %s is not configured correctly", container.Name)
+			}
+			if !trustedCABundleVolumeMountsConfigured(container.VolumeMounts, volumeName) {
+				t.Fatalf("trusted CA bundle volume mount for %s is not configured correctly", container.Name)
+			}
+		case "kube-rbac-proxy-metrics":
+			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
+			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
+		}
+	}
+	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyTLSCipherSuitesFlag,
+		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
+
+	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
+		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
+	}
+
+	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
+	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
+		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
+	}
+}
+
+func TestGrafanaAdditionalDatasources(t *testing.T) {
+	c, err := NewConfigFromString(``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	existing := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "grafana-datasources", Namespace: "openshift-monitoring"},
+		Data: map[string][]byte{
+			"prometheus.yaml":               []byte("{}"),
+			grafanaAdditionalDatasourcesKey: []byte("{}"),
+		},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		sources     []string
+		datasources []map[string]interface{}
+		err         bool
+	}{
+		{
+			name: "no additional datasources",
+		},
+		{
+			name: "yaml and json datasources",
+			sources: []string{
+				"name: loki\ntype: loki\nurl: https://loki.example.com\neditable: true\n",
+				`{"name": "thanos", "type": "prometheus", "url": "https://thanos.example.com", "orgId": 2}`,
+			},
+			datasources: []map[string]interface{}{
+				{"name": "loki", "type": "loki", "url": "https://loki.example.com", "editable": false, "orgId": float64(1)},
+				{"name": "thanos", "type": "prometheus", "url": "https://thanos.example.com", "editable": false, "orgId": float64(2)},
+			},
+		},
+		{
+			name:    "missing type",
+			sources: []string{"name: loki\n"},
+			err:     true,
+		},
+		{
+			name:    "conflict with the generated datasource",
+			sources: []string{"name: prometheus\ntype: prometheus\n"},
+			err:     true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sources := make([][]byte, 0, len(tc.sources))
+			for _, src := range tc.sources {
+				sources = append(sources, []byte(src))
+			}
+
+			s, err := f.GrafanaDatasourcesWithAdditional(existing, sources)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(s.Data["prometheus.yaml"]) != "{}" {
+				t.Fatalf("the generated datasource shouldn't be modified, got %q", s.Data["prometheus.yaml"])
+			}
+
+			b, found := s.Data[grafanaAdditionalDatasourcesKey]
+			if len(tc.datasources) == 0 {
+				if found {
+					t.Fatalf("expected no additional datasources, got %q", b)
+				}
+				return
+			}
+
+			var got struct {
+				Datasources []map[string]interface{} `json:"datasources"`
+			}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Datasources, tc.datasources) {
+				t.Fatalf("expected datasources %v, got %v", tc.datasources, got.Datasources)
+			}
+
+			d, err := f.GrafanaDeployment(nil, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Spec.Template.Annotations[grafanaAdditionalDatasourcesHashAnnotation] == "" {
+				t.Fatal("expected the additional datasources hash annotation on the pod template")
+			}
+		})
+	}
+}
+
+func TestConsoleDashboards(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		excluded []string
+		err      bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:     "excluded dashboards",
+			config:   "consoleDashboards:\n  excluded: [grafana-dashboard-etcd]\n",
+			excluded: []string{"grafana-dashboard-etcd"},
+		},
+		{
+			name:   "unknown dashboard",
+			config: "consoleDashboards:\n  excluded: [grafana-dashboard-etcd, foo]\n",
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+			kept, removed, err := f.ConsoleDashboards()
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected a validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, cm := range removed.Items {
+				got = append(got, cm.Name)
+			}
+			if !reflect.DeepEqual(got, tc.excluded) {
+				t.Fatalf("expected excluded dashboards %v, got %v", tc.excluded, got)
+			}
+
+			if len(kept.Items) == 0 {
+				t.Fatal("expected dashboards to deploy")
+			}
+			for _, cm := range kept.Items {
+				if cm.Namespace != ConsoleDashboardsNamespace {
+					t.Fatalf("expected namespace %s, got %s", ConsoleDashboardsNamespace, cm.Namespace)
+				}
+				if cm.Labels["console.openshift.io/dashboard"] != "true" {
+					t.Fatalf("expected the console label on %s", cm.Name)
+				}
+				for _, e := range tc.excluded {
+					if cm.Name == e {
+						t.Fatalf("expected %s to be excluded", e)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConsoleAdditionalDashboards(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		ns     string
+		err    bool
+	}{
+		{
+			name: "not configured",
+		},
+		{
+			name:   "namespace",
+			config: "consoleDashboards:\n  additionalDashboardsNamespace: team-a\n",
+			ns:     "team-a",
+		},
+		{
+			name:   "invalid namespace",
+			config: "consoleDashboards:\n  additionalDashboardsNamespace: Team_A\n",
+			err:    true,
+		},
+		{
+			name:   "console namespace",
+			config: "consoleDashboards:\n  additionalDashboardsNamespace: openshift-config-managed\n",
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+			ns, err := f.AdditionalDashboardsNamespace()
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected a validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ns != tc.ns {
+				t.Fatalf("expected namespace %q, got %q", tc.ns, ns)
+			}
+		})
+	}
+
+	c, err := NewConfigFromString(``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	cl := f.ConsoleAdditionalDashboards([]v1.ConfigMap{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "team-a",
+				Labels: map[string]string{
+					"console.openshift.io/dashboard": "true",
+					"team":                           "a",
+				},
+			},
+			Data: map[string]string{"app.json": "{}"},
+		},
+	})
+	if len(cl.Items) != 1 {
+		t.Fatalf("expected 1 copy, got %d", len(cl.Items))
+	}
+
+	cm := cl.Items[0]
+	if cm.Namespace != ConsoleDashboardsNamespace || cm.Name != "monitoring-additional-team-a-app" {
+		t.Fatalf("expected openshift-config-managed/monitoring-additional-team-a-app, got %s/%s", cm.Namespace, cm.Name)
+	}
+	expLabels := map[string]string{
+		"app.kubernetes.io/part-of":                          "openshift-monitoring",
+		"console.openshift.io/dashboard":                     "true",
+		"monitoring.openshift.io/dashboard-source-namespace": "team-a",
+	}
+	if !reflect.DeepEqual(cm.Labels, expLabels) {
+		t.Fatalf("expected labels %v, got %v", expLabels, cm.Labels)
+	}
+	if !reflect.DeepEqual(cm.Data, map[string]string{"app.json": "{}"}) {
+		t.Fatalf("unexpected data %v", cm.Data)
+	}
+}
+
+func TestConsoleAdditionalDashboardsDontCollide(t *testing.T) {
+	c, err := NewConfigFromString(``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	shipped, _, err := f.ConsoleDashboards()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Copying grafana/dashboard-<name> used to produce the name of the
+	// shipped grafana-dashboard-<name> dashboard.
+	var sources []v1.ConfigMap
+	for _, cm := range shipped.Items {
+		name := strings.TrimPrefix(cm.Name, "grafana-")
+		if name == cm.Name {
+			continue
+		}
+		sources = append(sources, v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "grafana"}})
+	}
+	if len(sources) == 0 {
+		t.Fatal("expected shipped dashboards prefixed with grafana-")
+	}
+
+	names := map[string]struct{}{}
+	for _, cm := range shipped.Items {
+		names[cm.Name] = struct{}{}
+	}
+	for _, cm := range f.ConsoleAdditionalDashboards(sources).Items {
+		if _, found := names[cm.Name]; found {
+			t.Fatalf("copy %s collides with a shipped dashboard", cm.Name)
+		}
+	}
+}
+
+func TestGrafanaCustomDashboards(t *testing.T) {
+	c, err := NewConfigFromString(``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	cm, err := f.GrafanaCustomDashboards([]v1.ConfigMap{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "grafana-dashboard-etcd"},
+			Data:       map[string]string{"etcd.json": "{}"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+			Data: map[string]string{
+				"app.json":  `{"title": "app"}`,
+				"README.md": "not a dashboard",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cm.Namespace != "openshift-monitoring" {
+		t.Fatalf("expected namespace openshift-monitoring, got %q", cm.Namespace)
+	}
+	exp := map[string]string{"team-a-app.json": `{"title": "app"}`}
+	if !reflect.DeepEqual(cm.Data, exp) {
+		t.Fatalf("expected data %v, got %v", exp, cm.Data)
+	}
+
+	sources, err := f.GrafanaDashboardSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ds struct {
+		Providers []struct {
+			Folder  string `json:"folder"`
+			Options struct {
+				Path string `json:"path"`
+			} `json:"options"`
+		} `json:"providers"`
+	}
+	if err := json.Unmarshal([]byte(sources.Data["dashboards.yaml"]), &ds); err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Providers) != 2 || ds.Providers[1].Folder != "Custom" || ds.Providers[1].Options.Path != "/grafana-dashboard-definitions/1" {
+		t.Fatalf("custom dashboards provider not configured: %+v", ds.Providers)
+	}
+
+	d, err := f.GrafanaDeployment(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mounted bool
+	for _, c := range d.Spec.Template.Spec.Containers {
+		if c.Name != "grafana" {
+			continue
+		}
+		for _, vm := range c.VolumeMounts {
+			if vm.Name == "grafana-dashboards-custom" && vm.MountPath == "/grafana-dashboard-definitions/1" {
+				mounted = true
+			}
+		}
+	}
+	if !mounted {
+		t.Fatal("custom dashboards volume isn't mounted in the grafana container")
+	}
+}
+
+func TestTelemeterConfiguration(t *testing.T) {
+	c, err := NewConfigFromString(``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	d, err := f.TelemeterClientDeployment(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kubeRbacProxyTLSCipherSuitesArg := ""
+	kubeRbacProxyMinTLSVersionArg := ""
+	for _, container := range d.Spec.Template.Spec.Containers {
+		switch container.Name {
+		case "telemeter-client":
+			volumeName := "telemeter-trusted-ca-bundle"
+			if !trustedCABundleVolumeConfigured(d.Spec.Template.Spec.Volumes, volumeName) {
+				t.Fatalf("trusted CA bundle volume for %s is not configured correctly", container.Name)
+			}
+			if !trustedCABundleVolumeMountsConfigured(container.VolumeMounts, volumeName) {
+				t.Fatalf("trusted CA bundle volume mount for %s is not configured correctly", container.Name)
+			}
+		case "kube-rbac-proxy":
+			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
+			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
+		}
+	}
+
+	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyTLSCipherSuitesFlag,
+		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
+
+	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
+		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
+	}
+
+	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
+	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
+		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
+	}
+}
+
+func TestTelemeterClientProxyAndCABundle(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		err    bool
+		env    map[string]string
+		volume *v1.Volume
+	}{
+		{
+			name: "proxy override",
+			config: `telemeterClient:
+  proxy:
+    httpsProxy: https://proxy.example.com:3128
+    noProxy: .cluster.local
+`,
+			env: map[string]string{
+				"HTTPS_PROXY": "https://proxy.example.com:3128",
+				"NO_PROXY":    ".cluster.local",
+			},
+		},
+		{
+			name: "invalid proxy",
+			config: `telemeterClient:
+  proxy:
+    httpsProxy: proxy.example.com
+`,
+			err: true,
+		},
+		{
+			name: "additional CA bundle from ConfigMap",
+			config: `telemeterClient:
+  additionalCABundle:
+    configMap:
+      name: corporate-ca
+      key: ca.crt
+`,
+			env: map[string]string{
+				"SSL_CERT_DIR": telemeterAdditionalCABundleDir,
+			},
+			volume: &v1.Volume{
+				Name: telemeterAdditionalCABundleVolume,
+				VolumeSource: v1.VolumeSource{
+					ConfigMap: &v1.ConfigMapVolumeSource{
+						LocalObjectReference: v1.LocalObjectReference{Name: "corporate-ca"},
+						Items:                []v1.KeyToPath{{Key: "ca.crt", Path: telemeterAdditionalCABundleFile}},
+					},
+				},
+			},
+		},
+		{
+			name: "additional CA bundle from Secret",
+			config: `telemeterClient:
+  additionalCABundle:
+    secret:
+      name: corporate-ca
+      key: ca.crt
+`,
+			env: map[string]string{
+				"SSL_CERT_DIR": telemeterAdditionalCABundleDir,
+			},
+			volume: &v1.Volume{
+				Name: telemeterAdditionalCABundleVolume,
+				VolumeSource: v1.VolumeSource{
+					Secret: &v1.SecretVolumeSource{
+						SecretName: "corporate-ca",
+						Items:      []v1.KeyToPath{{Key: "ca.crt", Path: telemeterAdditionalCABundleFile}},
+					},
+				},
+			},
+		},
+		{
+			name: "ambiguous additional CA bundle",
+			config: `telemeterClient:
+  additionalCABundle:
+    configMap:
+      name: corporate-ca
+      key: ca.crt
+    secret:
+      name: corporate-ca
+      key: ca.crt
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			d, err := f.TelemeterClientDeployment(nil, []byte("ca"))
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, container := range d.Spec.Template.Spec.Containers {
+				if container.Name != "telemeter-client" {
+					continue
+				}
+
+				env := map[string]string{}
+				for _, e := range container.Env {
+					env[e.Name] = e.Value
+				}
+				for k, v := range tc.env {
+					if env[k] != v {
+						t.Errorf("expected %s=%q, got %q", k, v, env[k])
+					}
+				}
+			}
+
+			var got *v1.Volume
+			for i := range d.Spec.Template.Spec.Volumes {
+				if d.Spec.Template.Spec.Volumes[i].Name == telemeterAdditionalCABundleVolume {
+					got = &d.Spec.Template.Spec.Volumes[i]
+				}
+			}
+			if !reflect.DeepEqual(got, tc.volume) {
+				t.Errorf("expected volume %v, got %v", tc.volume, got)
+			}
+
+			if _, found := d.Spec.Template.Annotations[telemeterAdditionalCABundleHashAnnotation]; found != (tc.volume != nil) {
+				t.Errorf("expected CA bundle hash annotation %t, got %t", tc.volume != nil, found)
+			}
+		})
+	}
+}
+
+func TestThanosRulerConfiguration(t *testing.T) {
+	c, err := NewConfigFromString(``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	tr, err := f.ThanosRulerCustomResource(
+		"",
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, container := range tr.Spec.Containers {
+		if container.Name == "thanos-ruler-proxy" {
+			volumeName := "thanos-ruler-trusted-ca-bundle"
+			if !trustedCABundleVolumeConfigured(tr.Spec.Volumes, volumeName) {
+				t.Fatalf("trusted CA bundle volume for %s is not configured correctly", container.Name)
+			}
+			if !trustedCABundleVolumeMountsConfigured(container.VolumeMounts, volumeName) {
+				t.Fatalf("trusted CA bundle volume mount for %s is not configured correctly", container.Name)
+			}
+		}
+	}
+}
+
+func TestNonHighlyAvailableInfrastructure(t *testing.T) {
+	type spec struct {
+		replicas int32
+		affinity *v1.Affinity
+	}
+
+	tests := []struct {
+		name    string
+		getSpec func(f *Factory) (spec, error)
+	}{
+		{
+			name: "Prometheus",
+			getSpec: func(f *Factory) (spec, error) {
+				p, err := f.PrometheusK8s(
+					"prometheus-k8s.openshift-monitoring.svc",
+					&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+					&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				)
+				if err != nil {
+					return spec{}, err
+				}
+				return spec{*p.Spec.Replicas, p.Spec.Affinity}, nil
+			},
+		},
+		{
+			name: "Alertmanager",
+			getSpec: func(f *Factory) (spec, error) {
+				a, err := f.AlertmanagerMain(
+					"alertmanager-main.openshift-monitoring.svc",
+					&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				)
+				if err != nil {
+					return spec{}, err
+				}
+				return spec{*a.Spec.Replicas, a.Spec.Affinity}, nil
+			},
+		},
+		{
+			name: "Thanos querier",
+			getSpec: func(f *Factory) (spec, error) {
+				q, err := f.ThanosQuerierDeployment(
+					&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+					true,
+					&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				)
+				if err != nil {
+					return spec{}, err
+				}
+				return spec{*q.Spec.Replicas, q.Spec.Template.Spec.Affinity}, nil
+			},
+		},
+		{
+			name: "Prometheus (user-workload)",
+			getSpec: func(f *Factory) (spec, error) {
+				p, err := f.PrometheusUserWorkload(
+					&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				)
+				if err != nil {
+					return spec{}, err
+				}
+				return spec{*p.Spec.Replicas, p.Spec.Affinity}, nil
+			},
+		},
+		{
+			name: "Thanos ruler",
+			getSpec: func(f *Factory) (spec, error) {
+				t, err := f.ThanosRulerCustomResource(
+					"",
+					&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+					&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+					nil,
+				)
+				if err != nil {
+					return spec{}, err
+				}
+				return spec{*t.Spec.Replicas, t.Spec.Affinity}, nil
+			},
+		},
+		{
+			name: "Prometheus adapter",
+			getSpec: func(f *Factory) (spec, error) {
+				p, err := f.PrometheusAdapterDeployment("foo",
+					map[string]string{
+						"requestheader-allowed-names":        "",
+						"requestheader-extra-headers-prefix": "",
+						"requestheader-group-headers":        "",
+						"requestheader-username-headers":     "",
+					})
+				if err != nil {
+					return spec{}, err
+				}
+				return spec{*p.Spec.Replicas, p.Spec.Template.Spec.Affinity}, nil
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), &fakeInfrastructureReader{highlyAvailableInfrastructure: false}, &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+		spec, err := tc.getSpec(f)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if spec.replicas != 1 {
+			t.Errorf("expecting 1 replica, got %d", spec.replicas)
+		}
+
+		if spec.affinity != nil {
+			t.Errorf("expected no affinity constraints with 1 replica, got %v", spec.affinity)
+		}
+	}
+}
+
+func TestPodDisruptionBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		getPDB func(f *Factory) (*policyv1.PodDisruptionBudget, error)
+		ha     bool
+	}{
+		{
+			name: "PrometheusK8s HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.PrometheusK8sPodDisruptionBudget()
+			},
+			ha: true,
+		},
+		{
+			name: "PrometheusK8s non-HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.PrometheusK8sPodDisruptionBudget()
+			},
+			ha: false,
+		},
+		{
+			name: "Alertmanager HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.AlertmanagerPodDisruptionBudget()
+			},
+			ha: true,
+		},
+		{
+			name: "Alertmanager non-HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.AlertmanagerPodDisruptionBudget()
+			},
+			ha: false,
+		},
+		{
+			name: "PrometheusAdapter HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.PrometheusAdapterPodDisruptionBudget()
+			},
+			ha: true,
+		},
+		{
+			name: "PrometheusAdapter non-HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.PrometheusAdapterPodDisruptionBudget()
+			},
+			ha: false,
+		},
+		{
+			name: "ThanosQuerier HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.ThanosQuerierPodDisruptionBudget()
+			},
+			ha: true,
+		},
+		{
+			name: "ThanosQuerier non-HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.ThanosQuerierPodDisruptionBudget()
+			},
+			ha: false,
+		},
+		{
+			name: "PrometheusUWM HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.PrometheusUserWorkloadPodDisruptionBudget()
+			},
+			ha: true,
+		},
+		{
+			name: "PrometheusUWM non-HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.PrometheusUserWorkloadPodDisruptionBudget()
+			},
+			ha: false,
+		},
+		{
+			name: "ThanosRuler HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.ThanosRulerPodDisruptionBudget()
+			},
+			ha: true,
+		},
+		{
+			name: "ThanosRuler non-HA",
+			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
+				return f.ThanosRulerPodDisruptionBudget()
+			},
+			ha: false,
+		},
+	}
+
+	for _, tc := range tests {
+		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), &fakeInfrastructureReader{highlyAvailableInfrastructure: tc.ha}, &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+		pdb, err := tc.getPDB(f)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if tc.ha && pdb == nil {
+			t.Error("expected PodDisruptionBudget in HA infrastructure")
+		} else if !tc.ha && pdb != nil {
+			t.Error("unexpected PodDisruptionBudget in non-HA infrastructure")
+		}
+	}
+
+}
+
+func TestPrometheusOperatorUserWorkloadConfiguration(t *testing.T) {
+	c, err := NewConfigFromString(`
+enableUserWorkload: true
+`)
+
+	c.SetImages(map[string]string{
+		"prometheus-operator":        "docker.io/openshift/origin-prometheus-operator:latest",
+		"prometheus-config-reloader": "docker.io/openshift/origin-prometheus-config-reloader:latest",
+		"configmap-reloader":         "docker.io/openshift/origin-configmap-reloader:latest",
+		"kube-rbac-proxy":            "docker.io/openshift/origin-kube-rbac-proxy:latest",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	d, err := f.PrometheusOperatorUserWorkloadDeployment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prometheusReloaderFound := false
+	prometheusWebTLSCipherSuitesArg := ""
+	prometheusWebTLSVersionArg := ""
+	kubeRbacProxyTLSCipherSuitesArg := ""
+	kubeRbacProxyMinTLSVersionArg := ""
+	for _, container := range d.Spec.Template.Spec.Containers {
+		switch container.Name {
+		case "prometheus-operator":
+			if container.Image != "docker.io/openshift/origin-prometheus-operator:latest" {
+				t.Fatalf("%s image incorrectly configured", container.Name)
+			}
+			if getContainerArgValue(d.Spec.Template.Spec.Containers, PrometheusConfigReloaderFlag+"docker.io/openshift/origin-prometheus-config-reloader:latest", container.Name) != "" {
+				prometheusReloaderFound = true
+			}
+
+			prometheusWebTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, PrometheusOperatorWebTLSCipherSuitesFlag, container.Name)
+			prometheusWebTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, PrometheusOperatorWebTLSMinTLSVersionFlag, container.Name)
+
+		case "kube-rbac-proxy":
+			if container.Image != "docker.io/openshift/origin-kube-rbac-proxy:latest" {
+				t.Fatal("kube-rbac-proxy image incorrectly configured")
+			}
+			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
+			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
+		}
+	}
+
+	if !prometheusReloaderFound {
+		t.Fatal("Configuring the Prometheus Config reloader image failed")
+	}
+
+	expectedPrometheusWebTLSCipherSuitesArg := fmt.Sprintf("%s%s",
+		PrometheusOperatorWebTLSCipherSuitesFlag,
+		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","),
+	)
+	if expectedPrometheusWebTLSCipherSuitesArg != prometheusWebTLSCipherSuitesArg {
+		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", prometheusWebTLSCipherSuitesArg, expectedPrometheusWebTLSCipherSuitesArg)
+	}
+
+	expectedPrometheusWebTLSVersionArg := fmt.Sprintf("%s%s",
+		PrometheusOperatorWebTLSMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
+	if expectedPrometheusWebTLSVersionArg != prometheusWebTLSVersionArg {
+		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", prometheusWebTLSVersionArg, expectedPrometheusWebTLSVersionArg)
+	}
+
+	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyTLSCipherSuitesFlag,
+		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
+
+	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
+		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
+	}
+
+	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
+		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
+	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
+		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
+	}
+
+	d2, err := f.PrometheusOperatorUserWorkloadDeployment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d, d2) {
+		t.Fatal("expected PrometheusOperatorUserWorkloadDeployment to be an idempotent function")
+	}
+}
+
+func TestUserWorkloadPriorityClassName(t *testing.T) {
+	c, err := NewConfigFromString(`
+enableUserWorkload: true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.UserWorkloadConfiguration, err = NewUserConfigFromString(`
+prometheusOperator:
+  priorityClassName: uwm-operator
+prometheus:
+  priorityClassName: uwm-prometheus
+thanosRuler:
+  priorityClassName: uwm-thanos-ruler
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	d, err := f.PrometheusOperatorUserWorkloadDeployment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Spec.Template.Spec.PriorityClassName != "uwm-operator" {
+		t.Fatalf("expected priorityClassName %q for prometheus-operator, got %q", "uwm-operator", d.Spec.Template.Spec.PriorityClassName)
+	}
+
+	p, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Spec.PriorityClassName != "uwm-prometheus" {
+		t.Fatalf("expected priorityClassName %q for prometheus, got %q", "uwm-prometheus", p.Spec.PriorityClassName)
+	}
+
+	tr, err := f.ThanosRulerCustomResource(
+		"",
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Spec.PriorityClassName != "uwm-thanos-ruler" {
+		t.Fatalf("expected priorityClassName %q for thanos-ruler, got %q", "uwm-thanos-ruler", tr.Spec.PriorityClassName)
+	}
+
+	// The platform prometheus-operator keeps its default priority class.
+	d, err = f.PrometheusOperatorDeployment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Spec.Template.Spec.PriorityClassName != "system-cluster-critical" {
+		t.Fatalf("expected default priorityClassName %q for platform prometheus-operator, got %q", "system-cluster-critical", d.Spec.Template.Spec.PriorityClassName)
+	}
+}
+
+func trustedCABundleVolumeConfigured(volumes []v1.Volume, volumeName string) bool {
+	for _, volume := range volumes {
+		if volume.Name == volumeName {
+			return true
+		}
+	}
+	return false
+}
+
+func trustedCABundleVolumeMountsConfigured(volumeMounts []v1.VolumeMount, volumeName string) bool {
+	for _, volumeMount := range volumeMounts {
+		if volumeMount.Name == volumeName {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHostedControlPlane(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		hosted    bool
+		namespace string
+		expected  string
+		err       bool
+	}{
+		{
+			name:      "standalone control plane",
+			namespace: "clusters-foo",
+		},
+		{
+			name:   "hosted without namespace",
+			hosted: true,
+		},
+		{
+			name:      "hosted with namespace",
+			hosted:    true,
+			namespace: "clusters-foo",
+			expected:  "clusters-foo",
+		},
+		{
+			name:      "hosted with invalid namespace",
+			hosted:    true,
+			namespace: "Clusters_Foo",
+			err:       true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewDefaultConfig()
+			c.ClusterMonitoringConfiguration.HostedControlPlane.Namespace = tc.namespace
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, &fakeInfrastructureReader{highlyAvailableInfrastructure: true, hostedControlPlane: tc.hosted}, &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+			ns, err := f.HostedControlPlaneNamespace()
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ns != tc.expected {
+				t.Fatalf("expected namespace %q, got %q", tc.expected, ns)
+			}
+
+			for _, fn := range []func() (*monv1.ServiceMonitor, error){
+				f.ControlPlaneEtcdHostedServiceMonitor,
+				f.ControlPlaneKubeAPIServerHostedServiceMonitor,
+			} {
+				sm, err := fn()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if sm.Namespace != "openshift-monitoring" {
+					t.Fatalf("unexpected namespace %q for ServiceMonitor %q", sm.Namespace, sm.Name)
+				}
+				if tc.expected != "" && !reflect.DeepEqual(sm.Spec.NamespaceSelector.MatchNames, []string{tc.expected}) {
+					t.Fatalf("unexpected namespace selector %v for ServiceMonitor %q", sm.Spec.NamespaceSelector.MatchNames, sm.Name)
+				}
+			}
+
+			r, err := f.ControlPlanePrometheusRule()
+			if err != nil {
+				t.Fatal(err)
+			}
+			apiServerRulesFound := false
+			for _, g := range r.Spec.Groups {
+				if g.Name == "kubernetes-system-apiserver" {
+					apiServerRulesFound = true
+				}
+			}
+			if apiServerRulesFound != (!tc.hosted || tc.expected != "") {
+				t.Fatalf("unexpected apiserver rules presence: %t", apiServerRulesFound)
+			}
+
+			rl, err := f.PrometheusK8sRoleList()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rbl, err := f.PrometheusK8sRoleBindingList()
+			if err != nil {
+				t.Fatal(err)
+			}
+			roleFound, bindingFound := false, false
+			for _, r := range rl.Items {
+				roleFound = roleFound || r.Namespace == "clusters-foo"
+			}
+			for _, rb := range rbl.Items {
+				bindingFound = bindingFound || rb.Namespace == "clusters-foo"
+			}
+			if roleFound != (tc.expected != "") || bindingFound != (tc.expected != "") {
+				t.Fatalf("unexpected Role (%t) or RoleBinding (%t) in the hosted namespace", roleFound, bindingFound)
+			}
+		})
+	}
+}
+
+func TestThanosRulerEvaluationInterval(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected string
+		err      bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:     "custom interval",
+			config:   "thanosRuler:\n  evaluationInterval: 1m\n",
+			expected: "1m",
+		},
+		{
+			name:   "invalid interval",
+			config: "thanosRuler:\n  evaluationInterval: 1 minute\n",
+			err:    true,
+		},
+		{
+			name:   "zero interval",
+			config: "thanosRuler:\n  evaluationInterval: 0s\n",
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewDefaultConfig()
+			uwc, err := NewUserConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.UserWorkloadConfiguration = uwc
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			tr, err := f.ThanosRulerCustomResource(
+				"",
+				&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				nil,
+			)
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tr.Spec.EvaluationInterval != tc.expected {
+				t.Fatalf("expected evaluation interval %q, got %q", tc.expected, tr.Spec.EvaluationInterval)
+			}
+		})
+	}
+}
+
+func TestThanosRulerReplicas(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		ha       bool
+		expected int32
+		err      bool
+	}{
+		{
+			name:     "default",
+			ha:       true,
+			expected: 2,
+		},
+		{
+			name:     "default on non-HA infrastructure",
+			expected: 1,
+		},
+		{
+			name:     "custom replicas",
+			config:   "thanosRuler:\n  replicas: 4\n",
+			ha:       true,
+			expected: 4,
+		},
+		{
+			name:     "custom replicas on non-HA infrastructure",
+			config:   "thanosRuler:\n  replicas: 3\n",
+			expected: 3,
+		},
+		{
+			name:   "zero replicas",
+			config: "thanosRuler:\n  replicas: 0\n",
+			ha:     true,
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewDefaultConfig()
+			uwc, err := NewUserConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.UserWorkloadConfiguration = uwc
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, &fakeInfrastructureReader{highlyAvailableInfrastructure: tc.ha}, &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			tr, err := f.ThanosRulerCustomResource(
+				"",
+				&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				nil,
+			)
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tr.Spec.Replicas == nil || *tr.Spec.Replicas != tc.expected {
+				t.Fatalf("expected %d replicas, got %v", tc.expected, tr.Spec.Replicas)
+			}
+		})
+	}
+}
+
+func TestThanosQuerierInvalidQueryLimits(t *testing.T) {
+	for _, config := range []string{
+		"thanosQuerier:\n  maxConcurrentQueries: 0\n",
+		"thanosQuerier:\n  queryTimeout: 2 minutes\n",
+		"thanosQuerier:\n  lookbackDelta: 0s\n",
+	} {
+		c, err := NewConfigFromString(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+		_, err = f.ThanosQuerierDeployment(
+			&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+			false,
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		)
+		if !errors.Is(err, ErrConfigValidation) {
+			t.Fatalf("config %q: expected config validation error, got %v", config, err)
+		}
+	}
+}
+
+func TestThanosQuerierAdditionalStores(t *testing.T) {
+	c, err := NewConfigFromString(`thanosQuerier:
+  logLevel: debug
+  additionalStores:
+  - address: store.example.com:10901
+    namespace: team-a
+    tlsConfig:
+      ca: {name: store-tls, key: ca.crt}
+      cert: {name: store-tls, key: tls.crt}
+      key: {name: store-tls, key: tls.key}
+      serverName: store.example.com
+  - address: receive.example.com:10901
+  - address: 10.0.0.1:10901
+    tlsConfig:
+      insecureSkipVerify: true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	data, err := f.ThanosQuerierAdditionalStoresTLS(func(namespace, name string) (*v1.Secret, error) {
+		if namespace != "team-a" || name != "store-tls" {
+			return nil, fmt.Errorf("unexpected Secret %s/%s", namespace, name)
+		}
+		return &v1.Secret{Data: map[string][]byte{
+			"ca.crt":  []byte("ca"),
+			"tls.crt": []byte("crt"),
+			"tls.key": []byte("key"),
+		}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedData := []string{
+		"store-0-ca.crt", "ca",
+		"store-0-tls.crt", "crt",
+		"store-0-tls.key", "key",
+	}
+	if !reflect.DeepEqual(data, expectedData) {
+		t.Fatalf("expected data %v, got %v", expectedData, data)
+	}
+
+	d, err := f.ThanosQuerierDeployment(
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		false,
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers := make(map[string]v1.Container)
+	for _, c := range d.Spec.Template.Spec.Containers {
+		containers[c.Name] = c
+	}
+
+	args := make(map[string]struct{})
+	for _, arg := range containers["thanos-query"].Args {
+		args[arg] = struct{}{}
+	}
+	for _, store := range []string{"--store=127.0.0.1:10911", "--store=127.0.0.1:10912", "--store=127.0.0.1:10913"} {
+		if _, found := args[store]; !found {
+			t.Fatalf("expected %q in the thanos-query arguments, got %v", store, containers["thanos-query"].Args)
+		}
+	}
+
+	for _, tc := range []struct {
+		container string
+		expected  []string
+	}{
+		{
+			container: "thanos-query-store-0",
+			expected: []string{
+				"query",
+				"--grpc-address=127.0.0.1:10911",
+				"--http-address=127.0.0.1:11911",
+				"--log.format=logfmt",
+				"--store=store.example.com:10901",
+				"--grpc-server-tls-cert=/etc/tls/grpc/server.crt",
+				"--grpc-server-tls-key=/etc/tls/grpc/server.key",
+				"--grpc-server-tls-client-ca=/etc/tls/grpc/ca.crt",
+				"--log.level=debug",
+				"--grpc-client-tls-secure",
+				"--grpc-client-tls-ca=/etc/tls/grpc/store-0-ca.crt",
+				"--grpc-client-tls-cert=/etc/tls/grpc/store-0-tls.crt",
+				"--grpc-client-tls-key=/etc/tls/grpc/store-0-tls.key",
+				"--grpc-client-server-name=store.example.com",
+			},
+		},
+		{
+			container: "thanos-query-store-1",
+			expected: []string{
+				"query",
+				"--grpc-address=127.0.0.1:10912",
+				"--http-address=127.0.0.1:11912",
+				"--log.format=logfmt",
+				"--store=receive.example.com:10901",
+				"--grpc-server-tls-cert=/etc/tls/grpc/server.crt",
+				"--grpc-server-tls-key=/etc/tls/grpc/server.key",
+				"--grpc-server-tls-client-ca=/etc/tls/grpc/ca.crt",
+				"--log.level=debug",
+			},
+		},
+		{
+			container: "thanos-query-store-2",
+			expected: []string{
+				"query",
+				"--grpc-address=127.0.0.1:10913",
+				"--http-address=127.0.0.1:11913",
+				"--log.format=logfmt",
+				"--store=10.0.0.1:10901",
+				"--grpc-server-tls-cert=/etc/tls/grpc/server.crt",
+				"--grpc-server-tls-key=/etc/tls/grpc/server.key",
+				"--grpc-server-tls-client-ca=/etc/tls/grpc/ca.crt",
+				"--log.level=debug",
+				"--grpc-client-tls-secure",
+				"--grpc-client-tls-skip-verify",
+			},
+		},
+	} {
+		c, found := containers[tc.container]
+		if !found {
+			t.Fatalf("container %q not found", tc.container)
+		}
+		if !reflect.DeepEqual(c.Args, tc.expected) {
+			t.Fatalf("container %q: expected arguments %v, got %v", tc.container, tc.expected, c.Args)
+		}
+		if c.Image != containers["thanos-query"].Image {
+			t.Fatalf("container %q: expected image %q, got %q", tc.container, containers["thanos-query"].Image, c.Image)
+		}
+	}
+}
+
+func TestThanosQuerierInvalidAdditionalStores(t *testing.T) {
+	for _, config := range []string{
+		"thanosQuerier:\n  additionalStores:\n  - address: store.example.com\n",
+		"thanosQuerier:\n  additionalStores:\n  - address: store.example.com:10901\n  - address: store.example.com:10901\n",
+		"thanosQuerier:\n  additionalStores:\n  - address: store.example.com:10901\n    namespace: Team_A\n",
+		"thanosQuerier:\n  additionalStores:\n  - address: store.example.com:10901\n    tlsConfig:\n      cert: {name: store-tls, key: tls.crt}\n",
+		"thanosQuerier:\n  additionalStores:\n  - address: store.example.com:10901\n    tlsConfig:\n      ca: {name: store-tls}\n",
+	} {
+		c, err := NewConfigFromString(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+		_, err = f.ThanosQuerierDeployment(
+			&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+			false,
+			&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		)
+		if !errors.Is(err, ErrConfigValidation) {
+			t.Fatalf("config %q: expected config validation error, got %v", config, err)
+		}
+	}
+}
+
+func TestPrometheusUserWorkloadEnforcedLimits(t *testing.T) {
+	c := NewDefaultConfig()
+	uwc, err := NewUserConfigFromString(`
+prometheus:
+  enforcedSampleLimit: 50000
+  enforcedTargetLimit: 100
+  enforcedLabelLimit: 64
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.UserWorkloadConfiguration = uwc
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	p, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		got      *uint64
+		expected uint64
+	}{
+		{name: "enforcedSampleLimit", got: p.Spec.EnforcedSampleLimit, expected: 50000},
+		{name: "enforcedTargetLimit", got: p.Spec.EnforcedTargetLimit, expected: 100},
+		{name: "enforcedLabelLimit", got: p.Spec.EnforcedLabelLimit, expected: 64},
+	} {
+		if tc.got == nil || *tc.got != tc.expected {
+			t.Fatalf("%s: expected %d, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+}
+
+func TestPrometheusUserWorkloadRemoteWriteHeaders(t *testing.T) {
+	const uwConfig = `
+prometheus:
+  remoteWrite:
+  - url: https://receive.example.com/api/v1/receive
+    headers:
+      X-Scope-OrgID: "cluster-{{ .ClusterID }}"
+      X-Static: "static"
+`
+
+	for _, tc := range []struct {
+		name      string
+		clusterID string
+		headers   map[string]string
+		err       bool
+	}{
+		{
+			name:      "known cluster ID",
+			clusterID: "123",
+			headers: map[string]string{
+				"X-Scope-OrgID": "cluster-123",
+				"X-Static":      "static",
+			},
+		},
+		{
+			name: "unknown cluster ID",
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewDefaultConfig()
+			c.ClusterMonitoringConfiguration.TelemeterClientConfig.ClusterID = tc.clusterID
+			uwc, err := NewUserConfigFromString(uwConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.UserWorkloadConfiguration = uwc
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			p, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(p.Spec.RemoteWrite) != 1 {
+				t.Fatalf("expected 1 remote write configuration, got %d", len(p.Spec.RemoteWrite))
+			}
+			if !reflect.DeepEqual(p.Spec.RemoteWrite[0].Headers, tc.headers) {
+				t.Fatalf("expected headers %v, got %v", tc.headers, p.Spec.RemoteWrite[0].Headers)
+			}
+
+			// The configuration must not be modified by the templating.
+			if uwc.Prometheus.RemoteWrite[0].Headers["X-Scope-OrgID"] != "cluster-{{ .ClusterID }}" {
+				t.Fatalf("expected header template to be preserved, got %q", uwc.Prometheus.RemoteWrite[0].Headers["X-Scope-OrgID"])
+			}
+		})
+	}
+}
+
+func TestUserWorkloadMonitorsOverTargetQuota(t *testing.T) {
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	p, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := metav1.LabelSelectorRequirement{
+		Key:      TargetQuotaExceededLabel,
+		Operator: metav1.LabelSelectorOpDoesNotExist,
+	}
+	for name, sel := range map[string]*metav1.LabelSelector{
+		"serviceMonitorSelector": p.Spec.ServiceMonitorSelector,
+		"podMonitorSelector":     p.Spec.PodMonitorSelector,
+	} {
+		var found bool
+		for _, req := range sel.MatchExpressions {
+			if reflect.DeepEqual(req, expected) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%s: expected %v in %v", name, expected, sel.MatchExpressions)
+		}
+	}
+}
+
+func TestUserWorkloadExcludedRuleNamespaces(t *testing.T) {
+	c := NewDefaultConfig()
+	uwc, err := NewUserConfigFromString(`
+excludedRuleNamespaces:
+- team-a
+- team-b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.UserWorkloadConfiguration = uwc
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	p, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := f.ThanosRulerCustomResource(
+		"",
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := metav1.LabelSelectorRequirement{
+		Key:      "kubernetes.io/metadata.name",
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   []string{"team-a", "team-b"},
+	}
+	for name, sel := range map[string]*metav1.LabelSelector{
+		"prometheus":   p.Spec.RuleNamespaceSelector,
+		"thanos-ruler": tr.Spec.RuleNamespaceSelector,
+	} {
+		var found bool
+		for _, req := range sel.MatchExpressions {
+			if reflect.DeepEqual(req, expected) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%s: expected rule namespace selector to contain %v, got %v", name, expected, sel.MatchExpressions)
+		}
+	}
+}
+
+func TestPrometheusK8sProbes(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+
+		expectedMinReadySeconds *uint32
+		expectedContainer       *v1.Container
+		err                     bool
+	}{
+		{
+			name:              "default",
+			expectedContainer: &v1.Container{Name: "prometheus"},
+		},
+		{
+			name: "custom probes",
+			config: `prometheusK8s:
+  minReadySeconds: 30
+  readinessProbe:
+    timeoutSeconds: 10
+  startupProbe:
+    failureThreshold: 120
+`,
+			expectedMinReadySeconds: func() *uint32 { v := uint32(30); return &v }(),
+			expectedContainer: &v1.Container{
+				Name:           "prometheus",
+				ReadinessProbe: &v1.Probe{TimeoutSeconds: 10},
+				StartupProbe:   &v1.Probe{FailureThreshold: 120},
+			},
+		},
+		{
+			name: "negative value",
+			config: `prometheusK8s:
+  readinessProbe:
+    periodSeconds: -1
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			p, err := f.PrometheusK8s(
+				"prometheus-k8s.openshift-monitoring.svc",
+				&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				nil,
+			)
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(p.Spec.MinReadySeconds, tc.expectedMinReadySeconds) {
+				t.Fatalf("expected minReadySeconds %v, got %v", tc.expectedMinReadySeconds, p.Spec.MinReadySeconds)
+			}
+
+			var container *v1.Container
+			for i := range p.Spec.Containers {
+				if p.Spec.Containers[i].Name == "prometheus" {
+					container = &p.Spec.Containers[i]
+				}
+			}
+			if !reflect.DeepEqual(container, tc.expectedContainer) {
+				t.Fatalf("expected prometheus container %v, got %v", tc.expectedContainer, container)
+			}
+		})
+	}
+}
+
+func TestPrometheusEnableFeatures(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		uwConfig string
+
+		expected   []string
+		expectedUW []string
+		err        bool
+	}{
+		{
+			name:       "default",
+			expected:   []string{},
+			expectedUW: []string{},
+		},
+		{
+			name: "allowed features",
+			config: `prometheusK8s:
+  enableFeatures:
+  - memory-snapshot-on-shutdown
+  - extra-scrape-metrics
+  - memory-snapshot-on-shutdown
+`,
+			uwConfig: `prometheus:
+  enableFeatures:
+  - exemplar-storage
+`,
+			expected:   []string{"memory-snapshot-on-shutdown", "extra-scrape-metrics"},
+			expectedUW: []string{"exemplar-storage"},
+		},
+		{
+			name: "unsupported feature",
+			config: `prometheusK8s:
+  enableFeatures:
+  - remote-write-receiver
+`,
+			err: true,
+		},
+		{
+			name: "unsupported user workload feature",
+			uwConfig: `prometheus:
+  enableFeatures:
+  - agent
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.UserWorkloadConfiguration, err = NewUserConfigFromString(tc.uwConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			p, err := f.PrometheusK8s(
+				"prometheus-k8s.openshift-monitoring.svc",
+				&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				nil,
+			)
+			if err == nil {
+				var uwp *monv1.Prometheus
+				uwp, err = f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+				if err == nil && !reflect.DeepEqual(uwp.Spec.EnableFeatures, tc.expectedUW) {
+					t.Fatalf("expected user workload features %v, got %v", tc.expectedUW, uwp.Spec.EnableFeatures)
+				}
+			}
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(p.Spec.EnableFeatures, tc.expected) {
+				t.Fatalf("expected features %v, got %v", tc.expected, p.Spec.EnableFeatures)
+			}
+		})
+	}
+}
+
+func TestPrometheusWAL(t *testing.T) {
+	enabled := true
+	for _, tc := range []struct {
+		name     string
+		config   string
+		uwConfig string
+
+		expectedCompression *bool
+		expectedFeatures    []string
+	}{
+		{
+			name:             "default",
+			expectedFeatures: []string{},
+		},
+		{
+			name: "compression and memory snapshot",
+			config: `prometheusK8s:
+  wal:
+    compression: true
+    memorySnapshotOnShutdown: true
+`,
+			uwConfig: `prometheus:
+  wal:
+    compression: true
+    memorySnapshotOnShutdown: true
+`,
+			expectedCompression: &enabled,
+			expectedFeatures:    []string{"memory-snapshot-on-shutdown"},
+		},
+		{
+			name: "memory snapshot already enabled",
+			config: `prometheusK8s:
+  enableFeatures:
+  - memory-snapshot-on-shutdown
+  wal:
+    memorySnapshotOnShutdown: true
+`,
+			uwConfig: `prometheus:
+  enableFeatures:
+  - memory-snapshot-on-shutdown
+  wal:
+    memorySnapshotOnShutdown: true
+`,
+			expectedFeatures: []string{"memory-snapshot-on-shutdown"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.UserWorkloadConfiguration, err = NewUserConfigFromString(tc.uwConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			p, err := f.PrometheusK8s(
+				"prometheus-k8s.openshift-monitoring.svc",
+				&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			uwp, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, p := range []*monv1.Prometheus{p, uwp} {
+				if !reflect.DeepEqual(p.Spec.WALCompression, tc.expectedCompression) {
+					t.Fatalf("%s: expected WAL compression %v, got %v", p.Name, tc.expectedCompression, p.Spec.WALCompression)
+				}
+				if !reflect.DeepEqual(p.Spec.EnableFeatures, tc.expectedFeatures) {
+					t.Fatalf("%s: expected features %v, got %v", p.Name, tc.expectedFeatures, p.Spec.EnableFeatures)
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusExternalLabelNames(t *testing.T) {
+	source, replica := "source", "replica"
+	for _, tc := range []struct {
+		name   string
+		config string
+
+		expectedPrometheusLabel *string
+		expectedReplicaLabel    *string
+		expectedReplicaArgs     []string
+	}{
+		{
+			name:                "default",
+			expectedReplicaArgs: []string{"--query.replica-label=prometheus_replica", "--query.replica-label=thanos_ruler_replica"},
+		},
+		{
+			name: "default names",
+			config: `prometheusK8s:
+  externalLabelNames:
+    prometheus: prometheus
+`,
+			expectedReplicaArgs: []string{"--query.replica-label=prometheus_replica", "--query.replica-label=thanos_ruler_replica"},
+		},
+		{
+			name: "renamed labels",
+			config: `telemeterClient:
+  enabled: false
+prometheusK8s:
+  externalLabelNames:
+    prometheus: source
+    replica: replica
+`,
+			expectedPrometheusLabel: &source,
+			expectedReplicaLabel:    &replica,
+			expectedReplicaArgs:     []string{"--query.replica-label=prometheus_replica", "--query.replica-label=thanos_ruler_replica", "--query.replica-label=replica"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.UserWorkloadConfiguration, err = NewUserConfigFromString("")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			p, err := f.PrometheusK8s(
+				"prometheus-k8s.openshift-monitoring.svc",
+				&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			uwp, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, p := range []*monv1.Prometheus{p, uwp} {
+				if !reflect.DeepEqual(p.Spec.PrometheusExternalLabelName, tc.expectedPrometheusLabel) {
+					t.Fatalf("%s: expected prometheus label %v, got %v", p.Name, tc.expectedPrometheusLabel, p.Spec.PrometheusExternalLabelName)
+				}
+				if !reflect.DeepEqual(p.Spec.ReplicaExternalLabelName, tc.expectedReplicaLabel) {
+					t.Fatalf("%s: expected replica label %v, got %v", p.Name, tc.expectedReplicaLabel, p.Spec.ReplicaExternalLabelName)
+				}
+			}
+
+			d, err := f.ThanosQuerierDeployment(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, false, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var replicaArgs []string
+			for _, c := range d.Spec.Template.Spec.Containers {
+				if c.Name != "thanos-query" {
+					continue
+				}
+				for _, arg := range c.Args {
+					if strings.HasPrefix(arg, "--query.replica-label=") {
+						replicaArgs = append(replicaArgs, arg)
+					}
+				}
+			}
+			if !reflect.DeepEqual(replicaArgs, tc.expectedReplicaArgs) {
+				t.Fatalf("expected replica label flags %v, got %v", tc.expectedReplicaArgs, replicaArgs)
+			}
+		})
+	}
+}
+
+func TestPrometheusK8sQueryLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+
+		expected *monv1.QuerySpec
+		err      bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "custom limits",
+			config: `prometheusK8s:
+  queryTimeout: 1m
+  queryMaxSamples: 10000000
+  queryMaxConcurrency: 10
+`,
+			expected: &monv1.QuerySpec{
+				Timeout:        func() *string { v := "1m"; return &v }(),
+				MaxSamples:     func() *int32 { v := int32(10000000); return &v }(),
+				MaxConcurrency: func() *int32 { v := int32(10); return &v }(),
+			},
+		},
+		{
+			name: "invalid max concurrency",
+			config: `prometheusK8s:
+  queryMaxConcurrency: 0
+`,
+			err: true,
+		},
+		{
+			name: "invalid timeout",
+			config: `prometheusK8s:
+  queryTimeout: 0s
+`,
+			err: true,
+		},
+		{
+			name: "invalid max samples",
+			config: `prometheusK8s:
+  queryMaxSamples: 3000000000
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			p, err := f.PrometheusK8s(
+				"prometheus-k8s.openshift-monitoring.svc",
+				&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				nil,
+			)
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected config validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(p.Spec.Query, tc.expected) {
+				t.Fatalf("expected query spec %+v, got %+v", tc.expected, p.Spec.Query)
+			}
+		})
+	}
+}
+
+func TestPrometheusUserWorkloadQueryLimits(t *testing.T) {
+	c, err := NewConfigFromString(`enableUserWorkload: true`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.UserWorkloadConfiguration, err = NewUserConfigFromString(`prometheus:
+  queryTimeout: 30s
+  queryMaxSamples: 1000000
+  queryMaxConcurrency: 4
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	p, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout, maxSamples, maxConcurrency := "30s", int32(1000000), int32(4)
+	expected := &monv1.QuerySpec{
+		Timeout:        &timeout,
+		MaxSamples:     &maxSamples,
+		MaxConcurrency: &maxConcurrency,
+	}
+	if !reflect.DeepEqual(p.Spec.Query, expected) {
+		t.Fatalf("expected query spec %+v, got %+v", expected, p.Spec.Query)
+	}
+}
+
+func TestPrometheusUserWorkloadFederateProxies(t *testing.T) {
+	c := NewDefaultConfig()
+	c.Images.KubeRbacProxy = "kube-rbac-proxy:test"
+	c.Images.PromLabelProxy = "prom-label-proxy:test"
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	p, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"kube-rbac-proxy-federate": "kube-rbac-proxy:test",
+		"prom-label-proxy":         "prom-label-proxy:test",
+	}
+	for _, container := range p.Spec.Containers {
+		image, found := expected[container.Name]
+		if !found {
+			continue
+		}
+		if container.Image != image {
+			t.Fatalf("%s: expected image %q, got %q", container.Name, image, container.Image)
+		}
+		delete(expected, container.Name)
+	}
+	if len(expected) > 0 {
+		t.Fatalf("missing containers: %v", expected)
+	}
+
+	s, err := f.PrometheusUserWorkloadRBACProxyFederateSecret()
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	for _, container := range tr.Spec.Containers {
-		if container.Name == "thanos-ruler-proxy" {
-			volumeName := "thanos-ruler-trusted-ca-bundle"
-			if !trustedCABundleVolumeConfigured(tr.Spec.Volumes, volumeName) {
-				t.Fatalf("trusted CA bundle volume for %s is not configured correctly", container.Name)
-			}
-			if !trustedCABundleVolumeMountsConfigured(container.VolumeMounts, volumeName) {
-				t.Fatalf("trusted CA bundle volume mount for %s is not configured correctly", container.Name)
-			}
-		}
+	if s.Namespace != "openshift-user-workload-monitoring" {
+		t.Fatalf("expected namespace openshift-user-workload-monitoring, got %q", s.Namespace)
 	}
 }
 
-func TestNonHighlyAvailableInfrastructure(t *testing.T) {
-	type spec struct {
-		replicas int32
-		affinity *v1.Affinity
-	}
+func TestUserWorkloadDefaultRuleEvaluationScope(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
 
-	tests := []struct {
-		name    string
-		getSpec func(f *Factory) (spec, error)
+		expectedPrometheus  *metav1.LabelSelector
+		expectedThanosRuler *metav1.LabelSelector
 	}{
 		{
-			name: "Prometheus",
-			getSpec: func(f *Factory) (spec, error) {
-				p, err := f.PrometheusK8s(
-					"prometheus-k8s.openshift-monitoring.svc",
-					&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-					&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-				)
-				if err != nil {
-					return spec{}, err
-				}
-				return spec{*p.Spec.Replicas, p.Spec.Affinity}, nil
+			name: "default",
+			expectedPrometheus: &metav1.LabelSelector{
+				MatchLabels: map[string]string{RuleEvaluationScopeLabel: RuleEvaluationScopeLeafPrometheus},
 			},
-		},
-		{
-			name: "Alertmanager",
-			getSpec: func(f *Factory) (spec, error) {
-				a, err := f.AlertmanagerMain(
-					"alertmanager-main.openshift-monitoring.svc",
-					&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-				)
-				if err != nil {
-					return spec{}, err
-				}
-				return spec{*a.Spec.Replicas, a.Spec.Affinity}, nil
+			expectedThanosRuler: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: RuleEvaluationScopeLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{RuleEvaluationScopeLeafPrometheus}},
+				},
 			},
 		},
 		{
-			name: "Thanos querier",
-			getSpec: func(f *Factory) (spec, error) {
-				q, err := f.ThanosQuerierDeployment(
-					&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-					true,
-					&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-				)
-				if err != nil {
-					return spec{}, err
-				}
-				return spec{*q.Spec.Replicas, q.Spec.Template.Spec.Affinity}, nil
+			name:   "leaf-prometheus",
+			config: "defaultRuleEvaluationScope: leaf-prometheus\n",
+			expectedPrometheus: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: RuleEvaluationScopeLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{RuleEvaluationScopeThanosRuler}},
+				},
 			},
-		},
-		{
-			name: "Prometheus (user-workload)",
-			getSpec: func(f *Factory) (spec, error) {
-				p, err := f.PrometheusUserWorkload(
-					&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-				)
-				if err != nil {
-					return spec{}, err
-				}
-				return spec{*p.Spec.Replicas, p.Spec.Affinity}, nil
+			expectedThanosRuler: &metav1.LabelSelector{
+				MatchLabels: map[string]string{RuleEvaluationScopeLabel: RuleEvaluationScopeThanosRuler},
 			},
 		},
 		{
-			name: "Thanos ruler",
-			getSpec: func(f *Factory) (spec, error) {
-				t, err := f.ThanosRulerCustomResource(
-					"",
-					&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-					&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
-					nil,
-				)
-				if err != nil {
-					return spec{}, err
-				}
-				return spec{*t.Spec.Replicas, t.Spec.Affinity}, nil
+			name: "rule quotas",
+			config: `namespaceQuotas:
+- namespace: team-a
+  alertingRuleLimit: 10
+`,
+			expectedPrometheus: &metav1.LabelSelector{
+				MatchLabels: map[string]string{RuleEvaluationScopeLabel: RuleEvaluationScopeLeafPrometheus},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: RuleQuotaExceededLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+				},
 			},
-		},
-		{
-			name: "Prometheus adapter",
-			getSpec: func(f *Factory) (spec, error) {
-				p, err := f.PrometheusAdapterDeployment("foo",
-					map[string]string{
-						"requestheader-allowed-names":        "",
-						"requestheader-extra-headers-prefix": "",
-						"requestheader-group-headers":        "",
-						"requestheader-username-headers":     "",
-					})
-				if err != nil {
-					return spec{}, err
-				}
-				return spec{*p.Spec.Replicas, p.Spec.Template.Spec.Affinity}, nil
+			expectedThanosRuler: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: RuleEvaluationScopeLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{RuleEvaluationScopeLeafPrometheus}},
+					{Key: RuleQuotaExceededLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+				},
 			},
 		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewDefaultConfig()
+			uwc, err := NewUserConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.UserWorkloadConfiguration = uwc
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			p, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tr, err := f.ThanosRulerCustomResource(
+				"",
+				&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(p.Spec.RuleSelector, tc.expectedPrometheus) {
+				t.Fatalf("prometheus: expected rule selector %v, got %v", tc.expectedPrometheus, p.Spec.RuleSelector)
+			}
+			if !reflect.DeepEqual(tr.Spec.RuleSelector, tc.expectedThanosRuler) {
+				t.Fatalf("thanos-ruler: expected rule selector %v, got %v", tc.expectedThanosRuler, tr.Spec.RuleSelector)
+			}
+		})
 	}
+}
 
-	for _, tc := range tests {
-		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), &fakeInfrastructureReader{highlyAvailableInfrastructure: false}, &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-		spec, err := tc.getSpec(f)
-		if err != nil {
-			t.Error(err)
+func TestUserWorkloadInvalidDefaultRuleEvaluationScope(t *testing.T) {
+	_, err := NewUserConfigFromString("defaultRuleEvaluationScope: everywhere\n")
+	if !errors.Is(err, ErrConfigValidation) {
+		t.Fatalf("expected config validation error, got %v", err)
+	}
+}
+
+func TestPrometheusK8sExternalLabelsTemplating(t *testing.T) {
+	c, err := NewConfigFromString(`prometheusK8s:
+  externalLabels:
+    cluster: "{{ .ClusterID }}"
+    platform: "{{ .PlatformType }}"
+    region: "{{ .Region }}"
+    domain: "{{ .BaseDomain }}"
+    env: prod
+  remoteWrite:
+  - url: https://receive.example.com/api/v1/receive
+    headers:
+      X-Scope-OrgID: "{{ .Region }}-{{ .ClusterID }}"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ClusterMonitoringConfiguration.TelemeterClientConfig.ClusterID = "123"
+
+	infra := &fakeInfrastructureReader{
+		highlyAvailableInfrastructure: true,
+		platformType:                  "AWS",
+		region:                        "eu-west-1",
+		baseDomain:                    "example.com",
+	}
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, infra, &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	p, err := f.PrometheusK8s(
+		"prometheus-k8s.openshift-monitoring.svc",
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"cluster":  "123",
+		"platform": "AWS",
+		"region":   "eu-west-1",
+		"domain":   "example.com",
+		"env":      "prod",
+	}
+	if !reflect.DeepEqual(p.Spec.ExternalLabels, expected) {
+		t.Fatalf("expected external labels %v, got %v", expected, p.Spec.ExternalLabels)
+	}
+
+	var headers map[string]string
+	for _, rw := range p.Spec.RemoteWrite {
+		if rw.URL == "https://receive.example.com/api/v1/receive" {
+			headers = rw.Headers
 		}
+	}
+	if headers["X-Scope-OrgID"] != "eu-west-1-123" {
+		t.Fatalf("expected X-Scope-OrgID header %q, got %q", "eu-west-1-123", headers["X-Scope-OrgID"])
+	}
 
-		if spec.replicas != 1 {
-			t.Errorf("expecting 1 replica, got %d", spec.replicas)
+	// The region is unknown on platforms without regions.
+	f = NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	_, err = f.PrometheusK8s(
+		"prometheus-k8s.openshift-monitoring.svc",
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		nil,
+	)
+	if !errors.Is(err, ErrConfigValidation) {
+		t.Fatalf("expected config validation error, got %v", err)
+	}
+}
+
+func TestUserWorkloadInvalidRemoteWriteHeaders(t *testing.T) {
+	for _, header := range []string{
+		`"{{ .ClusterID"`,
+		`"{{ .Token }}"`,
+		`"{{ .Region"`,
+	} {
+		_, err := NewUserConfigFromString(`
+prometheus:
+  remoteWrite:
+  - url: https://receive.example.com/api/v1/receive
+    headers:
+      X-Scope-OrgID: ` + header + "\n")
+		if !errors.Is(err, ErrConfigValidation) {
+			t.Fatalf("header %s: expected config validation error, got %v", header, err)
 		}
+	}
+}
 
-		if spec.affinity != nil {
-			t.Errorf("expected no affinity constraints with 1 replica, got %v", spec.affinity)
+func TestDarkLaunchedAlerts(t *testing.T) {
+	c, err := NewConfigFromString(`prometheusK8s:
+  darkLaunchAlerts:
+    NewAlert: false
+    OldAlert: true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	p, err := f.NewPrometheusRule(strings.NewReader(`apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: test
+spec:
+  groups:
+  - name: test
+    rules:
+    - alert: DarkAlert
+      expr: vector(1)
+      annotations:
+        openshift.io/dark-launch: "true"
+    - alert: NewAlert
+      expr: vector(1)
+      annotations:
+        openshift.io/dark-launch: "true"
+    - alert: OldAlert
+      expr: vector(1)
+      labels:
+        severity: warning
+    - alert: OtherAlert
+      expr: vector(1)
+    - record: foo
+      expr: vector(1)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{
+		"DarkAlert":  true,
+		"NewAlert":   false,
+		"OldAlert":   true,
+		"OtherAlert": false,
+		"":           false,
+	}
+	for _, r := range p.Spec.Groups[0].Rules {
+		got := r.Labels[DarkLaunchLabel] == "true"
+		if got != expected[r.Alert] {
+			t.Fatalf("%q: expected dark launch to be %t, got %t", r.Alert+r.Record, expected[r.Alert], got)
 		}
 	}
+
+	if p.Spec.Groups[0].Rules[2].Labels["severity"] != "warning" {
+		t.Fatalf("expected severity label to be kept, got %v", p.Spec.Groups[0].Rules[2].Labels)
+	}
 }
 
-func TestPodDisruptionBudget(t *testing.T) {
-	tests := []struct {
-		name   string
-		getPDB func(f *Factory) (*policyv1.PodDisruptionBudget, error)
-		ha     bool
+func TestExcludedRules(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected map[string][]string
+		removed  map[ExcludedRule]int
+		err      bool
 	}{
 		{
-			name: "PrometheusK8s HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.PrometheusK8sPodDisruptionBudget()
-			},
-			ha: true,
-		},
-		{
-			name: "PrometheusK8s non-HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.PrometheusK8sPodDisruptionBudget()
-			},
-			ha: false,
-		},
-		{
-			name: "Alertmanager HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.AlertmanagerPodDisruptionBudget()
-			},
-			ha: true,
-		},
-		{
-			name: "Alertmanager non-HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.AlertmanagerPodDisruptionBudget()
-			},
-			ha: false,
-		},
-		{
-			name: "PrometheusAdapter HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.PrometheusAdapterPodDisruptionBudget()
-			},
-			ha: true,
-		},
-		{
-			name: "PrometheusAdapter non-HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.PrometheusAdapterPodDisruptionBudget()
-			},
-			ha: false,
-		},
-		{
-			name: "ThanosQuerier HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.ThanosQuerierPodDisruptionBudget()
+			name: "no exclusion",
+			expected: map[string][]string{
+				"general":   {"Watchdog", "InfoInhibitor", ":node_memory:sum"},
+				"kube-etcd": {"etcdMembersDown"},
 			},
-			ha: true,
+			removed: map[ExcludedRule]int{},
 		},
 		{
-			name: "ThanosQuerier non-HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.ThanosQuerierPodDisruptionBudget()
+			name: "excluded group",
+			config: `excludedRules:
+- group: general
+`,
+			expected: map[string][]string{
+				"kube-etcd": {"etcdMembersDown"},
 			},
-			ha: false,
+			removed: map[ExcludedRule]int{{Group: "general"}: 3},
 		},
 		{
-			name: "PrometheusUWM HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.PrometheusUserWorkloadPodDisruptionBudget()
+			name: "excluded alerts",
+			config: `excludedRules:
+- group: general
+  alert: InfoInhibitor
+- alert: etcdMembersDown
+- alert: MissingAlert
+`,
+			expected: map[string][]string{
+				"general": {"Watchdog", ":node_memory:sum"},
 			},
-			ha: true,
-		},
-		{
-			name: "PrometheusUWM non-HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.PrometheusUserWorkloadPodDisruptionBudget()
+			removed: map[ExcludedRule]int{
+				{Group: "general", Alert: "InfoInhibitor"}: 1,
+				{Alert: "etcdMembersDown"}:                 1,
+				{Alert: "MissingAlert"}:                    0,
 			},
-			ha: false,
 		},
 		{
-			name: "ThanosRuler HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.ThanosRulerPodDisruptionBudget()
+			name: "alert in another group",
+			config: `excludedRules:
+- group: kube-etcd
+  alert: Watchdog
+`,
+			expected: map[string][]string{
+				"general":   {"Watchdog", "InfoInhibitor", ":node_memory:sum"},
+				"kube-etcd": {"etcdMembersDown"},
 			},
-			ha: true,
+			removed: map[ExcludedRule]int{{Group: "kube-etcd", Alert: "Watchdog"}: 0},
 		},
 		{
-			name: "ThanosRuler non-HA",
-			getPDB: func(f *Factory) (*policyv1.PodDisruptionBudget, error) {
-				return f.ThanosRulerPodDisruptionBudget()
-			},
-			ha: false,
+			name: "empty exclusion",
+			config: `excludedRules:
+- {}
+`,
+			err: true,
 		},
-	}
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	for _, tc := range tests {
-		f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), &fakeInfrastructureReader{highlyAvailableInfrastructure: tc.ha}, &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-		pdb, err := tc.getPDB(f)
-		if err != nil {
-			t.Error(err)
-		}
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			p, err := f.NewPrometheusRule(strings.NewReader(`apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: test
+spec:
+  groups:
+  - name: general
+    rules:
+    - alert: Watchdog
+      expr: vector(1)
+    - alert: InfoInhibitor
+      expr: vector(1)
+    - record: ":node_memory:sum"
+      expr: vector(1)
+  - name: kube-etcd
+    rules:
+    - alert: etcdMembersDown
+      expr: vector(1)
+`))
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
 
-		if tc.ha && pdb == nil {
-			t.Error("expected PodDisruptionBudget in HA infrastructure")
-		} else if !tc.ha && pdb != nil {
-			t.Error("unexpected PodDisruptionBudget in non-HA infrastructure")
-		}
-	}
+			got := map[string][]string{}
+			for _, g := range p.Spec.Groups {
+				for _, r := range g.Rules {
+					got[g.Name] = append(got[g.Name], r.Alert+r.Record)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("expected rules %v, got %v", tc.expected, got)
+			}
 
+			if removed := f.ExcludedRules(); !reflect.DeepEqual(removed, tc.removed) {
+				t.Fatalf("expected removed rules %v, got %v", tc.removed, removed)
+			}
+		})
+	}
 }
 
-func TestPrometheusOperatorUserWorkloadConfiguration(t *testing.T) {
-	c, err := NewConfigFromString(`
-enableUserWorkload: true
+func TestThanosReceiveConfiguration(t *testing.T) {
+	c, err := NewConfigFromString(`thanosReceive:
+  enabled: true
+  replicas: 3
+  replicationFactor: 2
+  tenantHeader: X-Tenant
+  tenants:
+  - spoke-a
+  - spoke-b
+  retention: 30d
+  logLevel: debug
+  nodeSelector:
+    type: foo
+  volumeClaimTemplate:
+    spec:
+      storageClassName: fast
+      resources:
+        requests:
+          storage: 10Gi
 `)
-
-	c.SetImages(map[string]string{
-		"prometheus-operator":        "docker.io/openshift/origin-prometheus-operator:latest",
-		"prometheus-config-reloader": "docker.io/openshift/origin-prometheus-config-reloader:latest",
-		"configmap-reloader":         "docker.io/openshift/origin-configmap-reloader:latest",
-		"kube-rbac-proxy":            "docker.io/openshift/origin-kube-rbac-proxy:latest",
-	})
-
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
-	d, err := f.PrometheusOperatorUserWorkloadDeployment()
+
+	cm, err := f.ThanosReceiveHashringsConfigMap()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	prometheusReloaderFound := false
-	prometheusWebTLSCipherSuitesArg := ""
-	prometheusWebTLSVersionArg := ""
-	kubeRbacProxyTLSCipherSuitesArg := ""
-	kubeRbacProxyMinTLSVersionArg := ""
-	for _, container := range d.Spec.Template.Spec.Containers {
-		switch container.Name {
-		case "prometheus-operator":
-			if container.Image != "docker.io/openshift/origin-prometheus-operator:latest" {
-				t.Fatalf("%s image incorrectly configured", container.Name)
-			}
-			if getContainerArgValue(d.Spec.Template.Spec.Containers, PrometheusConfigReloaderFlag+"docker.io/openshift/origin-prometheus-config-reloader:latest", container.Name) != "" {
-				prometheusReloaderFound = true
-			}
+	var hashrings []thanosReceiveHashring
+	if err := json.Unmarshal([]byte(cm.Data[thanosReceiveHashringsKey]), &hashrings); err != nil {
+		t.Fatal(err)
+	}
+	expected := []thanosReceiveHashring{{
+		Hashring: "default",
+		Tenants:  []string{"spoke-a", "spoke-b"},
+		Endpoints: []string{
+			"thanos-receive-0.thanos-receive.openshift-monitoring.svc.cluster.local:10901",
+			"thanos-receive-1.thanos-receive.openshift-monitoring.svc.cluster.local:10901",
+			"thanos-receive-2.thanos-receive.openshift-monitoring.svc.cluster.local:10901",
+		},
+	}}
+	if !reflect.DeepEqual(hashrings, expected) {
+		t.Fatalf("want hashrings %+v, got %+v", expected, hashrings)
+	}
 
-			prometheusWebTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, PrometheusOperatorWebTLSCipherSuitesFlag, container.Name)
-			prometheusWebTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, PrometheusOperatorWebTLSMinTLSVersionFlag, container.Name)
+	s, err := f.ThanosReceiveStatefulSet(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "grpc-tls-foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		case "kube-rbac-proxy":
-			if container.Image != "docker.io/openshift/origin-kube-rbac-proxy:latest" {
-				t.Fatal("kube-rbac-proxy image incorrectly configured")
-			}
-			kubeRbacProxyTLSCipherSuitesArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyTLSCipherSuitesFlag, container.Name)
-			kubeRbacProxyMinTLSVersionArg = getContainerArgValue(d.Spec.Template.Spec.Containers, KubeRbacProxyMinTLSVersionFlag, container.Name)
+	if *s.Spec.Replicas != 3 {
+		t.Fatalf("want 3 replicas, got %d", *s.Spec.Replicas)
+	}
+
+	for _, arg := range []string{
+		"--receive.replication-factor=2",
+		"--receive.tenant-header=X-Tenant",
+		"--tsdb.retention=30d",
+		"--log.level=debug",
+	} {
+		if got := getContainerArgValue(s.Spec.Template.Spec.Containers, strings.SplitN(arg, "=", 2)[0]+"=", "thanos-receive"); got != arg {
+			t.Errorf("want argument %q, got %q", arg, got)
 		}
 	}
 
-	if !prometheusReloaderFound {
-		t.Fatal("Configuring the Prometheus Config reloader image failed")
+	if !reflect.DeepEqual(s.Spec.Template.Spec.NodeSelector, map[string]string{"type": "foo"}) {
+		t.Errorf("unexpected node selector %v", s.Spec.Template.Spec.NodeSelector)
 	}
 
-	expectedPrometheusWebTLSCipherSuitesArg := fmt.Sprintf("%s%s",
-		PrometheusOperatorWebTLSCipherSuitesFlag,
-		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","),
+	if len(s.Spec.VolumeClaimTemplates) != 1 || s.Spec.VolumeClaimTemplates[0].Name != thanosReceiveDataVolume {
+		t.Fatalf("expected a single %q volume claim template, got %+v", thanosReceiveDataVolume, s.Spec.VolumeClaimTemplates)
+	}
+	if sc := s.Spec.VolumeClaimTemplates[0].Spec.StorageClassName; sc == nil || *sc != "fast" {
+		t.Errorf("expected storage class %q, got %v", "fast", sc)
+	}
+
+	var grpcSecret string
+	for _, v := range s.Spec.Template.Spec.Volumes {
+		switch v.Name {
+		case thanosReceiveDataVolume:
+			t.Errorf("the %q volume should be replaced by the volume claim template", v.Name)
+		case "secret-grpc-tls":
+			grpcSecret = v.Secret.SecretName
+		}
+	}
+	if grpcSecret != "grpc-tls-foo" {
+		t.Errorf("expected the GRPC TLS secret to be mounted, got %q", grpcSecret)
+	}
+
+	d, err := f.ThanosQuerierDeployment(
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+		false,
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
 	)
-	if expectedPrometheusWebTLSCipherSuitesArg != prometheusWebTLSCipherSuitesArg {
-		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", prometheusWebTLSCipherSuitesArg, expectedPrometheusWebTLSCipherSuitesArg)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	expectedPrometheusWebTLSVersionArg := fmt.Sprintf("%s%s",
-		PrometheusOperatorWebTLSMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
-	if expectedPrometheusWebTLSVersionArg != prometheusWebTLSVersionArg {
-		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", prometheusWebTLSVersionArg, expectedPrometheusWebTLSVersionArg)
+	store := "--store=dnssrv+_grpc._tcp.thanos-receive.openshift-monitoring.svc.cluster.local"
+	if got := getContainerArgValue(d.Spec.Template.Spec.Containers, store, "thanos-query"); got != store {
+		t.Errorf("expected Thanos Querier to query Thanos Receive")
 	}
+}
 
-	expectedKubeRbacProxyTLSCipherSuitesArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyTLSCipherSuitesFlag,
-		strings.Join(crypto.OpenSSLToIANACipherSuites(APIServerDefaultTLSCiphers), ","))
+func TestThanosReceiveInvalidReplicas(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+	}{
+		{
+			name:   "no replicas",
+			config: "thanosReceive:\n  enabled: true\n  replicas: 0\n",
+		},
+		{
+			name:   "replication factor greater than replicas",
+			config: "thanosReceive:\n  enabled: true\n  replicas: 2\n  replicationFactor: 3\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	if expectedKubeRbacProxyTLSCipherSuitesArg != kubeRbacProxyTLSCipherSuitesArg {
-		t.Fatalf("incorrect TLS ciphers, \n got %s, \nwant %s", kubeRbacProxyTLSCipherSuitesArg, expectedKubeRbacProxyTLSCipherSuitesArg)
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			_, err = f.ThanosReceiveHashringsConfigMap()
+			if !errors.Is(err, ErrConfigValidation) {
+				t.Fatalf("expected a config validation error, got %v", err)
+			}
+		})
 	}
+}
 
-	expectedKubeRbacProxyMinTLSVersionArg := fmt.Sprintf("%s%s",
-		KubeRbacProxyMinTLSVersionFlag, APIServerDefaultMinTLSVersion)
-	if expectedKubeRbacProxyMinTLSVersionArg != kubeRbacProxyMinTLSVersionArg {
-		t.Fatalf("incorrect TLS version \n got %s, \nwant %s", kubeRbacProxyMinTLSVersionArg, expectedKubeRbacProxyMinTLSVersionArg)
+func TestMetricsServerConfiguration(t *testing.T) {
+	c, err := NewConfigFromString(`metricsServer:
+  enabled: true
+  nodeSelector:
+    type: infra
+  resources:
+    requests:
+      cpu: 10m
+`)
+	if err != nil {
+		t.Fatal(err)
 	}
+	c.SetImages(map[string]string{"metrics-server": "docker.io/openshift/origin-metrics-server:latest"})
 
-	d2, err := f.PrometheusOperatorUserWorkloadDeployment()
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	d, err := f.MetricsServerDeployment()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if !reflect.DeepEqual(d, d2) {
-		t.Fatal("expected PrometheusOperatorUserWorkloadDeployment to be an idempotent function")
+	container := d.Spec.Template.Spec.Containers[0]
+	if container.Image != "docker.io/openshift/origin-metrics-server:latest" {
+		t.Fatalf("unexpected image %q", container.Image)
+	}
+	if container.Resources.Requests.Cpu().String() != "10m" {
+		t.Fatalf("want 10m CPU requests, got %s", container.Resources.Requests.Cpu().String())
+	}
+	if d.Spec.Template.Spec.NodeSelector["type"] != "infra" {
+		t.Fatalf("unexpected node selector %v", d.Spec.Template.Spec.NodeSelector)
+	}
+	if getContainerArgValue(d.Spec.Template.Spec.Containers, MetricsServerTLSMinTLSVersionFlag, "metrics-server") == "" {
+		t.Fatal("expected the minimum TLS version to be set")
+	}
+
+	api, err := f.MetricsServerAPIService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adapterAPI, err := f.PrometheusAdapterAPIService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if api.Name != adapterAPI.Name {
+		t.Fatalf("expected metrics-server to replace the %s APIService, got %s", adapterAPI.Name, api.Name)
+	}
+	if api.Spec.Service.Name != "metrics-server" || api.Spec.Service.Namespace != "openshift-monitoring" {
+		t.Fatalf("unexpected service %s/%s", api.Spec.Service.Namespace, api.Spec.Service.Name)
 	}
 }
 
-func trustedCABundleVolumeConfigured(volumes []v1.Volume, volumeName string) bool {
-	for _, volume := range volumes {
-		if volume.Name == volumeName {
-			return true
-		}
+func TestPrometheusAdapterEnabled(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		expected bool
+	}{
+		{
+			name:     "default",
+			expected: true,
+		},
+		{
+			name:     "metrics-server without rules",
+			config:   "metricsServer:\n  enabled: true\n",
+			expected: false,
+		},
+		{
+			name: "metrics-server with custom metrics rules",
+			config: `metricsServer:
+  enabled: true
+k8sPrometheusAdapter:
+  customMetricsRules:
+  - seriesQuery: http_requests_total
+    metricsQuery: sum(rate(<<.Series>>{<<.LabelMatchers>>}[5m])) by (<<.GroupBy>>)
+`,
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := c.ClusterMonitoringConfiguration
+			if got := cfg.K8sPrometheusAdapter.IsEnabled(cfg.MetricsServerConfig); got != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
 	}
-	return false
 }
 
-func trustedCABundleVolumeMountsConfigured(volumeMounts []v1.VolumeMount, volumeName string) bool {
-	for _, volumeMount := range volumeMounts {
-		if volumeMount.Name == volumeName {
-			return true
-		}
+func TestUserWorkloadTenantLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		config         string
+		relabelConfigs string
+		err            bool
+	}{
+		{
+			name:           "default",
+			relabelConfigs: "[]",
+		},
+		{
+			name: "namespace",
+			config: `userWorkloadTenancy:
+  tenantLabel: namespace`,
+			relabelConfigs: "[]",
+		},
+		{
+			name: "custom label",
+			config: `userWorkloadTenancy:
+  tenantLabel: tenant`,
+			relabelConfigs: `- action: replace
+  source_labels:
+  - namespace
+  regex: (.*)
+  replacement: $1
+  target_label: tenant
+`,
+		},
+		{
+			name: "invalid label",
+			config: `userWorkloadTenancy:
+  tenantLabel: tenant-id`,
+			err: true,
+		},
+		{
+			name: "reserved label",
+			config: `userWorkloadTenancy:
+  tenantLabel: alertname`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			s, err := f.PrometheusUserWorkloadAlertRelabelConfigsSecret()
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected a validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(s.Data[AlertRelabelConfigSecretKey]); got != tc.relabelConfigs {
+				t.Fatalf("expected relabel configs %q, got %q", tc.relabelConfigs, got)
+			}
+
+			s, err = f.ThanosRulerAlertRelabelConfigsSecret()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(s.Data[AlertRelabelConfigSecretKey]); got != tc.relabelConfigs {
+				t.Fatalf("expected Thanos Ruler relabel configs %q, got %q", tc.relabelConfigs, got)
+			}
+
+			p, err := f.PrometheusUserWorkload(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr, err := f.ThanosRulerCustomResource(
+				"",
+				&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Spec.EnforcedNamespaceLabel != "namespace" || tr.Spec.EnforcedNamespaceLabel != "namespace" {
+				t.Fatalf("expected the namespace label to be enforced, got %q and %q", p.Spec.EnforcedNamespaceLabel, tr.Spec.EnforcedNamespaceLabel)
+			}
+
+			if tc.relabelConfigs == "[]" {
+				if p.Spec.AdditionalAlertRelabelConfigs != nil || tr.Spec.AlertRelabelConfigs != nil {
+					t.Fatal("expected no alert relabel configs")
+				}
+				return
+			}
+			if p.Spec.AdditionalAlertRelabelConfigs == nil || p.Spec.AdditionalAlertRelabelConfigs.Name != PrometheusUWAlertRelabelConfigSecretName {
+				t.Fatalf("expected Prometheus to reference the %s secret, got %v", PrometheusUWAlertRelabelConfigSecretName, p.Spec.AdditionalAlertRelabelConfigs)
+			}
+			if tr.Spec.AlertRelabelConfigs == nil || tr.Spec.AlertRelabelConfigs.Name != ThanosRulerAlertRelabelConfigSecretName {
+				t.Fatalf("expected Thanos Ruler to reference the %s secret, got %v", ThanosRulerAlertRelabelConfigSecretName, tr.Spec.AlertRelabelConfigs)
+			}
+		})
 	}
-	return false
 }
@@ -0,0 +1,106 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"fmt"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	promql "github.com/prometheus/prometheus/promql/parser"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	metricCompatibilityRuleName = "metric-compatibility-rules"
+
+	// metricAliasLabel is added to the recorded series so that they can be
+	// told apart from the series exposed by the components.
+	metricAliasLabel = "metric_alias"
+)
+
+// MetricAlias maps a metric name dropped by a component upgrade to the
+// expression returning the equivalent series in the new version.
+type MetricAlias struct {
+	// Component is the component which renamed the metric.
+	Component string
+	// Name is the metric name before the upgrade.
+	Name string
+	// Expr is the PromQL expression returning the series after the upgrade.
+	Expr string
+}
+
+// metricAliases lists the metrics renamed by the components shipped in this
+// release. Entries are kept for one release only and must be removed once
+// the dashboards and alerts have been given time to migrate.
+var metricAliases = []MetricAlias{}
+
+// MetricCompatibilityPrometheusRule returns the recording rules aliasing the
+// renamed metrics to their new names. The rule has no groups when there is
+// nothing to alias.
+func (f *Factory) MetricCompatibilityPrometheusRule() (*monv1.PrometheusRule, error) {
+	p, err := newMetricCompatibilityPrometheusRule(f.namespace, metricAliases)
+	if err != nil {
+		return nil, err
+	}
+	f.setOrigin(p, "cluster-monitoring-operator")
+
+	return p, nil
+}
+
+func newMetricCompatibilityPrometheusRule(namespace string, aliases []MetricAlias) (*monv1.PrometheusRule, error) {
+	var groups []monv1.RuleGroup
+	rules := make([]monv1.Rule, 0, len(aliases))
+	for _, a := range aliases {
+		if _, err := promql.ParseExpr(a.Expr); err != nil {
+			return nil, fmt.Errorf("invalid expression for metric alias %q of %s: %w", a.Name, a.Component, err)
+		}
+
+		// The alias is only recorded when the original metric isn't exposed
+		// anymore to avoid duplicate series while the component is rolled
+		// out.
+		rules = append(rules, monv1.Rule{
+			Record: a.Name,
+			Expr:   intstr.FromString(fmt.Sprintf("(%s) unless on() %s{%s=\"\"}", a.Expr, a.Name, metricAliasLabel)),
+			Labels: map[string]string{
+				metricAliasLabel: "true",
+			},
+		})
+	}
+
+	if len(rules) > 0 {
+		groups = append(groups, monv1.RuleGroup{
+			Name:  "metric-compatibility.rules",
+			Rules: rules,
+		})
+	}
+
+	return &monv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      metricCompatibilityRuleName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/component": "operator",
+				"app.kubernetes.io/name":      "cluster-monitoring-operator",
+				"app.kubernetes.io/part-of":   "openshift-monitoring",
+				"prometheus":                  "k8s",
+				"role":                        "recording-rules",
+			},
+		},
+		Spec: monv1.PrometheusRuleSpec{
+			Groups: groups,
+		},
+	}, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"testing"
+
+	promql "github.com/prometheus/prometheus/promql/parser"
+)
+
+func TestShippedMetricAliases(t *testing.T) {
+	if _, err := newMetricCompatibilityPrometheusRule("openshift-monitoring", metricAliases); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetricCompatibilityPrometheusRule(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		aliases  []MetricAlias
+		expected []string
+		err      bool
+	}{
+		{
+			name: "no alias",
+		},
+		{
+			name: "renamed metric",
+			aliases: []MetricAlias{
+				{
+					Component: "kube-state-metrics",
+					Name:      "kube_hpa_spec_max_replicas",
+					Expr:      "kube_horizontalpodautoscaler_spec_max_replicas",
+				},
+			},
+			expected: []string{
+				`(kube_horizontalpodautoscaler_spec_max_replicas) unless on() kube_hpa_spec_max_replicas{metric_alias=""}`,
+			},
+		},
+		{
+			name: "invalid expression",
+			aliases: []MetricAlias{
+				{
+					Component: "node-exporter",
+					Name:      "node_foo",
+					Expr:      "node_bar{",
+				},
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newMetricCompatibilityPrometheusRule("openshift-monitoring", tc.aliases)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(tc.expected) == 0 {
+				if len(r.Spec.Groups) != 0 {
+					t.Fatalf("expected no rule group, got %d", len(r.Spec.Groups))
+				}
+				return
+			}
+
+			rules := r.Spec.Groups[0].Rules
+			if len(rules) != len(tc.expected) {
+				t.Fatalf("expected %d rules, got %d", len(tc.expected), len(rules))
+			}
+			for i, rule := range rules {
+				if rule.Record != tc.aliases[i].Name {
+					t.Fatalf("expected record %q, got %q", tc.aliases[i].Name, rule.Record)
+				}
+				if rule.Expr.String() != tc.expected[i] {
+					t.Fatalf("expected expression %q, got %q", tc.expected[i], rule.Expr.String())
+				}
+				if _, err := promql.ParseExpr(rule.Expr.String()); err != nil {
+					t.Fatalf("invalid expression %q: %v", rule.Expr.String(), err)
+				}
+				if rule.Labels[metricAliasLabel] != "true" {
+					t.Fatalf("expected %s label, got %v", metricAliasLabel, rule.Labels)
+				}
+			}
+		})
+	}
+}
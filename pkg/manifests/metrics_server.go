@@ -0,0 +1,163 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"fmt"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+)
+
+var (
+	MetricsServerTLSCipherSuitesFlag  = "--tls-cipher-suites="
+	MetricsServerTLSMinTLSVersionFlag = "--tls-min-version="
+)
+
+func (f *Factory) MetricsServerServiceAccount() (*v1.ServiceAccount, error) {
+	sa, err := f.NewServiceAccount(f.assets.MustNewAssetReader(MetricsServerServiceAccount))
+	if err != nil {
+		return nil, err
+	}
+
+	sa.Namespace = f.namespace
+
+	return sa, nil
+}
+
+func (f *Factory) MetricsServerClusterRole() (*rbacv1.ClusterRole, error) {
+	return f.NewClusterRole(f.assets.MustNewAssetReader(MetricsServerClusterRole))
+}
+
+func (f *Factory) MetricsServerClusterRoleBinding() (*rbacv1.ClusterRoleBinding, error) {
+	crb, err := f.NewClusterRoleBinding(f.assets.MustNewAssetReader(MetricsServerClusterRoleBinding))
+	if err != nil {
+		return nil, err
+	}
+
+	crb.Subjects[0].Namespace = f.namespace
+
+	return crb, nil
+}
+
+func (f *Factory) MetricsServerClusterRoleBindingAuthDelegator() (*rbacv1.ClusterRoleBinding, error) {
+	crb, err := f.NewClusterRoleBinding(f.assets.MustNewAssetReader(MetricsServerClusterRoleBindingAuthDelegator))
+	if err != nil {
+		return nil, err
+	}
+
+	crb.Subjects[0].Namespace = f.namespace
+
+	return crb, nil
+}
+
+func (f *Factory) MetricsServerRoleBindingAuthReader() (*rbacv1.RoleBinding, error) {
+	rb, err := f.NewRoleBinding(f.assets.MustNewAssetReader(MetricsServerRoleBindingAuthReader))
+	if err != nil {
+		return nil, err
+	}
+
+	rb.Subjects[0].Namespace = f.namespace
+
+	return rb, nil
+}
+
+func (f *Factory) MetricsServerService() (*v1.Service, error) {
+	s, err := f.NewService(f.assets.MustNewAssetReader(MetricsServerService))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespace
+
+	return s, nil
+}
+
+func (f *Factory) MetricsServerDeployment() (*appsv1.Deployment, error) {
+	dep, err := f.NewDeployment(f.assets.MustNewAssetReader(MetricsServerDeployment))
+	if err != nil {
+		return nil, err
+	}
+
+	dep.Namespace = f.namespace
+
+	cfg := f.config.ClusterMonitoringConfiguration.MetricsServerConfig
+	spec := &dep.Spec.Template.Spec
+	for i, c := range spec.Containers {
+		if c.Name != "metrics-server" {
+			continue
+		}
+
+		spec.Containers[i].Image = f.config.Images.MetricsServer
+		spec.Containers[i].Args = f.setTLSSecurityConfiguration(c.Args, MetricsServerTLSCipherSuitesFlag, MetricsServerTLSMinTLSVersionFlag)
+
+		if cfg.Resources != nil {
+			spec.Containers[i].Resources = *cfg.Resources
+		}
+	}
+
+	if len(cfg.NodeSelector) > 0 {
+		spec.NodeSelector = cfg.NodeSelector
+	}
+
+	if len(cfg.Tolerations) > 0 {
+		spec.Tolerations = cfg.Tolerations
+	}
+
+	return dep, nil
+}
+
+func (f *Factory) MetricsServerPodDisruptionBudget() (*policyv1.PodDisruptionBudget, error) {
+	pdb, err := f.NewPodDisruptionBudget(f.assets.MustNewAssetReader(MetricsServerPodDisruptionBudget))
+	if err != nil {
+		return nil, err
+	}
+
+	if pdb != nil {
+		pdb.Namespace = f.namespace
+	}
+
+	return pdb, nil
+}
+
+func (f *Factory) MetricsServerServiceMonitor() (*monv1.ServiceMonitor, error) {
+	sm, err := f.NewServiceMonitor(f.assets.MustNewAssetReader(MetricsServerServiceMonitor))
+	if err != nil {
+		return nil, err
+	}
+
+	sm.Namespace = f.namespace
+	sm.Spec.Endpoints[0].TLSConfig.ServerName = fmt.Sprintf("metrics-server.%s.svc", f.namespace)
+
+	return sm, nil
+}
+
+// MetricsServerAPIService returns the APIService of the resource metrics API
+// served by metrics-server. It has the same name as the APIService of
+// prometheus-adapter, creating it moves the API to metrics-server.
+func (f *Factory) MetricsServerAPIService() (*apiregistrationv1.APIService, error) {
+	a, err := f.NewAPIService(f.assets.MustNewAssetReader(MetricsServerAPIService))
+	if err != nil {
+		return nil, err
+	}
+
+	a.Spec.Service.Namespace = f.namespace
+
+	return a, nil
+}
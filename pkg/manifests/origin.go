@@ -0,0 +1,88 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"strings"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// OriginLabel is set on the PrometheusRules and the dashboards shipped
+	// by the operator. Its value is the component shipping the object
+	// (e.g. "alertmanager") so that the rules and dashboards deployed by
+	// the different OpenShift operators can be told apart.
+	OriginLabel = "monitoring.openshift.io/origin"
+	// OriginVersionLabel is set next to OriginLabel, its value is the
+	// version of the operator which deployed the object.
+	OriginVersionLabel = "monitoring.openshift.io/origin-version"
+)
+
+// setOrigin labels the object with the component shipping it and the version
+// of the operator.
+func (f *Factory) setOrigin(obj metav1.Object, component string) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[OriginLabel] = component
+	if v := originLabelValue(f.config.OperatorVersion); v != "" {
+		labels[OriginVersionLabel] = v
+	}
+	obj.SetLabels(labels)
+}
+
+// newShippedPrometheusRule returns the PrometheusRule of the asset labeled
+// with its origin. The component is the directory holding the asset (e.g.
+// "alertmanager" for "alertmanager/prometheus-rule.yaml").
+func (f *Factory) newShippedPrometheusRule(asset string) (*monv1.PrometheusRule, error) {
+	p, err := f.NewPrometheusRule(f.assets.MustNewAssetReader(asset))
+	if err != nil {
+		return nil, err
+	}
+
+	component := asset
+	if i := strings.IndexByte(asset, '/'); i >= 0 {
+		component = asset[:i]
+	}
+	f.setOrigin(p, component)
+
+	return p, nil
+}
+
+// originLabelValue turns the version into a valid label value. The
+// characters not allowed in label values (e.g. "+" in semantic versions) are
+// replaced by "_".
+func originLabelValue(v string) string {
+	if len(validation.IsValidLabelValue(v)) == 0 {
+		return v
+	}
+
+	v = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, v)
+	if len(v) > validation.LabelValueMaxLength {
+		v = v[:validation.LabelValueMaxLength]
+	}
+
+	return strings.Trim(v, "-_.")
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"testing"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+)
+
+func TestShippedPrometheusRulesOrigin(t *testing.T) {
+	c := NewDefaultConfig()
+	c.OperatorVersion = "4.11.0+abc"
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	for _, tc := range []struct {
+		component string
+		rule      func() (*monv1.PrometheusRule, error)
+	}{
+		{component: "alertmanager", rule: f.AlertmanagerPrometheusRule},
+		{component: "prometheus-k8s", rule: f.PrometheusK8sThanosSidecarPrometheusRule},
+		{component: "control-plane", rule: f.ControlPlanePrometheusRule},
+		{component: "cluster-monitoring-operator", rule: f.MetricCompatibilityPrometheusRule},
+	} {
+		t.Run(tc.component, func(t *testing.T) {
+			r, err := tc.rule()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := r.Labels[OriginLabel]; got != tc.component {
+				t.Fatalf("expected origin %q, got %q", tc.component, got)
+			}
+			if got := r.Labels[OriginVersionLabel]; got != "4.11.0_abc" {
+				t.Fatalf("expected origin version %q, got %q", "4.11.0_abc", got)
+			}
+		})
+	}
+
+	kept, _, err := f.ConsoleDashboards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cm := range kept.Items {
+		if got := cm.Labels[OriginLabel]; got != "cluster-monitoring-operator" {
+			t.Fatalf("expected origin of dashboard %s to be cluster-monitoring-operator, got %q", cm.Name, got)
+		}
+	}
+}
+
+func TestOriginLabelValue(t *testing.T) {
+	for _, tc := range []struct {
+		version  string
+		expected string
+	}{
+		{version: "", expected: ""},
+		{version: "4.11.0-0.nightly-2022-06-01-123456", expected: "4.11.0-0.nightly-2022-06-01-123456"},
+		{version: "v1.2.3+build.5", expected: "v1.2.3_build.5"},
+		{version: "+1.0-", expected: "1.0"},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			if got := originLabelValue(tc.version); got != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
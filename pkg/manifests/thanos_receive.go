@@ -0,0 +1,298 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"encoding/json"
+	"fmt"
+
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/pkg/errors"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	thanosReceiveDefaultTenantHeader = "THANOS-TENANT"
+	thanosReceiveHashringsKey        = "hashrings.json"
+	thanosReceiveDataVolume          = "thanos-receive-data"
+)
+
+// thanosReceiveHashring mirrors an entry of the Thanos Receive hashrings
+// file.
+type thanosReceiveHashring struct {
+	Hashring  string   `json:"hashring"`
+	Tenants   []string `json:"tenants,omitempty"`
+	Endpoints []string `json:"endpoints"`
+}
+
+// thanosReceiveReplicas returns the number of replicas and the replication
+// factor of Thanos Receive after applying the defaults.
+func thanosReceiveReplicas(cfg *ThanosReceiveConfig) (int32, int32, error) {
+	replicas, factor := int32(1), int32(1)
+	if cfg.Replicas != nil {
+		replicas = *cfg.Replicas
+	}
+	if cfg.ReplicationFactor != nil {
+		factor = *cfg.ReplicationFactor
+	}
+
+	if replicas < 1 {
+		return 0, 0, fmt.Errorf("%w - thanosReceive replicas must be at least 1: %d", ErrConfigValidation, replicas)
+	}
+	if factor < 1 || factor > replicas {
+		return 0, 0, fmt.Errorf("%w - thanosReceive replicationFactor must be between 1 and the number of replicas (%d): %d", ErrConfigValidation, replicas, factor)
+	}
+
+	return replicas, factor, nil
+}
+
+func (f *Factory) ThanosReceiveServiceAccount() (*v1.ServiceAccount, error) {
+	s, err := f.NewServiceAccount(f.assets.MustNewAssetReader(ThanosReceiveServiceAccount))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespace
+
+	return s, nil
+}
+
+func (f *Factory) ThanosReceiveClusterRole() (*rbacv1.ClusterRole, error) {
+	return f.NewClusterRole(f.assets.MustNewAssetReader(ThanosReceiveClusterRole))
+}
+
+func (f *Factory) ThanosReceiveClusterRoleBinding() (*rbacv1.ClusterRoleBinding, error) {
+	crb, err := f.NewClusterRoleBinding(f.assets.MustNewAssetReader(ThanosReceiveClusterRoleBinding))
+	if err != nil {
+		return nil, err
+	}
+
+	crb.Subjects[0].Namespace = f.namespace
+
+	return crb, nil
+}
+
+// ThanosReceiveRemoteWriterClusterRole returns the ClusterRole granting
+// permission to push metrics to Thanos Receive. It needs to be bound in the
+// monitoring namespace to the identity used by the remote clusters.
+func (f *Factory) ThanosReceiveRemoteWriterClusterRole() (*rbacv1.ClusterRole, error) {
+	return f.NewClusterRole(f.assets.MustNewAssetReader(ThanosReceiveRemoteWriterClusterRole))
+}
+
+func (f *Factory) ThanosReceiveRBACProxySecret() (*v1.Secret, error) {
+	s, err := f.NewSecret(f.assets.MustNewAssetReader(ThanosReceiveRBACProxySecret))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespace
+
+	return s, nil
+}
+
+func (f *Factory) ThanosReceiveRBACProxyMetricsSecret() (*v1.Secret, error) {
+	s, err := f.NewSecret(f.assets.MustNewAssetReader(ThanosReceiveRBACProxyMetricsSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespace
+
+	return s, nil
+}
+
+func (f *Factory) ThanosReceiveGrpcTLSSecret() (*v1.Secret, error) {
+	s, err := f.NewSecret(f.assets.MustNewAssetReader(ThanosReceiveGrpcTLSSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespace
+
+	return s, nil
+}
+
+// ThanosReceiveHashringsConfigMap returns the ConfigMap holding the hashring
+// of the Thanos Receive replicas. The hashring is restricted to the
+// configured tenants, if any, so that writes for other tenants are rejected.
+func (f *Factory) ThanosReceiveHashringsConfigMap() (*v1.ConfigMap, error) {
+	cfg := f.config.ClusterMonitoringConfiguration.ThanosReceiveConfig
+	replicas, _, err := thanosReceiveReplicas(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cm, err := f.NewConfigMap(f.assets.MustNewAssetReader(ThanosReceiveHashringsConfigMap))
+	if err != nil {
+		return nil, err
+	}
+
+	cm.Namespace = f.namespace
+
+	hashring := thanosReceiveHashring{
+		Hashring:  "default",
+		Tenants:   cfg.Tenants,
+		Endpoints: make([]string, 0, replicas),
+	}
+	for i := int32(0); i < replicas; i++ {
+		hashring.Endpoints = append(hashring.Endpoints, fmt.Sprintf("thanos-receive-%d.thanos-receive.%s.svc.cluster.local:10901", i, f.namespace))
+	}
+
+	b, err := json.Marshal([]thanosReceiveHashring{hashring})
+	if err != nil {
+		return nil, err
+	}
+	cm.Data[thanosReceiveHashringsKey] = string(b)
+
+	return cm, nil
+}
+
+func (f *Factory) ThanosReceiveService() (*v1.Service, error) {
+	s, err := f.NewService(f.assets.MustNewAssetReader(ThanosReceiveService))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespace
+
+	return s, nil
+}
+
+func (f *Factory) ThanosReceiveRoute() (*routev1.Route, error) {
+	r, err := f.NewRoute(f.assets.MustNewAssetReader(ThanosReceiveRoute))
+	if err != nil {
+		return nil, err
+	}
+
+	r.Namespace = f.namespace
+
+	return r, nil
+}
+
+func (f *Factory) ThanosReceiveServiceMonitor() (*monv1.ServiceMonitor, error) {
+	sm, err := f.NewServiceMonitor(f.assets.MustNewAssetReader(ThanosReceiveServiceMonitor))
+	if err != nil {
+		return nil, err
+	}
+
+	var found bool
+	const endpointPort = "metrics"
+	for i := range sm.Spec.Endpoints {
+		if sm.Spec.Endpoints[i].Port == endpointPort {
+			found = true
+			sm.Spec.Endpoints[i].TLSConfig.ServerName = fmt.Sprintf("thanos-receive.%s.svc", f.namespace)
+		}
+	}
+	if !found {
+		return nil, errors.Errorf("failed to find endpoint port %q", endpointPort)
+	}
+
+	sm.Namespace = f.namespace
+
+	return sm, nil
+}
+
+// ThanosReceiveStatefulSet returns the Thanos Receive StatefulSet mounting
+// the given GRPC TLS secret.
+func (f *Factory) ThanosReceiveStatefulSet(grpcTLS *v1.Secret) (*appsv1.StatefulSet, error) {
+	cfg := f.config.ClusterMonitoringConfiguration.ThanosReceiveConfig
+	replicas, factor, err := thanosReceiveReplicas(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewStatefulSet(f.assets.MustNewAssetReader(ThanosReceiveStatefulSet))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Namespace = f.namespace
+	s.Spec.Replicas = &replicas
+
+	tenantHeader := cfg.TenantHeader
+	if tenantHeader == "" {
+		tenantHeader = thanosReceiveDefaultTenantHeader
+	}
+	retention := cfg.Retention
+	if retention == "" {
+		retention = DefaultRetentionValue
+	}
+
+	for i, c := range s.Spec.Template.Spec.Containers {
+		switch c.Name {
+		case "thanos-receive":
+			s.Spec.Template.Spec.Containers[i].Image = f.config.Images.Thanos
+			s.Spec.Template.Spec.Containers[i].Args = append(
+				s.Spec.Template.Spec.Containers[i].Args,
+				fmt.Sprintf("--receive.replication-factor=%d", factor),
+				fmt.Sprintf("--receive.tenant-header=%s", tenantHeader),
+				fmt.Sprintf("--tsdb.retention=%s", retention),
+			)
+
+			if cfg.LogLevel != "" {
+				s.Spec.Template.Spec.Containers[i].Args = append(s.Spec.Template.Spec.Containers[i].Args, fmt.Sprintf("--log.level=%s", cfg.LogLevel))
+			}
+			if cfg.Resources != nil {
+				s.Spec.Template.Spec.Containers[i].Resources = *cfg.Resources
+			}
+
+		case "kube-rbac-proxy", "kube-rbac-proxy-metrics":
+			s.Spec.Template.Spec.Containers[i].Image = f.config.Images.KubeRbacProxy
+			s.Spec.Template.Spec.Containers[i].Args = f.setTLSSecurityConfiguration(c.Args, KubeRbacProxyTLSCipherSuitesFlag, KubeRbacProxyMinTLSVersionFlag)
+		}
+	}
+
+	if grpcTLS != nil {
+		s.Spec.Template.Spec.Volumes = append(s.Spec.Template.Spec.Volumes, v1.Volume{
+			Name: "secret-grpc-tls",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: grpcTLS.GetName(),
+				},
+			},
+		})
+	}
+
+	if cfg.VolumeClaimTemplate != nil {
+		volumes := s.Spec.Template.Spec.Volumes[:0]
+		for _, v := range s.Spec.Template.Spec.Volumes {
+			if v.Name != thanosReceiveDataVolume {
+				volumes = append(volumes, v)
+			}
+		}
+		s.Spec.Template.Spec.Volumes = volumes
+
+		pvc := v1.PersistentVolumeClaim{
+			Spec: cfg.VolumeClaimTemplate.Spec,
+		}
+		pvc.Name = thanosReceiveDataVolume
+		pvc.Labels = cfg.VolumeClaimTemplate.Labels
+		pvc.Annotations = cfg.VolumeClaimTemplate.Annotations
+		s.Spec.VolumeClaimTemplates = []v1.PersistentVolumeClaim{pvc}
+	}
+
+	if cfg.NodeSelector != nil {
+		s.Spec.Template.Spec.NodeSelector = cfg.NodeSelector
+	}
+
+	if len(cfg.Tolerations) > 0 {
+		s.Spec.Template.Spec.Tolerations = cfg.Tolerations
+	}
+
+	return s, nil
+}
@@ -98,6 +98,48 @@ func (f *Factory) UserWorkloadMetricsClientCACM(apiAuthConfigmap *v1.ConfigMap)
 	return cm, nil
 }
 
+const (
+	// GRPCTLSForcedRotateAnnotation requests the immediate renewal of the
+	// GRPC TLS CA and certificates. The CA keeps its private key so that the
+	// old and new certificates remain valid for each other.
+	GRPCTLSForcedRotateAnnotation = "monitoring.openshift.io/grpc-tls-forced-rotate"
+	// GRPCTLSForcedKeyRotateAnnotation requests the replacement of the GRPC
+	// TLS CA private key. The replacement is staged over several overlap
+	// periods, see RotateGRPCSecret.
+	GRPCTLSForcedKeyRotateAnnotation = "monitoring.openshift.io/grpc-tls-forced-key-rotate"
+	// GRPCTLSRotationPhaseAnnotation records the current phase of a staged
+	// CA replacement.
+	GRPCTLSRotationPhaseAnnotation = "monitoring.openshift.io/grpc-tls-rotation-phase"
+	// GRPCTLSRotationPhaseStartAnnotation records when the current phase of
+	// a staged CA replacement started (RFC 3339).
+	GRPCTLSRotationPhaseStartAnnotation = "monitoring.openshift.io/grpc-tls-rotation-phase-start"
+
+	// GRPCTLSRotationPhaseTrust is the phase during which the new CA is
+	// distributed next to the previous one while the client and server
+	// certificates are still issued by the previous CA.
+	GRPCTLSRotationPhaseTrust = "trust"
+	// GRPCTLSRotationPhaseReissue is the phase during which the client and
+	// server certificates are issued by the new CA while the previous CA is
+	// still trusted.
+	GRPCTLSRotationPhaseReissue = "reissue"
+
+	// grpcTLSRotationOverlap is the minimum duration of each phase of a
+	// staged CA replacement. It leaves enough time to all the Thanos
+	// components (querier, sidecars, ruler and receive) to roll out the
+	// updated secrets before the next phase starts, including Prometheus
+	// pods replaying a large WAL.
+	grpcTLSRotationOverlap = 6 * time.Hour
+)
+
+// grpcTLSCertificates maps the GRPC TLS client and server certificates to
+// their private keys in the GRPC TLS secret.
+var grpcTLSCertificates = []struct {
+	crt, key string
+}{
+	{crt: "thanos-querier-client.crt", key: "thanos-querier-client.key"},
+	{crt: "prometheus-server.crt", key: "prometheus-server.key"},
+}
+
 // RotateGRPCSecret rotates key material for Thanos GRPC TLS based communication.
 //
 // If no key material is present, it creates it.
@@ -112,53 +154,61 @@ func (f *Factory) UserWorkloadMetricsClientCACM(apiAuthConfigmap *v1.ConfigMap)
 //    as they are being mounted into multiple pods reachable externally i.e. via routes.
 //    This is addressed by re-issuing them at the same time the CA expires.
 // 3. The CA's private key is left out of the thread model as it is not mounted in any pod.
-//    This implementation assumes it can stay immutable and does not need rotation.
+//    This implementation assumes it can stay immutable and only replaces it on
+//    request or when it can't be read anymore.
+//
+// Since the Thanos components reload the certificates one after the other,
+// any rotation must keep the old and new certificates valid for each other.
+// Renewing the CA with the same private key guarantees it. When the private
+// key has to be replaced (because it can't be read anymore or because the
+// GRPCTLSForcedKeyRotateAnnotation annotation is set), the readable CA
+// certificates stay in the ca.crt bundle and the replacement goes through
+// the following phases, each lasting at least grpcTLSRotationOverlap:
+//
+// 1. "trust": ca.crt holds the new and the previous CA certificates, the
+//    client and server certificates are unchanged.
+// 2. "reissue": the client and server certificates are issued by the new CA.
+// 3. The previous CA certificates are removed from ca.crt.
 func RotateGRPCSecret(s *v1.Secret) error {
-	var (
-		curCA, newCA              *crypto.CA
-		curCABytes, crtPresent    = s.Data["ca.crt"]
-		curCAKeyBytes, keyPresent = s.Data["ca.key"]
-		rotate                    = !crtPresent || !keyPresent
-	)
+	return rotateGRPCSecret(s, time.Now)
+}
 
-	if crtPresent && keyPresent {
-		var err error
-		curCA, err = crypto.GetCAFromBytes(curCABytes, curCAKeyBytes)
-		if err != nil {
-			klog.Warningf("generating a new CA due to error reading CA: %v", err)
-			rotate = true
-		} else if needsNewCert(curCA.Config.Certs[0].NotBefore, curCA.Config.Certs[0].NotAfter, time.Now) {
-			rotate = true
-		}
+func rotateGRPCSecret(s *v1.Secret, now func() time.Time) error {
+	if s.Data == nil {
+		s.Data = make(map[string][]byte)
 	}
+	if s.Annotations == nil {
+		s.Annotations = make(map[string]string)
+	}
+
+	_, forced := s.Annotations[GRPCTLSForcedRotateAnnotation]
+	delete(s.Annotations, GRPCTLSForcedRotateAnnotation)
 
-	if _, ok := s.Annotations["monitoring.openshift.io/grpc-tls-forced-rotate"]; ok {
-		rotate = true
-		delete(s.Annotations, "monitoring.openshift.io/grpc-tls-forced-rotate")
+	curCA, err := crypto.GetCAFromBytes(s.Data["ca.crt"], s.Data["ca.key"])
+	if err != nil {
+		if len(s.Data["ca.crt"]) > 0 || len(s.Data["ca.key"]) > 0 {
+			klog.Warningf("generating a new CA due to error reading CA: %v", err)
+		}
+		return replaceGRPCCA(s, unexpiredCertificates(s.Data["ca.crt"], now()), now)
 	}
 
-	if !rotate {
-		return nil
+	phase := s.Annotations[GRPCTLSRotationPhaseAnnotation]
+	if _, ok := s.Annotations[GRPCTLSForcedKeyRotateAnnotation]; ok && phase == "" {
+		delete(s.Annotations, GRPCTLSForcedKeyRotateAnnotation)
+		return replaceGRPCCA(s, curCA.Config.Certs, now)
 	}
 
-	if curCA == nil {
-		newCAConfig, err := crypto.MakeSelfSignedCAConfig(
-			fmt.Sprintf("%s@%d", "openshift-cluster-monitoring", time.Now().Unix()),
-			crypto.DefaultCertificateLifetimeInDays,
-		)
-		if err != nil {
-			return errors.Wrap(err, "error generating self signed CA")
-		}
+	var (
+		certs   = curCA.Config.Certs
+		renew   = forced || needsNewCert(certs[0].NotBefore, certs[0].NotAfter, now)
+		reissue bool
+		changed bool
+	)
 
-		newCA = &crypto.CA{
-			SerialGenerator: &crypto.RandomSerialGenerator{},
-			Config:          newCAConfig,
-		}
-	} else {
-		template := curCA.Config.Certs[0]
-		now := time.Now()
-		template.NotBefore = now.Add(-1 * time.Second)
-		template.NotAfter = now.Add(certificateLifetime)
+	if renew {
+		template := certs[0]
+		template.NotBefore = now().Add(-1 * time.Second)
+		template.NotAfter = now().Add(certificateLifetime)
 		template.SerialNumber = template.SerialNumber.Add(template.SerialNumber, big.NewInt(1))
 
 		newCACert, err := createCertificate(template, template, template.PublicKey, curCA.Config.Key)
@@ -166,25 +216,104 @@ func RotateGRPCSecret(s *v1.Secret) error {
 			return errors.Wrap(err, "error rotating CA")
 		}
 
-		newCA = &crypto.CA{
-			SerialGenerator: &crypto.RandomSerialGenerator{},
-			Config: &crypto.TLSCertificateConfig{
-				Certs: []*x509.Certificate{newCACert},
-				Key:   curCA.Config.Key,
-			},
+		// Certificates issued by the previous CA certificates remain
+		// valid since the private key doesn't change.
+		certs = append([]*x509.Certificate{newCACert}, certs[1:]...)
+		changed = true
+		// The client and server certificates must still be issued by the
+		// previous CA until the new CA is trusted by all components.
+		reissue = phase != GRPCTLSRotationPhaseTrust
+	}
+
+	if phase != "" && grpcTLSRotationPhaseElapsed(s, now) {
+		switch phase {
+		case GRPCTLSRotationPhaseTrust:
+			setGRPCTLSRotationPhase(s, GRPCTLSRotationPhaseReissue, now)
+			reissue = true
+		default:
+			delete(s.Annotations, GRPCTLSRotationPhaseAnnotation)
+			delete(s.Annotations, GRPCTLSRotationPhaseStartAnnotation)
+			certs = certs[:1]
+			changed = true
+		}
+	}
+
+	if !reissue && phase != GRPCTLSRotationPhaseTrust {
+		reissue = !validGRPCCertificates(s, certs, now())
+	}
+
+	if changed {
+		b, err := crypto.EncodeCertificates(certs...)
+		if err != nil {
+			return errors.Wrap(err, "error getting PEM bytes from CA")
 		}
+		s.Data["ca.crt"] = b
+	}
+
+	if !reissue {
+		return nil
+	}
+
+	return issueGRPCCertificates(s, &crypto.CA{
+		SerialGenerator: &crypto.RandomSerialGenerator{},
+		Config: &crypto.TLSCertificateConfig{
+			Certs: certs[:1],
+			Key:   curCA.Config.Key,
+		},
+	})
+}
+
+// replaceGRPCCA generates a CA with a new private key. The previous CA
+// certificates are kept in the ca.crt bundle until the client and server
+// certificates issued by the new CA have been rolled out.
+func replaceGRPCCA(s *v1.Secret, previous []*x509.Certificate, now func() time.Time) error {
+	newCAConfig, err := crypto.MakeSelfSignedCAConfig(
+		fmt.Sprintf("%s@%d", "openshift-cluster-monitoring", now().Unix()),
+		crypto.DefaultCertificateLifetimeInDays,
+	)
+	if err != nil {
+		return errors.Wrap(err, "error generating self signed CA")
+	}
+
+	newCA := &crypto.CA{
+		SerialGenerator: &crypto.RandomSerialGenerator{},
+		Config:          newCAConfig,
 	}
 
-	newCABytes, newCAKeyBytes, err := newCA.Config.GetPEMBytes()
+	certs := append([]*x509.Certificate{newCAConfig.Certs[0]}, previous...)
+	crt, err := crypto.EncodeCertificates(certs...)
+	if err != nil {
+		return errors.Wrap(err, "error getting PEM bytes from CA")
+	}
+	_, key, err := newCAConfig.GetPEMBytes()
 	if err != nil {
 		return errors.Wrap(err, "error getting PEM bytes from CA")
 	}
 
-	s.Data["ca.crt"] = newCABytes
-	s.Data["ca.key"] = newCAKeyBytes
+	s.Data["ca.crt"] = crt
+	s.Data["ca.key"] = key
+
+	switch {
+	case len(previous) == 0:
+		// Nothing can be kept trusted, the new key material is used
+		// right away.
+		delete(s.Annotations, GRPCTLSRotationPhaseAnnotation)
+		delete(s.Annotations, GRPCTLSRotationPhaseStartAnnotation)
+	case validGRPCCertificates(s, previous, now()):
+		setGRPCTLSRotationPhase(s, GRPCTLSRotationPhaseTrust, now)
+		return nil
+	default:
+		setGRPCTLSRotationPhase(s, GRPCTLSRotationPhaseReissue, now)
+	}
 
+	return issueGRPCCertificates(s, newCA)
+}
+
+// issueGRPCCertificates issues the client and server certificates from the
+// given CA.
+func issueGRPCCertificates(s *v1.Secret, ca *crypto.CA) error {
 	{
-		cfg, err := newCA.MakeClientCertificateForDuration(
+		cfg, err := ca.MakeClientCertificateForDuration(
 			&user.DefaultInfo{
 				Name: "thanos-querier",
 			},
@@ -203,7 +332,7 @@ func RotateGRPCSecret(s *v1.Secret) error {
 	}
 
 	{
-		cfg, err := newCA.MakeServerCert(
+		cfg, err := ca.MakeServerCert(
 			sets.NewString("prometheus-grpc"),
 			crypto.DefaultCertificateLifetimeInDays,
 		)
@@ -222,6 +351,95 @@ func RotateGRPCSecret(s *v1.Secret) error {
 	return nil
 }
 
+// validGRPCCertificates returns true if the client and server certificates
+// are readable, aren't due for renewal and are trusted by the given CA
+// certificates.
+func validGRPCCertificates(s *v1.Secret, cas []*x509.Certificate, now time.Time) bool {
+	roots := x509.NewCertPool()
+	for _, ca := range cas {
+		roots.AddCert(ca)
+	}
+
+	for _, c := range grpcTLSCertificates {
+		cfg, err := crypto.GetTLSCertificateConfigFromBytes(s.Data[c.crt], s.Data[c.key])
+		if err != nil {
+			return false
+		}
+
+		crt := cfg.Certs[0]
+		if needsNewCert(crt.NotBefore, crt.NotAfter, func() time.Time { return now }) {
+			return false
+		}
+
+		if _, err := crt.Verify(x509.VerifyOptions{
+			Roots:       roots,
+			CurrentTime: now,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unexpiredCertificates returns the certificates from the PEM bundle which
+// are still valid. Unreadable data is ignored.
+func unexpiredCertificates(b []byte, now time.Time) []*x509.Certificate {
+	if len(b) == 0 {
+		return nil
+	}
+
+	certs, err := crypto.CertsFromPEM(b)
+	if err != nil {
+		return nil
+	}
+
+	var ret []*x509.Certificate
+	for _, c := range certs {
+		if now.Before(c.NotAfter) {
+			ret = append(ret, c)
+		}
+	}
+
+	return ret
+}
+
+func setGRPCTLSRotationPhase(s *v1.Secret, phase string, now func() time.Time) {
+	s.Annotations[GRPCTLSRotationPhaseAnnotation] = phase
+	s.Annotations[GRPCTLSRotationPhaseStartAnnotation] = now().UTC().Format(time.RFC3339)
+}
+
+// grpcTLSRotationPhaseElapsed returns true when the current phase has lasted
+// at least grpcTLSRotationOverlap. An unreadable start time restarts the
+// phase.
+func grpcTLSRotationPhaseElapsed(s *v1.Secret, now func() time.Time) bool {
+	start, err := time.Parse(time.RFC3339, s.Annotations[GRPCTLSRotationPhaseStartAnnotation])
+	if err != nil {
+		klog.Warningf("restarting GRPC TLS rotation phase %q due to invalid start time: %v", s.Annotations[GRPCTLSRotationPhaseAnnotation], err)
+		s.Annotations[GRPCTLSRotationPhaseStartAnnotation] = now().UTC().Format(time.RFC3339)
+		return false
+	}
+
+	return !now().Before(start.Add(grpcTLSRotationOverlap))
+}
+
+// GRPCCertificateExpirations returns the expiration time of the current CA
+// and of the client and server certificates stored in the GRPC TLS secret,
+// keyed by the name of the certificate. Unreadable certificates are omitted.
+func GRPCCertificateExpirations(s *v1.Secret) map[string]time.Time {
+	ret := make(map[string]time.Time)
+	for _, name := range []string{"ca", "thanos-querier-client", "prometheus-server"} {
+		certs, err := crypto.CertsFromPEM(s.Data[name+".crt"])
+		if err != nil {
+			continue
+		}
+		ret[name] = certs[0].NotAfter
+	}
+
+	return ret
+}
+
 // createCertificate creates a new certificate and returns it in x509.Certificate form.
 func createCertificate(template, parent *x509.Certificate, pub, priv interface{}) (*x509.Certificate, error) {
 	rawCert, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
@@ -325,3 +325,192 @@ func assertCertValidityWithCa(cert []byte, ca []byte) error {
 	}
 	return nil
 }
+
+func TestGRPCKeyRotation(t *testing.T) {
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	for _, tc := range []struct {
+		name  string
+		setup func(*v1.Secret)
+	}{
+		{
+			name: "forced key rotation",
+			setup: func(s *v1.Secret) {
+				s.Annotations[GRPCTLSForcedKeyRotateAnnotation] = "true"
+			},
+		},
+		{
+			name: "unreadable CA key",
+			setup: func(s *v1.Secret) {
+				s.Data["ca.key"] = []byte("broken key")
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			at := func(d time.Duration) func() time.Time {
+				return func() time.Time { return start.Add(d) }
+			}
+
+			s, err := f.GRPCSecret()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := rotateGRPCSecret(s, at(0)); err != nil {
+				t.Fatal(err)
+			}
+			initial := s.DeepCopy()
+			tc.setup(s)
+
+			// Phase 1: the new CA is trusted next to the previous one.
+			if err := rotateGRPCSecret(s, at(0)); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := s.Annotations[GRPCTLSForcedKeyRotateAnnotation]; ok {
+				t.Fatalf("expected annotation %q to be removed", GRPCTLSForcedKeyRotateAnnotation)
+			}
+			expectPhase(t, s, GRPCTLSRotationPhaseTrust)
+			expectBundleSize(t, s, 2)
+			if bytes.Equal(initial.Data["ca.key"], s.Data["ca.key"]) {
+				t.Fatal("expected the CA key to change")
+			}
+			for _, c := range grpcTLSCertificates {
+				if !bytes.Equal(initial.Data[c.crt], s.Data[c.crt]) {
+					t.Fatalf("expected %s to be kept during the trust phase", c.crt)
+				}
+			}
+			trust := s.DeepCopy()
+
+			// Nothing happens until the overlap period has elapsed.
+			if err := rotateGRPCSecret(s, at(grpcTLSRotationOverlap/2)); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(trust, s) {
+				t.Fatal("expected no change before the end of the overlap period")
+			}
+
+			// Phase 2: the certificates are issued by the new CA.
+			if err := rotateGRPCSecret(s, at(grpcTLSRotationOverlap)); err != nil {
+				t.Fatal(err)
+			}
+			expectPhase(t, s, GRPCTLSRotationPhaseReissue)
+			expectBundleSize(t, s, 2)
+			for _, c := range grpcTLSCertificates {
+				if bytes.Equal(trust.Data[c.crt], s.Data[c.crt]) {
+					t.Fatalf("expected %s to be reissued", c.crt)
+				}
+				// Components which haven't reloaded the secret yet trust the
+				// new certificates and are trusted by the updated ones.
+				if err := assertCertValidityWithCa(s.Data[c.crt], trust.Data["ca.crt"]); err != nil {
+					t.Fatalf("new %s: %v", c.crt, err)
+				}
+				if err := assertCertValidityWithCa(trust.Data[c.crt], s.Data["ca.crt"]); err != nil {
+					t.Fatalf("old %s: %v", c.crt, err)
+				}
+			}
+			reissue := s.DeepCopy()
+
+			// Phase 3: the previous CA is removed.
+			if err := rotateGRPCSecret(s, at(2*grpcTLSRotationOverlap)); err != nil {
+				t.Fatal(err)
+			}
+			expectPhase(t, s, "")
+			expectBundleSize(t, s, 1)
+			if !bytes.Equal(reissue.Data["ca.key"], s.Data["ca.key"]) {
+				t.Fatal("expected the CA key to be kept")
+			}
+			for _, c := range grpcTLSCertificates {
+				if !bytes.Equal(reissue.Data[c.crt], s.Data[c.crt]) {
+					t.Fatalf("expected %s to be kept", c.crt)
+				}
+				if err := assertCertValidityWithCa(s.Data[c.crt], s.Data["ca.crt"]); err != nil {
+					t.Fatalf("%s: %v", c.crt, err)
+				}
+				if err := assertCertValidityWithCa(initial.Data[c.crt], s.Data["ca.crt"]); err == nil {
+					t.Fatalf("expected initial %s not to be trusted anymore", c.crt)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCCertificatesReissuedWhenInvalid(t *testing.T) {
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	s, err := f.GRPCSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RotateGRPCSecret(s); err != nil {
+		t.Fatal(err)
+	}
+
+	pre := s.DeepCopy()
+	delete(s.Data, "prometheus-server.key")
+	if err := RotateGRPCSecret(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(pre.Data["ca.crt"], s.Data["ca.crt"]) {
+		t.Fatal("expected the CA to be kept")
+	}
+	if len(s.Data["prometheus-server.key"]) == 0 {
+		t.Fatal("expected the server certificate to be reissued")
+	}
+	for _, c := range grpcTLSCertificates {
+		if err := assertCertValidityWithCa(s.Data[c.crt], pre.Data["ca.crt"]); err != nil {
+			t.Fatalf("%s: %v", c.crt, err)
+		}
+	}
+}
+
+func TestGRPCCertificateExpirations(t *testing.T) {
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", NewDefaultConfig(), defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+
+	s, err := f.GRPCSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := GRPCCertificateExpirations(s); len(got) != 0 {
+		t.Fatalf("expected no expiration for an empty secret, got %v", got)
+	}
+
+	if err := RotateGRPCSecret(s); err != nil {
+		t.Fatal(err)
+	}
+
+	got := GRPCCertificateExpirations(s)
+	for _, name := range []string{"ca", "thanos-querier-client", "prometheus-server"} {
+		exp, ok := got[name]
+		if !ok {
+			t.Fatalf("expected expiration for %q", name)
+		}
+		if d := time.Until(exp); d <= 0 || d > certificateLifetime {
+			t.Fatalf("expected %q to expire within %s, got %s", name, certificateLifetime, exp)
+		}
+	}
+}
+
+func expectPhase(t *testing.T, s *v1.Secret, phase string) {
+	t.Helper()
+
+	if got := s.Annotations[GRPCTLSRotationPhaseAnnotation]; got != phase {
+		t.Fatalf("expected rotation phase %q, got %q", phase, got)
+	}
+	if _, ok := s.Annotations[GRPCTLSRotationPhaseStartAnnotation]; ok != (phase != "") {
+		t.Fatalf("unexpected rotation phase start annotation: %v", s.Annotations)
+	}
+}
+
+func expectBundleSize(t *testing.T, s *v1.Secret, n int) {
+	t.Helper()
+
+	certs, err := crypto.CertsFromPEM(s.Data["ca.crt"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != n {
+		t.Fatalf("expected %d CA certificates, got %d", n, len(certs))
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// configCheck renders an object or checks a setting whose validity is only
+// known at render time. The checks without name don't render an object.
+type configCheck struct {
+	name   string
+	render func() (interface{}, error)
+}
+
+// configChecks returns the checks of the enabled components. The objects
+// which the tasks read from the cluster are replaced by empty placeholders.
+func (f *Factory) configChecks() []configCheck {
+	var (
+		cmc       = f.config.ClusterMonitoringConfiguration
+		secret    = &v1.Secret{}
+		configMap = &v1.ConfigMap{}
+	)
+
+	checks := []configCheck{
+		{
+			render: func() (interface{}, error) { return f.collectionProfile() },
+		},
+		{
+			render: func() (interface{}, error) { return f.HostedControlPlaneNamespace() },
+		},
+		{
+			render: func() (interface{}, error) { return f.AdditionalDashboardsNamespace() },
+		},
+		{
+			render: func() (interface{}, error) {
+				_, _, err := f.ConsoleDashboards()
+				return nil, err
+			},
+		},
+		{
+			name:   "prometheus-k8s PrometheusRule",
+			render: func() (interface{}, error) { return f.PrometheusK8sPrometheusRule() },
+		},
+		{
+			name:   "prometheus-k8s Prometheus",
+			render: func() (interface{}, error) { return f.PrometheusK8s("", secret, configMap) },
+		},
+		{
+			name:   "node-exporter DaemonSet",
+			render: func() (interface{}, error) { return f.NodeExporterDaemonSet(nil) },
+		},
+		{
+			render: func() (interface{}, error) { return cmc.KubeStateMetricsConfig.ShardCount() },
+		},
+		{
+			name: "thanos-querier Deployment",
+			render: func() (interface{}, error) {
+				return f.ThanosQuerierDeployment(secret, *cmc.UserWorkloadEnabled, configMap)
+			},
+		},
+	}
+
+	if cmc.AlertmanagerMainConfig.IsEnabled() {
+		checks = append(checks,
+			configCheck{
+				name:   "alertmanager-main Alertmanager",
+				render: func() (interface{}, error) { return f.AlertmanagerMain("", configMap) },
+			},
+			configCheck{
+				name: "alertmanager-main-rendered Secret",
+				render: func() (interface{}, error) {
+					s, err := f.AlertmanagerConfig()
+					if err != nil {
+						return nil, err
+					}
+					rendered, err := f.AlertmanagerRenderedConfig(s)
+					if rendered == nil {
+						// Alertmanager uses the user-provided Secret.
+						return nil, err
+					}
+					return rendered, err
+				},
+			},
+		)
+	}
+
+	if cmc.OpenShiftMetricsConfig.IsEnabled() {
+		checks = append(checks, configCheck{
+			name:   "openshift-state-metrics Deployment",
+			render: func() (interface{}, error) { return f.OpenShiftStateMetricsDeployment() },
+		})
+	}
+
+	if cmc.K8sPrometheusAdapter.IsEnabled(cmc.MetricsServerConfig) {
+		checks = append(checks, configCheck{
+			name:   "prometheus-adapter Deployment",
+			render: func() (interface{}, error) { return f.PrometheusAdapterDeployment("", map[string]string{}) },
+		})
+	}
+
+	if cmc.TelemeterClientConfig.IsEnabled() {
+		checks = append(checks, configCheck{
+			render: func() (interface{}, error) { return nil, cmc.TelemeterClientConfig.ValidateAdditionalCABundle() },
+		})
+	}
+
+	if cmc.ThanosReceiveConfig.IsEnabled() {
+		checks = append(checks, configCheck{
+			name:   "thanos-receive StatefulSet",
+			render: func() (interface{}, error) { return f.ThanosReceiveStatefulSet(secret) },
+		})
+	}
+
+	if *cmc.UserWorkloadEnabled {
+		checks = append(checks,
+			configCheck{
+				name:   "prometheus-user-workload Prometheus",
+				render: func() (interface{}, error) { return f.PrometheusUserWorkload(secret) },
+			},
+			configCheck{
+				name:   "user-workload ThanosRuler",
+				render: func() (interface{}, error) { return f.ThanosRulerCustomResource("", configMap, secret, secret) },
+			},
+		)
+	}
+
+	return checks
+}
+
+// ValidateConfig renders the objects of the enabled components whose
+// settings are only checked at render time and returns the first error
+// caused by an invalid configuration. The objects which the tasks read from
+// the cluster are replaced by empty placeholders hence errors which aren't
+// wrapping ErrConfigValidation are ignored.
+func (f *Factory) ValidateConfig() error {
+	for _, check := range f.configChecks() {
+		if _, err := check.render(); err != nil && errors.Is(err, ErrConfigValidation) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenderConfig renders the objects of the enabled components which depend on
+// the configuration, by name. It returns the first error caused by an invalid
+// configuration like ValidateConfig. The objects which can't be rendered
+// without the cluster are omitted.
+func (f *Factory) RenderConfig() (map[string]interface{}, error) {
+	objects := map[string]interface{}{}
+	for _, check := range f.configChecks() {
+		obj, err := check.render()
+		if err != nil {
+			if errors.Is(err, ErrConfigValidation) {
+				return nil, err
+			}
+			continue
+		}
+
+		if check.name != "" && obj != nil {
+			objects[check.name] = obj
+		}
+	}
+
+	return objects, nil
+}
+
+// ValidateConfigStrict runs ValidateConfig and also rejects the settings
+// which the operator accepts but which are most likely mistakes.
+func (f *Factory) ValidateConfigStrict() error {
+	if err := f.ValidateConfig(); err != nil {
+		return err
+	}
+
+	// The external labels set by the Prometheus operator identify the
+	// Prometheus instances and their replicas. Overriding them breaks the
+	// deduplication of the replicas.
+	prometheusLabel, replicaLabel, err := f.externalLabelNames()
+	if err != nil {
+		return err
+	}
+	reserved := []string{prometheusLabel, replicaLabel}
+
+	if err := checkReservedExternalLabels("prometheusK8s", f.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ExternalLabels, reserved); err != nil {
+		return err
+	}
+
+	if f.config.UserWorkloadConfiguration != nil && f.config.UserWorkloadConfiguration.Prometheus != nil {
+		if err := checkReservedExternalLabels("prometheus", f.config.UserWorkloadConfiguration.Prometheus.ExternalLabels, reserved); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkReservedExternalLabels(field string, labels map[string]string, reserved []string) error {
+	for _, l := range reserved {
+		if _, found := labels[l]; found {
+			return fmt.Errorf("%w - %s externalLabels: the %q label is reserved by the Prometheus operator", ErrConfigValidation, field, l)
+		}
+	}
+	return nil
+}
+
+// CheckConfigFields returns an error when the cluster monitoring
+// configuration holds fields unknown to the operator. The operator ignores
+// them but they usually are typos.
+func CheckConfigFields(content string) error {
+	if err := yaml.UnmarshalStrict([]byte(content), &ClusterMonitoringConfiguration{}); err != nil {
+		return fmt.Errorf("%w - %v", ErrConfigValidation, err)
+	}
+	return checkUnknownFields(UnknownConfigFields(content))
+}
+
+// CheckUserConfigFields returns an error when the user workload monitoring
+// configuration holds fields unknown to the operator.
+func CheckUserConfigFields(content string) error {
+	if err := yaml.UnmarshalStrict([]byte(content), &UserWorkloadConfiguration{}); err != nil {
+		return fmt.Errorf("%w - %v", ErrConfigValidation, err)
+	}
+	return checkUnknownFields(UnknownUserConfigFields(content))
+}
+
+func checkUnknownFields(fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w - unknown fields: %s", ErrConfigValidation, strings.Join(fields, ", "))
+}
+
+// CheckUnknownFields returns an error listing the fields of the
+// configuration ConfigMaps unknown to the operator.
+func (c *Config) CheckUnknownFields() error {
+	return checkUnknownFields(c.UnknownFieldsByConfigMap())
+}
+
+// UnknownFieldsByConfigMap returns the fields unknown to the operator
+// prefixed with the key of their ConfigMap, sorted.
+func (c *Config) UnknownFieldsByConfigMap() []string {
+	var fields []string
+	for key, unknown := range c.UnknownFields {
+		for _, f := range unknown {
+			fields = append(fields, key+": "+f)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// UnknownConfigFields returns the paths of the fields of the cluster
+// monitoring configuration which are unknown to the operator, sorted. The
+// fields only differing by case from a known field are matched by the parser
+// and reported with the expected name. Invalid configurations have no
+// unknown fields, the parsing errors are reported elsewhere.
+func UnknownConfigFields(content string) []string {
+	return unknownFields(content, reflect.TypeOf(ClusterMonitoringConfiguration{}))
+}
+
+// UnknownUserConfigFields returns the paths of the fields of the user
+// workload monitoring configuration which are unknown to the operator.
+func UnknownUserConfigFields(content string) []string {
+	return unknownFields(content, reflect.TypeOf(UserWorkloadConfiguration{}))
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func unknownFields(content string, t reflect.Type) []string {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(content), &v); err != nil {
+		return nil
+	}
+
+	var fields []string
+	collectUnknownFields(v, t, "", &fields)
+	sort.Strings(fields)
+
+	return fields
+}
+
+// collectUnknownFields walks the decoded value along the Go type it is
+// parsed into. The types with custom JSON parsing (e.g. quantities) are
+// opaque.
+func collectUnknownFields(v interface{}, t reflect.Type, path string, fields *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		known := jsonFields(t)
+		for k, fv := range m {
+			ft, found := known[k]
+			if found {
+				collectUnknownFields(fv, ft, fieldPath(path, k), fields)
+				continue
+			}
+
+			// encoding/json matches the field names case-insensitively.
+			name := k
+			for kn, kt := range known {
+				if strings.EqualFold(kn, k) {
+					name, ft, found = kn, kt, true
+					break
+				}
+			}
+			if !found {
+				*fields = append(*fields, fieldPath(path, k))
+				continue
+			}
+			*fields = append(*fields, fmt.Sprintf("%s (expected %q)", fieldPath(path, k), name))
+			collectUnknownFields(fv, ft, fieldPath(path, name), fields)
+		}
+
+	case reflect.Slice, reflect.Array:
+		items, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			collectUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, mv := range m {
+			collectUnknownFields(mv, t.Elem(), fieldPath(path, k), fields)
+		}
+	}
+}
+
+// jsonFields returns the types of the struct fields by JSON name, including
+// the fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for n, et := range jsonFields(ft) {
+					if _, found := fields[n]; !found {
+						fields[n] = et
+					}
+				}
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,309 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifests
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		config     string
+		userConfig string
+
+		err bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:   "user workload enabled",
+			config: "enableUserWorkload: true",
+		},
+		{
+			name:   "invalid external URL",
+			config: "prometheusK8s: {externalURL: 'not/absolute'}",
+			err:    true,
+		},
+		{
+			name:   "invalid Alertmanager retention",
+			config: "alertmanagerMain: {retention: 1y}",
+			err:    true,
+		},
+		{
+			name:   "invalid settings of a disabled Alertmanager",
+			config: "alertmanagerMain: {enabled: false, retention: 1y}",
+		},
+		{
+			name:   "invalid Thanos Querier timeout",
+			config: "thanosQuerier: {queryTimeout: -1m}",
+			err:    true,
+		},
+		{
+			name:       "invalid user workload Thanos Ruler replicas",
+			config:     "enableUserWorkload: true",
+			userConfig: "thanosRuler: {replicas: 0}",
+			err:        true,
+		},
+		{
+			name:       "invalid user workload settings with user workload disabled",
+			userConfig: "thanosRuler: {replicas: 0}",
+		},
+		{
+			name:   "renamed external labels",
+			config: "telemeterClient: {enabled: false}\nprometheusK8s: {externalLabelNames: {prometheus: source, replica: replica}}",
+		},
+		{
+			name:   "renamed external labels with telemetry",
+			config: "prometheusK8s: {externalLabelNames: {replica: replica}}",
+			err:    true,
+		},
+		{
+			name:   "identical external label names",
+			config: "telemeterClient: {enabled: false}\nprometheusK8s: {externalLabelNames: {prometheus: replica, replica: replica}}",
+			err:    true,
+		},
+		{
+			name:   "invalid external label name",
+			config: "telemeterClient: {enabled: false}\nprometheusK8s: {externalLabelNames: {replica: __replica}}",
+			err:    true,
+		},
+		{
+			name:   "external label name reserved by Thanos",
+			config: "telemeterClient: {enabled: false}\nprometheusK8s: {externalLabelNames: {replica: thanos_ruler_replica}}",
+			err:    true,
+		},
+		{
+			name:       "renamed external label set by the user workload Prometheus",
+			config:     "telemeterClient: {enabled: false}\nprometheusK8s: {externalLabelNames: {replica: replica}}",
+			userConfig: "prometheus: {externalLabels: {replica: foo}}",
+			err:        true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			uwc, err := NewUserConfigFromString(tc.userConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.UserWorkloadConfiguration = uwc
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			err = f.ValidateConfig()
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected a validation error, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigStrict(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		config     string
+		userConfig string
+
+		err bool
+	}{
+		{
+			name:   "valid",
+			config: "prometheusK8s: {externalLabels: {cluster: foo}}",
+		},
+		{
+			name:   "reserved platform external label",
+			config: "prometheusK8s: {externalLabels: {prometheus_replica: foo}}",
+			err:    true,
+		},
+		{
+			name:       "reserved user workload external label",
+			userConfig: "prometheus: {externalLabels: {prometheus: foo}}",
+			err:        true,
+		},
+		{
+			name:   "default name of a renamed external label",
+			config: "telemeterClient: {enabled: false}\nprometheusK8s: {externalLabelNames: {prometheus: source}, externalLabels: {prometheus: foo}}",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			uwc, err := NewUserConfigFromString(tc.userConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.UserWorkloadConfiguration = uwc
+
+			f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+			err = f.ValidateConfigStrict()
+			if tc.err {
+				if !errors.Is(err, ErrConfigValidation) {
+					t.Fatalf("expected a validation error, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckConfigFields(t *testing.T) {
+	if err := CheckConfigFields("prometheusK8s: {retention: 1d}"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := CheckConfigFields("prometheusK8s: {retntion: 1d}"); !errors.Is(err, ErrConfigValidation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if err := CheckUserConfigFields("thanosRuler: {replicas: 1}"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := CheckUserConfigFields("thanosRuler: {replica: 1}"); !errors.Is(err, ErrConfigValidation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if err := CheckConfigFields("prometheusk8s: {retention: 1d}"); !errors.Is(err, ErrConfigValidation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
+
+func TestUnknownConfigFields(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		content  string
+		user     bool
+		expected []string
+	}{
+		{
+			name: "known fields",
+			content: `prometheusK8s:
+  retention: 1d
+  resources:
+    requests:
+      cpu: 200m
+  tolerations:
+  - key: foo
+    operator: Exists
+  externalLabels:
+    anything: goes
+alertmanagerMain:
+  enabled: true
+`,
+		},
+		{
+			name: "unknown fields",
+			content: `prometheusK8s:
+  retntion: 1d
+  tolerations:
+  - key: foo
+    operatr: Exists
+foo: bar
+`,
+			expected: []string{"foo", "prometheusK8s.retntion", "prometheusK8s.tolerations[0].operatr"},
+		},
+		{
+			name:     "case mismatch",
+			content:  "prometheusk8s: {retention: 1d, foo: bar}",
+			expected: []string{"prometheusK8s.foo", `prometheusk8s (expected "prometheusK8s")`},
+		},
+		{
+			name:     "user workload",
+			content:  "thanosRuler: {replica: 1}\nprometheus: {retention: 1d}",
+			user:     true,
+			expected: []string{"thanosRuler.replica"},
+		},
+		{
+			name:    "invalid configuration",
+			content: "prometheusK8s: [",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var fields []string
+			if tc.user {
+				fields = UnknownUserConfigFields(tc.content)
+			} else {
+				fields = UnknownConfigFields(tc.content)
+			}
+
+			if !reflect.DeepEqual(fields, tc.expected) {
+				t.Fatalf("expected unknown fields %q, got %q", tc.expected, fields)
+			}
+		})
+	}
+}
+
+func TestRenderConfig(t *testing.T) {
+	c, err := NewConfigFromString("enableUserWorkload: true\nalertmanagerMain: {enabled: false}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, defaultInfrastructureReader(), &fakeProxyReader{}, NewAssets(assetsPath), &APIServerConfig{})
+	objects, err := f.RenderConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"prometheus-k8s Prometheus", "prometheus-user-workload Prometheus"} {
+		if _, found := objects[name]; !found {
+			t.Fatalf("expected %q to be rendered", name)
+		}
+	}
+	for _, name := range []string{"alertmanager-main Alertmanager", "alertmanager-main-rendered Secret"} {
+		if _, found := objects[name]; found {
+			t.Fatalf("expected %q not to be rendered", name)
+		}
+	}
+}
+
+func TestCheckUnknownFields(t *testing.T) {
+	c := NewDefaultConfig()
+	if err := c.CheckUnknownFields(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	c.UnknownFields = map[string][]string{
+		"openshift-user-workload-monitoring/user-workload-monitoring-config": {"thanosRuler.replica"},
+		"openshift-monitoring/cluster-monitoring-config":                     {"foo"},
+	}
+	expected := []string{
+		"openshift-monitoring/cluster-monitoring-config: foo",
+		"openshift-user-workload-monitoring/user-workload-monitoring-config: thanosRuler.replica",
+	}
+	if got := c.UnknownFieldsByConfigMap(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if err := c.CheckUnknownFields(); !errors.Is(err, ErrConfigValidation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/admission"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const configKey = "config.yaml"
+
+// ConfigValidationHandler returns the HTTP handler of the validating
+// admission webhook rejecting invalid Cluster Monitoring and User Workload
+// Monitoring ConfigMaps.
+func (o *Operator) ConfigValidationHandler() http.Handler {
+	return admission.NewHandler(
+		admission.ConfigMapValidator{
+			Namespace: o.namespace,
+			Name:      o.configMapName,
+			Validate:  o.validateConfigMap,
+		},
+		admission.ConfigMapValidator{
+			Namespace: o.namespaceUserWorkload,
+			Name:      o.userWorkloadConfigMapName,
+			Validate:  o.validateUserWorkloadConfigMap,
+		},
+	)
+}
+
+// validateConfigMap validates the Cluster Monitoring ConfigMap against the
+// current user workload configuration.
+func (o *Operator) validateConfigMap(ctx context.Context, cm *v1.ConfigMap) error {
+	content, found := cm.Data[configKey]
+	if !found {
+		return errors.Errorf("the %q key is missing", configKey)
+	}
+
+	c, err := manifests.NewConfigFromString(content)
+	if err != nil {
+		return err
+	}
+
+	if c.ClusterMonitoringConfiguration.Strict {
+		if err := manifests.CheckConfigFields(content); err != nil {
+			return err
+		}
+	}
+
+	uwc, _, err := o.loadUserWorkloadConfig(ctx)
+	if err != nil {
+		klog.Warningf("Validating the Cluster Monitoring ConfigMap with the default user workload configuration: %v", err)
+		uwc = manifests.NewDefaultUserWorkloadMonitoringConfig()
+	}
+	c.UserWorkloadConfiguration = uwc
+
+	return o.validateConfig(c)
+}
+
+// validateUserWorkloadConfigMap validates the User Workload Monitoring
+// ConfigMap against the current cluster configuration. The user workload
+// components are validated even if they aren't enabled yet.
+func (o *Operator) validateUserWorkloadConfigMap(ctx context.Context, cm *v1.ConfigMap) error {
+	content, found := cm.Data[configKey]
+	if !found {
+		return nil
+	}
+
+	uwc, err := manifests.NewUserConfigFromString(content)
+	if err != nil {
+		return err
+	}
+
+	c, err := o.loadConfig(o.namespace + "/" + o.configMapName)
+	if err != nil {
+		klog.Warningf("Validating the User Workload Monitoring ConfigMap with the default cluster configuration: %v", err)
+		c = manifests.NewDefaultConfig()
+	}
+
+	if c.ClusterMonitoringConfiguration.Strict {
+		if err := manifests.CheckUserConfigFields(content); err != nil {
+			return err
+		}
+	}
+
+	enabled := true
+	c.ClusterMonitoringConfiguration.UserWorkloadEnabled = &enabled
+	c.UserWorkloadConfiguration = uwc
+
+	return o.validateConfig(c)
+}
+
+// validateConfig renders the configuration like a reconciliation would. The
+// cluster infrastructure, proxy and API server settings don't change which
+// configurations are valid hence the defaults are used rather than fetching
+// them on every request.
+func (o *Operator) validateConfig(c *manifests.Config) error {
+	c.SetImages(o.images)
+	if err := c.SetTelemetryMatches(o.telemetryMatches); err != nil {
+		return err
+	}
+	c.SetRemoteWrite(o.remoteWrite)
+
+	f := manifests.NewFactory(o.namespace, o.namespaceUserWorkload, c, NewDefaultInfrastructureConfig(), c, o.assets, &manifests.APIServerConfig{})
+	return f.ValidateConfig()
+}
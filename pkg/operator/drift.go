@@ -0,0 +1,195 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"sync"
+	"time"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// driftInterval is the minimum interval between two reconciliations
+// triggered by the drift of workloads of the same kind. It prevents the
+// operator from hammering the API server when another controller keeps
+// modifying the same objects.
+const driftInterval = 30 * time.Second
+
+// managedWorkloads are the kinds of workloads deployed by the operator which
+// are watched to revert the changes made by other actors.
+var managedWorkloads = []struct {
+	kind     string
+	resource string
+	obj      runtime.Object
+}{
+	{kind: "Deployment", resource: "deployments", obj: &appsv1.Deployment{}},
+	{kind: "StatefulSet", resource: "statefulsets", obj: &appsv1.StatefulSet{}},
+	{kind: "DaemonSet", resource: "daemonsets", obj: &appsv1.DaemonSet{}},
+	{kind: "Prometheus", resource: "prometheuses", obj: &monv1.Prometheus{}},
+	{kind: "Alertmanager", resource: "alertmanagers", obj: &monv1.Alertmanager{}},
+	{kind: "ThanosRuler", resource: "thanosrulers", obj: &monv1.ThanosRuler{}},
+}
+
+// handleUpdate wraps an event handler to ignore the notifications sent when
+// the informers resync since the object didn't change.
+func handleUpdate(handler func(interface{})) func(interface{}, interface{}) {
+	return func(oldObj, newObj interface{}) {
+		oldMeta, err := meta.Accessor(oldObj)
+		if err != nil {
+			handler(newObj)
+			return
+		}
+		newMeta, err := meta.Accessor(newObj)
+		if err != nil {
+			handler(newObj)
+			return
+		}
+
+		if oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+			return
+		}
+		handler(newObj)
+	}
+}
+
+// workloadDrifted returns true when the spec of the workload was modified by
+// another actor than the operator. Status updates don't bump the generation
+// and are ignored.
+func workloadDrifted(kind string, oldObj, newObj metav1.Object, applied func(string, metav1.Object) bool) bool {
+	if oldObj.GetGeneration() == newObj.GetGeneration() {
+		return false
+	}
+	return !applied(kind, newObj)
+}
+
+// driftEventHandler enqueues the kind of the workloads modified or deleted
+// outside of the operator into the drift queue.
+func (o *Operator) driftEventHandler(kind string) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldMeta, err := meta.Accessor(oldObj)
+			if err != nil {
+				return
+			}
+			newMeta, err := meta.Accessor(newObj)
+			if err != nil {
+				return
+			}
+
+			if !workloadDrifted(kind, oldMeta, newMeta, o.client.AppliedGeneration) {
+				return
+			}
+
+			klog.Infof("Triggering an update due to the %s %s/%s modified outside of the operator", kind, newMeta.GetNamespace(), newMeta.GetName())
+			o.driftQueue.AddRateLimited(kind)
+		},
+		DeleteFunc: func(obj interface{}) {
+			key, ok := o.keyFunc(obj)
+			if !ok {
+				return
+			}
+
+			klog.Infof("Triggering an update due to the %s %s deleted", kind, key)
+			o.driftQueue.AddRateLimited(kind)
+		},
+	}
+}
+
+func (o *Operator) driftWorker() {
+	for o.processNextDrift() {
+	}
+}
+
+// processNextDrift turns the drift of a kind of workloads into a
+// reconciliation. The reconciliation queue deduplicates the keys so that
+// drifts of several kinds trigger a single reconciliation.
+func (o *Operator) processNextDrift() bool {
+	kind, quit := o.driftQueue.Get()
+	if quit {
+		return false
+	}
+	defer o.driftQueue.Done(kind)
+
+	klog.V(4).Infof("Reconciling the drift of %s objects", kind)
+	o.driftQueue.Forget(kind)
+	o.requestFullSync()
+	o.enqueue(o.namespace + "/" + o.configMapName)
+
+	return true
+}
+
+// kindRateLimiter is a workqueue rate limiter letting each item through at
+// most once per interval. Contrary to the token bucket rate limiters, a
+// burst of events for the same item doesn't push the following ones further
+// away: the events received while the item is already scheduled get the same
+// delay.
+type kindRateLimiter struct {
+	interval time.Duration
+	now      func() time.Time
+
+	mtx       sync.Mutex
+	next      map[interface{}]time.Time
+	scheduled map[interface{}]time.Time
+}
+
+func newKindRateLimiter(interval time.Duration) *kindRateLimiter {
+	return &kindRateLimiter{
+		interval:  interval,
+		now:       time.Now,
+		next:      map[interface{}]time.Time{},
+		scheduled: map[interface{}]time.Time{},
+	}
+}
+
+func (k *kindRateLimiter) When(item interface{}) time.Duration {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	now := k.now()
+	if at, found := k.scheduled[item]; found {
+		if at.Before(now) {
+			return 0
+		}
+		return at.Sub(now)
+	}
+
+	next := k.next[item]
+	if !now.Before(next) {
+		k.next[item] = now.Add(k.interval)
+		return 0
+	}
+
+	k.scheduled[item] = next
+	k.next[item] = next.Add(k.interval)
+	return next.Sub(now)
+}
+
+// Forget is called once the item has been processed: the next event will be
+// scheduled at the next slot.
+func (k *kindRateLimiter) Forget(item interface{}) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	delete(k.scheduled, item)
+}
+
+func (k *kindRateLimiter) NumRequeues(item interface{}) int {
+	return 0
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestKindRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	rl := newKindRateLimiter(30 * time.Second)
+	rl.now = func() time.Time { return now }
+
+	expectDelay := func(item string, expected time.Duration) {
+		t.Helper()
+		if got := rl.When(item); got != expected {
+			t.Fatalf("expected delay %s for %s, got %s", expected, item, got)
+		}
+	}
+
+	// The first event goes through immediately.
+	expectDelay("Deployment", 0)
+	// The kinds are rate-limited independently.
+	expectDelay("Prometheus", 0)
+
+	// The following events are delayed until the next slot, without pushing
+	// it further away.
+	now = now.Add(10 * time.Second)
+	expectDelay("Deployment", 20*time.Second)
+	expectDelay("Deployment", 20*time.Second)
+
+	// Once the delayed event is processed, the next one waits for the
+	// following slot.
+	now = now.Add(20 * time.Second)
+	rl.Forget("Deployment")
+	expectDelay("Deployment", 30*time.Second)
+	rl.Forget("Deployment")
+
+	// After a quiet period, events go through immediately again.
+	now = now.Add(5 * time.Minute)
+	expectDelay("Deployment", 0)
+}
+
+func TestWorkloadDrifted(t *testing.T) {
+	obj := func(generation int64) *metav1.ObjectMeta {
+		return &metav1.ObjectMeta{Namespace: "openshift-monitoring", Name: "k8s", Generation: generation}
+	}
+	applied := func(generation int64) func(string, metav1.Object) bool {
+		return func(_ string, o metav1.Object) bool {
+			return o.GetGeneration() == generation
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		old, new *metav1.ObjectMeta
+		applied  int64
+		expected bool
+	}{
+		{
+			name:    "status update",
+			old:     obj(2),
+			new:     obj(2),
+			applied: 1,
+		},
+		{
+			name:    "update from the operator",
+			old:     obj(1),
+			new:     obj(2),
+			applied: 2,
+		},
+		{
+			name:     "update from another actor",
+			old:      obj(2),
+			new:      obj(3),
+			applied:  2,
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := workloadDrifted("Prometheus", tc.old, tc.new, applied(tc.applied)); got != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateIgnoresResyncs(t *testing.T) {
+	var calls int
+	handler := handleUpdate(func(interface{}) { calls++ })
+
+	cm := func(rv string) *v1.ConfigMap {
+		return &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo", ResourceVersion: rv}}
+	}
+
+	handler(cm("1"), cm("1"))
+	if calls != 0 {
+		t.Fatalf("expected the resync to be ignored, got %d calls", calls)
+	}
+
+	handler(cm("1"), cm("2"))
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
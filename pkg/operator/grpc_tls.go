@@ -0,0 +1,83 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// grpcTLSCollector exposes the expiration time of the certificates used by
+// the Thanos components to communicate over GRPC, as well as whether a
+// staged replacement of the CA is in progress. The certificates are read
+// from the secret informer's store so that the metrics reflect the secret
+// even when the reconciliation fails.
+type grpcTLSCollector struct {
+	store      cache.Store
+	key        string
+	expiration *prometheus.Desc
+	rotation   *prometheus.Desc
+}
+
+func newGRPCTLSCollector(store cache.Store, namespace string) *grpcTLSCollector {
+	return &grpcTLSCollector{
+		store: store,
+		key:   namespace + "/grpc-tls",
+		expiration: prometheus.NewDesc(
+			"cluster_monitoring_operator_grpc_tls_certificate_expiration_timestamp_seconds",
+			"Expiration time of the certificates used by the Thanos components to communicate over GRPC.",
+			[]string{"certificate"},
+			nil,
+		),
+		rotation: prometheus.NewDesc(
+			"cluster_monitoring_operator_grpc_tls_rotation_in_progress",
+			"Whether the CA used by the Thanos components to communicate over GRPC is being replaced, by phase.",
+			[]string{"phase"},
+			nil,
+		),
+	}
+}
+
+func (c *grpcTLSCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.expiration
+	ch <- c.rotation
+}
+
+func (c *grpcTLSCollector) Collect(ch chan<- prometheus.Metric) {
+	obj, found, err := c.store.GetByKey(c.key)
+	if err != nil || !found {
+		return
+	}
+
+	s, ok := obj.(*v1.Secret)
+	if !ok {
+		return
+	}
+
+	for name, t := range manifests.GRPCCertificateExpirations(s) {
+		ch <- prometheus.MustNewConstMetric(c.expiration, prometheus.GaugeValue, float64(t.Unix()), name)
+	}
+
+	phase := s.Annotations[manifests.GRPCTLSRotationPhaseAnnotation]
+	for _, p := range []string{manifests.GRPCTLSRotationPhaseTrust, manifests.GRPCTLSRotationPhaseReissue} {
+		var v float64
+		if p == phase {
+			v = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.rotation, prometheus.GaugeValue, v, p)
+	}
+}
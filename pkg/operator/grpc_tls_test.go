@@ -0,0 +1,78 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+)
+
+func TestGRPCTLSCollector(t *testing.T) {
+	ca, err := crypto.MakeSelfSignedCAConfig("test", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, _, err := ca.GetPEMBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, s := range []*v1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "openshift-monitoring",
+				Name:      "grpc-tls",
+				Annotations: map[string]string{
+					manifests.GRPCTLSRotationPhaseAnnotation: manifests.GRPCTLSRotationPhaseReissue,
+				},
+			},
+			Data: map[string][]byte{
+				"ca.crt":                []byte(crt),
+				"prometheus-server.crt": []byte("broken certificate"),
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-user-workload-monitoring", Name: "grpc-tls"},
+			Data:       map[string][]byte{"ca.crt": []byte(crt)},
+		},
+	} {
+		if err := store.Add(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := fmt.Sprintf(`
+# HELP cluster_monitoring_operator_grpc_tls_certificate_expiration_timestamp_seconds Expiration time of the certificates used by the Thanos components to communicate over GRPC.
+# TYPE cluster_monitoring_operator_grpc_tls_certificate_expiration_timestamp_seconds gauge
+cluster_monitoring_operator_grpc_tls_certificate_expiration_timestamp_seconds{certificate="ca"} %d
+# HELP cluster_monitoring_operator_grpc_tls_rotation_in_progress Whether the CA used by the Thanos components to communicate over GRPC is being replaced, by phase.
+# TYPE cluster_monitoring_operator_grpc_tls_rotation_in_progress gauge
+cluster_monitoring_operator_grpc_tls_rotation_in_progress{phase="reissue"} 1
+cluster_monitoring_operator_grpc_tls_rotation_in_progress{phase="trust"} 0
+`, ca.Certs[0].NotAfter.Unix())
+	if err := testutil.CollectAndCompare(newGRPCTLSCollector(store, "openshift-monitoring"), strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// userWorkloadNamespaceTerminatingReason is the reason of the Degraded
+// condition and of the event reported while the user workload monitoring
+// namespace is being deleted.
+const userWorkloadNamespaceTerminatingReason = "UserWorkloadNamespaceTerminating"
+
+// namespaceTerminatingMessage returns why the deletion of the namespace
+// doesn't complete, from its finalizers and its deletion conditions. It
+// returns an empty string when the namespace isn't being deleted.
+func namespaceTerminatingMessage(ns *v1.Namespace) string {
+	if ns.DeletionTimestamp == nil {
+		return ""
+	}
+
+	var blockers []string
+	for _, c := range ns.Status.Conditions {
+		if c.Status != v1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case v1.NamespaceDeletionDiscoveryFailure,
+			v1.NamespaceDeletionGVParsingFailure,
+			v1.NamespaceDeletionContentFailure,
+			v1.NamespaceContentRemaining,
+			v1.NamespaceFinalizersRemaining:
+			blockers = append(blockers, fmt.Sprintf("%s: %s", c.Type, c.Message))
+		}
+	}
+
+	finalizers := append([]string{}, ns.Finalizers...)
+	for _, f := range ns.Spec.Finalizers {
+		finalizers = append(finalizers, string(f))
+	}
+	if len(finalizers) > 0 {
+		blockers = append(blockers, fmt.Sprintf("remaining finalizers: %s", strings.Join(finalizers, ", ")))
+	}
+
+	msg := fmt.Sprintf("The %s namespace has been terminating since %s, the user workload monitoring stack isn't updated until the namespace is recreated", ns.Name, ns.DeletionTimestamp.UTC().Format("2006-01-02T15:04:05Z"))
+	if len(blockers) > 0 {
+		msg = fmt.Sprintf("%s (%s)", msg, strings.Join(blockers, "; "))
+	}
+	return msg + "."
+}
+
+// userWorkloadNamespaceTerminating returns a message when the user workload
+// monitoring namespace is being deleted. Creating objects in the namespace
+// fails until the deletion completes, hence the user workload monitoring
+// tasks are paused instead of failing at each reconciliation. A warning
+// event explaining the remediation is emitted when the deletion is first
+// detected.
+func (o *Operator) userWorkloadNamespaceTerminating(ctx context.Context) string {
+	ns, err := o.client.GetNamespace(ctx, o.namespaceUserWorkload)
+	if err != nil {
+		klog.V(2).Infof("skipping the deletion check of the %s namespace: %v", o.namespaceUserWorkload, err)
+		return ""
+	}
+
+	msg := namespaceTerminatingMessage(ns)
+	switch {
+	case msg != "" && !o.userWorkloadNamespaceDeleting:
+		klog.Warning(msg)
+		if o.eventRecorder != nil {
+			o.eventRecorder.Warningf(userWorkloadNamespaceTerminatingReason, "%s Check the conditions of the namespace with 'oc get namespace %s -o yaml' and remove the resources or the finalizers blocking its deletion. The namespace is recreated once deleted and the operator resumes the deployment of the user workload monitoring stack.", msg, ns.Name)
+		}
+	case msg == "" && o.userWorkloadNamespaceDeleting:
+		klog.Infof("The %s namespace isn't terminating anymore, resuming the user workload monitoring tasks.", ns.Name)
+	}
+	o.userWorkloadNamespaceDeleting = msg != ""
+
+	return msg
+}
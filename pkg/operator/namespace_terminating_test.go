@@ -0,0 +1,111 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/library-go/pkg/operator/events"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func terminatingNamespace() *v1.Namespace {
+	deleted := metav1.NewTime(time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC))
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "openshift-user-workload-monitoring",
+			DeletionTimestamp: &deleted,
+		},
+		Spec: v1.NamespaceSpec{
+			Finalizers: []v1.FinalizerName{v1.FinalizerKubernetes},
+		},
+		Status: v1.NamespaceStatus{
+			Phase: v1.NamespaceTerminating,
+			Conditions: []v1.NamespaceCondition{
+				{
+					Type:    v1.NamespaceContentRemaining,
+					Status:  v1.ConditionTrue,
+					Message: "Some resources are remaining: prometheuses.monitoring.coreos.com has 1 resource instances",
+				},
+				{
+					Type:    v1.NamespaceDeletionDiscoveryFailure,
+					Status:  v1.ConditionFalse,
+					Message: "All resources successfully discovered",
+				},
+			},
+		},
+	}
+}
+
+func TestNamespaceTerminatingMessage(t *testing.T) {
+	if msg := namespaceTerminatingMessage(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}); msg != "" {
+		t.Fatalf("expected no message for an active namespace, got %q", msg)
+	}
+
+	msg := namespaceTerminatingMessage(terminatingNamespace())
+	for _, expected := range []string{
+		"openshift-user-workload-monitoring namespace has been terminating since 2022-03-01T10:00:00Z",
+		"NamespaceContentRemaining: Some resources are remaining",
+		"remaining finalizers: kubernetes",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Fatalf("expected message to contain %q, got %q", expected, msg)
+		}
+	}
+	if strings.Contains(msg, "NamespaceDeletionDiscoveryFailure") {
+		t.Fatalf("expected the false conditions to be omitted, got %q", msg)
+	}
+}
+
+func TestUserWorkloadNamespaceTerminating(t *testing.T) {
+	ctx := context.Background()
+	kclient := fake.NewSimpleClientset(terminatingNamespace())
+	recorder := events.NewInMemoryRecorder("cluster-monitoring-operator")
+	o := &Operator{
+		namespaceUserWorkload: "openshift-user-workload-monitoring",
+		client:                client.New("", "openshift-monitoring", "openshift-user-workload-monitoring", client.KubernetesClient(kclient)),
+		eventRecorder:         recorder,
+	}
+
+	for i := 0; i < 2; i++ {
+		if msg := o.userWorkloadNamespaceTerminating(ctx); msg == "" {
+			t.Fatal("expected the namespace to be reported as terminating")
+		}
+	}
+	// The remediation event is only emitted when the deletion is detected.
+	if n := len(recorder.Events()); n != 1 {
+		t.Fatalf("expected 1 event, got %d", n)
+	}
+	if reason := recorder.Events()[0].Reason; reason != userWorkloadNamespaceTerminatingReason {
+		t.Fatalf("expected reason %q, got %q", userWorkloadNamespaceTerminatingReason, reason)
+	}
+
+	// The namespace was recreated.
+	if err := kclient.Tracker().Update(v1.SchemeGroupVersion.WithResource("namespaces"), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "openshift-user-workload-monitoring"}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if msg := o.userWorkloadNamespaceTerminating(ctx); msg != "" {
+		t.Fatalf("expected no message, got %q", msg)
+	}
+	if o.userWorkloadNamespaceDeleting {
+		t.Fatal("expected the deletion to be over")
+	}
+}
@@ -17,20 +17,28 @@ package operator
 import (
 	"context"
 	"crypto/x509/pkix"
+	"flag"
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/openshift/cluster-monitoring-operator/pkg/promclient"
 	"github.com/openshift/cluster-monitoring-operator/pkg/rebalancer"
+	"github.com/openshift/cluster-monitoring-operator/pkg/recommender"
 	cmostr "github.com/openshift/cluster-monitoring-operator/pkg/strings"
 
 	"github.com/pkg/errors"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/prometheus/client_golang/prometheus"
 
 	certapiv1 "k8s.io/api/certificates/v1"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/rest"
@@ -39,11 +47,17 @@ import (
 	"k8s.io/klog/v2"
 
 	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
 	"github.com/openshift/library-go/pkg/operator/csr"
 	"github.com/openshift/library-go/pkg/operator/events"
 
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/clockskew"
+	"github.com/openshift/cluster-monitoring-operator/pkg/configreload"
+	"github.com/openshift/cluster-monitoring-operator/pkg/consolenotifications"
+	"github.com/openshift/cluster-monitoring-operator/pkg/footprint"
 	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/cluster-monitoring-operator/pkg/querycanary"
 	"github.com/openshift/cluster-monitoring-operator/pkg/tasks"
 )
 
@@ -52,6 +66,9 @@ import (
 type InfrastructureConfig struct {
 	highlyAvailableInfrastructure bool
 	hostedControlPlane            bool
+	platformType                  string
+	region                        string
+	baseDomain                    string
 }
 
 // NewDefaultInfrastructureConfig returns a default InfrastructureConfig.
@@ -73,6 +90,22 @@ func NewInfrastructureConfig(i *configv1.Infrastructure) *InfrastructureConfig {
 		ic.hostedControlPlane = true
 	}
 
+	if ps := i.Status.PlatformStatus; ps != nil {
+		ic.platformType = string(ps.Type)
+		switch {
+		case ps.AWS != nil:
+			ic.region = ps.AWS.Region
+		case ps.GCP != nil:
+			ic.region = ps.GCP.Region
+		case ps.IBMCloud != nil:
+			ic.region = ps.IBMCloud.Location
+		case ps.PowerVS != nil:
+			ic.region = ps.PowerVS.Region
+		case ps.AlibabaCloud != nil:
+			ic.region = ps.AlibabaCloud.Region
+		}
+	}
+
 	return ic
 }
 
@@ -86,6 +119,21 @@ func (ic *InfrastructureConfig) HostedControlPlane() bool {
 	return ic.hostedControlPlane
 }
 
+// PlatformType implements the InfrastructureReader interface.
+func (ic *InfrastructureConfig) PlatformType() string {
+	return ic.platformType
+}
+
+// Region implements the InfrastructureReader interface.
+func (ic *InfrastructureConfig) Region() string {
+	return ic.region
+}
+
+// BaseDomain implements the InfrastructureReader interface.
+func (ic *InfrastructureConfig) BaseDomain() string {
+	return ic.baseDomain
+}
+
 // ProxyConfig stores information about the proxy configuration.
 type ProxyConfig struct {
 	httpProxy  string
@@ -120,6 +168,10 @@ func (pc *ProxyConfig) NoProxy() string {
 const (
 	resyncPeriod = 15 * time.Minute
 
+	// maxConfigLoadAttempts is the number of times the configuration is
+	// loaded before giving up when the ConfigMaps are modified concurrently.
+	maxConfigLoadAttempts = 5
+
 	// see https://github.com/kubernetes/apiserver/blob/b571c70e6e823fd78910c3f5b9be895a756f4cbb/pkg/server/options/authentication.go#L239
 	apiAuthenticationConfigMap    = "kube-system/extension-apiserver-authentication"
 	kubeletServingCAConfigMap     = "openshift-config-managed/kubelet-serving-ca"
@@ -129,14 +181,27 @@ const (
 	alertmanagerCABundleConfigMap = "openshift-monitoring/alertmanager-trusted-ca-bundle"
 	grpcTLS                       = "openshift-monitoring/grpc-tls"
 	metricsClientCerts            = "openshift-monitoring/metrics-client-certs"
+	// The user-provided Alertmanager configuration is merged with the route
+	// defaults of the operator configuration.
+	alertmanagerConfigSecret = "openshift-monitoring/alertmanager-main"
+
+	// Label of the ConfigMaps holding the console dashboards.
+	consoleDashboardLabel = "console.openshift.io/dashboard"
 
 	// Canonical name of the cluster-wide infrastrucure resource.
 	clusterResourceName = "cluster"
+
+	// Credentials used to query Thanos Querier for the Prometheus resource
+	// recommendations.
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceCAFile           = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
 )
 
 type Operator struct {
 	namespace, namespaceUserWorkload string
 
+	version string
+
 	configMapName             string
 	userWorkloadConfigMapName string
 	images                    map[string]string
@@ -151,20 +216,96 @@ type Operator struct {
 	client *client.Client
 
 	cmapInf              cache.SharedIndexInformer
+	secretInf            cache.SharedIndexInformer
+	clusterMonitoringInf cache.SharedIndexInformer
+	prometheusRuleInf    cache.SharedIndexInformer
+	dashboardInf         cache.SharedIndexInformer
 	informers            []cache.SharedIndexInformer
 	informerFactories    []informers.SharedInformerFactory
 	controllersToRunFunc []func(ctx context.Context, workers int)
 
-	queue workqueue.RateLimitingInterface
+	queue       workqueue.RateLimitingInterface
+	rateLimiter *dynamicRateLimiter
+	// driftQueue holds the kinds of the workloads modified outside of the
+	// operator. Each kind is rate-limited independently before triggering
+	// a reconciliation.
+	driftQueue workqueue.RateLimitingInterface
+
+	// defaultLogLevel is the log level set from the command-line flags.
+	defaultLogLevel string
+	resyncMtx       sync.RWMutex
+	resync          time.Duration
+	resyncUpdated   chan struct{}
+
+	// additionalDashboardsNs is the namespace whose dashboards are copied to
+	// the console, as set by the last reconciliation.
+	additionalDashboardsMtx sync.RWMutex
+	additionalDashboardsNs  string
 
 	reconcileAttempts prometheus.Counter
 	reconcileStatus   prometheus.Gauge
 
+	preflightCheckStatus *prometheus.GaugeVec
+
+	prometheusRulesOverQuota prometheus.Gauge
+	monitorsOverTargetQuota  prometheus.Gauge
+
+	unknownConfigFields prometheus.Gauge
+
+	storageClassDrift *prometheus.GaugeVec
+
+	excludedRules *prometheus.GaugeVec
+
+	unmanagedDrift *prometheus.GaugeVec
+
+	userAlertsAggregated *prometheus.GaugeVec
+
 	failedReconcileAttempts int
 
+	// lastTaskInputs holds the inputs hash of the last successful run of
+	// each task. The tasks whose inputs didn't change are skipped unless
+	// fullSyncRequested is set.
+	lastTaskInputs    map[string]string
+	fullSyncRequested int32
+
+	// taskResults holds the results of the last run of each task reported
+	// in the conditions, see taskResults.
+	taskResults taskResults
+
+	// alertmanagerWarnings holds the result of the last analysis of the
+	// Alertmanager configuration, the warnings are only reported again
+	// when it changes.
+	alertmanagerWarnings []string
+
+	// userWorkloadNamespaceDeleting is true while the user workload
+	// monitoring namespace is terminating.
+	userWorkloadNamespaceDeleting bool
+
+	// syncMtx serializes the reconciliations so that the stack is never
+	// rendered from two configurations at once.
+	syncMtx sync.Mutex
+
 	assets *manifests.Assets
 
 	rebalancer *rebalancer.Rebalancer
+
+	eventRecorder events.Recorder
+
+	recommender *recommender.Recommender
+
+	// labelQuerier queries Thanos Querier for the user alerts aggregated by
+	// the Alertmanager throttling.
+	labelQuerier promclient.LabelQuerier
+
+	footprint *footprint.Reporter
+
+	configReloadChecker *configreload.Checker
+
+	clockSkewChecker *clockskew.Checker
+
+	consoleNotifications *consolenotifications.Controller
+
+	queryCanary *querycanary.Canary
 }
 
 func New(
@@ -182,6 +323,7 @@ func New(
 	}
 
 	o := &Operator{
+		version:                   version,
 		images:                    images,
 		telemetryMatches:          telemetryMatches,
 		configMapName:             configMapName,
@@ -191,39 +333,90 @@ func New(
 		namespace:                 namespace,
 		namespaceUserWorkload:     namespaceUserWorkload,
 		client:                    c,
-		queue:                     workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(50*time.Millisecond, 3*time.Minute), "cluster-monitoring"),
+		rateLimiter:               newDynamicRateLimiter(defaultRateLimitBaseDelay, defaultRateLimitMaxDelay),
+		defaultLogLevel:           "0",
+		resyncUpdated:             make(chan struct{}, 1),
 		informers:                 make([]cache.SharedIndexInformer, 0),
 		assets:                    a,
 		informerFactories:         make([]informers.SharedInformerFactory, 0),
 		controllersToRunFunc:      make([]func(context.Context, int), 0),
 		rebalancer:                rebalancer.NewRebalancer(ctx, c.KubernetesInterface()),
 	}
+	o.queue = workqueue.NewNamedRateLimitingQueue(o.rateLimiter, "cluster-monitoring")
+	o.driftQueue = workqueue.NewNamedRateLimitingQueue(newKindRateLimiter(driftInterval), "cluster-monitoring-drift")
+	if f := flag.CommandLine.Lookup("v"); f != nil {
+		o.defaultLogLevel = f.Value.String()
+	}
 
-	informer := cache.NewSharedIndexInformer(
+	querier, err := promclient.NewHTTPQuerier(fmt.Sprintf("https://thanos-querier.%s.svc:9091", namespace), serviceAccountTokenFile, serviceCAFile)
+	if err != nil {
+		klog.Warningf("Prometheus resource recommendations are disabled: %v", err)
+		o.footprint = footprint.New(c.KubernetesInterface(), nil, namespace, namespaceUserWorkload)
+	} else {
+		o.recommender = recommender.New(querier, namespace)
+		o.configReloadChecker = configreload.New(querier, configreload.DefaultGracePeriod)
+		o.clockSkewChecker = clockskew.New(querier, clockskew.DefaultThreshold)
+		o.labelQuerier = querier
+		o.footprint = footprint.New(c.KubernetesInterface(), querier, namespace, namespaceUserWorkload)
+		o.queryCanary = querycanary.New(querier, querycanary.DefaultQueries)
+		o.controllersToRunFunc = append(o.controllersToRunFunc, o.queryCanary.Run)
+	}
+
+	alertsGetter, err := consolenotifications.NewHTTPAlertsGetter(fmt.Sprintf("https://prometheus-k8s.%s.svc:9091", namespace), serviceAccountTokenFile, serviceCAFile)
+	if err != nil {
+		klog.Warningf("Console notifications are disabled: %v", err)
+	} else {
+		o.consoleNotifications = consolenotifications.NewController(c, alertsGetter)
+		o.controllersToRunFunc = append(o.controllersToRunFunc, o.consoleNotifications.Run)
+	}
+
+	o.secretInf = cache.NewSharedIndexInformer(
 		o.client.SecretListWatchForNamespace(namespace), &v1.Secret{}, resyncPeriod, cache.Indexers{},
 	)
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	o.secretInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    o.handleEvent,
-		UpdateFunc: func(_, newObj interface{}) { o.handleEvent(newObj) },
+		UpdateFunc: handleUpdate(o.handleEvent),
 		DeleteFunc: o.handleEvent,
 	})
-	o.informers = append(o.informers, informer)
+	o.informers = append(o.informers, o.secretInf)
 
 	o.cmapInf = cache.NewSharedIndexInformer(
 		o.client.ConfigMapListWatchForNamespace(namespace), &v1.ConfigMap{}, resyncPeriod, cache.Indexers{},
 	)
 	o.cmapInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    o.handleEvent,
-		UpdateFunc: func(_, newObj interface{}) { o.handleEvent(newObj) },
+		UpdateFunc: handleUpdate(o.handleEvent),
 		DeleteFunc: o.handleEvent,
 	})
 
+	// The ClusterMonitoring and UserWorkloadMonitoring resources take
+	// precedence over the configuration ConfigMaps.
+	o.clusterMonitoringInf = cache.NewSharedIndexInformer(
+		o.client.ClusterMonitoringListWatch(ctx), &unstructured.Unstructured{}, resyncPeriod, cache.Indexers{},
+	)
+	o.clusterMonitoringInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    o.handleConfigResourceEvent,
+		UpdateFunc: handleUpdate(o.handleConfigResourceEvent),
+		DeleteFunc: o.handleConfigResourceEvent,
+	})
+	o.informers = append(o.informers, o.clusterMonitoringInf)
+
+	informer := cache.NewSharedIndexInformer(
+		o.client.UserWorkloadMonitoringListWatch(ctx), &unstructured.Unstructured{}, resyncPeriod, cache.Indexers{},
+	)
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    o.handleConfigResourceEvent,
+		UpdateFunc: handleUpdate(o.handleConfigResourceEvent),
+		DeleteFunc: o.handleConfigResourceEvent,
+	})
+	o.informers = append(o.informers, informer)
+
 	informer = cache.NewSharedIndexInformer(
 		o.client.ConfigMapListWatchForNamespace(namespaceUserWorkload), &v1.ConfigMap{}, resyncPeriod, cache.Indexers{},
 	)
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    o.handleEvent,
-		UpdateFunc: func(_, newObj interface{}) { o.handleEvent(newObj) },
+		UpdateFunc: handleUpdate(o.handleEvent),
 		DeleteFunc: o.handleEvent,
 	})
 	o.informers = append(o.informers, informer)
@@ -233,7 +426,7 @@ func New(
 		&v1.ConfigMap{}, resyncPeriod, cache.Indexers{},
 	)
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		UpdateFunc: func(_, newObj interface{}) { o.handleEvent(newObj) },
+		UpdateFunc: handleUpdate(o.handleEvent),
 	})
 	o.informers = append(o.informers, informer)
 
@@ -242,16 +435,32 @@ func New(
 		&v1.ConfigMap{}, resyncPeriod, cache.Indexers{},
 	)
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		UpdateFunc: func(_, newObj interface{}) { o.handleEvent(newObj) },
+		AddFunc:    o.handleDashboardEvent,
+		UpdateFunc: handleUpdate(o.handleEvent),
+		DeleteFunc: o.handleDashboardEvent,
 	})
 	o.informers = append(o.informers, informer)
 
+	// The dashboards of the additional dashboards namespace are copied to the
+	// console. The namespace is only known once the configuration is loaded:
+	// watch the dashboards of all namespaces and filter the events.
+	o.dashboardInf = cache.NewSharedIndexInformer(
+		o.client.ConfigMapListWatchForSelector(metav1.NamespaceAll, manifests.ConsoleDashboardsSelector),
+		&v1.ConfigMap{}, resyncPeriod, cache.Indexers{},
+	)
+	o.dashboardInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    o.handleAdditionalDashboardEvent,
+		UpdateFunc: handleUpdate(o.handleAdditionalDashboardEvent),
+		DeleteFunc: o.handleAdditionalDashboardEvent,
+	})
+	o.informers = append(o.informers, o.dashboardInf)
+
 	informer = cache.NewSharedIndexInformer(
 		o.client.ConfigMapListWatchForNamespace("openshift-config"),
 		&v1.ConfigMap{}, resyncPeriod, cache.Indexers{},
 	)
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		UpdateFunc: func(_, newObj interface{}) { o.handleEvent(newObj) },
+		UpdateFunc: handleUpdate(o.handleEvent),
 	})
 	o.informers = append(o.informers, informer)
 
@@ -260,7 +469,7 @@ func New(
 		&configv1.Infrastructure{}, resyncPeriod, cache.Indexers{},
 	)
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		UpdateFunc: func(_, newObj interface{}) { o.handleEvent(newObj) },
+		UpdateFunc: handleUpdate(o.handleEvent),
 	})
 	o.informers = append(o.informers, informer)
 
@@ -270,9 +479,7 @@ func New(
 	)
 
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		UpdateFunc: func(_, newObj interface{}) {
-			o.handleEvent(newObj)
-		},
+		UpdateFunc: handleUpdate(o.handleEvent),
 	})
 	o.informers = append(o.informers, informer)
 
@@ -283,11 +490,33 @@ func New(
 			&v1.PersistentVolumeClaim{}, resyncPeriod, cache.Indexers{},
 		)
 		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-			UpdateFunc: func(_, newObj interface{}) { o.handleEvent(newObj) },
+			UpdateFunc: handleUpdate(o.handleEvent),
 		})
 		o.informers = append(o.informers, informer)
 	}
 
+	// Watch the workloads deployed by the operator to revert the changes made
+	// by other actors as soon as they happen.
+	for _, ns := range []string{o.namespace, o.namespaceUserWorkload} {
+		for _, w := range managedWorkloads {
+			informer = cache.NewSharedIndexInformer(
+				o.client.ManagedWorkloadListWatchForNamespace(w.resource, ns),
+				w.obj, 0, cache.Indexers{},
+			)
+			informer.AddEventHandler(o.driftEventHandler(w.kind))
+			o.informers = append(o.informers, informer)
+		}
+	}
+
+	// The PrometheusRules are only watched to attribute the rule evaluation
+	// failures to their namespace and to count the rules by origin, they
+	// don't trigger reconciliations.
+	o.prometheusRuleInf = cache.NewSharedIndexInformer(
+		o.client.PrometheusRuleMetadataListWatch(ctx),
+		&monv1.PrometheusRule{}, resyncPeriod, cache.Indexers{},
+	)
+	o.informers = append(o.informers, o.prometheusRuleInf)
+
 	kubeInformersOperatorNS := informers.NewSharedInformerFactoryWithOptions(
 		c.KubernetesInterface(),
 		resyncPeriod,
@@ -300,12 +529,15 @@ func New(
 		klog.Warningf("unable to get owner reference (falling back to namespace): %v", err)
 	}
 
-	eventRecorder := events.NewKubeRecorderWithOptions(
+	o.eventRecorder = events.NewKubeRecorderWithOptions(
 		o.client.KubernetesInterface().CoreV1().Events(namespace),
 		events.RecommendedClusterSingletonCorrelatorOptions(),
 		"cluster-monitoring-operator",
 		controllerRef,
 	)
+	// Report the objects created, updated and deleted by the operator next to
+	// its other events.
+	o.client.SetActionEventRecorder(o.eventRecorder)
 
 	csrController, err := csr.NewClientCertificateController(
 		csr.ClientCertOption{
@@ -326,7 +558,7 @@ func New(
 		o.client.KubernetesInterface().CertificatesV1().CertificateSigningRequests(),
 		kubeInformersOperatorNS.Core().V1().Secrets(),
 		o.client.KubernetesInterface().CoreV1(),
-		eventRecorder,
+		o.eventRecorder,
 		"OpenShiftMonitoringClientCertRequester",
 	)
 
@@ -350,16 +582,78 @@ func (o *Operator) RegisterMetrics(r prometheus.Registerer) {
 		Help: "Latest reconciliation state. Set to 1 if last reconciliation succeeded, else 0.",
 	})
 
+	o.preflightCheckStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_monitoring_operator_preflight_check_successful",
+		Help: "Result of the preflight checks run at startup. Set to 1 if the check passed, else 0.",
+	}, []string{"check"})
+
+	o.prometheusRulesOverQuota = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cluster_monitoring_operator_user_workload_prometheus_rules_over_quota",
+		Help: "Number of user-defined PrometheusRules ignored because they exceed the rule quota of their namespace.",
+	})
+
+	o.monitorsOverTargetQuota = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cluster_monitoring_operator_user_workload_monitors_over_target_quota",
+		Help: "Number of user-defined ServiceMonitors and PodMonitors ignored because their targets exceed the target quota of their namespace.",
+	})
+
+	o.unknownConfigFields = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cluster_monitoring_operator_config_unknown_fields",
+		Help: "Number of fields of the configuration ConfigMaps unknown to the operator and ignored.",
+	})
+
+	o.storageClassDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_monitoring_operator_storage_class_drift",
+		Help: "Number of persistent volume claims of the component using another storage class than the configured or default one.",
+	}, []string{"component"})
+
+	o.excludedRules = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_monitoring_operator_excluded_rules",
+		Help: "Number of platform rules removed by the configured rule exclusion.",
+	}, []string{"group", "alert"})
+
+	o.unmanagedDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_monitoring_operator_unmanaged_object_drifted_fields",
+		Help: "Number of fields of the object with the unmanaged annotation which differ from the desired state.",
+	}, []string{"kind", "namespace", "name"})
+
+	o.userAlertsAggregated = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_monitoring_operator_user_alerts_aggregated",
+		Help: "Number of firing user-defined alerts of the namespace aggregated into a single throttled notification group by Alertmanager.",
+	}, []string{"namespace"})
+
 	r.MustRegister(
 		o.reconcileAttempts,
 		o.reconcileStatus,
+		o.preflightCheckStatus,
+		o.prometheusRulesOverQuota,
+		o.monitorsOverTargetQuota,
+		o.unknownConfigFields,
+		o.storageClassDrift,
+		o.excludedRules,
+		o.unmanagedDrift,
+		o.userAlertsAggregated,
+		newPrometheusRuleCollector(o.prometheusRuleInf.GetStore()),
+		newOriginCollector(o.prometheusRuleInf.GetStore(), o.dashboardInf.GetStore()),
+		newGRPCTLSCollector(o.secretInf.GetStore(), o.namespace),
 	)
+
+	if o.queryCanary != nil {
+		r.MustRegister(o.queryCanary)
+	}
+}
+
+// FootprintHandler returns the HTTP handler reporting the resources consumed
+// by the monitoring stack.
+func (o *Operator) FootprintHandler() http.Handler {
+	return o.footprint.Handler()
 }
 
 // Run the controller.
 func (o *Operator) Run(ctx context.Context) error {
 	stopc := ctx.Done()
 	defer o.queue.ShutDown()
+	defer o.driftQueue.ShutDown()
 
 	errChan := make(chan error)
 	go func() {
@@ -402,32 +696,58 @@ func (o *Operator) Run(ctx context.Context) error {
 	}
 	klog.V(4).Info("Initial cache sync done.")
 
+	// The preflight report is published before the first reconciliation
+	// to surface missing prerequisites early.
+	o.preflight(ctx)
+
 	for _, r := range o.controllersToRunFunc {
 		go r(ctx, 1)
 	}
 
 	go o.worker(ctx)
+	go o.driftWorker()
 
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
+	o.reloadOperatorConfig()
 
+	// The reconciliations are triggered by the changes of the configuration
+	// and of the managed workloads. Without configuration, nothing triggers
+	// the first one.
 	key := o.namespace + "/" + o.configMapName
-	_, exists, _ := o.cmapInf.GetStore().GetByKey(key)
-	if !exists {
-		klog.Infof("ConfigMap to configure stack does not exist. Reconciling with default config every %s.", resyncPeriod)
+	if !o.configExists(key) {
+		klog.Info("ConfigMap to configure stack does not exist. Reconciling with default config.")
 		o.enqueue(key)
 	}
 
+	var (
+		resyncTicker *time.Ticker
+		resyncC      <-chan time.Time
+	)
+	updateResync := func() {
+		if resyncTicker != nil {
+			resyncTicker.Stop()
+			resyncTicker, resyncC = nil, nil
+		}
+		if d := o.resyncPeriod(); d > 0 {
+			resyncTicker = time.NewTicker(d)
+			resyncC = resyncTicker.C
+		}
+	}
+	defer func() {
+		if resyncTicker != nil {
+			resyncTicker.Stop()
+		}
+	}()
+
 	for {
 		select {
 		case <-stopc:
 			return nil
-		case <-ticker.C:
-			_, exists, _ := o.cmapInf.GetStore().GetByKey(key)
-			if !exists {
-				klog.Infof("ConfigMap to configure stack does not exist. Reconciling with default config every %s.", resyncPeriod)
-				o.enqueue(key)
-			}
+		case <-o.resyncUpdated:
+			updateResync()
+		case <-resyncC:
+			klog.Infof("Triggering a periodic resync every %s.", o.resyncPeriod())
+			o.requestFullSync()
+			o.enqueue(key)
 		}
 	}
 }
@@ -441,23 +761,92 @@ func (o *Operator) keyFunc(obj interface{}) (string, bool) {
 	return k, true
 }
 
+// handleDashboardEvent triggers an update when a console dashboard is added
+// or removed so that Grafana picks it up. Updates are handled by
+// handleEvent.
+func (o *Operator) handleDashboardEvent(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok || cm.Labels[consoleDashboardLabel] != "true" {
+		return
+	}
+
+	klog.Infof("Triggering update due to console dashboard: %s/%s", cm.Namespace, cm.Name)
+	o.requestFullSync()
+	o.enqueue(o.namespace + "/" + o.configMapName)
+}
+
+// handleAdditionalDashboardEvent triggers an update when a dashboard of the
+// additional dashboards namespace changes so that its copy is updated.
+func (o *Operator) handleAdditionalDashboardEvent(obj interface{}) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok || cm.Namespace == "" || cm.Namespace != o.additionalDashboardsNamespace() {
+		return
+	}
+
+	klog.Infof("Triggering update due to additional dashboard: %s/%s", cm.Namespace, cm.Name)
+	o.requestFullSync()
+	o.enqueue(o.namespace + "/" + o.configMapName)
+}
+
+func (o *Operator) additionalDashboardsNamespace() string {
+	o.additionalDashboardsMtx.RLock()
+	defer o.additionalDashboardsMtx.RUnlock()
+	return o.additionalDashboardsNs
+}
+
+func (o *Operator) setAdditionalDashboardsNamespace(ns string) {
+	o.additionalDashboardsMtx.Lock()
+	defer o.additionalDashboardsMtx.Unlock()
+	o.additionalDashboardsNs = ns
+}
+
+// handleConfigResourceEvent triggers an update when a ClusterMonitoring or
+// UserWorkloadMonitoring resource changes.
+func (o *Operator) handleConfigResourceEvent(obj interface{}) {
+	key, ok := o.keyFunc(obj)
+	if !ok {
+		return
+	}
+
+	klog.Infof("Triggering an update due to the configuration resource: %s", key)
+	o.enqueue(o.namespace + "/" + o.configMapName)
+}
+
 func (o *Operator) handleEvent(obj interface{}) {
 	cmoConfigMap := o.namespace + "/" + o.configMapName
 
+	if cm, ok := obj.(*v1.ConfigMap); ok && cm.Labels[consoleDashboardLabel] == "true" {
+		klog.Infof("Triggering update due to console dashboard update: %s/%s", cm.Namespace, cm.Name)
+		o.requestFullSync()
+		o.enqueue(cmoConfigMap)
+		return
+	}
+
 	if _, ok := obj.(*configv1.Infrastructure); ok {
 		klog.Infof("Triggering update due to an infrastructure update")
+		o.requestFullSync()
 		o.enqueue(cmoConfigMap)
 		return
 	}
 
 	if _, ok := obj.(*configv1.APIServer); ok {
 		klog.Infof("Triggering update due to an apiserver config update")
+		o.requestFullSync()
 		o.enqueue(cmoConfigMap)
 		return
 	}
 
 	if _, ok := obj.(*v1.PersistentVolumeClaim); ok {
 		klog.Info("Triggering update due to a PVC update")
+		o.requestFullSync()
 		o.enqueue(cmoConfigMap)
 		return
 	}
@@ -469,6 +858,11 @@ func (o *Operator) handleEvent(obj interface{}) {
 
 	klog.V(5).Infof("ConfigMap or Secret updated: %s", key)
 
+	if key == o.namespace+"/"+operatorConfigMapName {
+		o.reloadOperatorConfig()
+		return
+	}
+
 	uwmConfigMap := o.namespaceUserWorkload + "/" + o.userWorkloadConfigMapName
 
 	switch key {
@@ -481,6 +875,7 @@ func (o *Operator) handleEvent(obj interface{}) {
 	case alertmanagerCABundleConfigMap:
 	case grpcTLS:
 	case metricsClientCerts:
+	case alertmanagerConfigSecret:
 	case uwmConfigMap:
 	default:
 		klog.V(5).Infof("ConfigMap or Secret (%s) not triggering an update.", key)
@@ -488,6 +883,11 @@ func (o *Operator) handleEvent(obj interface{}) {
 	}
 
 	klog.Infof("Triggering an update due to ConfigMap or Secret: %s", key)
+	// Only the configuration changes are covered by the inputs of the
+	// tasks.
+	if key != cmoConfigMap && key != uwmConfigMap {
+		o.requestFullSync()
+	}
 
 	// Always enqueue the cluster monitoring operator configmap.
 	// That way we reuse the same synchronization logic for all triggering object changes.
@@ -539,6 +939,9 @@ func (o *Operator) enqueue(obj interface{}) {
 }
 
 func (o *Operator) sync(ctx context.Context, key string) error {
+	o.syncMtx.Lock()
+	defer o.syncMtx.Unlock()
+
 	// The operator may have left some nodes as unschedulable during a previous
 	// sync in an attempt to rebalance workloads.
 	// Ensure that the nodes are switched back to schedulable first.
@@ -553,9 +956,56 @@ func (o *Operator) sync(ctx context.Context, key string) error {
 		return err
 	}
 	config.SetImages(o.images)
-	config.SetTelemetryMatches(o.telemetryMatches)
+	config.OperatorVersion = o.version
+	if err := config.SetTelemetryMatches(o.telemetryMatches); err != nil {
+		o.reportError(ctx, err, "InvalidConfiguration")
+		return err
+	}
 	config.SetRemoteWrite(o.remoteWrite)
 
+	// The conditions reported by the reconciliation are collected and
+	// written to the ClusterOperator status in a single update at the end.
+	conditions := []client.Condition{o.reportUnknownFields(ctx, config)}
+	if config.ClusterMonitoringConfiguration.Strict {
+		if err := config.CheckUnknownFields(); err != nil {
+			o.reportError(ctx, err, "UnknownConfigFields", conditions...)
+			return err
+		}
+	}
+
+	if err := config.ApplyOverrides(); err != nil {
+		o.reportError(ctx, err, "InvalidConfiguration", conditions...)
+		return err
+	}
+
+	// The optional components are torn down before the operator is removed.
+	if config.ClusterMonitoringConfiguration.Teardown.Uninstall {
+		klog.Info("The removal of the operator is requested, tearing down the optional components.")
+		config.Uninstalling = true
+		config.DisableOptionalComponents()
+	}
+
+	// The hash is reported in the events attached to the updated objects
+	// and in the task state ConfigMap.
+	configHash, err := config.Hash()
+	if err != nil {
+		klog.Warningf("failed to compute the configuration hash: %v", err)
+	} else {
+		ctx = client.WithConfigHash(ctx, configHash)
+	}
+
+	var notAvailableFeatures []string
+	hasRoutes, err := o.client.HasRouteCapability()
+	if err != nil {
+		o.reportError(ctx, err, "RouteCapabilityCheckFailed", conditions...)
+		return err
+	}
+	if !hasRoutes {
+		klog.Warning("Route API isn't available, skipping the reconciliation of Route objects.")
+		config.RouteAPIUnavailable = true
+		notAvailableFeatures = append(notAvailableFeatures, "Routes (route.openshift.io/v1)")
+	}
+
 	var proxyConfig manifests.ProxyReader
 	proxyConfig, err = o.loadProxyConfig(ctx)
 	if err != nil {
@@ -567,46 +1017,181 @@ func (o *Operator) sync(ctx context.Context, key string) error {
 	apiServerConfig, err = o.loadApiServerConfig(ctx)
 
 	if err != nil {
-		o.reportError(ctx, err, "APIServerConfigError")
+		o.reportError(ctx, err, "APIServerConfigError", conditions...)
 		return err
 	}
 
-	factory := manifests.NewFactory(o.namespace, o.namespaceUserWorkload, config, o.loadInfrastructureConfig(ctx), proxyConfig, o.assets, apiServerConfig)
+	infrastructureConfig := o.loadInfrastructureConfig(ctx)
+	factory := manifests.NewFactory(o.namespace, o.namespaceUserWorkload, config, infrastructureConfig, proxyConfig, o.assets, apiServerConfig)
+	o.setAdditionalDashboardsNamespace(config.ClusterMonitoringConfiguration.ConsoleDashboards.AdditionalDashboardsNamespace)
+	namespaceQuotas := tasks.NewNamespaceQuotasTask(o.client, config, o.eventRecorder)
+	storageClassDrift := tasks.NewStorageClassDriftTask(o.client, config)
+	userAlertsThrottling := tasks.NewUserAlertsThrottlingTask(o.client, config, o.labelQuerier)
+	teardown := tasks.NewTeardownTask(o.client, config)
+	alertmanagerAnalyzer := tasks.NewAlertmanagerAnalyzerTask(o.client, factory, config, o.eventRecorder, o.alertmanagerWarnings)
+
+	var userWorkloadNamespaceTerminating string
+	if *config.ClusterMonitoringConfiguration.UserWorkloadEnabled {
+		userWorkloadNamespaceTerminating = o.userWorkloadNamespaceTerminating(ctx)
+		config.UserWorkloadNamespaceTerminating = userWorkloadNamespaceTerminating != ""
+	}
+
+	// The tasks run as soon as the tasks they depend on succeeded.
+	// prometheus-operator is updated before the components whose resources
+	// it manages (e.g. Prometheus, Alertmanager, Thanos Ruler, ...) or
+	// validates (ServiceMonitors, PrometheusRules). The metrics scraping
+	// client CA is referenced by Prometheus and the console dashboards are
+	// deployed before Grafana aggregates them.
+	metricsClientCA := tasks.NewTaskSpec("Updating metrics scraping client CA", tasks.NewMetricsClientCATask(o.client, factory, config))
+	prometheusOperator := tasks.NewTaskSpec("Updating Prometheus Operator", tasks.NewPrometheusOperatorTask(o.client, factory))
+	consoleDashboards := tasks.NewTaskSpec("Updating console dashboards", tasks.NewConsoleDashboardsTask(o.client, factory))
+	prometheusOperatorUserWorkload := tasks.NewTaskSpec("Updating user workload Prometheus Operator", tasks.NewPrometheusOperatorUserWorkloadTask(o.client, factory, config)).After(prometheusOperator)
+	clusterMonitoringOperator := tasks.NewTaskSpec("Updating Cluster Monitoring Operator", tasks.NewClusterMonitoringOperatorTask(o.client, factory, config)).After(prometheusOperator)
+	grafana := tasks.NewTaskSpec("Updating Grafana", tasks.NewGrafanaTask(o.client, factory, config)).After(prometheusOperator, consoleDashboards)
+	prometheusK8s := tasks.NewTaskSpec("Updating Prometheus-k8s", tasks.NewPrometheusTask(o.client, factory, config, o.recommender)).After(prometheusOperator, metricsClientCA)
+	prometheusUserWorkload := tasks.NewTaskSpec("Updating Prometheus-user-workload", tasks.NewPrometheusUserWorkloadTask(o.client, factory, config)).After(prometheusOperatorUserWorkload, metricsClientCA)
+	alertmanager := tasks.NewTaskSpec("Updating Alertmanager", tasks.NewAlertmanagerTask(o.client, factory, config)).After(prometheusOperator)
+	nodeExporter := tasks.NewTaskSpec("Updating node-exporter", tasks.NewNodeExporterTask(o.client, factory, config)).After(prometheusOperator)
+	kubeStateMetrics := tasks.NewTaskSpec("Updating kube-state-metrics", tasks.NewKubeStateMetricsTask(o.client, factory, config)).After(prometheusOperator)
+	openShiftStateMetrics := tasks.NewTaskSpec("Updating openshift-state-metrics", tasks.NewOpenShiftStateMetricsTask(o.client, factory, config)).After(prometheusOperator)
+	prometheusAdapter := tasks.NewTaskSpec("Updating prometheus-adapter", tasks.NewPrometheusAdapterTask(ctx, o.namespace, o.client, factory, config)).After(prometheusOperator)
+	metricsServer := tasks.NewTaskSpec("Updating metrics-server", tasks.NewMetricsServerTask(o.client, factory, config)).After(prometheusOperator)
+	telemeterClient := tasks.NewTaskSpec("Updating Telemeter client", tasks.NewTelemeterClientTask(o.client, factory, config)).After(prometheusOperator)
+	thanosQuerier := tasks.NewTaskSpec("Updating Thanos Querier", tasks.NewThanosQuerierTask(o.client, factory, config)).After(prometheusOperator)
+	thanosRulerUserWorkload := tasks.NewTaskSpec("Updating User Workload Thanos Ruler", tasks.NewThanosRulerUserWorkloadTask(o.client, factory, config)).After(prometheusOperatorUserWorkload)
+	thanosReceive := tasks.NewTaskSpec("Updating Thanos Receive", tasks.NewThanosReceiveTask(o.client, factory, config)).After(prometheusOperator)
+	controlPlane := tasks.NewTaskSpec("Updating Control Plane components", tasks.NewControlPlaneTask(o.client, factory, config)).After(prometheusOperator)
+	consoleNotifications := tasks.NewTaskSpec("Updating console notifications", tasks.NewConsoleNotificationsTask(o.consoleNotifications, config)).After(prometheusK8s)
+	queryCanary := tasks.NewTaskSpec("Updating query canary", tasks.NewQueryCanaryTask(o.queryCanary, config)).After(thanosQuerier)
+	if userWorkloadNamespaceTerminating != "" {
+		for _, ts := range []*tasks.TaskSpec{prometheusOperatorUserWorkload, prometheusUserWorkload, thanosRulerUserWorkload} {
+			ts.Pause(userWorkloadNamespaceTerminating)
+		}
+	}
+
+	// The tasks of the unmanaged components don't run, the objects of these
+	// components are left as they are.
+	overridableTasks := map[string]*tasks.TaskSpec{
+		"prometheusOperator":    prometheusOperator,
+		"prometheusK8s":         prometheusK8s,
+		"alertmanagerMain":      alertmanager,
+		"nodeExporter":          nodeExporter,
+		"kubeStateMetrics":      kubeStateMetrics,
+		"openshiftStateMetrics": openShiftStateMetrics,
+		"grafana":               grafana,
+		"telemeterClient":       telemeterClient,
+		"k8sPrometheusAdapter":  prometheusAdapter,
+		"metricsServer":         metricsServer,
+		"thanosQuerier":         thanosQuerier,
+		"thanosReceive":         thanosReceive,
+	}
+	for _, component := range config.ComponentsInState(operatorv1.Unmanaged) {
+		if ts, found := overridableTasks[component]; found {
+			ts.Pause(fmt.Sprintf("the %s component is unmanaged", component))
+		}
+	}
+
+	// The tasks updating the components are skipped when their inputs
+	// didn't change since their last successful run. The sections of the
+	// configuration read by a single component (or a few) are only inputs
+	// of their tasks, the other sections are inputs of all the tasks. The
+	// tasks checking the state of the cluster always run.
+	componentTasks := []*tasks.TaskSpec{
+		prometheusOperator,
+		prometheusOperatorUserWorkload,
+		clusterMonitoringOperator,
+		grafana,
+		prometheusK8s,
+		prometheusUserWorkload,
+		alertmanager,
+		nodeExporter,
+		kubeStateMetrics,
+		openShiftStateMetrics,
+		prometheusAdapter,
+		metricsServer,
+		telemeterClient,
+		thanosQuerier,
+		thanosRulerUserWorkload,
+		thanosReceive,
+		controlPlane,
+		consoleNotifications,
+		queryCanary,
+	}
+	err = hashTaskInputs(
+		config.ClusterMonitoringConfiguration,
+		map[string][]*tasks.TaskSpec{
+			"prometheusOperator":    {prometheusOperator},
+			"nodeExporter":          {nodeExporter},
+			"kubeStateMetrics":      {kubeStateMetrics},
+			"openshiftStateMetrics": {openShiftStateMetrics},
+			"k8sPrometheusAdapter":  {prometheusAdapter},
+			"metricsServer":         {metricsServer, prometheusAdapter},
+			"thanosQuerier":         {thanosQuerier},
+			"thanosReceive":         {thanosReceive, thanosQuerier},
+			"consoleNotifications":  {consoleNotifications},
+			"queryCanary":           {queryCanary},
+		},
+		[]interface{}{
+			o.version,
+			config.Images,
+			config.RemoteWrite,
+			config.RouteAPIUnavailable,
+			config.UserWorkloadNamespaceTerminating,
+			config.UserWorkloadConfiguration,
+			[]string{proxyConfig.HTTPProxy(), proxyConfig.HTTPSProxy(), proxyConfig.NoProxy()},
+			fmt.Sprintf("%+v", *infrastructureConfig),
+			apiServerConfig,
+		},
+		componentTasks...,
+	)
+	if err != nil {
+		klog.Warningf("failed to compute the inputs of the tasks, running all the tasks: %v", err)
+	} else if !o.takeFullSyncRequest() {
+		if skipped := o.skipUnchangedTasks(componentTasks...); len(skipped) > 0 {
+			klog.Infof("Skipping %d tasks whose inputs didn't change: %s", len(skipped), strings.Join(skipped, ", "))
+		}
+	}
 
 	tl := tasks.NewTaskRunner(
 		o.client,
-		// Update prometheus-operator before anything else because it is
-		// responsible for managing many other resources (e.g. Prometheus,
-		// Alertmanager, Thanos Ruler, ...). The metrics scraping client CA
-		// should also be created first because it is referenced by Prometheus.
-		tasks.NewTaskGroup(
-			[]*tasks.TaskSpec{
-				tasks.NewTaskSpec("Updating metrics scraping client CA", tasks.NewMetricsClientCATask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating Prometheus Operator", tasks.NewPrometheusOperatorTask(o.client, factory)),
-			}),
-		tasks.NewTaskGroup(
-			[]*tasks.TaskSpec{
-				tasks.NewTaskSpec("Updating user workload Prometheus Operator", tasks.NewPrometheusOperatorUserWorkloadTask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating Cluster Monitoring Operator", tasks.NewClusterMonitoringOperatorTask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating Grafana", tasks.NewGrafanaTask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating Prometheus-k8s", tasks.NewPrometheusTask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating Prometheus-user-workload", tasks.NewPrometheusUserWorkloadTask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating Alertmanager", tasks.NewAlertmanagerTask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating node-exporter", tasks.NewNodeExporterTask(o.client, factory)),
-				tasks.NewTaskSpec("Updating kube-state-metrics", tasks.NewKubeStateMetricsTask(o.client, factory)),
-				tasks.NewTaskSpec("Updating openshift-state-metrics", tasks.NewOpenShiftStateMetricsTask(o.client, factory)),
-				tasks.NewTaskSpec("Updating prometheus-adapter", tasks.NewPrometheusAdapterTask(ctx, o.namespace, o.client, factory)),
-				tasks.NewTaskSpec("Updating Telemeter client", tasks.NewTelemeterClientTask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating Thanos Querier", tasks.NewThanosQuerierTask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating User Workload Thanos Ruler", tasks.NewThanosRulerUserWorkloadTask(o.client, factory, config)),
-				tasks.NewTaskSpec("Updating Control Plane components", tasks.NewControlPlaneTask(o.client, factory, config)),
-			}),
-		// The shared configmap depends on resources being created by the previous tasks hence run it last.
-		tasks.NewTaskGroup(
-			[]*tasks.TaskSpec{
-				tasks.NewTaskSpec("Updating configuration sharing", tasks.NewConfigSharingTask(o.client, factory, config)),
-			},
-		),
+		metricsClientCA,
+		prometheusOperator,
+		consoleDashboards,
+		prometheusOperatorUserWorkload,
+		clusterMonitoringOperator,
+		grafana,
+		prometheusK8s,
+		prometheusUserWorkload,
+		alertmanager,
+		nodeExporter,
+		kubeStateMetrics,
+		openShiftStateMetrics,
+		prometheusAdapter,
+		metricsServer,
+		telemeterClient,
+		thanosQuerier,
+		thanosRulerUserWorkload,
+		thanosReceive,
+		controlPlane,
+		// The following tasks depend on resources created by the tasks
+		// updating the components (Routes, generated secrets, persistent
+		// volume claims, ...) or check the state of the components.
+		tasks.NewTaskSpec("Updating configuration sharing", tasks.NewConfigSharingTask(o.client, factory, config)).After(prometheusK8s, alertmanager, grafana, thanosQuerier),
+		tasks.NewTaskSpec("Analyzing Alertmanager configuration", alertmanagerAnalyzer).After(alertmanager),
+		tasks.NewTaskSpec("Checking monitoring Routes", tasks.NewRouteHealthTask(o.client, factory, config, o.eventRecorder)).After(prometheusK8s, alertmanager, grafana, thanosQuerier),
+		consoleNotifications,
+		queryCanary,
+		// The resource metrics API is moved to the enabled backend by the
+		// prometheus-adapter and metrics-server tasks before the unused
+		// backend is removed.
+		tasks.NewTaskSpec("Removing unused resource metrics backend", tasks.NewResourceMetricsTask(o.client, factory, config)).After(prometheusAdapter, metricsServer),
+		tasks.NewTaskSpec("Checking configuration reloads", tasks.NewConfigReloadTask(o.client, config, o.configReloadChecker)).After(prometheusK8s, prometheusUserWorkload, alertmanager),
+		tasks.NewTaskSpec("Enforcing namespace quotas", namespaceQuotas).After(prometheusUserWorkload),
+		tasks.NewTaskSpec("Checking storage classes", storageClassDrift).After(prometheusK8s, prometheusUserWorkload, alertmanager, thanosRulerUserWorkload),
+		tasks.NewTaskSpec("Measuring throttled user alerts", userAlertsThrottling).After(alertmanager, thanosQuerier),
+		// The removed components are torn down once their tasks deleted
+		// their objects.
+		tasks.NewTaskSpec("Tearing down removed components", teardown).After(alertmanager, grafana, openShiftStateMetrics, telemeterClient, thanosReceive, prometheusOperatorUserWorkload, prometheusUserWorkload, thanosRulerUserWorkload),
 	)
 	klog.Info("Updating ClusterOperator status to in progress.")
 	err = o.client.StatusReporter().SetRollOutInProgress(ctx)
@@ -615,6 +1200,30 @@ func (o *Operator) sync(ctx context.Context, key string) error {
 	}
 
 	taskErrors := tl.RunAll(ctx)
+	states := tl.States()
+	if err := o.persistTaskStates(ctx, states, configHash); err != nil {
+		klog.Warningf("failed to persist the task state: %v", err)
+	}
+	o.recordTaskInputs(states)
+	o.taskResults.update(states, o.client.TakeUnmanagedObjects(), o.client.TakeModifiedObjects(), factory.ExcludedRulesByObject())
+	o.alertmanagerWarnings = alertmanagerAnalyzer.Warnings()
+
+	var failures client.ComponentErrors
+	results := make([]client.ComponentResult, 0, len(states))
+	for _, s := range states {
+		r := client.ComponentResult{Component: s.Component(), Err: s.Err}
+		results = append(results, r)
+		if r.Err != nil {
+			failures = append(failures, r)
+		}
+	}
+	conditions = append(conditions, client.ComponentConditions(results)...)
+	if cr, found := o.clusterMonitoringResource(); found {
+		if err := o.client.UpdateClusterMonitoringStatus(ctx, cr, results); err != nil {
+			klog.Warningf("Updating the status of the ClusterMonitoring resource failed: %v", err)
+		}
+	}
+
 	if len(taskErrors) > 0 {
 		var failedTask string
 		if len(taskErrors) == 1 {
@@ -623,7 +1232,7 @@ func (o *Operator) sync(ctx context.Context, key string) error {
 			failedTask = "MultipleTasksFailed"
 		}
 
-		o.reportError(ctx, taskErrors, failedTask)
+		o.reportError(ctx, failures, failedTask, conditions...)
 		return errors.Errorf("cluster monitoring update failed (reason: %s)", failedTask)
 	}
 
@@ -633,36 +1242,154 @@ func (o *Operator) sync(ctx context.Context, key string) error {
 		degradedConditionReason = client.StorageNotConfiguredReason
 	}
 
+	conditions = append(conditions, client.ListCondition(client.NotAvailableFeatures, notAvailableFeatures))
+
+	var (
+		disabledComponents []string
+		osmRemoved         bool
+	)
+	for _, component := range config.ComponentsInState(operatorv1.Removed) {
+		disabledComponents = append(disabledComponents, fmt.Sprintf("%s (managementState: Removed)", component))
+		osmRemoved = osmRemoved || component == "openshiftStateMetrics"
+	}
+	if !config.ClusterMonitoringConfiguration.OpenShiftMetricsConfig.IsEnabled() && !osmRemoved {
+		disabledComponents = append(disabledComponents, "openshift-state-metrics")
+	}
+	conditions = append(conditions, client.ListCondition(client.DisabledComponents, disabledComponents))
+
+	if o.prometheusRulesOverQuota != nil {
+		o.prometheusRulesOverQuota.Set(float64(namespaceQuotas.PrometheusRulesOverQuota()))
+	}
+	if o.monitorsOverTargetQuota != nil {
+		o.monitorsOverTargetQuota.Set(float64(namespaceQuotas.MonitorsOverTargetQuota()))
+	}
+	conditions = append(conditions, client.ListCondition(client.NamespacesOverQuota, namespaceQuotas.NamespacesOverQuota()))
+
+	drifts := storageClassDrift.Drifts()
+	if o.storageClassDrift != nil {
+		o.storageClassDrift.Reset()
+		for component, pvcs := range drifts {
+			o.storageClassDrift.WithLabelValues(component).Set(float64(len(pvcs)))
+		}
+	}
+	components := make([]string, 0, len(drifts))
+	for component := range drifts {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	var driftMessages []string
+	for _, component := range components {
+		driftMessages = append(driftMessages, fmt.Sprintf("%s: %s", component, strings.Join(drifts[component], ", ")))
+	}
+	conditions = append(conditions,
+		client.ListCondition(client.StorageClassDrift, driftMessages),
+		client.TeardownCondition(config.Uninstalling, teardown.Pending(), teardown.Retained()),
+	)
+
+	if o.userAlertsAggregated != nil {
+		o.userAlertsAggregated.Reset()
+		for namespace, n := range userAlertsThrottling.Aggregated() {
+			o.userAlertsAggregated.WithLabelValues(namespace).Set(n)
+		}
+	}
+
+	conditions = append(conditions, o.taskResultConditions(config)...)
+
+	// The condition is left untouched when the skew can't be queried to
+	// avoid flapping while Thanos Querier is unavailable.
+	if skews, err := o.clockSkewChecker.Check(ctx); err != nil {
+		klog.Warningf("Checking the clock skew of the nodes failed: %v", err)
+	} else {
+		skewMessages := make([]string, 0, len(skews))
+		for _, s := range skews {
+			skewMessages = append(skewMessages, s.String())
+		}
+		conditions = append(conditions, client.ListCondition(client.ClockSkew, skewMessages))
+	}
+
+	// The Upgradeable condition is left untouched when it can't be
+	// computed, the other conditions are still reported.
+	operatorUpgradeable, upgradeableReason, upgradeableMessage, upgradeableErr := o.Upgradeable(ctx, o.upgradeBlockers(config, storageClassDrift.Resizes())...)
+	if upgradeableErr == nil {
+		conditions = append(conditions, client.Condition{
+			Type:    configv1.OperatorUpgradeable,
+			Status:  operatorUpgradeable,
+			Message: upgradeableMessage,
+			Reason:  upgradeableReason,
+		})
+	}
+
 	klog.Info("Updating ClusterOperator status to done.")
 	o.failedReconcileAttempts = 0
-	err = o.client.StatusReporter().SetRollOutDone(ctx, degradedConditionMessage, degradedConditionReason)
+	if userWorkloadNamespaceTerminating != "" {
+		err = o.client.StatusReporter().SetRollOutDoneDegraded(ctx, userWorkloadNamespaceTerminating, userWorkloadNamespaceTerminatingReason, conditions...)
+	} else {
+		err = o.client.StatusReporter().SetRollOutDone(ctx, degradedConditionMessage, degradedConditionReason, conditions...)
+	}
 	if err != nil {
 		klog.Errorf("error occurred while setting status to done: %v", err)
 	}
 
-	operatorUpgradeable, upgradeableReason, upgradeableMessage, err := o.Upgradeable(ctx)
-	if err != nil {
-		return err
+	return upgradeableErr
+}
+
+// reportUnknownFields reports the fields of the configuration ConfigMaps
+// unknown to the operator with warning events on the ConfigMaps and a metric.
+// It returns the UnknownFields condition. They are usually typos of the
+// settings which the operator silently ignores.
+func (o *Operator) reportUnknownFields(ctx context.Context, c *manifests.Config) client.Condition {
+	keys := make([]string, 0, len(c.UnknownFields))
+	for key := range c.UnknownFields {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	err = o.client.StatusReporter().SetUpgradeable(ctx, operatorUpgradeable, upgradeableMessage, upgradeableReason)
-	if err != nil {
-		klog.Errorf("error occurred while setting Upgradeable status: %v", err)
+	var n int
+	for _, key := range keys {
+		fields := c.UnknownFields[key]
+		n += len(fields)
+		klog.Warningf("The %q ConfigMap has fields unknown to the operator: %s", key, strings.Join(fields, ", "))
+
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			continue
+		}
+		cm, err := o.client.GetConfigmap(ctx, namespace, name)
+		if err != nil {
+			klog.V(4).Infof("failed to get the %q ConfigMap: %v", key, err)
+			continue
+		}
+		o.client.RecordObjectWarning(cm, "UnknownConfigFields", "The configuration has fields unknown to the operator: %s", strings.Join(fields, ", "))
+	}
+
+	if o.unknownConfigFields != nil {
+		o.unknownConfigFields.Set(float64(n))
 	}
 
-	return nil
+	return client.ListCondition(client.UnknownFields, c.UnknownFieldsByConfigMap())
 }
 
-func (o *Operator) reportError(ctx context.Context, err error, failedTaskReason string) {
+// reportError reports the failure of the reconciliation. The ClusterOperator
+// is only reported as failed after 3 attempts, the given conditions are
+// reported in any case.
+func (o *Operator) reportError(ctx context.Context, err error, failedTaskReason string, conditions ...client.Condition) {
 	klog.Infof("ClusterOperator reconciliation failed (attempt %d), retrying. ", o.failedReconcileAttempts+1)
+	// The task runner already emitted an event for each failed task.
+	if _, ok := err.(client.ComponentErrors); !ok && o.eventRecorder != nil {
+		o.eventRecorder.Warning(failedTaskReason, err.Error())
+	}
 	if o.failedReconcileAttempts >= 2 {
 		// Only update the ClusterOperator status after 3 retries have been attempted to avoid flapping status.
 		klog.Warningf("Updating ClusterOperator status to failed after %d attempts.", o.failedReconcileAttempts+1)
 
-		reportErr := o.client.StatusReporter().SetFailed(ctx, err, failedTaskReason)
+		reportErr := o.client.StatusReporter().SetFailed(ctx, err, failedTaskReason, conditions...)
 		if reportErr != nil {
 			klog.Errorf("error occurred while setting status to failed: %v", reportErr)
 		}
+	} else if len(conditions) > 0 {
+		if reportErr := o.client.StatusReporter().SetConditions(ctx, conditions...); reportErr != nil {
+			klog.Errorf("error occurred while setting the conditions: %v", reportErr)
+		}
 	}
 	o.failedReconcileAttempts++
 }
@@ -684,6 +1411,14 @@ func (o *Operator) loadInfrastructureConfig(ctx context.Context) *Infrastructure
 		klog.V(5).Infof("Cluster infrastructure: plaform=%s controlPlaneTopology=%s infrastructureTopology=%s", infrastructure.Status.Platform, infrastructure.Status.ControlPlaneTopology, infrastructure.Status.InfrastructureTopology)
 
 		infrastructureConfig = NewInfrastructureConfig(infrastructure)
+
+		dns, err := o.client.GetDNS(ctx, clusterResourceName)
+		if err != nil {
+			klog.Warningf("Error getting cluster DNS: %v", err)
+		} else {
+			infrastructureConfig.baseDomain = dns.Spec.BaseDomain
+		}
+
 		o.lastKnowInfrastructureConfig = infrastructureConfig
 	}
 
@@ -724,35 +1459,90 @@ func (o *Operator) loadApiServerConfig(ctx context.Context) (*manifests.APIServe
 	return o.lastKnownApiServerConfig, nil
 }
 
-func (o *Operator) loadUserWorkloadConfig(ctx context.Context) (*manifests.UserWorkloadConfiguration, error) {
+// loadUserWorkloadConfig returns the user workload configuration and the
+// fields of the User Workload Monitoring ConfigMap unknown to the operator.
+func (o *Operator) loadUserWorkloadConfig(ctx context.Context) (*manifests.UserWorkloadConfiguration, []string, error) {
 	cmKey := fmt.Sprintf("%s/%s", o.namespaceUserWorkload, o.userWorkloadConfigMapName)
 
+	cr, err := o.client.GetUserWorkloadMonitoring(ctx)
+	switch {
+	case err == nil:
+		spec, _, err := unstructured.NestedMap(cr.Object, "spec")
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "the UserWorkloadMonitoring resource spec is invalid")
+		}
+
+		uwc, err := manifests.NewUserConfigFromSpec(spec)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "the UserWorkloadMonitoring resource could not be parsed")
+		}
+		return uwc, nil, nil
+	case !apierrors.IsNotFound(err):
+		return nil, nil, errors.Wrap(err, "the UserWorkloadMonitoring resource could not be loaded")
+	}
+
 	userCM, err := o.client.GetConfigmap(ctx, o.namespaceUserWorkload, o.userWorkloadConfigMapName)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			klog.Warningf("User Workload Monitoring %q ConfigMap not found. Using defaults.", cmKey)
-			return manifests.NewDefaultUserWorkloadMonitoringConfig(), nil
+			return manifests.NewDefaultUserWorkloadMonitoringConfig(), nil, nil
 		}
 		klog.Warningf("Error loading User Workload Monitoring %q ConfigMap. Error: %v", cmKey, err)
-		return nil, errors.Wrapf(err, "the User Workload Monitoring %q ConfigMap could not be loaded", cmKey)
+		return nil, nil, errors.Wrapf(err, "the User Workload Monitoring %q ConfigMap could not be loaded", cmKey)
 	}
 
-	const configKey = "config.yaml"
 	configContent, found := userCM.Data[configKey]
 	if !found {
 		klog.Warningf("No %q key found in User Workload Monitoring %q ConfigMap. Using defaults.", configKey, cmKey)
-		return manifests.NewDefaultUserWorkloadMonitoringConfig(), nil
+		return manifests.NewDefaultUserWorkloadMonitoringConfig(), nil, nil
 	}
 
 	uwc, err := manifests.NewUserConfigFromString(configContent)
 	if err != nil {
 		klog.Warningf("Error creating User Workload Configuration from %q key in the %q ConfigMap. Error: %v", configKey, cmKey, err)
-		return nil, errors.Wrapf(err, "the User Workload Configuration from %q key in the %q ConfigMap could not be parsed", configKey, cmKey)
+		return nil, nil, errors.Wrapf(err, "the User Workload Configuration from %q key in the %q ConfigMap could not be parsed", configKey, cmKey)
+	}
+	return uwc, manifests.UnknownUserConfigFields(configContent), nil
+}
+
+// clusterMonitoringResource returns the ClusterMonitoring resource from the
+// informer cache.
+func (o *Operator) clusterMonitoringResource() (*unstructured.Unstructured, bool) {
+	obj, found, err := o.clusterMonitoringInf.GetStore().GetByKey(client.ClusterMonitoringName)
+	if err != nil || !found {
+		return nil, false
 	}
-	return uwc, nil
+	return obj.(*unstructured.Unstructured), true
+}
+
+// configExists returns whether the stack is configured by the
+// ClusterMonitoring resource or the Cluster Monitoring ConfigMap.
+func (o *Operator) configExists(key string) bool {
+	if _, found := o.clusterMonitoringResource(); found {
+		return true
+	}
+	_, found, _ := o.cmapInf.GetStore().GetByKey(key)
+	return found
 }
 
 func (o *Operator) loadConfig(key string) (*manifests.Config, error) {
+	if cr, found := o.clusterMonitoringResource(); found {
+		if _, found, _ := o.cmapInf.GetStore().GetByKey(key); found {
+			klog.Warningf("The ClusterMonitoring resource %q takes precedence, the %q ConfigMap is ignored.", cr.GetName(), key)
+		}
+
+		spec, _, err := unstructured.NestedMap(cr.Object, "spec")
+		if err != nil {
+			return nil, errors.Wrap(err, "the ClusterMonitoring resource spec is invalid")
+		}
+
+		c, err := manifests.NewConfigFromSpec(spec)
+		if err != nil {
+			return nil, errors.Wrap(err, "the ClusterMonitoring resource could not be parsed")
+		}
+		return c, nil
+	}
+
 	obj, found, err := o.cmapInf.GetStore().GetByKey(key)
 	if err != nil {
 		return nil, errors.Wrap(err, "an error occurred when retrieving the Cluster Monitoring ConfigMap")
@@ -775,24 +1565,76 @@ func (o *Operator) loadConfig(key string) (*manifests.Config, error) {
 		return nil, errors.Wrap(err, "the Cluster Monitoring ConfigMap could not be parsed")
 	}
 
+	if fields := manifests.UnknownConfigFields(configContent); len(fields) > 0 {
+		cParsed.UnknownFields = map[string][]string{key: fields}
+	}
+
 	return cParsed, nil
 }
 
-func (o *Operator) Config(ctx context.Context, key string) (*manifests.Config, error) {
-	c, err := o.loadConfig(key)
-	if err != nil {
-		return nil, err
+// configMapResourceVersion returns the resource versions of the
+// ClusterMonitoring resource and of the ConfigMap from the informer caches.
+// The versions are empty when the objects don't exist.
+func (o *Operator) configMapResourceVersion(key string) string {
+	var crVersion, cmVersion string
+	if cr, found := o.clusterMonitoringResource(); found {
+		crVersion = cr.GetResourceVersion()
 	}
 
-	// Only use User Workload Monitoring ConfigMap from user ns and populate if
-	// its enabled by admin via Cluster Monitoring ConfigMap.  The above
-	// loadConfig() already initializes the structs with nil values for
-	// UserWorkloadConfiguration struct.
-	if *c.ClusterMonitoringConfiguration.UserWorkloadEnabled {
-		c.UserWorkloadConfiguration, err = o.loadUserWorkloadConfig(ctx)
+	obj, found, err := o.cmapInf.GetStore().GetByKey(key)
+	if err == nil && found {
+		cmVersion = obj.(*v1.ConfigMap).ResourceVersion
+	}
+
+	return crVersion + "/" + cmVersion
+}
+
+// loadConsistentConfig loads the cluster and user workload configurations.
+// The ClusterMonitoring resource and the Cluster Monitoring ConfigMap are
+// read from the informer caches while the user workload configuration is
+// fetched from the API: the load is retried when the former change in between
+// so that the returned configuration never mixes versions which didn't
+// coexist.
+func (o *Operator) loadConsistentConfig(ctx context.Context, key string) (*manifests.Config, error) {
+	for i := 0; i < maxConfigLoadAttempts; i++ {
+		rv := o.configMapResourceVersion(key)
+
+		c, err := o.loadConfig(key)
 		if err != nil {
 			return nil, err
 		}
+
+		// Only use User Workload Monitoring ConfigMap from user ns and populate if
+		// its enabled by admin via Cluster Monitoring ConfigMap.  The above
+		// loadConfig() already initializes the structs with nil values for
+		// UserWorkloadConfiguration struct.
+		if *c.ClusterMonitoringConfiguration.UserWorkloadEnabled {
+			var unknown []string
+			c.UserWorkloadConfiguration, unknown, err = o.loadUserWorkloadConfig(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if len(unknown) > 0 {
+				if c.UnknownFields == nil {
+					c.UnknownFields = map[string][]string{}
+				}
+				c.UnknownFields[o.namespaceUserWorkload+"/"+o.userWorkloadConfigMapName] = unknown
+			}
+		}
+
+		if o.configMapResourceVersion(key) == rv {
+			return c, nil
+		}
+		klog.V(4).Infof("Configuration %s changed while loading the configuration, retrying.", key)
+	}
+
+	return nil, errors.Errorf("configuration %s kept changing while loading the configuration", key)
+}
+
+func (o *Operator) Config(ctx context.Context, key string) (*manifests.Config, error) {
+	c, err := o.loadConsistentConfig(ctx, key)
+	if err != nil {
+		return nil, err
 	}
 	o.userWorkloadEnabled = *c.ClusterMonitoringConfiguration.UserWorkloadEnabled
 
@@ -842,16 +1684,138 @@ func (o *Operator) Config(ctx context.Context, key string) (*manifests.Config, e
 	return c, nil
 }
 
+// upgradeBlocker is a state of the monitoring stack which must be fixed
+// before upgrading to the next minor version.
+// taskResultConditions returns the conditions derived from the results of
+// the last run of each task and updates the matching metrics.
+func (o *Operator) taskResultConditions(config *manifests.Config) []client.Condition {
+	excludedRules := o.taskResults.excludedRulesCount(config.ClusterMonitoringConfiguration.ExcludedRules)
+	if o.excludedRules != nil {
+		o.excludedRules.Reset()
+		for e, n := range excludedRules {
+			o.excludedRules.WithLabelValues(e.Group, e.Alert).Set(float64(n))
+		}
+	}
+	var exclusions []string
+	for e, n := range excludedRules {
+		if n == 0 {
+			klog.Warningf("The rule exclusion (%s) doesn't match any platform rule.", e)
+			exclusions = append(exclusions, fmt.Sprintf("%s (no matching rule)", e))
+			continue
+		}
+		exclusions = append(exclusions, fmt.Sprintf("%s (%d rules)", e, n))
+	}
+	sort.Strings(exclusions)
+
+	unmanaged := o.taskResults.unmanagedObjects()
+	if o.unmanagedDrift != nil {
+		o.unmanagedDrift.Reset()
+		for _, u := range unmanaged {
+			o.unmanagedDrift.WithLabelValues(u.Kind, u.Namespace, u.Name).Set(float64(len(u.Drift)))
+		}
+	}
+	var unmanagedDrifts []string
+	for _, u := range unmanaged {
+		if len(u.Drift) > 0 {
+			unmanagedDrifts = append(unmanagedDrifts, fmt.Sprintf("%s (%s)", u, strings.Join(u.Drift, ", ")))
+		}
+	}
+
+	return []client.Condition{
+		client.ListCondition(client.ExcludedRules, exclusions),
+		client.ListCondition(client.UnmanagedDrift, unmanagedDrifts),
+	}
+}
+
+type upgradeBlocker struct {
+	reason  string
+	message string
+}
+
+// upgradeBlockers returns the blocking states detected by the last
+// reconciliation: deprecated configuration fields, workloads modified outside
+// of the operator, unmanaged components and persistent volume claims needing
+// a manual resize.
+func (o *Operator) upgradeBlockers(config *manifests.Config, resizes map[string][]string) []upgradeBlocker {
+	var blockers []upgradeBlocker
+
+	if fields := config.DeprecatedFields(); len(fields) > 0 {
+		blockers = append(blockers, upgradeBlocker{
+			reason:  "DeprecatedConfigFields",
+			message: fmt.Sprintf("The cluster monitoring configuration uses deprecated fields which may be removed in the next minor version: %s.", strings.Join(fields, "; ")),
+		})
+	}
+
+	if modified := o.taskResults.modifiedObjects(); len(modified) > 0 {
+		blockers = append(blockers, upgradeBlocker{
+			reason:  "ResourcesModifiedExternally",
+			message: fmt.Sprintf("The following resources were modified outside of the operator which reverted the changes: %s. Stop the users or tools modifying them and use the monitoring configuration instead.", strings.Join(modified, ", ")),
+		})
+	}
+
+	if unmanaged := config.ComponentsInState(operatorv1.Unmanaged); len(unmanaged) > 0 {
+		blockers = append(blockers, upgradeBlocker{
+			reason:  "UnmanagedComponents",
+			message: fmt.Sprintf("The following components are unmanaged and wouldn't be upgraded: %s. Set them back to Managed before upgrading.", strings.Join(unmanaged, ", ")),
+		})
+	}
+
+	if len(resizes) > 0 {
+		components := make([]string, 0, len(resizes))
+		for component := range resizes {
+			components = append(components, component)
+		}
+		sort.Strings(components)
+
+		pvcs := make([]string, 0, len(components))
+		for _, component := range components {
+			pvcs = append(pvcs, fmt.Sprintf("%s: %s", component, strings.Join(resizes[component], ", ")))
+		}
+		blockers = append(blockers, upgradeBlocker{
+			reason:  "PersistentVolumeClaimsNeedResize",
+			message: fmt.Sprintf("Persistent volume claims are smaller than the size requested by the volume claim template and have to be resized manually (%s).", strings.Join(pvcs, "; ")),
+		})
+	}
+
+	return blockers
+}
+
 // Upgradeable verifies whether the operator can be upgraded or not. It returns
 // the ConditionStatus with optional reason and message.  To set this status, it
 // will verify that in HA topology, workloads with persistent storage are
 // correctly balanced across multiple nodes. If it isn't it will try to
-// rebalance the workloads.
-func (o *Operator) Upgradeable(ctx context.Context) (configv1.ConditionStatus, string, string, error) {
-	if !o.lastKnowInfrastructureConfig.HighlyAvailableInfrastructure() {
+// rebalance the workloads. The given blockers, detected by the
+// reconciliation, are reported as well.
+func (o *Operator) Upgradeable(ctx context.Context, blockers ...upgradeBlocker) (configv1.ConditionStatus, string, string, error) {
+	if o.lastKnowInfrastructureConfig.HighlyAvailableInfrastructure() {
+		blocker, err := o.rebalanceWorkloads(ctx)
+		if err != nil {
+			return configv1.ConditionUnknown, "", "", err
+		}
+		if blocker != nil {
+			blockers = append(blockers, *blocker)
+		}
+	}
+
+	switch len(blockers) {
+	case 0:
 		return configv1.ConditionTrue, "", "", nil
+	case 1:
+		return configv1.ConditionFalse, blockers[0].reason, blockers[0].message, nil
 	}
 
+	messages := make([]string, 0, len(blockers))
+	for _, b := range blockers {
+		messages = append(messages, b.message)
+	}
+	return configv1.ConditionFalse, "MultipleUpgradeBlockers", strings.Join(messages, "\n"), nil
+}
+
+// rebalanceWorkloads verifies that the highly-available workloads with
+// persistent storage are correctly balanced across multiple nodes and tries
+// to rebalance them otherwise. It returns a blocker when manual intervention
+// is needed.
+func (o *Operator) rebalanceWorkloads(ctx context.Context) (*upgradeBlocker, error) {
 	var (
 		messages           []string
 		workloadRebalanced bool
@@ -860,7 +1824,7 @@ func (o *Operator) Upgradeable(ctx context.Context) (configv1.ConditionStatus, s
 		balanced, err := o.rebalancer.WorkloadCorrectlyBalanced(ctx, &workload)
 		if err != nil {
 			klog.Errorf("Couldn't figure out if workload in namespace %s, with label %q is correctly balanced, err %v.", workload.Namespace, workload.LabelSelector, err)
-			return configv1.ConditionUnknown, "", "", err
+			return nil, err
 		}
 
 		if balanced {
@@ -870,7 +1834,7 @@ func (o *Operator) Upgradeable(ctx context.Context) (configv1.ConditionStatus, s
 		workloadRebalanced, err := o.rebalancer.RebalanceWorkloads(ctx, &workload)
 		if err != nil {
 			klog.Errorf("Couldn't rebalance workload in namespace %s, with label %q, err %v.", workload.Namespace, workload.LabelSelector, err)
-			return configv1.ConditionUnknown, "", "", err
+			return nil, err
 		}
 
 		if !workloadRebalanced {
@@ -878,19 +1842,23 @@ func (o *Operator) Upgradeable(ctx context.Context) (configv1.ConditionStatus, s
 		}
 	}
 
-	if len(messages) > 0 {
-		msg := "Manual intervention is needed to upgrade to the next minor version. "
-		if workloadRebalanced {
-			msg += "The operator couldn't rebalance the pods automatically with the annotation. " +
-				"For each highly-available workload that has a single point of failure, you will need to manually delete at least one of the PersistentVolumeClaims and Pods of this workload until at least 2 of its replicas are scheduled on different nodes."
-		} else {
-			msg += fmt.Sprintf("For each highly-available workload that has a single point of failure please mark at least one of their PersistentVolumeClaim for deletion by annotating them with %q.", map[string]string{rebalancer.DropPVCAnnotation: "yes"})
-		}
-		messages = append(messages, msg)
-		return configv1.ConditionFalse, "WorkloadSinglePointOfFailure", strings.Join(messages, "\n"), nil
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	msg := "Manual intervention is needed to upgrade to the next minor version. "
+	if workloadRebalanced {
+		msg += "The operator couldn't rebalance the pods automatically with the annotation. " +
+			"For each highly-available workload that has a single point of failure, you will need to manually delete at least one of the PersistentVolumeClaims and Pods of this workload until at least 2 of its replicas are scheduled on different nodes."
+	} else {
+		msg += fmt.Sprintf("For each highly-available workload that has a single point of failure please mark at least one of their PersistentVolumeClaim for deletion by annotating them with %q.", map[string]string{rebalancer.DropPVCAnnotation: "yes"})
 	}
+	messages = append(messages, msg)
 
-	return configv1.ConditionTrue, "", "", nil
+	return &upgradeBlocker{
+		reason:  "WorkloadSinglePointOfFailure",
+		message: strings.Join(messages, "\n"),
+	}, nil
 }
 
 // workloadsToRebalance returns the list of workloads with persistent storage
@@ -0,0 +1,208 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// operatorConfigMapName is the optional ConfigMap holding the
+	// operator-level tunables. Changes are applied without restarting the
+	// operator.
+	operatorConfigMapName = "operator-config"
+	operatorConfigKey     = "config.yaml"
+
+	// defaultResyncPeriod bounds the delay before the objects which aren't
+	// watched by the operator (e.g. Services, Routes, RBAC) are reverted,
+	// the state-checking tasks run again and the GRPC CA rotation moves to
+	// its next phase.
+	defaultResyncPeriod = 15 * time.Minute
+
+	defaultRateLimitBaseDelay = 50 * time.Millisecond
+	defaultRateLimitMaxDelay  = 3 * time.Minute
+)
+
+// OperatorConfig holds the operator-level tunables.
+type OperatorConfig struct {
+	// LogLevel is the klog verbosity. When unset, the level from the
+	// command-line flags is used.
+	LogLevel *int `json:"logLevel"`
+	// ResyncPeriod forces a reconciliation at the given interval even when
+	// no change has been observed. Defaults to 15m.
+	ResyncPeriod *metav1.Duration `json:"resyncPeriod"`
+	// RateLimit controls the backoff applied when reconciliations fail.
+	RateLimit *RateLimitConfig `json:"rateLimit"`
+}
+
+type RateLimitConfig struct {
+	BaseDelay *metav1.Duration `json:"baseDelay"`
+	MaxDelay  *metav1.Duration `json:"maxDelay"`
+}
+
+// NewOperatorConfigFromConfigMap parses the operator configuration from the
+// given ConfigMap. A nil ConfigMap returns the default configuration.
+func NewOperatorConfigFromConfigMap(cm *v1.ConfigMap) (*OperatorConfig, error) {
+	oc := &OperatorConfig{}
+	if cm == nil {
+		return oc, nil
+	}
+
+	content, found := cm.Data[operatorConfigKey]
+	if !found {
+		return oc, nil
+	}
+
+	err := k8syaml.NewYAMLOrJSONDecoder(bytes.NewBufferString(content), 100).Decode(oc)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing operator configuration failed")
+	}
+
+	if oc.LogLevel != nil && *oc.LogLevel < 0 {
+		return nil, errors.Errorf("invalid log level %d", *oc.LogLevel)
+	}
+
+	if oc.ResyncPeriod != nil && oc.ResyncPeriod.Duration < time.Minute {
+		return nil, errors.Errorf("resync period %s is lower than 1m", oc.ResyncPeriod.Duration)
+	}
+
+	if oc.RateLimit != nil {
+		base, max := oc.rateLimitDelays()
+		if base <= 0 || max < base {
+			return nil, errors.Errorf("invalid rate limit delays (base: %s, max: %s)", base, max)
+		}
+	}
+
+	return oc, nil
+}
+
+func (oc *OperatorConfig) rateLimitDelays() (time.Duration, time.Duration) {
+	base, max := defaultRateLimitBaseDelay, defaultRateLimitMaxDelay
+	if oc.RateLimit == nil {
+		return base, max
+	}
+
+	if oc.RateLimit.BaseDelay != nil {
+		base = oc.RateLimit.BaseDelay.Duration
+	}
+	if oc.RateLimit.MaxDelay != nil {
+		max = oc.RateLimit.MaxDelay.Duration
+	}
+
+	return base, max
+}
+
+func (oc *OperatorConfig) resyncPeriod() time.Duration {
+	if oc.ResyncPeriod == nil {
+		return defaultResyncPeriod
+	}
+	return oc.ResyncPeriod.Duration
+}
+
+// dynamicRateLimiter is a workqueue rate limiter whose delays can be
+// updated at runtime. Updating the delays resets the failure counters.
+type dynamicRateLimiter struct {
+	mtx sync.RWMutex
+	rl  workqueue.RateLimiter
+}
+
+func newDynamicRateLimiter(base, max time.Duration) *dynamicRateLimiter {
+	return &dynamicRateLimiter{
+		rl: workqueue.NewItemExponentialFailureRateLimiter(base, max),
+	}
+}
+
+func (d *dynamicRateLimiter) set(base, max time.Duration) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.rl = workqueue.NewItemExponentialFailureRateLimiter(base, max)
+}
+
+func (d *dynamicRateLimiter) When(item interface{}) time.Duration {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	return d.rl.When(item)
+}
+
+func (d *dynamicRateLimiter) Forget(item interface{}) {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	d.rl.Forget(item)
+}
+
+func (d *dynamicRateLimiter) NumRequeues(item interface{}) int {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	return d.rl.NumRequeues(item)
+}
+
+// reloadOperatorConfig applies the operator-level tunables from the
+// operator-config ConfigMap. An invalid configuration is logged and ignored
+// so that the operator keeps running with the last valid settings.
+func (o *Operator) reloadOperatorConfig() {
+	var cm *v1.ConfigMap
+	obj, exists, err := o.cmapInf.GetStore().GetByKey(o.namespace + "/" + operatorConfigMapName)
+	if err != nil {
+		klog.Warningf("failed to get the %s ConfigMap: %v", operatorConfigMapName, err)
+		return
+	}
+	if exists {
+		cm = obj.(*v1.ConfigMap)
+	}
+
+	oc, err := NewOperatorConfigFromConfigMap(cm)
+	if err != nil {
+		klog.Warningf("ignoring invalid operator configuration from the %s ConfigMap: %v", operatorConfigMapName, err)
+		return
+	}
+
+	logLevel := o.defaultLogLevel
+	if oc.LogLevel != nil {
+		logLevel = strconv.Itoa(*oc.LogLevel)
+	}
+	var l klog.Level
+	if err := l.Set(logLevel); err != nil {
+		klog.Warningf("failed to set log level to %q: %v", logLevel, err)
+	}
+
+	base, max := oc.rateLimitDelays()
+	o.rateLimiter.set(base, max)
+
+	o.resyncMtx.Lock()
+	o.resync = oc.resyncPeriod()
+	o.resyncMtx.Unlock()
+	select {
+	case o.resyncUpdated <- struct{}{}:
+	default:
+	}
+
+	klog.Infof("Operator configuration reloaded (log level: %s, resync period: %s, rate limit base delay: %s, max delay: %s)", logLevel, oc.resyncPeriod(), base, max)
+}
+
+func (o *Operator) resyncPeriod() time.Duration {
+	o.resyncMtx.RLock()
+	defer o.resyncMtx.RUnlock()
+	return o.resync
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestNewOperatorConfigFromConfigMap(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		cm           *v1.ConfigMap
+		logLevel     *int
+		resyncPeriod time.Duration
+		baseDelay    time.Duration
+		maxDelay     time.Duration
+		err          bool
+	}{
+		{
+			name:         "no ConfigMap",
+			resyncPeriod: defaultResyncPeriod,
+			baseDelay:    defaultRateLimitBaseDelay,
+			maxDelay:     defaultRateLimitMaxDelay,
+		},
+		{
+			name:         "missing key",
+			cm:           &v1.ConfigMap{Data: map[string]string{"foo": "bar"}},
+			resyncPeriod: defaultResyncPeriod,
+			baseDelay:    defaultRateLimitBaseDelay,
+			maxDelay:     defaultRateLimitMaxDelay,
+		},
+		{
+			name: "all settings",
+			cm: &v1.ConfigMap{Data: map[string]string{operatorConfigKey: `logLevel: 4
+resyncPeriod: 10m
+rateLimit:
+  baseDelay: 1s
+  maxDelay: 5m
+`}},
+			logLevel:     intPtr(4),
+			resyncPeriod: 10 * time.Minute,
+			baseDelay:    time.Second,
+			maxDelay:     5 * time.Minute,
+		},
+		{
+			name: "partial rate limit",
+			cm: &v1.ConfigMap{Data: map[string]string{operatorConfigKey: `rateLimit:
+  maxDelay: 10m
+`}},
+			resyncPeriod: defaultResyncPeriod,
+			baseDelay:    defaultRateLimitBaseDelay,
+			maxDelay:     10 * time.Minute,
+		},
+		{
+			name: "resync period too short",
+			cm:   &v1.ConfigMap{Data: map[string]string{operatorConfigKey: `resyncPeriod: 10s`}},
+			err:  true,
+		},
+		{
+			name: "max delay lower than base delay",
+			cm: &v1.ConfigMap{Data: map[string]string{operatorConfigKey: `rateLimit:
+  baseDelay: 1m
+  maxDelay: 1s
+`}},
+			err: true,
+		},
+		{
+			name: "negative log level",
+			cm:   &v1.ConfigMap{Data: map[string]string{operatorConfigKey: `logLevel: -1`}},
+			err:  true,
+		},
+		{
+			name: "invalid YAML",
+			cm:   &v1.ConfigMap{Data: map[string]string{operatorConfigKey: `logLevel: [`}},
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			oc, err := NewOperatorConfigFromConfigMap(tc.cm)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if (tc.logLevel == nil) != (oc.LogLevel == nil) || (tc.logLevel != nil && *tc.logLevel != *oc.LogLevel) {
+				t.Fatalf("expected log level %v, got %v", tc.logLevel, oc.LogLevel)
+			}
+
+			if got := oc.resyncPeriod(); got != tc.resyncPeriod {
+				t.Fatalf("expected resync period %s, got %s", tc.resyncPeriod, got)
+			}
+
+			base, max := oc.rateLimitDelays()
+			if base != tc.baseDelay || max != tc.maxDelay {
+				t.Fatalf("expected delays (%s, %s), got (%s, %s)", tc.baseDelay, tc.maxDelay, base, max)
+			}
+		})
+	}
+}
+
+func TestDynamicRateLimiter(t *testing.T) {
+	rl := newDynamicRateLimiter(time.Second, time.Minute)
+
+	if d := rl.When("foo"); d != time.Second {
+		t.Fatalf("expected 1s, got %s", d)
+	}
+	if d := rl.When("foo"); d != 2*time.Second {
+		t.Fatalf("expected 2s, got %s", d)
+	}
+
+	rl.set(time.Millisecond, time.Second)
+	if n := rl.NumRequeues("foo"); n != 0 {
+		t.Fatalf("expected requeues to be reset, got %d", n)
+	}
+	if d := rl.When("foo"); d != time.Millisecond {
+		t.Fatalf("expected 1ms, got %s", d)
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -16,15 +16,23 @@ package operator
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 
 	configv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
 	"github.com/openshift/cluster-monitoring-operator/pkg/rebalancer"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestNewInfrastructureConfig(t *testing.T) {
@@ -33,6 +41,8 @@ func TestNewInfrastructureConfig(t *testing.T) {
 		infrastructure     configv1.Infrastructure
 		hostedControlPlane bool
 		haInfrastructure   bool
+		platformType       string
+		region             string
 	}{
 		{
 			name:               "empty infrastructure",
@@ -60,6 +70,21 @@ func TestNewInfrastructureConfig(t *testing.T) {
 			hostedControlPlane: false,
 			haInfrastructure:   false,
 		},
+		{
+			name: "AWS infrastructure",
+			infrastructure: configv1.Infrastructure{
+				Status: configv1.InfrastructureStatus{
+					PlatformStatus: &configv1.PlatformStatus{
+						Type: configv1.AWSPlatformType,
+						AWS:  &configv1.AWSPlatformStatus{Region: "eu-west-1"},
+					},
+				},
+			},
+			hostedControlPlane: false,
+			haInfrastructure:   true,
+			platformType:       "AWS",
+			region:             "eu-west-1",
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			c := NewInfrastructureConfig(&tc.infrastructure)
@@ -71,6 +96,14 @@ func TestNewInfrastructureConfig(t *testing.T) {
 			if c.HighlyAvailableInfrastructure() != tc.haInfrastructure {
 				t.Errorf("expected HA infrastructure: %v, got %v", tc.haInfrastructure, c.HighlyAvailableInfrastructure())
 			}
+
+			if c.PlatformType() != tc.platformType {
+				t.Errorf("expected platform type: %q, got %q", tc.platformType, c.PlatformType())
+			}
+
+			if c.Region() != tc.region {
+				t.Errorf("expected region: %q, got %q", tc.region, c.Region())
+			}
 		})
 	}
 }
@@ -174,7 +207,9 @@ func TestUpgradeableStatus(t *testing.T) {
 		infra       InfrastructureConfig
 		uwm         bool
 		pods        []v1.Pod
+		blockers    []upgradeBlocker
 		upgradeable configv1.ConditionStatus
+		reason      string
 	}{
 		{
 			name:        "Non HA infrastructures are always Upgradeable",
@@ -344,6 +379,31 @@ func TestUpgradeableStatus(t *testing.T) {
 			},
 			upgradeable: configv1.ConditionFalse,
 		},
+		{
+			name:        "Non HA infrastructures with blocker",
+			infra:       nonHAInfrastructure,
+			pods:        []v1.Pod{},
+			blockers:    []upgradeBlocker{{reason: "DeprecatedConfigFields", message: "http"}},
+			upgradeable: configv1.ConditionFalse,
+			reason:      "DeprecatedConfigFields",
+		},
+		{
+			name:  "Prometheus k8s incorrectly balanced with blocker",
+			infra: haInfrastructure,
+			pods: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "prometheus-k8s-0", Namespace: namespace, Labels: map[string]string{"app.kubernetes.io/name": "prometheus"}},
+					Spec:       v1.PodSpec{NodeName: "node-1", Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "prometheus-k8s-db-prometheus-k8s-0"}}}}},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "prometheus-k8s-1", Namespace: namespace, Labels: map[string]string{"app.kubernetes.io/name": "prometheus"}},
+					Spec:       v1.PodSpec{NodeName: "node-1", Volumes: []v1.Volume{{VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "prometheus-k8s-db-prometheus-k8s-1"}}}}},
+				},
+			},
+			blockers:    []upgradeBlocker{{reason: "PersistentVolumeClaimsNeedResize", message: "resize"}},
+			upgradeable: configv1.ConditionFalse,
+			reason:      "MultipleUpgradeBlockers",
+		},
 		{
 			name:  "Workload incorrectly balanced without PVC",
 			infra: haInfrastructure,
@@ -380,7 +440,7 @@ func TestUpgradeableStatus(t *testing.T) {
 			fakeOperator.rebalancer = rebalancer.NewRebalancer(context.Background(), fakeOperator.client.KubernetesInterface())
 
 			var message, reason string
-			upgradeable, message, reason, err := fakeOperator.Upgradeable(context.Background())
+			upgradeable, reason, message, err := fakeOperator.Upgradeable(context.Background(), tc.blockers...)
 			if err != nil {
 				t.Error(err)
 			}
@@ -388,6 +448,271 @@ func TestUpgradeableStatus(t *testing.T) {
 			if tc.upgradeable != upgradeable {
 				t.Errorf("Unexpected ClusterOperator Upgradeable status: expected: %v, got: %v with reason: %v and message: %v.", tc.upgradeable, upgradeable, reason, message)
 			}
+
+			if tc.reason != "" && tc.reason != reason {
+				t.Errorf("Unexpected ClusterOperator Upgradeable reason: expected: %v, got: %v.", tc.reason, reason)
+			}
+		})
+	}
+}
+
+func TestUpgradeBlockers(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  string
+		resizes map[string][]string
+		reasons []string
+	}{
+		{
+			name: "no blocker",
+		},
+		{
+			name: "deprecated fields",
+			config: `http:
+  httpProxy: http://proxy.example.com
+`,
+			reasons: []string{"DeprecatedConfigFields"},
+		},
+		{
+			name: "unmanaged components",
+			config: `overrides:
+- component: grafana
+  managementState: Unmanaged
+- component: telemeterClient
+  managementState: Removed
+`,
+			reasons: []string{"UnmanagedComponents"},
+		},
+		{
+			name: "persistent volume claims to resize",
+			resizes: map[string][]string{
+				"prometheus-k8s": {"openshift-monitoring/prometheus-k8s-db-prometheus-k8s-0 has 10Gi instead of 40Gi"},
+			},
+			reasons: []string{"PersistentVolumeClaimsNeedResize"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := manifests.NewConfigFromString(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			o := &Operator{client: client.New("", "openshift-monitoring", "openshift-user-workload-monitoring")}
+
+			var reasons []string
+			for _, b := range o.upgradeBlockers(c, tc.resizes) {
+				reasons = append(reasons, b.reason)
+				if b.message == "" {
+					t.Errorf("expected a message for reason %q", b.reason)
+				}
+			}
+
+			if strings.Join(reasons, ",") != strings.Join(tc.reasons, ",") {
+				t.Fatalf("expected reasons %v, got %v", tc.reasons, reasons)
+			}
 		})
 	}
 }
+
+func TestConfigConcurrentUpdates(t *testing.T) {
+	const (
+		ns    = "openshift-monitoring"
+		uwmNs = "openshift-user-workload-monitoring"
+		// updates is the number of versions written to each ConfigMap.
+		updates = 200
+	)
+
+	cmoConfigMap := func(i int) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "cluster-monitoring-config",
+				Namespace:       ns,
+				ResourceVersion: strconv.Itoa(i),
+			},
+			Data: map[string]string{
+				"config.yaml": fmt.Sprintf(`enableUserWorkload: true
+telemeterClient:
+  clusterID: test
+  token: test
+prometheusK8s:
+  retention: %dh
+`, i),
+			},
+		}
+	}
+	uwmConfigMap := func(i int) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "user-workload-monitoring-config",
+				Namespace: uwmNs,
+			},
+			Data: map[string]string{
+				"config.yaml": fmt.Sprintf("prometheus:\n  retention: %dh\n", i),
+			},
+		}
+	}
+
+	kclient := fake.NewSimpleClientset(uwmConfigMap(0))
+	cmapInf := cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1.ConfigMap{}, 0, cache.Indexers{})
+	if err := cmapInf.GetStore().Add(cmoConfigMap(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	dclient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	o := &Operator{
+		namespace:                 ns,
+		namespaceUserWorkload:     uwmNs,
+		configMapName:             "cluster-monitoring-config",
+		userWorkloadConfigMapName: "user-workload-monitoring-config",
+		cmapInf:                   cmapInf,
+		clusterMonitoringInf:      cache.NewSharedIndexInformer(&cache.ListWatch{}, &unstructured.Unstructured{}, 0, cache.Indexers{}),
+		client:                    client.New("", ns, uwmNs, client.KubernetesClient(kclient), client.DynamicClient(dclient)),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The writer always updates the Cluster Monitoring ConfigMap before the
+	// User Workload Monitoring ConfigMap hence the pairs which coexisted
+	// have the same version or the user workload one is one version behind.
+	done := make(chan error)
+	go func() {
+		defer close(done)
+		for i := 1; i <= updates; i++ {
+			if err := cmapInf.GetStore().Update(cmoConfigMap(i)); err != nil {
+				done <- err
+				return
+			}
+			if _, err := kclient.CoreV1().ConfigMaps(uwmNs).Update(ctx, uwmConfigMap(i), metav1.UpdateOptions{}); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+
+	var loaded int
+	for {
+		select {
+		case err, ok := <-done:
+			if ok {
+				t.Fatal(err)
+			}
+			if loaded == 0 {
+				t.Fatal("no configuration loaded")
+			}
+			return
+		default:
+		}
+
+		c, err := o.Config(ctx, ns+"/cluster-monitoring-config")
+		if err != nil {
+			if strings.Contains(err.Error(), "kept changing") {
+				continue
+			}
+			t.Fatal(err)
+		}
+		loaded++
+
+		cmo, uwm := c.ClusterMonitoringConfiguration.PrometheusK8sConfig.Retention, c.UserWorkloadConfiguration.Prometheus.Retention
+		var cmoVersion, uwmVersion int
+		if _, err := fmt.Sscanf(cmo, "%dh", &cmoVersion); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fmt.Sscanf(uwm, "%dh", &uwmVersion); err != nil {
+			t.Fatal(err)
+		}
+		if uwmVersion != cmoVersion && uwmVersion != cmoVersion-1 {
+			t.Fatalf("configuration mixes versions which didn't coexist: cluster monitoring %s, user workload %s", cmo, uwm)
+		}
+	}
+}
+
+func TestConfigResourcesPrecedence(t *testing.T) {
+	const (
+		ns    = "openshift-monitoring"
+		uwmNs = "openshift-user-workload-monitoring"
+	)
+
+	cmapInf := cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1.ConfigMap{}, 0, cache.Indexers{})
+	if err := cmapInf.GetStore().Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-monitoring-config",
+			Namespace: ns,
+		},
+		Data: map[string]string{
+			"config.yaml": "prometheusK8s:\n  retention: 1h\n",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	clusterMonitoringInf := cache.NewSharedIndexInformer(&cache.ListWatch{}, &unstructured.Unstructured{}, 0, cache.Indexers{})
+	if err := clusterMonitoringInf.GetStore().Add(&unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "monitoring.openshift.io/v1alpha1",
+			"kind":       "ClusterMonitoring",
+			"metadata": map[string]interface{}{
+				"name": client.ClusterMonitoringName,
+			},
+			"spec": map[string]interface{}{
+				"enableUserWorkload": true,
+				"telemeterClient": map[string]interface{}{
+					"clusterID": "test",
+					"token":     "test",
+				},
+				"prometheusK8s": map[string]interface{}{
+					"retention": "2h",
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	kclient := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "user-workload-monitoring-config",
+			Namespace: uwmNs,
+		},
+		Data: map[string]string{
+			"config.yaml": "prometheus:\n  retention: 3h\n",
+		},
+	})
+	dclient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "monitoring.openshift.io/v1alpha1",
+			"kind":       "UserWorkloadMonitoring",
+			"metadata": map[string]interface{}{
+				"name":      client.UserWorkloadMonitoringName,
+				"namespace": uwmNs,
+			},
+			"spec": map[string]interface{}{
+				"prometheus": map[string]interface{}{
+					"retention": "4h",
+				},
+			},
+		},
+	})
+
+	o := &Operator{
+		namespace:                 ns,
+		namespaceUserWorkload:     uwmNs,
+		configMapName:             "cluster-monitoring-config",
+		userWorkloadConfigMapName: "user-workload-monitoring-config",
+		cmapInf:                   cmapInf,
+		clusterMonitoringInf:      clusterMonitoringInf,
+		client:                    client.New("", ns, uwmNs, client.KubernetesClient(kclient), client.DynamicClient(dclient)),
+	}
+
+	c, err := o.Config(context.Background(), ns+"/cluster-monitoring-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.ClusterMonitoringConfiguration.PrometheusK8sConfig.Retention; got != "2h" {
+		t.Fatalf("expected the retention of the ClusterMonitoring resource %q, got %q", "2h", got)
+	}
+	if got := c.UserWorkloadConfiguration.Prometheus.Retention; got != "4h" {
+		t.Fatalf("expected the retention of the UserWorkloadMonitoring resource %q, got %q", "4h", got)
+	}
+}
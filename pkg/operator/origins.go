@@ -0,0 +1,113 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"strings"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// userDefinedOrigin is the origin of the objects deployed in the namespaces
+// which don't belong to the platform.
+const userDefinedOrigin = "user-defined"
+
+// origin is the component shipping a PrometheusRule or a dashboard and the
+// version of the operator which deployed it, if known.
+type origin struct {
+	component string
+	version   string
+}
+
+// objectOrigin returns the origin of the object. The objects shipped by the
+// operator have origin labels, the origin of the other objects is their
+// namespace when it belongs to the platform (e.g. the rules shipped by the
+// etcd operator in openshift-etcd-operator).
+func objectOrigin(obj interface{}) (origin, bool) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return origin{}, false
+	}
+
+	if c, found := m.GetLabels()[manifests.OriginLabel]; found {
+		return origin{component: c, version: m.GetLabels()[manifests.OriginVersionLabel]}, true
+	}
+
+	ns := m.GetNamespace()
+	if strings.HasPrefix(ns, "openshift-") || strings.HasPrefix(ns, "kube-") {
+		return origin{component: ns}, true
+	}
+
+	return origin{component: userDefinedOrigin}, true
+}
+
+// originCollector counts the PrometheusRules and the console dashboards by
+// origin so that the alerts and dashboards can be attributed to the
+// component shipping them.
+type originCollector struct {
+	rules      cache.Store
+	dashboards cache.Store
+
+	rulesDesc      *prometheus.Desc
+	dashboardsDesc *prometheus.Desc
+}
+
+func newOriginCollector(rules, dashboards cache.Store) *originCollector {
+	return &originCollector{
+		rules:      rules,
+		dashboards: dashboards,
+		rulesDesc: prometheus.NewDesc(
+			"cluster_monitoring_operator_prometheus_rules",
+			"Number of PrometheusRule objects by origin. The origin is the component shipping the rules, the platform namespace of the rules or user-defined.",
+			[]string{"origin", "version"},
+			nil,
+		),
+		dashboardsDesc: prometheus.NewDesc(
+			"cluster_monitoring_operator_console_dashboards",
+			"Number of console dashboards by origin. The origin is the component shipping the dashboard, the platform namespace of the dashboard or user-defined.",
+			[]string{"origin", "version"},
+			nil,
+		),
+	}
+}
+
+func (c *originCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.rulesDesc
+	ch <- c.dashboardsDesc
+}
+
+func (c *originCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range []struct {
+		store cache.Store
+		desc  *prometheus.Desc
+	}{
+		{store: c.rules, desc: c.rulesDesc},
+		{store: c.dashboards, desc: c.dashboardsDesc},
+	} {
+		counts := map[origin]int{}
+		for _, obj := range s.store.List() {
+			if o, ok := objectOrigin(obj); ok {
+				counts[o]++
+			}
+		}
+
+		for o, n := range counts {
+			ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, float64(n), o.component, o.version)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestOriginCollector(t *testing.T) {
+	shipped := func(component string) map[string]string {
+		return map[string]string{
+			manifests.OriginLabel:        component,
+			manifests.OriginVersionLabel: "4.11.0",
+		}
+	}
+
+	rules := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, r := range []*monv1.PrometheusRule{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-monitoring", Name: "alertmanager-main-rules", Labels: shipped("alertmanager")}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-monitoring", Name: "prometheus-k8s-prometheus-rules", Labels: shipped("prometheus-k8s")}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-monitoring", Name: "prometheus-k8s-thanos-sidecar-rules", Labels: shipped("prometheus-k8s")}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-etcd-operator", Name: "etcd-prometheus-rules"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "api-rules"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "api-rules"}},
+	} {
+		if err := rules.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dashboards := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, cm := range []*v1.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-config-managed", Name: "grafana-dashboard-etcd", Labels: shipped("cluster-monitoring-operator")}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-config-managed", Name: "dashboard-network"}},
+	} {
+		if err := dashboards.Add(cm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `
+# HELP cluster_monitoring_operator_console_dashboards Number of console dashboards by origin. The origin is the component shipping the dashboard, the platform namespace of the dashboard or user-defined.
+# TYPE cluster_monitoring_operator_console_dashboards gauge
+cluster_monitoring_operator_console_dashboards{origin="cluster-monitoring-operator",version="4.11.0"} 1
+cluster_monitoring_operator_console_dashboards{origin="openshift-config-managed",version=""} 1
+# HELP cluster_monitoring_operator_prometheus_rules Number of PrometheusRule objects by origin. The origin is the component shipping the rules, the platform namespace of the rules or user-defined.
+# TYPE cluster_monitoring_operator_prometheus_rules gauge
+cluster_monitoring_operator_prometheus_rules{origin="alertmanager",version="4.11.0"} 1
+cluster_monitoring_operator_prometheus_rules{origin="openshift-etcd-operator",version=""} 1
+cluster_monitoring_operator_prometheus_rules{origin="prometheus-k8s",version="4.11.0"} 2
+cluster_monitoring_operator_prometheus_rules{origin="user-defined",version=""} 2
+`
+	if err := testutil.CollectAndCompare(newOriginCollector(rules, dashboards), strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
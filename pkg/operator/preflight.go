@@ -0,0 +1,248 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/klog/v2"
+)
+
+const prometheusRuleValidatingWebhook = "prometheusrules.openshift.io"
+
+// preflightCheck verifies one of the prerequisites of the operator. The
+// checks are only informational: the operator reconciles even if some fail.
+type preflightCheck struct {
+	name string
+	run  func(context.Context) error
+}
+
+type preflightResult struct {
+	name string
+	err  error
+}
+
+// preflightReport holds the results of the preflight checks in the order
+// they were run.
+type preflightReport []preflightResult
+
+func runPreflightChecks(ctx context.Context, checks []preflightCheck) preflightReport {
+	report := make(preflightReport, 0, len(checks))
+	for _, c := range checks {
+		report = append(report, preflightResult{name: c.name, err: c.run(ctx)})
+	}
+	return report
+}
+
+func (r preflightReport) failed() int {
+	var n int
+	for _, res := range r {
+		if res.err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// String returns a one-line summary listing the outcome of each check.
+func (r preflightReport) String() string {
+	results := make([]string, 0, len(r))
+	for _, res := range r {
+		if res.err != nil {
+			results = append(results, fmt.Sprintf("%s=failed (%v)", res.name, res.err))
+			continue
+		}
+		results = append(results, fmt.Sprintf("%s=ok", res.name))
+	}
+	return fmt.Sprintf("%d/%d preflight checks passed: %s", len(r)-r.failed(), len(r), strings.Join(results, ", "))
+}
+
+// preflight runs the preflight checks and publishes the report as a single
+// event and as metrics.
+func (o *Operator) preflight(ctx context.Context) {
+	report := runPreflightChecks(ctx, o.preflightChecks())
+
+	for _, res := range report {
+		if res.err != nil {
+			klog.Warningf("Preflight check %q failed: %v", res.name, res.err)
+		}
+		if o.preflightCheckStatus == nil {
+			continue
+		}
+		var v float64
+		if res.err == nil {
+			v = 1
+		}
+		o.preflightCheckStatus.WithLabelValues(res.name).Set(v)
+	}
+
+	if report.failed() > 0 {
+		o.eventRecorder.Warning("PreflightChecksFailed", report.String())
+		return
+	}
+	o.eventRecorder.Event("PreflightChecksPassed", report.String())
+}
+
+func (o *Operator) preflightChecks() []preflightCheck {
+	return []preflightCheck{
+		{name: "rbac", run: o.checkPermissions},
+		{name: "crds", run: o.checkCRDs},
+		{name: "namespaces", run: o.checkNamespaces},
+		{name: "webhook", run: o.checkWebhook},
+		{name: "storage-classes", run: o.checkStorageClasses},
+	}
+}
+
+func (o *Operator) checkPermissions(ctx context.Context) error {
+	var denied []string
+	for _, ra := range []authorizationv1.ResourceAttributes{
+		{Group: "config.openshift.io", Resource: "clusteroperators", Verb: "update"},
+		{Group: "monitoring.coreos.com", Resource: "prometheuses", Verb: "create", Namespace: o.namespace},
+		{Group: "monitoring.coreos.com", Resource: "alertmanagers", Verb: "create", Namespace: o.namespace},
+		{Group: "apps", Resource: "deployments", Verb: "update", Namespace: o.namespace},
+		{Resource: "secrets", Verb: "create", Namespace: o.namespace},
+		{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Verb: "update"},
+		{Group: "admissionregistration.k8s.io", Resource: "validatingwebhookconfigurations", Verb: "update"},
+	} {
+		allowed, err := o.client.CanI(ctx, ra)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			denied = append(denied, resourceAttributesString(ra))
+		}
+	}
+
+	if len(denied) > 0 {
+		return errors.Errorf("missing permissions: %s", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+func resourceAttributesString(ra authorizationv1.ResourceAttributes) string {
+	resource := ra.Resource
+	if ra.Group != "" {
+		resource = ra.Resource + "." + ra.Group
+	}
+	if ra.Namespace != "" {
+		return fmt.Sprintf("%s %s in %s", ra.Verb, resource, ra.Namespace)
+	}
+	return fmt.Sprintf("%s %s", ra.Verb, resource)
+}
+
+func (o *Operator) checkCRDs(ctx context.Context) error {
+	missing, err := o.client.MissingAPIResources(
+		monv1.SchemeGroupVersion.String(),
+		monv1.PrometheusName,
+		monv1.AlertmanagerName,
+		monv1.ServiceMonitorName,
+		monv1.PodMonitorName,
+		monv1.PrometheusRuleName,
+		monv1.ThanosRulerName,
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return errors.Errorf("resources not served by the %s API: %s", monv1.SchemeGroupVersion, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (o *Operator) checkNamespaces(ctx context.Context) error {
+	var missing []string
+	for _, ns := range []string{o.namespace, o.namespaceUserWorkload} {
+		found, err := o.client.HasNamespace(ctx, ns)
+		if err != nil {
+			return err
+		}
+		if !found {
+			missing = append(missing, ns)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.Errorf("missing namespaces: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (o *Operator) checkWebhook(ctx context.Context) error {
+	return o.client.ValidatingWebhookReady(ctx, prometheusRuleValidatingWebhook)
+}
+
+// checkStorageClasses verifies that the storage classes explicitly
+// referenced by the volume claim templates exist.
+func (o *Operator) checkStorageClasses(ctx context.Context) error {
+	c, err := o.loadConfig(o.namespace + "/" + o.configMapName)
+	if err != nil {
+		return err
+	}
+
+	templates := map[string]*monv1.EmbeddedPersistentVolumeClaim{
+		"prometheusK8s":    c.ClusterMonitoringConfiguration.PrometheusK8sConfig.VolumeClaimTemplate,
+		"alertmanagerMain": c.ClusterMonitoringConfiguration.AlertmanagerMainConfig.VolumeClaimTemplate,
+	}
+
+	if *c.ClusterMonitoringConfiguration.UserWorkloadEnabled {
+		uwc, _, err := o.loadUserWorkloadConfig(ctx)
+		if err != nil {
+			return err
+		}
+		templates["prometheus"] = uwc.Prometheus.VolumeClaimTemplate
+		templates["thanosRuler"] = uwc.ThanosRuler.VolumeClaimTemplate
+	}
+
+	return checkStorageClasses(templates, func(name string) (bool, error) {
+		sc, err := o.client.GetStorageClass(ctx, name)
+		return sc != nil, err
+	})
+}
+
+func checkStorageClasses(templates map[string]*monv1.EmbeddedPersistentVolumeClaim, exists func(string) (bool, error)) error {
+	components := make([]string, 0, len(templates))
+	for component := range templates {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	var missing []string
+	for _, component := range components {
+		t := templates[component]
+		if t == nil || t.Spec.StorageClassName == nil || *t.Spec.StorageClassName == "" {
+			continue
+		}
+
+		found, err := exists(*t.Spec.StorageClassName)
+		if err != nil {
+			return err
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("%q referenced by %s", *t.Spec.StorageClassName, component))
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.Errorf("missing storage classes: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
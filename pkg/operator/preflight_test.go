@@ -0,0 +1,151 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPreflightReport(t *testing.T) {
+	report := runPreflightChecks(context.Background(), []preflightCheck{
+		{name: "first", run: func(context.Context) error { return nil }},
+		{name: "second", run: func(context.Context) error { return errors.New("boom") }},
+	})
+
+	if report.failed() != 1 {
+		t.Fatalf("expected 1 failed check, got %d", report.failed())
+	}
+
+	exp := "1/2 preflight checks passed: first=ok, second=failed (boom)"
+	if report.String() != exp {
+		t.Fatalf("expected report %q, got %q", exp, report.String())
+	}
+}
+
+func TestPreflightCheckCRDs(t *testing.T) {
+	kclient := fake.NewSimpleClientset()
+	kclient.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: monv1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{Name: monv1.PrometheusName},
+				{Name: monv1.AlertmanagerName},
+				{Name: monv1.ServiceMonitorName},
+				{Name: monv1.PodMonitorName},
+				{Name: monv1.PrometheusRuleName},
+			},
+		},
+	}
+
+	o := &Operator{
+		client: client.New("", "", "", client.KubernetesClient(kclient)),
+	}
+
+	err := o.checkCRDs(context.Background())
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	exp := "resources not served by the monitoring.coreos.com/v1 API: thanosrulers"
+	if err.Error() != exp {
+		t.Fatalf("expected error %q, got %q", exp, err.Error())
+	}
+}
+
+func TestPreflightCheckNamespaces(t *testing.T) {
+	o := &Operator{
+		namespace:             "openshift-monitoring",
+		namespaceUserWorkload: "openshift-user-workload-monitoring",
+		client: client.New("", "", "", client.KubernetesClient(
+			fake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "openshift-monitoring"}}),
+		)),
+	}
+
+	err := o.checkNamespaces(context.Background())
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	exp := "missing namespaces: openshift-user-workload-monitoring"
+	if err.Error() != exp {
+		t.Fatalf("expected error %q, got %q", exp, err.Error())
+	}
+}
+
+func TestPreflightCheckStorageClasses(t *testing.T) {
+	vct := func(sc string) *monv1.EmbeddedPersistentVolumeClaim {
+		return &monv1.EmbeddedPersistentVolumeClaim{
+			Spec: v1.PersistentVolumeClaimSpec{StorageClassName: &sc},
+		}
+	}
+	exists := func(name string) (bool, error) { return name == "gp2", nil }
+
+	for _, tc := range []struct {
+		name      string
+		templates map[string]*monv1.EmbeddedPersistentVolumeClaim
+		err       string
+	}{
+		{
+			name: "no storage",
+			templates: map[string]*monv1.EmbeddedPersistentVolumeClaim{
+				"prometheusK8s": nil,
+			},
+		},
+		{
+			name: "default storage class",
+			templates: map[string]*monv1.EmbeddedPersistentVolumeClaim{
+				"prometheusK8s": {},
+			},
+		},
+		{
+			name: "existing storage class",
+			templates: map[string]*monv1.EmbeddedPersistentVolumeClaim{
+				"prometheusK8s": vct("gp2"),
+			},
+		},
+		{
+			name: "missing storage classes",
+			templates: map[string]*monv1.EmbeddedPersistentVolumeClaim{
+				"prometheusK8s":    vct("fast"),
+				"alertmanagerMain": vct("slow"),
+				"thanosRuler":      vct("gp2"),
+			},
+			err: `missing storage classes: "slow" referenced by alertmanagerMain, "fast" referenced by prometheusK8s`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkStorageClasses(tc.templates, exists)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != tc.err {
+				t.Fatalf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
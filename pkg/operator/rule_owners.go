@@ -0,0 +1,60 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+)
+
+// prometheusRuleCollector exposes the namespace and name of the
+// PrometheusRules by UID. The rule evaluation metrics of Prometheus and
+// Thanos Ruler only identify the rule file which is named after the
+// namespace, name and UID of the PrometheusRule. Since both the namespace and
+// the name may contain dashes, the UID is the only reliable way to attribute
+// the rule evaluation failures to their namespace.
+type prometheusRuleCollector struct {
+	store cache.Store
+	desc  *prometheus.Desc
+}
+
+func newPrometheusRuleCollector(store cache.Store) *prometheusRuleCollector {
+	return &prometheusRuleCollector{
+		store: store,
+		desc: prometheus.NewDesc(
+			"cluster_monitoring_operator_prometheus_rule_info",
+			"Information about the PrometheusRule objects of the cluster.",
+			// The labels don't clash with the target labels of the operator.
+			[]string{"rule_namespace", "rule_name", "uid"},
+			nil,
+		),
+	}
+}
+
+func (c *prometheusRuleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *prometheusRuleCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, obj := range c.store.List() {
+		r, ok := obj.(*monv1.PrometheusRule)
+		if !ok {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1, r.Namespace, r.Name, string(r.UID))
+	}
+}
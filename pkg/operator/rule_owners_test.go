@@ -0,0 +1,47 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"strings"
+	"testing"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPrometheusRuleCollector(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, r := range []*monv1.PrometheusRule{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "api-rules", UID: "d3b07384-d9a0-4c9b-8f3e-0c1a2b3c4d5e"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "a-api-rules", UID: "0f1e2d3c-4b5a-4968-8776-655443322110"}},
+	} {
+		if err := store.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `
+# HELP cluster_monitoring_operator_prometheus_rule_info Information about the PrometheusRule objects of the cluster.
+# TYPE cluster_monitoring_operator_prometheus_rule_info gauge
+cluster_monitoring_operator_prometheus_rule_info{rule_name="a-api-rules",rule_namespace="team",uid="0f1e2d3c-4b5a-4968-8776-655443322110"} 1
+cluster_monitoring_operator_prometheus_rule_info{rule_name="api-rules",rule_namespace="team-a",uid="d3b07384-d9a0-4c9b-8f3e-0c1a2b3c4d5e"} 1
+`
+	if err := testutil.CollectAndCompare(newPrometheusRuleCollector(store), strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
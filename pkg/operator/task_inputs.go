@@ -0,0 +1,138 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/cluster-monitoring-operator/pkg/tasks"
+	"k8s.io/klog/v2"
+)
+
+// unchangedInputsReason is the reason of the tasks skipped because their
+// inputs didn't change since their last successful run.
+const unchangedInputsReason = "inputs unchanged since the last successful run"
+
+// hashTaskInputs sets the hash of the inputs of the given tasks. The inputs
+// of a task are the common inputs (images, proxy settings, ...), the user
+// workload configuration and the sections of the cluster monitoring
+// configuration. The sections listed in owners are only inputs of the tasks
+// owning them so that changing e.g. the thanosQuerier section doesn't rerun
+// the node-exporter task.
+func hashTaskInputs(cmc *manifests.ClusterMonitoringConfiguration, owners map[string][]*tasks.TaskSpec, common []interface{}, specs ...*tasks.TaskSpec) error {
+	b, err := json.Marshal(cmc)
+	if err != nil {
+		return err
+	}
+	sections := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &sections); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(sections))
+	for k := range sections {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	shared := fnv.New64a()
+	for _, v := range common {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		shared.Write(b)
+	}
+	sharedSum := shared.Sum(nil)
+
+	for _, ts := range specs {
+		h := fnv.New64a()
+		h.Write(sharedSum)
+		for _, k := range keys {
+			if o, found := owners[k]; found && !containsTask(o, ts) {
+				continue
+			}
+			h.Write([]byte(k))
+			h.Write(sections[k])
+		}
+		ts.Inputs(strconv.FormatUint(h.Sum64(), 32))
+	}
+
+	return nil
+}
+
+func containsTask(specs []*tasks.TaskSpec, ts *tasks.TaskSpec) bool {
+	for _, s := range specs {
+		if s == ts {
+			return true
+		}
+	}
+	return false
+}
+
+// skipUnchangedTasks skips the tasks whose inputs are the same as during
+// their last successful run. It returns the names of the skipped tasks.
+func (o *Operator) skipUnchangedTasks(specs ...*tasks.TaskSpec) []string {
+	var skipped []string
+	for _, ts := range specs {
+		hash := ts.InputsHash()
+		if hash == "" {
+			continue
+		}
+		if last, found := o.lastTaskInputs[ts.Name]; found && last == hash {
+			ts.Skip(unchangedInputsReason)
+			skipped = append(skipped, ts.Name)
+		}
+	}
+	return skipped
+}
+
+// recordTaskInputs remembers the inputs of the tasks which succeeded. The
+// inputs of the failed tasks are forgotten so that they run again.
+func (o *Operator) recordTaskInputs(states []tasks.TaskState) {
+	if o.lastTaskInputs == nil {
+		o.lastTaskInputs = map[string]string{}
+	}
+	for _, s := range states {
+		if s.Err != nil || s.InputsHash == "" {
+			delete(o.lastTaskInputs, s.Name)
+			continue
+		}
+		o.lastTaskInputs[s.Name] = s.InputsHash
+	}
+}
+
+// requestFullSync makes the next reconciliation run all the tasks. It is
+// called when the reconciliation is triggered by something else than the
+// configuration (e.g. a managed object modified outside of the operator, a
+// certificate rotation or the periodic resync) since the inputs hashes only
+// cover the configuration.
+func (o *Operator) requestFullSync() {
+	atomic.StoreInt32(&o.fullSyncRequested, 1)
+}
+
+// takeFullSyncRequest reports whether a full reconciliation was requested
+// since the last call.
+func (o *Operator) takeFullSyncRequest() bool {
+	if atomic.SwapInt32(&o.fullSyncRequested, 0) == 1 {
+		klog.V(4).Info("Running all the tasks because the reconciliation wasn't triggered by a configuration change.")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/cluster-monitoring-operator/pkg/tasks"
+)
+
+func TestHashTaskInputs(t *testing.T) {
+	hashes := func(t *testing.T, config string, common ...interface{}) map[string]string {
+		t.Helper()
+
+		c, err := manifests.NewConfigFromString(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		thanosQuerier := tasks.NewTaskSpec("Updating Thanos Querier", nil)
+		nodeExporter := tasks.NewTaskSpec("Updating node-exporter", nil)
+		prometheusK8s := tasks.NewTaskSpec("Updating Prometheus-k8s", nil)
+		err = hashTaskInputs(
+			c.ClusterMonitoringConfiguration,
+			map[string][]*tasks.TaskSpec{
+				"thanosQuerier": {thanosQuerier},
+				"nodeExporter":  {nodeExporter},
+			},
+			common,
+			thanosQuerier, nodeExporter, prometheusK8s,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return map[string]string{
+			"thanosQuerier": thanosQuerier.InputsHash(),
+			"nodeExporter":  nodeExporter.InputsHash(),
+			"prometheusK8s": prometheusK8s.InputsHash(),
+		}
+	}
+
+	base := hashes(t, "", "v1")
+	for task, hash := range base {
+		if hash == "" {
+			t.Fatalf("expected inputs hash for %s", task)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		config  string
+		version string
+		changed []string
+	}{
+		{
+			name:    "same inputs",
+			version: "v1",
+		},
+		{
+			name: "owned section",
+			config: `thanosQuerier:
+  logLevel: debug`,
+			version: "v1",
+			changed: []string{"thanosQuerier"},
+		},
+		{
+			name: "shared section",
+			config: `prometheusK8s:
+  logLevel: debug`,
+			version: "v1",
+			changed: []string{"nodeExporter", "prometheusK8s", "thanosQuerier"},
+		},
+		{
+			name:    "common inputs",
+			version: "v2",
+			changed: []string{"nodeExporter", "prometheusK8s", "thanosQuerier"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var changed []string
+			for _, task := range []string{"nodeExporter", "prometheusK8s", "thanosQuerier"} {
+				if hashes(t, tc.config, tc.version)[task] != base[task] {
+					changed = append(changed, task)
+				}
+			}
+
+			if !reflect.DeepEqual(changed, tc.changed) {
+				t.Fatalf("expected changed inputs for %v, got %v", tc.changed, changed)
+			}
+		})
+	}
+}
+
+func TestSkipUnchangedTasks(t *testing.T) {
+	o := &Operator{}
+
+	alertmanager := tasks.NewTaskSpec("Updating Alertmanager", nil).Inputs("a")
+	grafana := tasks.NewTaskSpec("Updating Grafana", nil).Inputs("b")
+	checks := tasks.NewTaskSpec("Checking monitoring Routes", nil)
+	if skipped := o.skipUnchangedTasks(alertmanager, grafana, checks); len(skipped) != 0 {
+		t.Fatalf("expected no skipped tasks before the first run, got %v", skipped)
+	}
+
+	o.recordTaskInputs([]tasks.TaskState{
+		{Name: alertmanager.Name, StartTime: time.Now(), InputsHash: "a"},
+		{Name: grafana.Name, StartTime: time.Now(), InputsHash: "b", Err: errors.New("boom")},
+		{Name: checks.Name, StartTime: time.Now()},
+	})
+
+	alertmanager = tasks.NewTaskSpec("Updating Alertmanager", nil).Inputs("a")
+	grafana = tasks.NewTaskSpec("Updating Grafana", nil).Inputs("b")
+	checks = tasks.NewTaskSpec("Checking monitoring Routes", nil)
+	skipped := o.skipUnchangedTasks(alertmanager, grafana, checks)
+	if !reflect.DeepEqual(skipped, []string{"Updating Alertmanager"}) {
+		t.Fatalf("expected only the Alertmanager task to be skipped, got %v", skipped)
+	}
+
+	alertmanager = tasks.NewTaskSpec("Updating Alertmanager", nil).Inputs("c")
+	if skipped := o.skipUnchangedTasks(alertmanager); len(skipped) != 0 {
+		t.Fatalf("expected no skipped tasks after the inputs changed, got %v", skipped)
+	}
+}
+
+func TestFullSyncRequest(t *testing.T) {
+	o := &Operator{}
+	if o.takeFullSyncRequest() {
+		t.Fatal("expected no full sync request")
+	}
+
+	o.requestFullSync()
+	o.requestFullSync()
+	if !o.takeFullSyncRequest() {
+		t.Fatal("expected a full sync request")
+	}
+	if o.takeFullSyncRequest() {
+		t.Fatal("expected the full sync request to be consumed")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"sort"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/cluster-monitoring-operator/pkg/tasks"
+)
+
+// taskResults holds the state collected while the tasks render and apply
+// their objects: the unmanaged objects and the workloads modified outside of
+// the operator found by the client, and the rules removed by each exclusion
+// from each PrometheusRule object. The tasks which don't run (skipped
+// because their inputs didn't change, paused or after a failed dependency)
+// collect nothing, hence their results are kept from their last run so that
+// the conditions and metrics derived from them don't change between
+// reconciliations.
+type taskResults struct {
+	unmanaged     map[string][]client.UnmanagedObject
+	modified      map[string][]string
+	excludedRules map[string]map[manifests.ExcludedRule]int
+}
+
+// update replaces the results of the tasks which ran with the ones collected
+// during the reconciliation. The objects applied outside of a task are
+// reported under an empty task name and always replaced.
+func (r *taskResults) update(states []tasks.TaskState, unmanaged map[string][]client.UnmanagedObject, modified map[string][]string, excludedRules map[string]map[manifests.ExcludedRule]int) {
+	if r.unmanaged == nil {
+		r.unmanaged = map[string][]client.UnmanagedObject{}
+		r.modified = map[string][]string{}
+		r.excludedRules = map[string]map[manifests.ExcludedRule]int{}
+	}
+
+	ran := []string{""}
+	for _, s := range states {
+		ran = append(ran, s.Name)
+	}
+	for _, name := range ran {
+		delete(r.unmanaged, name)
+		delete(r.modified, name)
+	}
+
+	for task, objects := range unmanaged {
+		r.unmanaged[task] = objects
+	}
+	for task, objects := range modified {
+		r.modified[task] = objects
+	}
+	// The rules are counted per object rather than per task since the
+	// factory doesn't know which task renders an object. Every task
+	// rendering a PrometheusRule object overwrites its counts.
+	for object, removed := range excludedRules {
+		r.excludedRules[object] = removed
+	}
+}
+
+// unmanagedObjects returns the unmanaged objects found by all the tasks,
+// sorted by kind, namespace and name.
+func (r *taskResults) unmanagedObjects() []client.UnmanagedObject {
+	var ret []client.UnmanagedObject
+	for _, objects := range r.unmanaged {
+		ret = append(ret, objects...)
+	}
+	client.SortUnmanagedObjects(ret)
+	return ret
+}
+
+// modifiedObjects returns the workloads modified outside of the operator
+// found by all the tasks, sorted.
+func (r *taskResults) modifiedObjects() []string {
+	var ret []string
+	for _, objects := range r.modified {
+		ret = append(ret, objects...)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// excludedRulesCount returns the number of rules removed by each of the
+// exclusions from the PrometheusRule objects rendered by all the tasks.
+func (r *taskResults) excludedRulesCount(exclusions []manifests.ExcludedRule) map[manifests.ExcludedRule]int {
+	return manifests.CountExcludedRules(exclusions, r.excludedRules)
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/cluster-monitoring-operator/pkg/tasks"
+)
+
+func TestTaskResultsOfSkippedTasks(t *testing.T) {
+	config, err := manifests.NewConfigFromString(`excludedRules:
+- alert: Watchdog
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watchdog := manifests.ExcludedRule{Alert: "Watchdog"}
+
+	const (
+		prometheus   = "Updating Prometheus-k8s"
+		alertmanager = "Updating Alertmanager"
+	)
+	ran := func(names ...string) []tasks.TaskState {
+		states := make([]tasks.TaskState, 0, len(names))
+		for _, name := range names {
+			states = append(states, tasks.TaskState{Name: name, StartTime: time.Now()})
+		}
+		return states
+	}
+
+	o := &Operator{}
+	sync := func() ([]client.Condition, []string) {
+		var reasons []string
+		for _, b := range o.upgradeBlockers(config, nil) {
+			reasons = append(reasons, b.reason)
+		}
+		return o.taskResultConditions(config), reasons
+	}
+
+	// All the tasks run during the first reconciliation.
+	o.taskResults.update(
+		ran(prometheus, alertmanager),
+		map[string][]client.UnmanagedObject{
+			prometheus: {{Kind: "ConfigMap", Namespace: "openshift-monitoring", Name: "prometheus-k8s-rulefiles", Drift: []string{"data"}}},
+		},
+		map[string][]string{
+			alertmanager: {"Alertmanager openshift-monitoring/main"},
+		},
+		map[string]map[manifests.ExcludedRule]int{
+			"openshift-monitoring/cluster-monitoring-operator-prometheus-rules": {watchdog: 1},
+		},
+	)
+	conditions, reasons := sync()
+	for _, c := range conditions {
+		if c.Status != configv1.ConditionTrue {
+			t.Fatalf("expected the %s condition to be true, got %v", c.Type, c)
+		}
+	}
+	if !reflect.DeepEqual(reasons, []string{"ResourcesModifiedExternally"}) {
+		t.Fatalf("expected the modified Alertmanager to block upgrades, got %v", reasons)
+	}
+
+	// The tasks are skipped during the second reconciliation, nothing is
+	// collected.
+	o.taskResults.update(ran(), nil, nil, nil)
+	if got, gotReasons := sync(); !reflect.DeepEqual(got, conditions) || !reflect.DeepEqual(gotReasons, reasons) {
+		t.Fatalf("expected the conditions %v and blockers %v to be kept, got %v and %v", conditions, reasons, got, gotReasons)
+	}
+
+	// The Prometheus task runs again and finds its objects as desired, the
+	// results of the skipped Alertmanager task are kept.
+	o.taskResults.update(
+		ran(prometheus),
+		nil,
+		nil,
+		map[string]map[manifests.ExcludedRule]int{
+			"openshift-monitoring/cluster-monitoring-operator-prometheus-rules": {watchdog: 1},
+		},
+	)
+	conditions, reasons = sync()
+	for _, c := range conditions {
+		expected := configv1.ConditionTrue
+		if c.Type == client.UnmanagedDrift {
+			expected = configv1.ConditionFalse
+		}
+		if c.Status != expected {
+			t.Fatalf("expected the %s condition to be %s, got %v", c.Type, expected, c)
+		}
+	}
+	if !reflect.DeepEqual(reasons, []string{"ResourcesModifiedExternally"}) {
+		t.Fatalf("expected the modified Alertmanager to still block upgrades, got %v", reasons)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/tasks"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// taskStateConfigMap holds the state of the reconciliation tasks so that
+// GitOps tools and dashboards can track the health of the operator without
+// reading its logs.
+const taskStateConfigMap = "cluster-monitoring-operator-task-state"
+
+// taskState is the state of a task as stored in the task state ConfigMap.
+type taskState struct {
+	// LastApplyTime is the start time of the last run of the task.
+	LastApplyTime metav1.Time `json:"lastApplyTime"`
+	// LastSuccessfulApplyTime is the start time of the last successful run
+	// of the task.
+	LastSuccessfulApplyTime *metav1.Time `json:"lastSuccessfulApplyTime,omitempty"`
+	// LastError is the error returned by the last run of the task, if any.
+	LastError string `json:"lastError,omitempty"`
+	// InputsHash is the hash of the inputs applied by the last run of the
+	// task. It falls back to the hash of the whole configuration for the
+	// tasks without dedicated inputs.
+	InputsHash string `json:"inputsHash,omitempty"`
+}
+
+// taskStateKey returns the ConfigMap key of a task (e.g. "Updating
+// Prometheus-k8s" becomes "updating-prometheus-k8s").
+func taskStateKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), " ", "-")
+}
+
+// mergeTaskStates updates the data of the ConfigMap with the outcome of the
+// given tasks. The state of the tasks which didn't run is kept as is.
+// configHash is recorded for the tasks which don't report the hash of their
+// inputs.
+func mergeTaskStates(cm *v1.ConfigMap, states []tasks.TaskState, configHash string) error {
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	for _, s := range states {
+		key := taskStateKey(s.Name)
+
+		var ts taskState
+		if v, found := cm.Data[key]; found {
+			// Start over if the previous state can't be decoded.
+			_ = json.Unmarshal([]byte(v), &ts)
+		}
+
+		ts.LastApplyTime = metav1.NewTime(s.StartTime)
+		ts.InputsHash = s.InputsHash
+		if ts.InputsHash == "" {
+			ts.InputsHash = configHash
+		}
+		ts.LastError = ""
+		if s.Err != nil {
+			ts.LastError = s.Err.Error()
+		} else {
+			t := ts.LastApplyTime
+			ts.LastSuccessfulApplyTime = &t
+		}
+
+		b, err := json.Marshal(ts)
+		if err != nil {
+			return err
+		}
+		cm.Data[key] = string(b)
+	}
+
+	return nil
+}
+
+// persistTaskStates stores the outcome of the tasks in the task state
+// ConfigMap.
+func (o *Operator) persistTaskStates(ctx context.Context, states []tasks.TaskState, configHash string) error {
+	cm, err := o.client.GetConfigmap(ctx, o.namespace, taskStateConfigMap)
+	if apierrors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      taskStateConfigMap,
+				Namespace: o.namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "cluster-monitoring-operator",
+					"app.kubernetes.io/part-of":    "openshift-monitoring",
+				},
+			},
+		}
+	} else if err != nil {
+		return errors.Wrap(err, "retrieving task state ConfigMap failed")
+	}
+
+	if err := mergeTaskStates(cm, states, configHash); err != nil {
+		return errors.Wrap(err, "encoding task state failed")
+	}
+
+	return o.client.CreateOrUpdateConfigMap(ctx, cm)
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/tasks"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestMergeTaskStates(t *testing.T) {
+	first := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	cm := &v1.ConfigMap{}
+	err := mergeTaskStates(cm, []tasks.TaskState{
+		{Name: "Updating Prometheus-k8s", StartTime: first},
+		{Name: "Updating Alertmanager", StartTime: first},
+	}, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mergeTaskStates(cm, []tasks.TaskState{
+		{Name: "Updating Prometheus-k8s", StartTime: second, Err: errors.New("boom")},
+		{Name: "Updating node-exporter", StartTime: second, InputsHash: "xyz"},
+	}, "def")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		key            string
+		lastApply      time.Time
+		lastSuccessful time.Time
+		lastError      string
+		inputsHash     string
+	}{
+		{
+			key:            "updating-prometheus-k8s",
+			lastApply:      second,
+			lastSuccessful: first,
+			lastError:      "boom",
+			inputsHash:     "def",
+		},
+		{
+			key:            "updating-alertmanager",
+			lastApply:      first,
+			lastSuccessful: first,
+			inputsHash:     "abc",
+		},
+		{
+			key:            "updating-node-exporter",
+			lastApply:      second,
+			lastSuccessful: second,
+			inputsHash:     "xyz",
+		},
+	} {
+		t.Run(tc.key, func(t *testing.T) {
+			v, found := cm.Data[tc.key]
+			if !found {
+				t.Fatalf("missing key %q", tc.key)
+			}
+
+			var ts taskState
+			if err := json.Unmarshal([]byte(v), &ts); err != nil {
+				t.Fatal(err)
+			}
+
+			if !ts.LastApplyTime.Time.Equal(tc.lastApply) {
+				t.Fatalf("expected last apply time %v, got %v", tc.lastApply, ts.LastApplyTime)
+			}
+			if ts.LastSuccessfulApplyTime == nil || !ts.LastSuccessfulApplyTime.Time.Equal(tc.lastSuccessful) {
+				t.Fatalf("expected last successful apply time %v, got %v", tc.lastSuccessful, ts.LastSuccessfulApplyTime)
+			}
+			if ts.LastError != tc.lastError {
+				t.Fatalf("expected last error %q, got %q", tc.lastError, ts.LastError)
+			}
+			if ts.InputsHash != tc.inputsHash {
+				t.Fatalf("expected inputs hash %q, got %q", tc.inputsHash, ts.InputsHash)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package promclient queries the Prometheus-compatible HTTP API.
+package promclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Querier runs instant PromQL queries returning a single value.
+type Querier interface {
+	Query(ctx context.Context, query string) (float64, error)
+}
+
+// LabelQuerier runs instant PromQL queries returning one value per value of
+// a label.
+type LabelQuerier interface {
+	QueryByLabel(ctx context.Context, query, label string) (map[string]float64, error)
+}
+
+// HTTPQuerier queries the Prometheus HTTP API with a bearer token.
+type HTTPQuerier struct {
+	url       string
+	tokenFile string
+	client    *http.Client
+}
+
+// NewHTTPQuerier returns a querier for the Prometheus-compatible API served at
+// the given URL. The bearer token is read from tokenFile on every request so
+// that rotated tokens are picked up and the server certificate is verified
+// against the CA bundle in caFile.
+func NewHTTPQuerier(u, tokenFile, caFile string) (*HTTPQuerier, error) {
+	ca, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading CA bundle failed")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.Errorf("no certificate found in %s", caFile)
+	}
+
+	return &HTTPQuerier{
+		url:       u,
+		tokenFile: tokenFile,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  [2]interface{}    `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// Query runs the given query and returns the value of the first sample of
+// the resulting vector. It fails if the vector is empty.
+func (q *HTTPQuerier) Query(ctx context.Context, query string) (float64, error) {
+	resp, err := q.do(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return parseQueryResponse(resp)
+}
+
+// QueryByLabel runs the given query and returns the values of the resulting
+// vector keyed by the value of the given label.
+func (q *HTTPQuerier) QueryByLabel(ctx context.Context, query, label string) (map[string]float64, error) {
+	resp, err := q.do(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return parseLabelQueryResponse(resp, label)
+}
+
+func (q *HTTPQuerier) do(ctx context.Context, query string) (*http.Response, error) {
+	token, err := ioutil.ReadFile(q.tokenFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading bearer token failed")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/query?%s", q.url, url.Values{"query": []string{query}}.Encode()), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request failed")
+	}
+	req.Header.Set("Authorization", "Bearer "+string(token))
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying Prometheus failed")
+	}
+
+	return resp, nil
+}
+
+func parseQueryResponse(resp *http.Response) (float64, error) {
+	r, err := decodeQueryResponse(resp)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(r.Data.Result) == 0 {
+		return 0, errors.New("empty result")
+	}
+
+	return parseSampleValue(r.Data.Result[0].Value[1])
+}
+
+func parseLabelQueryResponse(resp *http.Response, label string) (map[string]float64, error) {
+	r, err := decodeQueryResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64, len(r.Data.Result))
+	for _, sample := range r.Data.Result {
+		v, err := parseSampleValue(sample.Value[1])
+		if err != nil {
+			return nil, err
+		}
+		values[sample.Metric[label]] = v
+	}
+
+	return values, nil
+}
+
+func decodeQueryResponse(resp *http.Response) (*queryResponse, error) {
+	var r queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, errors.Wrapf(err, "decoding response failed (status code: %d)", resp.StatusCode)
+	}
+
+	if r.Status != "success" {
+		return nil, errors.Errorf("query failed: %s: %s", r.ErrorType, r.Error)
+	}
+
+	if r.Data.ResultType != "vector" {
+		return nil, errors.Errorf("unexpected result type %q", r.Data.ResultType)
+	}
+
+	return &r, nil
+}
+
+func parseSampleValue(v interface{}) (float64, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, errors.Errorf("unexpected sample value %v", v)
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseQueryResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		expected float64
+		err      bool
+	}{
+		{
+			name:     "vector",
+			body:     `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1643723200.123,"42"]}]}}`,
+			expected: 42,
+		},
+		{
+			name: "empty vector",
+			body: `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			err:  true,
+		},
+		{
+			name: "error",
+			body: `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			err:  true,
+		},
+		{
+			name: "invalid body",
+			body: `Forbidden`,
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseQueryResponse(&http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(tc.body)),
+			})
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expected {
+				t.Fatalf("expected %f, got %f", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseLabelQueryResponse(t *testing.T) {
+	got, err := parseLabelQueryResponse(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"namespace":"team-a"},"value":[1643723200.123,"3"]},{"metric":{"namespace":"team-b"},"value":[1643723200.123,"1"]}]}}`)),
+	}, "namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]float64{"team-a": 3, "team-b": 1}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
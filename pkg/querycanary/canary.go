@@ -0,0 +1,211 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package querycanary periodically runs representative queries against
+// Thanos Querier and records their latency and success as metrics.
+package querycanary
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/promclient"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+)
+
+// Query is a query run by the canary.
+type Query struct {
+	// Name identifies the query in the metrics.
+	Name  string
+	Query string
+}
+
+// DefaultQueries go through the different parts of the query path: the
+// selection of a series from all the Prometheus instances, the evaluation of
+// a range function and an aggregation over many series.
+var DefaultQueries = []Query{
+	{
+		Name:  "instant",
+		Query: `count(up{job="prometheus-k8s"})`,
+	},
+	{
+		Name:  "rate",
+		Query: `sum(rate(prometheus_tsdb_head_samples_appended_total[5m]))`,
+	},
+	{
+		Name:  "aggregation",
+		Query: `count by (namespace) (kube_pod_info)`,
+	},
+}
+
+// Canary runs the queries at the configured interval. It doesn't do
+// anything until it's been enabled.
+type Canary struct {
+	querier promclient.LabelQuerier
+	queries []Query
+	now     func() time.Time
+
+	mtx      sync.Mutex
+	enabled  bool
+	interval time.Duration
+
+	trigger chan struct{}
+
+	queriesTotal  *prometheus.CounterVec
+	failuresTotal *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	lastSuccess   *prometheus.GaugeVec
+}
+
+func New(querier promclient.LabelQuerier, queries []Query) *Canary {
+	return &Canary{
+		querier: querier,
+		queries: queries,
+		now:     time.Now,
+		trigger: make(chan struct{}, 1),
+		queriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cluster_monitoring_operator_query_canary_queries_total",
+			Help: "Number of queries run by the query canary against Thanos Querier.",
+		}, []string{"query"}),
+		failuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cluster_monitoring_operator_query_canary_failures_total",
+			Help: "Number of queries run by the query canary against Thanos Querier which failed or returned no data.",
+		}, []string{"query"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cluster_monitoring_operator_query_canary_duration_seconds",
+			Help:    "Duration of the queries run by the query canary against Thanos Querier.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"query"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cluster_monitoring_operator_query_canary_last_success_timestamp_seconds",
+			Help: "Timestamp of the last successful query run by the query canary against Thanos Querier.",
+		}, []string{"query"}),
+	}
+}
+
+// SetConfig enables or disables the canary and updates the interval between
+// two runs of the queries.
+func (c *Canary) SetConfig(enabled bool, interval time.Duration) {
+	c.mtx.Lock()
+	changed := c.enabled != enabled || c.interval != interval
+	c.enabled, c.interval = enabled, interval
+	c.mtx.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if !enabled {
+		// Stale timestamps would hide the canary being disabled.
+		c.lastSuccess.Reset()
+	}
+
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Canary) config() (bool, time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.enabled, c.interval
+}
+
+// Run runs the queries periodically until the context is canceled.
+func (c *Canary) Run(ctx context.Context, _ int) {
+	var (
+		timer *time.Timer
+		tick  <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-c.trigger:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		timer, tick = nil, nil
+
+		enabled, interval := c.config()
+		if !enabled {
+			continue
+		}
+
+		c.run(ctx, interval)
+		timer = time.NewTimer(interval)
+		tick = timer.C
+	}
+}
+
+// run runs all the queries once. Each query is bound by the interval so that
+// a stuck query path doesn't delay the next run.
+func (c *Canary) run(ctx context.Context, timeout time.Duration) {
+	for _, q := range c.queries {
+		if err := c.runQuery(ctx, q, timeout); err != nil {
+			klog.V(4).Infof("Query canary %q failed: %v", q.Name, err)
+		}
+	}
+}
+
+func (c *Canary) runQuery(ctx context.Context, q Query, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c.queriesTotal.WithLabelValues(q.Name).Inc()
+
+	start := c.now()
+	res, err := c.querier.QueryByLabel(ctx, q.Query, "")
+	c.duration.WithLabelValues(q.Name).Observe(c.now().Sub(start).Seconds())
+
+	if err == nil && len(res) == 0 {
+		err = errors.New("empty result")
+	}
+	if err != nil {
+		c.failuresTotal.WithLabelValues(q.Name).Inc()
+		return err
+	}
+
+	c.lastSuccess.WithLabelValues(q.Name).Set(float64(c.now().Unix()))
+	return nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *Canary) Describe(ch chan<- *prometheus.Desc) {
+	c.queriesTotal.Describe(ch)
+	c.failuresTotal.Describe(ch)
+	c.duration.Describe(ch)
+	c.lastSuccess.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *Canary) Collect(ch chan<- prometheus.Metric) {
+	c.queriesTotal.Collect(ch)
+	c.failuresTotal.Collect(ch)
+	c.duration.Collect(ch)
+	c.lastSuccess.Collect(ch)
+}
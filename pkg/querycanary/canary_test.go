@@ -0,0 +1,111 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package querycanary
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type querierFunc func(query, label string) (map[string]float64, error)
+
+func (f querierFunc) QueryByLabel(_ context.Context, query, label string) (map[string]float64, error) {
+	return f(query, label)
+}
+
+func TestRun(t *testing.T) {
+	c := New(querierFunc(func(query, _ string) (map[string]float64, error) {
+		switch query {
+		case "up":
+			return map[string]float64{"": 3}, nil
+		case "absent_metric":
+			return map[string]float64{}, nil
+		default:
+			return nil, errors.New("connection refused")
+		}
+	}), []Query{
+		{Name: "ok", Query: "up"},
+		{Name: "empty", Query: "absent_metric"},
+		{Name: "error", Query: "vector(1)"},
+	})
+
+	now := time.Unix(1650000000, 0)
+	c.now = func() time.Time { return now }
+
+	c.run(context.Background(), time.Minute)
+
+	expected := `
+# HELP cluster_monitoring_operator_query_canary_failures_total Number of queries run by the query canary against Thanos Querier which failed or returned no data.
+# TYPE cluster_monitoring_operator_query_canary_failures_total counter
+cluster_monitoring_operator_query_canary_failures_total{query="empty"} 1
+cluster_monitoring_operator_query_canary_failures_total{query="error"} 1
+# HELP cluster_monitoring_operator_query_canary_last_success_timestamp_seconds Timestamp of the last successful query run by the query canary against Thanos Querier.
+# TYPE cluster_monitoring_operator_query_canary_last_success_timestamp_seconds gauge
+cluster_monitoring_operator_query_canary_last_success_timestamp_seconds{query="ok"} 1.65e+09
+# HELP cluster_monitoring_operator_query_canary_queries_total Number of queries run by the query canary against Thanos Querier.
+# TYPE cluster_monitoring_operator_query_canary_queries_total counter
+cluster_monitoring_operator_query_canary_queries_total{query="empty"} 1
+cluster_monitoring_operator_query_canary_queries_total{query="error"} 1
+cluster_monitoring_operator_query_canary_queries_total{query="ok"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"cluster_monitoring_operator_query_canary_failures_total",
+		"cluster_monitoring_operator_query_canary_last_success_timestamp_seconds",
+		"cluster_monitoring_operator_query_canary_queries_total",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := testutil.CollectAndCount(c, "cluster_monitoring_operator_query_canary_duration_seconds"); n != 3 {
+		t.Fatalf("expected 3 duration histograms, got %d", n)
+	}
+
+	// Disabling the canary drops the timestamps of the last successes.
+	c.SetConfig(true, time.Minute)
+	c.SetConfig(false, time.Minute)
+	if n := testutil.CollectAndCount(c, "cluster_monitoring_operator_query_canary_last_success_timestamp_seconds"); n != 0 {
+		t.Fatalf("expected no last success timestamp, got %d", n)
+	}
+}
+
+func TestRunLoop(t *testing.T) {
+	queried := make(chan string, 10)
+	c := New(querierFunc(func(query, _ string) (map[string]float64, error) {
+		queried <- query
+		return map[string]float64{"": 1}, nil
+	}), []Query{{Name: "ok", Query: "up"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx, 1)
+
+	select {
+	case q := <-queried:
+		t.Fatalf("expected no query before the canary is enabled, got %q", q)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.SetConfig(true, time.Hour)
+	select {
+	case <-queried:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a query once the canary is enabled")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package recommender computes resource requests for the platform Prometheus
+// from its observed usage.
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/promclient"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	workingSetQuery = `max(max_over_time(container_memory_working_set_bytes{namespace=%q,pod=~"prometheus-k8s-.*",container="prometheus"}[1h]))`
+	headSeriesQuery = `max(max_over_time(prometheus_tsdb_head_series{namespace=%q,job="prometheus-k8s"}[1h]))`
+
+	// workingSetHeadroom is the margin added on top of the observed working
+	// set.
+	workingSetHeadroom = 1.2
+	// bytesPerSeries is a conservative estimate of the memory used by each
+	// series in the head block.
+	bytesPerSeries = 4 * 1024
+	// changeThreshold is the relative difference below which the previous
+	// recommendation is kept to avoid restarting Prometheus needlessly.
+	changeThreshold = 0.1
+)
+
+// Recommendation is the outcome of a recommendation round.
+type Recommendation struct {
+	MemoryRequest   resource.Quantity
+	WorkingSetBytes float64
+	HeadSeries      float64
+}
+
+type Recommender struct {
+	querier   promclient.Querier
+	namespace string
+}
+
+func New(querier promclient.Querier, namespace string) *Recommender {
+	return &Recommender{
+		querier:   querier,
+		namespace: namespace,
+	}
+}
+
+// Recommend queries the current usage of the prometheus-k8s pods and
+// returns the memory request to apply within the [min, max] bounds. Both
+// bounds are optional.
+func (r *Recommender) Recommend(ctx context.Context, previous, min, max *resource.Quantity) (*Recommendation, error) {
+	ws, err := r.querier.Query(ctx, fmt.Sprintf(workingSetQuery, r.namespace))
+	if err != nil {
+		return nil, errors.Wrap(err, "querying the Prometheus working set failed")
+	}
+
+	hs, err := r.querier.Query(ctx, fmt.Sprintf(headSeriesQuery, r.namespace))
+	if err != nil {
+		return nil, errors.Wrap(err, "querying the Prometheus head series failed")
+	}
+
+	return &Recommendation{
+		MemoryRequest:   MemoryRequest(ws, hs, previous, min, max),
+		WorkingSetBytes: ws,
+		HeadSeries:      hs,
+	}, nil
+}
+
+// MemoryRequest returns the memory request matching the observed working
+// set and number of head series, rounded up to the next MiB. The previous
+// value is returned as long as it is close enough to the new estimate.
+func MemoryRequest(workingSetBytes, headSeries float64, previous, min, max *resource.Quantity) resource.Quantity {
+	v := math.Max(workingSetBytes*workingSetHeadroom, headSeries*bytesPerSeries)
+
+	if previous != nil && !previous.IsZero() {
+		prev := float64(previous.Value())
+		if math.Abs(v-prev)/prev <= changeThreshold {
+			v = prev
+		}
+	}
+
+	mib := int64(math.Ceil(v / (1 << 20)))
+	q := *resource.NewQuantity(mib<<20, resource.BinarySI)
+
+	if min != nil && q.Cmp(*min) < 0 {
+		return min.DeepCopy()
+	}
+
+	if max != nil && q.Cmp(*max) > 0 {
+		return max.DeepCopy()
+	}
+
+	return q
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func quantityPtr(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func TestMemoryRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		workingSet float64
+		headSeries float64
+		previous   *resource.Quantity
+		min, max   *resource.Quantity
+		expected   string
+	}{
+		{
+			name:       "working set with headroom",
+			workingSet: 1000 * (1 << 20),
+			headSeries: 1000,
+			expected:   "1200Mi",
+		},
+		{
+			name:       "head series estimate",
+			workingSet: 100 * (1 << 20),
+			headSeries: 1000000,
+			expected:   "3907Mi",
+		},
+		{
+			name:       "previous value kept",
+			workingSet: 1000 * (1 << 20),
+			previous:   quantityPtr("1250Mi"),
+			expected:   "1250Mi",
+		},
+		{
+			name:       "previous value replaced",
+			workingSet: 1000 * (1 << 20),
+			previous:   quantityPtr("2Gi"),
+			expected:   "1200Mi",
+		},
+		{
+			name:       "lower bound",
+			workingSet: 100 * (1 << 20),
+			min:        quantityPtr("1Gi"),
+			max:        quantityPtr("4Gi"),
+			expected:   "1Gi",
+		},
+		{
+			name:       "upper bound",
+			workingSet: 8 * (1 << 30),
+			min:        quantityPtr("1Gi"),
+			max:        quantityPtr("4Gi"),
+			expected:   "4Gi",
+		},
+		{
+			name:       "previous value out of bounds",
+			workingSet: 8 * (1 << 30),
+			previous:   quantityPtr("9Gi"),
+			max:        quantityPtr("4Gi"),
+			expected:   "4Gi",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := MemoryRequest(tc.workingSet, tc.headSeries, tc.previous, tc.min, tc.max)
+			if got.Cmp(resource.MustParse(tc.expected)) != 0 {
+				t.Fatalf("expected %s, got %s", tc.expected, got.String())
+			}
+		})
+	}
+}
+
+type fakeQuerier map[string]float64
+
+func (f fakeQuerier) Query(_ context.Context, query string) (float64, error) {
+	for k, v := range f {
+		if strings.Contains(query, k) {
+			return v, nil
+		}
+	}
+	return 0, errors.New("empty result")
+}
+
+func TestRecommend(t *testing.T) {
+	r := New(fakeQuerier{
+		"container_memory_working_set_bytes": 1000 * (1 << 20),
+		"prometheus_tsdb_head_series":        1000,
+	}, "openshift-monitoring")
+
+	rec, err := r.Recommend(context.Background(), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.MemoryRequest.Cmp(resource.MustParse("1200Mi")) != 0 {
+		t.Fatalf("expected 1200Mi, got %s", rec.MemoryRequest.String())
+	}
+
+	if rec.HeadSeries != 1000 {
+		t.Fatalf("expected 1000 head series, got %f", rec.HeadSeries)
+	}
+
+	_, err = New(fakeQuerier{}, "openshift-monitoring").Recommend(context.Background(), nil, nil, nil)
+	if err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rulecheck evaluates the alerting rules shipped by the operator
+// against recorded TSDB data to find out which alerts would have fired.
+package rulecheck
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// DefaultInterval matches the rule evaluation interval of the platform
+	// Prometheus.
+	DefaultInterval = 30 * time.Second
+
+	lookbackDelta = 5 * time.Minute
+	maxSamples    = 50000000
+	queryTimeout  = 10 * time.Minute
+)
+
+// Alert is an alert instance which would have fired.
+type Alert struct {
+	Group  string
+	Name   string
+	Labels labels.Labels
+	// FiredAt is the first evaluation at which the alert was firing.
+	FiredAt time.Time
+	// ResolvedAt is the last evaluation at which the alert was firing.
+	ResolvedAt time.Time
+}
+
+// Rule is an alerting rule loaded from the PrometheusRule assets.
+type Rule struct {
+	Group string
+	monv1.Rule
+}
+
+// LoadRules walks the given assets directory and returns the alerting rules
+// of all PrometheusRule manifests.
+func LoadRules(dir string) ([]Rule, error) {
+	var rules []Rule
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !(strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		pr := monv1.PrometheusRule{}
+		if err := k8syaml.NewYAMLOrJSONDecoder(f, 100).Decode(&pr); err != nil {
+			// Not every asset is a Kubernetes object.
+			return nil
+		}
+		if pr.Kind != monv1.PrometheusRuleKind {
+			return nil
+		}
+
+		for _, g := range pr.Spec.Groups {
+			for _, r := range g.Rules {
+				if r.Alert == "" {
+					continue
+				}
+				rules = append(rules, Rule{Group: g.Name, Rule: r})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading rules from %q failed", dir)
+	}
+
+	return rules, nil
+}
+
+// DB is a read-only view of a TSDB directory. Unlike tsdb.DBReadOnly, the
+// data is loaded only once for all the queries.
+type DB struct {
+	db *tsdb.DBReadOnly
+	q  storage.Querier
+
+	// MinTime and MaxTime are the time range covered by the persisted
+	// blocks. They are zero when the directory only contains a WAL.
+	MinTime, MaxTime time.Time
+}
+
+// OpenDB opens the TSDB directory in read-only mode. The WAL, if present, is
+// loaded too.
+func OpenDB(dir string) (*DB, error) {
+	db, err := tsdb.OpenDBReadOnly(dir, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening TSDB failed")
+	}
+
+	d := &DB{db: db}
+	if err := d.load(dir); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *DB) load(dir string) error {
+	blocks, err := d.db.Blocks()
+	if err != nil {
+		return errors.Wrap(err, "reading TSDB blocks failed")
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "wal"))
+	hasWAL := err == nil
+	if len(blocks) == 0 && !hasWAL {
+		return errors.Errorf("no TSDB blocks or WAL found in %q", dir)
+	}
+
+	maxt := int64(math.MaxInt64)
+	if len(blocks) > 0 {
+		mint, bmaxt := blocks[0].Meta().MinTime, blocks[0].Meta().MaxTime
+		for _, b := range blocks[1:] {
+			if b.Meta().MinTime < mint {
+				mint = b.Meta().MinTime
+			}
+			if b.Meta().MaxTime > bmaxt {
+				bmaxt = b.Meta().MaxTime
+			}
+		}
+		d.MinTime, d.MaxTime = timestamp.Time(mint), timestamp.Time(bmaxt)
+
+		// The WAL is only read when the queried range goes beyond the
+		// persisted blocks.
+		if !hasWAL {
+			maxt = bmaxt - 1
+		}
+	}
+
+	d.q, err = d.db.Querier(context.Background(), math.MinInt64, maxt)
+	if err != nil {
+		return errors.Wrap(err, "loading TSDB data failed")
+	}
+
+	return nil
+}
+
+// Querier implements the storage.Queryable interface.
+func (d *DB) Querier(_ context.Context, _, _ int64) (storage.Querier, error) {
+	return nopCloserQuerier{d.q}, nil
+}
+
+// Close releases the resources held by the database.
+func (d *DB) Close() error {
+	d.q.Close()
+	return d.db.Close()
+}
+
+// nopCloserQuerier prevents the PromQL engine from closing the shared
+// querier after each query.
+type nopCloserQuerier struct {
+	storage.Querier
+}
+
+func (nopCloserQuerier) Close() error { return nil }
+
+// Checker evaluates alerting rules against a storage.
+type Checker struct {
+	engine   *promql.Engine
+	q        storage.Queryable
+	interval time.Duration
+}
+
+// NewChecker returns a Checker evaluating the rules every interval.
+func NewChecker(q storage.Queryable, interval time.Duration) *Checker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &Checker{
+		engine: promql.NewEngine(promql.EngineOpts{
+			MaxSamples:    maxSamples,
+			Timeout:       queryTimeout,
+			LookbackDelta: lookbackDelta,
+		}),
+		q:        q,
+		interval: interval,
+	}
+}
+
+// Check evaluates the rules between start and end and returns the alerts
+// which would have fired, sorted by firing time. The "for" clause of the
+// rules is honored, the pending state lasting until the expression has been
+// true for long enough.
+func (c *Checker) Check(ctx context.Context, rules []Rule, start, end time.Time) ([]Alert, error) {
+	var alerts []Alert
+	for _, r := range rules {
+		a, err := c.check(ctx, r, start, end)
+		if err != nil {
+			return nil, errors.Wrapf(err, "evaluating alert %q (group %q) failed", r.Alert, r.Group)
+		}
+		alerts = append(alerts, a...)
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].FiredAt.Before(alerts[j].FiredAt)
+	})
+
+	return alerts, nil
+}
+
+func (c *Checker) check(ctx context.Context, r Rule, start, end time.Time) ([]Alert, error) {
+	var holdDuration time.Duration
+	if r.For != "" {
+		d, err := model.ParseDuration(r.For)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid for duration %q", r.For)
+		}
+		holdDuration = time.Duration(d)
+	}
+
+	q, err := c.engine.NewRangeQuery(c.q, r.Expr.String(), start, end, c.interval)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	res := q.Exec(ctx)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+
+	matrix, err := res.Matrix()
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []Alert
+	for _, s := range matrix {
+		lbls := alertLabels(r, s.Metric)
+
+		var (
+			activeAt, firedAt, lastAt int64
+			firing                    bool
+		)
+		for i, p := range s.Points {
+			// A gap between 2 points means that the expression stopped
+			// returning this series and the alert got resolved.
+			if i == 0 || p.T-lastAt > c.interval.Milliseconds() {
+				if firing {
+					alerts = append(alerts, newAlert(r, lbls, firedAt, lastAt))
+				}
+				activeAt, firing = p.T, false
+			}
+			lastAt = p.T
+
+			if !firing && p.T-activeAt >= holdDuration.Milliseconds() {
+				firedAt, firing = p.T, true
+			}
+		}
+		if firing {
+			alerts = append(alerts, newAlert(r, lbls, firedAt, lastAt))
+		}
+	}
+
+	return alerts, nil
+}
+
+func alertLabels(r Rule, metric labels.Labels) labels.Labels {
+	lb := labels.NewBuilder(metric).Del(labels.MetricName)
+	for k, v := range r.Labels {
+		lb.Set(k, v)
+	}
+	lb.Set(labels.AlertName, r.Alert)
+	return lb.Labels()
+}
+
+func newAlert(r Rule, lbls labels.Labels, firedAt, resolvedAt int64) Alert {
+	return Alert{
+		Group:      r.Group,
+		Name:       r.Alert,
+		Labels:     lbls,
+		FiredAt:    timestamp.Time(firedAt).UTC(),
+		ResolvedAt: timestamp.Time(resolvedAt).UTC(),
+	}
+}
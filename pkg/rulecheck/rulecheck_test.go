@@ -0,0 +1,172 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulecheck
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const ruleManifest = `apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: test
+spec:
+  groups:
+  - name: test
+    rules:
+    - record: foo:sum
+      expr: sum(foo)
+    - alert: FooHigh
+      expr: foo > 10
+      for: 2m
+      labels:
+        severity: warning
+`
+
+func TestLoadRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rulecheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"rule.yaml":       ruleManifest,
+		"service.yaml":    "apiVersion: v1\nkind: Service\nmetadata:\n  name: foo\n",
+		"dashboard.json":  "{}",
+		"not-object.yaml": "- foo\n- bar\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules, err := LoadRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 alerting rule, got %d", len(rules))
+	}
+	if rules[0].Group != "test" || rules[0].Alert != "FooHigh" || rules[0].For != "2m" {
+		t.Fatalf("unexpected rule %+v", rules[0])
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := teststorage.New(t)
+	defer s.Close()
+
+	// The value of series "a" is high from 5m to 10m (included), the value
+	// of series "b" is high from 5m to 6m (included) and again from 20m to
+	// 30m (included).
+	start := time.Unix(0, 0).UTC()
+	app := s.Appender(context.Background())
+	for i := 0; i <= 30; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		for _, inst := range []string{"a", "b"} {
+			v := 0.0
+			switch {
+			case inst == "a" && i >= 5 && i <= 10:
+				v = 20
+			case inst == "b" && ((i >= 5 && i <= 6) || i >= 20):
+				v = 20
+			}
+			_, err := app.Append(0, labels.FromStrings(labels.MetricName, "foo", "instance", inst), ts.UnixNano()/int64(time.Millisecond), v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := app.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshots only contain persisted blocks, as with the backups.
+	dir, err := ioutil.TempDir("", "rulecheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := s.Snapshot(dir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if !db.MinTime.Equal(start) || db.MaxTime.Before(start.Add(30*time.Minute)) {
+		t.Fatalf("unexpected time range [%s, %s]", db.MinTime, db.MaxTime)
+	}
+
+	rules := []Rule{{
+		Group: "test",
+		Rule: monv1.Rule{
+			Alert:  "FooHigh",
+			Expr:   intstr.FromString("foo > 10"),
+			For:    "2m",
+			Labels: map[string]string{"severity": "warning"},
+		},
+	}}
+
+	alerts, err := NewChecker(db, time.Minute).Check(context.Background(), rules, start, start.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Alert{
+		{
+			Group:      "test",
+			Name:       "FooHigh",
+			Labels:     labels.FromStrings("alertname", "FooHigh", "instance", "a", "severity", "warning"),
+			FiredAt:    start.Add(7 * time.Minute),
+			ResolvedAt: start.Add(10 * time.Minute),
+		},
+		{
+			Group:      "test",
+			Name:       "FooHigh",
+			Labels:     labels.FromStrings("alertname", "FooHigh", "instance", "b", "severity", "warning"),
+			FiredAt:    start.Add(22 * time.Minute),
+			ResolvedAt: start.Add(30 * time.Minute),
+		},
+	}
+
+	if len(alerts) != len(expected) {
+		t.Fatalf("expected %d alerts, got %d: %v", len(expected), len(alerts), alerts)
+	}
+	for i := range expected {
+		if !labels.Equal(alerts[i].Labels, expected[i].Labels) ||
+			alerts[i].Group != expected[i].Group ||
+			alerts[i].Name != expected[i].Name ||
+			!alerts[i].FiredAt.Equal(expected[i].FiredAt) ||
+			!alerts[i].ResolvedAt.Equal(expected[i].ResolvedAt) {
+			t.Fatalf("alert %d: expected %+v, got %+v", i, expected[i], alerts[i])
+		}
+	}
+}
@@ -20,6 +20,11 @@ import (
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
 	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
 	"github.com/pkg/errors"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
 )
 
 type AlertmanagerTask struct {
@@ -49,29 +54,65 @@ func (t *AlertmanagerTask) Run(ctx context.Context) error {
 }
 
 func (t *AlertmanagerTask) create(ctx context.Context) error {
-	r, err := t.factory.AlertmanagerRoute()
+	var host string
+	if !t.config.RouteAPIUnavailable {
+		r, err := t.factory.AlertmanagerRoute()
+		if err != nil {
+			return errors.Wrap(err, "initializing Alertmanager Route failed")
+		}
+
+		err = t.client.CreateRouteIfNotExists(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "creating Alertmanager Route failed")
+		}
+
+		host, err = t.client.WaitForRouteReady(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "waiting for Alertmanager Route to become ready failed")
+		}
+	}
+
+	s, err := t.factory.AlertmanagerConfig()
 	if err != nil {
-		return errors.Wrap(err, "initializing Alertmanager Route failed")
+		return errors.Wrap(err, "initializing Alertmanager configuration Secret failed")
 	}
 
-	err = t.client.CreateRouteIfNotExists(ctx, r)
+	err = t.client.CreateIfNotExistSecret(ctx, s)
 	if err != nil {
-		return errors.Wrap(err, "creating Alertmanager Route failed")
+		return errors.Wrap(err, "creating Alertmanager configuration Secret failed")
 	}
 
-	host, err := t.client.WaitForRouteReady(ctx, r)
+	s, err = t.client.GetSecret(ctx, s.GetNamespace(), s.GetName())
 	if err != nil {
-		return errors.Wrap(err, "waiting for Alertmanager Route to become ready failed")
+		return errors.Wrap(err, "getting Alertmanager configuration Secret failed")
 	}
 
-	s, err := t.factory.AlertmanagerConfig()
+	receiversTLS, err := t.factory.AlertmanagerReceiversTLSSecret(func(namespace, name string) (*v1.Secret, error) {
+		return t.client.GetSecret(ctx, namespace, name)
+	})
 	if err != nil {
-		return errors.Wrap(err, "initializing Alertmanager configuration Secret failed")
+		return errors.Wrap(err, "initializing Alertmanager receivers TLS Secret failed")
 	}
 
-	err = t.client.CreateIfNotExistSecret(ctx, s)
+	// The copied Secret needs to exist before Alertmanager mounts it.
+	if receiversTLS != nil {
+		err = t.client.CreateOrUpdateSecret(ctx, receiversTLS)
+		if err != nil {
+			return errors.Wrap(err, "reconciling Alertmanager receivers TLS Secret failed")
+		}
+	}
+
+	rendered, err := t.factory.AlertmanagerRenderedConfig(s)
 	if err != nil {
-		return errors.Wrap(err, "creating Alertmanager configuration Secret failed")
+		return errors.Wrap(err, "initializing Alertmanager rendered configuration Secret failed")
+	}
+
+	// The rendered Secret needs to exist before Alertmanager references it.
+	if rendered != nil {
+		err = t.client.CreateOrUpdateSecret(ctx, rendered)
+		if err != nil {
+			return errors.Wrap(err, "reconciling Alertmanager rendered configuration Secret failed")
+		}
 	}
 
 	pdb, err := t.factory.AlertmanagerPodDisruptionBudget()
@@ -141,9 +182,9 @@ func (t *AlertmanagerTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "initializing Alertmanager proxy Secret failed")
 	}
 
-	err = t.client.CreateIfNotExistSecret(ctx, ps)
+	err = t.client.CreateOrUpdateGeneratedSecret(ctx, ps, "session_secret")
 	if err != nil {
-		return errors.Wrap(err, "creating Alertmanager proxy Secret failed")
+		return errors.Wrap(err, "reconciling Alertmanager proxy Secret failed")
 	}
 
 	svc, err := t.factory.AlertmanagerService()
@@ -178,6 +219,8 @@ func (t *AlertmanagerTask) create(ctx context.Context) error {
 			return errors.Wrap(err, "initializing Alertmanager object failed")
 		}
 
+		t.checkVolumeBindingMode(ctx, a)
+
 		err = t.client.CreateOrUpdateAlertmanager(ctx, a)
 		if err != nil {
 			return errors.Wrap(err, "reconciling Alertmanager object failed")
@@ -187,6 +230,22 @@ func (t *AlertmanagerTask) create(ctx context.Context) error {
 			return errors.Wrap(err, "waiting for Alertmanager object changes failed")
 		}
 	}
+
+	// Alertmanager doesn't reference the rendered Secret anymore.
+	if rendered == nil {
+		err = t.deleteRenderedConfig(ctx)
+		if err != nil {
+			return errors.Wrap(err, "deleting Alertmanager rendered configuration Secret failed")
+		}
+	}
+
+	if receiversTLS == nil {
+		err = t.deleteReceiversTLS(ctx)
+		if err != nil {
+			return errors.Wrap(err, "deleting Alertmanager receivers TLS Secret failed")
+		}
+	}
+
 	pr, err := t.factory.AlertmanagerPrometheusRule()
 	if err != nil {
 		return errors.Wrap(err, "initializing alertmanager rules PrometheusRule failed")
@@ -234,6 +293,16 @@ func (t *AlertmanagerTask) destroy(ctx context.Context) error {
 		return errors.Wrap(err, "deleting Alertmanager configuration Secret failed")
 	}
 
+	err = t.deleteRenderedConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "deleting Alertmanager rendered configuration Secret failed")
+	}
+
+	err = t.deleteReceiversTLS(ctx)
+	if err != nil {
+		return errors.Wrap(err, "deleting Alertmanager receivers TLS Secret failed")
+	}
+
 	rs, err := t.factory.AlertmanagerRBACProxySecret()
 	if err != nil {
 		return errors.Wrap(err, "initializing Alertmanager RBAC proxy Secret failed")
@@ -356,3 +425,50 @@ func (t *AlertmanagerTask) destroy(ctx context.Context) error {
 	err = t.client.DeleteServiceMonitor(ctx, smam)
 	return errors.Wrap(err, "deleting Alertmanager ServiceMonitor failed")
 }
+
+// checkVolumeBindingMode warns when the storage class of the Alertmanager
+// volumes binds them immediately. In this case, the volumes are provisioned
+// before the pods get scheduled and the zone topology spread constraint
+// can't distribute them across zones.
+func (t *AlertmanagerTask) checkVolumeBindingMode(ctx context.Context, a *monv1.Alertmanager) {
+	if a.Spec.Storage == nil {
+		return
+	}
+
+	var name string
+	if a.Spec.Storage.VolumeClaimTemplate.Spec.StorageClassName != nil {
+		name = *a.Spec.Storage.VolumeClaimTemplate.Spec.StorageClassName
+	}
+
+	sc, err := t.client.GetStorageClass(ctx, name)
+	if err != nil {
+		klog.Warningf("failed to check the volume binding mode of the Alertmanager storage class: %v", err)
+		return
+	}
+
+	if sc == nil {
+		return
+	}
+
+	if sc.VolumeBindingMode == nil || *sc.VolumeBindingMode == storagev1.VolumeBindingImmediate {
+		klog.Warningf("Alertmanager storage class %q uses the %s volume binding mode: volumes may not be distributed across zones, consider using a storage class with the %s mode", sc.Name, storagev1.VolumeBindingImmediate, storagev1.VolumeBindingWaitForFirstConsumer)
+	}
+}
+
+func (t *AlertmanagerTask) deleteRenderedConfig(ctx context.Context) error {
+	return t.client.DeleteSecret(ctx, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      manifests.AlertmanagerRenderedConfigSecret,
+			Namespace: t.client.Namespace(),
+		},
+	})
+}
+
+func (t *AlertmanagerTask) deleteReceiversTLS(ctx context.Context) error {
+	return t.client.DeleteSecret(ctx, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      manifests.AlertmanagerReceiversTLSSecret,
+			Namespace: t.client.Namespace(),
+		},
+	})
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/alertmanager"
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/klog/v2"
+)
+
+// AlertmanagerAnalyzerTask inspects the routing configuration of the
+// platform Alertmanager and reports common mistakes as warning events. It
+// never fails the reconciliation since the configuration is owned by the
+// cluster admins. The events are only emitted when the result of the
+// analysis differs from the previous one.
+type AlertmanagerAnalyzerTask struct {
+	client   *client.Client
+	factory  *manifests.Factory
+	config   *manifests.Config
+	recorder events.Recorder
+
+	previous []string
+	warnings []string
+}
+
+// NewAlertmanagerAnalyzerTask returns the task analyzing the Alertmanager
+// configuration. previous holds the warnings of the last analysis.
+func NewAlertmanagerAnalyzerTask(client *client.Client, factory *manifests.Factory, config *manifests.Config, recorder events.Recorder, previous []string) *AlertmanagerAnalyzerTask {
+	return &AlertmanagerAnalyzerTask{
+		client:   client,
+		factory:  factory,
+		config:   config,
+		recorder: recorder,
+		previous: previous,
+		warnings: previous,
+	}
+}
+
+func (t *AlertmanagerAnalyzerTask) Run(ctx context.Context) error {
+	if !t.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.IsEnabled() {
+		t.warnings = nil
+		return nil
+	}
+
+	s, err := t.factory.AlertmanagerConfig()
+	if err != nil {
+		klog.Warningf("skipping analysis of the Alertmanager configuration: initializing Alertmanager configuration Secret failed: %v", err)
+		return nil
+	}
+
+	s, err = t.client.GetSecret(ctx, s.GetNamespace(), s.GetName())
+	if err != nil {
+		klog.Warningf("skipping analysis of the Alertmanager configuration: getting Alertmanager configuration Secret failed: %v", err)
+		return nil
+	}
+
+	warnings, err := alertmanager.Analyze(s.Data["alertmanager.yaml"])
+	if err != nil {
+		// Alertmanager reports invalid configurations by itself.
+		klog.Warningf("skipping analysis of the Alertmanager configuration: %v", err)
+		return nil
+	}
+
+	t.warnings = warnings
+	if equalStrings(warnings, t.previous) {
+		return nil
+	}
+
+	for _, w := range warnings {
+		klog.Warningf("Alertmanager configuration %s/%s: %s", s.GetNamespace(), s.GetName(), w)
+		t.recorder.Warningf("AlertmanagerConfigWarning", "Alertmanager configuration %s/%s: %s", s.GetNamespace(), s.GetName(), w)
+	}
+
+	return nil
+}
+
+// Warnings returns the warnings of the last analysis. They are kept from the
+// previous run when the configuration couldn't be analyzed.
+func (t *AlertmanagerAnalyzerTask) Warnings() []string {
+	return t.warnings
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
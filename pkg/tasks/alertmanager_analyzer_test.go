@@ -0,0 +1,111 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/library-go/pkg/operator/events"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAlertmanagerAnalyzerTask(t *testing.T) {
+	ctx := context.Background()
+	c := manifests.NewDefaultConfig()
+	f := manifests.NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, hostedInfrastructureReader{}, noProxyReader{}, manifests.NewAssets("../../assets"), &manifests.APIServerConfig{})
+
+	secret := func(config string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "alertmanager-main", Namespace: "openshift-monitoring"},
+			Data:       map[string][]byte{"alertmanager.yaml": []byte(config)},
+		}
+	}
+	unusedReceiver := `
+route:
+  receiver: default
+receivers:
+- name: default
+- name: unused
+`
+
+	for _, tc := range []struct {
+		name     string
+		secret   *v1.Secret
+		previous []string
+
+		events   int
+		warnings []string
+	}{
+		{
+			name:     "new warnings",
+			secret:   secret(unusedReceiver),
+			events:   1,
+			warnings: []string{`receiver "unused" isn't referenced by any route`},
+		},
+		{
+			name:     "unchanged warnings",
+			secret:   secret(unusedReceiver),
+			previous: []string{`receiver "unused" isn't referenced by any route`},
+			warnings: []string{`receiver "unused" isn't referenced by any route`},
+		},
+		{
+			name:     "warnings fixed",
+			secret:   secret("route:\n  receiver: default\nreceivers:\n- name: default\n"),
+			previous: []string{`receiver "unused" isn't referenced by any route`},
+		},
+		{
+			name:     "missing secret",
+			previous: []string{`receiver "unused" isn't referenced by any route`},
+			warnings: []string{`receiver "unused" isn't referenced by any route`},
+		},
+		{
+			name:     "invalid configuration",
+			secret:   secret("route: ["),
+			previous: []string{`receiver "unused" isn't referenced by any route`},
+			warnings: []string{`receiver "unused" isn't referenced by any route`},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kclient := fake.NewSimpleClientset()
+			if tc.secret != nil {
+				kclient = fake.NewSimpleClientset(tc.secret)
+			}
+			recorder := events.NewInMemoryRecorder("test")
+			task := NewAlertmanagerAnalyzerTask(
+				client.New("", "openshift-monitoring", "openshift-user-workload-monitoring", client.KubernetesClient(kclient)),
+				f,
+				c,
+				recorder,
+				tc.previous,
+			)
+
+			if err := task.Run(ctx); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if n := len(recorder.Events()); n != tc.events {
+				t.Fatalf("expected %d events, got %d", tc.events, n)
+			}
+			if !equalStrings(task.Warnings(), tc.warnings) {
+				t.Fatalf("expected warnings %q, got %q", tc.warnings, task.Warnings())
+			}
+		})
+	}
+}
@@ -75,9 +75,11 @@ func (t *ClusterMonitoringOperatorTask) Run(ctx context.Context) error {
 		return errors.Wrap(err, "initializing UserWorkloadConfigEdit Role failed")
 	}
 
-	err = t.client.CreateOrUpdateRole(ctx, uwcr)
-	if err != nil {
-		return errors.Wrap(err, "reconciling UserWorkloadConfigEdit Role failed")
+	if !t.config.UserWorkloadNamespaceTerminating {
+		err = t.client.CreateOrUpdateRole(ctx, uwcr)
+		if err != nil {
+			return errors.Wrap(err, "reconciling UserWorkloadConfigEdit Role failed")
+		}
 	}
 
 	amwr, err := t.factory.ClusterMonitoringAlertManagerEditRole()
@@ -104,6 +106,22 @@ func (t *ClusterMonitoringOperatorTask) Run(ctx context.Context) error {
 		return errors.Wrap(err, "reconciling cluster-monitoring-operator rules PrometheusRule failed")
 	}
 
+	mcr, err := t.factory.MetricCompatibilityPrometheusRule()
+	if err != nil {
+		return errors.Wrap(err, "initializing metric compatibility rules PrometheusRule failed")
+	}
+	if len(mcr.Spec.Groups) > 0 {
+		err = t.client.CreateOrUpdatePrometheusRule(ctx, mcr)
+		if err != nil {
+			return errors.Wrap(err, "reconciling metric compatibility rules PrometheusRule failed")
+		}
+	} else {
+		err = t.client.DeletePrometheusRule(ctx, mcr)
+		if err != nil {
+			return errors.Wrap(err, "deleting metric compatibility rules PrometheusRule failed")
+		}
+	}
+
 	smcmo, err := t.factory.ClusterMonitoringOperatorServiceMonitor()
 	if err != nil {
 		return errors.Wrap(err, "initializing Cluster Monitoring Operator ServiceMonitor failed")
@@ -0,0 +1,88 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"strings"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/configreload"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// ConfigReloadTask verifies that the components picked up the configuration
+// generated by the Prometheus operator. Silent reload failures degrade the
+// operator while failures to query the components are only logged.
+type ConfigReloadTask struct {
+	client  *client.Client
+	config  *manifests.Config
+	checker *configreload.Checker
+}
+
+func NewConfigReloadTask(client *client.Client, config *manifests.Config, checker *configreload.Checker) *ConfigReloadTask {
+	return &ConfigReloadTask{
+		client:  client,
+		config:  config,
+		checker: checker,
+	}
+}
+
+func (t *ConfigReloadTask) Run(ctx context.Context) error {
+	if t.checker == nil {
+		return nil
+	}
+
+	var stale []string
+	check := func(err error) {
+		if err == nil {
+			return
+		}
+
+		if errors.Is(err, configreload.ErrStaleConfig) {
+			stale = append(stale, err.Error())
+			return
+		}
+
+		klog.Warningf("skipping the configuration reload check: %v", err)
+	}
+
+	check(t.checker.CheckPrometheus(ctx, t.client.Namespace(), "prometheus-k8s"))
+	if *t.config.ClusterMonitoringConfiguration.UserWorkloadEnabled {
+		check(t.checker.CheckPrometheus(ctx, t.client.UserWorkloadNamespace(), "prometheus-user-workload"))
+	}
+
+	if t.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.IsEnabled() {
+		// The Prometheus operator writes the configuration loaded by
+		// Alertmanager in the generated secret.
+		s, err := t.client.GetSecret(ctx, t.client.Namespace(), "alertmanager-main-generated")
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			check(errors.Wrap(err, "getting the generated Alertmanager configuration failed"))
+		default:
+			check(t.checker.CheckAlertmanager(ctx, t.client.Namespace(), "alertmanager-main", s.Data["alertmanager.yaml"]))
+		}
+	}
+
+	if len(stale) > 0 {
+		return errors.Errorf("configuration reload failed: %s", strings.Join(stale, "; "))
+	}
+
+	return nil
+}
@@ -38,55 +38,57 @@ func NewConfigSharingTask(client *client.Client, factory *manifests.Factory, con
 }
 
 func (t *ConfigSharingTask) Run(ctx context.Context) error {
-	promRoute, err := t.factory.PrometheusK8sRoute()
-	if err != nil {
-		return errors.Wrap(err, "initializing Prometheus Route failed")
-	}
-
-	promURL, err := t.client.GetRouteURL(ctx, promRoute)
-	if err != nil {
-		return errors.Wrap(err, "failed to retrieve Prometheus host")
-	}
+	var promURL, amURL, grafanaURL, thanosURL *url.URL
 
-	var amURL *url.URL
-	if t.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.IsEnabled() {
-		amRoute, err := t.factory.AlertmanagerRoute()
+	// The public URLs are only known when the Route API is available.
+	if !t.config.RouteAPIUnavailable {
+		promRoute, err := t.factory.PrometheusK8sRoute()
 		if err != nil {
-			return errors.Wrap(err, "initializing Alertmanager Route failed")
+			return errors.Wrap(err, "initializing Prometheus Route failed")
 		}
 
-		amURL, err = t.client.GetRouteURL(ctx, amRoute)
+		promURL, err = t.client.GetRouteURL(ctx, promRoute)
 		if err != nil {
-			return errors.Wrap(err, "failed to retrieve Alertmanager host")
+			return errors.Wrap(err, "failed to retrieve Prometheus host")
 		}
-	}
 
-	var grafanaURL *url.URL
+		if t.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.IsEnabled() {
+			amRoute, err := t.factory.AlertmanagerRoute()
+			if err != nil {
+				return errors.Wrap(err, "initializing Alertmanager Route failed")
+			}
 
-	if t.config.ClusterMonitoringConfiguration.GrafanaConfig.IsEnabled() {
-		grafanaRoute, err := t.factory.GrafanaRoute()
-		if err != nil {
-			return errors.Wrap(err, "initializing Grafana Route failed")
+			amURL, err = t.client.GetRouteURL(ctx, amRoute)
+			if err != nil {
+				return errors.Wrap(err, "failed to retrieve Alertmanager host")
+			}
 		}
 
-		grafanaURL, err = t.client.GetRouteURL(ctx, grafanaRoute)
-		if err != nil {
-			return errors.Wrap(err, "failed to retrieve Grafana host")
+		if t.config.ClusterMonitoringConfiguration.GrafanaConfig.IsEnabled() {
+			grafanaRoute, err := t.factory.GrafanaRoute()
+			if err != nil {
+				return errors.Wrap(err, "initializing Grafana Route failed")
+			}
+
+			grafanaURL, err = t.client.GetRouteURL(ctx, grafanaRoute)
+			if err != nil {
+				return errors.Wrap(err, "failed to retrieve Grafana host")
+			}
 		}
-	}
 
-	thanosRoute, err := t.factory.ThanosQuerierRoute()
-	if err != nil {
-		return errors.Wrap(err, "initializing Thanos Querier Route failed")
-	}
+		thanosRoute, err := t.factory.ThanosQuerierRoute()
+		if err != nil {
+			return errors.Wrap(err, "initializing Thanos Querier Route failed")
+		}
 
-	thanosURL, err := t.client.GetRouteURL(ctx, thanosRoute)
-	if err != nil {
-		return errors.Wrap(err, "failed to retrieve Thanos Querier host")
+		thanosURL, err = t.client.GetRouteURL(ctx, thanosRoute)
+		if err != nil {
+			return errors.Wrap(err, "failed to retrieve Thanos Querier host")
+		}
 	}
 
 	cm := t.factory.SharingConfig(promURL, amURL, grafanaURL, thanosURL)
-	err = t.client.CreateOrUpdateConfigMap(ctx, cm)
+	err := t.client.CreateOrUpdateConfigMap(ctx, cm)
 	if err != nil {
 		return errors.Wrapf(err, "reconciling %s/%s Config ConfigMap failed", cm.Namespace, cm.Name)
 	}
@@ -0,0 +1,106 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ConsoleDashboardsTask deploys the dashboards of the web console: the
+// shipped ones which aren't excluded and the copies of the dashboards found
+// in the additional dashboards namespace.
+type ConsoleDashboardsTask struct {
+	client  *client.Client
+	factory *manifests.Factory
+}
+
+func NewConsoleDashboardsTask(client *client.Client, factory *manifests.Factory) *ConsoleDashboardsTask {
+	return &ConsoleDashboardsTask{
+		client:  client,
+		factory: factory,
+	}
+}
+
+func (t *ConsoleDashboardsTask) Run(ctx context.Context) error {
+	kept, excluded, err := t.factory.ConsoleDashboards()
+	if err != nil {
+		return errors.Wrap(err, "initializing console dashboards ConfigMaps failed")
+	}
+
+	err = t.client.CreateOrUpdateConfigMapList(ctx, kept)
+	if err != nil {
+		return errors.Wrap(err, "reconciling console dashboards ConfigMaps failed")
+	}
+
+	err = t.client.DeleteConfigMapList(ctx, excluded)
+	if err != nil {
+		return errors.Wrap(err, "deleting excluded console dashboards ConfigMaps failed")
+	}
+
+	return t.reconcileAdditionalDashboards(ctx)
+}
+
+// reconcileAdditionalDashboards copies the dashboards of the additional
+// namespace and removes the copies whose source is gone, including all of
+// them when the namespace isn't configured anymore.
+func (t *ConsoleDashboardsTask) reconcileAdditionalDashboards(ctx context.Context) error {
+	ns, err := t.factory.AdditionalDashboardsNamespace()
+	if err != nil {
+		return err
+	}
+
+	var sources []v1.ConfigMap
+	if ns != "" {
+		sources, err = t.client.ListConfigMaps(ctx, ns, manifests.ConsoleDashboardsSelector)
+		if err != nil {
+			return errors.Wrap(err, "listing additional dashboards ConfigMaps failed")
+		}
+	}
+
+	copies := t.factory.ConsoleAdditionalDashboards(sources)
+	err = t.client.CreateOrUpdateConfigMapList(ctx, copies)
+	if err != nil {
+		return errors.Wrap(err, "reconciling additional dashboards ConfigMaps failed")
+	}
+
+	existing, err := t.client.ListConfigMaps(ctx, manifests.ConsoleDashboardsNamespace, manifests.AdditionalDashboardsSelector)
+	if err != nil {
+		return errors.Wrap(err, "listing the copies of the additional dashboards failed")
+	}
+
+	wanted := make(map[string]struct{}, len(copies.Items))
+	for _, c := range copies.Items {
+		wanted[c.GetName()] = struct{}{}
+	}
+
+	stale := &v1.ConfigMapList{}
+	for _, c := range existing {
+		if _, found := wanted[c.GetName()]; !found {
+			stale.Items = append(stale.Items, c)
+		}
+	}
+
+	err = t.client.DeleteConfigMapList(ctx, stale)
+	if err != nil {
+		return errors.Wrap(err, "deleting stale additional dashboards ConfigMaps failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/consolenotifications"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+)
+
+// ConsoleNotificationsTask passes the configuration to the controller which
+// converts firing alerts into console banners. The controller reconciles the
+// ConsoleNotification objects on its own since alerts change independently
+// of the cluster monitoring configuration.
+type ConsoleNotificationsTask struct {
+	controller *consolenotifications.Controller
+	config     *manifests.Config
+}
+
+func NewConsoleNotificationsTask(controller *consolenotifications.Controller, config *manifests.Config) *ConsoleNotificationsTask {
+	return &ConsoleNotificationsTask{
+		controller: controller,
+		config:     config,
+	}
+}
+
+func (t *ConsoleNotificationsTask) Run(ctx context.Context) error {
+	if t.controller == nil {
+		return nil
+	}
+
+	cfg := t.config.ClusterMonitoringConfiguration.ConsoleNotifications
+	selector, err := cfg.Selector()
+	if err != nil {
+		return err
+	}
+
+	t.controller.SetConfig(cfg.Enabled, selector)
+	return nil
+}
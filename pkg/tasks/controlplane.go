@@ -16,9 +16,11 @@ package tasks
 
 import (
 	"context"
+
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
 	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 type ControlPlaneTask struct {
@@ -91,5 +93,102 @@ func (t *ControlPlaneTask) Run(ctx context.Context) error {
 		}
 	}
 
+	return t.reconcileHostedControlPlane(ctx)
+}
+
+// reconcileHostedControlPlane deploys the service monitors for the control
+// plane running as pods in a management namespace.
+func (t *ControlPlaneTask) reconcileHostedControlPlane(ctx context.Context) error {
+	ns, err := t.factory.HostedControlPlaneNamespace()
+	if err != nil {
+		return err
+	}
+
+	smEtcd, err := t.factory.ControlPlaneEtcdHostedServiceMonitor()
+	if err != nil {
+		return errors.Wrap(err, "initializing hosted control-plane etcd ServiceMonitor failed")
+	}
+
+	smAPIServer, err := t.factory.ControlPlaneKubeAPIServerHostedServiceMonitor()
+	if err != nil {
+		return errors.Wrap(err, "initializing hosted control-plane kube-apiserver ServiceMonitor failed")
+	}
+
+	// The previous namespace is recorded by the metrics client Secret, its
+	// Role and RoleBinding are removed when the namespace changes.
+	previous, err := t.client.GetSecret(ctx, t.client.Namespace(), t.factory.ControlPlaneHostedMetricsClientSecretMeta().Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to load hosted control-plane metrics client certs secret")
+	}
+	if err == nil {
+		if prev := previous.Annotations[manifests.HostedControlPlaneNamespaceAnnotation]; prev != "" && prev != ns {
+			if err := t.deleteHostedControlPlaneRBAC(ctx, prev); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ns == "" {
+		err = t.client.DeleteServiceMonitor(ctx, smEtcd)
+		if err != nil {
+			return errors.Wrap(err, "deleting hosted control-plane etcd ServiceMonitor failed")
+		}
+
+		err = t.client.DeleteServiceMonitor(ctx, smAPIServer)
+		if err != nil {
+			return errors.Wrap(err, "deleting hosted control-plane kube-apiserver ServiceMonitor failed")
+		}
+
+		err = t.client.DeleteSecret(ctx, t.factory.ControlPlaneHostedMetricsClientSecretMeta())
+		if err != nil {
+			return errors.Wrap(err, "deleting hosted control-plane metrics client certs secret failed")
+		}
+
+		return nil
+	}
+
+	clientSecret, err := t.client.GetSecret(ctx, ns, "metrics-client")
+	if err != nil {
+		return errors.Wrap(err, "failed to load hosted control-plane metrics client secret")
+	}
+
+	s, err := t.factory.ControlPlaneHostedMetricsClientSecret(clientSecret)
+	if err != nil {
+		return errors.Wrap(err, "initializing hosted control-plane metrics client secret failed")
+	}
+
+	err = t.client.CreateOrUpdateSecret(ctx, s)
+	if err != nil {
+		return errors.Wrap(err, "reconciling hosted control-plane metrics client secret failed")
+	}
+
+	err = t.client.CreateOrUpdateServiceMonitor(ctx, smEtcd)
+	if err != nil {
+		return errors.Wrap(err, "reconciling hosted control-plane etcd ServiceMonitor failed")
+	}
+
+	err = t.client.CreateOrUpdateServiceMonitor(ctx, smAPIServer)
+	if err != nil {
+		return errors.Wrap(err, "reconciling hosted control-plane kube-apiserver ServiceMonitor failed")
+	}
+
+	return nil
+}
+
+// deleteHostedControlPlaneRBAC removes the Role and RoleBinding of Prometheus
+// from a namespace which doesn't host the control plane anymore.
+func (t *ControlPlaneTask) deleteHostedControlPlaneRBAC(ctx context.Context, namespace string) error {
+	r, rb, err := t.factory.PrometheusK8sHostedControlPlaneRBAC(namespace)
+	if err != nil {
+		return errors.Wrap(err, "initializing hosted control-plane Prometheus RBAC failed")
+	}
+
+	if err := t.client.DeleteRoleBinding(ctx, rb); err != nil {
+		return errors.Wrapf(err, "deleting Prometheus RoleBinding in previous hosted control-plane namespace %s failed", namespace)
+	}
+	if err := t.client.DeleteRole(ctx, r); err != nil {
+		return errors.Wrapf(err, "deleting Prometheus Role in previous hosted control-plane namespace %s failed", namespace)
+	}
+
 	return nil
 }
@@ -0,0 +1,162 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	monfake "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned/fake"
+	monscheme "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned/scheme"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+type hostedInfrastructureReader struct{}
+
+func (hostedInfrastructureReader) HighlyAvailableInfrastructure() bool { return true }
+func (hostedInfrastructureReader) HostedControlPlane() bool            { return true }
+func (hostedInfrastructureReader) PlatformType() string                { return "" }
+func (hostedInfrastructureReader) Region() string                      { return "" }
+func (hostedInfrastructureReader) BaseDomain() string                  { return "" }
+
+type noProxyReader struct{}
+
+func (noProxyReader) HTTPProxy() string  { return "" }
+func (noProxyReader) HTTPSProxy() string { return "" }
+func (noProxyReader) NoProxy() string    { return "" }
+
+// replaceOnApply emulates server-side apply for the fake clientsets which
+// don't support it: the applied object replaces the existing one.
+func replaceOnApply(f *clienttesting.Fake, tracker clienttesting.ObjectTracker, scheme *runtime.Scheme) {
+	f.PrependReactor("patch", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		pa := action.(clienttesting.PatchAction)
+		if pa.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+
+		var tm metav1.TypeMeta
+		if err := json.Unmarshal(pa.GetPatch(), &tm); err != nil {
+			return true, nil, err
+		}
+		obj, err := scheme.New(tm.GroupVersionKind())
+		if err != nil {
+			return true, nil, err
+		}
+		if err := json.Unmarshal(pa.GetPatch(), obj); err != nil {
+			return true, nil, err
+		}
+
+		err = tracker.Update(pa.GetResource(), obj, pa.GetNamespace())
+		if apierrors.IsNotFound(err) {
+			err = tracker.Create(pa.GetResource(), obj, pa.GetNamespace())
+		}
+		return true, obj, err
+	})
+}
+
+func TestHostedControlPlaneNamespaceChange(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name      string
+		namespace string
+	}{
+		{
+			name: "namespace unset",
+		},
+		{
+			name:      "namespace changed",
+			namespace: "new-control-plane",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := manifests.NewConfigFromString("hostedControlPlane:\n  namespace: \"" + tc.namespace + "\"\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := manifests.NewFactory("openshift-monitoring", "openshift-user-workload-monitoring", c, hostedInfrastructureReader{}, noProxyReader{}, manifests.NewAssets("../../assets"), &manifests.APIServerConfig{})
+
+			role, binding, err := f.PrometheusK8sHostedControlPlaneRBAC("old-control-plane")
+			if err != nil {
+				t.Fatal(err)
+			}
+			objects := []runtime.Object{
+				role,
+				binding,
+				&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        f.ControlPlaneHostedMetricsClientSecretMeta().Name,
+						Namespace:   "openshift-monitoring",
+						Annotations: map[string]string{manifests.HostedControlPlaneNamespaceAnnotation: "old-control-plane"},
+					},
+				},
+			}
+			if tc.namespace != "" {
+				objects = append(objects, &v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "metrics-client", Namespace: tc.namespace},
+					Data: map[string][]byte{
+						"ca.crt":  []byte("ca"),
+						"tls.crt": []byte("cert"),
+						"tls.key": []byte("key"),
+					},
+				})
+			}
+			kclient := fake.NewSimpleClientset(objects...)
+			replaceOnApply(&kclient.Fake, kclient.Tracker(), kscheme.Scheme)
+			mclient := monfake.NewSimpleClientset()
+			replaceOnApply(&mclient.Fake, mclient.Tracker(), monscheme.Scheme)
+			task := NewControlPlaneTask(
+				client.New("", "openshift-monitoring", "openshift-user-workload-monitoring", client.KubernetesClient(kclient), client.MonitoringClient(mclient)),
+				f,
+				c,
+			)
+
+			if err := task.reconcileHostedControlPlane(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := kclient.RbacV1().Roles("old-control-plane").Get(ctx, role.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+				t.Fatalf("expected the Role of the previous namespace to be deleted, got %v", err)
+			}
+			if _, err := kclient.RbacV1().RoleBindings("old-control-plane").Get(ctx, binding.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+				t.Fatalf("expected the RoleBinding of the previous namespace to be deleted, got %v", err)
+			}
+
+			s, err := kclient.CoreV1().Secrets("openshift-monitoring").Get(ctx, f.ControlPlaneHostedMetricsClientSecretMeta().Name, metav1.GetOptions{})
+			if tc.namespace == "" {
+				if !apierrors.IsNotFound(err) {
+					t.Fatalf("expected the metrics client certs secret to be deleted, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Annotations[manifests.HostedControlPlaneNamespaceAnnotation]; got != tc.namespace {
+				t.Fatalf("expected the secret to record namespace %q, got %q", tc.namespace, got)
+			}
+		})
+	}
+}
@@ -16,10 +16,13 @@ package tasks
 
 import (
 	"context"
+	"reflect"
 
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
 	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 type GrafanaTask struct {
@@ -65,19 +68,21 @@ func (t *GrafanaTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "reconciling Grafana ClusterRoleBinding failed")
 	}
 
-	r, err := t.factory.GrafanaRoute()
-	if err != nil {
-		return errors.Wrap(err, "initializing Grafana Route failed")
-	}
+	if !t.config.RouteAPIUnavailable {
+		r, err := t.factory.GrafanaRoute()
+		if err != nil {
+			return errors.Wrap(err, "initializing Grafana Route failed")
+		}
 
-	err = t.client.CreateRouteIfNotExists(ctx, r)
-	if err != nil {
-		return errors.Wrap(err, "creating Grafana Route failed")
-	}
+		err = t.client.CreateRouteIfNotExists(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "creating Grafana Route failed")
+		}
 
-	_, err = t.client.WaitForRouteReady(ctx, r)
-	if err != nil {
-		return errors.Wrap(err, "waiting for Grafana Route to become ready failed")
+		_, err = t.client.WaitForRouteReady(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "waiting for Grafana Route to become ready failed")
+		}
 	}
 
 	rs, err := t.factory.GrafanaRBACProxyMetricSecret()
@@ -95,9 +100,9 @@ func (t *GrafanaTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "initializing Grafana proxy Secret failed")
 	}
 
-	err = t.client.CreateIfNotExistSecret(ctx, ps)
+	err = t.client.CreateOrUpdateGeneratedSecret(ctx, ps, "session_secret")
 	if err != nil {
-		return errors.Wrap(err, "creating Grafana proxy Secret failed")
+		return errors.Wrap(err, "reconciling Grafana proxy Secret failed")
 	}
 
 	smc, err := t.factory.GrafanaConfig()
@@ -120,6 +125,11 @@ func (t *GrafanaTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "reconciling Grafana Datasources Secret failed")
 	}
 
+	sds, err = t.reconcileAdditionalDatasources(ctx, sds)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Grafana additional datasources failed")
+	}
+
 	cmdds, err := t.factory.GrafanaDashboardDefinitions()
 	if err != nil {
 		return errors.Wrap(err, "initializing Grafana Dashboard Definitions ConfigMaps failed")
@@ -140,6 +150,21 @@ func (t *GrafanaTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "reconciling Grafana Dashboard Sources ConfigMap failed")
 	}
 
+	consoleDashboards, err := t.client.ListConfigMaps(ctx, manifests.ConsoleDashboardsNamespace, manifests.ConsoleDashboardsSelector)
+	if err != nil {
+		return errors.Wrap(err, "listing console dashboards ConfigMaps failed")
+	}
+
+	cmcds, err := t.factory.GrafanaCustomDashboards(consoleDashboards)
+	if err != nil {
+		return errors.Wrap(err, "initializing Grafana Custom Dashboards ConfigMap failed")
+	}
+
+	err = t.client.CreateOrUpdateConfigMap(ctx, cmcds)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Grafana Custom Dashboards ConfigMap failed")
+	}
+
 	sa, err := t.factory.GrafanaServiceAccount()
 	if err != nil {
 		return errors.Wrap(err, "initializing Grafana ServiceAccount failed")
@@ -176,7 +201,7 @@ func (t *GrafanaTask) create(ctx context.Context) error {
 			return errors.Wrap(err, "syncing Grafana CA bundle ConfigMap failed")
 		}
 
-		d, err := t.factory.GrafanaDeployment(trustedCA)
+		d, err := t.factory.GrafanaDeployment(trustedCA, sds)
 		if err != nil {
 			return errors.Wrap(err, "initializing Grafana Deployment failed")
 		}
@@ -196,6 +221,46 @@ func (t *GrafanaTask) create(ctx context.Context) error {
 	return errors.Wrap(err, "reconciling Grafana ServiceMonitor failed")
 }
 
+// reconcileAdditionalDatasources merges the datasources referenced by the
+// configuration into the existing datasources secret and returns it.
+func (t *GrafanaTask) reconcileAdditionalDatasources(ctx context.Context, sds *v1.Secret) (*v1.Secret, error) {
+	existing, err := t.client.GetSecret(ctx, sds.GetNamespace(), sds.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	var sources [][]byte
+	for _, ref := range t.config.ClusterMonitoringConfiguration.GrafanaConfig.AdditionalDatasources {
+		s, err := t.client.GetSecret(ctx, sds.GetNamespace(), ref.Name)
+		if apierrors.IsNotFound(err) && ref.Optional != nil && *ref.Optional {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get secret %q", ref.Name)
+		}
+
+		b, found := s.Data[ref.Key]
+		if !found {
+			if ref.Optional != nil && *ref.Optional {
+				continue
+			}
+			return nil, errors.Errorf("key %q not found in secret %q", ref.Key, ref.Name)
+		}
+		sources = append(sources, b)
+	}
+
+	merged, err := t.factory.GrafanaDatasourcesWithAdditional(existing, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	if reflect.DeepEqual(existing.Data, merged.Data) {
+		return existing, nil
+	}
+
+	return merged, t.client.CreateOrUpdateSecret(ctx, merged)
+}
+
 func (t *GrafanaTask) destroy(ctx context.Context) error {
 	sm, err := t.factory.GrafanaServiceMonitor()
 	if err != nil {
@@ -213,7 +278,7 @@ func (t *GrafanaTask) destroy(ctx context.Context) error {
 			return errors.Wrap(err, "initializing Grafana CA bundle ConfigMap failed")
 		}
 
-		d, err := t.factory.GrafanaDeployment(trustedCA)
+		d, err := t.factory.GrafanaDeployment(trustedCA, nil)
 		if err != nil {
 			return errors.Wrap(err, "initializing Grafana Deployment failed")
 		}
@@ -264,6 +329,16 @@ func (t *GrafanaTask) destroy(ctx context.Context) error {
 		return errors.Wrap(err, "deleting Grafana Dashboard Sources ConfigMap failed")
 	}
 
+	cmcds, err := t.factory.GrafanaCustomDashboards(nil)
+	if err != nil {
+		return errors.Wrap(err, "initializing Grafana Custom Dashboards ConfigMap failed")
+	}
+
+	err = t.client.DeleteConfigMap(ctx, cmcds)
+	if err != nil {
+		return errors.Wrap(err, "deleting Grafana Custom Dashboards ConfigMap failed")
+	}
+
 	cmdds, err := t.factory.GrafanaDashboardDefinitions()
 	if err != nil {
 		return errors.Wrap(err, "initializing Grafana Dashboard Definitions ConfigMaps failed")
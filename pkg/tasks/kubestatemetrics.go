@@ -20,17 +20,23 @@ import (
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
 	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+const kubeStateMetricsDeploymentSelector = "app.kubernetes.io/name=kube-state-metrics,app.kubernetes.io/managed-by=cluster-monitoring-operator"
+
 type KubeStateMetricsTask struct {
 	client  *client.Client
 	factory *manifests.Factory
+	config  *manifests.Config
 }
 
-func NewKubeStateMetricsTask(client *client.Client, factory *manifests.Factory) *KubeStateMetricsTask {
+func NewKubeStateMetricsTask(client *client.Client, factory *manifests.Factory, config *manifests.Config) *KubeStateMetricsTask {
 	return &KubeStateMetricsTask{
 		client:  client,
 		factory: factory,
+		config:  config,
 	}
 }
 
@@ -85,14 +91,27 @@ func (t *KubeStateMetricsTask) Run(ctx context.Context) error {
 		return errors.Wrap(err, "reconciling kube-state-metrics Service failed")
 	}
 
-	dep, err := t.factory.KubeStateMetricsDeployment()
+	crs, err := t.customResourceStateConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "retrieving kube-state-metrics custom resource state ConfigMap failed")
+	}
+
+	deps, err := t.factory.KubeStateMetricsDeployments(crs)
 	if err != nil {
-		return errors.Wrap(err, "initializing kube-state-metrics Deployment failed")
+		return errors.Wrap(err, "initializing kube-state-metrics Deployments failed")
 	}
 
-	err = t.client.CreateOrUpdateDeployment(ctx, dep)
+	for _, dep := range deps {
+		err = t.client.CreateOrUpdateDeployment(ctx, dep)
+		if err != nil {
+			return errors.Wrapf(err, "reconciling kube-state-metrics Deployment %s failed", dep.GetName())
+		}
+	}
+
+	// Remove the Deployments left over by a previous number of shards.
+	err = t.client.DeleteStaleDeployments(ctx, t.client.Namespace(), kubeStateMetricsDeploymentSelector, deps)
 	if err != nil {
-		return errors.Wrap(err, "reconciling kube-state-metrics Deployment failed")
+		return errors.Wrap(err, "deleting stale kube-state-metrics Deployments failed")
 	}
 
 	pr, err := t.factory.KubeStateMetricsPrometheusRule()
@@ -116,3 +135,27 @@ func (t *KubeStateMetricsTask) Run(ctx context.Context) error {
 
 	return nil
 }
+
+// customResourceStateConfig returns the ConfigMap holding the custom resource
+// state configuration or nil if there is none.
+func (t *KubeStateMetricsTask) customResourceStateConfig(ctx context.Context) (*v1.ConfigMap, error) {
+	ref := t.config.ClusterMonitoringConfiguration.KubeStateMetricsConfig.CustomResourceStateConfig
+	if ref == nil {
+		return nil, nil
+	}
+
+	optional := ref.Optional != nil && *ref.Optional
+	cm, err := t.client.GetConfigmap(ctx, t.client.Namespace(), ref.Name)
+	if apierrors.IsNotFound(err) && optional {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, found := cm.Data[ref.Key]; !found && !optional {
+		return nil, errors.Errorf("key %q not found in ConfigMap %q", ref.Key, ref.Name)
+	}
+
+	return cm, nil
+}
@@ -46,6 +46,10 @@ func (t *MetricsClientCATask) Run(ctx context.Context) error {
 }
 
 func (t *MetricsClientCATask) reconcileUWMConfigMap(ctx context.Context, apiAuthConfigmap *v1.ConfigMap) error {
+	if t.config.UserWorkloadNamespaceTerminating {
+		return nil
+	}
+
 	cm, err := t.factory.UserWorkloadMetricsClientCACM(apiAuthConfigmap)
 	if err != nil {
 		return err
@@ -0,0 +1,149 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// MetricsServerTask deploys metrics-server when enabled. The resource metrics
+// API is moved to metrics-server only once its deployment is rolled out so
+// that the API remains available while migrating from prometheus-adapter.
+type MetricsServerTask struct {
+	client  *client.Client
+	factory *manifests.Factory
+	config  *manifests.Config
+}
+
+func NewMetricsServerTask(client *client.Client, factory *manifests.Factory, config *manifests.Config) *MetricsServerTask {
+	return &MetricsServerTask{
+		client:  client,
+		factory: factory,
+		config:  config,
+	}
+}
+
+func (t *MetricsServerTask) Run(ctx context.Context) error {
+	// The resources are removed by the resource metrics task once the API
+	// is served by prometheus-adapter again.
+	if !t.config.ClusterMonitoringConfiguration.MetricsServerConfig.IsEnabled() {
+		return nil
+	}
+
+	sa, err := t.factory.MetricsServerServiceAccount()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server ServiceAccount failed")
+	}
+
+	err = t.client.CreateOrUpdateServiceAccount(ctx, sa)
+	if err != nil {
+		return errors.Wrap(err, "reconciling metrics-server ServiceAccount failed")
+	}
+
+	cr, err := t.factory.MetricsServerClusterRole()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server ClusterRole failed")
+	}
+
+	err = t.client.CreateOrUpdateClusterRole(ctx, cr)
+	if err != nil {
+		return errors.Wrap(err, "reconciling metrics-server ClusterRole failed")
+	}
+
+	for _, f := range []func() (*rbacv1.ClusterRoleBinding, error){
+		t.factory.MetricsServerClusterRoleBinding,
+		t.factory.MetricsServerClusterRoleBindingAuthDelegator,
+	} {
+		crb, err := f()
+		if err != nil {
+			return errors.Wrap(err, "initializing metrics-server ClusterRoleBinding failed")
+		}
+
+		err = t.client.CreateOrUpdateClusterRoleBinding(ctx, crb)
+		if err != nil {
+			return errors.Wrap(err, "reconciling metrics-server ClusterRoleBinding failed")
+		}
+	}
+
+	rb, err := t.factory.MetricsServerRoleBindingAuthReader()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server RoleBinding for auth-reader failed")
+	}
+
+	err = t.client.CreateOrUpdateRoleBinding(ctx, rb)
+	if err != nil {
+		return errors.Wrap(err, "reconciling metrics-server RoleBinding for auth-reader failed")
+	}
+
+	svc, err := t.factory.MetricsServerService()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server Service failed")
+	}
+
+	err = t.client.CreateOrUpdateService(ctx, svc)
+	if err != nil {
+		return errors.Wrap(err, "reconciling metrics-server Service failed")
+	}
+
+	dep, err := t.factory.MetricsServerDeployment()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server Deployment failed")
+	}
+
+	err = t.client.CreateOrUpdateDeployment(ctx, dep)
+	if err != nil {
+		return errors.Wrap(err, "reconciling metrics-server Deployment failed")
+	}
+
+	pdb, err := t.factory.MetricsServerPodDisruptionBudget()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server PodDisruptionBudget failed")
+	}
+
+	if pdb != nil {
+		err = t.client.CreateOrUpdatePodDisruptionBudget(ctx, pdb)
+		if err != nil {
+			return errors.Wrap(err, "reconciling metrics-server PodDisruptionBudget failed")
+		}
+	}
+
+	sm, err := t.factory.MetricsServerServiceMonitor()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server ServiceMonitor failed")
+	}
+
+	err = t.client.CreateOrUpdateServiceMonitor(ctx, sm)
+	if err != nil {
+		return errors.Wrap(err, "reconciling metrics-server ServiceMonitor failed")
+	}
+
+	err = t.client.WaitForDeploymentRollout(ctx, dep)
+	if err != nil {
+		return errors.Wrap(err, "waiting for metrics-server Deployment to rollout failed")
+	}
+
+	api, err := t.factory.MetricsServerAPIService()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server APIService failed")
+	}
+
+	err = t.client.CreateOrUpdateAPIService(ctx, api)
+	return errors.Wrap(err, "reconciling metrics-server APIService failed")
+}
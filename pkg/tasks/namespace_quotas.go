@@ -0,0 +1,267 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"sort"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/pkg/errors"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/klog/v2"
+)
+
+// NamespaceQuotasTask enforces the per-namespace scrape and rule budgets of
+// the user workload configuration on the ServiceMonitors, PodMonitors and
+// PrometheusRules.
+type NamespaceQuotasTask struct {
+	client   *client.Client
+	config   *manifests.Config
+	recorder events.Recorder
+
+	overQuota         []string
+	rulesOverQuota    int
+	monitorsOverQuota int
+}
+
+func NewNamespaceQuotasTask(client *client.Client, config *manifests.Config, recorder events.Recorder) *NamespaceQuotasTask {
+	return &NamespaceQuotasTask{
+		client:   client,
+		config:   config,
+		recorder: recorder,
+	}
+}
+
+func (t *NamespaceQuotasTask) Run(ctx context.Context) error {
+	t.overQuota = nil
+	t.rulesOverQuota = 0
+	t.monitorsOverQuota = 0
+
+	if !*t.config.ClusterMonitoringConfiguration.UserWorkloadEnabled {
+		return nil
+	}
+
+	ruleBudgets := map[string]struct{}{}
+	for _, q := range t.config.UserWorkloadConfiguration.NamespaceQuotas {
+		exceeded, err := t.enforce(ctx, q)
+		if err != nil {
+			return errors.Wrapf(err, "enforcing quota of namespace %q failed", q.Namespace)
+		}
+
+		if q.HasRuleBudget() {
+			ruleBudgets[q.Namespace] = struct{}{}
+
+			n, err := t.enforceRules(ctx, q)
+			if err != nil {
+				return errors.Wrapf(err, "enforcing rule quota of namespace %q failed", q.Namespace)
+			}
+			exceeded = exceeded || n > 0
+			t.rulesOverQuota += n
+		}
+
+		if exceeded {
+			t.overQuota = append(t.overQuota, q.Namespace)
+		}
+	}
+
+	if err := t.releaseRules(ctx, ruleBudgets); err != nil {
+		return errors.Wrap(err, "releasing PrometheusRules from obsolete rule quotas failed")
+	}
+
+	overTargetQuota, n, err := t.enforceTargetQuotas(ctx)
+	if err != nil {
+		return errors.Wrap(err, "enforcing target quotas failed")
+	}
+	t.monitorsOverQuota = n
+
+	seen := make(map[string]struct{}, len(t.overQuota))
+	for _, ns := range t.overQuota {
+		seen[ns] = struct{}{}
+	}
+	for _, ns := range overTargetQuota {
+		if _, found := seen[ns]; !found {
+			t.overQuota = append(t.overQuota, ns)
+		}
+	}
+
+	sort.Strings(t.overQuota)
+	return nil
+}
+
+// NamespacesOverQuota returns the namespaces having monitors which requested
+// higher limits than their budget, rules which don't fit in their budget or
+// monitors which don't fit in their target quota.
+func (t *NamespaceQuotasTask) NamespacesOverQuota() []string {
+	return t.overQuota
+}
+
+// PrometheusRulesOverQuota returns the number of PrometheusRules ignored
+// because they don't fit in the rule budget of their namespace.
+func (t *NamespaceQuotasTask) PrometheusRulesOverQuota() int {
+	return t.rulesOverQuota
+}
+
+// MonitorsOverTargetQuota returns the number of ServiceMonitors and
+// PodMonitors ignored because they don't fit in the target quota of their
+// namespace.
+func (t *NamespaceQuotasTask) MonitorsOverTargetQuota() int {
+	return t.monitorsOverQuota
+}
+
+func (t *NamespaceQuotasTask) enforce(ctx context.Context, q manifests.NamespaceQuota) (bool, error) {
+	var overQuota bool
+
+	sms, err := t.client.ListServiceMonitors(ctx, q.Namespace, "")
+	if err != nil {
+		return false, err
+	}
+
+	for _, sm := range sms {
+		changed, exceeded := q.Apply(&sm.ObjectMeta, &sm.Spec.SampleLimit, &sm.Spec.TargetLimit)
+		overQuota = overQuota || exceeded
+		if !changed {
+			continue
+		}
+
+		klog.V(4).Infof("Enforcing quota on ServiceMonitor %s/%s: sampleLimit=%d targetLimit=%d", sm.Namespace, sm.Name, sm.Spec.SampleLimit, sm.Spec.TargetLimit)
+		if err := t.client.UpdateServiceMonitor(ctx, sm); err != nil {
+			return false, err
+		}
+	}
+
+	pms, err := t.client.ListPodMonitors(ctx, q.Namespace, "")
+	if err != nil {
+		return false, err
+	}
+
+	for _, pm := range pms {
+		changed, exceeded := q.Apply(&pm.ObjectMeta, &pm.Spec.SampleLimit, &pm.Spec.TargetLimit)
+		overQuota = overQuota || exceeded
+		if !changed {
+			continue
+		}
+
+		klog.V(4).Infof("Enforcing quota on PodMonitor %s/%s: sampleLimit=%d targetLimit=%d", pm.Namespace, pm.Name, pm.Spec.SampleLimit, pm.Spec.TargetLimit)
+		if err := t.client.UpdatePodMonitor(ctx, pm); err != nil {
+			return false, err
+		}
+	}
+
+	return overQuota, nil
+}
+
+// enforceRules labels the PrometheusRules of the namespace which don't fit in
+// the rule budget so that they are ignored by the user workload Prometheus
+// and Thanos Ruler. The oldest PrometheusRules are accounted first to avoid
+// evicting existing rules when new ones are created. It returns the number of
+// PrometheusRules over quota.
+func (t *NamespaceQuotasTask) enforceRules(ctx context.Context, q manifests.NamespaceQuota) (int, error) {
+	rules, err := t.client.ListPrometheusRules(ctx, q.Namespace, "")
+	if err != nil {
+		return 0, err
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreationTimestamp.Equal(&rules[j].CreationTimestamp) {
+			return rules[i].CreationTimestamp.Before(&rules[j].CreationTimestamp)
+		}
+		return rules[i].Name < rules[j].Name
+	})
+
+	var (
+		overQuota                      int
+		prometheusRules, alertingRules uint64
+	)
+	for _, rule := range rules {
+		alerts := countAlertingRules(rule)
+		_, labeled := rule.Labels[manifests.RuleQuotaExceededLabel]
+
+		if q.AllowsRules(prometheusRules+1, alertingRules+alerts) {
+			prometheusRules++
+			alertingRules += alerts
+
+			if !labeled {
+				continue
+			}
+
+			delete(rule.Labels, manifests.RuleQuotaExceededLabel)
+			klog.V(4).Infof("PrometheusRule %s/%s fits in the rule quota again", rule.Namespace, rule.Name)
+			if err := t.client.UpdatePrometheusRule(ctx, rule); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		overQuota++
+		if labeled {
+			continue
+		}
+
+		if rule.Labels == nil {
+			rule.Labels = map[string]string{}
+		}
+		rule.Labels[manifests.RuleQuotaExceededLabel] = "true"
+		if err := t.client.UpdatePrometheusRule(ctx, rule); err != nil {
+			return 0, err
+		}
+
+		klog.Warningf("PrometheusRule %s/%s exceeds the rule quota of its namespace and is ignored", rule.Namespace, rule.Name)
+		t.recorder.Warningf(
+			"PrometheusRuleQuotaExceeded",
+			"PrometheusRule %s/%s with %d alerting rules exceeds the rule quota of the namespace (prometheusRuleLimit=%d, alertingRuleLimit=%d) and is ignored",
+			rule.Namespace, rule.Name, alerts, q.PrometheusRuleLimit, q.AlertingRuleLimit,
+		)
+	}
+
+	return overQuota, nil
+}
+
+// releaseRules removes the quota label from the PrometheusRules of the
+// namespaces which no longer have a rule budget.
+func (t *NamespaceQuotasTask) releaseRules(ctx context.Context, ruleBudgets map[string]struct{}) error {
+	rules, err := t.client.ListPrometheusRules(ctx, "", manifests.RuleQuotaExceededLabel)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if _, found := ruleBudgets[rule.Namespace]; found {
+			continue
+		}
+
+		delete(rule.Labels, manifests.RuleQuotaExceededLabel)
+		klog.V(4).Infof("Releasing PrometheusRule %s/%s from the rule quota", rule.Namespace, rule.Name)
+		if err := t.client.UpdatePrometheusRule(ctx, rule); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func countAlertingRules(rule *monv1.PrometheusRule) uint64 {
+	var n uint64
+	for _, g := range rule.Spec.Groups {
+		for _, r := range g.Rules {
+			if r.Alert != "" {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,360 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"sort"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+// monitor is a ServiceMonitor or a PodMonitor with the number of targets it
+// discovers.
+type monitor struct {
+	obj     runtime.Object
+	meta    *metav1.ObjectMeta
+	kind    string
+	targets uint64
+}
+
+func (m *monitor) labeled() bool {
+	_, found := m.meta.Labels[manifests.TargetQuotaExceededLabel]
+	return found
+}
+
+// enforceTargetQuotas labels the ServiceMonitors and PodMonitors whose
+// targets don't fit in the target budget of their namespace so that they are
+// ignored by the user workload Prometheus. The targets are counted from the
+// Services, Endpoints and Pods like the Kubernetes service discovery of
+// Prometheus would discover them. The oldest monitors are accounted first to
+// avoid evicting existing monitors when new ones are created. Only the
+// namespaces with a target budget are listed. It returns the namespaces over
+// quota and the number of monitors over quota.
+func (t *NamespaceQuotasTask) enforceTargetQuotas(ctx context.Context) ([]string, int, error) {
+	var (
+		budgets           = map[string]struct{}{}
+		overQuota         []string
+		monitorsOverQuota int
+	)
+	for _, q := range t.config.UserWorkloadConfiguration.NamespaceQuotas {
+		if q.TargetLimit == 0 {
+			continue
+		}
+		budgets[q.Namespace] = struct{}{}
+
+		monitors, err := t.listMonitors(ctx, q.Namespace, "")
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(monitors) == 0 {
+			continue
+		}
+
+		n, err := t.enforceTargetQuota(ctx, q.Namespace, q.TargetLimit, monitors)
+		if err != nil {
+			return nil, 0, err
+		}
+		if n > 0 {
+			overQuota = append(overQuota, q.Namespace)
+			monitorsOverQuota += n
+		}
+	}
+
+	if err := t.releaseMonitors(ctx, budgets); err != nil {
+		return nil, 0, err
+	}
+
+	return overQuota, monitorsOverQuota, nil
+}
+
+// listMonitors returns the ServiceMonitors and PodMonitors of the namespace
+// matching the label selector.
+func (t *NamespaceQuotasTask) listMonitors(ctx context.Context, namespace, labelSelector string) ([]*monitor, error) {
+	sms, err := t.client.ListServiceMonitors(ctx, namespace, labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	pms, err := t.client.ListPodMonitors(ctx, namespace, labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	monitors := make([]*monitor, 0, len(sms)+len(pms))
+	for _, sm := range sms {
+		monitors = append(monitors, &monitor{obj: sm, meta: &sm.ObjectMeta, kind: "ServiceMonitor"})
+	}
+	for _, pm := range pms {
+		monitors = append(monitors, &monitor{obj: pm, meta: &pm.ObjectMeta, kind: "PodMonitor"})
+	}
+	return monitors, nil
+}
+
+func (t *NamespaceQuotasTask) enforceTargetQuota(ctx context.Context, namespace string, quota uint64, monitors []*monitor) (int, error) {
+	if err := t.countTargets(ctx, namespace, monitors); err != nil {
+		return 0, err
+	}
+
+	sort.Slice(monitors, func(i, j int) bool {
+		if !monitors[i].meta.CreationTimestamp.Equal(&monitors[j].meta.CreationTimestamp) {
+			return monitors[i].meta.CreationTimestamp.Before(&monitors[j].meta.CreationTimestamp)
+		}
+		if monitors[i].kind != monitors[j].kind {
+			return monitors[i].kind < monitors[j].kind
+		}
+		return monitors[i].meta.Name < monitors[j].meta.Name
+	})
+
+	var (
+		overQuota int
+		targets   uint64
+	)
+	for _, m := range monitors {
+		if targets+m.targets <= quota {
+			targets += m.targets
+
+			if !m.labeled() {
+				continue
+			}
+
+			delete(m.meta.Labels, manifests.TargetQuotaExceededLabel)
+			klog.V(4).Infof("%s %s/%s fits in the target quota again", m.kind, m.meta.Namespace, m.meta.Name)
+			if err := t.updateMonitor(ctx, m); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		overQuota++
+		if m.labeled() {
+			continue
+		}
+
+		if m.meta.Labels == nil {
+			m.meta.Labels = map[string]string{}
+		}
+		m.meta.Labels[manifests.TargetQuotaExceededLabel] = "true"
+		if err := t.updateMonitor(ctx, m); err != nil {
+			return 0, err
+		}
+
+		klog.Warningf("%s %s/%s exceeds the target quota of its namespace and is ignored", m.kind, m.meta.Namespace, m.meta.Name)
+		t.client.RecordObjectWarning(
+			m.obj,
+			"TargetQuotaExceeded",
+			"%s discovers %d targets which exceed the target quota of the namespace (%d targets used out of %d) and is ignored",
+			m.kind, m.targets, targets, quota,
+		)
+	}
+
+	return overQuota, nil
+}
+
+// releaseMonitors removes the quota label from the monitors of the
+// namespaces which no longer have a target budget.
+func (t *NamespaceQuotasTask) releaseMonitors(ctx context.Context, budgets map[string]struct{}) error {
+	monitors, err := t.listMonitors(ctx, "", manifests.TargetQuotaExceededLabel)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range monitors {
+		if _, found := budgets[m.meta.Namespace]; found {
+			continue
+		}
+
+		delete(m.meta.Labels, manifests.TargetQuotaExceededLabel)
+		klog.V(4).Infof("Releasing %s %s/%s from the target quota", m.kind, m.meta.Namespace, m.meta.Name)
+		if err := t.updateMonitor(ctx, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *NamespaceQuotasTask) updateMonitor(ctx context.Context, m *monitor) error {
+	switch obj := m.obj.(type) {
+	case *monv1.ServiceMonitor:
+		return t.client.UpdateServiceMonitor(ctx, obj)
+	case *monv1.PodMonitor:
+		return t.client.UpdatePodMonitor(ctx, obj)
+	}
+	return nil
+}
+
+func (t *NamespaceQuotasTask) countTargets(ctx context.Context, namespace string, monitors []*monitor) error {
+	services, err := t.client.ListServices(ctx, namespace, "")
+	if err != nil {
+		return err
+	}
+	endpoints, err := t.client.ListEndpoints(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	pods, err := t.client.ListPods(ctx, namespace, "")
+	if err != nil {
+		return err
+	}
+
+	endpointsByService := make(map[string]*v1.Endpoints, len(endpoints))
+	for i := range endpoints {
+		endpointsByService[endpoints[i].Name] = &endpoints[i]
+	}
+	podsByName := make(map[string]*v1.Pod, len(pods))
+	for i := range pods {
+		podsByName[pods[i].Name] = &pods[i]
+	}
+
+	for _, m := range monitors {
+		var err error
+		switch obj := m.obj.(type) {
+		case *monv1.ServiceMonitor:
+			m.targets, err = serviceMonitorTargets(obj, services, endpointsByService, podsByName)
+		case *monv1.PodMonitor:
+			m.targets, err = podMonitorTargets(obj, pods)
+		}
+		if err != nil {
+			// The monitor can't select anything, Prometheus ignores it.
+			klog.V(4).Infof("Failed to count the targets of %s %s/%s: %v", m.kind, m.meta.Namespace, m.meta.Name, err)
+			m.targets = 0
+		}
+	}
+
+	return nil
+}
+
+// serviceMonitorTargets returns the number of targets discovered by the
+// ServiceMonitor: one per address and port of the endpoints of the selected
+// services matching each endpoint of the ServiceMonitor.
+func serviceMonitorTargets(sm *monv1.ServiceMonitor, services []v1.Service, endpoints map[string]*v1.Endpoints, pods map[string]*v1.Pod) (uint64, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&sm.Spec.Selector)
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	for _, svc := range services {
+		if !selector.Matches(labels.Set(svc.Labels)) {
+			continue
+		}
+
+		ep, found := endpoints[svc.Name]
+		if !found {
+			continue
+		}
+
+		for _, mep := range sm.Spec.Endpoints {
+			for _, subset := range ep.Subsets {
+				addresses := append(append([]v1.EndpointAddress{}, subset.Addresses...), subset.NotReadyAddresses...)
+				for _, port := range subset.Ports {
+					for _, addr := range addresses {
+						var pod *v1.Pod
+						if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+							pod = pods[addr.TargetRef.Name]
+						}
+						if serviceMonitorEndpointMatches(mep, port, pod) {
+							n++
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// serviceMonitorEndpointMatches returns whether the endpoint of the
+// ServiceMonitor discovers the port of an address backed by the given pod.
+// The pod is nil when the address isn't backed by a known pod.
+func serviceMonitorEndpointMatches(mep monv1.Endpoint, port v1.EndpointPort, pod *v1.Pod) bool {
+	switch {
+	case mep.Port != "":
+		return mep.Port == port.Name
+	case mep.TargetPort != nil && mep.TargetPort.IntVal != 0:
+		return mep.TargetPort.IntVal == port.Port
+	case mep.TargetPort != nil && mep.TargetPort.StrVal != "":
+		// The target port names are the names of the container ports,
+		// the addresses without pod aren't discovered.
+		if pod == nil {
+			return false
+		}
+		for _, c := range pod.Spec.Containers {
+			for _, cp := range c.Ports {
+				if cp.Name == mep.TargetPort.StrVal && cp.ContainerPort == port.Port {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// podMonitorTargets returns the number of targets discovered by the
+// PodMonitor: one per container port of the selected running pods matching
+// each endpoint of the PodMonitor.
+func podMonitorTargets(pm *monv1.PodMonitor, pods []v1.Pod) (uint64, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&pm.Spec.Selector)
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+
+		for _, pep := range pm.Spec.PodMetricsEndpoints {
+			for _, c := range pod.Spec.Containers {
+				if len(c.Ports) == 0 && pep.Port == "" && pep.TargetPort == nil {
+					// Containers without ports are discovered once.
+					n++
+					continue
+				}
+				for _, port := range c.Ports {
+					if podMetricsEndpointMatches(pep, port) {
+						n++
+					}
+				}
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func podMetricsEndpointMatches(pep monv1.PodMetricsEndpoint, port v1.ContainerPort) bool {
+	switch {
+	case pep.Port != "":
+		return pep.Port == port.Name
+	case pep.TargetPort != nil && pep.TargetPort.IntVal != 0:
+		return pep.TargetPort.IntVal == port.ContainerPort
+	case pep.TargetPort != nil:
+		return pep.TargetPort.StrVal == port.Name
+	}
+	return true
+}
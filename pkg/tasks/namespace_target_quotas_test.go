@@ -0,0 +1,105 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"testing"
+
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestServiceMonitorTargets(t *testing.T) {
+	svc := v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"app": "foo"}}}
+	pod := func(name string, ports ...v1.ContainerPort) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app", Ports: ports}}},
+		}
+	}
+	address := func(pod string) v1.EndpointAddress {
+		return v1.EndpointAddress{IP: "10.0.0.1", TargetRef: &v1.ObjectReference{Kind: "Pod", Name: pod}}
+	}
+	endpoints := map[string]*v1.Endpoints{
+		"app": {
+			ObjectMeta: metav1.ObjectMeta{Name: "app"},
+			Subsets: []v1.EndpointSubset{{
+				Addresses:         []v1.EndpointAddress{address("app-0"), address("app-1"), {IP: "10.0.0.9"}},
+				NotReadyAddresses: []v1.EndpointAddress{address("app-2")},
+				Ports: []v1.EndpointPort{
+					{Name: "web", Port: 8080},
+					{Name: "metrics", Port: 9090},
+				},
+			}},
+		},
+	}
+	pods := map[string]*v1.Pod{
+		"app-0": pod("app-0", v1.ContainerPort{Name: "http", ContainerPort: 8080}, v1.ContainerPort{Name: "prom", ContainerPort: 9090}),
+		"app-1": pod("app-1", v1.ContainerPort{Name: "http", ContainerPort: 8080}, v1.ContainerPort{Name: "prom", ContainerPort: 9090}),
+		// The named port points to another port number in this pod.
+		"app-2": pod("app-2", v1.ContainerPort{Name: "prom", ContainerPort: 9091}),
+	}
+
+	for _, tc := range []struct {
+		name     string
+		endpoint monv1.Endpoint
+		expected uint64
+	}{
+		{
+			name:     "port name",
+			endpoint: monv1.Endpoint{Port: "metrics"},
+			expected: 4,
+		},
+		{
+			name:     "target port number",
+			endpoint: monv1.Endpoint{TargetPort: &intstr.IntOrString{Type: intstr.Int, IntVal: 8080}},
+			expected: 4,
+		},
+		{
+			name:     "target port name",
+			endpoint: monv1.Endpoint{TargetPort: &intstr.IntOrString{Type: intstr.String, StrVal: "prom"}},
+			expected: 2,
+		},
+		{
+			name:     "unknown target port name",
+			endpoint: monv1.Endpoint{TargetPort: &intstr.IntOrString{Type: intstr.String, StrVal: "unknown"}},
+			expected: 0,
+		},
+		{
+			name:     "all ports",
+			endpoint: monv1.Endpoint{},
+			expected: 8,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sm := &monv1.ServiceMonitor{
+				Spec: monv1.ServiceMonitorSpec{
+					Selector:  metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+					Endpoints: []monv1.Endpoint{tc.endpoint},
+				},
+			}
+
+			n, err := serviceMonitorTargets(sm, []v1.Service{svc}, endpoints, pods)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tc.expected {
+				t.Fatalf("expected %d targets, got %d", tc.expected, n)
+			}
+		})
+	}
+}
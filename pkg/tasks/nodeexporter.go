@@ -16,20 +16,24 @@ package tasks
 
 import (
 	"context"
+
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
 	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 )
 
 type NodeExporterTask struct {
 	client  *client.Client
 	factory *manifests.Factory
+	config  *manifests.Config
 }
 
-func NewNodeExporterTask(client *client.Client, factory *manifests.Factory) *NodeExporterTask {
+func NewNodeExporterTask(client *client.Client, factory *manifests.Factory, config *manifests.Config) *NodeExporterTask {
 	return &NodeExporterTask{
 		client:  client,
 		factory: factory,
+		config:  config,
 	}
 }
 
@@ -93,7 +97,12 @@ func (t *NodeExporterTask) Run(ctx context.Context) error {
 		return errors.Wrap(err, "reconciling node-exporter Service failed")
 	}
 
-	ds, err := t.factory.NodeExporterDaemonSet()
+	textfile, err := t.textfileConfigMap(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting node-exporter textfile ConfigMap failed")
+	}
+
+	ds, err := t.factory.NodeExporterDaemonSet(textfile)
 	if err != nil {
 		return errors.Wrap(err, "initializing node-exporter DaemonSet failed")
 	}
@@ -120,3 +129,14 @@ func (t *NodeExporterTask) Run(ctx context.Context) error {
 	err = t.client.CreateOrUpdateServiceMonitor(ctx, smn)
 	return errors.Wrap(err, "reconciling node-exporter ServiceMonitor failed")
 }
+
+// textfileConfigMap returns the ConfigMap holding the custom textfile
+// collector files or nil if none is configured.
+func (t *NodeExporterTask) textfileConfigMap(ctx context.Context) (*v1.ConfigMap, error) {
+	cfg := t.config.ClusterMonitoringConfiguration.NodeExporterConfig.Textfile
+	if cfg == nil || cfg.ConfigMap == "" {
+		return nil, nil
+	}
+
+	return t.client.GetConfigmap(ctx, t.client.Namespace(), cfg.ConfigMap)
+}
@@ -24,16 +24,26 @@ import (
 type OpenShiftStateMetricsTask struct {
 	client  *client.Client
 	factory *manifests.Factory
+	config  *manifests.Config
 }
 
-func NewOpenShiftStateMetricsTask(client *client.Client, factory *manifests.Factory) *OpenShiftStateMetricsTask {
+func NewOpenShiftStateMetricsTask(client *client.Client, factory *manifests.Factory, config *manifests.Config) *OpenShiftStateMetricsTask {
 	return &OpenShiftStateMetricsTask{
 		client:  client,
 		factory: factory,
+		config:  config,
 	}
 }
 
 func (t *OpenShiftStateMetricsTask) Run(ctx context.Context) error {
+	if t.config.ClusterMonitoringConfiguration.OpenShiftMetricsConfig.IsEnabled() {
+		return t.create(ctx)
+	}
+
+	return t.destroy(ctx)
+}
+
+func (t *OpenShiftStateMetricsTask) create(ctx context.Context) error {
 	sa, err := t.factory.OpenShiftStateMetricsServiceAccount()
 	if err != nil {
 		return errors.Wrap(err, "initializing openshift-state-metrics Service failed")
@@ -102,3 +112,73 @@ func (t *OpenShiftStateMetricsTask) Run(ctx context.Context) error {
 	err = t.client.CreateOrUpdateServiceMonitor(ctx, sm)
 	return errors.Wrap(err, "reconciling openshift-state-metrics ServiceMonitor failed")
 }
+
+func (t *OpenShiftStateMetricsTask) destroy(ctx context.Context) error {
+	sm, err := t.factory.OpenShiftStateMetricsServiceMonitor()
+	if err != nil {
+		return errors.Wrap(err, "initializing openshift-state-metrics ServiceMonitor failed")
+	}
+
+	err = t.client.DeleteServiceMonitor(ctx, sm)
+	if err != nil {
+		return errors.Wrap(err, "deleting openshift-state-metrics ServiceMonitor failed")
+	}
+
+	dep, err := t.factory.OpenShiftStateMetricsDeployment()
+	if err != nil {
+		return errors.Wrap(err, "initializing openshift-state-metrics Deployment failed")
+	}
+
+	err = t.client.DeleteDeployment(ctx, dep)
+	if err != nil {
+		return errors.Wrap(err, "deleting openshift-state-metrics Deployment failed")
+	}
+
+	rs, err := t.factory.OpenShiftStateMetricsRBACProxySecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing openshift-state-metrics RBAC proxy Secret failed")
+	}
+
+	err = t.client.DeleteSecret(ctx, rs)
+	if err != nil {
+		return errors.Wrap(err, "deleting openshift-state-metrics RBAC proxy Secret failed")
+	}
+
+	svc, err := t.factory.OpenShiftStateMetricsService()
+	if err != nil {
+		return errors.Wrap(err, "initializing openshift-state-metrics Service failed")
+	}
+
+	err = t.client.DeleteService(ctx, svc)
+	if err != nil {
+		return errors.Wrap(err, "deleting openshift-state-metrics Service failed")
+	}
+
+	crb, err := t.factory.OpenShiftStateMetricsClusterRoleBinding()
+	if err != nil {
+		return errors.Wrap(err, "initializing openshift-state-metrics ClusterRoleBinding failed")
+	}
+
+	err = t.client.DeleteClusterRoleBinding(ctx, crb)
+	if err != nil {
+		return errors.Wrap(err, "deleting openshift-state-metrics ClusterRoleBinding failed")
+	}
+
+	cr, err := t.factory.OpenShiftStateMetricsClusterRole()
+	if err != nil {
+		return errors.Wrap(err, "initializing openshift-state-metrics ClusterRole failed")
+	}
+
+	err = t.client.DeleteClusterRole(ctx, cr)
+	if err != nil {
+		return errors.Wrap(err, "deleting openshift-state-metrics ClusterRole failed")
+	}
+
+	sa, err := t.factory.OpenShiftStateMetricsServiceAccount()
+	if err != nil {
+		return errors.Wrap(err, "initializing openshift-state-metrics ServiceAccount failed")
+	}
+
+	err = t.client.DeleteServiceAccount(ctx, sa)
+	return errors.Wrap(err, "deleting openshift-state-metrics ServiceAccount failed")
+}
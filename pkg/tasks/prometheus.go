@@ -20,21 +20,28 @@ import (
 
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
 	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/cluster-monitoring-operator/pkg/recommender"
 	"github.com/pkg/errors"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/klog/v2"
 )
 
 type PrometheusTask struct {
-	client  *client.Client
-	factory *manifests.Factory
-	config  *manifests.Config
+	client      *client.Client
+	factory     *manifests.Factory
+	config      *manifests.Config
+	recommender *recommender.Recommender
 }
 
-func NewPrometheusTask(client *client.Client, factory *manifests.Factory, config *manifests.Config) *PrometheusTask {
+func NewPrometheusTask(client *client.Client, factory *manifests.Factory, config *manifests.Config, recommender *recommender.Recommender) *PrometheusTask {
 	return &PrometheusTask{
-		client:  client,
-		factory: factory,
-		config:  config,
+		client:      client,
+		factory:     factory,
+		config:      config,
+		recommender: recommender,
 	}
 }
 
@@ -64,19 +71,22 @@ func (t *PrometheusTask) Run(ctx context.Context) error {
 		return errors.Wrap(err, "creating kubelet serving CA Bundle ConfigMap failed")
 	}
 
-	r, err := t.factory.PrometheusK8sRoute()
-	if err != nil {
-		return errors.Wrap(err, "initializing Prometheus Route failed")
-	}
+	var host string
+	if !t.config.RouteAPIUnavailable {
+		r, err := t.factory.PrometheusK8sRoute()
+		if err != nil {
+			return errors.Wrap(err, "initializing Prometheus Route failed")
+		}
 
-	err = t.client.CreateRouteIfNotExists(ctx, r)
-	if err != nil {
-		return errors.Wrap(err, "creating Prometheus Route failed")
-	}
+		err = t.client.CreateRouteIfNotExists(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "creating Prometheus Route failed")
+		}
 
-	host, err := t.client.WaitForRouteReady(ctx, r)
-	if err != nil {
-		return errors.Wrap(err, "waiting for Prometheus Route to become ready failed")
+		host, err = t.client.WaitForRouteReady(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "waiting for Prometheus Route to become ready failed")
+		}
 	}
 
 	ps, err := t.factory.PrometheusK8sProxySecret()
@@ -84,9 +94,9 @@ func (t *PrometheusTask) Run(ctx context.Context) error {
 		return errors.Wrap(err, "initializing Prometheus proxy Secret failed")
 	}
 
-	err = t.client.CreateIfNotExistSecret(ctx, ps)
+	err = t.client.CreateOrUpdateGeneratedSecret(ctx, ps, "session_secret")
 	if err != nil {
-		return errors.Wrap(err, "creating Prometheus proxy Secret failed")
+		return errors.Wrap(err, "reconciling Prometheus proxy Secret failed")
 	}
 
 	// If Grafana is enabled, create the basic auth secret.
@@ -114,7 +124,7 @@ func (t *PrometheusTask) Run(ctx context.Context) error {
 			return errors.Wrap(err, "initializing Prometheus htpasswd Secret failed")
 		}
 
-		err = t.client.CreateOrUpdateSecret(ctx, htpasswdSecret)
+		err = t.client.CreateOrUpdateHtpasswdSecret(ctx, htpasswdSecret, basicAuthPassword)
 		if err != nil {
 			return errors.Wrap(err, "creating Prometheus htpasswd Secret failed")
 		}
@@ -370,6 +380,11 @@ func (t *PrometheusTask) Run(ctx context.Context) error {
 			return errors.Wrap(err, "initializing Prometheus object failed")
 		}
 
+		err = t.applyResourceRecommendation(ctx, p)
+		if err != nil {
+			return errors.Wrap(err, "applying Prometheus resource recommendation failed")
+		}
+
 		klog.V(4).Info("reconciling Prometheus object")
 		err = t.client.CreateOrUpdatePrometheus(ctx, p)
 		if err != nil {
@@ -405,3 +420,61 @@ func (t *PrometheusTask) Run(ctx context.Context) error {
 
 	return nil
 }
+
+// applyResourceRecommendation sets the memory request of the Prometheus
+// object from its observed usage when the recommendation mode is enabled.
+// The last recommendation is persisted in a ConfigMap and reused when
+// Prometheus can't be queried so that the request doesn't flap.
+func (t *PrometheusTask) applyResourceRecommendation(ctx context.Context, p *monv1.Prometheus) error {
+	rc := t.config.ClusterMonitoringConfiguration.PrometheusK8sConfig.ResourceRecommendation
+	cm := t.factory.PrometheusK8sResourceRecommendation(resource.Quantity{}, 0, 0)
+
+	if !rc.IsEnabled() {
+		return errors.Wrap(t.client.DeleteConfigMap(ctx, cm), "deleting resource recommendation ConfigMap failed")
+	}
+
+	if t.recommender == nil {
+		klog.Warning("Prometheus resource recommendation is enabled but the recommender isn't available")
+		return nil
+	}
+
+	var previous *resource.Quantity
+	existing, err := t.client.GetConfigmap(ctx, cm.GetNamespace(), cm.GetName())
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return errors.Wrap(err, "getting resource recommendation ConfigMap failed")
+	default:
+		if q, err := resource.ParseQuantity(existing.Data["memoryRequest"]); err == nil {
+			previous = &q
+		}
+	}
+
+	rec, err := t.recommender.Recommend(ctx, previous, rc.MinMemoryRequest, rc.MaxMemoryRequest)
+	if err != nil {
+		// Prometheus isn't queryable during the initial rollout for instance.
+		klog.Warningf("computing Prometheus resource recommendation failed, keeping the previous value: %v", err)
+		if previous != nil {
+			setMemoryRequest(p, *previous)
+		}
+		return nil
+	}
+
+	setMemoryRequest(p, rec.MemoryRequest)
+
+	cm = t.factory.PrometheusK8sResourceRecommendation(rec.MemoryRequest, rec.WorkingSetBytes, rec.HeadSeries)
+	return errors.Wrap(t.client.CreateOrUpdateConfigMap(ctx, cm), "reconciling resource recommendation ConfigMap failed")
+}
+
+// setMemoryRequest sets the memory request of the Prometheus object without
+// exceeding the memory limit.
+func setMemoryRequest(p *monv1.Prometheus, q resource.Quantity) {
+	if limit, found := p.Spec.Resources.Limits[v1.ResourceMemory]; found && q.Cmp(limit) > 0 {
+		q = limit
+	}
+
+	if p.Spec.Resources.Requests == nil {
+		p.Spec.Resources.Requests = v1.ResourceList{}
+	}
+	p.Spec.Resources.Requests[v1.ResourceMemory] = q
+}
@@ -198,6 +198,16 @@ func (t *PrometheusUserWorkloadTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "creating or updating UserWorkload Prometheus RBAC proxy Secret failed")
 	}
 
+	rsf, err := t.factory.PrometheusUserWorkloadRBACProxyFederateSecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing UserWorkload Prometheus RBAC federate endpoint Secret failed")
+	}
+
+	err = t.client.CreateOrUpdateSecret(ctx, rsf)
+	if err != nil {
+		return errors.Wrap(err, "creating or updating UserWorkload Prometheus RBAC federate endpoint Secret failed")
+	}
+
 	secret, err := t.factory.PrometheusUserWorkloadAdditionalAlertManagerConfigsSecret()
 	if err != nil {
 		return errors.Wrap(err, "initializing UserWorkload Prometheus additionalAlertmanagerConfigs secret failed")
@@ -208,6 +218,16 @@ func (t *PrometheusUserWorkloadTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "reconciling UserWorkload Prometheus additionalAlertmanagerConfigs secret failed")
 	}
 
+	arc, err := t.factory.PrometheusUserWorkloadAlertRelabelConfigsSecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing UserWorkload Prometheus alert relabel configs secret failed")
+	}
+
+	err = t.client.CreateOrUpdateSecret(ctx, arc)
+	if err != nil {
+		return errors.Wrap(err, "reconciling UserWorkload Prometheus alert relabel configs secret failed")
+	}
+
 	pdb, err := t.factory.PrometheusUserWorkloadPodDisruptionBudget()
 	if err != nil {
 		return errors.Wrap(err, "initializing UserWorkload Prometheus PodDisruptionBudget object failed")
@@ -439,6 +459,16 @@ func (t *PrometheusUserWorkloadTask) destroy(ctx context.Context) error {
 		return errors.Wrap(err, "deleting or updating UserWorkload Prometheus RBAC proxy Secret failed")
 	}
 
+	rsf, err := t.factory.PrometheusUserWorkloadRBACProxyFederateSecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing UserWorkload Prometheus RBAC federate endpoint Secret failed")
+	}
+
+	err = t.client.DeleteSecret(ctx, rsf)
+	if err != nil {
+		return errors.Wrap(err, "deleting UserWorkload Prometheus RBAC federate endpoint Secret failed")
+	}
+
 	amsSecret, err := t.factory.PrometheusUserWorkloadAdditionalAlertManagerConfigsSecret()
 	if err != nil {
 		return errors.Wrap(err, "initializing UserWorkload Prometheus additionalAlertmanagerConfigs secret failed")
@@ -448,6 +478,15 @@ func (t *PrometheusUserWorkloadTask) destroy(ctx context.Context) error {
 		return errors.Wrap(err, "deleting UserWorkload Prometheus additionalAlertmanagerConfigs Secret failed")
 	}
 
+	arc, err := t.factory.PrometheusUserWorkloadAlertRelabelConfigsSecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing UserWorkload Prometheus alert relabel configs secret failed")
+	}
+
+	if err = t.client.DeleteSecret(ctx, arc); err != nil {
+		return errors.Wrap(err, "deleting UserWorkload Prometheus alert relabel configs Secret failed")
+	}
+
 	err = t.client.DeleteConfigMap(ctx, cacm)
 	return errors.Wrap(err, "deleting UserWorkload serving certs CA Bundle ConfigMap failed")
 }
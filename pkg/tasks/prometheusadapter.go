@@ -6,26 +6,36 @@ import (
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
 	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 )
 
 type PrometheusAdapterTask struct {
 	client    *client.Client
 	ctx       context.Context
 	factory   *manifests.Factory
+	config    *manifests.Config
 	namespace string
 }
 
-func NewPrometheusAdapterTask(ctx context.Context, namespace string, client *client.Client, factory *manifests.Factory) *PrometheusAdapterTask {
+func NewPrometheusAdapterTask(ctx context.Context, namespace string, client *client.Client, factory *manifests.Factory, config *manifests.Config) *PrometheusAdapterTask {
 	return &PrometheusAdapterTask{
 		client:    client,
 		factory:   factory,
+		config:    config,
 		namespace: namespace,
 		ctx:       ctx,
 	}
 }
 
 func (t *PrometheusAdapterTask) Run(ctx context.Context) error {
+	// The resources are removed by the resource metrics task once the API
+	// is served by metrics-server.
+	if !t.config.ClusterMonitoringConfiguration.K8sPrometheusAdapter.IsEnabled(t.config.ClusterMonitoringConfiguration.MetricsServerConfig) {
+		return nil
+	}
+
 	{
 		cr, err := t.factory.PrometheusAdapterClusterRole()
 		if err != nil {
@@ -158,6 +168,7 @@ func (t *PrometheusAdapterTask) Run(ctx context.Context) error {
 			return errors.Wrap(err, "reconciling PrometheusAdapter Service failed")
 		}
 	}
+	var dep *appsv1.Deployment
 	{
 		tlsSecret, err := t.client.GetSecret(ctx, t.namespace, "prometheus-adapter-tls")
 		if err != nil {
@@ -184,7 +195,7 @@ func (t *PrometheusAdapterTask) Run(ctx context.Context) error {
 			return errors.Wrap(err, "reconciling PrometheusAdapter Secret failed")
 		}
 
-		dep, err := t.factory.PrometheusAdapterDeployment(secret.Name, apiAuthConfigmap.Data)
+		dep, err = t.factory.PrometheusAdapterDeployment(secret.Name, apiAuthConfigmap.Data)
 		if err != nil {
 			return errors.Wrap(err, "initializing PrometheusAdapter Deployment failed")
 		}
@@ -218,7 +229,15 @@ func (t *PrometheusAdapterTask) Run(ctx context.Context) error {
 			return errors.Wrap(err, "reconciling PrometheusAdapter ServiceMonitor failed")
 		}
 	}
-	{
+	// The resource metrics API is served by metrics-server when enabled.
+	if !t.config.ClusterMonitoringConfiguration.MetricsServerConfig.IsEnabled() {
+		// Make sure that prometheus-adapter is available before taking
+		// over the API from metrics-server.
+		err := t.client.WaitForDeploymentRollout(ctx, dep)
+		if err != nil {
+			return errors.Wrap(err, "waiting for PrometheusAdapter Deployment to rollout failed")
+		}
+
 		api, err := t.factory.PrometheusAdapterAPIService()
 		if err != nil {
 			return errors.Wrap(err, "initializing PrometheusAdapter APIService failed")
@@ -229,6 +248,37 @@ func (t *PrometheusAdapterTask) Run(ctx context.Context) error {
 			return errors.Wrap(err, "reconciling PrometheusAdapter APIService failed")
 		}
 	}
+	{
+		// The custom and external metrics APIs are only registered when
+		// rules are defined to not conflict with other adapters.
+		cfg := t.config.ClusterMonitoringConfiguration.K8sPrometheusAdapter
+		for _, api := range []struct {
+			name    string
+			enabled bool
+			fn      func() (*apiregistrationv1.APIService, error)
+		}{
+			{name: "custom metrics", enabled: len(cfg.CustomMetricsRules) > 0, fn: t.factory.PrometheusAdapterCustomMetricsAPIService},
+			{name: "external metrics", enabled: len(cfg.ExternalMetricsRules) > 0, fn: t.factory.PrometheusAdapterExternalMetricsAPIService},
+		} {
+			apiService, err := api.fn()
+			if err != nil {
+				return errors.Wrapf(err, "initializing PrometheusAdapter %s APIService failed", api.name)
+			}
+
+			if api.enabled {
+				err = t.client.CreateOrUpdateAPIService(ctx, apiService)
+				if err != nil {
+					return errors.Wrapf(err, "reconciling PrometheusAdapter %s APIService failed", api.name)
+				}
+				continue
+			}
+
+			err = t.client.DeleteAPIService(ctx, apiService)
+			if err != nil {
+				return errors.Wrapf(err, "deleting PrometheusAdapter %s APIService failed", api.name)
+			}
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,52 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/cluster-monitoring-operator/pkg/querycanary"
+)
+
+// QueryCanaryTask passes the configuration to the canary which runs
+// representative queries against Thanos Querier. The canary runs the queries
+// on its own schedule, independently of the reconciliations.
+type QueryCanaryTask struct {
+	canary *querycanary.Canary
+	config *manifests.Config
+}
+
+func NewQueryCanaryTask(canary *querycanary.Canary, config *manifests.Config) *QueryCanaryTask {
+	return &QueryCanaryTask{
+		canary: canary,
+		config: config,
+	}
+}
+
+func (t *QueryCanaryTask) Run(ctx context.Context) error {
+	if t.canary == nil {
+		return nil
+	}
+
+	cfg := t.config.ClusterMonitoringConfiguration.QueryCanary
+	interval, err := cfg.IntervalDuration()
+	if err != nil {
+		return err
+	}
+
+	t.canary.SetConfig(cfg.Enabled, interval)
+	return nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+)
+
+// ResourceMetricsTask removes the resource metrics backend which isn't used
+// anymore. It runs after the prometheus-adapter and metrics-server tasks
+// which move the resource metrics API to the enabled backend, hence the
+// resources are only removed once the API is served by the other backend.
+type ResourceMetricsTask struct {
+	client  *client.Client
+	factory *manifests.Factory
+	config  *manifests.Config
+}
+
+func NewResourceMetricsTask(client *client.Client, factory *manifests.Factory, config *manifests.Config) *ResourceMetricsTask {
+	return &ResourceMetricsTask{
+		client:  client,
+		factory: factory,
+		config:  config,
+	}
+}
+
+func (t *ResourceMetricsTask) Run(ctx context.Context) error {
+	cfg := t.config.ClusterMonitoringConfiguration
+	if !cfg.MetricsServerConfig.IsEnabled() {
+		return t.destroyMetricsServer(ctx)
+	}
+
+	if !cfg.K8sPrometheusAdapter.IsEnabled(cfg.MetricsServerConfig) {
+		return t.destroyPrometheusAdapter(ctx)
+	}
+
+	return nil
+}
+
+func (t *ResourceMetricsTask) destroyMetricsServer(ctx context.Context) error {
+	// The APIService is left untouched if already served by
+	// prometheus-adapter.
+	api, err := t.factory.MetricsServerAPIService()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server APIService failed")
+	}
+
+	err = t.client.DeleteAPIService(ctx, api)
+	if err != nil {
+		return errors.Wrap(err, "deleting metrics-server APIService failed")
+	}
+
+	sm, err := t.factory.MetricsServerServiceMonitor()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server ServiceMonitor failed")
+	}
+
+	err = t.client.DeleteServiceMonitor(ctx, sm)
+	if err != nil {
+		return errors.Wrap(err, "deleting metrics-server ServiceMonitor failed")
+	}
+
+	pdb, err := t.factory.MetricsServerPodDisruptionBudget()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server PodDisruptionBudget failed")
+	}
+
+	if pdb != nil {
+		err = t.client.DeletePodDisruptionBudget(ctx, pdb)
+		if err != nil {
+			return errors.Wrap(err, "deleting metrics-server PodDisruptionBudget failed")
+		}
+	}
+
+	dep, err := t.factory.MetricsServerDeployment()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server Deployment failed")
+	}
+
+	err = t.client.DeleteDeployment(ctx, dep)
+	if err != nil {
+		return errors.Wrap(err, "deleting metrics-server Deployment failed")
+	}
+
+	svc, err := t.factory.MetricsServerService()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server Service failed")
+	}
+
+	err = t.client.DeleteService(ctx, svc)
+	if err != nil {
+		return errors.Wrap(err, "deleting metrics-server Service failed")
+	}
+
+	err = t.deleteServingCertSecret(ctx, svc)
+	if err != nil {
+		return errors.Wrap(err, "deleting metrics-server TLS Secret failed")
+	}
+
+	rb, err := t.factory.MetricsServerRoleBindingAuthReader()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server RoleBinding for auth-reader failed")
+	}
+
+	err = t.client.DeleteRoleBinding(ctx, rb)
+	if err != nil {
+		return errors.Wrap(err, "deleting metrics-server RoleBinding for auth-reader failed")
+	}
+
+	for _, f := range []func() (*rbacv1.ClusterRoleBinding, error){
+		t.factory.MetricsServerClusterRoleBinding,
+		t.factory.MetricsServerClusterRoleBindingAuthDelegator,
+	} {
+		crb, err := f()
+		if err != nil {
+			return errors.Wrap(err, "initializing metrics-server ClusterRoleBinding failed")
+		}
+
+		err = t.client.DeleteClusterRoleBinding(ctx, crb)
+		if err != nil {
+			return errors.Wrap(err, "deleting metrics-server ClusterRoleBinding failed")
+		}
+	}
+
+	cr, err := t.factory.MetricsServerClusterRole()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server ClusterRole failed")
+	}
+
+	err = t.client.DeleteClusterRole(ctx, cr)
+	if err != nil {
+		return errors.Wrap(err, "deleting metrics-server ClusterRole failed")
+	}
+
+	sa, err := t.factory.MetricsServerServiceAccount()
+	if err != nil {
+		return errors.Wrap(err, "initializing metrics-server ServiceAccount failed")
+	}
+
+	err = t.client.DeleteServiceAccount(ctx, sa)
+	return errors.Wrap(err, "deleting metrics-server ServiceAccount failed")
+}
+
+func (t *ResourceMetricsTask) destroyPrometheusAdapter(ctx context.Context) error {
+	// The resource metrics APIService is left untouched since it is served
+	// by metrics-server at this point.
+	for _, f := range []func() (*apiregistrationv1.APIService, error){
+		t.factory.PrometheusAdapterAPIService,
+		t.factory.PrometheusAdapterCustomMetricsAPIService,
+		t.factory.PrometheusAdapterExternalMetricsAPIService,
+	} {
+		api, err := f()
+		if err != nil {
+			return errors.Wrap(err, "initializing PrometheusAdapter APIService failed")
+		}
+
+		err = t.client.DeleteAPIService(ctx, api)
+		if err != nil {
+			return errors.Wrap(err, "deleting PrometheusAdapter APIService failed")
+		}
+	}
+
+	sm, err := t.factory.PrometheusAdapterServiceMonitor()
+	if err != nil {
+		return errors.Wrap(err, "initializing PrometheusAdapter ServiceMonitor failed")
+	}
+
+	err = t.client.DeleteServiceMonitor(ctx, sm)
+	if err != nil {
+		return errors.Wrap(err, "deleting PrometheusAdapter ServiceMonitor failed")
+	}
+
+	pdb, err := t.factory.PrometheusAdapterPodDisruptionBudget()
+	if err != nil {
+		return errors.Wrap(err, "initializing PrometheusAdapter PodDisruptionBudget failed")
+	}
+
+	if pdb != nil {
+		err = t.client.DeletePodDisruptionBudget(ctx, pdb)
+		if err != nil {
+			return errors.Wrap(err, "deleting PrometheusAdapter PodDisruptionBudget failed")
+		}
+	}
+
+	// The Deployment manifest depends on the API server authentication
+	// configuration, only its name is needed here.
+	err = t.client.DeleteDeployment(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: t.client.Namespace(),
+			Name:      "prometheus-adapter",
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "deleting PrometheusAdapter Deployment failed")
+	}
+
+	err = t.client.DeleteHashedSecret(ctx, t.client.Namespace(), "prometheus-adapter", "")
+	if err != nil {
+		return errors.Wrap(err, "deleting PrometheusAdapter Secret failed")
+	}
+
+	svc, err := t.factory.PrometheusAdapterService()
+	if err != nil {
+		return errors.Wrap(err, "initializing PrometheusAdapter Service failed")
+	}
+
+	err = t.client.DeleteService(ctx, svc)
+	if err != nil {
+		return errors.Wrap(err, "deleting PrometheusAdapter Service failed")
+	}
+
+	err = t.deleteServingCertSecret(ctx, svc)
+	if err != nil {
+		return errors.Wrap(err, "deleting PrometheusAdapter TLS Secret failed")
+	}
+
+	for _, f := range []func() (*v1.ConfigMap, error){
+		t.factory.PrometheusAdapterConfigMap,
+		t.factory.PrometheusAdapterConfigMapAuditPolicy,
+		t.factory.PrometheusAdapterConfigMapPrometheus,
+	} {
+		cm, err := f()
+		if err != nil {
+			return errors.Wrap(err, "initializing PrometheusAdapter ConfigMap failed")
+		}
+
+		err = t.client.DeleteConfigMap(ctx, cm)
+		if err != nil {
+			return errors.Wrap(err, "deleting PrometheusAdapter ConfigMap failed")
+		}
+	}
+
+	rb, err := t.factory.PrometheusAdapterRoleBindingAuthReader()
+	if err != nil {
+		return errors.Wrap(err, "initializing PrometheusAdapter RoleBinding for auth-reader failed")
+	}
+
+	err = t.client.DeleteRoleBinding(ctx, rb)
+	if err != nil {
+		return errors.Wrap(err, "deleting PrometheusAdapter RoleBinding for auth-reader failed")
+	}
+
+	for _, f := range []func() (*rbacv1.ClusterRoleBinding, error){
+		t.factory.PrometheusAdapterClusterRoleBinding,
+		t.factory.PrometheusAdapterClusterRoleBindingDelegator,
+		t.factory.PrometheusAdapterClusterRoleBindingView,
+	} {
+		crb, err := f()
+		if err != nil {
+			return errors.Wrap(err, "initializing PrometheusAdapter ClusterRoleBinding failed")
+		}
+
+		err = t.client.DeleteClusterRoleBinding(ctx, crb)
+		if err != nil {
+			return errors.Wrap(err, "deleting PrometheusAdapter ClusterRoleBinding failed")
+		}
+	}
+
+	// The ClusterRole aggregating the read permissions of the resource
+	// metrics API to the default roles is kept, the API is still served.
+	for _, f := range []func() (*rbacv1.ClusterRole, error){
+		t.factory.PrometheusAdapterClusterRole,
+		t.factory.PrometheusAdapterClusterRoleServerResources,
+	} {
+		cr, err := f()
+		if err != nil {
+			return errors.Wrap(err, "initializing PrometheusAdapter ClusterRole failed")
+		}
+
+		err = t.client.DeleteClusterRole(ctx, cr)
+		if err != nil {
+			return errors.Wrap(err, "deleting PrometheusAdapter ClusterRole failed")
+		}
+	}
+
+	sa, err := t.factory.PrometheusAdapterServiceAccount()
+	if err != nil {
+		return errors.Wrap(err, "initializing PrometheusAdapter ServiceAccount failed")
+	}
+
+	err = t.client.DeleteServiceAccount(ctx, sa)
+	return errors.Wrap(err, "deleting PrometheusAdapter ServiceAccount failed")
+}
+
+// deleteServingCertSecret deletes the serving certificate generated by the
+// service CA operator for the Service.
+func (t *ResourceMetricsTask) deleteServingCertSecret(ctx context.Context, svc *v1.Service) error {
+	name := svc.Annotations["service.beta.openshift.io/serving-cert-secret-name"]
+	if name == "" {
+		return nil
+	}
+
+	return t.client.DeleteSecret(ctx, &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: svc.Namespace,
+			Name:      name,
+		},
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"time"
+
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/library-go/pkg/operator/events"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// The CA bundle of the default ingress certificate is published by the
+	// ingress operator, including when the certificate is issued by a custom
+	// PKI.
+	ingressCANamespace = "openshift-config-managed"
+	ingressCAConfigMap = "default-ingress-cert"
+	ingressCAKey       = "ca-bundle.crt"
+
+	routeProbeTimeout = 10 * time.Second
+)
+
+// RouteHealthTask checks that the monitoring UIs are reachable through their
+// Routes. The default ingress CA is trusted in addition to the system CAs so
+// that clusters using a custom PKI for ingress don't report TLS errors. The
+// failures are reported as warning events and never fail the reconciliation.
+type RouteHealthTask struct {
+	client   *client.Client
+	factory  *manifests.Factory
+	config   *manifests.Config
+	recorder events.Recorder
+}
+
+func NewRouteHealthTask(client *client.Client, factory *manifests.Factory, config *manifests.Config, recorder events.Recorder) *RouteHealthTask {
+	return &RouteHealthTask{
+		client:   client,
+		factory:  factory,
+		config:   config,
+		recorder: recorder,
+	}
+}
+
+func (t *RouteHealthTask) Run(ctx context.Context) error {
+	if t.config.RouteAPIUnavailable {
+		return nil
+	}
+
+	pool, err := t.ingressCertPool(ctx)
+	if err != nil {
+		klog.Warningf("skipping the Route health checks: %v", err)
+		return nil
+	}
+
+	c := &http.Client{
+		Timeout: routeProbeTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+		// The UIs redirect to the OAuth server, reaching the Route is
+		// enough.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	routes := []func() (*routev1.Route, error){
+		t.factory.PrometheusK8sRoute,
+		t.factory.ThanosQuerierRoute,
+	}
+	if t.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig.IsEnabled() {
+		routes = append(routes, t.factory.AlertmanagerRoute)
+	}
+	if t.config.ClusterMonitoringConfiguration.GrafanaConfig.IsEnabled() {
+		routes = append(routes, t.factory.GrafanaRoute)
+	}
+
+	for _, f := range routes {
+		r, err := f()
+		if err != nil {
+			klog.Warningf("skipping the Route health check: %v", err)
+			continue
+		}
+
+		t.probe(ctx, c, r)
+	}
+
+	return nil
+}
+
+// ingressCertPool returns the system CAs extended with the CA bundle of the
+// default ingress certificate, if published.
+func (t *RouteHealthTask) ingressCertPool(ctx context.Context) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		klog.V(4).Infof("system CA pool unavailable: %v", err)
+		pool = x509.NewCertPool()
+	}
+
+	cm, err := t.client.GetConfigmap(ctx, ingressCANamespace, ingressCAConfigMap)
+	if apierrors.IsNotFound(err) {
+		return pool, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ca := cm.Data[ingressCAKey]; ca != "" && !pool.AppendCertsFromPEM([]byte(ca)) {
+		klog.Warningf("no certificate found in %s/%s", ingressCANamespace, ingressCAConfigMap)
+	}
+
+	return pool, nil
+}
+
+func (t *RouteHealthTask) probe(ctx context.Context, c *http.Client, r *routev1.Route) {
+	u, err := t.client.GetRouteURL(ctx, r)
+	if err != nil {
+		klog.Warningf("skipping the health check of Route %s/%s: %v", r.Namespace, r.Name, err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		klog.Warningf("skipping the health check of Route %s/%s: %v", r.Namespace, r.Name, err)
+		return
+	}
+
+	resp, err := c.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		klog.V(4).Infof("Route %s/%s is reachable at %s (status %d)", r.Namespace, r.Name, u, resp.StatusCode)
+		return
+	}
+
+	reason := "RouteUnreachable"
+	var uaErr x509.UnknownAuthorityError
+	if errors.As(err, &uaErr) {
+		reason = "RouteCertificateUntrusted"
+	}
+
+	klog.Warningf("Route %s/%s isn't reachable at %s: %v", r.Namespace, r.Name, u, err)
+	t.recorder.Warningf(reason, "Route %s/%s isn't reachable at %s: %v", r.Namespace, r.Name, u, err)
+}
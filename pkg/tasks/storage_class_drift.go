@@ -0,0 +1,196 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/pkg/errors"
+	monv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// storageStack describes the persistent volume claims of a component and the
+// volume claim template they are created from.
+type storageStack struct {
+	name      string
+	namespace string
+	selector  map[string]string
+	template  *monv1.EmbeddedPersistentVolumeClaim
+}
+
+// StorageClassDriftTask detects the persistent volume claims which were
+// provisioned with another storage class than the one configured in the
+// volume claim template, or the default storage class when the template
+// doesn't set any. The volume claim templates of a StatefulSet are
+// immutable hence such volumes are never migrated automatically.
+//
+// For the same reason, the task also detects the persistent volume claims
+// smaller than the size requested by the volume claim template: they have to
+// be resized manually.
+type StorageClassDriftTask struct {
+	client *client.Client
+	config *manifests.Config
+
+	drifts  map[string][]string
+	resizes map[string][]string
+}
+
+func NewStorageClassDriftTask(client *client.Client, config *manifests.Config) *StorageClassDriftTask {
+	return &StorageClassDriftTask{
+		client: client,
+		config: config,
+	}
+}
+
+func (t *StorageClassDriftTask) Run(ctx context.Context) error {
+	t.drifts = map[string][]string{}
+	t.resizes = map[string][]string{}
+
+	var defaultClass *string
+	for _, s := range t.stacks() {
+		if s.template == nil {
+			continue
+		}
+
+		expected := s.template.Spec.StorageClassName
+		if expected == nil || *expected == "" {
+			if defaultClass == nil {
+				sc, err := t.client.GetStorageClass(ctx, "")
+				if err != nil {
+					return errors.Wrap(err, "getting the default storage class failed")
+				}
+
+				var name string
+				if sc != nil {
+					name = sc.Name
+				}
+				defaultClass = &name
+			}
+			expected = defaultClass
+		}
+
+		requested, sized := s.template.Spec.Resources.Requests[v1.ResourceStorage]
+
+		// Without default storage class, the volumes are bound to
+		// pre-provisioned persistent volumes.
+		if *expected == "" && !sized {
+			continue
+		}
+
+		pvcs, err := t.client.ListPersistentVolumeClaims(ctx, s.namespace, labels.FormatLabels(s.selector))
+		if err != nil {
+			return errors.Wrapf(err, "listing the persistent volume claims of %s failed", s.name)
+		}
+
+		for _, pvc := range pvcs {
+			if sized {
+				// The capacity is only known once the volume is bound and
+				// lags behind the request while a resize is in progress.
+				size, found := pvc.Status.Capacity[v1.ResourceStorage]
+				if !found {
+					size = pvc.Spec.Resources.Requests[v1.ResourceStorage]
+				}
+
+				if size.Cmp(requested) < 0 {
+					klog.Warningf("PersistentVolumeClaim %s/%s of %s has a size of %s instead of %s", pvc.Namespace, pvc.Name, s.name, size.String(), requested.String())
+					t.resizes[s.name] = append(t.resizes[s.name], fmt.Sprintf("%s/%s has %s instead of %s", pvc.Namespace, pvc.Name, size.String(), requested.String()))
+				}
+			}
+
+			if *expected == "" {
+				continue
+			}
+
+			var current string
+			if pvc.Spec.StorageClassName != nil {
+				current = *pvc.Spec.StorageClassName
+			}
+
+			if current == *expected {
+				continue
+			}
+
+			klog.Warningf("PersistentVolumeClaim %s/%s of %s uses storage class %q instead of %q", pvc.Namespace, pvc.Name, s.name, current, *expected)
+			t.drifts[s.name] = append(t.drifts[s.name], fmt.Sprintf("%s/%s uses %q instead of %q", pvc.Namespace, pvc.Name, current, *expected))
+		}
+	}
+
+	return nil
+}
+
+// Drifts returns the persistent volume claims using an unexpected storage
+// class, keyed by component.
+func (t *StorageClassDriftTask) Drifts() map[string][]string {
+	return t.drifts
+}
+
+// Resizes returns the persistent volume claims smaller than the size
+// requested by the volume claim template, keyed by component.
+func (t *StorageClassDriftTask) Resizes() map[string][]string {
+	return t.resizes
+}
+
+func (t *StorageClassDriftTask) stacks() []storageStack {
+	cfg := t.config.ClusterMonitoringConfiguration
+
+	stacks := []storageStack{
+		{
+			name:      "prometheus-k8s",
+			namespace: t.client.Namespace(),
+			selector:  map[string]string{"app.kubernetes.io/name": "prometheus", "prometheus": "k8s"},
+			template:  cfg.PrometheusK8sConfig.VolumeClaimTemplate,
+		},
+	}
+
+	if cfg.AlertmanagerMainConfig.IsEnabled() {
+		stacks = append(stacks, storageStack{
+			name:      "alertmanager-main",
+			namespace: t.client.Namespace(),
+			selector:  map[string]string{"app.kubernetes.io/name": "alertmanager", "alertmanager": "main"},
+			template:  cfg.AlertmanagerMainConfig.VolumeClaimTemplate,
+		})
+	}
+
+	uwc := t.config.UserWorkloadConfiguration
+	if !*cfg.UserWorkloadEnabled || uwc == nil {
+		return stacks
+	}
+
+	if uwc.Prometheus != nil {
+		stacks = append(stacks, storageStack{
+			name:      "prometheus-user-workload",
+			namespace: t.client.UserWorkloadNamespace(),
+			selector:  map[string]string{"app.kubernetes.io/name": "prometheus", "prometheus": "user-workload"},
+			template:  uwc.Prometheus.VolumeClaimTemplate,
+		})
+	}
+
+	if uwc.ThanosRuler != nil {
+		stacks = append(stacks, storageStack{
+			name:      "thanos-ruler-user-workload",
+			namespace: t.client.UserWorkloadNamespace(),
+			selector:  map[string]string{"app.kubernetes.io/name": "thanos-ruler", "thanos-ruler": "user-workload"},
+			template:  uwc.ThanosRuler.VolumeClaimTemplate,
+		})
+	}
+
+	return stacks
+}
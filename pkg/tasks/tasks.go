@@ -17,87 +17,183 @@ package tasks
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
-	"golang.org/x/sync/errgroup"
+	cmostr "github.com/openshift/cluster-monitoring-operator/pkg/strings"
 	"k8s.io/klog/v2"
-	"strings"
 )
 
-// TaskRunner manages lists of task groups. Through the RunAll method task groups are
-// executed, the groups sequentially, each group of tasks concurrently.
+// TaskRunner runs a graph of tasks. A task starts as soon as all the tasks it
+// depends on succeeded, hence independent branches of the graph run
+// concurrently and a slow task (e.g. waiting for the rollout of a
+// StatefulSet) only delays the tasks depending on it.
 type TaskRunner struct {
-	client     *client.Client
-	taskGroups []*TaskGroup
+	client *client.Client
+	tasks  []*TaskSpec
 }
 
-// NewTaskRunner returns a task runner. tasks is the first task group that will
-// be executed, before any list added via AppendTaskGroup.
-func NewTaskRunner(client *client.Client, taskGroups ...*TaskGroup) *TaskRunner {
+// NewTaskRunner returns a task runner for the given tasks. The dependencies
+// of the tasks must be part of the runner.
+func NewTaskRunner(client *client.Client, tasks ...*TaskSpec) *TaskRunner {
 	return &TaskRunner{
-		client:     client,
-		taskGroups: append([]*TaskGroup{}, taskGroups...),
+		client: client,
+		tasks:  append([]*TaskSpec{}, tasks...),
 	}
 }
 
-// RunAll executes all registered task groups sequentially. For each group the
-// taskGroup.RunConcurrently function is called.
+// RunAll executes all the tasks following their dependencies. The tasks
+// depending on a failed task, directly or not, are skipped while the other
+// branches of the graph complete. A warning event is emitted for each failed
+// task. Tasks with dependencies missing from the runner or forming a cycle
+// fail without running.
+func (tl *TaskRunner) RunAll(ctx context.Context) TaskErrors {
+	invalid := tl.validate()
 
-func (tl *TaskRunner) RunAll(ctx context.Context) TaskGroupErrors {
-	for i, tGroup := range tl.taskGroups {
-		klog.V(2).Infof("processing task group %d of %d", i+1, len(tl.taskGroups))
-		tErrors := tGroup.RunConcurrently(ctx)
-		if len(tErrors) > 0 {
-			return tErrors
-		}
+	var (
+		wg         sync.WaitGroup
+		mtx        sync.Mutex
+		taskErrors TaskErrors
+	)
+	total := len(tl.tasks)
+	for _, ts := range tl.tasks {
+		ts.state = nil
+		ts.succeeded = false
+		ts.done = make(chan struct{})
 	}
-	return nil
-}
 
-// RunConcurrently dispatches all tasks in a task group. The tasks are scheduled
-// concurrently. Returns all the errors that are encountered.
-func (tg *TaskGroup) RunConcurrently(ctx context.Context) TaskGroupErrors {
-	var g errgroup.Group
-	tgLength := len(tg.tasks)
-	errChan := make(chan TaskErr, tgLength)
-	for i, ts := range tg.tasks {
+	for i, ts := range tl.tasks {
 		// shadow vars due to concurrency
 		ts := ts
 		i := i
 
-		g.Go(func() error {
-			klog.V(2).Infof("running task %d of %d: %v", i+1, tgLength, ts.Name)
-			err := ts.Task.Run(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(ts.done)
+
+			if err, found := invalid[ts]; found {
+				klog.Warningf("task %d of %d: %v can't run: %v", i+1, total, ts.Name, err)
+				mtx.Lock()
+				taskErrors = append(taskErrors, TaskErr{Err: err, Name: ts.Name})
+				mtx.Unlock()
+				return
+			}
+
+			for _, dep := range ts.deps {
+				<-dep.done
+				if !dep.succeeded {
+					klog.V(2).Infof("skipping task %d of %d: %v because %v didn't succeed", i+1, total, ts.Name, dep.Name)
+					return
+				}
+			}
+
+			if ts.paused != "" {
+				klog.V(2).Infof("pausing task %d of %d: %v: %s", i+1, total, ts.Name, ts.paused)
+				ts.succeeded = true
+				return
+			}
+
+			if ts.skipped != "" {
+				klog.V(2).Infof("skipping task %d of %d: %v: %s", i+1, total, ts.Name, ts.skipped)
+				ts.succeeded = true
+				return
+			}
+
+			klog.V(2).Infof("running task %d of %d: %v", i+1, total, ts.Name)
+			start := time.Now()
+			err := ts.Task.Run(client.WithTask(ctx, ts.Name))
+			ts.state = &TaskState{Name: ts.Name, StartTime: start, Err: err, InputsHash: ts.inputs}
 			if err != nil {
-				klog.Warningf("task %d of %d: %v failed: %v", i+1, tgLength, ts.Name, err)
-				errChan <- TaskErr{Err: err, Name: ts.Name}
-			} else {
-				klog.V(2).Infof("ran task %d of %d: %v", i+1, tgLength, ts.Name)
+				klog.Warningf("task %d of %d: %v failed: %v", i+1, total, ts.Name, err)
+				mtx.Lock()
+				taskErrors = append(taskErrors, TaskErr{Err: err, Name: ts.Name})
+				mtx.Unlock()
+				return
 			}
-			return nil
-		})
+			ts.succeeded = true
+			klog.V(2).Infof("ran task %d of %d (%v): %v", i+1, total, time.Since(start).Round(time.Millisecond), ts.Name)
+		}()
 	}
+	wg.Wait()
 
-	g.Wait()
-	// To be able to use the range function on the buffered channel
-	// the channel needs to closed. Otherwise the range will keep waiting
-	// till the channel is closed. This is why defer is not used.
-	close(errChan)
-	var taskGroupErrors TaskGroupErrors
-	for tErr := range errChan {
-		taskGroupErrors = append(taskGroupErrors, tErr)
+	// Report the errors in the order of the tasks rather than in the order
+	// of completion.
+	order := make(map[string]int, total)
+	for i, ts := range tl.tasks {
+		order[ts.Name] = i
 	}
+	sort.SliceStable(taskErrors, func(i, j int) bool { return order[taskErrors[i].Name] < order[taskErrors[j].Name] })
 
-	return taskGroupErrors
+	for _, tErr := range taskErrors {
+		tl.client.RecordFailure(cmostr.ToPascalCase(tErr.Name+"Failed"), tErr.Err)
+	}
+	return taskErrors
 }
 
-func NewTaskGroup(tasks []*TaskSpec) *TaskGroup {
-	return &TaskGroup{
-		tasks: tasks,
+// validate returns the tasks which can't run because one of their
+// dependencies, direct or not, isn't part of the runner or because they
+// belong to a dependency cycle.
+func (tl *TaskRunner) validate() map[*TaskSpec]error {
+	registered := make(map[*TaskSpec]struct{}, len(tl.tasks))
+	for _, ts := range tl.tasks {
+		registered[ts] = struct{}{}
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	marks := map[*TaskSpec]int{}
+	invalid := map[*TaskSpec]error{}
+
+	var visit func(ts *TaskSpec) error
+	visit = func(ts *TaskSpec) error {
+		switch marks[ts] {
+		case visiting:
+			return fmt.Errorf("dependency cycle through %q", ts.Name)
+		case visited:
+			return invalid[ts]
+		}
+
+		marks[ts] = visiting
+		var err error
+		for _, dep := range ts.deps {
+			if _, found := registered[dep]; !found {
+				err = fmt.Errorf("unknown dependency %q", dep.Name)
+				break
+			}
+			if err = visit(dep); err != nil {
+				break
+			}
+		}
+		marks[ts] = visited
+		if err != nil {
+			invalid[ts] = err
+		}
+		return err
+	}
+
+	for _, ts := range tl.tasks {
+		visit(ts)
 	}
+	return invalid
 }
 
-type TaskGroup struct {
-	tasks []*TaskSpec
+// States returns the outcome of the tasks which ran during the last call to
+// RunAll. The tasks which didn't run because they were skipped or paused or
+// because a dependency failed are omitted.
+func (tl *TaskRunner) States() []TaskState {
+	var states []TaskState
+	for _, ts := range tl.tasks {
+		if ts.state != nil {
+			states = append(states, *ts.state)
+		}
+	}
+	return states
 }
 
 func NewTaskSpec(name string, task Task) *TaskSpec {
@@ -107,9 +203,72 @@ func NewTaskSpec(name string, task Task) *TaskSpec {
 	}
 }
 
+// After declares the tasks which must succeed before the task runs.
+func (ts *TaskSpec) After(deps ...*TaskSpec) *TaskSpec {
+	ts.deps = append(ts.deps, deps...)
+	return ts
+}
+
+// Pause prevents the task from running while a state which the operator
+// can't fix on its own (e.g. a namespace being deleted) lasts. The state of a
+// paused task isn't updated and the tasks depending on it run as if it
+// succeeded.
+func (ts *TaskSpec) Pause(reason string) *TaskSpec {
+	ts.paused = reason
+	return ts
+}
+
+// Skip prevents the task from running during the next call to RunAll, e.g.
+// because its inputs didn't change since its last successful run. Like for a
+// paused task, the state of a skipped task isn't updated and the tasks
+// depending on it run as if it succeeded.
+func (ts *TaskSpec) Skip(reason string) *TaskSpec {
+	ts.skipped = reason
+	return ts
+}
+
+// Inputs sets the hash of the inputs of the task, as reported by the state
+// of its next run.
+func (ts *TaskSpec) Inputs(hash string) *TaskSpec {
+	ts.inputs = hash
+	return ts
+}
+
+// InputsHash returns the hash of the inputs of the task.
+func (ts *TaskSpec) InputsHash() string {
+	return ts.inputs
+}
+
 type TaskSpec struct {
 	Name string
 	Task Task
+
+	deps      []*TaskSpec
+	paused    string
+	skipped   string
+	inputs    string
+	state     *TaskState
+	succeeded bool
+	done      chan struct{}
+}
+
+// TaskState describes the last run of a task.
+type TaskState struct {
+	Name      string
+	StartTime time.Time
+	Err       error
+	// InputsHash is the hash of the inputs of the task, if known.
+	InputsHash string
+}
+
+// Component returns the component handled by the task: the task name
+// without its leading verb (e.g. "Prometheus-k8s" for "Updating
+// Prometheus-k8s").
+func (s TaskState) Component() string {
+	if i := strings.IndexByte(s.Name, ' '); i >= 0 {
+		return s.Name[i+1:]
+	}
+	return s.Name
 }
 
 type Task interface {
@@ -121,9 +280,9 @@ type TaskErr struct {
 	Name string
 }
 
-type TaskGroupErrors []TaskErr
+type TaskErrors []TaskErr
 
-func (tge TaskGroupErrors) Error() string {
+func (tge TaskErrors) Error() string {
 	if len(tge) == 0 {
 		return ""
 	}
@@ -0,0 +1,195 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// recorder records the names of the tasks which ran.
+type recorder struct {
+	mtx sync.Mutex
+	ran []string
+}
+
+func (r *recorder) names() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	names := append([]string{}, r.ran...)
+	sort.Strings(names)
+	return names
+}
+
+type fakeTask struct {
+	name  string
+	err   error
+	delay time.Duration
+	rec   *recorder
+}
+
+func (t *fakeTask) Run(ctx context.Context) error {
+	time.Sleep(t.delay)
+	t.rec.mtx.Lock()
+	t.rec.ran = append(t.rec.ran, t.name)
+	t.rec.mtx.Unlock()
+	return t.err
+}
+
+func TestTaskRunnerRunAll(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	for _, tc := range []struct {
+		name string
+		// tasks returns the tasks of the runner using newTask to create
+		// them.
+		tasks func(newTask func(name string, err error, delay time.Duration) *TaskSpec) []*TaskSpec
+
+		ran    []string
+		errors []string
+		// errContains is a substring of every error.
+		errContains string
+	}{
+		{
+			name: "all tasks succeed",
+			tasks: func(newTask func(string, error, time.Duration) *TaskSpec) []*TaskSpec {
+				a := newTask("a", nil, 0)
+				b := newTask("b", nil, 0).After(a)
+				return []*TaskSpec{a, b}
+			},
+			ran: []string{"a", "b"},
+		},
+		{
+			name: "dependents of a failed task are skipped",
+			tasks: func(newTask func(string, error, time.Duration) *TaskSpec) []*TaskSpec {
+				a := newTask("a", errFailed, 0)
+				b := newTask("b", nil, 0).After(a)
+				c := newTask("c", nil, 0).After(b)
+				d := newTask("d", nil, 0)
+				return []*TaskSpec{a, b, c, d}
+			},
+			ran:    []string{"a", "d"},
+			errors: []string{"a"},
+		},
+		{
+			name: "dependency cycle",
+			tasks: func(newTask func(string, error, time.Duration) *TaskSpec) []*TaskSpec {
+				a := newTask("a", nil, 0)
+				b := newTask("b", nil, 0).After(a)
+				a.After(b)
+				c := newTask("c", nil, 0)
+				return []*TaskSpec{a, b, c}
+			},
+			ran:         []string{"c"},
+			errors:      []string{"a", "b"},
+			errContains: "dependency cycle",
+		},
+		{
+			name: "unknown dependency",
+			tasks: func(newTask func(string, error, time.Duration) *TaskSpec) []*TaskSpec {
+				unknown := newTask("unknown", nil, 0)
+				a := newTask("a", nil, 0).After(unknown)
+				b := newTask("b", nil, 0).After(a)
+				c := newTask("c", nil, 0)
+				return []*TaskSpec{a, b, c}
+			},
+			ran:         []string{"c"},
+			errors:      []string{"a", "b"},
+			errContains: `unknown dependency "unknown"`,
+		},
+		{
+			name: "paused dependency counts as succeeded",
+			tasks: func(newTask func(string, error, time.Duration) *TaskSpec) []*TaskSpec {
+				a := newTask("a", errFailed, 0).Pause("namespace terminating")
+				b := newTask("b", nil, 0).After(a)
+				return []*TaskSpec{a, b}
+			},
+			ran: []string{"b"},
+		},
+		{
+			name: "skipped dependency counts as succeeded",
+			tasks: func(newTask func(string, error, time.Duration) *TaskSpec) []*TaskSpec {
+				a := newTask("a", errFailed, 0).Skip("inputs unchanged")
+				b := newTask("b", nil, 0).After(a)
+				return []*TaskSpec{a, b}
+			},
+			ran: []string{"b"},
+		},
+		{
+			name: "errors in the order of the tasks",
+			tasks: func(newTask func(string, error, time.Duration) *TaskSpec) []*TaskSpec {
+				// The first tasks complete last.
+				return []*TaskSpec{
+					newTask("a", errFailed, 30*time.Millisecond),
+					newTask("b", errFailed, 20*time.Millisecond),
+					newTask("c", nil, 0),
+					newTask("d", errFailed, 10*time.Millisecond),
+					newTask("e", errFailed, 0),
+				}
+			},
+			ran:    []string{"a", "b", "c", "d", "e"},
+			errors: []string{"a", "b", "d", "e"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &recorder{}
+			newTask := func(name string, err error, delay time.Duration) *TaskSpec {
+				return NewTaskSpec(name, &fakeTask{name: name, err: err, delay: delay, rec: rec})
+			}
+
+			c := client.New("", "openshift-monitoring", "openshift-user-workload-monitoring", client.KubernetesClient(fake.NewSimpleClientset()))
+			tr := NewTaskRunner(c, tc.tasks(newTask)...)
+
+			// The results don't depend on the run.
+			for run := 0; run < 2; run++ {
+				rec.ran = nil
+				taskErrors := tr.RunAll(context.Background())
+
+				if got := rec.names(); !reflect.DeepEqual(got, tc.ran) && (len(got) != 0 || len(tc.ran) != 0) {
+					t.Fatalf("run %d: expected tasks %v to run, got %v", run, tc.ran, got)
+				}
+
+				var names []string
+				for _, err := range taskErrors {
+					names = append(names, err.Name)
+					if !strings.Contains(err.Err.Error(), tc.errContains) {
+						t.Fatalf("run %d: expected the error of %s to contain %q, got %v", run, err.Name, tc.errContains, err.Err)
+					}
+				}
+				if !reflect.DeepEqual(names, tc.errors) {
+					t.Fatalf("run %d: expected errors for %v, got %v", run, tc.errors, names)
+				}
+
+				var states []string
+				for _, s := range tr.States() {
+					states = append(states, s.Name)
+				}
+				sort.Strings(states)
+				if !reflect.DeepEqual(states, tc.ran) && (len(states) != 0 || len(tc.ran) != 0) {
+					t.Fatalf("run %d: expected states for %v, got %v", run, tc.ran, states)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// teardownStack describes the pods and the persistent volume claims left by
+// a removable component once its task deleted its objects.
+type teardownStack struct {
+	name      string
+	namespace string
+	enabled   bool
+	selector  map[string]string
+	// claims is true when the component may have persistent volume claims.
+	claims bool
+}
+
+// TeardownTask completes the teardown of the disabled components: the tasks
+// of the components delete their objects, this task waits for their pods to
+// be gone and then deletes their persistent volume claims if the
+// configuration allows it. The claims are retained otherwise so that
+// re-enabling a component recovers its data.
+type TeardownTask struct {
+	client *client.Client
+	config *manifests.Config
+
+	pending  []string
+	retained []string
+}
+
+func NewTeardownTask(client *client.Client, config *manifests.Config) *TeardownTask {
+	return &TeardownTask{
+		client: client,
+		config: config,
+	}
+}
+
+func (t *TeardownTask) Run(ctx context.Context) error {
+	t.pending = nil
+	t.retained = nil
+
+	deleteClaims := t.config.ClusterMonitoringConfiguration.Teardown.DeletePersistentVolumeClaims
+	for _, s := range t.stacks() {
+		if s.enabled {
+			continue
+		}
+
+		selector := labels.FormatLabels(s.selector)
+		pods, err := t.client.ListPods(ctx, s.namespace, selector)
+		if err != nil {
+			return errors.Wrapf(err, "listing the pods of %s failed", s.name)
+		}
+		if len(pods) > 0 {
+			t.pending = append(t.pending, fmt.Sprintf("%s: %d pods terminating", s.name, len(pods)))
+		}
+
+		if !s.claims {
+			continue
+		}
+
+		pvcs, err := t.client.ListPersistentVolumeClaims(ctx, s.namespace, selector)
+		if err != nil {
+			return errors.Wrapf(err, "listing the persistent volume claims of %s failed", s.name)
+		}
+		if len(pvcs) == 0 {
+			continue
+		}
+
+		if !deleteClaims {
+			for _, pvc := range pvcs {
+				t.retained = append(t.retained, fmt.Sprintf("%s: %s/%s", s.name, pvc.Namespace, pvc.Name))
+			}
+			continue
+		}
+
+		t.pending = append(t.pending, fmt.Sprintf("%s: %d persistent volume claims deleting", s.name, len(pvcs)))
+
+		// The claims are deleted once the pods are gone so that the volumes
+		// aren't removed under a running workload.
+		if len(pods) > 0 {
+			continue
+		}
+
+		for i := range pvcs {
+			if pvcs[i].DeletionTimestamp != nil {
+				continue
+			}
+
+			klog.Infof("Deleting the PersistentVolumeClaim %s/%s of the removed component %s", pvcs[i].Namespace, pvcs[i].Name, s.name)
+			if err := t.client.DeletePersistentVolumeClaim(ctx, &pvcs[i]); err != nil {
+				return errors.Wrapf(err, "deleting the persistent volume claim %s/%s of %s failed", pvcs[i].Namespace, pvcs[i].Name, s.name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Pending returns the removed components whose pods or persistent volume
+// claims are still being deleted.
+func (t *TeardownTask) Pending() []string {
+	return t.pending
+}
+
+// Retained returns the persistent volume claims of the removed components
+// which are kept because the configuration doesn't allow their deletion.
+func (t *TeardownTask) Retained() []string {
+	return t.retained
+}
+
+func (t *TeardownTask) stacks() []teardownStack {
+	cfg := t.config.ClusterMonitoringConfiguration
+	uwmEnabled := *cfg.UserWorkloadEnabled
+
+	return []teardownStack{
+		{
+			name:      "alertmanager-main",
+			namespace: t.client.Namespace(),
+			enabled:   cfg.AlertmanagerMainConfig.IsEnabled(),
+			selector:  map[string]string{"app.kubernetes.io/name": "alertmanager", "alertmanager": "main"},
+			claims:    true,
+		},
+		{
+			name:      "grafana",
+			namespace: t.client.Namespace(),
+			enabled:   cfg.GrafanaConfig.IsEnabled(),
+			selector:  map[string]string{"app.kubernetes.io/name": "grafana", "app.kubernetes.io/component": "grafana"},
+		},
+		{
+			name:      "openshift-state-metrics",
+			namespace: t.client.Namespace(),
+			enabled:   cfg.OpenShiftMetricsConfig.IsEnabled(),
+			selector:  map[string]string{"app.kubernetes.io/name": "openshift-state-metrics"},
+		},
+		{
+			name:      "telemeter-client",
+			namespace: t.client.Namespace(),
+			enabled:   cfg.TelemeterClientConfig.IsEnabled(),
+			selector:  map[string]string{"app.kubernetes.io/name": "telemeter-client"},
+		},
+		{
+			name:      "thanos-receive",
+			namespace: t.client.Namespace(),
+			enabled:   cfg.ThanosReceiveConfig.IsEnabled(),
+			selector:  map[string]string{"app.kubernetes.io/name": "thanos-receive", "app.kubernetes.io/instance": "thanos-receive"},
+			claims:    true,
+		},
+		{
+			name:      "prometheus-operator-user-workload",
+			namespace: t.client.UserWorkloadNamespace(),
+			enabled:   uwmEnabled,
+			selector:  map[string]string{"app.kubernetes.io/name": "prometheus-operator"},
+		},
+		{
+			name:      "prometheus-user-workload",
+			namespace: t.client.UserWorkloadNamespace(),
+			enabled:   uwmEnabled,
+			selector:  map[string]string{"app.kubernetes.io/name": "prometheus", "prometheus": "user-workload"},
+			claims:    true,
+		},
+		{
+			name:      "thanos-ruler-user-workload",
+			namespace: t.client.UserWorkloadNamespace(),
+			enabled:   uwmEnabled,
+			selector:  map[string]string{"app.kubernetes.io/name": "thanos-ruler", "thanos-ruler": "user-workload"},
+			claims:    true,
+		},
+	}
+}
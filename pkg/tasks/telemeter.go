@@ -16,6 +16,7 @@ package tasks
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
@@ -117,7 +118,7 @@ func (t *TelemeterClientTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "initializing Telemeter client Secret failed")
 	}
 
-	err = t.client.CreateOrUpdateSecret(ctx, s)
+	err = t.client.CreateOrUpdateGeneratedSecret(ctx, s, "salt")
 	if err != nil {
 		return errors.Wrap(err, "reconciling Telemeter client Secret failed")
 	}
@@ -149,7 +150,12 @@ func (t *TelemeterClientTask) create(ctx context.Context) error {
 			return errors.Wrap(err, "syncing Telemeter client CA bundle ConfigMap failed")
 		}
 
-		dep, err := t.factory.TelemeterClientDeployment(trustedCA)
+		additionalCA, err := t.additionalCABundle(ctx)
+		if err != nil {
+			return errors.Wrap(err, "retrieving Telemeter client additional CA bundle failed")
+		}
+
+		dep, err := t.factory.TelemeterClientDeployment(trustedCA, additionalCA)
 		if err != nil {
 			return errors.Wrap(err, "initializing Telemeter client Deployment failed")
 		}
@@ -184,8 +190,52 @@ func (t *TelemeterClientTask) create(ctx context.Context) error {
 	return errors.Wrap(err, "reconciling Telemeter client ServiceMonitor failed")
 }
 
+// additionalCABundle returns the content of the additional CA bundle
+// referenced by the configuration or nil if there is none.
+func (t *TelemeterClientTask) additionalCABundle(ctx context.Context) ([]byte, error) {
+	cfg := t.config.ClusterMonitoringConfiguration.TelemeterClientConfig
+	if err := cfg.ValidateAdditionalCABundle(); err != nil {
+		return nil, err
+	}
+
+	ref := cfg.AdditionalCABundle
+	if ref == nil {
+		return nil, nil
+	}
+
+	var (
+		ca    []byte
+		found bool
+	)
+	if ref.ConfigMap != nil {
+		cm, err := t.client.GetConfigmap(ctx, t.client.Namespace(), ref.ConfigMap.Name)
+		if err != nil {
+			return nil, err
+		}
+		var v string
+		v, found = cm.Data[ref.ConfigMap.Key]
+		ca = []byte(v)
+	} else {
+		s, err := t.client.GetSecret(ctx, t.client.Namespace(), ref.Secret.Name)
+		if err != nil {
+			return nil, err
+		}
+		ca, found = s.Data[ref.Secret.Key]
+	}
+
+	if !found {
+		return nil, errors.New("key not found")
+	}
+
+	if !x509.NewCertPool().AppendCertsFromPEM(ca) {
+		return nil, errors.New("no PEM-encoded certificate found")
+	}
+
+	return ca, nil
+}
+
 func (t *TelemeterClientTask) destroy(ctx context.Context) error {
-	dep, err := t.factory.TelemeterClientDeployment(nil)
+	dep, err := t.factory.TelemeterClientDeployment(nil, nil)
 	if err != nil {
 		return errors.Wrap(err, "initializing Telemeter client Deployment failed")
 	}
@@ -21,6 +21,7 @@ import (
 	"github.com/openshift/cluster-monitoring-operator/pkg/client"
 	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 )
 
 type ThanosQuerierTask struct {
@@ -48,19 +49,21 @@ func (t *ThanosQuerierTask) Run(ctx context.Context) error {
 		return errors.Wrap(err, "reconciling Thanos Querier Service failed")
 	}
 
-	r, err := t.factory.ThanosQuerierRoute()
-	if err != nil {
-		return errors.Wrap(err, "initializing Thanos Querier Route failed")
-	}
+	if !t.config.RouteAPIUnavailable {
+		r, err := t.factory.ThanosQuerierRoute()
+		if err != nil {
+			return errors.Wrap(err, "initializing Thanos Querier Route failed")
+		}
 
-	err = t.client.CreateRouteIfNotExists(ctx, r)
-	if err != nil {
-		return errors.Wrap(err, "creating Thanos Querier Route failed")
-	}
+		err = t.client.CreateRouteIfNotExists(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "creating Thanos Querier Route failed")
+		}
 
-	_, err = t.client.WaitForRouteReady(ctx, r)
-	if err != nil {
-		return errors.Wrap(err, "waiting for Thanos Querier Route to become ready failed")
+		_, err = t.client.WaitForRouteReady(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "waiting for Thanos Querier Route to become ready failed")
+		}
 	}
 
 	s, err := t.factory.ThanosQuerierOauthCookieSecret()
@@ -68,9 +71,9 @@ func (t *ThanosQuerierTask) Run(ctx context.Context) error {
 		return errors.Wrap(err, "initializing Thanos Querier OAuth Cookie Secret failed")
 	}
 
-	err = t.client.CreateIfNotExistSecret(ctx, s)
+	err = t.client.CreateOrUpdateGeneratedSecret(ctx, s, "session_secret")
 	if err != nil {
-		return errors.Wrap(err, "creating Thanos Querier OAuth Cookie Secret failed")
+		return errors.Wrap(err, "reconciling Thanos Querier OAuth Cookie Secret failed")
 	}
 
 	// If Grafana is enabled, create the basic auth secret.
@@ -98,7 +101,7 @@ func (t *ThanosQuerierTask) Run(ctx context.Context) error {
 			return errors.Wrap(err, "initializing Thanos Querier htpasswd Secret failed")
 		}
 
-		err = t.client.CreateOrUpdateSecret(ctx, htpasswdSecret)
+		err = t.client.CreateOrUpdateHtpasswdSecret(ctx, htpasswdSecret, basicAuthPassword)
 		if err != nil {
 			return errors.Wrap(err, "creating Thanos Querier htpasswd Secret failed")
 		}
@@ -179,11 +182,31 @@ func (t *ThanosQuerierTask) Run(ctx context.Context) error {
 		return errors.Wrap(err, "error initializing Thanos Querier Client GRPC TLS secret")
 	}
 
-	s, err = t.factory.HashSecret(s,
+	data := []string{
 		"ca.crt", string(grpcTLS.Data["ca.crt"]),
 		"client.crt", string(grpcTLS.Data["thanos-querier-client.crt"]),
 		"client.key", string(grpcTLS.Data["thanos-querier-client.key"]),
-	)
+	}
+
+	// The queriers proxying the additional stores serve the store API to
+	// Thanos Querier hence they need the server certificate, the
+	// certificates of the stores are copied into the same Secret so that
+	// the Deployment is rolled out when they change.
+	stores, err := t.factory.ThanosQuerierAdditionalStoresTLS(func(namespace, name string) (*v1.Secret, error) {
+		return t.client.GetSecret(ctx, namespace, name)
+	})
+	if err != nil {
+		return errors.Wrap(err, "error initializing Thanos Querier additional stores TLS")
+	}
+	if len(t.config.ClusterMonitoringConfiguration.ThanosQuerierConfig.AdditionalStores) > 0 {
+		data = append(data,
+			"server.crt", string(grpcTLS.Data["prometheus-server.crt"]),
+			"server.key", string(grpcTLS.Data["prometheus-server.key"]),
+		)
+		data = append(data, stores...)
+	}
+
+	s, err = t.factory.HashSecret(s, data...)
 	if err != nil {
 		return errors.Wrap(err, "error hashing Thanos Querier Client GRPC TLS secret")
 	}
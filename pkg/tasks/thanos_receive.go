@@ -0,0 +1,311 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+type ThanosReceiveTask struct {
+	client  *client.Client
+	factory *manifests.Factory
+	config  *manifests.Config
+}
+
+func NewThanosReceiveTask(client *client.Client, factory *manifests.Factory, config *manifests.Config) *ThanosReceiveTask {
+	return &ThanosReceiveTask{
+		client:  client,
+		factory: factory,
+		config:  config,
+	}
+}
+
+func (t *ThanosReceiveTask) Run(ctx context.Context) error {
+	if t.config.ClusterMonitoringConfiguration.ThanosReceiveConfig.IsEnabled() {
+		return t.create(ctx)
+	}
+
+	return t.destroy(ctx)
+}
+
+func (t *ThanosReceiveTask) create(ctx context.Context) error {
+	// Validate the configuration before creating any resource.
+	hashrings, err := t.factory.ThanosReceiveHashringsConfigMap()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive hashrings ConfigMap failed")
+	}
+
+	sa, err := t.factory.ThanosReceiveServiceAccount()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive ServiceAccount failed")
+	}
+
+	err = t.client.CreateOrUpdateServiceAccount(ctx, sa)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Thanos Receive ServiceAccount failed")
+	}
+
+	cr, err := t.factory.ThanosReceiveClusterRole()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive ClusterRole failed")
+	}
+
+	err = t.client.CreateOrUpdateClusterRole(ctx, cr)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Thanos Receive ClusterRole failed")
+	}
+
+	crb, err := t.factory.ThanosReceiveClusterRoleBinding()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive ClusterRoleBinding failed")
+	}
+
+	err = t.client.CreateOrUpdateClusterRoleBinding(ctx, crb)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Thanos Receive ClusterRoleBinding failed")
+	}
+
+	cr, err = t.factory.ThanosReceiveRemoteWriterClusterRole()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive remote writer ClusterRole failed")
+	}
+
+	err = t.client.CreateOrUpdateClusterRole(ctx, cr)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Thanos Receive remote writer ClusterRole failed")
+	}
+
+	rs, err := t.factory.ThanosReceiveRBACProxySecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive kube-rbac-proxy Secret failed")
+	}
+
+	err = t.client.CreateOrUpdateSecret(ctx, rs)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Thanos Receive kube-rbac-proxy Secret failed")
+	}
+
+	rs, err = t.factory.ThanosReceiveRBACProxyMetricsSecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive kube-rbac-proxy metrics Secret failed")
+	}
+
+	err = t.client.CreateOrUpdateSecret(ctx, rs)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Thanos Receive kube-rbac-proxy metrics Secret failed")
+	}
+
+	svc, err := t.factory.ThanosReceiveService()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive Service failed")
+	}
+
+	err = t.client.CreateOrUpdateService(ctx, svc)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Thanos Receive Service failed")
+	}
+
+	if !t.config.RouteAPIUnavailable {
+		r, err := t.factory.ThanosReceiveRoute()
+		if err != nil {
+			return errors.Wrap(err, "initializing Thanos Receive Route failed")
+		}
+
+		err = t.client.CreateRouteIfNotExists(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "creating Thanos Receive Route failed")
+		}
+	}
+
+	err = t.client.CreateOrUpdateConfigMap(ctx, hashrings)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Thanos Receive hashrings ConfigMap failed")
+	}
+
+	grpcTLS, err := t.factory.GRPCSecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive GRPC secret failed")
+	}
+
+	grpcTLS, err = t.client.WaitForSecret(ctx, grpcTLS)
+	if err != nil {
+		return errors.Wrap(err, "waiting for Thanos Receive GRPC secret failed")
+	}
+
+	// Thanos Receive serves the store API to Thanos Querier and forwards
+	// the remote write requests to the other members of the hashring, hence
+	// it needs both the server and client certificates.
+	s, err := t.factory.ThanosReceiveGrpcTLSSecret()
+	if err != nil {
+		return errors.Wrap(err, "error initializing Thanos Receive GRPC TLS secret")
+	}
+
+	s, err = t.factory.HashSecret(s,
+		"ca.crt", string(grpcTLS.Data["ca.crt"]),
+		"server.crt", string(grpcTLS.Data["prometheus-server.crt"]),
+		"server.key", string(grpcTLS.Data["prometheus-server.key"]),
+		"client.crt", string(grpcTLS.Data["thanos-querier-client.crt"]),
+		"client.key", string(grpcTLS.Data["thanos-querier-client.key"]),
+	)
+	if err != nil {
+		return errors.Wrap(err, "error hashing Thanos Receive GRPC TLS secret")
+	}
+
+	err = t.client.CreateOrUpdateSecret(ctx, s)
+	if err != nil {
+		return errors.Wrap(err, "error creating Thanos Receive GRPC TLS secret")
+	}
+
+	err = t.client.DeleteHashedSecret(
+		ctx,
+		s.GetNamespace(),
+		"thanos-receive-grpc-tls",
+		string(s.Labels["monitoring.openshift.io/hash"]),
+	)
+	if err != nil {
+		return errors.Wrap(err, "error deleting expired Thanos Receive GRPC TLS secret")
+	}
+
+	sts, err := t.factory.ThanosReceiveStatefulSet(s)
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive StatefulSet failed")
+	}
+
+	err = t.client.CreateOrUpdateStatefulSet(ctx, sts)
+	if err != nil {
+		return errors.Wrap(err, "reconciling Thanos Receive StatefulSet failed")
+	}
+
+	sm, err := t.factory.ThanosReceiveServiceMonitor()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive ServiceMonitor failed")
+	}
+
+	err = t.client.CreateOrUpdateServiceMonitor(ctx, sm)
+	return errors.Wrap(err, "reconciling Thanos Receive ServiceMonitor failed")
+}
+
+func (t *ThanosReceiveTask) destroy(ctx context.Context) error {
+	sm, err := t.factory.ThanosReceiveServiceMonitor()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive ServiceMonitor failed")
+	}
+
+	err = t.client.DeleteServiceMonitor(ctx, sm)
+	if err != nil {
+		return errors.Wrap(err, "deleting Thanos Receive ServiceMonitor failed")
+	}
+
+	sts, err := t.factory.ThanosReceiveStatefulSet(nil)
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive StatefulSet failed")
+	}
+
+	err = t.client.DeleteStatefulSet(ctx, sts)
+	if err != nil {
+		return errors.Wrap(err, "deleting Thanos Receive StatefulSet failed")
+	}
+
+	err = t.client.DeleteHashedSecret(ctx, t.client.Namespace(), "thanos-receive-grpc-tls", "")
+	if err != nil {
+		return errors.Wrap(err, "deleting Thanos Receive GRPC TLS secret failed")
+	}
+
+	if !t.config.RouteAPIUnavailable {
+		r, err := t.factory.ThanosReceiveRoute()
+		if err != nil {
+			return errors.Wrap(err, "initializing Thanos Receive Route failed")
+		}
+
+		err = t.client.DeleteRoute(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "deleting Thanos Receive Route failed")
+		}
+	}
+
+	svc, err := t.factory.ThanosReceiveService()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive Service failed")
+	}
+
+	err = t.client.DeleteService(ctx, svc)
+	if err != nil {
+		return errors.Wrap(err, "deleting Thanos Receive Service failed")
+	}
+
+	cm, err := t.factory.ThanosReceiveHashringsConfigMap()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive hashrings ConfigMap failed")
+	}
+
+	err = t.client.DeleteConfigMap(ctx, cm)
+	if err != nil {
+		return errors.Wrap(err, "deleting Thanos Receive hashrings ConfigMap failed")
+	}
+
+	for _, f := range []func() (*v1.Secret, error){
+		t.factory.ThanosReceiveRBACProxySecret,
+		t.factory.ThanosReceiveRBACProxyMetricsSecret,
+	} {
+		s, err := f()
+		if err != nil {
+			return errors.Wrap(err, "initializing Thanos Receive kube-rbac-proxy Secret failed")
+		}
+
+		err = t.client.DeleteSecret(ctx, s)
+		if err != nil {
+			return errors.Wrap(err, "deleting Thanos Receive kube-rbac-proxy Secret failed")
+		}
+	}
+
+	for _, f := range []func() (*rbacv1.ClusterRole, error){
+		t.factory.ThanosReceiveClusterRole,
+		t.factory.ThanosReceiveRemoteWriterClusterRole,
+	} {
+		cr, err := f()
+		if err != nil {
+			return errors.Wrap(err, "initializing Thanos Receive ClusterRole failed")
+		}
+
+		err = t.client.DeleteClusterRole(ctx, cr)
+		if err != nil {
+			return errors.Wrap(err, "deleting Thanos Receive ClusterRole failed")
+		}
+	}
+
+	crb, err := t.factory.ThanosReceiveClusterRoleBinding()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive ClusterRoleBinding failed")
+	}
+
+	err = t.client.DeleteClusterRoleBinding(ctx, crb)
+	if err != nil {
+		return errors.Wrap(err, "deleting Thanos Receive ClusterRoleBinding failed")
+	}
+
+	sa, err := t.factory.ThanosReceiveServiceAccount()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Receive ServiceAccount failed")
+	}
+
+	err = t.client.DeleteServiceAccount(ctx, sa)
+	return errors.Wrap(err, "deleting Thanos Receive ServiceAccount failed")
+}
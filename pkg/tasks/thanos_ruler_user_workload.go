@@ -54,19 +54,21 @@ func (t *ThanosRulerUserWorkloadTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "reconciling Thanos Ruler Service failed")
 	}
 
-	r, err := t.factory.ThanosRulerRoute()
-	if err != nil {
-		return errors.Wrap(err, "initializing Thanos Ruler Route failed")
-	}
+	if !t.config.RouteAPIUnavailable {
+		r, err := t.factory.ThanosRulerRoute()
+		if err != nil {
+			return errors.Wrap(err, "initializing Thanos Ruler Route failed")
+		}
 
-	err = t.client.CreateRouteIfNotExists(ctx, r)
-	if err != nil {
-		return errors.Wrap(err, "creating Thanos Ruler Route failed")
-	}
+		err = t.client.CreateRouteIfNotExists(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "creating Thanos Ruler Route failed")
+		}
 
-	_, err = t.client.WaitForRouteReady(ctx, r)
-	if err != nil {
-		return errors.Wrap(err, "waiting for Thanos Ruler Route to become ready failed")
+		_, err = t.client.WaitForRouteReady(ctx, r)
+		if err != nil {
+			return errors.Wrap(err, "waiting for Thanos Ruler Route to become ready failed")
+		}
 	}
 
 	cr, err := t.factory.ThanosRulerClusterRole()
@@ -114,9 +116,9 @@ func (t *ThanosRulerUserWorkloadTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "initializing Thanos Ruler OAuth Cookie Secret failed")
 	}
 
-	err = t.client.CreateIfNotExistSecret(ctx, s)
+	err = t.client.CreateOrUpdateGeneratedSecret(ctx, s, "session_secret")
 	if err != nil {
-		return errors.Wrap(err, "creating Thanos Ruler OAuth Cookie Secret failed")
+		return errors.Wrap(err, "reconciling Thanos Ruler OAuth Cookie Secret failed")
 	}
 
 	// Thanos components use https://godoc.org/github.com/prometheus/common/config#NewClientFromConfig
@@ -146,6 +148,16 @@ func (t *ThanosRulerUserWorkloadTask) create(ctx context.Context) error {
 		return errors.Wrap(err, "creating or updating Thanos Ruler alertmanager config Secret failed")
 	}
 
+	arc, err := t.factory.ThanosRulerAlertRelabelConfigsSecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Ruler alert relabel configs Secret failed")
+	}
+
+	err = t.client.CreateOrUpdateSecret(ctx, arc)
+	if err != nil {
+		return errors.Wrap(err, "creating or updating Thanos Ruler alert relabel configs Secret failed")
+	}
+
 	{
 		// Create trusted CA bundle ConfigMap.
 		trustedCA, err := t.factory.ThanosRulerTrustedCABundle()
@@ -202,11 +214,24 @@ func (t *ThanosRulerUserWorkloadTask) create(ctx context.Context) error {
 			return errors.Wrap(err, "error deleting expired UserWorkload Thanos Ruler GRPC TLS secret")
 		}
 
-		querierRoute, err := t.factory.ThanosQuerierRoute()
+		var queryURL string
+		if !t.config.RouteAPIUnavailable {
+			querierRoute, err := t.factory.ThanosQuerierRoute()
+			if err != nil {
+				return errors.Wrap(err, "initializing Thanos Querier Route failed")
+			}
+
+			u, err := t.client.GetRouteURL(ctx, querierRoute)
+			if err != nil {
+				return errors.Wrap(err, "failed to retrieve Thanos Querier host")
+			}
+			queryURL = u.String()
+		}
+
+		tr, err := t.factory.ThanosRulerCustomResource(queryURL, trustedCA, grpcSecret, acs)
 		if err != nil {
-			return errors.Wrap(err, "initializing Thanos Querier Route failed")
+			return errors.Wrap(err, "initializing ThanosRuler object failed")
 		}
-		queryURL, err := t.client.GetRouteURL(ctx, querierRoute)
 
 		pdb, err := t.factory.ThanosRulerPodDisruptionBudget()
 		if err != nil {
@@ -214,17 +239,21 @@ func (t *ThanosRulerUserWorkloadTask) create(ctx context.Context) error {
 		}
 
 		if pdb != nil {
-			err = t.client.CreateOrUpdatePodDisruptionBudget(ctx, pdb)
-			if err != nil {
-				return errors.Wrap(err, "reconciling Thanos Ruler PodDisruptionBudget object failed")
+			// A single replica can't be evicted while keeping one replica
+			// available, the PodDisruptionBudget would block the node drains.
+			if tr.Spec.Replicas != nil && *tr.Spec.Replicas < 2 {
+				err = t.client.DeletePodDisruptionBudget(ctx, pdb)
+				if err != nil {
+					return errors.Wrap(err, "deleting Thanos Ruler PodDisruptionBudget object failed")
+				}
+			} else {
+				err = t.client.CreateOrUpdatePodDisruptionBudget(ctx, pdb)
+				if err != nil {
+					return errors.Wrap(err, "reconciling Thanos Ruler PodDisruptionBudget object failed")
+				}
 			}
 		}
 
-		tr, err := t.factory.ThanosRulerCustomResource(queryURL.String(), trustedCA, grpcSecret, acs)
-		if err != nil {
-			return errors.Wrap(err, "initializing ThanosRuler object failed")
-		}
-
 		err = t.client.CreateOrUpdateThanosRuler(ctx, tr)
 		if err != nil {
 			return errors.Wrap(err, "reconciling ThanosRuler object failed")
@@ -441,6 +470,16 @@ func (t *ThanosRulerUserWorkloadTask) destroy(ctx context.Context) error {
 		return errors.Wrap(err, "creating Thanos Ruler alertmanager config Secret failed")
 	}
 
+	arc, err := t.factory.ThanosRulerAlertRelabelConfigsSecret()
+	if err != nil {
+		return errors.Wrap(err, "initializing Thanos Ruler alert relabel configs Secret failed")
+	}
+
+	err = t.client.DeleteSecret(ctx, arc)
+	if err != nil {
+		return errors.Wrap(err, "deleting Thanos Ruler alert relabel configs Secret failed")
+	}
+
 	trsm, err := t.factory.ThanosRulerServiceMonitor()
 	if err != nil {
 		return errors.Wrap(err, "initializing Thanos Ruler ServiceMonitor failed")
@@ -0,0 +1,74 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openshift/cluster-monitoring-operator/pkg/client"
+	"github.com/openshift/cluster-monitoring-operator/pkg/manifests"
+	"github.com/openshift/cluster-monitoring-operator/pkg/promclient"
+	"k8s.io/klog/v2"
+)
+
+// userAlertsQuery counts the firing alerts which aren't evaluated by the
+// platform Prometheus per namespace. The alerts of prometheus-k8s carry the
+// prometheus external label set by the Prometheus operator.
+const userAlertsQuery = `count by (namespace) (ALERTS{alertstate="firing",namespace!="",prometheus!="%s/k8s"})`
+
+// UserAlertsThrottlingTask measures the firing user-defined alerts which
+// are aggregated into a single notification group per namespace when the
+// throttling of the user alerts is configured. Failures to query the alerts
+// are only logged.
+type UserAlertsThrottlingTask struct {
+	client  *client.Client
+	config  *manifests.Config
+	querier promclient.LabelQuerier
+
+	aggregated map[string]float64
+}
+
+func NewUserAlertsThrottlingTask(client *client.Client, config *manifests.Config, querier promclient.LabelQuerier) *UserAlertsThrottlingTask {
+	return &UserAlertsThrottlingTask{
+		client:  client,
+		config:  config,
+		querier: querier,
+	}
+}
+
+func (t *UserAlertsThrottlingTask) Run(ctx context.Context) error {
+	t.aggregated = nil
+
+	amc := t.config.ClusterMonitoringConfiguration.AlertmanagerMainConfig
+	if t.querier == nil || !amc.IsEnabled() || amc.UserAlertsThrottling == nil {
+		return nil
+	}
+
+	aggregated, err := t.querier.QueryByLabel(ctx, fmt.Sprintf(userAlertsQuery, t.client.Namespace()), "namespace")
+	if err != nil {
+		klog.Warningf("skipping the measurement of the throttled user alerts: %v", err)
+		return nil
+	}
+	t.aggregated = aggregated
+
+	return nil
+}
+
+// Aggregated returns the number of firing user-defined alerts aggregated into
+// the throttled notifications, keyed by namespace.
+func (t *UserAlertsThrottlingTask) Aggregated() map[string]float64 {
+	return t.aggregated
+}
@@ -34,6 +34,8 @@ import (
 )
 
 func TestAlertmanagerTrustedCA(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	var (
 		factory = manifests.NewFactory("openshift-monitoring", "", nil, nil, nil, manifests.NewAssets(assetsPath), &manifests.APIServerConfig{})
 		newCM   *v1.ConfigMap
@@ -76,6 +78,8 @@ func TestAlertmanagerTrustedCA(t *testing.T) {
 
 // The Alertmanager API should be protected by kube-rbac-proxy (and prom-label-proxy).
 func TestAlertmanagerKubeRbacProxy(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	ctx := context.Background()
 	const testNs = "test-kube-rbac-proxy"
 
@@ -299,6 +303,8 @@ func TestAlertmanagerKubeRbacProxy(t *testing.T) {
 // Even when no persistent storage is configured, silences (and notifications)
 // shouldn't be lost when new Alertmanager pods are rolled out.
 func TestAlertmanagerDataReplication(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		silenceLabelName  = "test"
 		silenceLabelValue = "AlertmanagerReplication"
@@ -396,6 +402,8 @@ func TestAlertmanagerDataReplication(t *testing.T) {
 
 // The Alertmanager API should be protected by the OAuth proxy.
 func TestAlertmanagerOAuthProxy(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	err := framework.Poll(5*time.Second, 5*time.Minute, func() error {
 		body, err := f.AlertmanagerClient.GetAlertmanagerAlerts(
 			"filter", `alertname="Watchdog"`,
@@ -428,6 +436,8 @@ func TestAlertmanagerOAuthProxy(t *testing.T) {
 
 // Users should be able to disable Alertmanager through the cluster-monitoring-config
 func TestAlertmanagerDisabling(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	// Disable alertmanager
 	cm := &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -512,6 +522,8 @@ func TestAlertmanagerDisabling(t *testing.T) {
 }
 
 func TestAlertManagerHasAdditionalAlertRelabelConfigs(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		expectPlatformLabel      = "openshift_io_alert_source"
 		expectPlatformLabelValue = "platform"
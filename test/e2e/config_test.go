@@ -17,14 +17,17 @@ package e2e
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"testing"
 	"time"
 
 	configv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/cluster-monitoring-operator/test/e2e/framework"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -34,6 +37,8 @@ const (
 )
 
 func TestClusterMonitoringOperatorConfiguration(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	// Enable user workload monitoring to assess that an invalid configuration
 	// doesn't rollback the last known and valid configuration.
 	setupUserWorkloadAssets(t, f)
@@ -56,7 +61,10 @@ func TestClusterMonitoringOperatorConfiguration(t *testing.T) {
 			"config.yaml": `cannot be deserialized`,
 		},
 	}
-	f.MustCreateOrUpdateConfigMap(t, cm)
+	// The validating webhook would reject the configuration but it fails open
+	// and doesn't run during the cluster bootstrap or while the operator is
+	// down hence the operator still needs to handle invalid configurations.
+	mustCreateOrUpdateConfigMapWithoutValidation(t, f, cm)
 
 	t.Log("asserting that CMO goes degraded after an invalid configuration is pushed")
 	f.AssertOperatorCondition(configv1.OperatorDegraded, configv1.ConditionTrue)(t)
@@ -71,7 +79,47 @@ func TestClusterMonitoringOperatorConfiguration(t *testing.T) {
 	f.AssertOperatorCondition(configv1.OperatorAvailable, configv1.ConditionTrue)(t)
 }
 
+// mustCreateOrUpdateConfigMapWithoutValidation stores the ConfigMap while the
+// configuration validating webhook is removed. The webhook configuration is
+// managed by the CVO which may recreate it at any time hence the removal is
+// retried until the ConfigMap is stored.
+func mustCreateOrUpdateConfigMapWithoutValidation(t *testing.T, f *framework.Framework, cm *v1.ConfigMap) {
+	t.Helper()
+
+	webhooks := f.AdmissionClient.ValidatingWebhookConfigurations()
+	webhook, err := webhooks.Get(ctx, configWebhookName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("unable to get the monitoring config maps validating webhook", err)
+	}
+
+	defer func() {
+		_, err := webhooks.Get(ctx, configWebhookName, metav1.GetOptions{})
+		if !apierrors.IsNotFound(err) {
+			return
+		}
+		webhook.ResourceVersion = ""
+		webhook.UID = ""
+		_, err = webhooks.Create(ctx, webhook, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			t.Fatal("unable to restore the monitoring config maps validating webhook", err)
+		}
+	}()
+
+	err = framework.Poll(time.Second, 5*time.Minute, func() error {
+		err := webhooks.Delete(ctx, configWebhookName, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		return f.OperatorClient.CreateOrUpdateConfigMap(ctx, cm)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestGrafanaConfiguration(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	config := &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "cluster-monitoring-config",
@@ -105,6 +153,8 @@ func TestGrafanaConfiguration(t *testing.T) {
 }
 
 func TestClusterMonitorPrometheusOperatorConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		containerName = "prometheus-operator"
 	)
@@ -135,6 +185,8 @@ func TestClusterMonitorPrometheusOperatorConfig(t *testing.T) {
 }
 
 func TestClusterMonitorPrometheusK8Config(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		pvcClaimName    = "prometheus-k8s-db-prometheus-k8s-0"
 		statefulsetName = "prometheus-k8s"
@@ -214,6 +266,8 @@ func TestClusterMonitorPrometheusK8Config(t *testing.T) {
 }
 
 func TestClusterMonitorAlertManagerConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		pvcClaimName    = "alertmanager-main-db-alertmanager-main-0"
 		statefulsetName = "alertmanager-main"
@@ -266,6 +320,8 @@ func TestClusterMonitorAlertManagerConfig(t *testing.T) {
 }
 
 func TestClusterMonitorKSMConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		deploymentName = "kube-state-metrics"
 	)
@@ -297,6 +353,8 @@ func TestClusterMonitorKSMConfig(t *testing.T) {
 }
 
 func TestClusterMonitorOSMConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		deploymentName = "openshift-state-metrics"
 	)
@@ -328,6 +386,8 @@ func TestClusterMonitorOSMConfig(t *testing.T) {
 }
 
 func TestClusterMonitorGrafanaConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const deploymentName = "grafana"
 	data := `grafana:
   tolerations:
@@ -356,6 +416,8 @@ func TestClusterMonitorGrafanaConfig(t *testing.T) {
 }
 
 func TestClusterMonitorTelemeterClientConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		deploymentName = "telemeter-client"
 	)
@@ -389,6 +451,8 @@ func TestClusterMonitorTelemeterClientConfig(t *testing.T) {
 }
 
 func TestClusterMonitorK8sPromAdapterConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		deploymentName = "prometheus-adapter"
 	)
@@ -420,6 +484,8 @@ func TestClusterMonitorK8sPromAdapterConfig(t *testing.T) {
 }
 
 func TestClusterMonitorThanosQuerierConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		deploymentName = "thanos-querier"
 		containerName  = "thanos-query"
@@ -460,6 +526,8 @@ func TestClusterMonitorThanosQuerierConfig(t *testing.T) {
 }
 
 func TestUserWorkloadMonitorPromOperatorConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		containerName = "prometheus-operator"
 	)
@@ -502,6 +570,8 @@ func TestUserWorkloadMonitorPromOperatorConfig(t *testing.T) {
 }
 
 func TestUserWorkloadMonitorPrometheusK8Config(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	setupUserWorkloadAssetsWithTeardownHook(t, f)
 	const (
 		pvcClaimName    = "prometheus-user-workload-db-prometheus-user-workload-0"
@@ -593,13 +663,18 @@ func TestUserWorkloadMonitorPrometheusK8Config(t *testing.T) {
 }
 
 func TestUserWorkloadMonitorThanosRulerConfig(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	const (
 		containerName   = "thanos-ruler"
 		pvcClaimName    = "thanos-ruler-user-workload-data-thanos-ruler-user-workload-0"
 		statefulsetName = "thanos-ruler-user-workload"
 		cpu             = "1m"
 		mem             = "3Mi"
+		limitCPU        = "100m"
+		limitMem        = "100Mi"
 		storage         = "2Gi"
+		replicas        = 3
 	)
 
 	setupUserWorkloadAssetsWithTeardownHook(t, f)
@@ -615,6 +690,7 @@ func TestUserWorkloadMonitorThanosRulerConfig(t *testing.T) {
 		Data: map[string]string{
 			"config.yaml": fmt.Sprintf(`thanosRuler:
   logLevel: debug
+  replicas: %d
   tolerations:
     - operator: "Exists"
   volumeClaimTemplate:
@@ -626,7 +702,10 @@ func TestUserWorkloadMonitorThanosRulerConfig(t *testing.T) {
     requests:
       cpu: %s
       memory: %s
-`, storage, cpu, mem),
+    limits:
+      cpu: %s
+      memory: %s
+`, replicas, storage, cpu, mem, limitCPU, limitMem),
 		},
 	}
 	f.MustCreateOrUpdateConfigMap(t, uwmCM)
@@ -640,6 +719,18 @@ func TestUserWorkloadMonitorThanosRulerConfig(t *testing.T) {
 			name:      "assert ss exists and rolled out",
 			assertion: f.AssertStatefulSetExistsAndRollout(statefulsetName, f.UserWorkloadMonitoringNs),
 		},
+		{
+			name: "assert ss has the configured replicas",
+			assertion: func(t *testing.T) {
+				ss, err := f.KubeClient.AppsV1().StatefulSets(f.UserWorkloadMonitoringNs).Get(ctx, statefulsetName, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ss.Spec.Replicas == nil || *ss.Spec.Replicas != replicas {
+					t.Fatalf("expected %d replicas, got %v", replicas, ss.Spec.Replicas)
+				}
+			},
+		},
 		{
 			name: "assert pod configuration is as expected",
 			assertion: f.AssertPodConfiguration(
@@ -648,6 +739,7 @@ func TestUserWorkloadMonitorThanosRulerConfig(t *testing.T) {
 				[]framework.PodAssertion{
 					expectCatchAllToleration(),
 					expectMatchingRequests("*", containerName, mem, cpu),
+					expectMatchingLimits("*", containerName, limitMem, limitCPU),
 				},
 			),
 		},
@@ -715,6 +807,30 @@ func expectMatchingRequests(podName, containerName, expectMem, expectCPU string)
 	}
 }
 
+// checks that the container name has the same limit cpu,mem as expected
+// pass "*" as podName t match all
+func expectMatchingLimits(podName, containerName, expectMem, expectCPU string) framework.PodAssertion {
+	return func(pod v1.Pod) error {
+		if podName == "*" || pod.Name == podName {
+			for _, container := range pod.Spec.Containers {
+				if container.Name == containerName {
+					containerMemory := container.Resources.Limits[v1.ResourceMemory]
+					actualMemory := containerMemory.String()
+					if actualMemory != expectMem {
+						return fmt.Errorf("memory limits %s does not match actual %s", expectMem, actualMemory)
+					}
+					containerCPU := container.Resources.Limits[v1.ResourceCPU]
+					actualCPU := containerCPU.String()
+					if actualCPU != expectCPU {
+						return fmt.Errorf("CPU limits %s does not match actual %s", expectCPU, actualCPU)
+					}
+				}
+			}
+		}
+		return nil
+	}
+}
+
 func expectContainerArg(arg string, containerName string) framework.PodAssertion {
 	return func(pod v1.Pod) error {
 		for _, container := range pod.Spec.Containers {
@@ -845,3 +961,103 @@ func assertQueryLogValueEquals(namespace, crName, value string) func(t *testing.
 		}
 	}
 }
+
+// TestConcurrentConfigUpdates edits both configuration ConfigMaps
+// concurrently while removing a managed resource and asserts that the
+// operator converges to the last versions of the configurations.
+func TestConcurrentConfigUpdates(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
+	setupUserWorkloadAssetsWithTeardownHook(t, f)
+	const updates = 10
+
+	cmoConfigMap := func(i int) *v1.ConfigMap {
+		return configMapWithData(t, fmt.Sprintf(`enableUserWorkload: true
+prometheusK8s:
+  retention: %dh
+`, 24+i))
+	}
+	uwmConfigMap := func(i int) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userWorkloadMonitorConfigMapName,
+				Namespace: f.UserWorkloadMonitoringNs,
+				Labels: map[string]string{
+					framework.E2eTestLabelName: framework.E2eTestLabelValue,
+				},
+			},
+			Data: map[string]string{
+				"config.yaml": fmt.Sprintf(`prometheus:
+  retention: %dh
+`, 24+i),
+			},
+		}
+	}
+	t.Cleanup(func() {
+		f.MustDeleteConfigMap(t, uwmConfigMap(0))
+	})
+
+	var g errgroup.Group
+	for _, newConfigMap := range []func(int) *v1.ConfigMap{cmoConfigMap, uwmConfigMap} {
+		newConfigMap := newConfigMap
+		g.Go(func() error {
+			for i := 1; i <= updates; i++ {
+				if err := f.OperatorClient.CreateOrUpdateConfigMap(ctx, newConfigMap(i)); err != nil {
+					return err
+				}
+				time.Sleep(time.Duration(rand.Intn(1000)) * time.Millisecond)
+			}
+			return nil
+		})
+	}
+	// Simulate a drift of the managed resources in the middle of the
+	// reconciliations.
+	g.Go(func() error {
+		time.Sleep(2 * time.Second)
+		return f.KubeClient.AppsV1().Deployments(f.Ns).Delete(ctx, "thanos-querier", metav1.DeleteOptions{})
+	})
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("%dh", 24+updates)
+	for _, tc := range []scenario{
+		{
+			name:      "assert the platform Prometheus uses the last configuration",
+			assertion: assertRetentionEquals(f.Ns, "k8s", expected),
+		},
+		{
+			name:      "assert the user workload Prometheus uses the last configuration",
+			assertion: assertRetentionEquals(f.UserWorkloadMonitoringNs, "user-workload", expected),
+		},
+		{
+			name:      "assert the deleted deployment is restored",
+			assertion: f.AssertDeploymentExistsAndRollout("thanos-querier", f.Ns),
+		},
+		{
+			name:      "assert the operator is healthy",
+			assertion: f.AssertOperatorCondition(configv1.OperatorDegraded, configv1.ConditionFalse),
+		},
+	} {
+		t.Run(tc.name, tc.assertion)
+	}
+}
+
+func assertRetentionEquals(namespace, crName, value string) func(t *testing.T) {
+	return func(t *testing.T) {
+		err := framework.Poll(time.Second, time.Minute*5, func() error {
+			prom, err := f.MonitoringClient.Prometheuses(namespace).Get(context.Background(), crName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			if prom.Spec.Retention != value {
+				return fmt.Errorf("expected retention %q, got %q", value, prom.Spec.Retention)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
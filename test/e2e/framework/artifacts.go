@@ -0,0 +1,187 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ArtifactDirEnv is the environment variable holding the directory
+	// where the state of the cluster is dumped when a test fails. It is set
+	// by the OpenShift CI, nothing is dumped when it is empty.
+	ArtifactDirEnv = "ARTIFACT_DIR"
+
+	// operatorLogLines is the number of lines of the operator logs dumped.
+	operatorLogLines = 2000
+	// podLogLines is the number of lines dumped for each container of the
+	// pods which aren't ready.
+	podLogLines = 200
+)
+
+var unsafePathChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// artifactDir returns the directory holding the artifacts of the given test.
+func artifactDir(root, testName string) string {
+	return filepath.Join(root, "e2e-failures", unsafePathChars.ReplaceAllString(testName, "_"))
+}
+
+// DumpStateOnFailure dumps the state of the monitoring namespaces (pods,
+// events and logs of the pods which aren't ready), the conditions of the
+// monitoring ClusterOperator and the recent logs of the operator into the
+// artifacts directory when the test fails, including when one of its subtests
+// fails. Tests should call it first so that the state is dumped after their
+// own clean-up functions have run, which keeps the artifacts of tests
+// failing on timeouts.
+func (f *Framework) DumpStateOnFailure(t *testing.T) {
+	t.Helper()
+
+	root := os.Getenv(ArtifactDirEnv)
+	if root == "" {
+		return
+	}
+
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+
+		dir := artifactDir(root, t.Name())
+		for _, err := range f.dumpState(context.Background(), dir) {
+			t.Logf("failed to dump the cluster state: %v", err)
+		}
+		t.Logf("cluster state dumped into %s", dir)
+	})
+}
+
+// dumpState writes the cluster state into dir. It carries on when some state
+// can't be collected and returns all the errors.
+func (f *Framework) dumpState(ctx context.Context, dir string) []error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	write := func(name string, obj interface{}) {
+		b, err := yaml.Marshal(obj)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "marshaling %s", name))
+			return
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	co, err := f.OpenShiftConfigClient.ConfigV1().ClusterOperators().Get(ctx, "monitoring", metav1.GetOptions{})
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "getting the monitoring ClusterOperator"))
+	} else {
+		write("clusteroperator-conditions.yaml", co.Status.Conditions)
+	}
+
+	for _, ns := range []string{f.Ns, f.UserWorkloadMonitoringNs} {
+		pods, err := f.KubeClient.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "listing the pods of %s", ns))
+		} else {
+			write(ns+"-pods.yaml", pods.Items)
+			for _, p := range pods.Items {
+				if isPodReady(&p) {
+					continue
+				}
+				for _, c := range p.Spec.Containers {
+					errs = append(errs, f.dumpLogs(ctx, dir, &p, c.Name, podLogLines)...)
+				}
+			}
+		}
+
+		events, err := f.KubeClient.CoreV1().Events(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "listing the events of %s", ns))
+		} else {
+			sort.SliceStable(events.Items, func(i, j int) bool {
+				return eventTime(&events.Items[i]).Before(eventTime(&events.Items[j]))
+			})
+			write(ns+"-events.yaml", events.Items)
+		}
+	}
+
+	pods, err := f.KubeClient.CoreV1().Pods(f.Ns).List(ctx, metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/name=cluster-monitoring-operator",
+	})
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "listing the operator pods"))
+	} else {
+		for _, p := range pods.Items {
+			errs = append(errs, f.dumpLogs(ctx, dir, &p, "cluster-monitoring-operator", operatorLogLines)...)
+		}
+	}
+
+	return errs
+}
+
+// dumpLogs writes the last lines of the container logs into dir.
+func (f *Framework) dumpLogs(ctx context.Context, dir string, p *v1.Pod, container string, lines int64) []error {
+	b, err := f.KubeClient.CoreV1().Pods(p.Namespace).GetLogs(p.Name, &v1.PodLogOptions{
+		Container: container,
+		TailLines: &lines,
+	}).DoRaw(ctx)
+	if err != nil {
+		return []error{errors.Wrapf(err, "getting the logs of %s/%s (%s)", p.Namespace, p.Name, container)}
+	}
+
+	name := fmt.Sprintf("%s-%s-%s.log", p.Namespace, p.Name, container)
+	if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+		return []error{err}
+	}
+
+	return nil
+}
+
+func isPodReady(p *v1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// eventTime returns the time of the last occurrence of the event.
+func eventTime(e *v1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case e.Series != nil:
+		return e.Series.LastObservedTime.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
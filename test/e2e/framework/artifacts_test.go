@@ -0,0 +1,111 @@
+// Copyright 2022 The Cluster Monitoring Operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configfake "github.com/openshift/client-go/config/clientset/versioned/fake"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestArtifactDir(t *testing.T) {
+	got := artifactDir("/artifacts", "TestUserWorkloadMonitoring/assert tenancy model is enforced")
+	expected := "/artifacts/e2e-failures/TestUserWorkloadMonitoring_assert_tenancy_model_is_enforced"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestDumpState(t *testing.T) {
+	ready := v1.PodStatus{Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}}
+	f := &Framework{
+		Ns:                       namespaceName,
+		UserWorkloadMonitoringNs: userWorkloadNamespaceName,
+		KubeClient: fake.NewSimpleClientset(
+			&v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "cluster-monitoring-operator-1", Labels: map[string]string{"app.kubernetes.io/name": "cluster-monitoring-operator"}},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "cluster-monitoring-operator"}}},
+				Status:     ready,
+			},
+			&v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "prometheus-k8s-0"},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "prometheus"}}},
+				Status:     ready,
+			},
+			&v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: userWorkloadNamespaceName, Name: "thanos-ruler-user-workload-0"},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "thanos-ruler"}}},
+			},
+			&v1.Event{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: "event"},
+				Reason:     "BackOff",
+			},
+		),
+		OpenShiftConfigClient: configfake.NewSimpleClientset(
+			&configv1.ClusterOperator{
+				ObjectMeta: metav1.ObjectMeta{Name: "monitoring"},
+				Status: configv1.ClusterOperatorStatus{
+					Conditions: []configv1.ClusterOperatorStatusCondition{{Type: configv1.OperatorDegraded, Status: configv1.ConditionTrue}},
+				},
+			},
+		),
+	}
+
+	dir := t.TempDir()
+	if errs := f.dumpState(context.Background(), dir); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, fi := range files {
+		got = append(got, fi.Name())
+	}
+	sort.Strings(got)
+
+	expected := []string{
+		"clusteroperator-conditions.yaml",
+		"openshift-monitoring-cluster-monitoring-operator-1-cluster-monitoring-operator.log",
+		"openshift-monitoring-events.yaml",
+		"openshift-monitoring-pods.yaml",
+		"openshift-user-workload-monitoring-events.yaml",
+		"openshift-user-workload-monitoring-pods.yaml",
+		// Only the logs of the pods which aren't ready are dumped.
+		"openshift-user-workload-monitoring-thanos-ruler-user-workload-0-thanos-ruler.log",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected files %v, got %v", expected, got)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "clusteroperator-conditions.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) == 0 {
+		t.Fatal("expected the conditions to be dumped")
+	}
+}
@@ -11,6 +11,8 @@ import (
 )
 
 func TestImageRegistryPods(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	var pods *v1.PodList
 
 	// Get all pods in openshift-monitoring namespace.
@@ -115,6 +115,8 @@ func testMain(m *testing.M) error {
 }
 
 func TestTargetsUp(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	// Don't run this test in parallel, as metrics might be influenced by other
 	// tests.
 
@@ -139,6 +141,8 @@ func TestTargetsUp(t *testing.T) {
 // Once we have the need to test multiple recording rules, we can unite them in
 // a single test function.
 func TestMemoryUsageRecordingRule(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	f.ThanosQuerierClient.WaitForQueryReturnGreaterEqualOne(
 		t,
 		time.Minute,
@@ -30,6 +30,8 @@ import (
 )
 
 func TestMultinamespacePrometheusRule(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	ctx := context.Background()
 	nsName := "openshift-test-prometheus-rules" + strconv.FormatInt(time.Now().Unix(), 36)
 	t.Parallel()
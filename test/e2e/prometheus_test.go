@@ -32,6 +32,8 @@ import (
 )
 
 func TestPrometheusMetrics(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	for service, expected := range map[string]int{
 		"prometheus-operator":           1,
 		"prometheus-k8s":                2,
@@ -60,6 +62,8 @@ func TestPrometheusMetrics(t *testing.T) {
 }
 
 func TestAntiAffinity(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	for _, tc := range []struct {
 		name     string
 		instance string
@@ -96,6 +100,8 @@ func TestAntiAffinity(t *testing.T) {
 }
 
 func TestPrometheusRemoteWrite(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	ctx := context.Background()
 
 	name := "remote-write-e2e-test"
@@ -59,6 +59,8 @@ func isAPIServiceAvailable(conditions []apiservicesv1.APIServiceCondition) bool
 }
 
 func TestMetricsAPIAvailability(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	ctx := context.Background()
 	var lastErr error
 	err := wait.Poll(time.Second, 5*time.Minute, func() (bool, error) {
@@ -82,6 +84,8 @@ func TestMetricsAPIAvailability(t *testing.T) {
 }
 
 func TestNodeMetricsPresence(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	ctx := context.Background()
 	var lastErr error
 	err := wait.Poll(time.Second, 5*time.Minute, func() (bool, error) {
@@ -120,6 +124,8 @@ func TestNodeMetricsPresence(t *testing.T) {
 }
 
 func TestPodMetricsPresence(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	var lastErr error
 	ctx := context.Background()
 	err := wait.Poll(time.Second, 5*time.Minute, func() (bool, error) {
@@ -161,6 +167,8 @@ func TestPodMetricsPresence(t *testing.T) {
 }
 
 func TestAggregatedMetricPermissions(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	ctx := context.Background()
 	present := func(where []string, what string) bool {
 		sort.Strings(where)
@@ -227,6 +235,8 @@ func TestAggregatedMetricPermissions(t *testing.T) {
 }
 
 func TestPrometheusAdapterCARotation(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	ctx := context.Background()
 	// Wait for prometheus-adapter deployment
 	f.AssertDeploymentExistsAndRollout("prometheus-adapter", f.Ns)(t)
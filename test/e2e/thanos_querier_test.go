@@ -28,6 +28,8 @@ import (
 )
 
 func TestThanosQuerierTrustedCA(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	ctx := context.Background()
 	var (
 		factory = manifests.NewFactory("openshift-monitoring", "", nil, nil, nil, manifests.NewAssets(assetsPath), &manifests.APIServerConfig{})
@@ -83,6 +85,8 @@ func TestThanosQuerierTrustedCA(t *testing.T) {
 }
 
 func TestThanosQueryCanQueryWatchdogAlert(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	// The 2 minute timeout is what console CI tests set.
 	// If this test is flaky, we should increase until
 	// we can fix the possible DNS resolve issues.
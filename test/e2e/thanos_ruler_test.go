@@ -16,6 +16,8 @@ import (
 )
 
 func TestUserWorkloadThanosRulerWithAdditionalAlertmanagers(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	setupUserWorkloadAssetsWithTeardownHook(t, f)
 	uwmCM := &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
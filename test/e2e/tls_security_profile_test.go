@@ -30,6 +30,8 @@ import (
 )
 
 func TestTLSSecurityProfileConfiguration(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	testCases := []struct {
 		name                  string
 		profile               *configv1.TLSSecurityProfile
@@ -43,6 +43,8 @@ type scenario struct {
 }
 
 func TestUserWorkloadMonitoringMetrics(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	setupUserWorkloadAssetsWithTeardownHook(t, f)
 
 	uwmCM := &v1.ConfigMap{
@@ -105,6 +107,8 @@ func TestUserWorkloadMonitoringMetrics(t *testing.T) {
 }
 
 func TestUserWorkloadMonitoringAlerting(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	setupUserWorkloadAssetsWithTeardownHook(t, f)
 
 	uwmCM := &v1.ConfigMap{
@@ -159,6 +163,8 @@ func TestUserWorkloadMonitoringAlerting(t *testing.T) {
 }
 
 func TestUserWorkloadMonitoringOptOut(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	setupUserWorkloadAssetsWithTeardownHook(t, f)
 
 	uwmCM := &v1.ConfigMap{
@@ -198,6 +204,8 @@ func TestUserWorkloadMonitoringOptOut(t *testing.T) {
 }
 
 func TestUserWorkloadMonitoringGrpcSecrets(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	setupUserWorkloadAssetsWithTeardownHook(t, f)
 
 	uwmCM := &v1.ConfigMap{
@@ -231,6 +239,8 @@ func TestUserWorkloadMonitoringGrpcSecrets(t *testing.T) {
 }
 
 func TestUserWorkloadMonitoringWithAdditionalAlertmanagerConfigs(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	setupUserWorkloadAssetsWithTeardownHook(t, f)
 
 	if err := createSelfSignedCertificateSecret("alertmanager-tls"); err != nil {
@@ -865,6 +875,58 @@ func assertTenancyForRules(t *testing.T) {
 		t.Fatalf("failed to query rules from Thanos querier: %v", err)
 	}
 
+	err = framework.Poll(5*time.Second, time.Minute, func() error {
+		resp, err := client.Do("GET", "/api/v1/alerts", nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code response, want %d, got %d (%s)", http.StatusOK, resp.StatusCode, framework.ClampMax(b))
+		}
+
+		res, err := gabs.ParseJSON(b)
+		if err != nil {
+			return err
+		}
+
+		alerts, err := res.Path("data.alerts").Children()
+		if err != nil {
+			return err
+		}
+
+		var found bool
+		for _, alert := range alerts {
+			labels, err := alert.Path("labels").ChildrenMap()
+			if err != nil {
+				return err
+			}
+
+			if ns := labels["namespace"].Data().(string); ns != userWorkloadTestNs {
+				return errors.Errorf("expected alerts from namespace %q only, got alert from %q", userWorkloadTestNs, ns)
+			}
+
+			if labels["alertname"].Data().(string) == "VersionAlert" {
+				found = true
+			}
+		}
+
+		if !found {
+			return errors.New("VersionAlert not found")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to query alerts from Thanos querier: %v", err)
+	}
+
 	// Check that the account doesn't have to access the query endpoints.
 	for _, path := range []string{"/api/v1/range?query=up", "/api/v1/query_range?query=up&start=0&end=0&step=1s"} {
 		err = framework.Poll(5*time.Second, time.Minute, func() error {
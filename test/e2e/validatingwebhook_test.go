@@ -18,15 +18,20 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	yaml "github.com/ghodss/yaml"
+	configv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/cluster-monitoring-operator/test/e2e/framework"
+	"github.com/pkg/errors"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
-	webhookName = "prometheusrules.openshift.io"
+	webhookName       = "prometheusrules.openshift.io"
+	configWebhookName = "monitoringconfigmaps.openshift.io"
 )
 
 var (
@@ -62,6 +67,8 @@ spec:
 )
 
 func TestPrometheusRuleValidatingWebhook(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
 	ctx := context.Background()
 
 	_, err := f.AdmissionClient.ValidatingWebhookConfigurations().Get(ctx, webhookName, metav1.GetOptions{})
@@ -90,3 +97,45 @@ func TestPrometheusRuleValidatingWebhook(t *testing.T) {
 	}
 
 }
+
+func TestClusterMonitoringConfigValidatingWebhook(t *testing.T) {
+	f.DumpStateOnFailure(t)
+
+	_, err := f.AdmissionClient.ValidatingWebhookConfigurations().Get(ctx, configWebhookName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("unable to get monitoring config maps validating webhook", err)
+	}
+
+	validConfig := getUserWorkloadEnabledConfigMap(t, f)
+	validConfig.Data["config.yaml"] = "prometheusK8s: {}\n"
+	f.MustCreateOrUpdateConfigMap(t, validConfig)
+	defer f.MustDeleteConfigMap(t, validConfig)
+
+	invalidConfig := validConfig.DeepCopy()
+	invalidConfig.Data = map[string]string{
+		"config.yaml": `cannot be deserialized`,
+	}
+
+	// The webhook fails open hence the invalid configuration may be stored
+	// until the operator serves the webhook.
+	t.Log("asserting that the validating webhook rejects an invalid configuration")
+	err = framework.Poll(time.Second, time.Minute, func() error {
+		err := f.OperatorClient.CreateOrUpdateConfigMap(ctx, invalidConfig)
+		if err == nil {
+			return errors.New("invalid configuration was accepted by validatingwebhook")
+		}
+		if !apierrors.IsInvalid(errors.Cause(err)) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		f.MustCreateOrUpdateConfigMap(t, validConfig)
+		t.Fatal(err)
+	}
+
+	t.Log("asserting that CMO stays healthy after an invalid configuration is rejected")
+	f.AssertValueInConfigMapEquals(validConfig.Name, validConfig.Namespace, "config.yaml", validConfig.Data["config.yaml"])(t)
+	f.AssertOperatorCondition(configv1.OperatorDegraded, configv1.ConditionFalse)(t)
+	f.AssertOperatorCondition(configv1.OperatorAvailable, configv1.ConditionTrue)(t)
+}